@@ -4,6 +4,17 @@
 //
 //	pgwatch [OPTIONS] [config | metric | source]
 //
+// Application Options:
+//
+//	--mode=[all|gatherer|webui]          Components to run, the gatherer and
+//	                                     web UI can be deployed separately
+//	                                     sharing the configuration database
+//	                                     (default: all) [$PW_MODE]
+//	--update-check                       Check daily for a newer pgwatch
+//	                                     release and log it, nothing is
+//	                                     downloaded or updated
+//	                                     [$PW_UPDATE_CHECK]
+//
 // Sources:
 //
 //	-s, --sources=                           Postgres URI, file or folder of YAML
@@ -18,10 +29,50 @@
 //	                                         not monitored until they reach the
 //	                                         threshold. (default: 0)
 //	                                         [$PW_MIN_DB_SIZE_MB]
+//	    --min-db-size-hysteresis=            Percentage above --min-db-size-mb a
+//	                                         dormant DB must grow to be monitored
+//	                                         again (default: 10)
+//	                                         [$PW_MIN_DB_SIZE_HYSTERESIS]
+//	    --dormancy-state-file=               File to keep the dormant DBs state
+//	                                         across restarts. Disabled if empty
+//	                                         [$PW_DORMANCY_STATE_FILE]
+//	    --downtime-file=                     File to keep the planned maintenance
+//	                                         downtimes across restarts. Disabled if
+//	                                         empty [$PW_DOWNTIME_FILE]
 //	    --max-parallel-connections-per-db=   Max parallel metric fetches per DB.
 //	                                         Note the multiplication effect on
 //	                                         multi-DB instances (default: 4)
 //	                                         [$PW_MAX_PARALLEL_CONNECTIONS_PER_DB]
+//	    --max-parallel-fetches-per-host=     Max in-flight metric fetches of all
+//	                                         DBs of a physical host, surplus
+//	                                         fetches wait at most the metric
+//	                                         interval. Set to 0 to disable
+//	                                         (default: 3)
+//	                                         [$PW_MAX_PARALLEL_FETCHES_PER_HOST]
+//	    --statement-timeout=                 Max execution time of a metric
+//	                                         query. Enforced both server-side
+//	                                         and client-side. Set to 0 to
+//	                                         disable (default: 5m)
+//	                                         [$PW_STATEMENT_TIMEOUT]
+//	    --audit-log=                         File to record every statement
+//	                                         executed on monitored DBs. Disabled
+//	                                         if empty [$PW_AUDIT_LOG]
+//	    --audit-log-size=                    Maximum size in MB of the audit log
+//	                                         file before it gets rotated
+//	                                         (default: 100) [$PW_AUDIT_LOG_SIZE]
+//	    --audit-log-number=                  Maximum number of old audit log
+//	                                         files to retain (default: 10)
+//	                                         [$PW_AUDIT_LOG_NUMBER]
+//	    --inventory-export=                  File or webhook URL to export the
+//	                                         inventory of monitored DBs to.
+//	                                         Disabled if empty
+//	                                         [$PW_INVENTORY_EXPORT]
+//	    --inventory-export-format=[json|csv] Format of the inventory export
+//	                                         (default: json)
+//	                                         [$PW_INVENTORY_EXPORT_FORMAT]
+//	    --inventory-export-interval=         How often to export the inventory
+//	                                         of monitored DBs (default: 1h)
+//	                                         [$PW_INVENTORY_EXPORT_INTERVAL]
 //	    --try-create-listed-exts-if-missing= Try creating the listed extensions
 //	                                         (comma sep.) on first connect for
 //	                                         all monitored DBs when missing. Main
@@ -50,6 +101,68 @@
 //	                                         (default:
 //	                                         /tmp/pgwatch-emergency-pause)
 //	                                         [$PW_EMERGENCY_PAUSE_TRIGGERFILE]
+//	    --collector-id=                      Identifier of this pgwatch instance
+//	                                         added as a comment to every metric
+//	                                         query. Hostname is used if empty
+//	                                         [$PW_COLLECTOR_ID]
+//	    --run-lock=[auto|file|off]           Fail at startup if another collector
+//	                                         with the same collector id is
+//	                                         running: auto uses an advisory lock
+//	                                         in the configuration database if
+//	                                         any, a lock file otherwise (default:
+//	                                         auto) [$PW_RUN_LOCK]
+//	    --slow-metric-threshold=             Log metric queries running longer
+//	                                         than this. Set to 0 to disable
+//	                                         (default: 5s)
+//	                                         [$PW_SLOW_METRIC_THRESHOLD]
+//	    --clock-drift-threshold=             Warn if the clock of a monitored
+//	                                         server differs more than this from
+//	                                         the pgwatch host. Set to 0 to
+//	                                         disable (default: 1s)
+//	                                         [$PW_CLOCK_DRIFT_THRESHOLD]
+//	    --archiving-stuck-threshold=         Mark WAL archiving as stuck in the
+//	                                         archiver metric if no WAL segment
+//	                                         was archived for this long while
+//	                                         there is some to archive (default:
+//	                                         5m) [$PW_ARCHIVING_STUCK_THRESHOLD]
+//	    --vacuum-starvation-threshold=       Count tables in the
+//	                                         autovacuum_health metric as starving
+//	                                         if over the autovacuum threshold for
+//	                                         this long without being vacuumed
+//	                                         (default: 1h)
+//	                                         [$PW_VACUUM_STARVATION_THRESHOLD]
+//	    --advisory-feed=                     File or URL of the JSON feed with
+//	                                         the latest PostgreSQL minor and
+//	                                         extension versions to report
+//	                                         outdated_version recommendations
+//	                                         [$PW_ADVISORY_FEED]
+//	    --backfill=                          Comma separated cumulative metrics,
+//	                                         e.g. stat_statements,db_stats,
+//	                                         whose first measurements after a
+//	                                         collector downtime are stored as
+//	                                         catch-up rows marked backfilled
+//	                                         [$PW_BACKFILL]
+//	    --align-timestamps                   Schedule the fetches on the interval
+//	                                         boundaries and store the boundary as
+//	                                         the measurement time instead of the
+//	                                         actual fetch time
+//	                                         [$PW_ALIGN_TIMESTAMPS]
+//	    --testdata-days=                     Generate test data for the given
+//	                                         amount of days based on a single
+//	                                         fetch of every configured metric,
+//	                                         write it to sinks and exit
+//	                                         (default: 0) [$PW_TESTDATA_DAYS]
+//	    --testdata-multiplier=               For how many copies of every source
+//	                                         to generate test data (default: 1)
+//	                                         [$PW_TESTDATA_MULTIPLIER]
+//	    --testdata-profile=[steady|diurnal|bursty|spiky]
+//	                                         Workload profile shaping generated
+//	                                         test data (default: steady)
+//	                                         [$PW_TESTDATA_PROFILE]
+//	    --testdata-jitter=                   Max random deviation in percent
+//	                                         applied to every generated column
+//	                                         value (default: 5)
+//	                                         [$PW_TESTDATA_JITTER]
 //
 // Sinks:
 //
@@ -68,6 +181,58 @@
 //	--system-identifier-field=           Tag key for system identifier value
 //	                                     (default: sys_id)
 //	                                     [$PW_SYSTEM_IDENTIFIER_FIELD]
+//	--duplicate-guard=[off|warn|drop]    Detect sources written to a Postgres
+//	                                     sink by several collectors: report
+//	                                     overlaps or drop measurements of
+//	                                     sources owned by another collector
+//	                                     (default: off) [$PW_DUPLICATE_GUARD]
+//	--sink-write-timeout=                Max time to wait for a sink to
+//	                                     accept measurements, further writes
+//	                                     to a hanging sink are dropped. Set
+//	                                     to 0 to disable (default: 30s)
+//	                                     [$PW_SINK_WRITE_TIMEOUT]
+//	--sink-health-check-interval=        How often to check the sinks
+//	                                     availability. Set to 0 to disable
+//	                                     (default: 1m)
+//	                                     [$PW_SINK_HEALTH_CHECK_INTERVAL]
+//	--prometheus-tls-cert=               Certificate file to serve the
+//	                                     Prometheus scrape endpoint over
+//	                                     HTTPS [$PW_PROMETHEUS_TLS_CERT]
+//	--prometheus-tls-key=                Private key file of the Prometheus
+//	                                     scrape endpoint certificate
+//	                                     [$PW_PROMETHEUS_TLS_KEY]
+//	--prometheus-user=                   Basic auth user required to scrape
+//	                                     the Prometheus endpoint
+//	                                     [$PW_PROMETHEUS_USER]
+//	--prometheus-password=               Basic auth password required to
+//	                                     scrape the Prometheus endpoint
+//	                                     [$PW_PROMETHEUS_PASSWORD]
+//	--prometheus-token=                  Bearer token accepted to scrape the
+//	                                     Prometheus endpoint
+//	                                     [$PW_PROMETHEUS_TOKEN]
+//	--prometheus-allowed-cidrs=          Comma separated networks allowed to
+//	                                     scrape the Prometheus endpoint, e.g.
+//	                                     10.0.0.0/8. All if empty
+//	                                     [$PW_PROMETHEUS_ALLOWED_CIDRS]
+//	--prometheus-exemplars               Serve the OpenMetrics format with
+//	                                     exemplars, e.g. query ids, attached
+//	                                     to the counters of metrics having
+//	                                     exemplar_columns
+//	                                     [$PW_PROMETHEUS_EXEMPLARS]
+//	--prometheus-cache-file=             File to keep the measurements
+//	                                     served to Prometheus across
+//	                                     restarts. Disabled if empty
+//	                                     [$PW_PROMETHEUS_CACHE_FILE]
+//	--metric-name-remap=                 Store a metric under another name
+//	                                     as [sink_type:]metric=name, e.g.
+//	                                     prometheus:db_stats=database, can
+//	                                     be used multiple times
+//	                                     [$PW_METRIC_NAME_REMAP]
+//	--metric-name-prefix=                Prefix for the stored metric names
+//	                                     as [sink_type:]prefix, e.g.
+//	                                     prometheus:pg_, can be used
+//	                                     multiple times
+//	                                     [$PW_METRIC_NAME_PREFIX]
 //
 // Logging:
 //
@@ -92,6 +257,9 @@
 //	                                     [$PW_WEBADDR]
 //	--web-user=                          Admin login [$PW_WEBUSER]
 //	--web-password=                      Admin password [$PW_WEBPASSWORD]
+//	--web-readonly                       Reject all requests changing the
+//	                                     configuration or the stored
+//	                                     measurements [$PW_WEBREADONLY]
 //
 // Help Options:
 //
@@ -102,9 +270,12 @@
 //	config         Manage configurations
 //	   init            Initialize configuration
 //	   upgrade         Upgrade configuration schema
+//	   show-effective  Print the fully resolved configuration of the running instance
 //	metric         Manage metrics
 //	   print-init      Get and print init SQL for a given metric or preset
 //	   print-sql       Get and print SQL for a given metric
+//	refresh        Make the running instance re-read sources and metrics now
 //	source         Manage sources
 //	   ping            Try to connect to configured sources, report errors if any and exit
+//	   forget          Make the running instance remove the stored measurements of decommissioned sources
 package main
@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"os"
 	"os/signal"
@@ -11,6 +10,8 @@ import (
 	"syscall"
 
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/cmdopts"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/faults"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/handover"
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/reaper"
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/webserver"
@@ -31,6 +32,14 @@ func SetupCloseHandler(cancel context.CancelFunc) {
 	}()
 }
 
+// shutdownCommand returns the handler of the remote shutdown requests, the collector stops as on Ctrl+C
+func shutdownCommand(cancel context.CancelFunc) func() {
+	return func() {
+		exitCode.Store(cmdopts.ExitCodeShutdownCommand)
+		cancel()
+	}
+}
+
 var (
 	exitCode atomic.Int32          // Exit code to be returned to the OS
 	mainCtx  context.Context       // Main context for the application
@@ -47,7 +56,7 @@ func main() {
 	defer func() {
 		if err := recover(); err != nil {
 			exitCode.Store(cmdopts.ExitCodeFatalError)
-			log.GetLogger(mainCtx).WithField("callstack", string(debug.Stack())).Error(err)
+			log.GetLogger(mainCtx).WithField(log.CallstackField, string(debug.Stack())).Error(err)
 		}
 		os.Exit(int(exitCode.Load()))
 	}()
@@ -71,37 +80,100 @@ func main() {
 		return
 	}
 
+	opts.Version = version
 	logger = log.Init(opts.Logging)
 	mainCtx = log.WithLogger(mainCtx, logger)
 
+	if opts.Logging.ErrorReportDSN > "" {
+		hook, err := log.NewErrorReportHook(opts.Logging.ErrorReportDSN, opts.Logging.ErrorReportEnvironment, version)
+		if err != nil {
+			exitCode.Store(cmdopts.ExitCodeConfigError)
+			logger.Error(err)
+			return
+		}
+		logger.AddHook(hook)
+	}
+
+	faults.Init(opts.Faults)
+	if opts.Faults.Enabled() {
+		logger.WithField("faults", opts.Faults).Warning("failure injection enabled, not for production use")
+	}
+
 	logger.Debugf("opts: %+v", opts)
 
-	if err := opts.InitConfigReaders(mainCtx); err != nil {
-		exitCode.Store(cmdopts.ExitCodeCmdError)
-		logger.Error(err)
+	if opts.Mode == cmdopts.ModeAgent && opts.AgentMemLimit > 0 && os.Getenv("GOMEMLIMIT") == "" {
+		debug.SetMemoryLimit(opts.AgentMemLimit << 20)
+	}
+
+	// listeners inherited on an upgrade or bound alongside the running collector are ready before the run lock
+	if err = handover.Prepare(mainCtx, opts.ListenAddresses(), opts.Metrics.ReusePort || opts.Metrics.Handover); err != nil {
+		exitCode.Store(cmdopts.ExitCodeRunLockError)
+		logger.Error("failed to prepare the listeners: ", err)
 		return
 	}
 
-	if upgrade, err := opts.NeedsSchemaUpgrade(); upgrade || err != nil {
-		if upgrade {
-			err = errors.Join(err, errors.New(`configuration needs upgrade, use "init --upgrade" command`))
+	// all the problems preventing the start are reported at once rather than failing on the first one
+	if report := opts.Preflight(mainCtx); len(report) > 0 {
+		report.Log(logger)
+		if code := report.ExitCode(); code != cmdopts.ExitCodeOK {
+			exitCode.Store(code)
+			return
+		}
+	}
+
+	if !opts.RunsGatherer() {
+		// the web UI only deployment shares the configuration database with the gatherers
+		ws, err := webserver.Init(mainCtx, opts.WebUI, webui.WebUIFs, opts.MetricsReaderWriter,
+			opts.SourcesReaderWriter, alwaysReady{})
+		if err != nil {
+			exitCode.Store(cmdopts.ExitCodeWebUIError)
+			logger.Error("failed to initialize web UI: ", err)
+			return
+		}
+		ws.SetShutdownHandler(shutdownCommand(cancel))
+		if pid := handover.ParentPID(); pid > 0 {
+			_ = handover.Terminate(pid)
 		}
-		exitCode.Store(cmdopts.ExitCodeUpgradeError)
+		SetupUpgradeSignalHandler()
+		<-mainCtx.Done()
+		return
+	}
+
+	var runLock reaper.RunLock
+	if pid := handover.ParentPID(); pid > 0 || opts.Metrics.Handover {
+		runLock, err = reaper.TakeOverRunLock(mainCtx, opts, pid)
+	} else {
+		runLock, err = reaper.AcquireRunLock(mainCtx, opts)
+	}
+	if err != nil {
+		exitCode.Store(cmdopts.ExitCodeRunLockError)
 		logger.Error(err)
 		return
 	}
+	defer runLock.Release()
 
 	reaper := reaper.NewReaper(opts, opts.SourcesReaderWriter, opts.MetricsReaderWriter)
+	SetupDebugSignalHandler(reaper)
 
-	if _, err = webserver.Init(mainCtx, opts.WebUI, webui.WebUIFs, opts.MetricsReaderWriter,
-		opts.SourcesReaderWriter, reaper); err != nil {
+	ws, err := webserver.Init(mainCtx, opts.WebUI, webui.WebUIFs, opts.MetricsReaderWriter,
+		opts.SourcesReaderWriter, reaper)
+	if err != nil {
 		exitCode.Store(cmdopts.ExitCodeWebUIError)
 		logger.Error("failed to initialize web UI: ", err)
 		return
 	}
+	ws.SetShutdownHandler(shutdownCommand(cancel))
+	SetupUpgradeSignalHandler()
 
 	if err = reaper.Reap(mainCtx); err != nil {
 		logger.Error(err)
 		exitCode.Store(cmdopts.ExitCodeFatalError)
 	}
 }
+
+// alwaysReady reports the web UI only process ready as soon as it is serving
+type alwaysReady struct{}
+
+func (alwaysReady) Ready() bool {
+	return true
+}
@@ -0,0 +1,51 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/handover"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/reaper"
+)
+
+// SetupDebugSignalHandler listens for SIGUSR1 to dump the internal state of the reaper
+// to the log and for SIGUSR2 to toggle debug logging on and off
+func SetupDebugSignalHandler(r *reaper.Reaper) {
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for sig := range c {
+			switch sig {
+			case syscall.SIGUSR1:
+				r.LogState(mainCtx)
+			case syscall.SIGUSR2:
+				logger.WithField("level", logger.ToggleDebug()).Warning("log level changed by SIGUSR2")
+			}
+		}
+	}()
+}
+
+// SetupUpgradeSignalHandler listens for SIGHUP to start the executable again, e.g. replaced by a newer version,
+// and pass the listeners to the new process. It asks this one to exit once it's ready to take over
+func SetupUpgradeSignalHandler() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	go func() {
+		for range c {
+			p, err := handover.Upgrade()
+			if err != nil {
+				logger.WithError(err).Error("could not start a new collector for the handover")
+				continue
+			}
+			logger.WithField("pid", p.Pid).Warning("new collector started by SIGHUP, handing over")
+			go func() {
+				if state, err := p.Wait(); err == nil {
+					logger.WithField("pid", p.Pid).WithField("state", state.String()).Error("new collector exited before taking over")
+				}
+			}()
+		}
+	}()
+}
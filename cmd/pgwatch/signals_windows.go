@@ -0,0 +1,9 @@
+package main
+
+import "github.com/cybertec-postgresql/pgwatch/v3/internal/reaper"
+
+// SetupDebugSignalHandler is a no-op, SIGUSR1 and SIGUSR2 are not available on Windows
+func SetupDebugSignalHandler(*reaper.Reaper) {}
+
+// SetupUpgradeSignalHandler is a no-op, SIGHUP is not available on Windows
+func SetupUpgradeSignalHandler() {}
@@ -0,0 +1,58 @@
+package alerting
+
+import (
+	"errors"
+	"time"
+)
+
+// DowntimeMetric is the internal metric stored once a minute for every monitored DB, with an active row per
+// downtime of the DB or a single inactive row. The generated rules skip the DBs having an active row
+const DowntimeMetric = "downtime"
+
+// downtimeStaleness is how old the latest downtime rows may be for the rules to still honor them
+const downtimeStaleness = 3 * time.Minute
+
+// ErrDowntimeNotFound is returned on cancelling a downtime not scheduled or already over
+var ErrDowntimeNotFound = errors.New("downtime not found")
+
+// Downtime is a planned maintenance window silencing the alert rules and suspending the availability
+// accounting of the matching monitored DBs. Empty DBName, Group or Metric match all of them
+type Downtime struct {
+	ID      string    `json:"id"`
+	DBName  string    `json:"dbname,omitempty"`
+	Group   string    `json:"group,omitempty"`
+	Metric  string    `json:"metric,omitempty"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Reason  string    `json:"reason"`
+	Creator string    `json:"creator"`
+}
+
+// Validate checks the downtime is scoped and not over yet, the missing start is set to now
+func (d *Downtime) Validate(now time.Time) error {
+	if d.DBName == "" && d.Group == "" {
+		return errors.New("downtime dbname or group is required")
+	}
+	if d.Start.IsZero() {
+		d.Start = now
+	}
+	if !d.End.After(d.Start) {
+		return errors.New("downtime end must be after its start")
+	}
+	if !d.End.After(now) {
+		return errors.New("downtime end is in the past")
+	}
+	return nil
+}
+
+// Active returns true if the downtime is in effect at the time
+func (d Downtime) Active(now time.Time) bool {
+	return !now.Before(d.Start) && now.Before(d.End)
+}
+
+// Matches returns true if the downtime applies to the metric of the monitored DB of the source group
+func (d Downtime) Matches(dbName, group, metric string) bool {
+	return (d.DBName == "" || d.DBName == dbName) &&
+		(d.Group == "" || d.Group == group) &&
+		(d.Metric == "" || d.Metric == metric)
+}
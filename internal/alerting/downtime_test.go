@@ -0,0 +1,37 @@
+package alerting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDowntimeValidate(t *testing.T) {
+	now := time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC)
+	d := Downtime{DBName: "db1", End: now.Add(time.Hour)}
+	assert.NoError(t, d.Validate(now))
+	assert.Equal(t, now, d.Start, "starts now by default")
+
+	for err, d := range map[string]Downtime{
+		"dbname or group is required": {Metric: "db_stats", End: now.Add(time.Hour)},
+		"end must be after its start": {Group: "prod", Start: now.Add(time.Hour), End: now.Add(time.Minute)},
+		"end is in the past":          {Group: "prod", Start: now.Add(-time.Hour), End: now.Add(-time.Minute)},
+	} {
+		assert.ErrorContains(t, d.Validate(now), err)
+	}
+}
+
+func TestDowntimeMatches(t *testing.T) {
+	now := time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC)
+	d := Downtime{Group: "prod", Metric: "archiver", Start: now, End: now.Add(time.Hour)}
+	assert.True(t, d.Active(now))
+	assert.False(t, d.Active(now.Add(time.Hour)), "the end is exclusive")
+	assert.False(t, d.Active(now.Add(-time.Second)))
+
+	assert.True(t, d.Matches("db1", "prod", "archiver"))
+	assert.False(t, d.Matches("db1", "prod", "db_stats"))
+	assert.False(t, d.Matches("db1", "staging", "archiver"))
+	assert.True(t, Downtime{DBName: "db1"}.Matches("db1", "", "db_stats"), "all metrics of the DB")
+	assert.False(t, Downtime{DBName: "db1"}.Matches("db2", "", "db_stats"))
+}
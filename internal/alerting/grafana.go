@@ -0,0 +1,308 @@
+package alerting
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/jackc/pgx/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// Data source types the alert rule queries are generated for
+const (
+	DatasourcePostgres   = "postgres"
+	DatasourcePrometheus = "prometheus"
+)
+
+// Severities of the generated rules, set as the severity label
+const (
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+const (
+	defaultFor       = 5 * time.Minute
+	expressionSource = "__expr__" // datasource UID of the Grafana server side expressions
+)
+
+// Options parameterize the generated alert rules
+type Options struct {
+	DatasourceType string
+	DatasourceUID  string
+	OrgID          int64
+	Folder         string
+	Interval       time.Duration // evaluation interval of the rule groups
+	Lookback       time.Duration // time range of the Postgres data source queries
+	PromNamespace  string        // namespace of the Prometheus sink
+}
+
+// Group is a set of monitored sources getting their own rule group, e.g. a fleet
+type Group struct {
+	Name    string
+	DBNames string // regex the dbname of the measurements must match, all sources if empty
+}
+
+// SourcesGroup returns the rule group of the sources of the group. Databases found by the discovery
+// sources are matched by their default names, i.e. prefixed with the source name
+func SourcesGroup(name string, srcs sources.Sources) Group {
+	var patterns []string
+	for _, s := range srcs {
+		if s.Group != name {
+			continue
+		}
+		switch s.Kind {
+		case sources.SourcePostgres, sources.SourcePgBouncer, sources.SourcePgPool:
+			patterns = append(patterns, regexp.QuoteMeta(s.Name))
+		default:
+			patterns = append(patterns, regexp.QuoteMeta(s.Name)+"_.*")
+		}
+	}
+	if len(patterns) == 0 {
+		return Group{Name: name, DBNames: "^$"} // matches nothing, the group has no sources
+	}
+	return Group{Name: name, DBNames: strings.Join(patterns, "|")}
+}
+
+// Provisioning is a Grafana alerting provisioning file, see
+// https://grafana.com/docs/grafana/latest/alerting/set-up/provision-alerting-resources/file-provisioning/
+type Provisioning struct {
+	APIVersion int         `json:"apiVersion" yaml:"apiVersion"`
+	Groups     []RuleGroup `json:"groups" yaml:"groups"`
+}
+
+// RuleGroup is a group of Grafana-managed alert rules evaluated together
+type RuleGroup struct {
+	OrgID    int64  `json:"orgId" yaml:"orgId"`
+	Name     string `json:"name" yaml:"name"`
+	Folder   string `json:"folder" yaml:"folder"`
+	Interval string `json:"interval" yaml:"interval"`
+	Rules    []Rule `json:"rules" yaml:"rules"`
+}
+
+// Rule is a Grafana-managed alert rule
+type Rule struct {
+	UID          string            `json:"uid" yaml:"uid"`
+	Title        string            `json:"title" yaml:"title"`
+	Condition    string            `json:"condition" yaml:"condition"`
+	Data         []Query           `json:"data" yaml:"data"`
+	NoDataState  string            `json:"noDataState" yaml:"noDataState"`
+	ExecErrState string            `json:"execErrState" yaml:"execErrState"`
+	For          string            `json:"for" yaml:"for"`
+	Labels       map[string]string `json:"labels" yaml:"labels"`
+	Annotations  map[string]string `json:"annotations" yaml:"annotations"`
+	IsPaused     bool              `json:"isPaused" yaml:"isPaused"`
+}
+
+// Query is a data source query or server side expression of an alert rule
+type Query struct {
+	RefID             string         `json:"refId" yaml:"refId"`
+	RelativeTimeRange TimeRange      `json:"relativeTimeRange" yaml:"relativeTimeRange"`
+	DatasourceUID     string         `json:"datasourceUid" yaml:"datasourceUid"`
+	Model             map[string]any `json:"model" yaml:"model"`
+}
+
+// TimeRange is relative to the evaluation time, in seconds
+type TimeRange struct {
+	From int64 `json:"from" yaml:"from"`
+	To   int64 `json:"to" yaml:"to"`
+}
+
+// GenerateGrafanaRules returns the alert rules checking the alert thresholds of the metrics for every group,
+// all sources are checked by a single "pgwatch" group if no group is given
+func GenerateGrafanaRules(defs *metrics.Metrics, groups []Group, opts Options) (*Provisioning, error) {
+	if opts.DatasourceType != DatasourcePostgres && opts.DatasourceType != DatasourcePrometheus {
+		return nil, fmt.Errorf("unsupported data source type %q", opts.DatasourceType)
+	}
+	if len(groups) == 0 {
+		groups = []Group{{Name: "pgwatch"}}
+	}
+	p := &Provisioning{APIVersion: 1}
+	for _, g := range groups {
+		rg := RuleGroup{OrgID: cmp.Or(opts.OrgID, 1), Name: g.Name, Folder: opts.Folder, Interval: duration(opts.Interval), Rules: []Rule{}}
+		for _, name := range slices.Sorted(maps.Keys(defs.MetricDefs)) {
+			def := defs.MetricDefs[name]
+			for _, a := range def.Alerts {
+				rules, err := thresholdRules(name, def, a, g, opts)
+				if err != nil {
+					return nil, fmt.Errorf("metric %s: %w", name, err)
+				}
+				rg.Rules = append(rg.Rules, rules...)
+			}
+		}
+		p.Groups = append(p.Groups, rg)
+	}
+	return p, nil
+}
+
+// thresholdRules returns the rules of the critical and warning thresholds of the alert
+func thresholdRules(metric string, def metrics.Metric, a metrics.AlertThreshold, g Group, opts Options) ([]Rule, error) {
+	op := cmp.Or(a.Operator, ">")
+	if a.Name == "" || a.Column == "" {
+		return nil, errors.New("alert name and column are required")
+	}
+	if op != ">" && op != "<" {
+		return nil, fmt.Errorf("alert %s: unsupported operator %q, expected > or <", a.Name, a.Operator)
+	}
+	if a.Critical == nil && a.Warning == nil {
+		return nil, fmt.Errorf("alert %s: no warning or critical threshold", a.Name)
+	}
+	forDuration := defaultFor
+	if a.For > "" {
+		d, err := time.ParseDuration(a.For)
+		if err != nil {
+			return nil, fmt.Errorf("alert %s: %w", a.Name, err)
+		}
+		forDuration = d
+	}
+	query := sqlQuery(metric, def, a, op, g, opts)
+	if opts.DatasourceType == DatasourcePrometheus {
+		query = promQuery(metric, def, a, op, g, opts)
+	}
+
+	var rules []Rule
+	for _, t := range []struct {
+		severity  string
+		threshold *float64
+	}{{SeverityCritical, a.Critical}, {SeverityWarning, a.Warning}} {
+		if t.threshold == nil {
+			continue
+		}
+		summary := cmp.Or(a.Summary, fmt.Sprintf("%s.%s %s %v", metric, a.Column, op, *t.threshold))
+		uid := sha256.Sum256([]byte(strings.Join([]string{g.Name, metric, a.Name, t.severity}, "\x00")))
+		rules = append(rules, Rule{
+			UID:       "pgwatch-" + hex.EncodeToString(uid[:12]),
+			Title:     fmt.Sprintf("[%s] %s %s (%s)", g.Name, metric, a.Name, t.severity),
+			Condition: "B",
+			Data: []Query{query, {
+				RefID:         "B",
+				DatasourceUID: expressionSource,
+				Model: map[string]any{
+					"refId":      "B",
+					"type":       "threshold",
+					"expression": "A",
+					"conditions": []any{map[string]any{
+						"evaluator": map[string]any{"type": map[string]string{">": "gt", "<": "lt"}[op], "params": []float64{*t.threshold}},
+					}},
+				},
+			}},
+			NoDataState:  "OK", // the metric is not gathered from the sources
+			ExecErrState: "Error",
+			For:          duration(forDuration),
+			Labels:       map[string]string{"severity": t.severity, "metric": metric, "pgwatch_group": g.Name},
+			Annotations:  map[string]string{"summary": "{{ $labels.dbname }}: " + summary},
+		})
+	}
+	return rules, nil
+}
+
+// promQuery returns the instant query of the column value per source as exposed by the Prometheus sink,
+// the sources in downtime are skipped
+func promQuery(metric string, def metrics.Metric, a metrics.AlertThreshold, op string, g Group, opts Options) Query {
+	series := cmp.Or(def.StorageName, metric) + "_" + a.Column + def.ColumnAttrs[a.Column].UnitSuffix(a.Column)
+	if metric == "instance_up" {
+		series = metric // exposed without the column name
+	}
+	if opts.PromNamespace > "" {
+		series = opts.PromNamespace + "_" + series
+	}
+	if g.DBNames > "" {
+		series += fmt.Sprintf(`{dbname=~%q}`, g.DBNames)
+	}
+	downtime := DowntimeMetric + "_active"
+	if opts.PromNamespace > "" {
+		downtime = opts.PromNamespace + "_" + downtime
+	}
+	expr := fmt.Sprintf(`%s by (dbname) (%s) unless on (dbname) (%s{silenced_metric=~"|%s"} == 1)`,
+		aggregate(op), series, downtime, regexp.QuoteMeta(metric))
+	return Query{
+		RefID:             "A",
+		RelativeTimeRange: TimeRange{From: int64(opts.Lookback.Seconds())},
+		DatasourceUID:     opts.DatasourceUID,
+		Model: map[string]any{
+			"refId":   "A",
+			"expr":    expr,
+			"instant": true,
+			"range":   false,
+		},
+	}
+}
+
+// sqlQuery returns the query of the column value per source over the lookback period as stored by the Postgres sink,
+// the sources in downtime are skipped
+func sqlQuery(metric string, def metrics.Metric, a metrics.AlertThreshold, op string, g Group, opts Options) Query {
+	table := pgx.Identifier{cmp.Or(def.StorageSchema, "public"), cmp.Or(def.StorageName, metric)}.Sanitize()
+	value := fmt.Sprintf("(data->>%s)::float8", literal(a.Column))
+	if scale := def.ColumnAttrs[a.Column].Scale; scale != 0 {
+		value = fmt.Sprintf("%s * %v", value, scale)
+	}
+	where := fmt.Sprintf("time > now() - interval '%d seconds'", int64(opts.Lookback.Seconds()))
+	where += fmt.Sprintf(" and not exists (select from %s d where d.dbname = m.dbname and d.time > now() - interval '%d seconds'"+
+		" and (d.data->>'active')::int = 1 and coalesce(d.tag_data->>'silenced_metric', '') in ('', %s))",
+		pgx.Identifier{"public", DowntimeMetric}.Sanitize(), int64(downtimeStaleness.Seconds()), literal(metric))
+	if g.DBNames > "" {
+		where += fmt.Sprintf(" and dbname ~ %s", literal("^("+g.DBNames+")$"))
+	}
+	sql := fmt.Sprintf("select dbname, %s(%s) as value from %s m where %s group by dbname", aggregate(op), value, table, where)
+	return Query{
+		RefID:             "A",
+		RelativeTimeRange: TimeRange{From: int64(opts.Lookback.Seconds())},
+		DatasourceUID:     opts.DatasourceUID,
+		Model: map[string]any{
+			"refId":      "A",
+			"rawSql":     sql,
+			"format":     "table",
+			"rawQuery":   true,
+			"editorMode": "code",
+		},
+	}
+}
+
+// aggregate returns the aggregate of the values of a source closest to crossing the threshold
+func aggregate(op string) string {
+	if op == "<" {
+		return "min"
+	}
+	return "max"
+}
+
+// literal returns s as a SQL string literal
+func literal(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// duration formats d the way Grafana expects, e.g. 5m instead of 5m0s
+func duration(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0 && d > 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0 && d > 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	default:
+		return fmt.Sprintf("%ds", int64(d.Seconds()))
+	}
+}
+
+// Write writes the provisioning file in the format, yaml or json
+func (p *Provisioning) Write(w io.Writer, format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(p)
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	defer enc.Close()
+	return enc.Encode(p)
+}
@@ -0,0 +1,125 @@
+package alerting
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func ptr(f float64) *float64 {
+	return &f
+}
+
+var testMetrics = &metrics.Metrics{MetricDefs: metrics.MetricDefs{
+	"sequence_health": {MetricAttrs: metrics.MetricAttrs{Alerts: []metrics.AlertThreshold{{Name: "sequence_exhaustion", Column: "max_used_pct", Warning: ptr(75), Critical: ptr(90)}}}},
+	"instance_up":     {MetricAttrs: metrics.MetricAttrs{Alerts: []metrics.AlertThreshold{{Name: "instance_down", Column: "is_up", Operator: "<", Critical: ptr(1), For: "2m"}}}},
+	"wal_size": {StorageName: "wal", MetricAttrs: metrics.MetricAttrs{
+		ColumnAttrs: metrics.ColumnAttrs{"wal_blocks": {Unit: metrics.ColumnUnitBytes, Scale: 8192}},
+		Alerts:      []metrics.AlertThreshold{{Name: "wal_too_big", Column: "wal_blocks", Warning: ptr(1 << 30)}},
+	}},
+	"db_stats": {},
+}}
+
+func TestGenerateGrafanaRules(t *testing.T) {
+	opts := Options{DatasourceType: DatasourcePostgres, DatasourceUID: "sink", Folder: "pgwatch", Interval: time.Minute, Lookback: 10 * time.Minute}
+	p, err := GenerateGrafanaRules(testMetrics, nil, opts)
+	require.NoError(t, err)
+	require.Len(t, p.Groups, 1)
+	g := p.Groups[0]
+	assert.Equal(t, "pgwatch", g.Name)
+	assert.Equal(t, "1m", g.Interval)
+	assert.EqualValues(t, 1, g.OrgID)
+	require.Len(t, g.Rules, 4, "one rule per metric threshold and severity")
+
+	down := g.Rules[0]
+	assert.Equal(t, "[pgwatch] instance_up instance_down (critical)", down.Title)
+	assert.Equal(t, "2m", down.For)
+	assert.Equal(t, map[string]string{"severity": "critical", "metric": "instance_up", "pgwatch_group": "pgwatch"}, down.Labels)
+	assert.Equal(t, `select dbname, min((data->>'is_up')::float8) as value from "public"."instance_up" m where time > now() - interval '600 seconds'`+
+		` and not exists (select from "public"."downtime" d where d.dbname = m.dbname and d.time > now() - interval '180 seconds'`+
+		` and (d.data->>'active')::int = 1 and coalesce(d.tag_data->>'silenced_metric', '') in ('', 'instance_up')) group by dbname`,
+		down.Data[0].Model["rawSql"], "the sources in downtime are skipped")
+	assert.Equal(t, "__expr__", down.Data[1].DatasourceUID)
+	assert.Contains(t, down.Data[1].Model["conditions"], map[string]any{"evaluator": map[string]any{"type": "lt", "params": []float64{1}}})
+
+	assert.Equal(t, "critical", g.Rules[1].Labels["severity"])
+	assert.Equal(t, "5m", g.Rules[1].For, "default pending period")
+	assert.Equal(t, "warning", g.Rules[2].Labels["severity"])
+	assert.NotEqual(t, g.Rules[1].UID, g.Rules[2].UID)
+	assert.Contains(t, g.Rules[3].Data[0].Model["rawSql"], `max((data->>'wal_blocks')::float8 * 8192) as value from "public"."wal" m`)
+
+	again, err := GenerateGrafanaRules(testMetrics, nil, opts)
+	require.NoError(t, err)
+	assert.Equal(t, p, again, "rules are stable across runs")
+}
+
+func TestGenerateGrafanaRulesPrometheus(t *testing.T) {
+	groups := []Group{{Name: "prod", DBNames: "db1|db2"}, {Name: "staging"}}
+	p, err := GenerateGrafanaRules(testMetrics, groups, Options{DatasourceType: DatasourcePrometheus, DatasourceUID: "prom", PromNamespace: "pgwatch"})
+	require.NoError(t, err)
+	require.Len(t, p.Groups, 2)
+	prod := p.Groups[0].Rules
+	assert.Equal(t, `min by (dbname) (pgwatch_instance_up{dbname=~"db1|db2"}) unless on (dbname) (pgwatch_downtime_active{silenced_metric=~"|instance_up"} == 1)`,
+		prod[0].Data[0].Model["expr"], "the sources in downtime are skipped")
+	assert.Equal(t, `max by (dbname) (pgwatch_sequence_health_max_used_pct{dbname=~"db1|db2"}) unless on (dbname) (pgwatch_downtime_active{silenced_metric=~"|sequence_health"} == 1)`,
+		prod[1].Data[0].Model["expr"])
+	assert.Contains(t, prod[3].Data[0].Model["expr"], `max by (dbname) (pgwatch_wal_wal_blocks_bytes{dbname=~"db1|db2"}) unless`)
+	assert.Contains(t, p.Groups[1].Rules[1].Data[0].Model["expr"], `max by (dbname) (pgwatch_sequence_health_max_used_pct) unless`)
+	assert.NotEqual(t, prod[0].UID, p.Groups[1].Rules[0].UID, "UIDs are unique per group")
+}
+
+func TestGenerateGrafanaRulesErrors(t *testing.T) {
+	for name, a := range map[string]metrics.AlertThreshold{
+		"column are required":    {Name: "x", Critical: ptr(1)},
+		"unsupported operator":   {Name: "x", Column: "c", Operator: ">=", Critical: ptr(1)},
+		"no warning or critical": {Name: "x", Column: "c"},
+		"invalid duration":       {Name: "x", Column: "c", Critical: ptr(1), For: "soon"},
+	} {
+		_, err := GenerateGrafanaRules(&metrics.Metrics{MetricDefs: metrics.MetricDefs{"m": {MetricAttrs: metrics.MetricAttrs{Alerts: []metrics.AlertThreshold{a}}}}},
+			nil, Options{DatasourceType: DatasourcePostgres})
+		assert.ErrorContains(t, err, name)
+	}
+	_, err := GenerateGrafanaRules(testMetrics, nil, Options{DatasourceType: "influx"})
+	assert.ErrorContains(t, err, "unsupported data source type")
+}
+
+func TestSourcesGroup(t *testing.T) {
+	srcs := sources.Sources{
+		{Name: "db.1", Kind: sources.SourcePostgres, Group: "prod"},
+		{Name: "cluster", Kind: sources.SourcePostgresContinuous, Group: "prod"},
+		{Name: "db3", Kind: sources.SourcePostgres, Group: "staging"},
+	}
+	assert.Equal(t, Group{Name: "prod", DBNames: `db\.1|cluster_.*`}, SourcesGroup("prod", srcs))
+	assert.Equal(t, Group{Name: "dev", DBNames: "^$"}, SourcesGroup("dev", srcs))
+}
+
+func TestProvisioningWrite(t *testing.T) {
+	p, err := GenerateGrafanaRules(testMetrics, nil, Options{DatasourceType: DatasourcePostgres, Interval: time.Minute})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, p.Write(&buf, "yaml"))
+	var y map[string]any
+	require.NoError(t, yaml.Unmarshal(buf.Bytes(), &y))
+	assert.Equal(t, 1, y["apiVersion"])
+
+	buf.Reset()
+	require.NoError(t, p.Write(&buf, "json"))
+	var j map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &j))
+	assert.Len(t, j["groups"], 1)
+}
+
+func TestDuration(t *testing.T) {
+	assert.Equal(t, "2h", duration(2*time.Hour))
+	assert.Equal(t, "90m", duration(90*time.Minute))
+	assert.Equal(t, "30s", duration(30*time.Second))
+	assert.Equal(t, "0s", duration(0))
+}
@@ -0,0 +1,78 @@
+package cmdopts
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/alerting"
+)
+
+type AlertsCommand struct {
+	owner   *Options
+	Grafana AlertsGrafanaCommand `command:"grafana" description:"Print Grafana-managed alert rules provisioning file for the metric thresholds"`
+}
+
+func NewAlertsCommand(owner *Options) *AlertsCommand {
+	return &AlertsCommand{
+		owner:   owner,
+		Grafana: AlertsGrafanaCommand{owner: owner},
+	}
+}
+
+type AlertsGrafanaCommand struct {
+	owner          *Options
+	DatasourceUID  string        `long:"datasource-uid" description:"UID of the Grafana data source the rules query" required:"true"`
+	DatasourceType string        `long:"datasource-type" description:"Type of the data source, i.e. the sink the measurements are stored in" choice:"postgres" choice:"prometheus" default:"postgres"`
+	Groups         []string      `long:"group" description:"Generate a rule group for the sources of the group, can be repeated. All sources are checked by one rule group if not set"`
+	Folder         string        `long:"folder" description:"Grafana folder of the rule groups" default:"pgwatch"`
+	Interval       time.Duration `long:"interval" description:"Evaluation interval of the rule groups" default:"1m"`
+	Lookback       time.Duration `long:"lookback" description:"Time range of the data source queries" default:"10m"`
+	PromNamespace  string        `long:"prometheus-namespace" description:"Namespace of the Prometheus sink metrics" default:"pgwatch"`
+	OrgID          int64         `long:"org-id" description:"Grafana organization ID" default:"1"`
+	Format         string        `long:"format" description:"Output format" choice:"yaml" choice:"json" default:"yaml"`
+}
+
+// Execute prints the alert rules checking the thresholds of the "alerts" metric attribute, parameterized
+// per source group, so that the same alerting baseline can be provisioned for every fleet
+func (cmd *AlertsGrafanaCommand) Execute([]string) error {
+	ctx := context.Background()
+	err := cmd.owner.InitMetricReader(ctx)
+	if err != nil {
+		return err
+	}
+	defs, err := cmd.owner.MetricsReaderWriter.GetMetrics()
+	if err != nil {
+		return err
+	}
+	var groups []alerting.Group
+	if len(cmd.Groups) > 0 {
+		if err = cmd.owner.InitSourceReader(ctx); err != nil {
+			return err
+		}
+		srcs, err := cmd.owner.SourcesReaderWriter.GetSources()
+		if err != nil {
+			return err
+		}
+		for _, g := range cmd.Groups {
+			groups = append(groups, alerting.SourcesGroup(g, srcs))
+		}
+	}
+	p, err := alerting.GenerateGrafanaRules(defs, groups, alerting.Options{
+		DatasourceType: cmd.DatasourceType,
+		DatasourceUID:  cmd.DatasourceUID,
+		OrgID:          cmd.OrgID,
+		Folder:         cmd.Folder,
+		Interval:       cmd.Interval,
+		Lookback:       cmd.Lookback,
+		PromNamespace:  cmd.PromNamespace,
+	})
+	if err != nil {
+		return err
+	}
+	if err = p.Write(os.Stdout, cmd.Format); err != nil {
+		return err
+	}
+	cmd.owner.CompleteCommand(ExitCodeOK)
+	return nil
+}
@@ -0,0 +1,36 @@
+package cmdopts
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertsGrafanaCommand_Execute(t *testing.T) {
+	os.Args = []string{0: "config_test", "alerts", "grafana"}
+	_, err := New(nil)
+	assert.ErrorContains(t, err, "datasource-uid", "data source UID is required")
+
+	os.Args = []string{0: "config_test", "alerts", "grafana", "--datasource-uid=metrics", "--datasource-type=prometheus", "--format=json"}
+	opts, err := New(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, ExitCodeOK, opts.ExitCode)
+
+	f, err := os.CreateTemp(t.TempDir(), "sample.config.yaml")
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.WriteString(`
+- name: test1
+  kind: postgres
+  conn_str: postgresql://foo@localhost:1/baz
+  group: prod
+  is_enabled: true`)
+	require.NoError(t, err)
+
+	os.Args = []string{0: "config_test", "--sources=" + f.Name(), "alerts", "grafana", "--datasource-uid=metrics", "--group=prod", "--group=staging"}
+	opts, err = New(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, ExitCodeOK, opts.ExitCode)
+}
@@ -0,0 +1,133 @@
+package cmdopts
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/db"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/report"
+)
+
+type BaselineCommand struct {
+	owner   *Options
+	Capture BaselineCaptureCommand `command:"capture" description:"Store a labelled snapshot of the key metrics of a source in the Postgres sink"`
+	Compare BaselineCompareCommand `command:"compare" description:"Compare a time window or another snapshot of a source with a stored snapshot"`
+}
+
+func NewBaselineCommand(owner *Options) *BaselineCommand {
+	return &BaselineCommand{
+		owner:   owner,
+		Capture: BaselineCaptureCommand{owner: owner},
+		Compare: BaselineCompareCommand{owner: owner},
+	}
+}
+
+// BaselineWindow is the time window of the measurements a snapshot is calculated from
+type BaselineWindow struct {
+	Window time.Duration `long:"window" description:"Length of the time window of the measurements" default:"1h"`
+	End    string        `long:"end" description:"End of the time window in RFC 3339 format, e.g. 2024-01-31T12:00:00Z (default: now)"`
+}
+
+// Bounds returns the start and end of the window
+func (w BaselineWindow) Bounds() (from, to time.Time, err error) {
+	to = time.Now()
+	if w.End != "" {
+		if to, err = time.Parse(time.RFC3339, w.End); err != nil {
+			return from, to, fmt.Errorf("invalid --end: %w", err)
+		}
+	}
+	return to.Add(-w.Window), to, nil
+}
+
+type BaselineCaptureCommand struct {
+	owner  *Options
+	Source string `long:"source" description:"Name of the source" required:"true"`
+	Label  string `long:"label" description:"Label of the snapshot, e.g. before-upgrade. An existing snapshot with the label is replaced" required:"true"`
+	BaselineWindow
+}
+
+// Execute calculates the snapshot from the measurements stored in the Postgres sink and stores it there
+func (cmd *BaselineCaptureCommand) Execute([]string) error {
+	connStr, err := cmd.owner.PostgresSink()
+	if err != nil {
+		return err
+	}
+	from, to, err := cmd.Bounds()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	err = func() error {
+		conn, err := db.New(ctx, connStr)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		b, err := report.FetchBaseline(ctx, conn, cmd.Source, cmd.Label, from, to)
+		if err != nil {
+			return err
+		}
+		if err = report.SaveBaseline(ctx, conn, b); err != nil {
+			return err
+		}
+		fmt.Printf("Baseline %q of %s captured\n", cmd.Label, cmd.Source)
+		return nil
+	}()
+	if err != nil {
+		fmt.Printf("FAIL:\t%s\n", err)
+	}
+	// err here specifies execution error, not configuration error
+	cmd.owner.CompleteCommand(map[bool]int32{true: ExitCodeCmdError, false: ExitCodeOK}[err != nil])
+	return nil
+}
+
+type BaselineCompareCommand struct {
+	owner   *Options
+	Source  string `long:"source" description:"Name of the source" required:"true"`
+	Label   string `long:"label" description:"Label of the stored snapshot to compare with" required:"true"`
+	Against string `long:"against" description:"Label of the stored snapshot to compare, instead of the time window"`
+	Format  string `long:"format" description:"Report format" choice:"markdown" choice:"json" default:"markdown"`
+	BaselineWindow
+}
+
+// Execute prints the differences of the snapshot or time window to the stored snapshot to stdout
+func (cmd *BaselineCompareCommand) Execute([]string) error {
+	connStr, err := cmd.owner.PostgresSink()
+	if err != nil {
+		return err
+	}
+	from, to, err := cmd.Bounds()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	err = func() error {
+		conn, err := db.New(ctx, connStr)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		before, err := report.LoadBaseline(ctx, conn, cmd.Source, cmd.Label)
+		if err != nil {
+			return err
+		}
+		var after *report.Baseline
+		if cmd.Against != "" {
+			after, err = report.LoadBaseline(ctx, conn, cmd.Source, cmd.Against)
+		} else {
+			after, err = report.FetchBaseline(ctx, conn, cmd.Source, "", from, to)
+		}
+		if err != nil {
+			return err
+		}
+		return report.Compare(before, after).Write(os.Stdout, cmd.Format)
+	}()
+	if err != nil {
+		fmt.Printf("FAIL:\t%s\n", err)
+	}
+	// err here specifies execution error, not configuration error
+	cmd.owner.CompleteCommand(map[bool]int32{true: ExitCodeCmdError, false: ExitCodeOK}[err != nil])
+	return nil
+}
@@ -1,8 +1,14 @@
 package cmdopts
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
 
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
@@ -12,6 +18,7 @@ type ConfigCommand struct {
 	owner   *Options
 	Init    ConfigInitCommand    `command:"init" description:"Initialize configuration"`
 	Upgrade ConfigUpgradeCommand `command:"upgrade" description:"Upgrade configuration schema"`
+	Show    ConfigShowCommand    `command:"show-effective" description:"Print the fully resolved configuration of the running instance"`
 }
 
 func NewConfigCommand(owner *Options) *ConfigCommand {
@@ -19,6 +26,7 @@ func NewConfigCommand(owner *Options) *ConfigCommand {
 		owner:   owner,
 		Init:    ConfigInitCommand{owner: owner},
 		Upgrade: ConfigUpgradeCommand{owner: owner},
+		Show:    ConfigShowCommand{owner: owner},
 	}
 }
 
@@ -97,3 +105,51 @@ func (cmd *ConfigUpgradeCommand) Execute([]string) (err error) {
 	opts.CompleteCommand(ExitCodeConfigError)
 	return errors.New("configuration storage does not support upgrade")
 }
+
+type ConfigShowCommand struct {
+	owner *Options
+}
+
+// Execute prints the effective configuration of the running pgwatch instance listening on --web-addr,
+// i.e. the options and the sources after the discovery, the presets resolution and the standby switching
+func (cmd *ConfigShowCommand) Execute([]string) error {
+	cfg, err := cmd.owner.FetchEffectiveConfig(&http.Client{Timeout: 10 * time.Second})
+	if err != nil {
+		fmt.Printf("FAIL:\t%s\n", err)
+	} else {
+		fmt.Println(string(cfg))
+	}
+	// err here specifies execution error, not configuration error
+	cmd.owner.CompleteCommand(map[bool]int32{true: ExitCodeCmdError, false: ExitCodeOK}[err != nil])
+	return nil
+}
+
+// FetchEffectiveConfig logs in to the REST API of the running instance and returns its effective configuration
+func (c *Options) FetchEffectiveConfig(client *http.Client) ([]byte, error) {
+	baseURL, token, err := c.login(client)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/effective-config", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Token", token)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching effective configuration failed: %s", bytes.TrimSpace(body))
+	}
+	var out bytes.Buffer
+	if err = json.Indent(&out, body, "", "  "); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -2,7 +2,10 @@ package cmdopts
 
 import (
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -86,3 +89,35 @@ func TestConfigUpgradeCommand_Execute(t *testing.T) {
 	})
 
 }
+
+func TestConfigShowCommand_Execute(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("token"))
+	})
+	mux.HandleFunc("/effective-config", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Token") != "token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"sources":[{"name":"db1"}]}`))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	opts := &Options{}
+	opts.WebUI.WebAddr = strings.TrimPrefix(ts.URL, "http://")
+	cfg, err := opts.FetchEffectiveConfig(ts.Client())
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n  \"sources\": [\n    {\n      \"name\": \"db1\"\n    }\n  ]\n}", string(cfg))
+
+	os.Args = []string{0: "config_test", "--web-addr=" + opts.WebUI.WebAddr, "config", "show-effective"}
+	opts, err = New(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, ExitCodeOK, opts.ExitCode)
+
+	ts.Close()
+	opts, err = New(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, ExitCodeCmdError, opts.ExitCode, "unreachable instance should be reported")
+}
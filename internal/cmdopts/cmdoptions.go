@@ -6,8 +6,11 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/faults"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/ingest"
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/sinks"
@@ -26,6 +29,15 @@ const (
 	ExitCodeUserCancel
 	ExitCodeShutdownCommand
 	ExitCodeFatalError
+	ExitCodeRunLockError
+)
+
+// Components started by the main process, see --mode
+const (
+	ModeAll      string = "all"
+	ModeGatherer string = "gatherer"
+	ModeWebUI    string = "webui"
+	ModeAgent    string = "agent" // gatherer of an edge host pushing to a central collector
 )
 
 type Kind int
@@ -44,8 +56,17 @@ type Options struct {
 	Sinks   sinks.CmdOpts     `group:"Sinks"`
 	Logging log.CmdOpts       `group:"Logging"`
 	WebUI   webserver.CmdOpts `group:"WebUI"`
+	Ingest  ingest.CmdOpts    `group:"Ingest"`
+	Faults  faults.CmdOpts    `group:"Failure injection" hidden:"true"`
+	Mode    string            `long:"mode" mapstructure:"mode" description:"Components to run, the gatherer and web UI can be deployed separately sharing the configuration database. The agent is a lightweight gatherer pushing to a central collector" env:"PW_MODE" default:"all" choice:"all" choice:"gatherer" choice:"webui" choice:"agent"`
 	Help    bool
 
+	UpdateCheck   bool          `long:"update-check" mapstructure:"update-check" description:"Check daily for a newer pgwatch release and log it, nothing is downloaded or updated" env:"PW_UPDATE_CHECK"`
+	StatsWindow   time.Duration `long:"stats-window" mapstructure:"stats-window" description:"Window of the average collector throughput in the stats REST API, in whole minutes" env:"PW_STATS_WINDOW" default:"5m"`
+	StatsHistory  time.Duration `long:"stats-history" mapstructure:"stats-history" description:"Period of the per-minute collector throughput history kept in memory for the stats REST API" env:"PW_STATS_HISTORY" default:"24h"`
+	AgentMemLimit int64         `long:"agent-memory-limit" mapstructure:"agent-memory-limit" description:"Soft memory limit of the agent mode in MB, the garbage collection gets more aggressive when approaching it. Set to 0 to disable" env:"PW_AGENT_MEMORY_LIMIT" default:"64"`
+	Version       string        `no-flag:"true"` // of the running binary, set by main
+
 	// sourcesReaderWriter reads/writes the monitored sources (databases, patroni clusters, pgpools, etc.) information
 	SourcesReaderWriter sources.ReaderWriter
 	// metricsReaderWriter reads/writes the metric and preset definitions
@@ -58,6 +79,11 @@ func addCommands(parser *flags.Parser, opts *Options) {
 	_, _ = parser.AddCommand("metric", "Manage metrics", "", NewMetricCommand(opts))
 	_, _ = parser.AddCommand("source", "Manage sources", "", NewSourceCommand(opts))
 	_, _ = parser.AddCommand("config", "Manage configurations", "", NewConfigCommand(opts))
+	_, _ = parser.AddCommand("refresh", "Make the running instance re-read sources and metrics now", "", NewRefreshCommand(opts))
+	_, _ = parser.AddCommand("report", "Summarize the stored measurements", "", NewReportCommand(opts))
+	_, _ = parser.AddCommand("baseline", "Capture and compare snapshots of the stored measurements", "", NewBaselineCommand(opts))
+	_, _ = parser.AddCommand("upgrade-check", "Check sources for the blockers of a major version upgrade", "", NewUpgradeCheckCommand(opts))
+	_, _ = parser.AddCommand("alerts", "Generate alert rules from the metric thresholds", "", NewAlertsCommand(opts))
 }
 
 // New returns a new instance of Options and immediately executes the subcommand if specified.
@@ -95,6 +121,32 @@ func (c *Options) CompleteCommand(code int32) {
 	c.ExitCode = code
 }
 
+// RunsGatherer returns true if the metric gathering should be started
+func (c *Options) RunsGatherer() bool {
+	return c.Mode != ModeWebUI
+}
+
+// ListenAddresses returns the addresses the process listens on: the web UI, the Prometheus sinks and the ingest
+// endpoint of the gatherer
+func (c *Options) ListenAddresses() (addrs []string) {
+	if c.WebUI.WebDisable != webserver.WebDisableAll {
+		addrs = append(addrs, c.WebUI.WebAddr)
+	}
+	if !c.RunsGatherer() {
+		return
+	}
+	for _, s := range c.Sinks.Sinks {
+		if path, ok := strings.CutPrefix(s, "prometheus://"); ok {
+			addr, _, _ := strings.Cut(path, "/")
+			addrs = append(addrs, addr)
+		}
+	}
+	if c.Ingest.Listen > "" {
+		addrs = append(addrs, c.Ingest.Listen)
+	}
+	return
+}
+
 // Verbose returns true if the debug log is enabled
 func (c *Options) Verbose() bool {
 	return c.Logging.LogLevel == "debug"
@@ -146,7 +198,7 @@ func (c *Options) InitSourceReader(ctx context.Context) (err error) {
 	case ConfigPgURL:
 		c.SourcesReaderWriter, err = sources.NewPostgresSourcesReaderWriter(ctx, c.Sources.Sources)
 	default:
-		c.SourcesReaderWriter, err = sources.NewYAMLSourcesReaderWriter(ctx, c.Sources.Sources)
+		c.SourcesReaderWriter, err = sources.NewEncryptedYAMLSourcesReaderWriter(ctx, c.Sources.Sources, c.Sources.DecryptionKey)
 	}
 	return
 }
@@ -189,11 +241,59 @@ func (c *Options) ValidateConfig() error {
 	if c.Sources.MaxParallelConnectionsPerDb < 1 {
 		return errors.New("--max-parallel-connections-per-db must be >= 1")
 	}
+	if c.Sources.MaxParallelFetchesPerHost < 0 {
+		return errors.New("--max-parallel-fetches-per-host must be >= 0")
+	}
+	if c.Sources.StatementTimeout < 0 {
+		return errors.New("--statement-timeout must be >= 0")
+	}
 
 	// validate that input is boolean is set
 	if c.Sinks.BatchingDelay <= 0 || c.Sinks.BatchingDelay > time.Hour {
 		return errors.New("--batching-delay-ms must be between 0 and 3600000")
 	}
 
+	switch c.Mode {
+	case ModeWebUI:
+		if c.WebUI.WebDisable != "" {
+			return errors.New("--web-disable cannot be used with --mode=webui")
+		}
+	case ModeGatherer:
+		// the REST API stays available for the health probes and the refresh command
+		if c.WebUI.WebDisable == "" {
+			c.WebUI.WebDisable = webserver.WebDisableUI
+		}
+	case ModeAgent:
+		if err := c.validateAgent(); err != nil {
+			return err
+		}
+	}
+
+	if c.Metrics.TestdataDays < 0 {
+		return errors.New("--testdata-days must be >= 0")
+	}
+	if c.Metrics.TestdataDays > 0 && c.Metrics.TestdataMultiplier < 1 {
+		return errors.New("--testdata-multiplier must be >= 1")
+	}
+
+	return nil
+}
+
+// validateAgent checks the agent mode only pushes to central collectors, serving nothing itself
+func (c *Options) validateAgent() error {
+	if len(c.Sinks.Sinks) == 0 {
+		return errors.New("--mode=agent needs a grpc:// sink of the central collector")
+	}
+	for _, s := range c.Sinks.Sinks {
+		if !strings.HasPrefix(s, "grpc://") {
+			return fmt.Errorf("--mode=agent supports grpc:// sinks only, got %s", log.Redact(s))
+		}
+	}
+	if c.Ingest.Listen > "" {
+		return errors.New("--ingest-listen cannot be used with --mode=agent")
+	}
+	if c.WebUI.WebDisable == "" {
+		c.WebUI.WebDisable = webserver.WebDisableAll
+	}
 	return nil
 }
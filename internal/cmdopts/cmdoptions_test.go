@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/webserver"
 	flags "github.com/jessevdk/go-flags"
 	"github.com/stretchr/testify/assert"
 )
@@ -66,3 +67,68 @@ func TestConfig(t *testing.T) {
 	_, err = New(nil)
 	assert.NoError(t, err)
 }
+
+func TestMode(t *testing.T) {
+	os.Args = []string{0: "config_test", "--sources=sample.config.yaml"}
+	opts, err := New(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, ModeAll, opts.Mode)
+	assert.True(t, opts.RunsGatherer())
+	assert.Empty(t, opts.WebUI.WebDisable)
+
+	os.Args = []string{0: "config_test", "--sources=sample.config.yaml", "--mode=gatherer"}
+	opts, err = New(nil)
+	assert.NoError(t, err)
+	assert.True(t, opts.RunsGatherer())
+	assert.Equal(t, webserver.WebDisableUI, opts.WebUI.WebDisable, "REST API kept for the probes")
+
+	os.Args = []string{0: "config_test", "--sources=sample.config.yaml", "--mode=gatherer", "--web-disable"}
+	opts, err = New(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, webserver.WebDisableAll, opts.WebUI.WebDisable)
+
+	os.Args = []string{0: "config_test", "--sources=sample.config.yaml", "--mode=webui"}
+	opts, err = New(nil)
+	assert.NoError(t, err)
+	assert.False(t, opts.RunsGatherer())
+
+	os.Args = []string{0: "config_test", "--sources=sample.config.yaml", "--mode=webui", "--web-disable=ui"}
+	_, err = New(nil)
+	assert.Error(t, err)
+
+	os.Args = []string{0: "config_test", "--sources=sample.config.yaml", "--mode=agent", "--sink=grpc://collector:9188"}
+	opts, err = New(nil)
+	assert.NoError(t, err)
+	assert.True(t, opts.RunsGatherer())
+	assert.Equal(t, webserver.WebDisableAll, opts.WebUI.WebDisable, "agent serves nothing")
+
+	os.Args = []string{0: "config_test", "--sources=sample.config.yaml", "--mode=agent", "--sink=postgresql://localhost/metrics"}
+	_, err = New(nil)
+	assert.ErrorContains(t, err, "grpc:// sinks only")
+
+	os.Args = []string{0: "config_test", "--sources=sample.config.yaml", "--mode=agent", "--sink=grpc://collector:9188", "--ingest-listen=:9188"}
+	_, err = New(nil)
+	assert.ErrorContains(t, err, "--ingest-listen")
+
+	os.Args = []string{0: "config_test", "--sources=sample.config.yaml", "--mode=unknown"}
+	_, err = New(nil)
+	assert.Error(t, err)
+}
+
+func TestListenAddresses(t *testing.T) {
+	os.Args = []string{0: "config_test", "--sources=sample.config.yaml", "--web-addr=:8080", "--sink=prometheus://:9187/pgwatch",
+		"--sink=postgresql://localhost/metrics", "--sink=prometheus://localhost:9188", "--ingest-listen=:9189"}
+	opts, err := New(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{":8080", ":9187", "localhost:9188", ":9189"}, opts.ListenAddresses())
+
+	os.Args = []string{0: "config_test", "--sources=sample.config.yaml", "--web-addr=:8080", "--sink=prometheus://:9187", "--mode=webui"}
+	opts, err = New(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{":8080"}, opts.ListenAddresses(), "no sinks without the gatherer")
+
+	os.Args = []string{0: "config_test", "--sources=sample.config.yaml", "--sink=prometheus://:9187", "--web-disable"}
+	opts, err = New(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{":9187"}, opts.ListenAddresses())
+}
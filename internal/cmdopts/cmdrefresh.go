@@ -0,0 +1,83 @@
+package cmdopts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+type RefreshCommand struct {
+	owner *Options
+}
+
+func NewRefreshCommand(owner *Options) *RefreshCommand {
+	return &RefreshCommand{owner: owner}
+}
+
+// Execute asks the running pgwatch instance listening on --web-addr to re-read
+// the sources and metric definitions immediately instead of waiting for --refresh seconds
+func (cmd *RefreshCommand) Execute([]string) error {
+	err := cmd.owner.RequestRefresh(&http.Client{Timeout: 10 * time.Second})
+	if err != nil {
+		fmt.Printf("FAIL:\t%s\n", err)
+	} else {
+		fmt.Println("OK:\trefresh requested")
+	}
+	// err here specifies execution error, not configuration error
+	cmd.owner.CompleteCommand(map[bool]int32{true: ExitCodeCmdError, false: ExitCodeOK}[err != nil])
+	return nil
+}
+
+// login authenticates against the REST API of the running instance listening on --web-addr
+// and returns its base URL together with the session token
+func (c *Options) login(client *http.Client) (baseURL string, token string, err error) {
+	host, port, err := net.SplitHostPort(c.WebUI.WebAddr)
+	if err != nil {
+		return
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	baseURL = "http://" + net.JoinHostPort(host, port)
+	creds, _ := json.Marshal(map[string]string{"user": c.WebUI.WebUser, "password": c.WebUI.WebPassword})
+	resp, err := client.Post(baseURL+"/login", "application/json", bytes.NewReader(creds))
+	if err != nil {
+		return
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("login failed: %s", bytes.TrimSpace(body))
+	}
+	return baseURL, string(body), nil
+}
+
+// RequestRefresh logs in to the REST API of the running instance and triggers the immediate refresh
+func (c *Options) RequestRefresh(client *http.Client) error {
+	baseURL, token, err := c.login(client)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, baseURL+"/refresh", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Token", token)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("refresh failed: %s", bytes.TrimSpace(body))
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package cmdopts
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshCommand_Execute(t *testing.T) {
+	refreshes := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("token"))
+	})
+	mux.HandleFunc("/refresh", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Token") != "token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		refreshes++
+		w.WriteHeader(http.StatusAccepted)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	os.Args = []string{0: "config_test", "--web-addr=" + strings.TrimPrefix(ts.URL, "http://"), "refresh"}
+	opts, err := New(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, ExitCodeOK, opts.ExitCode)
+	assert.Equal(t, 1, refreshes)
+
+	ts.Close()
+	opts, err = New(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, ExitCodeCmdError, opts.ExitCode, "unreachable instance should be reported")
+	assert.Equal(t, 1, refreshes)
+}
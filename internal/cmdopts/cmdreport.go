@@ -0,0 +1,68 @@
+package cmdopts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/db"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/report"
+)
+
+type ReportCommand struct {
+	owner *Options
+	Fleet ReportFleetCommand `command:"fleet" description:"Print a summary of all the sources from the measurements stored in the Postgres sink"`
+}
+
+func NewReportCommand(owner *Options) *ReportCommand {
+	return &ReportCommand{
+		owner: owner,
+		Fleet: ReportFleetCommand{owner: owner},
+	}
+}
+
+type ReportFleetCommand struct {
+	owner  *Options
+	Format string `long:"format" description:"Report format" choice:"markdown" choice:"html" choice:"json" default:"markdown"`
+	Days   int    `long:"days" description:"Period of the measurements considered, e.g. for the top growers" default:"30"`
+	Top    int    `long:"top" description:"Max number of sources in the top lists" default:"10"`
+}
+
+// PostgresSink returns the first Postgres sink URI of the --sink options
+func (c *Options) PostgresSink() (string, error) {
+	for _, s := range c.Sinks.Sinks {
+		if strings.HasPrefix(s, "postgres://") || strings.HasPrefix(s, "postgresql://") {
+			return s, nil
+		}
+	}
+	return "", errors.New("the command needs a Postgres sink specified with --sink")
+}
+
+// Execute prints the fleet report read from the Postgres sink to stdout
+func (cmd *ReportFleetCommand) Execute([]string) error {
+	connStr, err := cmd.owner.PostgresSink()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	err = func() error {
+		conn, err := db.New(ctx, connStr)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		fleet, err := report.FetchFleet(ctx, conn, report.FleetOptions{Days: cmd.Days, Top: cmd.Top})
+		if err != nil {
+			return err
+		}
+		return fleet.Write(os.Stdout, cmd.Format)
+	}()
+	if err != nil {
+		fmt.Printf("FAIL:\t%s\n", err)
+	}
+	// err here specifies execution error, not configuration error
+	cmd.owner.CompleteCommand(map[bool]int32{true: ExitCodeCmdError, false: ExitCodeOK}[err != nil])
+	return nil
+}
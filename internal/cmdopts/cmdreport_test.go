@@ -0,0 +1,53 @@
+package cmdopts
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportFleetCommand_Execute(t *testing.T) {
+	os.Args = []string{0: "config_test", "--sink=jsonfile://test.json", "report", "fleet"}
+	_, err := New(nil)
+	assert.ErrorContains(t, err, "needs a Postgres sink")
+
+	os.Args = []string{0: "config_test", "--sink=jsonfile://test.json", "--sink=postgresql://localhost:1/pgwatch_metrics?connect_timeout=1",
+		"report", "fleet", "--format=json"}
+	opts, err := New(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, ExitCodeCmdError, opts.ExitCode, "unreachable sink should be reported")
+}
+
+func TestOptions_PostgresSink(t *testing.T) {
+	opts := &Options{}
+	opts.Sinks.Sinks = []string{"prometheus://:9187", "postgres://localhost/metrics", "postgresql://other/metrics"}
+	s, err := opts.PostgresSink()
+	assert.NoError(t, err)
+	assert.Equal(t, "postgres://localhost/metrics", s)
+}
+
+func TestBaselineCommands_Execute(t *testing.T) {
+	os.Args = []string{0: "config_test", "--sink=jsonfile://test.json", "baseline", "capture", "--source=db1", "--label=before"}
+	_, err := New(nil)
+	assert.ErrorContains(t, err, "needs a Postgres sink")
+
+	os.Args = []string{0: "config_test", "--sink=postgresql://localhost:1/pgwatch_metrics?connect_timeout=1",
+		"baseline", "compare", "--source=db1", "--label=before", "--end=yesterday"}
+	_, err = New(nil)
+	assert.ErrorContains(t, err, "invalid --end")
+
+	os.Args = []string{0: "config_test", "--sink=postgresql://localhost:1/pgwatch_metrics?connect_timeout=1",
+		"baseline", "compare", "--source=db1", "--label=before", "--window=30m", "--end=2024-01-31T12:00:00Z"}
+	opts, err := New(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, ExitCodeCmdError, opts.ExitCode, "unreachable sink should be reported")
+}
+
+func TestBaselineWindow_Bounds(t *testing.T) {
+	from, to, err := BaselineWindow{Window: time.Hour, End: "2024-01-31T12:00:00Z"}.Bounds()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 31, 11, 0, 0, 0, time.UTC), from.UTC())
+	assert.Equal(t, time.Hour, to.Sub(from))
+}
@@ -1,23 +1,30 @@
 package cmdopts
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
 )
 
 type SourceCommand struct {
-	owner *Options
-	Ping  SourcePingCommand `command:"ping" description:"Try to connect to configured sources, report errors if any and then exit"`
+	owner  *Options
+	Ping   SourcePingCommand   `command:"ping" description:"Try to connect to configured sources, report errors if any and then exit"`
+	Forget SourceForgetCommand `command:"forget" description:"Make the running instance remove the stored measurements of decommissioned sources"`
 	// PrintSQL  SourcePrintCommand `command:"print" description:"Get and print SQL for a given Source"`
 }
 
 func NewSourceCommand(owner *Options) *SourceCommand {
 	return &SourceCommand{
-		owner: owner,
-		Ping:  SourcePingCommand{owner: owner},
+		owner:  owner,
+		Ping:   SourcePingCommand{owner: owner},
+		Forget: SourceForgetCommand{owner: owner},
 	}
 }
 
@@ -70,3 +77,56 @@ func (cmd *SourcePingCommand) Execute(args []string) error {
 	cmd.owner.CompleteCommand(map[bool]int32{true: ExitCodeCmdError, false: ExitCodeOK}[err != nil])
 	return nil
 }
+
+type SourceForgetCommand struct {
+	owner   *Options
+	Embargo time.Duration `long:"embargo" description:"Keep the data for this period before removing it, e.g. 720h" default:"0"`
+}
+
+// Execute asks the running pgwatch instance listening on --web-addr to remove the stored
+// measurements of the given sources, immediately or after the embargo period
+func (cmd *SourceForgetCommand) Execute(args []string) error {
+	if len(args) == 0 {
+		return errors.New("at least one source name is required")
+	}
+	client := &http.Client{Timeout: time.Minute}
+	var err error
+	for _, name := range args {
+		e := cmd.owner.RequestForget(client, name, cmd.Embargo)
+		if e != nil {
+			fmt.Printf("FAIL:\t%s (%s)\n", name, e)
+		} else if cmd.Embargo > 0 {
+			fmt.Printf("OK:\t%s (scheduled in %s)\n", name, cmd.Embargo)
+		} else {
+			fmt.Printf("OK:\t%s\n", name)
+		}
+		err = errors.Join(err, e)
+	}
+	// err here specifies execution error, not configuration error
+	cmd.owner.CompleteCommand(map[bool]int32{true: ExitCodeCmdError, false: ExitCodeOK}[err != nil])
+	return nil
+}
+
+// RequestForget logs in to the REST API of the running instance and requests the removal of the source data
+func (c *Options) RequestForget(client *http.Client, name string, embargo time.Duration) error {
+	baseURL, token, err := c.login(client)
+	if err != nil {
+		return err
+	}
+	params := url.Values{"name": {name}, "embargo": {embargo.String()}}
+	req, err := http.NewRequest(http.MethodPost, baseURL+"/source/forget?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Token", token)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("forget failed: %s", bytes.TrimSpace(body))
+	}
+	return nil
+}
@@ -1,7 +1,10 @@
 package cmdopts
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -29,3 +32,37 @@ func TestSourcePingCommand_Execute(t *testing.T) {
 	_, err = New(nil)
 	assert.NoError(t, err)
 }
+
+func TestSourceForgetCommand_Execute(t *testing.T) {
+	var forgotten []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("token"))
+	})
+	mux.HandleFunc("/source/forget", func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("name")
+		if name == "monitored" {
+			http.Error(w, "source is still monitored", http.StatusInternalServerError)
+			return
+		}
+		forgotten = append(forgotten, name+"@"+r.URL.Query().Get("embargo"))
+		if r.URL.Query().Get("embargo") != "0s" {
+			w.WriteHeader(http.StatusAccepted)
+		}
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	webAddr := "--web-addr=" + strings.TrimPrefix(ts.URL, "http://")
+
+	os.Args = []string{0: "config_test", webAddr, "source", "forget", "old1", "old2"}
+	opts, err := New(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, ExitCodeOK, opts.ExitCode)
+	assert.Equal(t, []string{"old1@0s", "old2@0s"}, forgotten)
+
+	os.Args = []string{0: "config_test", webAddr, "source", "forget", "--embargo=720h", "old3", "monitored"}
+	opts, err = New(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, ExitCodeCmdError, opts.ExitCode, "rejected sources should be reported")
+	assert.Equal(t, []string{"old1@0s", "old2@0s", "old3@720h0m0s"}, forgotten)
+}
@@ -0,0 +1,81 @@
+package cmdopts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/upgrade"
+)
+
+type UpgradeCheckCommand struct {
+	owner         *Options
+	TargetVersion int `long:"target-version" description:"Postgres major version to upgrade to, e.g. 16" required:"true"`
+}
+
+func NewUpgradeCheckCommand(owner *Options) *UpgradeCheckCommand {
+	return &UpgradeCheckCommand{owner: owner}
+}
+
+// Execute checks the given or all configured sources for the blockers of a major version upgrade and prints
+// the findings. Blockers and connection errors are indicated by the exit code
+func (cmd *UpgradeCheckCommand) Execute(args []string) error {
+	if cmd.TargetVersion < 10 {
+		return errors.New("--target-version must be 10 or later")
+	}
+	ctx := context.Background()
+	err := cmd.owner.InitSourceReader(ctx)
+	if err != nil {
+		return err
+	}
+	srcs, err := cmd.owner.SourcesReaderWriter.GetSources()
+	if err != nil {
+		return err
+	}
+	srcs = slices.DeleteFunc(srcs, func(s sources.Source) bool {
+		return len(args) > 0 && !slices.Contains(args, s.Name)
+	})
+	mdbs, err := srcs.ResolveDatabases()
+	if err != nil {
+		return err
+	}
+	var blockers int
+	for _, md := range mdbs {
+		if !md.IsPostgresSource() {
+			continue
+		}
+		findings, e := cmd.check(ctx, md)
+		if e != nil {
+			fmt.Printf("FAIL:\t%s (%s)\n", md.Name, e)
+			err = errors.Join(err, e)
+			continue
+		}
+		if len(findings) == 0 {
+			fmt.Printf("OK:\t%s\n", md.Name)
+		}
+		for _, f := range findings {
+			fmt.Printf("%s:\t%s\t%s\n", strings.ToUpper(f.Severity), md.Name, f)
+			if f.Severity == upgrade.SeverityBlocker {
+				blockers++
+			}
+		}
+	}
+	if blockers > 0 {
+		fmt.Printf("%d upgrade blockers found\n", blockers)
+		err = errors.Join(err, errors.New("upgrade blockers found"))
+	}
+	// err here specifies execution error or blockers found, not configuration error
+	cmd.owner.CompleteCommand(map[bool]int32{true: ExitCodeCmdError, false: ExitCodeOK}[err != nil])
+	return nil
+}
+
+func (cmd *UpgradeCheckCommand) check(ctx context.Context, md *sources.MonitoredDatabase) ([]upgrade.Finding, error) {
+	if err := md.Connect(ctx, cmd.owner.Sources); err != nil {
+		return nil, err
+	}
+	defer md.Conn.Close()
+	return upgrade.Run(ctx, md.Conn, cmd.TargetVersion)
+}
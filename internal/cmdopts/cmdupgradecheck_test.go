@@ -0,0 +1,39 @@
+package cmdopts
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpgradeCheckCommand_Execute(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "sample.config.yaml")
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.WriteString(`
+- name: test1
+  kind: postgres
+  conn_str: postgresql://foo@localhost:1/baz?connect_timeout=1
+  is_enabled: true
+- name: pool
+  kind: pgbouncer
+  conn_str: postgresql://foo@localhost:1/pgbouncer
+  is_enabled: true`)
+	require.NoError(t, err)
+
+	os.Args = []string{0: "config_test", "--sources=" + f.Name(), "upgrade-check", "--target-version=9"}
+	_, err = New(nil)
+	assert.ErrorContains(t, err, "must be 10 or later")
+
+	os.Args = []string{0: "config_test", "--sources=" + f.Name(), "upgrade-check", "--target-version=17"}
+	opts, err := New(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, ExitCodeCmdError, opts.ExitCode, "unreachable source should be reported")
+
+	os.Args = []string{0: "config_test", "--sources=" + f.Name(), "upgrade-check", "--target-version=17", "pool"}
+	opts, err = New(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, ExitCodeOK, opts.ExitCode, "poolers are not checked")
+}
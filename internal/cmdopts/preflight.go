@@ -0,0 +1,157 @@
+package cmdopts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/handover"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sinks"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+)
+
+// PreflightProblem is a problem found by the startup checks, see Options.Preflight
+type PreflightProblem struct {
+	Check    string // checked component, e.g. "sink"
+	Subject  string // checked item, e.g. the sink URI
+	Err      error
+	Warning  bool  // the collector starts nevertheless
+	ExitCode int32 // of the collector failing on the problem
+}
+
+// PreflightReport lists all the problems found by the startup checks
+type PreflightReport []PreflightProblem
+
+func (r *PreflightReport) fail(check, subject string, code int32, err error) {
+	*r = append(*r, PreflightProblem{Check: check, Subject: subject, Err: err, ExitCode: code})
+}
+
+func (r *PreflightReport) warn(check, subject string, err error) {
+	*r = append(*r, PreflightProblem{Check: check, Subject: subject, Err: err, Warning: true})
+}
+
+// ExitCode returns the exit code of the first problem preventing the start, ExitCodeOK if there is none
+func (r PreflightReport) ExitCode() int32 {
+	for _, p := range r {
+		if !p.Warning {
+			return p.ExitCode
+		}
+	}
+	return ExitCodeOK
+}
+
+// Log writes all the problems, the ones preventing the start as errors followed by their count
+func (r PreflightReport) Log(l log.LoggerIface) {
+	failed := 0
+	for _, p := range r {
+		entry := l.WithField("check", p.Check).WithError(p.Err)
+		if p.Subject > "" {
+			entry = entry.WithField("subject", p.Subject)
+		}
+		if p.Warning {
+			entry.Warning("preflight check failed, starting nevertheless")
+			continue
+		}
+		entry.Error("preflight check failed")
+		failed++
+	}
+	if failed > 0 {
+		l.Errorf("%d preflight problem(s) prevent the start, fix all of the above", failed)
+	}
+}
+
+// Preflight initializes the configuration readers and checks everything needed to start, reporting all the
+// problems at once: the sources and metrics configuration, the decryption key of the sources, the sink
+// connectivity and measurements schema, and the addresses to listen on. Nothing is created or changed
+func (c *Options) Preflight(ctx context.Context) (r PreflightReport) {
+	if err := c.InitConfigReaders(ctx); err != nil {
+		r.fail("config", "", ExitCodeCmdError, err)
+	} else if upgrade, err := c.NeedsSchemaUpgrade(); upgrade || err != nil {
+		if upgrade {
+			err = errors.Join(err, errors.New(`configuration needs upgrade, use "init --upgrade" command`))
+		}
+		r.fail("config", "", ExitCodeUpgradeError, err)
+	} else {
+		c.checkConfig(ctx, &r)
+	}
+	if c.RunsGatherer() {
+		if len(c.Sinks.Sinks) == 0 {
+			r.fail("sink", "", ExitCodeConfigError, errors.New("no sinks specified for measurements, use --sink"))
+		}
+		for _, s := range c.Sinks.Sinks {
+			if err := sinks.CheckSink(ctx, s, &c.Sinks); errors.Is(err, sinks.ErrSinkUnreachable) {
+				r.warn("sink", log.Redact(s), err)
+			} else if err != nil {
+				r.fail("sink", log.Redact(s), ExitCodeConfigError, err)
+			}
+		}
+	}
+	for _, addr := range c.ListenAddresses() {
+		code := ExitCodeConfigError
+		if addr == c.WebUI.WebAddr {
+			code = ExitCodeWebUIError
+		}
+		if err := handover.Available(addr); err != nil {
+			r.fail("listener", addr, code, err)
+		}
+	}
+	return
+}
+
+// checkConfig reads the sources and metrics and checks the presets and metrics used by the enabled sources are defined
+func (c *Options) checkConfig(ctx context.Context, r *PreflightReport) {
+	if c.Sources.SourcesKeyFile > "" || c.Sources.SourcesKeyCommand > "" {
+		if _, err := c.Sources.DecryptionKey(ctx); err != nil {
+			r.fail("sources key", "", ExitCodeConfigError, err)
+			return // every encrypted file would fail the same way
+		}
+	}
+	defs, err := c.MetricsReaderWriter.GetMetrics()
+	if err != nil {
+		r.fail("metrics", log.Redact(c.Metrics.Metrics), ExitCodeConfigError, err)
+	}
+	srcs, err := c.SourcesReaderWriter.GetSources()
+	if err != nil {
+		r.fail("sources", log.Redact(c.Sources.Sources), ExitCodeConfigError, err)
+	}
+	if defs == nil {
+		return
+	}
+	used := make(map[string]bool) // presets of the enabled sources
+	for _, src := range srcs {
+		if src.IsEnabled {
+			checkSourceMetrics(src, defs, r)
+			used[src.PresetMetrics], used[src.PresetMetricsStandby] = true, true
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(defs.PresetDefs)) {
+		if !used[name] {
+			continue
+		}
+		for _, m := range slices.Sorted(maps.Keys(defs.PresetDefs[name].Metrics)) {
+			if _, ok := defs.MetricDefs[m]; !ok {
+				r.warn("metrics", "preset "+name, fmt.Errorf("unknown metric %s", m))
+			}
+		}
+	}
+}
+
+// checkSourceMetrics checks the presets and custom metrics of the source are defined
+func checkSourceMetrics(src sources.Source, defs *metrics.Metrics, r *PreflightReport) {
+	for _, preset := range []string{src.PresetMetrics, src.PresetMetricsStandby} {
+		if _, ok := defs.PresetDefs[preset]; preset > "" && !ok {
+			r.warn("sources", "source "+src.Name, fmt.Errorf("unknown preset %s", preset))
+		}
+	}
+	for _, custom := range []map[string]float64{src.Metrics, src.MetricsStandby} {
+		for _, m := range slices.Sorted(maps.Keys(custom)) {
+			if _, ok := defs.MetricDefs[m]; !ok {
+				r.warn("sources", "source "+src.Name, fmt.Errorf("unknown metric %s", m))
+			}
+		}
+	}
+}
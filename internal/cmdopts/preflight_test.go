@@ -0,0 +1,94 @@
+package cmdopts
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sinks"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/webserver"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreflight(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	sourcesFile := filepath.Join(dir, "sources.yaml")
+	require.NoError(t, os.WriteFile(sourcesFile, []byte(`
+- name: good
+  conn_str: postgresql://localhost/good
+  preset_metrics: basic
+  is_enabled: true
+- name: typo
+  conn_str: postgresql://localhost/typo
+  preset_metrics: basics
+  preset_metrics_standby: aiven
+  custom_metrics: {db_stat: 60}
+  is_enabled: true
+- name: disabled
+  conn_str: postgresql://localhost/disabled
+  preset_metrics: nonexistent
+`), 0644))
+	newOpts := func() *Options {
+		return &Options{
+			Mode:    ModeAll,
+			Sources: sources.CmdOpts{Sources: sourcesFile},
+			Sinks:   sinks.CmdOpts{Sinks: []string{"jsonfile://" + filepath.Join(dir, "out.json")}},
+			WebUI:   webserver.CmdOpts{WebDisable: webserver.WebDisableAll},
+		}
+	}
+
+	opts := newOpts()
+	report := opts.Preflight(ctx)
+	assert.NotNil(t, opts.SourcesReaderWriter, "the readers are ready for the start")
+	assert.Equal(t, ExitCodeOK, report.ExitCode(), "the warnings don't prevent the start")
+	require.Len(t, report, 3)
+	assert.Equal(t, "source typo", report[0].Subject)
+	assert.EqualError(t, report[0].Err, "unknown preset basics")
+	assert.EqualError(t, report[1].Err, "unknown metric db_stat")
+	assert.Equal(t, "preset aiven", report[2].Subject, "the presets used only")
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	opts = newOpts()
+	opts.Sources.SourcesKeyFile = filepath.Join(dir, "missing.key")
+	opts.Sinks.Sinks = append(opts.Sinks.Sinks, "graphite://localhost:2003", "prometheus://localhost/pgwatch")
+	opts.WebUI = webserver.CmdOpts{WebAddr: ln.Addr().String()}
+	report = opts.Preflight(ctx)
+	var checks []string
+	for _, p := range report {
+		assert.False(t, p.Warning)
+		checks = append(checks, p.Check)
+	}
+	assert.Equal(t, []string{"sources key", "sink", "listener", "listener"}, checks, "all the problems reported at once")
+	assert.Equal(t, ExitCodeConfigError, report.ExitCode())
+	assert.Equal(t, ExitCodeWebUIError, report[2].ExitCode)
+	assert.Equal(t, ExitCodeConfigError, report[3].ExitCode)
+
+	var buf bytes.Buffer
+	l := logrus.New()
+	l.SetOutput(&buf)
+	report.Log(l)
+	assert.Contains(t, buf.String(), "4 preflight problem(s) prevent the start")
+	assert.Contains(t, buf.String(), "subject=\"graphite://localhost:2003\"")
+
+	opts = newOpts()
+	opts.Sources.Sources = filepath.Join(dir, "missing.yaml")
+	opts.Sinks.Sinks = nil
+	report = opts.Preflight(ctx)
+	require.Len(t, report, 2)
+	assert.Equal(t, ExitCodeCmdError, report.ExitCode())
+	assert.EqualError(t, report[1].Err, "no sinks specified for measurements, use --sink")
+
+	opts = newOpts()
+	opts.Mode = ModeWebUI
+	opts.Sinks.Sinks = nil
+	assert.Len(t, opts.Preflight(ctx), 3, "no sinks needed")
+}
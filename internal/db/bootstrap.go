@@ -7,16 +7,20 @@ import (
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgconn/ctxwatch"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/tracelog"
 	retry "github.com/sethvargo/go-retry"
 )
 
 const (
-	pgConnRecycleSeconds = 1800      // applies for monitored nodes
-	applicationName      = "pgwatch" // will be set on all opened PG connections for informative purposes
+	pgConnRecycleSeconds = 1800            // applies for monitored nodes
+	cancelDeadlineDelay  = 5 * time.Second // how long to wait for the server to cancel the query before closing the connection
 )
 
+// ApplicationName will be set on all opened PG connections for informative purposes
+const ApplicationName = "pgwatch"
+
 func Ping(ctx context.Context, connStr string) error {
 	c, err := pgx.Connect(ctx, connStr)
 	if c != nil {
@@ -44,7 +48,7 @@ func NewWithConfig(ctx context.Context, connConfig *pgxpool.Config, callbacks ..
 	}
 	connConfig.MaxConnIdleTime = 15 * time.Second
 	connConfig.MaxConnLifetime = pgConnRecycleSeconds * time.Second
-	connConfig.ConnConfig.RuntimeParams["application_name"] = applicationName
+	connConfig.ConnConfig.RuntimeParams["application_name"] = ApplicationName
 	connConfig.ConnConfig.OnNotice = func(_ *pgconn.PgConn, n *pgconn.Notice) {
 		logger.WithField("severity", n.Severity).WithField("notice", n.Message).Info("Notice received")
 	}
@@ -53,6 +57,11 @@ func NewWithConfig(ctx context.Context, connConfig *pgxpool.Config, callbacks ..
 		LogLevel: tracelog.LogLevelDebug,
 	}
 	connConfig.ConnConfig.Tracer = tracelogger
+	// on context cancellation ask the server to cancel the query, and if it doesn't react, e.g. network
+	// is hung, close the underlying connection so the caller is never blocked indefinitely
+	connConfig.ConnConfig.BuildContextWatcherHandler = func(pgConn *pgconn.PgConn) ctxwatch.Handler {
+		return &pgconn.CancelRequestContextWatcherHandler{Conn: pgConn, DeadlineDelay: cancelDeadlineDelay}
+	}
 	for _, f := range callbacks {
 		if err := f(connConfig); err != nil {
 			return nil, err
@@ -0,0 +1,166 @@
+// Package drift compares the server configuration of the monitored hosts, flagging the settings that deviate
+// from the consensus of their group or from a golden configuration
+package drift
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Baselines the settings are compared to
+const (
+	BaselineConsensus = "consensus" // the value of the strict majority of the hosts in the group
+	BaselineGolden    = "golden"    // the value of the golden configuration file
+)
+
+// AnyGroup is the golden configuration group applying to all groups
+const AnyGroup = "*"
+
+// ignoredSettings identify the host or its replication role, so they are expected to differ
+var ignoredSettings = map[string]bool{
+	"cluster_name":                  true,
+	"config_file":                   true,
+	"data_directory":                true,
+	"default_transaction_read_only": true,
+	"external_pid_file":             true,
+	"hba_file":                      true,
+	"ident_file":                    true,
+	"listen_addresses":              true,
+	"port":                          true,
+	"primary_conninfo":              true,
+	"primary_slot_name":             true,
+	"ssl_cert_file":                 true,
+	"ssl_key_file":                  true,
+	"transaction_read_only":         true,
+}
+
+// Settings are the server settings of a host, [name]=value incl. the unit
+type Settings map[string]string
+
+// Host is the configuration of a monitored host
+type Host struct {
+	Source   string
+	Group    string
+	Settings Settings
+}
+
+// Deviation is a setting of a host differing from the baseline
+type Deviation struct {
+	Setting  string `json:"setting"`
+	Value    string `json:"value"`
+	Expected string `json:"expected"`
+	Baseline string `json:"baseline"`
+}
+
+// String returns the deviation as "setting=value (expected: value, baseline)"
+func (d Deviation) String() string {
+	return fmt.Sprintf("%s=%s (expected: %s, %s)", d.Setting, d.Value, d.Expected, d.Baseline)
+}
+
+// Report is the configuration drift of a host
+type Report struct {
+	Source     string      `json:"source"`
+	Group      string      `json:"group"`
+	GroupSize  int         `json:"group_size"`
+	Deviations []Deviation `json:"deviations"`
+}
+
+// Golden is the expected configuration per group, [group]=settings. The AnyGroup settings apply to all groups
+// and are overridden by the group ones
+type Golden map[string]Settings
+
+// LoadGolden reads the golden configuration YAML file, nil is returned if no file is given
+func LoadGolden(path string) (Golden, error) {
+	if path == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var golden Golden
+	if err = yaml.Unmarshal(b, &golden); err != nil {
+		return nil, fmt.Errorf("invalid golden configuration %s: %w", path, err)
+	}
+	return golden, nil
+}
+
+// expected returns the golden settings of the group
+func (g Golden) expected(group string) Settings {
+	s := maps.Clone(g[AnyGroup])
+	if s == nil {
+		s = Settings{}
+	}
+	maps.Copy(s, g[group])
+	return s
+}
+
+// consensus returns the values of the settings shared by the strict majority of the hosts
+func consensus(hosts []Host) Settings {
+	counts := make(map[string]map[string]int) // [setting][value]=hosts
+	for _, h := range hosts {
+		for name, value := range h.Settings {
+			if counts[name] == nil {
+				counts[name] = make(map[string]int)
+			}
+			counts[name][value]++
+		}
+	}
+	s := Settings{}
+	for name, values := range counts {
+		for value, n := range values {
+			if 2*n > len(hosts) {
+				s[name] = value
+			}
+		}
+	}
+	return s
+}
+
+// Analyze compares the settings of every host with the golden configuration of its group and, for the settings
+// not in there, with the consensus of the group. Reports are returned for all hosts, sorted by group and source
+func Analyze(hosts []Host, golden Golden) []Report {
+	groups := make(map[string][]Host)
+	for _, h := range hosts {
+		groups[h.Group] = append(groups[h.Group], h)
+	}
+	reports := make([]Report, 0, len(hosts))
+	for _, group := range slices.Sorted(maps.Keys(groups)) {
+		members := groups[group]
+		expected := golden.expected(group)
+		var majority Settings
+		if len(members) > 1 {
+			majority = consensus(members)
+		}
+		for _, h := range members {
+			r := Report{Source: h.Source, Group: group, GroupSize: len(members), Deviations: []Deviation{}}
+			for _, name := range slices.Sorted(maps.Keys(h.Settings)) {
+				value := h.Settings[name]
+				if want, ok := expected[name]; ok {
+					if value != want {
+						r.Deviations = append(r.Deviations, Deviation{name, value, want, BaselineGolden})
+					}
+					continue
+				}
+				if want, ok := majority[name]; ok && value != want && !ignoredSettings[name] {
+					r.Deviations = append(r.Deviations, Deviation{name, value, want, BaselineConsensus})
+				}
+			}
+			for _, name := range slices.Sorted(maps.Keys(expected)) {
+				if _, ok := h.Settings[name]; !ok { // e.g. unknown to the server version
+					r.Deviations = append(r.Deviations, Deviation{name, "", expected[name], BaselineGolden})
+				}
+			}
+			reports = append(reports, r)
+		}
+	}
+	slices.SortStableFunc(reports, func(a, b Report) int {
+		return cmp.Or(cmp.Compare(a.Group, b.Group), cmp.Compare(a.Source, b.Source))
+	})
+	return reports
+}
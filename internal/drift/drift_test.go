@@ -0,0 +1,57 @@
+package drift
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyze(t *testing.T) {
+	hosts := []Host{
+		{Source: "b", Group: "prod", Settings: Settings{"work_mem": "4MB", "port": "5433", "shared_buffers": "1GB"}},
+		{Source: "a", Group: "prod", Settings: Settings{"work_mem": "4MB", "port": "5432", "shared_buffers": "2GB"}},
+		{Source: "c", Group: "prod", Settings: Settings{"work_mem": "64MB", "port": "5432", "shared_buffers": "2GB"}},
+		{Source: "d", Group: "test", Settings: Settings{"work_mem": "1MB", "shared_buffers": "1GB"}},
+		{Source: "e", Group: "test", Settings: Settings{"work_mem": "2MB", "shared_buffers": "1GB"}},
+	}
+	reports := Analyze(hosts, nil)
+	require.Len(t, reports, 5)
+	assert.Equal(t, "a", reports[0].Source, "sorted by group and source")
+	assert.Empty(t, reports[0].Deviations)
+	assert.Equal(t, []Deviation{{"shared_buffers", "1GB", "2GB", BaselineConsensus}}, reports[1].Deviations, "port is ignored")
+	assert.Equal(t, []Deviation{{"work_mem", "64MB", "4MB", BaselineConsensus}}, reports[2].Deviations)
+	assert.Equal(t, 3, reports[2].GroupSize)
+	assert.Empty(t, reports[3].Deviations, "no strict majority")
+
+	golden := Golden{AnyGroup: {"shared_buffers": "1GB"}, "prod": {"shared_buffers": "2GB", "jit": "off"}}
+	reports = Analyze(hosts, golden)
+	assert.Equal(t, []Deviation{{"jit", "", "off", BaselineGolden}}, reports[0].Deviations, "missing golden setting")
+	assert.Equal(t, []Deviation{{"shared_buffers", "1GB", "2GB", BaselineGolden}, {"jit", "", "off", BaselineGolden}}, reports[1].Deviations)
+	assert.Empty(t, reports[4].Deviations, "the group falls back to the any group")
+	assert.Equal(t, "shared_buffers=1GB (expected: 2GB, golden)", reports[1].Deviations[0].String())
+
+	reports = Analyze([]Host{{Source: "single", Group: "default", Settings: Settings{"work_mem": "1MB"}}}, nil)
+	assert.Empty(t, reports[0].Deviations, "a single host has no consensus")
+}
+
+func TestLoadGolden(t *testing.T) {
+	golden, err := LoadGolden("")
+	assert.NoError(t, err)
+	assert.Nil(t, golden)
+
+	path := filepath.Join(t.TempDir(), "golden.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("'*':\n  jit: 'off'\nprod:\n  shared_buffers: 2GB\n"), 0600))
+	golden, err = LoadGolden(path)
+	assert.NoError(t, err)
+	assert.Equal(t, Settings{"jit": "off", "shared_buffers": "2GB"}, golden.expected("prod"))
+
+	require.NoError(t, os.WriteFile(path, []byte("- not a map"), 0600))
+	_, err = LoadGolden(path)
+	assert.ErrorContains(t, err, "invalid golden configuration")
+
+	_, err = LoadGolden(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,17 @@
+package faults
+
+import "time"
+
+// CmdOpts specifies the failures to inject for resilience testing, the flags are hidden from the help
+type CmdOpts struct {
+	SinkWriteErrors   float64       `long:"inject-sink-write-errors" mapstructure:"inject-sink-write-errors" description:"Fail the given fraction, 0..1, of the writes to every sink" env:"PW_INJECT_SINK_WRITE_ERRORS"`
+	FetchTimeouts     []string      `long:"inject-fetch-timeouts" mapstructure:"inject-fetch-timeouts" description:"Fail every metric fetch of the source name or host with a statement timeout, can be used multiple times" env:"PW_INJECT_FETCH_TIMEOUTS"`
+	ConfigDBDown      time.Duration `long:"inject-config-db-down" mapstructure:"inject-config-db-down" description:"Fail the reads of the sources and metric definitions for the given duration" env:"PW_INJECT_CONFIG_DB_DOWN"`
+	ConfigDBDownAfter time.Duration `long:"inject-config-db-down-after" mapstructure:"inject-config-db-down-after" description:"Delay of the injected configuration unavailability after the start" env:"PW_INJECT_CONFIG_DB_DOWN_AFTER"`
+	Seed              int64         `long:"inject-seed" mapstructure:"inject-seed" description:"Seed of the random sink write errors, the same seed fails the same writes" env:"PW_INJECT_SEED" default:"1"`
+}
+
+// Enabled returns true if any failure is to be injected
+func (o CmdOpts) Enabled() bool {
+	return o.SinkWriteErrors > 0 || len(o.FetchTimeouts) > 0 || o.ConfigDBDown > 0
+}
@@ -0,0 +1,94 @@
+// Package faults injects simulated failures into the measurement pipeline, so that the retry, backoff and drop
+// behavior can be verified deterministically. Nothing is injected unless Init is called with some failures enabled
+package faults
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrInjected is wrapped by all the injected errors except the fetch timeouts, which mimic the Postgres error
+var ErrInjected = errors.New("injected failure")
+
+// statementTimeoutCode is the SQLSTATE of a query canceled by the statement_timeout
+const statementTimeoutCode = "57014"
+
+// Injector decides which operations fail
+type Injector struct {
+	opts  CmdOpts
+	start time.Time
+	mu    sync.Mutex
+	rnd   *rand.Rand
+}
+
+var active atomic.Pointer[Injector]
+
+// New returns an injector of the given failures, the configuration unavailability is counted from now
+func New(opts CmdOpts) *Injector {
+	return &Injector{opts: opts, start: time.Now(), rnd: rand.New(rand.NewSource(opts.Seed))}
+}
+
+// Init activates the injection of the failures process wide
+func Init(opts CmdOpts) {
+	if opts.Enabled() {
+		active.Store(New(opts))
+	} else {
+		active.Store(nil)
+	}
+}
+
+// SinkWriteError returns an error for the given fraction of the writes to a sink
+func (i *Injector) SinkWriteError(sink string) error {
+	if i == nil || i.opts.SinkWriteErrors <= 0 {
+		return nil
+	}
+	i.mu.Lock()
+	fail := i.rnd.Float64() < i.opts.SinkWriteErrors
+	i.mu.Unlock()
+	if fail {
+		return fmt.Errorf("write to sink %s: %w", sink, ErrInjected)
+	}
+	return nil
+}
+
+// FetchTimeout returns a statement timeout error for the fetches of the listed sources or hosts
+func (i *Injector) FetchTimeout(source, host string) error {
+	if i == nil || !slices.Contains(i.opts.FetchTimeouts, source) && (host == "" || !slices.Contains(i.opts.FetchTimeouts, host)) {
+		return nil
+	}
+	return &pgconn.PgError{Severity: "ERROR", Code: statementTimeoutCode, Message: "canceling statement due to statement timeout (injected)"}
+}
+
+// ConfigError returns an error while the configuration is unavailable
+func (i *Injector) ConfigError() error {
+	if i == nil || i.opts.ConfigDBDown <= 0 {
+		return nil
+	}
+	since := time.Since(i.start)
+	if since >= i.opts.ConfigDBDownAfter && since < i.opts.ConfigDBDownAfter+i.opts.ConfigDBDown {
+		return fmt.Errorf("configuration unavailable: %w", ErrInjected)
+	}
+	return nil
+}
+
+// SinkWriteError returns an error for the configured fraction of the writes to a sink
+func SinkWriteError(sink string) error {
+	return active.Load().SinkWriteError(sink)
+}
+
+// FetchTimeout returns a statement timeout error for the fetches of the configured sources or hosts
+func FetchTimeout(source, host string) error {
+	return active.Load().FetchTimeout(source, host)
+}
+
+// ConfigError returns an error while the configuration is configured to be unavailable
+func ConfigError() error {
+	return active.Load().ConfigError()
+}
@@ -0,0 +1,68 @@
+package faults
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSinkWriteError(t *testing.T) {
+	failures := func(seed int64) (failed []bool) {
+		i := New(CmdOpts{SinkWriteErrors: 0.5, Seed: seed})
+		for range 100 {
+			failed = append(failed, i.SinkWriteError("postgres") != nil)
+		}
+		return
+	}
+	first := failures(42)
+	assert.Equal(t, first, failures(42), "the same seed fails the same writes")
+	var n int
+	for _, f := range first {
+		if f {
+			n++
+		}
+	}
+	assert.InDelta(t, 50, n, 20)
+
+	assert.ErrorIs(t, New(CmdOpts{SinkWriteErrors: 1}).SinkWriteError("postgres"), ErrInjected)
+	assert.NoError(t, New(CmdOpts{}).SinkWriteError("postgres"))
+}
+
+func TestFetchTimeout(t *testing.T) {
+	i := New(CmdOpts{FetchTimeouts: []string{"db1", "10.0.0.5"}})
+	var pgErr *pgconn.PgError
+	if assert.True(t, errors.As(i.FetchTimeout("db1", "localhost"), &pgErr)) {
+		assert.Equal(t, "57014", pgErr.Code)
+	}
+	assert.Error(t, i.FetchTimeout("db2", "10.0.0.5"), "matched by host")
+	assert.NoError(t, i.FetchTimeout("db2", "localhost"))
+	assert.NoError(t, i.FetchTimeout("db2", ""))
+}
+
+func TestConfigError(t *testing.T) {
+	i := New(CmdOpts{ConfigDBDown: time.Hour})
+	assert.ErrorIs(t, i.ConfigError(), ErrInjected)
+	i = New(CmdOpts{ConfigDBDown: time.Hour, ConfigDBDownAfter: time.Hour})
+	assert.NoError(t, i.ConfigError(), "not down yet")
+	i.start = time.Now().Add(-90 * time.Minute)
+	assert.Error(t, i.ConfigError())
+	i.start = time.Now().Add(-3 * time.Hour)
+	assert.NoError(t, i.ConfigError(), "up again")
+}
+
+func TestInit(t *testing.T) {
+	defer Init(CmdOpts{})
+	Init(CmdOpts{})
+	assert.Nil(t, active.Load(), "nothing injected by default")
+	assert.NoError(t, SinkWriteError("postgres"))
+	assert.NoError(t, FetchTimeout("db1", ""))
+	assert.NoError(t, ConfigError())
+
+	Init(CmdOpts{FetchTimeouts: []string{"db1"}, ConfigDBDown: time.Minute})
+	assert.Error(t, FetchTimeout("db1", ""))
+	assert.Error(t, ConfigError())
+	assert.NoError(t, SinkWriteError("postgres"))
+}
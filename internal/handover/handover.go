@@ -0,0 +1,169 @@
+// Package handover passes the listening sockets of a running collector to a new one, e.g. of a newer version,
+// so that the Prometheus scrapes, REST API calls and agent pushes are not refused while the collectors swap.
+// The sockets are either inherited following the systemd socket activation protocol (LISTEN_FDS), as done by
+// Upgrade, or bound alongside the running collector with SO_REUSEPORT.
+package handover
+
+import (
+	"context"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+)
+
+const (
+	listenFdsStart = 3                 // the first inherited file descriptor, SD_LISTEN_FDS_START
+	parentPIDEnv   = "PW_HANDOVER_PID" // set by Upgrade to the PID of the collector to take over from
+)
+
+// registry keeps the listeners of the process to pass them to the next one
+type registry struct {
+	sync.Mutex
+	prepared map[string]net.Listener // inherited or bound by Prepare, by configured address
+	active   []*net.TCPListener
+}
+
+var listeners = &registry{prepared: make(map[string]net.Listener)}
+
+// Prepare takes the listeners of the addresses over from the parent process or the service manager, or binds
+// them with SO_REUSEPORT if reusePort is set. It is called before waiting for the run lock, so that the connections
+// are queued rather than refused while the running collector drains. Inherited listeners not configured are closed
+func Prepare(ctx context.Context, addrs []string, reusePort bool) error {
+	logger := log.GetLogger(ctx)
+	inherited := inheritedListeners()
+	listeners.Lock()
+	defer listeners.Unlock()
+	for _, addr := range addrs {
+		if i := slices.IndexFunc(inherited, func(ln net.Listener) bool { return sameAddress(ln.Addr(), addr) }); i >= 0 {
+			listeners.prepared[addr] = inherited[i]
+			inherited = slices.Delete(inherited, i, i+1)
+			logger.WithField("address", addr).Info("listener taken over")
+			continue
+		}
+		if !reusePort {
+			continue
+		}
+		ln, err := (&net.ListenConfig{Control: reusePortControl}).Listen(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		listeners.prepared[addr] = ln
+	}
+	for _, ln := range inherited {
+		logger.WithField("address", ln.Addr().String()).Warning("closing inherited listener not configured")
+		_ = ln.Close()
+	}
+	return nil
+}
+
+// Listen returns the listener prepared for the address, a new one is bound otherwise
+func Listen(addr string) (net.Listener, error) {
+	listeners.Lock()
+	defer listeners.Unlock()
+	ln, ok := listeners.prepared[addr]
+	if ok {
+		delete(listeners.prepared, addr)
+	} else {
+		var err error
+		if ln, err = net.Listen("tcp", addr); err != nil {
+			return nil, err
+		}
+	}
+	if tcp, ok := ln.(*net.TCPListener); ok {
+		listeners.active = append(listeners.active, tcp)
+	}
+	return ln, nil
+}
+
+// Available checks the address can be listened on, the addresses of the prepared listeners are
+func Available(addr string) error {
+	listeners.Lock()
+	defer listeners.Unlock()
+	if _, ok := listeners.prepared[addr]; ok {
+		return nil
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return ln.Close()
+}
+
+// Upgrade starts the executable of the process, e.g. replaced by a newer version in the meantime, with the same
+// arguments and passes the listeners to it. Once ready, the new process asks this one to exit, see ParentPID
+func Upgrade() (*os.Process, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	files := []*os.File{os.Stdin, os.Stdout, os.Stderr}
+	listeners.Lock()
+	for _, ln := range listeners.active {
+		if f, err := ln.File(); err == nil { // closed listeners are skipped
+			files = append(files, f)
+		}
+	}
+	listeners.Unlock()
+	defer func() {
+		for _, f := range files[listenFdsStart:] {
+			_ = f.Close()
+		}
+	}()
+	env := slices.DeleteFunc(os.Environ(), func(e string) bool {
+		return strings.HasPrefix(e, "LISTEN_") || strings.HasPrefix(e, parentPIDEnv+"=")
+	})
+	env = append(env, "LISTEN_FDS="+strconv.Itoa(len(files)-listenFdsStart), parentPIDEnv+"="+strconv.Itoa(os.Getpid()))
+	return os.StartProcess(exe, os.Args, &os.ProcAttr{Env: env, Files: files})
+}
+
+// ParentPID returns the PID of the collector to take over from if the process was started by its Upgrade, 0 otherwise
+func ParentPID() int {
+	pid, err := strconv.Atoi(os.Getenv(parentPIDEnv))
+	if err != nil || pid != os.Getppid() {
+		return 0
+	}
+	return pid
+}
+
+// inheritedListeners returns the listeners passed following the socket activation protocol, the variables
+// are unset not to be passed further
+func inheritedListeners() (lns []net.Listener) {
+	defer func() {
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	}()
+	if pid := os.Getenv("LISTEN_PID"); pid > "" && pid != strconv.Itoa(os.Getpid()) {
+		return nil // meant for another process
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil {
+		return nil
+	}
+	for fd := listenFdsStart; fd < listenFdsStart+n; fd++ {
+		f := os.NewFile(uintptr(fd), "listener")
+		if ln, err := net.FileListener(f); err == nil {
+			lns = append(lns, ln)
+		}
+		_ = f.Close()
+	}
+	return lns
+}
+
+// sameAddress returns true if the listener address is the one configured, e.g. [::]:9187 for :9187
+func sameAddress(a net.Addr, addr string) bool {
+	got, ok := a.(*net.TCPAddr)
+	want, err := net.ResolveTCPAddr("tcp", addr)
+	if !ok || err != nil || got.Port != want.Port {
+		return false
+	}
+	if want.IP == nil || want.IP.IsUnspecified() {
+		return got.IP.IsUnspecified()
+	}
+	return want.IP.Equal(got.IP)
+}
@@ -0,0 +1,95 @@
+package handover
+
+import (
+	"context"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrepareReusePort(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SO_REUSEPORT is not available on Windows")
+	}
+	ctx := context.Background()
+	running, err := (&net.ListenConfig{Control: reusePortControl}).Listen(ctx, "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer running.Close()
+	addr := running.Addr().String()
+
+	require.NoError(t, Prepare(ctx, []string{addr}, true), "bound alongside the running collector")
+	ln, err := Listen(addr)
+	require.NoError(t, err)
+	defer ln.Close()
+	assert.Equal(t, addr, ln.Addr().String())
+
+	_, err = Listen(addr)
+	assert.Error(t, err, "not prepared, bound without SO_REUSEPORT")
+
+	assert.NoError(t, Prepare(ctx, []string{addr}, false), "nothing to prepare")
+	listeners.Lock()
+	assert.Empty(t, listeners.prepared)
+	listeners.Unlock()
+}
+
+func TestAvailable(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SO_REUSEPORT is not available on Windows")
+	}
+	ctx := context.Background()
+	running, err := (&net.ListenConfig{Control: reusePortControl}).Listen(ctx, "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer running.Close()
+	addr := running.Addr().String()
+
+	assert.Error(t, Available(addr), "in use")
+	require.NoError(t, Prepare(ctx, []string{addr}, true))
+	assert.NoError(t, Available(addr), "prepared")
+	ln, err := Listen(addr)
+	require.NoError(t, err)
+	defer ln.Close()
+	assert.NoError(t, Available("127.0.0.1:0"))
+}
+
+func TestInheritedListeners(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+	assert.Empty(t, inheritedListeners(), "meant for another process")
+	assert.Empty(t, os.Getenv("LISTEN_FDS"), "not passed further")
+
+	t.Setenv("LISTEN_FDS", "")
+	assert.Empty(t, inheritedListeners())
+}
+
+func TestParentPID(t *testing.T) {
+	t.Setenv(parentPIDEnv, strconv.Itoa(os.Getppid()))
+	assert.Equal(t, os.Getppid(), ParentPID())
+	t.Setenv(parentPIDEnv, "1234567")
+	assert.Zero(t, ParentPID(), "started by another process")
+	t.Setenv(parentPIDEnv, "")
+	assert.Zero(t, ParentPID())
+}
+
+func TestSameAddress(t *testing.T) {
+	for _, tc := range []struct {
+		listener, addr string
+		same           bool
+	}{
+		{"[::]:9187", ":9187", true},
+		{"0.0.0.0:9187", "0.0.0.0:9187", true},
+		{"127.0.0.1:9187", "127.0.0.1:9187", true},
+		{"127.0.0.1:9187", ":9187", false},
+		{"[::]:9187", ":9188", false},
+		{"[::]:9187", "127.0.0.1:9187", false},
+		{"[::]:9187", "invalid", false},
+	} {
+		a, err := net.ResolveTCPAddr("tcp", tc.listener)
+		require.NoError(t, err)
+		assert.Equal(t, tc.same, sameAddress(a, tc.addr), tc)
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package handover
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// reusePortControl sets SO_REUSEPORT on the socket, so that several processes can bind the same address
+func reusePortControl(_, _ string, c syscall.RawConn) (err error) {
+	if e := c.Control(func(fd uintptr) {
+		err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	}); e != nil {
+		return e
+	}
+	return
+}
+
+// Terminate asks the process to shut down gracefully, as on Ctrl+C
+func Terminate(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
@@ -0,0 +1,16 @@
+package handover
+
+import (
+	"errors"
+	"syscall"
+)
+
+// reusePortControl fails, SO_REUSEPORT is not available on Windows
+func reusePortControl(_, _ string, _ syscall.RawConn) error {
+	return errors.New("SO_REUSEPORT is not supported on Windows")
+}
+
+// Terminate fails, there is no graceful shutdown signal on Windows
+func Terminate(int) error {
+	return errors.New("handover is not supported on Windows")
+}
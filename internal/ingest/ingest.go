@@ -0,0 +1,184 @@
+package ingest
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/api/pb"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/handover"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// CmdOpts specifies the gRPC endpoint remote agents push their measurements to
+type CmdOpts struct {
+	Listen string `long:"ingest-listen" mapstructure:"ingest-listen" description:"Address of the gRPC endpoint accepting measurements pushed by remote agents, e.g. :9188. Disabled if empty" env:"PW_INGEST_LISTEN"`
+	Token  string `long:"ingest-token" mapstructure:"ingest-token" description:"Bearer token the remote agents must send, any agent is accepted if empty" env:"PW_INGEST_TOKEN"`
+}
+
+// Server receives the measurements pushed by remote agents and queues them for the sinks of the collector,
+// so that routing, batching and storing is done centrally
+type Server struct {
+	pb.UnimplementedIngestServer
+	measurementCh chan<- []metrics.MeasurementEnvelope
+	metricDef     func(name string) (metrics.Metric, bool)
+	token         string
+}
+
+// NewServer returns a server queuing the pushed measurements to measurementCh. The definitions of the
+// pushed metrics are looked up by metricDef, e.g. to export the gauges to Prometheus properly
+func NewServer(measurementCh chan<- []metrics.MeasurementEnvelope, metricDef func(name string) (metrics.Metric, bool), token string) *Server {
+	return &Server{measurementCh: measurementCh, metricDef: metricDef, token: token}
+}
+
+// Push queues the measurements, failing with ResourceExhausted if the queue is full so the agent can retry later
+func (s *Server) Push(ctx context.Context, req *pb.PushRequest) (*pb.PushResponse, error) {
+	msgs := make([]metrics.MeasurementEnvelope, 0, len(req.GetMeasurements()))
+	for _, e := range req.GetMeasurements() {
+		if e.GetDbName() == "" || e.GetMetricName() == "" {
+			return nil, status.Error(codes.InvalidArgument, "db_name and metric_name are required")
+		}
+		msg := FromProto(e)
+		if def, ok := s.metricDef(msg.MetricName); ok {
+			msg.MetricDef = def
+		}
+		msgs = append(msgs, msg)
+	}
+	if len(msgs) == 0 {
+		return &pb.PushResponse{}, nil
+	}
+	select {
+	case s.measurementCh <- msgs:
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	default:
+		return nil, status.Error(codes.ResourceExhausted, "measurement queue is full")
+	}
+	return &pb.PushResponse{Accepted: int64(len(msgs))}, nil
+}
+
+// authenticate rejects the calls without the bearer token if one is configured
+func (s *Server) authenticate(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if s.token > "" {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if auth := md.Get("authorization"); len(auth) == 0 ||
+			subtle.ConstantTimeCompare([]byte(auth[0]), []byte("Bearer "+s.token)) != 1 {
+			return nil, status.Error(codes.Unauthenticated, "invalid or missing bearer token")
+		}
+	}
+	return handler(ctx, req)
+}
+
+// Serve accepts the pushed measurements on addr until ctx is cancelled
+func (s *Server) Serve(ctx context.Context, addr string) error {
+	l, err := handover.Listen(addr)
+	if err != nil {
+		return err
+	}
+	srv := grpc.NewServer(grpc.UnaryInterceptor(s.authenticate))
+	pb.RegisterIngestServer(srv, s)
+	go func() {
+		<-ctx.Done()
+		srv.GracefulStop()
+	}()
+	log.GetLogger(ctx).WithField("address", l.Addr().String()).Info("accepting measurements from remote agents")
+	return srv.Serve(l)
+}
+
+// FromProto converts a pushed measurement envelope
+func FromProto(e *pb.MeasurementEnvelope) metrics.MeasurementEnvelope {
+	data := make(metrics.Measurements, 0, len(e.GetData()))
+	for _, row := range e.GetData() {
+		m := make(metrics.Measurement, len(row.GetColumns()))
+		for col, v := range row.GetColumns() {
+			switch k := v.GetKind().(type) {
+			case *pb.Value_IntValue:
+				m[col] = k.IntValue
+			case *pb.Value_DoubleValue:
+				m[col] = k.DoubleValue
+			case *pb.Value_StringValue:
+				m[col] = k.StringValue
+			case *pb.Value_BoolValue:
+				m[col] = k.BoolValue
+			}
+		}
+		data = append(data, m)
+	}
+	return metrics.MeasurementEnvelope{
+		DBName:           e.GetDbName(),
+		SourceType:       e.GetSourceType(),
+		MetricName:       e.GetMetricName(),
+		CustomTags:       e.GetCustomTags(),
+		Data:             data,
+		RealDbname:       e.GetRealDbname(),
+		SystemIdentifier: e.GetSystemIdentifier(),
+	}
+}
+
+// ToProto converts a measurement envelope to be pushed. NULL columns are left out, timestamps are sent as
+// RFC 3339 strings and other non-scalar values, e.g. arrays, as JSON
+func ToProto(msg metrics.MeasurementEnvelope) *pb.MeasurementEnvelope {
+	e := &pb.MeasurementEnvelope{
+		DbName:           msg.DBName,
+		SourceType:       msg.SourceType,
+		MetricName:       msg.MetricName,
+		CustomTags:       msg.CustomTags,
+		RealDbname:       msg.RealDbname,
+		SystemIdentifier: msg.SystemIdentifier,
+		Data:             make([]*pb.Measurement, 0, len(msg.Data)),
+	}
+	for _, row := range msg.Data {
+		m := &pb.Measurement{Columns: make(map[string]*pb.Value, len(row))}
+		for col, v := range row {
+			if v := toValue(v); v != nil {
+				m.Columns[col] = v
+			}
+		}
+		e.Data = append(e.Data, m)
+	}
+	return e
+}
+
+func toValue(v any) *pb.Value {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case int:
+		return &pb.Value{Kind: &pb.Value_IntValue{IntValue: int64(val)}}
+	case int8:
+		return &pb.Value{Kind: &pb.Value_IntValue{IntValue: int64(val)}}
+	case int16:
+		return &pb.Value{Kind: &pb.Value_IntValue{IntValue: int64(val)}}
+	case int32:
+		return &pb.Value{Kind: &pb.Value_IntValue{IntValue: int64(val)}}
+	case int64:
+		return &pb.Value{Kind: &pb.Value_IntValue{IntValue: val}}
+	case uint32:
+		return &pb.Value{Kind: &pb.Value_IntValue{IntValue: int64(val)}}
+	case float32:
+		return &pb.Value{Kind: &pb.Value_DoubleValue{DoubleValue: float64(val)}}
+	case float64:
+		return &pb.Value{Kind: &pb.Value_DoubleValue{DoubleValue: val}}
+	case bool:
+		return &pb.Value{Kind: &pb.Value_BoolValue{BoolValue: val}}
+	case string:
+		return &pb.Value{Kind: &pb.Value_StringValue{StringValue: val}}
+	case []byte:
+		return &pb.Value{Kind: &pb.Value_StringValue{StringValue: string(val)}}
+	case time.Time:
+		return &pb.Value{Kind: &pb.Value_StringValue{StringValue: val.Format(time.RFC3339Nano)}}
+	default:
+		b, err := json.Marshal(val)
+		if err != nil {
+			b = []byte(fmt.Sprint(val))
+		}
+		return &pb.Value{Kind: &pb.Value_StringValue{StringValue: string(b)}}
+	}
+}
@@ -0,0 +1,101 @@
+package ingest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/api/pb"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestProtoRoundTrip(t *testing.T) {
+	ts := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+	msg := metrics.MeasurementEnvelope{
+		DBName:     "db1",
+		SourceType: "postgres",
+		MetricName: "cpu_load",
+		CustomTags: map[string]string{"env": "prod"},
+		Data: metrics.Measurements{{
+			"epoch_ns":    int64(1706702400000000000),
+			"load_1min":   1.5,
+			"cores":       int32(8),
+			"tag_host":    "db-host-1",
+			"is_primary":  true,
+			"stats_reset": ts,
+			"disks":       []string{"sda", "sdb"},
+			"missing":     nil,
+		}},
+		RealDbname:       "postgres",
+		SystemIdentifier: "7321",
+	}
+	got := FromProto(ToProto(msg))
+	assert.Equal(t, msg.DBName, got.DBName)
+	assert.Equal(t, msg.CustomTags, got.CustomTags)
+	assert.Equal(t, msg.SystemIdentifier, got.SystemIdentifier)
+	assert.Equal(t, metrics.Measurements{{
+		"epoch_ns":    int64(1706702400000000000),
+		"load_1min":   1.5,
+		"cores":       int64(8),
+		"tag_host":    "db-host-1",
+		"is_primary":  true,
+		"stats_reset": "2024-01-31T12:00:00Z",
+		"disks":       `["sda","sdb"]`,
+	}}, got.Data, "NULL columns are left out, epoch_ns keeps its precision")
+}
+
+func TestServerPush(t *testing.T) {
+	ch := make(chan []metrics.MeasurementEnvelope, 1)
+	defs := map[string]metrics.Metric{"cpu_load": {Gauges: []string{"*"}}}
+	s := NewServer(ch, func(name string) (metrics.Metric, bool) {
+		m, ok := defs[name]
+		return m, ok
+	}, "")
+	ctx := context.Background()
+
+	resp, err := s.Push(ctx, &pb.PushRequest{Measurements: []*pb.MeasurementEnvelope{
+		{DbName: "db1", MetricName: "cpu_load"},
+		{DbName: "db1", MetricName: "custom"},
+	}})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, resp.Accepted)
+	msgs := <-ch
+	assert.Equal(t, []string{"*"}, msgs[0].MetricDef.Gauges, "definition of known metrics is attached")
+	assert.Empty(t, msgs[1].MetricDef.Gauges)
+
+	_, err = s.Push(ctx, &pb.PushRequest{Measurements: []*pb.MeasurementEnvelope{{DbName: "db1"}}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	resp, err = s.Push(ctx, &pb.PushRequest{})
+	require.NoError(t, err)
+	assert.Zero(t, resp.Accepted)
+
+	ch <- nil
+	_, err = s.Push(ctx, &pb.PushRequest{Measurements: []*pb.MeasurementEnvelope{{DbName: "db1", MetricName: "cpu_load"}}})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err), "full queue is reported to the agent")
+}
+
+func TestServerAuthenticate(t *testing.T) {
+	s := NewServer(nil, nil, "secret")
+	handler := func(context.Context, any) (any, error) { return "ok", nil }
+	for auth, code := range map[string]codes.Code{
+		"":              codes.Unauthenticated,
+		"Bearer wrong":  codes.Unauthenticated,
+		"Bearer secret": codes.OK,
+	} {
+		ctx := context.Background()
+		if auth > "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", auth))
+		}
+		_, err := s.authenticate(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+		assert.Equal(t, code, status.Code(err), auth)
+	}
+	_, err := NewServer(nil, nil, "").authenticate(context.Background(), nil, &grpc.UnaryServerInfo{}, handler)
+	assert.NoError(t, err, "no token configured")
+}
@@ -0,0 +1,33 @@
+//go:build integration
+
+package integration
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoredRowsPerMetric(t *testing.T) {
+	h := NewHarness(t)
+	intervals := map[string]float64{"db_stats": 1, "db_size": 1, "wal": 1, "backends": 1, "instance_up": 1}
+	h.AddSource("monitored", intervals)
+
+	allStored := func() bool {
+		for metric := range intervals {
+			if h.StoredRows(metric, "monitored") == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	h.RunCollector(time.Minute, allStored)
+
+	for metric := range intervals {
+		assert.Positive(t, h.StoredRows(metric, "monitored"), metric)
+	}
+	assert.Contains(t, h.LastStored("db_stats", "monitored"), "numbackends")
+	assert.EqualValues(t, 1, h.LastStored("instance_up", "monitored")["is_up"])
+	assert.Zero(t, h.StoredRows("db_stats", "unknown"), "rows are stored per source")
+}
@@ -0,0 +1,6 @@
+// Package integration contains the end-to-end tests of the collector. They start Postgres containers for the
+// configuration database, the metrics database and a monitored database, run the collector briefly and check the
+// rows stored per metric. The tests need Docker and are excluded from the regular test run by a build tag:
+//
+//	go test -tags integration ./internal/integration/...
+package integration
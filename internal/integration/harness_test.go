@@ -0,0 +1,131 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/cmdopts"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/reaper"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	testcontainers "github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const postgresImage = "docker.io/postgres:16-alpine"
+
+// Harness runs the collector against throwaway Postgres containers
+type Harness struct {
+	t           *testing.T
+	ctx         context.Context
+	ConfigDB    string // sources and metric definitions
+	MetricsDB   string // Postgres sink
+	MonitoredDB string
+}
+
+// startPostgres starts a Postgres container terminated at the end of the test and returns its connection string
+func startPostgres(ctx context.Context, t *testing.T, dbname string) string {
+	pgContainer, err := postgres.Run(ctx,
+		postgresImage,
+		postgres.WithDatabase(dbname),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(30*time.Second)),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = pgContainer.Terminate(context.Background()) })
+	connStr, err := pgContainer.ConnectionString(ctx, "sslmode=disable")
+	require.NoError(t, err)
+	return connStr
+}
+
+// NewHarness starts the configuration, metrics and monitored databases
+func NewHarness(t *testing.T) *Harness {
+	ctx := context.Background()
+	return &Harness{
+		t:           t,
+		ctx:         ctx,
+		ConfigDB:    startPostgres(ctx, t, "pgwatch"),
+		MetricsDB:   startPostgres(ctx, t, "pgwatch_metrics"),
+		MonitoredDB: startPostgres(ctx, t, "monitored"),
+	}
+}
+
+// options parses the command line the collector is started with, so that the defaults are applied
+func (h *Harness) options(args ...string) *cmdopts.Options {
+	osArgs := os.Args
+	defer func() { os.Args = osArgs }()
+	os.Args = append([]string{"pgwatch", "--sources=" + h.ConfigDB, "--sink=" + h.MetricsDB,
+		"--web-disable=all", "--run-lock=off"}, args...)
+	opts, err := cmdopts.New(io.Discard)
+	require.NoError(h.t, err)
+	require.NoError(h.t, opts.InitConfigReaders(h.ctx))
+	return opts
+}
+
+// AddSource registers the monitored database in the configuration database with the given metric intervals
+func (h *Harness) AddSource(name string, intervals map[string]float64) {
+	opts := h.options()
+	require.NoError(h.t, opts.SourcesReaderWriter.UpdateSource(sources.Source{
+		Name:      name,
+		ConnStr:   h.MonitoredDB,
+		Kind:      sources.SourcePostgres,
+		Metrics:   intervals,
+		IsEnabled: true,
+	}))
+}
+
+// RunCollector runs the collector until done returns true or the timeout expires
+func (h *Harness) RunCollector(timeout time.Duration, done func() bool, args ...string) {
+	opts := h.options(args...)
+	ctx, cancel := context.WithTimeout(h.ctx, timeout)
+	defer cancel()
+	finished := make(chan error, 1)
+	go func() {
+		finished <- reaper.NewReaper(opts, opts.SourcesReaderWriter, opts.MetricsReaderWriter).Reap(ctx)
+	}()
+	for ctx.Err() == nil && !done() {
+		time.Sleep(time.Second)
+	}
+	cancel()
+	<-finished
+}
+
+// query runs the query on the metrics database, missing metric tables are reported as errors
+func (h *Harness) query(sql string, args []any, dest ...any) error {
+	conn, err := pgx.Connect(h.ctx, h.MetricsDB)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(h.ctx)
+	return conn.QueryRow(h.ctx, sql, args...).Scan(dest...)
+}
+
+// StoredRows returns the number of rows of the metric stored for the source
+func (h *Harness) StoredRows(metric, source string) (rows int) {
+	err := h.query("select count(*) from public."+pgx.Identifier{metric}.Sanitize()+" where dbname = $1", []any{source}, &rows)
+	if err != nil {
+		return 0
+	}
+	return
+}
+
+// LastStored returns the data of the latest row of the metric stored for the source
+func (h *Harness) LastStored(metric, source string) map[string]any {
+	var data []byte
+	err := h.query("select data from public."+pgx.Identifier{metric}.Sanitize()+" where dbname = $1 order by time desc limit 1",
+		[]any{source}, &data)
+	require.NoError(h.t, err)
+	row := make(map[string]any)
+	require.NoError(h.t, json.Unmarshal(data, &row))
+	return row
+}
@@ -0,0 +1,128 @@
+package log
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// CallstackField is the field of the entries logging a recovered panic, they are reported as crashes
+const CallstackField = "callstack"
+
+// errorReportTimeout is how long sending a report may take, reports are sent synchronously not to lose them on exit
+var errorReportTimeout = 5 * time.Second
+
+// ErrorReportHook sends the panics and fatal errors to a Sentry compatible endpoint, e.g. Sentry or GlitchTip.
+// Only the log entry is sent: the message, fields and call stack, never measurements
+type ErrorReportHook struct {
+	endpoint    string // the envelope endpoint of the project
+	auth        string // X-Sentry-Auth header
+	environment string
+	release     string
+	serverName  string
+	client      *http.Client
+}
+
+// NewErrorReportHook returns the hook sending the reports to the project of the DSN, e.g.
+// https://<key>@sentry.example.com/<project>, tagged with the environment and the pgwatch version
+func NewErrorReportHook(dsn, environment, release string) (*ErrorReportHook, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid error report DSN: %w", err)
+	}
+	project := path.Base(u.Path)
+	if u.User == nil || u.User.Username() == "" || u.Host == "" || project == "." || project == "/" {
+		return nil, errors.New("invalid error report DSN, expected format is https://<key>@<host>/<project>")
+	}
+	hostname, _ := os.Hostname()
+	return &ErrorReportHook{
+		endpoint: fmt.Sprintf("%s://%s%s/api/%s/envelope/", u.Scheme, u.Host, strings.TrimSuffix(path.Dir(u.Path), "/"), project),
+		auth: fmt.Sprintf("Sentry sentry_version=7, sentry_client=pgwatch/%s, sentry_key=%s",
+			release, u.User.Username()),
+		environment: environment,
+		release:     release,
+		serverName:  hostname,
+		client:      &http.Client{Timeout: errorReportTimeout},
+	}, nil
+}
+
+func (h *ErrorReportHook) Levels() []logrus.Level {
+	return []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel}
+}
+
+// Fire sends the panics and fatal errors, other errors are expected failures, e.g. of monitored databases
+func (h *ErrorReportHook) Fire(entry *logrus.Entry) error {
+	_, crashed := entry.Data[CallstackField]
+	if !crashed && entry.Level > logrus.FatalLevel {
+		return nil
+	}
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	event := map[string]any{
+		"event_id":    hex.EncodeToString(id),
+		"timestamp":   entry.Time.UTC().Format(time.RFC3339Nano),
+		"level":       "fatal",
+		"logger":      "pgwatch",
+		"platform":    "go",
+		"release":     h.release,
+		"environment": h.environment,
+		"server_name": h.serverName,
+		"message":     map[string]string{"formatted": entry.Message},
+		"tags":        map[string]string{"os": runtime.GOOS, "arch": runtime.GOARCH, "go": runtime.Version()},
+	}
+	extra := make(map[string]string, len(entry.Data))
+	for k, v := range entry.Data {
+		extra[k] = fmt.Sprint(v)
+	}
+	event["extra"] = extra
+	if crashed {
+		event["exception"] = map[string]any{"values": []map[string]any{{
+			"type":      "panic",
+			"value":     entry.Message,
+			"mechanism": map[string]any{"type": "panic", "handled": false},
+		}}}
+	}
+	return h.send(event)
+}
+
+// send posts the event as an envelope, see https://develop.sentry.dev/sdk/data-model/envelopes/
+func (h *ErrorReportHook) send(event map[string]any) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	var envelope bytes.Buffer
+	header, _ := json.Marshal(map[string]string{"event_id": event["event_id"].(string),
+		"sent_at": time.Now().UTC().Format(time.RFC3339Nano)})
+	envelope.Write(header)
+	fmt.Fprintf(&envelope, "\n{\"type\":\"event\",\"length\":%d}\n", len(payload))
+	envelope.Write(payload)
+	envelope.WriteByte('\n')
+	req, err := http.NewRequest(http.MethodPost, h.endpoint, &envelope)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", h.auth)
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error report failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("error report failed: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package log_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewErrorReportHook(t *testing.T) {
+	for _, dsn := range []string{"https://sentry.example.com/1", "https://key@sentry.example.com", "https://key@/1", "::"} {
+		_, err := log.NewErrorReportHook(dsn, "production", "3.0.0")
+		assert.Error(t, err, dsn)
+	}
+}
+
+func TestErrorReportHook(t *testing.T) {
+	var requests []*http.Request
+	var events []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		scanner := bufio.NewScanner(r.Body)
+		scanner.Buffer(nil, 1<<20)
+		var lines []string
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		if assert.Len(t, lines, 3, "envelope header, item header, event") {
+			var event map[string]any
+			assert.NoError(t, json.Unmarshal([]byte(lines[2]), &event))
+			events = append(events, event)
+		}
+	}))
+	defer srv.Close()
+
+	hook, err := log.NewErrorReportHook(strings.Replace(srv.URL, "://", "://public@", 1)+"/sentry/42", "staging", "3.1.0")
+	require.NoError(t, err)
+	l, _ := test.NewNullLogger()
+	l.ExitFunc = func(int) {}
+	l.AddHook(hook)
+
+	l.WithError(errors.New("connection refused")).Error("failed to fetch metric data")
+	l.Warning("not an error")
+	assert.Empty(t, requests, "expected errors are not reported")
+
+	l.WithField("source", "db1").WithField(log.CallstackField, "goroutine 1 [running]:").Error("runtime error: index out of range")
+	l.Fatal("could not create the sinks")
+	require.Len(t, requests, 2)
+	assert.Equal(t, "/sentry/api/42/envelope/", requests[0].URL.Path)
+	assert.Equal(t, "Sentry sentry_version=7, sentry_client=pgwatch/3.1.0, sentry_key=public", requests[0].Header.Get("X-Sentry-Auth"))
+
+	require.Len(t, events, 2)
+	assert.Equal(t, "staging", events[0]["environment"])
+	assert.Equal(t, "3.1.0", events[0]["release"])
+	assert.Equal(t, map[string]any{"source": "db1", log.CallstackField: "goroutine 1 [running]:"}, events[0]["extra"])
+	assert.NotNil(t, events[0]["exception"], "crashes are reported as exceptions")
+	assert.Equal(t, map[string]any{"formatted": "could not create the sinks"}, events[1]["message"])
+	assert.Nil(t, events[1]["exception"])
+
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusTooManyRequests) })
+	entry := logrus.NewEntry(l)
+	entry.Level = logrus.FatalLevel
+	assert.EqualError(t, hook.Fire(entry), "error report failed: 429 Too Many Requests")
+}
@@ -19,6 +19,7 @@ type (
 		AddHook(hook logrus.Hook)
 		AddSubscriber(msgCh MessageChanType)
 		RemoveSubscriber(msgCh MessageChanType)
+		ToggleDebug() logrus.Level
 	}
 
 	loggerKey struct{}
@@ -27,6 +28,7 @@ type (
 type logger struct {
 	*logrus.Logger
 	*BrokerHook
+	level logrus.Level // configured level, restored when debug logging is toggled off
 }
 
 func getLogFileWriter(opts CmdOpts) any {
@@ -56,7 +58,8 @@ func getLogFileFormatter(opts CmdOpts) logrus.Formatter {
 // Init creates logging facilities for the application
 func Init(opts CmdOpts) LoggerHookerIface {
 	var err error
-	l := logger{logrus.New(), NewBrokerHook(context.Background(), opts.LogLevel)}
+	l := logger{Logger: logrus.New(), BrokerHook: NewBrokerHook(context.Background(), opts.LogLevel)}
+	l.AddHook(RedactHook{}) // must be the first one, so no secret is passed to other hooks
 	l.AddHook(l.BrokerHook)
 	l.Out = os.Stdout
 	if opts.LogFile > "" {
@@ -66,12 +69,25 @@ func Init(opts CmdOpts) LoggerHookerIface {
 	if err != nil {
 		l.Level = logrus.InfoLevel
 	}
+	l.level = l.Level
 	l.SetFormatter(newFormatter(enableColors))
 	l.SetBrokerFormatter(newFormatter(disableColors))
 	l.SetReportCaller(l.Level > logrus.InfoLevel)
 	return l
 }
 
+// ToggleDebug switches the logger to the debug level or back to the configured one,
+// and returns the level in effect afterwards
+func (l logger) ToggleDebug() logrus.Level {
+	level := logrus.DebugLevel
+	if l.GetLevel() >= logrus.DebugLevel {
+		level = l.level
+	}
+	l.SetLevel(level)
+	l.SetReportCaller(level > logrus.InfoLevel)
+	return level
+}
+
 // PgxLogger is the struct used to log using pgx postgres driver
 type PgxLogger struct {
 	l LoggerIface
@@ -7,6 +7,7 @@ import (
 
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
 	"github.com/jackc/pgx/v5/tracelog"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -34,3 +35,12 @@ func TestPgxLog(_ *testing.T) {
 		pgxl.Log(context.Background(), level, "foo", map[string]interface{}{"func": "TestPgxLog"})
 	}
 }
+
+func TestToggleDebug(t *testing.T) {
+	l := log.Init(log.CmdOpts{LogLevel: "warn"})
+	assert.Equal(t, logrus.DebugLevel, l.ToggleDebug())
+	assert.Equal(t, logrus.WarnLevel, l.ToggleDebug())
+
+	l = log.Init(log.CmdOpts{LogLevel: "debug"})
+	assert.Equal(t, logrus.DebugLevel, l.ToggleDebug(), "configured debug level stays")
+}
@@ -0,0 +1,86 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Throttler collapses the repeated errors of many sources into periodic summaries. The first error of a key,
+// e.g. "metric db_stats", in a period is logged at full detail, the others are counted and summarized at the end
+// of the period, e.g. "metric db_stats failed on 37 sources in the last 5m0s"
+type Throttler struct {
+	period time.Duration
+	mu     sync.Mutex
+	errors map[string]*throttledError
+}
+
+type throttledError struct {
+	sources    map[string]struct{} // failed in the period
+	suppressed int                 // errors not logged in the period
+	lastErr    error
+}
+
+// NewThrottler returns a throttler summarizing every period, errors are not throttled if period is 0
+func NewThrottler(period time.Duration) *Throttler {
+	return &Throttler{period: period, errors: make(map[string]*throttledError)}
+}
+
+// Error logs the error of the source with msg if it is the first of the key in the period, and counts it otherwise
+func (t *Throttler) Error(l LoggerIface, key, source string, err error, msg string) {
+	if t.period <= 0 {
+		l.WithError(err).Error(msg)
+		return
+	}
+	t.mu.Lock()
+	e, ok := t.errors[key]
+	if !ok {
+		e = &throttledError{sources: make(map[string]struct{})}
+		t.errors[key] = e
+	} else {
+		e.suppressed++
+	}
+	e.sources[source] = struct{}{}
+	e.lastErr = err
+	t.mu.Unlock()
+	if !ok {
+		l.WithError(err).Error(msg)
+	}
+}
+
+// Flush logs the summaries of the keys with suppressed errors and starts a new period
+func (t *Throttler) Flush(l LoggerIface) {
+	t.mu.Lock()
+	pending := t.errors
+	t.errors = make(map[string]*throttledError)
+	t.mu.Unlock()
+	for _, key := range slices.Sorted(maps.Keys(pending)) {
+		e := pending[key]
+		if e.suppressed == 0 {
+			continue
+		}
+		l.WithError(e.lastErr).WithField("suppressed", e.suppressed).
+			Error(fmt.Sprintf("%s failed on %d sources in the last %v", key, len(e.sources), t.period))
+	}
+}
+
+// Run flushes the summaries every period until the context is cancelled
+func (t *Throttler) Run(ctx context.Context, l LoggerIface) {
+	if t.period <= 0 {
+		return
+	}
+	ticker := time.NewTicker(t.period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			t.Flush(l)
+			return
+		case <-ticker.C:
+			t.Flush(l)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package log_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottler(t *testing.T) {
+	l, hook := test.NewNullLogger()
+	th := log.NewThrottler(5 * time.Minute)
+
+	th.Error(l.WithField("source", "db1"), "metric db_stats", "db1", errors.New("connection refused"), "failed to fetch metric data")
+	for _, source := range []string{"db2", "db3", "db2"} {
+		th.Error(l.WithField("source", source), "metric db_stats", source, errors.New("timeout"), "failed to fetch metric data")
+	}
+	th.Error(l, "metric wal", "db1", errors.New("connection refused"), "failed to fetch metric data")
+	assert.Len(t, hook.AllEntries(), 2, "only the first error of a key at full detail")
+	assert.Equal(t, "db1", hook.AllEntries()[0].Data["source"])
+	assert.EqualError(t, hook.AllEntries()[0].Data["error"].(error), "connection refused")
+
+	hook.Reset()
+	th.Flush(l)
+	if assert.Len(t, hook.AllEntries(), 1, "no summary without suppressed errors") {
+		e := hook.LastEntry()
+		assert.Equal(t, "metric db_stats failed on 3 sources in the last 5m0s", e.Message)
+		assert.Equal(t, 3, e.Data["suppressed"])
+		assert.EqualError(t, e.Data["error"].(error), "timeout", "the last error")
+	}
+
+	hook.Reset()
+	th.Error(l, "metric db_stats", "db1", errors.New("connection refused"), "failed to fetch metric data")
+	assert.Len(t, hook.AllEntries(), 1, "a new period starts after the summary")
+	th.Flush(l)
+	assert.Len(t, hook.AllEntries(), 1)
+
+	hook.Reset()
+	th = log.NewThrottler(0)
+	th.Error(l, "metric db_stats", "db1", errors.New("timeout"), "failed to fetch metric data")
+	th.Error(l, "metric db_stats", "db1", errors.New("timeout"), "failed to fetch metric data")
+	assert.Len(t, hook.AllEntries(), 2, "not throttled")
+}
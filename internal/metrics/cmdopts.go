@@ -1,10 +1,30 @@
 package metrics
 
+import "time"
+
 // CmdOpts specifies metric command-line options
 type CmdOpts struct {
-	Metrics                      string `short:"m" long:"metrics" mapstructure:"metrics" description:"File or folder of YAML files with metrics definitions" env:"PW_METRICS"`
-	CreateHelpers                bool   `long:"create-helpers" mapstructure:"create-helpers" description:"Create helper database objects from metric definitions" env:"PW_CREATE_HELPERS"`
-	DirectOSStats                bool   `long:"direct-os-stats" mapstructure:"direct-os-stats" description:"Extract OS related psutil statistics not via PL/Python wrappers but directly on host" env:"PW_DIRECT_OS_STATS"`
-	InstanceLevelCacheMaxSeconds int64  `long:"instance-level-cache-max-seconds" mapstructure:"instance-level-cache-max-seconds" description:"Max allowed staleness for instance level metric data shared between DBs of an instance. Affects 'continuous' host types only. Set to 0 to disable" env:"PW_INSTANCE_LEVEL_CACHE_MAX_SECONDS" default:"30"`
-	EmergencyPauseTriggerfile    string `long:"emergency-pause-triggerfile" mapstructure:"emergency-pause-triggerfile" description:"When the file exists no metrics will be temporarily fetched / scraped" env:"PW_EMERGENCY_PAUSE_TRIGGERFILE" default:"/tmp/pgwatch-emergency-pause"`
+	Metrics                      string        `short:"m" long:"metrics" mapstructure:"metrics" description:"File or folder of YAML files with metrics definitions" env:"PW_METRICS"`
+	CreateHelpers                bool          `long:"create-helpers" mapstructure:"create-helpers" description:"Create helper database objects from metric definitions" env:"PW_CREATE_HELPERS"`
+	DirectOSStats                bool          `long:"direct-os-stats" mapstructure:"direct-os-stats" description:"Extract OS related psutil statistics not via PL/Python wrappers but directly on host" env:"PW_DIRECT_OS_STATS"`
+	InstanceLevelCacheMaxSeconds int64         `long:"instance-level-cache-max-seconds" mapstructure:"instance-level-cache-max-seconds" description:"Max allowed staleness for instance level metric data shared between DBs of an instance. Affects 'continuous' host types only. Set to 0 to disable" env:"PW_INSTANCE_LEVEL_CACHE_MAX_SECONDS" default:"30"`
+	EmergencyPauseTriggerfile    string        `long:"emergency-pause-triggerfile" mapstructure:"emergency-pause-triggerfile" description:"When the file exists no metrics will be temporarily fetched / scraped" env:"PW_EMERGENCY_PAUSE_TRIGGERFILE" default:"/tmp/pgwatch-emergency-pause"`
+	CollectorID                  string        `long:"collector-id" mapstructure:"collector-id" description:"Identifier of this pgwatch instance added as a comment to every metric query. Hostname is used if empty" env:"PW_COLLECTOR_ID"`
+	RunLock                      string        `long:"run-lock" mapstructure:"run-lock" description:"Fail at startup if another collector with the same collector id is running: auto uses an advisory lock in the configuration database if any, a lock file otherwise" choice:"auto" choice:"file" choice:"off" env:"PW_RUN_LOCK" default:"auto"`
+	ReusePort                    bool          `long:"reuse-port" mapstructure:"reuse-port" description:"Bind the web UI, Prometheus sink and ingest listeners with SO_REUSEPORT, so that a new collector started with --handover can bind them alongside" env:"PW_REUSE_PORT"`
+	Handover                     bool          `long:"handover" mapstructure:"handover" description:"Take over from the running collector with the same collector id: bind its listeners alongside (it needs --reuse-port), ask it to exit and wait for its run lock" env:"PW_HANDOVER"`
+	HandoverTimeout              time.Duration `long:"handover-timeout" mapstructure:"handover-timeout" description:"Max time to wait for the running collector to drain and release the run lock on a handover" env:"PW_HANDOVER_TIMEOUT" default:"1m"`
+	SlowMetricThreshold          time.Duration `long:"slow-metric-threshold" mapstructure:"slow-metric-threshold" description:"Log metric queries running longer than this. Set to 0 to disable" env:"PW_SLOW_METRIC_THRESHOLD" default:"5s"`
+	ClockDriftThreshold          time.Duration `long:"clock-drift-threshold" mapstructure:"clock-drift-threshold" description:"Warn if the clock of a monitored server differs more than this from the pgwatch host. Set to 0 to disable" env:"PW_CLOCK_DRIFT_THRESHOLD" default:"1s"`
+	ArchivingStuckThreshold      time.Duration `long:"archiving-stuck-threshold" mapstructure:"archiving-stuck-threshold" description:"Mark WAL archiving as stuck in the archiver metric if no WAL segment was archived for this long while there is some to archive" env:"PW_ARCHIVING_STUCK_THRESHOLD" default:"5m"`
+	VacuumStarvationThreshold    time.Duration `long:"vacuum-starvation-threshold" mapstructure:"vacuum-starvation-threshold" description:"Count tables in the autovacuum_health metric as starving if over the autovacuum threshold for this long without being vacuumed" env:"PW_VACUUM_STARVATION_THRESHOLD" default:"1h"`
+	UnusedIndexDays              int           `long:"unused-index-days" mapstructure:"unused-index-days" description:"Recommend dropping indexes observed without scans for this many days" env:"PW_UNUSED_INDEX_DAYS" default:"30"`
+	GoldenConfig                 string        `long:"golden-config" mapstructure:"golden-config" description:"YAML file with the expected server settings per group, hosts deviating from it are reported in the config_drift metric" env:"PW_GOLDEN_CONFIG"`
+	AdvisoryFeed                 string        `long:"advisory-feed" mapstructure:"advisory-feed" description:"File or URL of the JSON feed with the latest PostgreSQL minor and extension versions to report outdated_version recommendations" env:"PW_ADVISORY_FEED"`
+	Backfill                     string        `long:"backfill" mapstructure:"backfill" description:"Comma separated cumulative metrics, e.g. stat_statements,db_stats, whose first measurements after a collector downtime are stored as catch-up rows marked backfilled" env:"PW_BACKFILL"`
+	AlignTimestamps              bool          `long:"align-timestamps" mapstructure:"align-timestamps" description:"Schedule the fetches on the interval boundaries and store the boundary as the measurement time instead of the actual fetch time" env:"PW_ALIGN_TIMESTAMPS"`
+	TestdataDays                 int           `long:"testdata-days" mapstructure:"testdata-days" description:"Generate test data for the given amount of days based on a single fetch of every configured metric, write it to sinks and exit" env:"PW_TESTDATA_DAYS" default:"0"`
+	TestdataMultiplier           int           `long:"testdata-multiplier" mapstructure:"testdata-multiplier" description:"For how many copies of every source to generate test data" env:"PW_TESTDATA_MULTIPLIER" default:"1"`
+	TestdataProfile              string        `long:"testdata-profile" mapstructure:"testdata-profile" description:"Workload profile shaping generated test data" choice:"steady" choice:"diurnal" choice:"bursty" choice:"spiky" env:"PW_TESTDATA_PROFILE" default:"steady"`
+	TestdataJitter               float64       `long:"testdata-jitter" mapstructure:"testdata-jitter" description:"Max random deviation in percent applied to every generated column value" env:"PW_TESTDATA_JITTER" default:"5"`
 }
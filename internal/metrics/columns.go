@@ -0,0 +1,178 @@
+package metrics
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"gopkg.in/yaml.v3"
+)
+
+// Column types of the ColumnAttrs
+const (
+	ColumnTypeInt       = "int"
+	ColumnTypeFloat     = "float"
+	ColumnTypeNumeric   = "numeric" // any number, incl. ints and floats
+	ColumnTypeText      = "text"
+	ColumnTypeBool      = "bool"
+	ColumnTypeTimestamp = "timestamp"
+	ColumnTypeJSON      = "json"
+	ColumnTypeAny       = "any"
+)
+
+var columnTypes = []string{ColumnTypeInt, ColumnTypeFloat, ColumnTypeNumeric, ColumnTypeText, ColumnTypeBool, ColumnTypeTimestamp, ColumnTypeJSON, ColumnTypeAny}
+
+// Column units of the ColumnAttrs
+const (
+	ColumnUnitBytes   = "bytes"
+	ColumnUnitSeconds = "seconds"
+	ColumnUnitCount   = "count"
+)
+
+var columnUnits = []string{ColumnUnitBytes, ColumnUnitSeconds, ColumnUnitCount}
+
+// columnAttr is ColumnAttr without the custom (un)marshalling
+type columnAttr ColumnAttr
+
+// isTypeOnly returns true if the attribute can be written in the short form, i.e. as the type only
+func (a ColumnAttr) isTypeOnly() bool {
+	return a.Unit == "" && a.Scale == 0
+}
+
+func (a *ColumnAttr) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*a = ColumnAttr{}
+		return value.Decode(&a.Type)
+	}
+	return value.Decode((*columnAttr)(a))
+}
+
+func (a ColumnAttr) MarshalYAML() (any, error) {
+	if a.isTypeOnly() {
+		return a.Type, nil
+	}
+	return columnAttr(a), nil
+}
+
+func (a *ColumnAttr) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		*a = ColumnAttr{}
+		return json.Unmarshal(data, &a.Type)
+	}
+	return json.Unmarshal(data, (*columnAttr)(a))
+}
+
+func (a ColumnAttr) MarshalJSON() ([]byte, error) {
+	if a.isTypeOnly() {
+		return json.Marshal(a.Type)
+	}
+	return json.Marshal(columnAttr(a))
+}
+
+// UnitSuffix returns the base unit suffix of the column following the Prometheus naming conventions,
+// e.g. "_bytes", unless the column name already ends with it. Counts have no suffix
+func (a ColumnAttr) UnitSuffix(column string) string {
+	if a.Unit != ColumnUnitBytes && a.Unit != ColumnUnitSeconds || strings.HasSuffix(column, "_"+a.Unit) {
+		return ""
+	}
+	return "_" + a.Unit
+}
+
+// Scaled returns the fetched value converted to the unit of the column
+func (a ColumnAttr) Scaled(v float64) float64 {
+	if a.Scale == 0 {
+		return v
+	}
+	return v * a.Scale
+}
+
+// epochColumnName is added to every row by the collector and needs no declaration
+const epochColumnName = "epoch_ns"
+
+// hasColumnType returns true if the fetched value is of the column type, NULLs match any type
+func hasColumnType(v any, typ string) bool {
+	if v == nil {
+		return true
+	}
+	switch typ {
+	case ColumnTypeInt:
+		switch v.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			return true
+		}
+	case ColumnTypeFloat:
+		switch v.(type) {
+		case float32, float64:
+			return true
+		}
+	case ColumnTypeNumeric:
+		switch v.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, pgtype.Numeric:
+			return true
+		}
+	case ColumnTypeText:
+		_, ok := v.(string)
+		return ok
+	case ColumnTypeBool:
+		_, ok := v.(bool)
+		return ok
+	case ColumnTypeTimestamp:
+		_, ok := v.(time.Time)
+		return ok
+	case ColumnTypeJSON:
+		switch v.(type) {
+		case map[string]any, []any, string, float64, bool:
+			return true
+		}
+	case ColumnTypeAny:
+		return true
+	}
+	return false
+}
+
+// SchemaMismatches returns how the fetched rows differ from the declared column attributes and gauge columns:
+// declared columns not fetched, fetched columns not declared, values of another type and gauge columns not fetched
+func (m Metric) SchemaMismatches(data Measurements) (mismatches []string) {
+	if len(data) == 0 || len(m.ColumnAttrs) == 0 && (len(m.Gauges) == 0 || m.Gauges[0] == "*") {
+		return nil
+	}
+	fetched := data[0]
+	for col, attr := range m.ColumnAttrs {
+		typ := cmp.Or(attr.Type, ColumnTypeAny)
+		if attr.Unit > "" && !slices.Contains(columnUnits, attr.Unit) {
+			mismatches = append(mismatches, fmt.Sprintf("declared column %s has unknown unit %q", col, attr.Unit))
+		}
+		if !slices.Contains(columnTypes, typ) {
+			mismatches = append(mismatches, fmt.Sprintf("declared column %s has unknown type %q", col, typ))
+			continue
+		}
+		if _, ok := fetched[col]; !ok {
+			mismatches = append(mismatches, fmt.Sprintf("declared column %s not fetched", col))
+			continue
+		}
+		for _, row := range data {
+			if !hasColumnType(row[col], typ) {
+				mismatches = append(mismatches, fmt.Sprintf("column %s is %T instead of %s", col, row[col], typ))
+				break
+			}
+		}
+	}
+	if len(m.ColumnAttrs) > 0 {
+		for col := range fetched {
+			if _, ok := m.ColumnAttrs[col]; !ok && col != epochColumnName {
+				mismatches = append(mismatches, fmt.Sprintf("fetched column %s not declared", col))
+			}
+		}
+	}
+	for _, col := range m.Gauges {
+		if _, ok := fetched[col]; !ok && col != "*" {
+			mismatches = append(mismatches, fmt.Sprintf("gauge column %s not fetched", col))
+		}
+	}
+	slices.Sort(mismatches)
+	return mismatches
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestSchemaMismatches(t *testing.T) {
+	data := Measurements{
+		{"epoch_ns": int64(1), "tag_datname": "db1", "numbackends": int32(3), "blk_read_time": 1.5, "stats_reset": time.Now()},
+		{"epoch_ns": int64(1), "tag_datname": "db2", "numbackends": nil, "blk_read_time": int64(2), "stats_reset": nil},
+	}
+	assert.Empty(t, Metric{}.SchemaMismatches(data), "nothing declared")
+	assert.Empty(t, Metric{Gauges: []string{"*"}}.SchemaMismatches(data))
+	assert.Empty(t, Metric{Gauges: []string{"numbackends"}}.SchemaMismatches(data))
+	assert.Empty(t, Metric{Gauges: []string{"numbackends"}}.SchemaMismatches(nil), "no rows to check")
+
+	m := Metric{MetricAttrs: MetricAttrs{ColumnAttrs: ColumnAttrs{
+		"tag_datname":   {Type: ColumnTypeText},
+		"numbackends":   {Type: ColumnTypeInt},
+		"blk_read_time": {Type: ColumnTypeNumeric},
+		"stats_reset":   {Type: ColumnTypeTimestamp},
+	}}}
+	assert.Empty(t, m.SchemaMismatches(data))
+
+	m.ColumnAttrs["blk_read_time"] = ColumnAttr{Type: ColumnTypeFloat}
+	m.ColumnAttrs["xact_commit"] = ColumnAttr{Type: ColumnTypeInt}
+	m.ColumnAttrs["conflicts"] = ColumnAttr{Type: "bigint"}
+	delete(m.ColumnAttrs, "stats_reset")
+	m.Gauges = []string{"numbackends", "blks_hit"}
+	assert.Equal(t, []string{
+		"column blk_read_time is int64 instead of float",
+		"declared column conflicts has unknown type \"bigint\"",
+		"declared column xact_commit not fetched",
+		"fetched column stats_reset not declared",
+		"gauge column blks_hit not fetched",
+	}, m.SchemaMismatches(data))
+}
+
+func TestColumnAttrUnits(t *testing.T) {
+	var m Metric
+	require.NoError(t, yaml.Unmarshal([]byte(`
+column_attrs:
+    tag_datname: text
+    blks_read:
+        type: int
+        unit: bytes
+        scale: 8192
+    blk_read_time: {unit: seconds, scale: 0.001}
+`), &m))
+	assert.Equal(t, ColumnAttrs{
+		"tag_datname":   {Type: ColumnTypeText},
+		"blks_read":     {Type: ColumnTypeInt, Unit: ColumnUnitBytes, Scale: 8192},
+		"blk_read_time": {Unit: ColumnUnitSeconds, Scale: 0.001},
+	}, m.ColumnAttrs)
+	assert.Equal(t, 16384.0, m.ColumnAttrs["blks_read"].Scaled(2))
+	assert.Equal(t, 2.0, m.ColumnAttrs["tag_datname"].Scaled(2), "no scale")
+
+	out, err := yaml.Marshal(m.ColumnAttrs)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "tag_datname: text", "type only attributes keep the short form")
+	b, err := json.Marshal(m.ColumnAttrs)
+	require.NoError(t, err)
+	var fromJSON ColumnAttrs
+	require.NoError(t, json.Unmarshal(b, &fromJSON))
+	assert.Equal(t, m.ColumnAttrs, fromJSON)
+	assert.Contains(t, string(b), `"tag_datname":"text"`)
+
+	data := Measurements{{"tag_datname": "db1", "blks_read": int64(1), "blk_read_time": "1ms"}}
+	assert.Empty(t, m.SchemaMismatches(data), "columns without type accept any value")
+	m.ColumnAttrs["blks_read"] = ColumnAttr{Type: ColumnTypeInt, Unit: "pages"}
+	assert.Equal(t, []string{`declared column blks_read has unknown unit "pages"`}, m.SchemaMismatches(data))
+}
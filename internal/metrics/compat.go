@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// StatViews lists the statistics views worth monitoring with the major version they appeared in.
+// Used to report new server functionality not covered by any metric yet
+var StatViews = map[string]int{
+	"pg_stat_wal":                14,
+	"pg_stat_replication_slots":  14,
+	"pg_stat_subscription_stats": 15,
+	"pg_stat_recovery_prefetch":  15,
+	"pg_stat_io":                 16,
+	"pg_stat_checkpointer":       17,
+	"pg_wait_events":             17,
+}
+
+// CompatibilityReport describes how well the metric definitions cover a server major version
+type CompatibilityReport struct {
+	Version        int      `json:"version"`
+	Sources        []string `json:"sources,omitempty"`
+	MissingMetrics []string `json:"missing_metrics"` // no SQL defined for the version, i.e. server too old
+	UncoveredViews []string `json:"uncovered_views"` // statistics views available on the version but not used by any metric
+}
+
+// CheckCompatibility reports metrics without SQL for the given server major version
+// and statistics views of that version not queried by any metric
+func (defs MetricDefs) CheckCompatibility(version int) CompatibilityReport {
+	report := CompatibilityReport{Version: version, MissingMetrics: []string{}, UncoveredViews: []string{}}
+	sqls := make([]string, 0, len(defs))
+	for name, m := range defs {
+		if len(m.SQLs) == 0 { // special metrics handled in code
+			continue
+		}
+		sql := m.GetSQL(version)
+		if sql == "" {
+			report.MissingMetrics = append(report.MissingMetrics, name)
+			continue
+		}
+		sqls = append(sqls, strings.ToLower(sql))
+	}
+	for view, since := range StatViews {
+		if since > version {
+			continue
+		}
+		usesView := regexp.MustCompile(`\b` + view + `\b`) // pg_stat_wal must not match pg_stat_wal_receiver
+		if !slices.ContainsFunc(sqls, usesView.MatchString) {
+			report.UncoveredViews = append(report.UncoveredViews, view)
+		}
+	}
+	slices.Sort(report.MissingMetrics)
+	slices.Sort(report.UncoveredViews)
+	return report
+}
@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckCompatibility(t *testing.T) {
+	defs := MetricDefs{
+		"wal":          Metric{SQLs: SQLs{11: "select 1", 14: "select * from pg_stat_wal"}},
+		"stat_io":      Metric{SQLs: SQLs{16: "select * from PG_STAT_IO"}},
+		"checkpointer": Metric{SQLs: SQLs{11: "; -- covered by bgwriter", 17: "select * from pg_stat_checkpointer"}},
+		"special":      Metric{},
+	}
+
+	r := defs.CheckCompatibility(13)
+	assert.Equal(t, 13, r.Version)
+	assert.Equal(t, []string{"stat_io"}, r.MissingMetrics)
+	assert.Empty(t, r.UncoveredViews)
+
+	r = defs.CheckCompatibility(16)
+	assert.Empty(t, r.MissingMetrics)
+	assert.Equal(t, []string{"pg_stat_recovery_prefetch", "pg_stat_replication_slots", "pg_stat_subscription_stats"}, r.UncoveredViews)
+
+	r = defs.CheckCompatibility(17)
+	assert.Equal(t, []string{"pg_stat_recovery_prefetch", "pg_stat_replication_slots", "pg_stat_subscription_stats", "pg_wait_events"}, r.UncoveredViews)
+
+	defs = MetricDefs{"wal_receiver": Metric{SQLs: SQLs{11: "select * from pg_stat_wal_receiver"}}}
+	r = defs.CheckCompatibility(14)
+	assert.Contains(t, r.UncoveredViews, "pg_stat_wal", "only whole identifiers are matched")
+}
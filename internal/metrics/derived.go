@@ -0,0 +1,230 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Errors of the derived metric expressions not caused by the definition itself, the column is just not calculated
+var (
+	ErrNoPreviousRow  = errors.New("no previous measurement to compare with")
+	ErrDivisionByZero = errors.New("division by zero")
+)
+
+// Expr is a parsed derived metric expression
+type Expr interface {
+	// Eval calculates the expression for the row, prev is the row of the previous fetch
+	// and seconds the time passed since then, both used by delta() and rate() only
+	Eval(row, prev Measurement, seconds float64) (float64, error)
+}
+
+type (
+	numberExpr float64
+	columnExpr string
+	negExpr    struct{ x Expr }
+	binaryExpr struct {
+		op   byte
+		x, y Expr
+	}
+	deltaExpr struct {
+		x    Expr
+		rate bool
+	}
+)
+
+func (e numberExpr) Eval(Measurement, Measurement, float64) (float64, error) {
+	return float64(e), nil
+}
+
+func (e columnExpr) Eval(row, _ Measurement, _ float64) (float64, error) {
+	v, ok := row[string(e)]
+	if !ok {
+		return 0, fmt.Errorf("column %s not found", string(e))
+	}
+	if f, ok := ToFloat(v); ok {
+		return f, nil
+	}
+	return 0, fmt.Errorf("column %s is not numeric", string(e))
+}
+
+func (e negExpr) Eval(row, prev Measurement, seconds float64) (float64, error) {
+	x, err := e.x.Eval(row, prev, seconds)
+	return -x, err
+}
+
+func (e binaryExpr) Eval(row, prev Measurement, seconds float64) (float64, error) {
+	x, err := e.x.Eval(row, prev, seconds)
+	if err != nil {
+		return 0, err
+	}
+	y, err := e.y.Eval(row, prev, seconds)
+	if err != nil {
+		return 0, err
+	}
+	switch e.op {
+	case '+':
+		return x + y, nil
+	case '-':
+		return x - y, nil
+	case '*':
+		return x * y, nil
+	}
+	if y == 0 {
+		return 0, ErrDivisionByZero
+	}
+	return x / y, nil
+}
+
+func (e deltaExpr) Eval(row, prev Measurement, seconds float64) (float64, error) {
+	if prev == nil {
+		return 0, ErrNoPreviousRow
+	}
+	cur, err := e.x.Eval(row, nil, 0)
+	if err != nil {
+		return 0, err
+	}
+	last, err := e.x.Eval(prev, nil, 0)
+	if err != nil {
+		return 0, err
+	}
+	if !e.rate {
+		return cur - last, nil
+	}
+	if seconds <= 0 {
+		return 0, ErrDivisionByZero
+	}
+	return (cur - last) / seconds, nil
+}
+
+// ToFloat returns the numeric measurement value as float64
+func ToFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// exprParser is a recursive descent parser of the derived metric expressions:
+//
+//	expr   = term { ("+" | "-") term }
+//	term   = factor { ("*" | "/") factor }
+//	factor = number | column | ("delta" | "rate") "(" expr ")" | "(" expr ")" | "-" factor
+type exprParser struct {
+	s   string
+	pos int
+}
+
+// ParseExpr parses the derived metric expression, e.g. "100 * blks_hit / (blks_hit + blks_read)"
+// or "rate(xact_commit + xact_rollback)"
+func ParseExpr(s string) (Expr, error) {
+	p := &exprParser{s: s}
+	e, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpaces(); p.pos < len(p.s) {
+		return nil, fmt.Errorf("unexpected %q at position %d of %q", p.s[p.pos], p.pos, s)
+	}
+	return e, nil
+}
+
+func (p *exprParser) skipSpaces() {
+	for p.pos < len(p.s) && strings.ContainsRune(" \t\n\r", rune(p.s[p.pos])) {
+		p.pos++
+	}
+}
+
+// next returns the next non-space character without consuming it, 0 at the end
+func (p *exprParser) next() byte {
+	if p.skipSpaces(); p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+func (p *exprParser) expr() (Expr, error) {
+	x, err := p.term()
+	for err == nil && (p.next() == '+' || p.next() == '-') {
+		op := p.s[p.pos]
+		p.pos++
+		var y Expr
+		if y, err = p.term(); err == nil {
+			x = binaryExpr{op: op, x: x, y: y}
+		}
+	}
+	return x, err
+}
+
+func (p *exprParser) term() (Expr, error) {
+	x, err := p.factor()
+	for err == nil && (p.next() == '*' || p.next() == '/') {
+		op := p.s[p.pos]
+		p.pos++
+		var y Expr
+		if y, err = p.factor(); err == nil {
+			x = binaryExpr{op: op, x: x, y: y}
+		}
+	}
+	return x, err
+}
+
+func (p *exprParser) factor() (Expr, error) {
+	switch c := p.next(); {
+	case c == 0:
+		return nil, fmt.Errorf("unexpected end of %q", p.s)
+	case c == '-':
+		p.pos++
+		x, err := p.factor()
+		return negExpr{x}, err
+	case c == '(':
+		p.pos++
+		x, err := p.expr()
+		if err == nil {
+			err = p.expect(')')
+		}
+		return x, err
+	case isDigit(c) || c == '.':
+		start := p.pos
+		for p.pos < len(p.s) && (isDigit(p.s[p.pos]) || p.s[p.pos] == '.') {
+			p.pos++
+		}
+		f, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+		return numberExpr(f), err
+	case isIdentChar(c) && c != '$':
+		start := p.pos
+		for p.pos < len(p.s) && isIdentChar(p.s[p.pos]) {
+			p.pos++
+		}
+		name := p.s[start:p.pos]
+		if fn := strings.ToLower(name); (fn == "delta" || fn == "rate") && p.next() == '(' {
+			p.pos++
+			x, err := p.expr()
+			if err == nil {
+				err = p.expect(')')
+			}
+			return deltaExpr{x: x, rate: fn == "rate"}, err
+		}
+		return columnExpr(name), nil
+	default:
+		return nil, fmt.Errorf("unexpected %q at position %d of %q", c, p.pos, p.s)
+	}
+}
+
+func (p *exprParser) expect(c byte) error {
+	if p.next() != c {
+		return fmt.Errorf("expected %q at position %d of %q", c, p.pos, p.s)
+	}
+	p.pos++
+	return nil
+}
@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseExpr(t *testing.T) {
+	row := Measurement{"blks_hit": int64(90), "blks_read": int64(10), "xact_commit": int64(160), "ratio": 0.5, "name": "db1"}
+	prev := Measurement{"blks_hit": int64(50), "blks_read": int64(10), "xact_commit": int64(100)}
+	tests := map[string]float64{
+		"100 * blks_hit / (blks_hit + blks_read)":     90,
+		"blks_hit - blks_read - 10":                   70,
+		"-ratio * 2 + .5":                             -0.5,
+		"delta(xact_commit)":                          60,
+		"RATE(xact_commit)":                           6,
+		"rate(blks_hit) / rate(blks_hit + blks_read)": 1,
+	}
+	for expr, want := range tests {
+		e, err := ParseExpr(expr)
+		if assert.NoError(t, err, expr) {
+			got, err := e.Eval(row, prev, 10)
+			assert.NoError(t, err, expr)
+			assert.InDelta(t, want, got, 1e-9, expr)
+		}
+	}
+
+	for _, expr := range []string{"", "blks_hit +", "(blks_hit", "blks_hit blks_read", "delta(", "blks_hit % 2", "1.2.3"} {
+		_, err := ParseExpr(expr)
+		assert.Error(t, err, expr)
+	}
+
+	e, _ := ParseExpr("rate(xact_commit)")
+	_, err := e.Eval(row, nil, 0)
+	assert.ErrorIs(t, err, ErrNoPreviousRow)
+	_, err = e.Eval(row, prev, 0)
+	assert.ErrorIs(t, err, ErrDivisionByZero)
+	e, _ = ParseExpr("blks_hit / (blks_read - 10)")
+	_, err = e.Eval(row, nil, 0)
+	assert.ErrorIs(t, err, ErrDivisionByZero)
+	e, _ = ParseExpr("missing + name")
+	_, err = e.Eval(row, nil, 0)
+	assert.EqualError(t, err, "column missing not found")
+	e, _ = ParseExpr("name")
+	_, err = e.Eval(row, nil, 0)
+	assert.EqualError(t, err, "column name is not numeric")
+}
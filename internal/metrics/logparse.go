@@ -127,6 +127,7 @@ func ParseLogs(ctx context.Context, conn db.PgxIface, mdb *sources.MonitoredData
 	var err error
 	var firstRun = true
 	var csvlogRegex *regexp.Regexp
+	var serverVersionNum int
 	dbUniqueName := realDbname
 	logger := log.GetLogger(ctx)
 	for { // re-try loop. re-start in case of FS errors or just to refresh host config
@@ -164,6 +165,13 @@ func ParseLogs(ctx context.Context, conn db.PgxIface, mdb *sources.MonitoredData
 			continue
 		}
 
+		serverVersionNum, err = tryDetermineServerVersionNum(ctx, conn)
+		if err != nil {
+			logger.WithError(err).Debugf("[%s] Could not determine server version, temporary files are not attributed to queries", dbUniqueName)
+		}
+		// the default CSV log lines end with query_id as of v14, custom regexes can define the query_id group
+		csvQueryIDs := logsMatchRegex == CSVLogDefaultRegEx && serverVersionNum >= 140000
+
 		if logsMatchRegexPrev != logsMatchRegex { // avoid regex recompile if no changes
 			csvlogRegex, err = regexp.Compile(logsMatchRegex)
 			if err != nil {
@@ -307,6 +315,13 @@ func ParseLogs(ctx context.Context, conn db.PgxIface, mdb *sources.MonitoredData
 				}
 				if realDbname == databaseName {
 					eventCounts[errorSeverity]++
+					if size, ok := parseTempFileSize(line); ok {
+						queryID := result["query_id"]
+						if csvQueryIDs {
+							queryID = parseCSVQueryID(line)
+						}
+						RecordTempFile(mdb.Name, queryID, size)
+					}
 				}
 				eventCountsTotal[errorSeverity]++
 			}
@@ -372,6 +387,13 @@ func tryDetermineLogMessagesLanguage(ctx context.Context, conn db.PgxIface) (str
 	return lc, nil
 }
 
+func tryDetermineServerVersionNum(ctx context.Context, conn db.PgxIface) (int, error) {
+	sql := `select current_setting('server_version_num')::int`
+	var ver int
+	err := conn.QueryRow(ctx, sql).Scan(&ver)
+	return ver, err
+}
+
 func regexMatchesToMap(csvlogRegex *regexp.Regexp, matches []string) map[string]string {
 	result := make(map[string]string)
 	if len(matches) == 0 || csvlogRegex == nil {
@@ -41,19 +41,19 @@ func writeMetricsToPostgres(ctx context.Context, conn db.PgxIface, metricDefs *M
 	}
 	defer func() { _ = tx.Rollback(ctx) }()
 	for metricName, metric := range metricDefs.MetricDefs {
-		_, err = tx.Exec(ctx, `INSERT INTO pgwatch.metric (name, sqls, init_sql, description, node_status, gauges, is_instance_level, storage_name)
-		values ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT (name) 
+		_, err = tx.Exec(ctx, `INSERT INTO pgwatch.metric (name, sqls, init_sql, description, node_status, gauges, is_instance_level, storage_name, attrs)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9) ON CONFLICT (name) 
 		DO UPDATE SET sqls = $2, init_sql = $3, description = $4, node_status = $5, 
-		gauges = $6, is_instance_level = $7, storage_name = $8`,
-			metricName, metric.SQLs, metric.InitSQL, metric.Description, metric.NodeStatus, metric.Gauges, metric.IsInstanceLevel, metric.StorageName)
+		gauges = $6, is_instance_level = $7, storage_name = $8, attrs = $9`,
+			metricName, metric.SQLs, metric.InitSQL, metric.Description, metric.NodeStatus, metric.Gauges, metric.IsInstanceLevel, metric.StorageName, metric.MetricAttrs)
 		if err != nil {
 			return err
 		}
 	}
 	for presetName, preset := range metricDefs.PresetDefs {
-		_, err = tx.Exec(ctx, `INSERT INTO pgwatch.preset (name, description, metrics) 
-		VALUES ($1, $2, $3) ON CONFLICT (name) DO UPDATE SET description = $2, metrics = $3;`,
-			presetName, preset.Description, preset.Metrics)
+		_, err = tx.Exec(ctx, `INSERT INTO pgwatch.preset (name, description, metrics, attrs) 
+		VALUES ($1, $2, $3, $4) ON CONFLICT (name) DO UPDATE SET description = $2, metrics = $3, attrs = $4;`,
+			presetName, preset.Description, preset.Metrics, preset.EnvRestrictions)
 		if err != nil {
 			return err
 		}
@@ -66,7 +66,7 @@ func (dmrw *dbMetricReaderWriter) GetMetrics() (metricDefMapNew *Metrics, err er
 	ctx := dmrw.ctx
 	conn := dmrw.configDb
 	metricDefMapNew = &Metrics{MetricDefs{}, PresetDefs{}}
-	rows, err := conn.Query(ctx, `SELECT name, sqls, init_sql, description, node_status, gauges, is_instance_level, storage_name FROM pgwatch.metric`)
+	rows, err := conn.Query(ctx, `SELECT name, sqls, init_sql, description, node_status, gauges, is_instance_level, storage_name, coalesce(attrs, '{}') FROM pgwatch.metric`)
 	if err != nil {
 		return nil, err
 	}
@@ -74,13 +74,13 @@ func (dmrw *dbMetricReaderWriter) GetMetrics() (metricDefMapNew *Metrics, err er
 	for rows.Next() {
 		metric := Metric{}
 		var name string
-		err = rows.Scan(&name, &metric.SQLs, &metric.InitSQL, &metric.Description, &metric.NodeStatus, &metric.Gauges, &metric.IsInstanceLevel, &metric.StorageName)
+		err = rows.Scan(&name, &metric.SQLs, &metric.InitSQL, &metric.Description, &metric.NodeStatus, &metric.Gauges, &metric.IsInstanceLevel, &metric.StorageName, &metric.MetricAttrs)
 		if err != nil {
 			return nil, err
 		}
 		metricDefMapNew.MetricDefs[name] = metric
 	}
-	rows, err = conn.Query(ctx, `SELECT name, description, metrics FROM pgwatch.preset`)
+	rows, err = conn.Query(ctx, `SELECT name, description, metrics, coalesce(attrs, '{}') FROM pgwatch.preset`)
 	if err != nil {
 		return nil, err
 	}
@@ -88,7 +88,7 @@ func (dmrw *dbMetricReaderWriter) GetMetrics() (metricDefMapNew *Metrics, err er
 	for rows.Next() {
 		preset := Preset{}
 		var name string
-		err = rows.Scan(&name, &preset.Description, &preset.Metrics)
+		err = rows.Scan(&name, &preset.Description, &preset.Metrics, &preset.EnvRestrictions)
 		if err != nil {
 			return nil, err
 		}
@@ -108,10 +108,10 @@ func (dmrw *dbMetricReaderWriter) DeleteMetric(metricName string) error {
 
 func (dmrw *dbMetricReaderWriter) UpdateMetric(metricName string, metric Metric) error {
 	_, err := dmrw.configDb.Exec(dmrw.ctx, `UPDATE pgwatch.metric SET 
-	sqls = $2, init_sql = $3, description = $4, node_status = $5, gauges = $6, is_instance_level = $7, storage_name = $8
+	sqls = $2, init_sql = $3, description = $4, node_status = $5, gauges = $6, is_instance_level = $7, storage_name = $8, attrs = $9
 	WHERE name = $1`,
 		metricName, db.MarshallParamToJSONB(metric.SQLs), metric.InitSQL, metric.Description,
-		metric.NodeStatus, metric.Gauges, metric.IsInstanceLevel, metric.StorageName)
+		metric.NodeStatus, metric.Gauges, metric.IsInstanceLevel, metric.StorageName, db.MarshallParamToJSONB(metric.MetricAttrs))
 	return err
 }
 
@@ -121,8 +121,9 @@ func (dmrw *dbMetricReaderWriter) DeletePreset(presetName string) error {
 }
 
 func (dmrw *dbMetricReaderWriter) UpdatePreset(presetName string, preset Preset) error {
-	sql := `INSERT INTO pgwatch.preset(name, description, metrics) VALUES ($1, $2, $3)
-	ON CONFLICT (name) DO UPDATE SET description = $2, metrics = $3`
-	_, err := dmrw.configDb.Exec(dmrw.ctx, sql, presetName, preset.Description, db.MarshallParamToJSONB(preset.Metrics))
+	sql := `INSERT INTO pgwatch.preset(name, description, metrics, attrs) VALUES ($1, $2, $3, $4)
+	ON CONFLICT (name) DO UPDATE SET description = $2, metrics = $3, attrs = $4`
+	_, err := dmrw.configDb.Exec(dmrw.ctx, sql, presetName, preset.Description,
+		db.MarshallParamToJSONB(preset.Metrics), db.MarshallParamToJSONB(preset.EnvRestrictions))
 	return err
 }
@@ -74,6 +74,38 @@ var migrations func() migrator.Option = func() migrator.Option {
 				return nil
 			},
 		},
+		&migrator.Migration{
+			Name: "00180 Add attrs column to metric table",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, `ALTER TABLE pgwatch.metric ADD COLUMN IF NOT EXISTS attrs jsonb`)
+				return err
+			},
+		},
+		&migrator.Migration{
+			Name: "00181 Add attrs column to preset table",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, `ALTER TABLE pgwatch.preset ADD COLUMN IF NOT EXISTS attrs jsonb`)
+				return err
+			},
+		},
+		&migrator.Migration{
+			Name: "00182 Add cluster_name column to source table",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, `ALTER TABLE pgwatch.source ADD COLUMN IF NOT EXISTS cluster_name text`)
+				return err
+			},
+		},
+		&migrator.Migration{
+			Name: "00183 Add ownership columns to source table",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, `ALTER TABLE pgwatch.source
+					ADD COLUMN IF NOT EXISTS owner text,
+					ADD COLUMN IF NOT EXISTS team text,
+					ADD COLUMN IF NOT EXISTS contact text,
+					ADD COLUMN IF NOT EXISTS notes text`)
+				return err
+			},
+		},
 
 		// adding new migration here, update "pgwatch"."migration" in "postgres_schema.sql"
 		// and "dbapi" variable in main.go!
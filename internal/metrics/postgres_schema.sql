@@ -8,20 +8,25 @@ CREATE TABLE IF NOT EXISTS pgwatch.metric (
 	node_status text,
 	gauges text[],
 	is_instance_level bool NOT NULL DEFAULT FALSE,
-	storage_name text
+	storage_name text,
+	attrs jsonb
 );
 	
 COMMENT ON COlUMN pgwatch.metric.node_status IS 'currently supports `primary` and `standby`';
 COmment on column pgwatch.metric.gauges IS 'comma separated list of gauge metric columns, * if all columns are gauges';
 COMMENT ON COlUMN pgwatch.metric.is_instance_level IS 'if true, the metric is collected only once per monitored instance';
 COMMENT ON COlUMN pgwatch.metric.storage_name IS 'data is stored in the specified table/file/sink target instead of the default one';
+COMMENT ON COlUMN pgwatch.metric.attrs IS 'additional metric attributes, e.g. fallback metric';
 
 CREATE TABLE IF NOT EXISTS pgwatch.preset (
 	name text PRIMARY KEY,
 	description text NOT NULL,
-	metrics jsonb NOT NULL
+	metrics jsonb NOT NULL,
+	attrs jsonb
 );
 
+COMMENT ON COlUMN pgwatch.preset.attrs IS 'additional preset attributes, e.g. execution environment restrictions';
+
 CREATE OR REPLACE FUNCTION pgwatch.update_preset()
 	RETURNS TRIGGER
 	AS $$
@@ -65,6 +70,11 @@ CREATE TABLE IF NOT EXISTS pgwatch.source(
 	only_if_master bool NOT NULL DEFAULT FALSE,
 	preset_config_standby text REFERENCES pgwatch.preset (name),
 	config_standby jsonb,
+	cluster_name text, -- logical HA cluster the source belongs to, stored as the cluster_name tag
+	owner text, -- ownership metadata stored with the configured_dbs sync metric
+	team text,
+	contact text,
+	notes text,
 	CONSTRAINT preset_or_custom_config CHECK (COALESCE(preset_config, config::text) IS NOT NULL AND (preset_config IS NULL OR config IS NULL)),
 	CONSTRAINT preset_or_custom_config_standby CHECK (preset_config_standby IS NULL OR config_standby IS NULL),
 	CHECK (dbtype IN ('postgres', 'pgbouncer', 'postgres-continuous-discovery', 'patroni', 'patroni-continuous-discovery', 'patroni-namespace-discovery', 'pgpool')),
@@ -83,4 +93,8 @@ CREATE TABLE pgwatch.migration(
 INSERT INTO
     pgwatch.migration (id, version)
 VALUES
-    (0,  '00179 Apply metrics migrations for v3');
+    (0,  '00179 Apply metrics migrations for v3'),
+    (1,  '00180 Add attrs column to metric table'),
+    (2,  '00181 Add attrs column to preset table'),
+    (3,  '00182 Add cluster_name column to source table'),
+    (4,  '00183 Add ownership columns to source table');
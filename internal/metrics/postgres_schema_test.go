@@ -18,12 +18,31 @@ func TestMigrate(t *testing.T) {
 
 	conn.ExpectExec(`CREATE TABLE IF NOT EXISTS pgwatch\.migration`).WillReturnResult(pgxmock.NewResult("CREATE", 1))
 	conn.ExpectQuery(`SELECT count`).WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(0))
+	// every migration is applied in its own transaction
 	conn.ExpectBegin()
 	conn.ExpectExec(`INSERT INTO`).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	conn.ExpectCommit()
+	conn.ExpectBegin()
+	conn.ExpectExec(`ALTER TABLE pgwatch\.metric`).WillReturnResult(pgxmock.NewResult("ALTER", 1))
+	conn.ExpectExec(`INSERT INTO`).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	conn.ExpectCommit()
+	conn.ExpectBegin()
+	conn.ExpectExec(`ALTER TABLE pgwatch\.preset`).WillReturnResult(pgxmock.NewResult("ALTER", 1))
+	conn.ExpectExec(`INSERT INTO`).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	conn.ExpectCommit()
+	conn.ExpectBegin()
+	conn.ExpectExec(`ALTER TABLE pgwatch\.source`).WillReturnResult(pgxmock.NewResult("ALTER", 1))
+	conn.ExpectExec(`INSERT INTO`).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	conn.ExpectCommit()
+	conn.ExpectBegin()
+	conn.ExpectExec(`ALTER TABLE pgwatch\.source`).WillReturnResult(pgxmock.NewResult("ALTER", 1))
+	conn.ExpectExec(`INSERT INTO`).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	conn.ExpectCommit()
 
 	dmrw := &dbMetricReaderWriter{ctx, conn}
 	err = dmrw.Migrate()
 	a.NoError(err)
+	a.NoError(conn.ExpectationsWereMet())
 }
 
 func TestNeedsMigration(t *testing.T) {
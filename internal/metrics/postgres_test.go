@@ -49,8 +49,8 @@ func TestNewPostgresMetricReaderWriterConn(t *testing.T) {
 		conn.ExpectBegin()
 		conn.ExpectExec("CREATE SCHEMA IF NOT EXISTS pgwatch").WillReturnResult(pgxmock.NewResult("CREATE", 1))
 		conn.ExpectBegin()
-		conn.ExpectExec(`INSERT.+metric`).WithArgs(AnyArgs(8)...).WillReturnResult(pgxmock.NewResult("INSERT", 1)).Times(uint(metricsCount))
-		conn.ExpectExec(`INSERT.+preset`).WithArgs(AnyArgs(3)...).WillReturnResult(pgxmock.NewResult("INSERT", 1)).Times(uint(presetsCount))
+		conn.ExpectExec(`INSERT.+metric`).WithArgs(AnyArgs(9)...).WillReturnResult(pgxmock.NewResult("INSERT", 1)).Times(uint(metricsCount))
+		conn.ExpectExec(`INSERT.+preset`).WithArgs(AnyArgs(4)...).WillReturnResult(pgxmock.NewResult("INSERT", 1)).Times(uint(presetsCount))
 		conn.ExpectCommit()
 		conn.ExpectCommit()
 		conn.ExpectPing()
@@ -106,7 +106,7 @@ func TestNewPostgresMetricReaderWriterConn(t *testing.T) {
 		conn.ExpectBegin()
 		conn.ExpectExec("CREATE SCHEMA IF NOT EXISTS pgwatch").WillReturnResult(pgxmock.NewResult("CREATE", 1))
 		conn.ExpectBegin()
-		conn.ExpectExec(`INSERT.+metric`).WithArgs(AnyArgs(8)...).WillReturnError(assert.AnError)
+		conn.ExpectExec(`INSERT.+metric`).WithArgs(AnyArgs(9)...).WillReturnError(assert.AnError)
 		conn.ExpectRollback()
 		rw, err := metrics.NewPostgresMetricReaderWriterConn(ctx, conn)
 		a.Error(err)
@@ -119,8 +119,8 @@ func TestNewPostgresMetricReaderWriterConn(t *testing.T) {
 		conn.ExpectBegin()
 		conn.ExpectExec("CREATE SCHEMA IF NOT EXISTS pgwatch").WillReturnResult(pgxmock.NewResult("CREATE", 1))
 		conn.ExpectBegin()
-		conn.ExpectExec(`INSERT.+metric`).WithArgs(AnyArgs(8)...).WillReturnResult(pgxmock.NewResult("INSERT", 1)).Times(uint(metricsCount))
-		conn.ExpectExec(`INSERT.+preset`).WithArgs(AnyArgs(3)...).WillReturnError(assert.AnError)
+		conn.ExpectExec(`INSERT.+metric`).WithArgs(AnyArgs(9)...).WillReturnResult(pgxmock.NewResult("INSERT", 1)).Times(uint(metricsCount))
+		conn.ExpectExec(`INSERT.+preset`).WithArgs(AnyArgs(4)...).WillReturnError(assert.AnError)
 		conn.ExpectRollback()
 		rw, err := metrics.NewPostgresMetricReaderWriterConn(ctx, conn)
 		a.Error(err)
@@ -133,8 +133,8 @@ func TestNewPostgresMetricReaderWriterConn(t *testing.T) {
 		conn.ExpectBegin()
 		conn.ExpectExec("CREATE SCHEMA IF NOT EXISTS pgwatch").WillReturnResult(pgxmock.NewResult("CREATE", 1))
 		conn.ExpectBegin()
-		conn.ExpectExec(`INSERT.+metric`).WithArgs(AnyArgs(8)...).WillReturnResult(pgxmock.NewResult("INSERT", 1)).Times(uint(metricsCount))
-		conn.ExpectExec(`INSERT.+preset`).WithArgs(AnyArgs(3)...).WillReturnResult(pgxmock.NewResult("INSERT", 1)).Times(uint(presetsCount))
+		conn.ExpectExec(`INSERT.+metric`).WithArgs(AnyArgs(9)...).WillReturnResult(pgxmock.NewResult("INSERT", 1)).Times(uint(metricsCount))
+		conn.ExpectExec(`INSERT.+preset`).WithArgs(AnyArgs(4)...).WillReturnResult(pgxmock.NewResult("INSERT", 1)).Times(uint(presetsCount))
 		conn.ExpectCommit().WillReturnError(assert.AnError)
 		conn.ExpectRollback()
 		rw, err := metrics.NewPostgresMetricReaderWriterConn(ctx, conn)
@@ -148,8 +148,8 @@ func TestNewPostgresMetricReaderWriterConn(t *testing.T) {
 		conn.ExpectBegin()
 		conn.ExpectExec("CREATE SCHEMA IF NOT EXISTS pgwatch").WillReturnResult(pgxmock.NewResult("CREATE", 1))
 		conn.ExpectBegin()
-		conn.ExpectExec(`INSERT.+metric`).WithArgs(AnyArgs(8)...).WillReturnResult(pgxmock.NewResult("INSERT", 1)).Times(uint(metricsCount))
-		conn.ExpectExec(`INSERT.+preset`).WithArgs(AnyArgs(3)...).WillReturnResult(pgxmock.NewResult("INSERT", 1)).Times(uint(presetsCount))
+		conn.ExpectExec(`INSERT.+metric`).WithArgs(AnyArgs(9)...).WillReturnResult(pgxmock.NewResult("INSERT", 1)).Times(uint(metricsCount))
+		conn.ExpectExec(`INSERT.+preset`).WithArgs(AnyArgs(4)...).WillReturnResult(pgxmock.NewResult("INSERT", 1)).Times(uint(presetsCount))
 		conn.ExpectCommit()
 		conn.ExpectCommit().WillReturnError(assert.AnError)
 		rw, err := metrics.NewPostgresMetricReaderWriterConn(ctx, conn)
@@ -173,12 +173,12 @@ func TestMetricsToPostgres(t *testing.T) {
 	a.NotNil(readerWriter)
 
 	metricsRows := func() *pgxmock.Rows {
-		return pgxmock.NewRows([]string{"name", "sqls", "init_sql", "description", "node_status", "gauges", "is_instance_level", "storage_name"}).
-			AddRow("test", metrics.SQLs{11: "select"}, "init", "desc", "primary", []string{"*"}, true, "storage")
+		return pgxmock.NewRows([]string{"name", "sqls", "init_sql", "description", "node_status", "gauges", "is_instance_level", "storage_name", "attrs"}).
+			AddRow("test", metrics.SQLs{11: "select"}, "init", "desc", "primary", []string{"*"}, true, "storage", metrics.MetricAttrs{})
 	}
 	presetRows := func() *pgxmock.Rows {
-		return pgxmock.NewRows([]string{"name", "description", "metrics"}).
-			AddRow("test", "desc", map[string]float64{"metric": 30})
+		return pgxmock.NewRows([]string{"name", "description", "metrics", "attrs"}).
+			AddRow("test", "desc", map[string]float64{"metric": 30}, metrics.EnvRestrictions{})
 	}
 
 	t.Run("GetMetrics", func(*testing.T) {
@@ -229,7 +229,7 @@ func TestMetricsToPostgres(t *testing.T) {
 	})
 
 	t.Run("UpdateMetric", func(*testing.T) {
-		conn.ExpectExec(`UPDATE.+metric`).WithArgs(AnyArgs(8)...).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+		conn.ExpectExec(`UPDATE.+metric`).WithArgs(AnyArgs(9)...).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
 		err = readerWriter.UpdateMetric("test", metrics.Metric{})
 		a.NoError(err)
 	})
@@ -241,7 +241,7 @@ func TestMetricsToPostgres(t *testing.T) {
 	})
 
 	t.Run("UpdatePreset", func(*testing.T) {
-		conn.ExpectExec(`INSERT.+preset`).WithArgs(AnyArgs(3)...).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		conn.ExpectExec(`INSERT.+preset`).WithArgs(AnyArgs(4)...).WillReturnResult(pgxmock.NewResult("INSERT", 1))
 		err = readerWriter.UpdatePreset("test", metrics.Preset{})
 		a.NoError(err)
 	})
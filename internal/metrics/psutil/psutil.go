@@ -1,9 +1,10 @@
 package psutil
 
 import (
+	"errors"
 	"math"
 	"os"
-	"path"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -14,6 +15,16 @@ import (
 	"github.com/shirou/gopsutil/v4/mem"
 )
 
+// ErrNotImplemented is returned for the statistics not available on the platform
+var ErrNotImplemented = errors.New("not implemented")
+
+// IsNotSupported returns true if the error means the statistics are not available on the platform or in the
+// environment, e.g. in a sandbox without the /proc files. gopsutil doesn't export its "not implemented yet" error
+func IsNotSupported(err error) bool {
+	return errors.Is(err, ErrNotImplemented) || errors.Is(err, os.ErrNotExist) ||
+		err != nil && strings.Contains(err.Error(), "not implemented yet")
+}
+
 // "cache" of last CPU utilization stats for GetGoPsutilCPU to get more exact results and not having to sleep
 var prevCPULoadTimeStatsLock sync.RWMutex
 var prevCPULoadTimeStats cpu.TimesStat
@@ -173,8 +184,8 @@ func GetGoPsutilDiskPG(DataDirs, TblspaceDirs []map[string]any) ([]map[string]an
 	}
 
 	logDirPath := data[0]["ld"].(string)
-	if !strings.HasPrefix(logDirPath, "/") {
-		logDirPath = path.Join(dataDirPath, logDirPath)
+	if !filepath.IsAbs(logDirPath) {
+		logDirPath = filepath.Join(dataDirPath, logDirPath)
 	}
 	if len(logDirPath) > 0 && CheckFolderExistsAndReadable(logDirPath) { // syslog etc considered out of scope
 		ldDevice, err = GetPathUnderlyingDeviceID(logDirPath)
@@ -200,10 +211,10 @@ func GetGoPsutilDiskPG(DataDirs, TblspaceDirs []map[string]any) ([]map[string]an
 	}
 
 	var walDirPath string
-	if CheckFolderExistsAndReadable(path.Join(dataDirPath, "pg_wal")) {
-		walDirPath = path.Join(dataDirPath, "pg_wal")
-	} else if CheckFolderExistsAndReadable(path.Join(dataDirPath, "pg_xlog")) {
-		walDirPath = path.Join(dataDirPath, "pg_xlog") // < v10
+	if CheckFolderExistsAndReadable(filepath.Join(dataDirPath, "pg_wal")) {
+		walDirPath = filepath.Join(dataDirPath, "pg_wal")
+	} else if CheckFolderExistsAndReadable(filepath.Join(dataDirPath, "pg_xlog")) {
+		walDirPath = filepath.Join(dataDirPath, "pg_xlog") // < v10
 	}
 
 	if len(walDirPath) > 0 {
@@ -1,9 +0,0 @@
-package psutil
-
-import "errors"
-
-var ErrNotImplemented = errors.New("not implemented")
-
-func GetPathUnderlyingDeviceID(path string) (uint64, error) {
-	return 0, ErrNotImplemented
-}
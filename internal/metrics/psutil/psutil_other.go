@@ -0,0 +1,7 @@
+//go:build !unix && !windows
+
+package psutil
+
+func GetPathUnderlyingDeviceID(_ string) (uint64, error) {
+	return 0, ErrNotImplemented
+}
@@ -1,6 +1,10 @@
 package psutil
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -70,3 +74,40 @@ func TestGetLoadAvgLocal(t *testing.T) {
 	resultKeys := maps.Keys(result[0])
 	a.ElementsMatch(resultKeys, expectedKeys)
 }
+
+func TestGetPathUnderlyingDeviceID(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	a.NoError(os.Mkdir(filepath.Join(dir, "pg_wal"), 0700))
+	dataDevice, err := GetPathUnderlyingDeviceID(dir)
+	a.NoError(err)
+	walDevice, err := GetPathUnderlyingDeviceID(filepath.Join(dir, "pg_wal"))
+	a.NoError(err)
+	a.Equal(dataDevice, walDevice, "same file system")
+
+	_, err = GetPathUnderlyingDeviceID(filepath.Join(dir, "missing"))
+	a.Error(err)
+}
+
+func TestGetGoPsutilDiskPG(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	a.NoError(os.Mkdir(filepath.Join(dir, "pg_wal"), 0700))
+	a.NoError(os.Mkdir(filepath.Join(dir, "log"), 0700))
+	result, err := GetGoPsutilDiskPG([]map[string]any{{"dd": dir, "ld": "log"}}, nil)
+	a.NoError(err)
+	a.Len(result, 1, "the log and WAL directories on the data directory file system are not reported")
+	a.Equal("data_directory", result[0]["tag_dir_or_tablespace"])
+}
+
+func TestIsNotSupported(t *testing.T) {
+	a := assert.New(t)
+	a.True(IsNotSupported(ErrNotImplemented))
+	a.True(IsNotSupported(errors.New("not implemented yet")), "gopsutil error")
+	_, err := os.Open("/nonexistent/proc/diskstats")
+	a.True(IsNotSupported(fmt.Errorf("could not read: %w", err)))
+	a.False(IsNotSupported(errors.New("timeout")))
+	a.False(IsNotSupported(nil))
+}
@@ -0,0 +1,21 @@
+//go:build unix
+
+package psutil
+
+import (
+	"os"
+	"syscall"
+)
+
+// GetPathUnderlyingDeviceID returns the device number of the file system the path is on
+func GetPathUnderlyingDeviceID(path string) (uint64, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, ErrNotImplemented
+	}
+	return uint64(stat.Dev), nil // not uint64 on all platforms
+}
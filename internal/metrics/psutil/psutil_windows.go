@@ -1,9 +1,23 @@
 package psutil
 
-import "errors"
+import (
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strings"
+)
 
-var ErrNotImplemented = errors.New("not implemented")
-
-func GetPathUnderlyingDeviceID(_ string) (uint64, error) {
-	return 0, ErrNotImplemented
+// GetPathUnderlyingDeviceID returns an identifier of the volume the path is on, e.g. of C: or \\server\share,
+// as there are no device numbers on Windows
+func GetPathUnderlyingDeviceID(path string) (uint64, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return 0, err
+	}
+	if _, err = os.Stat(abs); err != nil {
+		return 0, err
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(strings.ToUpper(filepath.VolumeName(abs))))
+	return h.Sum64(), nil
 }
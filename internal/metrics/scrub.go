@@ -0,0 +1,163 @@
+package metrics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// scrubHashLength is the number of hex digits kept of the SHA-256 hash of the scrubbed values
+const scrubHashLength = 16
+
+// IsEmpty returns true if no scrubbing is configured
+func (s ScrubRules) IsEmpty() bool {
+	return len(s.StripLiterals) == 0 && len(s.HashColumns) == 0 && len(s.DropColumns) == 0
+}
+
+// Apply scrubs the measurement rows in place according to the rules and returns them
+func (s ScrubRules) Apply(data Measurements) Measurements {
+	if s.IsEmpty() {
+		return data
+	}
+	for _, row := range data {
+		for _, col := range s.StripLiterals {
+			if sql, ok := row[col].(string); ok {
+				row[col] = StripLiterals(sql)
+			}
+		}
+		for _, col := range s.HashColumns {
+			if v, ok := row[col]; ok && v != nil {
+				row[col] = HashValue(v)
+			}
+		}
+		for _, col := range s.DropColumns {
+			delete(row, col)
+		}
+	}
+	return data
+}
+
+// HashValue returns a short stable hash of the value, so that the scrubbed values can still be grouped by
+func HashValue(v any) string {
+	sum := sha256.Sum256([]byte(fmt.Sprint(v)))
+	return hex.EncodeToString(sum[:])[:scrubHashLength]
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// dollarTag returns the opening tag of a dollar-quoted string starting at i, e.g. "$$" or "$body$"
+func dollarTag(sql string, i int) string {
+	for j := i + 1; j < len(sql); j++ {
+		switch c := sql[j]; {
+		case c == '$':
+			return sql[i : j+1]
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80 || j > i+1 && isDigit(c):
+			continue
+		}
+		break
+	}
+	return ""
+}
+
+// StripLiterals replaces the string, dollar-quoted, bit string and numeric literals of the SQL text with "?".
+// Identifiers, quoted identifiers, comments and $n parameter placeholders are kept intact
+func StripLiterals(sql string) string {
+	var b strings.Builder
+	b.Grow(len(sql))
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			b.WriteString(sql[i : i+end])
+			i += end
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				end = len(sql) - i
+			} else {
+				end += 4
+			}
+			b.WriteString(sql[i : i+end])
+			i += end
+		case c == '"':
+			end := i + 1
+			for end < len(sql) && sql[end] != '"' {
+				end++
+			}
+			end = min(end+1, len(sql))
+			b.WriteString(sql[i:end])
+			i = end
+		case c == '\'' || strings.ContainsRune("eEbBxXnN", rune(c)) && i+1 < len(sql) && sql[i+1] == '\'':
+			backslashEscapes := c == 'e' || c == 'E'
+			if c != '\'' {
+				i++
+			}
+			j := i + 1
+			for j < len(sql) {
+				if backslashEscapes && sql[j] == '\\' {
+					j += 2
+					continue
+				}
+				if sql[j] == '\'' {
+					if j+1 < len(sql) && sql[j+1] == '\'' {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			b.WriteByte('?')
+			i = min(j+1, len(sql))
+		case c == '$' && i+1 < len(sql) && isDigit(sql[i+1]):
+			j := i + 1
+			for j < len(sql) && isDigit(sql[j]) {
+				j++
+			}
+			b.WriteString(sql[i:j])
+			i = j
+		case c == '$' && dollarTag(sql, i) > "":
+			tag := dollarTag(sql, i)
+			end := strings.Index(sql[i+len(tag):], tag)
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += len(tag) + end + len(tag)
+			}
+			b.WriteByte('?')
+		case isDigit(c) || c == '.' && i+1 < len(sql) && isDigit(sql[i+1]):
+			j := i
+			for j < len(sql) && (isDigit(sql[j]) || sql[j] == '.' || sql[j] == '_' ||
+				(sql[j] == 'e' || sql[j] == 'E') && j+1 < len(sql) && (isDigit(sql[j+1]) || (sql[j+1] == '-' || sql[j+1] == '+') && j+2 < len(sql) && isDigit(sql[j+2]))) {
+				if sql[j] == 'e' || sql[j] == 'E' {
+					j++ // the exponent sign
+				}
+				j++
+			}
+			b.WriteByte('?')
+			i = j
+		case isIdentChar(c):
+			j := i
+			for j < len(sql) && isIdentChar(sql[j]) {
+				j++
+			}
+			b.WriteString(sql[i:j])
+			i = j
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
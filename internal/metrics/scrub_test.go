@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStripLiterals(t *testing.T) {
+	tests := map[string]string{
+		"select * from users where email = 'john@example.com' and id = 42":  "select * from users where email = ? and id = ?",
+		"select 'it''s', E'a\\'b', B'1010', X'ff', N'name', 1.5e-3, .5":     "select ?, ?, ?, ?, ?, ?, ?",
+		"select $body$ secret 'text' $body$, $$x$$ from t1 where a = $1":    "select ?, ? from t1 where a = $1",
+		`select "col 1", t2.col3 from "Table's" /* it's */ -- don't` + "\n": `select "col 1", t2.col3 from "Table's" /* it's */ -- don't` + "\n",
+		"insert into t values (1, 'unterminated":                            "insert into t values (?, ?",
+		"select price_2023 from t where a in (1,2,3)":                       "select price_2023 from t where a in (?,?,?)",
+	}
+	for sql, want := range tests {
+		assert.Equal(t, want, StripLiterals(sql), sql)
+	}
+}
+
+func TestScrubRulesApply(t *testing.T) {
+	data := Measurements{
+		{"tag_query": "select * from t where name = 'alice'", "usename": "alice", "client_addr": "10.0.0.1", "calls": 5},
+		{"tag_query": nil, "calls": 1},
+	}
+	assert.Equal(t, data, ScrubRules{}.Apply(data), "no rules, no changes")
+
+	rules := ScrubRules{StripLiterals: []string{"tag_query"}, HashColumns: []string{"usename", "tag_query"}, DropColumns: []string{"client_addr"}}
+	data = rules.Apply(data)
+	assert.Equal(t, HashValue("select * from t where name = ?"), data[0]["tag_query"], "literals are stripped before hashing")
+	assert.Len(t, data[0]["usename"], scrubHashLength)
+	assert.NotContains(t, data[0], "client_addr")
+	assert.EqualValues(t, 5, data[0]["calls"])
+	assert.Nil(t, data[1]["tag_query"], "missing values are kept")
+	assert.Equal(t, HashValue("alice"), HashValue("alice"), "hashes are stable")
+}
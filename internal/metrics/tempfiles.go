@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// TempFileUsage sums up the temporary files logged by the server with log_temp_files enabled
+type TempFileUsage struct {
+	Files int64
+	Bytes int64
+}
+
+var (
+	// e.g. `temporary file: path "base/pgsql_tmp/pgsql_tmp1234.0", size 8192`, quotes are doubled in CSV logs
+	tempFileRegex = regexp.MustCompile(`temporary file: path "{1,2}[^"]*"{1,2}, size (\d+)`)
+	// query_id is the last field of the CSV log lines as of Postgres 14
+	csvQueryIDRegex = regexp.MustCompile(`,(-?\d+)\s*$`)
+)
+
+// maxTempFileQueryIDs limits the memory used if the temp_spills metric is not gathered to drain the usage,
+// files of further queries are not attributed
+const maxTempFileQueryIDs = 1000
+
+var tempFiles = make(map[string]map[string]TempFileUsage) // [db1][queryid]=usage, queryid is empty if unknown
+var tempFilesLock sync.Mutex
+
+// parseTempFileSize returns the size of the temporary file from the log line if it's a log_temp_files message.
+// Only English server messages are recognized
+func parseTempFileSize(line string) (int64, bool) {
+	m := tempFileRegex.FindStringSubmatch(line)
+	if m == nil {
+		return 0, false
+	}
+	size, err := strconv.ParseInt(m[1], 10, 64)
+	return size, err == nil
+}
+
+// parseCSVQueryID returns the query_id field of a CSV log line, 0 if not computed
+func parseCSVQueryID(line string) string {
+	if m := csvQueryIDRegex.FindStringSubmatch(line); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// RecordTempFile adds a logged temporary file of the source, the query id is empty or 0 if not known
+func RecordTempFile(dbUnique, queryID string, size int64) {
+	if queryID == "0" {
+		queryID = ""
+	}
+	tempFilesLock.Lock()
+	defer tempFilesLock.Unlock()
+	if tempFiles[dbUnique] == nil {
+		tempFiles[dbUnique] = make(map[string]TempFileUsage)
+	}
+	if _, ok := tempFiles[dbUnique][queryID]; !ok && len(tempFiles[dbUnique]) >= maxTempFileQueryIDs {
+		queryID = ""
+	}
+	usage := tempFiles[dbUnique][queryID]
+	usage.Files++
+	usage.Bytes += size
+	tempFiles[dbUnique][queryID] = usage
+}
+
+// DrainTempFiles returns the temporary files logged for the source by query id since the previous call
+func DrainTempFiles(dbUnique string) map[string]TempFileUsage {
+	tempFilesLock.Lock()
+	defer tempFilesLock.Unlock()
+	usage := tempFiles[dbUnique]
+	delete(tempFiles, dbUnique)
+	return usage
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTempFileSize(t *testing.T) {
+	size, ok := parseTempFileSize(`2024-01-01 10:00:00 UTC [123] LOG:  temporary file: path "base/pgsql_tmp/pgsql_tmp123.0", size 8192`)
+	assert.True(t, ok)
+	assert.Equal(t, int64(8192), size)
+
+	size, ok = parseTempFileSize(`2024-01-01 10:00:00.000 UTC,"postgres","db1",123,,1,,,,,LOG,00000,"temporary file: path ""base/pgsql_tmp/pgsql_tmp123.1"", size 1048576",,,,,,"select 1",,,"psql","client backend",,-4203289117012587711`)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1048576), size)
+
+	_, ok = parseTempFileSize(`LOG:  checkpoint starting: time`)
+	assert.False(t, ok)
+}
+
+func TestParseCSVQueryID(t *testing.T) {
+	assert.Equal(t, "-4203289117012587711", parseCSVQueryID(`LOG,00000,"temporary file",,,,,,"select 1",,,"psql","client backend",,-4203289117012587711`))
+	assert.Equal(t, "0", parseCSVQueryID(`LOG,00000,"temporary file",,,,,,,,,"psql","client backend",,0`))
+	assert.Equal(t, "", parseCSVQueryID(`LOG,00000,"temporary file",,,,,,,,,"psql","client backend",`))
+}
+
+func TestRecordTempFile(t *testing.T) {
+	RecordTempFile("db1", "42", 100)
+	RecordTempFile("db1", "42", 50)
+	RecordTempFile("db1", "0", 10)
+	RecordTempFile("db2", "", 5)
+	assert.Equal(t, map[string]TempFileUsage{"42": {Files: 2, Bytes: 150}, "": {Files: 1, Bytes: 10}}, DrainTempFiles("db1"))
+	assert.Nil(t, DrainTempFiles("db1"), "usage is reset after draining")
+	assert.Equal(t, map[string]TempFileUsage{"": {Files: 1, Bytes: 5}}, DrainTempFiles("db2"))
+
+	for i := range maxTempFileQueryIDs + 10 {
+		RecordTempFile("db1", strconv.Itoa(i+1), 1)
+	}
+	usage := DrainTempFiles("db1")
+	assert.Len(t, usage, maxTempFileQueryIDs+1)
+	assert.Equal(t, TempFileUsage{Files: 10, Bytes: 10}, usage[""], "further queries are not attributed")
+}
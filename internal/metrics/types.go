@@ -1,5 +1,12 @@
 package metrics
 
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
 type (
 	ExtensionInfo struct {
 		ExtName       string `yaml:"ext_name"`
@@ -17,6 +24,85 @@ type (
 		DisabledDays              string               `yaml:"disabled_days,omitempty"`             // Cron style, 0 = Sunday. Ranges allowed: 0,2-4
 		DisableTimes              []string             `yaml:"disabled_times,omitempty"`            // "11:00-13:00"
 		StatementTimeoutSeconds   int64                `yaml:"statement_timeout_seconds,omitempty"` // overrides per monitored DB settings
+		FallbackMetric            string               `yaml:"fallback_metric,omitempty"`           // cheaper metric to fetch instead when one of FallbackWhen conditions is met
+		FallbackWhen              []string             `yaml:"fallback_when,omitempty"`             // "timeout", "error" or "&" joined source conditions, e.g. "env=AZURE_SINGLE&size>1TB"
+		Scrub                     ScrubRules           `yaml:"scrub,omitempty"`                     // applied to the fetched rows before storage
+		IsBulk                    bool                 `yaml:"is_bulk,omitempty"`                   // expensive metric skipped by the overload guard of the source
+		ExemplarColumns           []string             `yaml:"exemplar_columns,omitempty"`          // attached as exemplar labels to the counter samples scraped by Prometheus, e.g. query ids
+		StorageSchema             string               `yaml:"storage_schema,omitempty"`            // Postgres sink schema of the metric table instead of "public", e.g. to place bulk metrics on a cheaper tablespace
+		Storage                   StorageOptions       `yaml:"storage,omitempty"`                   // Postgres sink partition creation options
+		Derived                   DerivedMetrics       `yaml:"derived,omitempty"`                   // metrics calculated from the fetched rows and stored under their own names
+		ColumnAttrs               ColumnAttrs          `yaml:"column_attrs,omitempty"`              // expected columns of the fetched rows, checked on every fetch to catch definition drift
+		RunIfSQL                  string               `yaml:"run_if_sql,omitempty"`                // cheap boolean predicate query, the metric is fetched only if it returns true, e.g. for optional extensions
+		Composite                 Composite            `yaml:"composite,omitempty"`                 // additional statements merged into the rows of the metric query
+		Alerts                    []AlertThreshold     `yaml:"alerts,omitempty"`                    // thresholds of the columns the generated alert rules check
+		EnvRestrictions           `yaml:",inline"`
+	}
+
+	// ScrubRules remove potentially sensitive data, e.g. query texts with personal data, from the measurements.
+	// Columns are named as returned by the metric query, i.e. including the "tag_" prefix
+	ScrubRules struct {
+		StripLiterals []string `yaml:"strip_literals,omitempty"` // SQL text columns to replace the string and numeric literals in with "?"
+		HashColumns   []string `yaml:"hash_columns,omitempty"`   // columns to replace with a hash of the value, applied after the literals stripping
+		DropColumns   []string `yaml:"drop_columns,omitempty"`   // columns not to store at all
+	}
+
+	// StorageOptions control the creation of the metric partitions in the Postgres sink
+	StorageOptions struct {
+		Tablespace string         `yaml:"tablespace,omitempty"`
+		Fillfactor int            `yaml:"fillfactor,omitempty"`
+		Autovacuum map[string]any `yaml:"autovacuum,omitempty"` // storage parameters without the "autovacuum_" prefix, e.g. vacuum_scale_factor: 0.01
+		Unlogged   bool           `yaml:"unlogged,omitempty"`   // for ephemeral data, lost on a crash of the sink
+	}
+
+	// DerivedMetrics are calculated by the collector from each fetched row of the metric, e.g.
+	// db_cache_hit: {hit_ratio: "100 * blks_hit / (blks_hit + blks_read)", tps: "rate(xact_commit + xact_rollback)"}
+	DerivedMetrics map[string]DerivedColumns
+
+	// DerivedColumns map the columns of a derived metric to the expressions calculating them, see ParseExpr
+	DerivedColumns map[string]string
+
+	// ColumnAttrs map the columns returned by the metric query, i.e. including the "tag_" prefix, to their
+	// expected types: int, float, numeric, text, bool, timestamp, json or any, and optionally their units
+	ColumnAttrs map[string]ColumnAttr
+
+	// ColumnAttr describes an output column of the metric query, it can be given as the type only, e.g. "int"
+	ColumnAttr struct {
+		Type  string  `yaml:"type,omitempty"`
+		Unit  string  `yaml:"unit,omitempty"`  // bytes, seconds or count
+		Scale float64 `yaml:"scale,omitempty"` // multiplier converting the fetched values to the unit, e.g. 8192 for blocks or 0.001 for milliseconds
+	}
+
+	// Composite are additional statements of a metric, their rows are merged collector-side into the rows of the
+	// metric query, for insights needing data from several views that can't be queried in one statement on older versions
+	Composite struct {
+		Merge      string               `yaml:"merge,omitempty"` // "join" the rows with the same tag values (default) or "append" them
+		Statements []CompositeStatement `yaml:"statements,omitempty"`
+	}
+
+	// CompositeStatement is an additional statement of a composite metric
+	CompositeStatement struct {
+		Name string `yaml:"name"` // value of the tag_statement column of the appended rows
+		SQLs SQLs   `yaml:"sqls"`
+	}
+
+	// AlertThreshold declares when a gauge column of a metric should raise an alert. The thresholds are in the unit of
+	// the column, i.e. the fetched values multiplied by the scale of its column_attrs
+	AlertThreshold struct {
+		Name     string   `yaml:"name"` // unique within the metric, e.g. sequence_exhaustion
+		Column   string   `yaml:"column"`
+		Operator string   `yaml:"operator,omitempty"` // ">" (default) or "<"
+		Warning  *float64 `yaml:"warning,omitempty"`
+		Critical *float64 `yaml:"critical,omitempty"`
+		For      string   `yaml:"for,omitempty"` // how long the threshold must be crossed before firing, e.g. 5m
+		Summary  string   `yaml:"summary,omitempty"`
+	}
+
+	// EnvRestrictions limit metrics and presets to certain execution environments, e.g. AWS_RDS.
+	// The special CLOUD value matches any detected managed service
+	EnvRestrictions struct {
+		OnlyEnvs     []string `yaml:"only_envs,omitempty"`     // if set, used only in the listed environments
+		ExcludedEnvs []string `yaml:"excluded_envs,omitempty"` // never used in the listed environments
 	}
 
 	SQLs map[int]string
@@ -29,6 +115,7 @@ type (
 		IsInstanceLevel bool     `yaml:"is_instance_level,omitempty"`
 		StorageName     string   `yaml:"storage_name,omitempty"`
 		Description     string   `yaml:"description,omitempty"`
+		MetricAttrs     `yaml:",inline"`
 	}
 
 	MetricDefs map[string]Metric
@@ -39,6 +126,92 @@ type (
 	}
 )
 
+// Merge modes and the statement tag of the composite metrics
+const (
+	CompositeMergeJoin     = "join"
+	CompositeMergeAppend   = "append"
+	CompositeStatementTag  = "tag_statement"
+	CompositeMainStatement = "main" // statement tag of the rows of the metric query
+)
+
+const (
+	ExecEnvUnknown = "UNKNOWN"
+	ExecEnvCloud   = "CLOUD"
+)
+
+func matchesEnv(envs []string, execEnv string) bool {
+	return slices.ContainsFunc(envs, func(env string) bool {
+		return env == execEnv || env == ExecEnvCloud && execEnv > "" && execEnv != ExecEnvUnknown
+	})
+}
+
+// AllowsEnv returns true if the metric or preset can be used in the given execution environment
+func (r EnvRestrictions) AllowsEnv(execEnv string) bool {
+	if len(r.OnlyEnvs) > 0 && !matchesEnv(r.OnlyEnvs, execEnv) {
+		return false
+	}
+	return !matchesEnv(r.ExcludedEnvs, execEnv)
+}
+
+const (
+	FallbackWhenTimeout    = "timeout"
+	FallbackWhenError      = "error"
+	fallbackWhenEnvPrefix  = "env="
+	fallbackWhenSizePrefix = "size>"
+)
+
+var sizeUnits = map[string]int64{"": 1, "KB": 1e3, "MB": 1e6, "GB": 1e9, "TB": 1e12}
+
+// parseSize parses sizes like "500GB" or "1TB" into bytes
+func parseSize(s string) (int64, bool) {
+	num := strings.TrimRightFunc(s, unicode.IsLetter)
+	unit, ok := sizeUnits[strings.ToUpper(s[len(num):])]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	return n * unit, err == nil
+}
+
+// matchesSource returns true if all "&" joined terms of the condition hold for the source
+func matchesSource(cond, execEnv string, dbSizeB int64) bool {
+	for _, term := range strings.Split(cond, "&") {
+		term = strings.TrimSpace(term)
+		switch {
+		case strings.HasPrefix(term, fallbackWhenEnvPrefix):
+			if execEnv == "" || term[len(fallbackWhenEnvPrefix):] != execEnv {
+				return false
+			}
+		case strings.HasPrefix(term, fallbackWhenSizePrefix):
+			if limit, ok := parseSize(term[len(fallbackWhenSizePrefix):]); !ok || dbSizeB <= limit {
+				return false
+			}
+		default: // "timeout" and "error" only apply after a failed fetch
+			return false
+		}
+	}
+	return true
+}
+
+// IsEmpty returns true if the partitions are created with the defaults
+func (o StorageOptions) IsEmpty() bool {
+	return o.Tablespace == "" && o.Fillfactor == 0 && len(o.Autovacuum) == 0 && !o.Unlogged
+}
+
+// FallbackOnSource returns true if the fallback metric should always be used for a source
+// with the given execution environment and approximate database size
+func (m MetricAttrs) FallbackOnSource(execEnv string, dbSizeB int64) bool {
+	return m.FallbackMetric > "" && slices.ContainsFunc(m.FallbackWhen, func(cond string) bool {
+		return matchesSource(cond, execEnv, dbSizeB)
+	})
+}
+
+// FallbackOnError returns true if the fallback metric should be fetched after the metric query failed
+func (m MetricAttrs) FallbackOnError(isTimeout bool) bool {
+	return m.FallbackMetric > "" && (slices.Contains(m.FallbackWhen, FallbackWhenError) ||
+		isTimeout && slices.Contains(m.FallbackWhen, FallbackWhenTimeout))
+}
+
 func (m Metric) PrimaryOnly() bool {
 	return m.NodeStatus == "primary"
 }
@@ -48,26 +221,32 @@ func (m Metric) StandbyOnly() bool {
 }
 
 func (m Metric) GetSQL(version int) string {
+	return m.SQLs.Get(version)
+}
+
+// Get returns the SQL of the version or of the closest lower version
+func (s SQLs) Get(version int) string {
 	// Check if there's an exact match for i
-	if val, ok := m.SQLs[version]; ok {
+	if val, ok := s[version]; ok {
 		return val
 	}
 
 	// Find the closest value less than version
 	var closestVersion int
-	for v := range m.SQLs {
+	for v := range s {
 		if v < version && (closestVersion == 0 || v > closestVersion) {
 			closestVersion = v
 		}
 	}
-	return m.SQLs[closestVersion]
+	return s[closestVersion]
 }
 
 type PresetDefs map[string]Preset
 
 type Preset struct {
-	Description string
-	Metrics     map[string]float64
+	Description     string
+	Metrics         map[string]float64
+	EnvRestrictions `yaml:",inline"`
 }
 
 type Measurement map[string]any
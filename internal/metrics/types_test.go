@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
 )
 
 func TestGetSQL(t *testing.T) {
@@ -38,3 +39,87 @@ func TestPrimaryOnly(t *testing.T) {
 	assert.False(t, m.PrimaryOnly())
 	assert.True(t, m.StandbyOnly())
 }
+
+func TestFallback(t *testing.T) {
+	m := Metric{}
+	assert.False(t, m.FallbackOnSource("AZURE_SINGLE", 0))
+	assert.False(t, m.FallbackOnError(true))
+
+	m.FallbackMetric = "cheap"
+	m.FallbackWhen = []string{"timeout", "env=AZURE_SINGLE"}
+	assert.True(t, m.FallbackOnSource("AZURE_SINGLE", 0))
+	assert.False(t, m.FallbackOnSource("GOOGLE", 0))
+	assert.False(t, m.FallbackOnSource("", 0))
+	assert.True(t, m.FallbackOnError(true))
+	assert.False(t, m.FallbackOnError(false))
+
+	m.FallbackWhen = []string{"env=AZURE_SINGLE & size>1TB"}
+	assert.False(t, m.FallbackOnSource("AZURE_SINGLE", 1e9))
+	assert.True(t, m.FallbackOnSource("AZURE_SINGLE", 2e12))
+	assert.False(t, m.FallbackOnSource("GOOGLE", 2e12))
+
+	m.FallbackWhen = []string{"size>500gb", "size>x"}
+	assert.True(t, m.FallbackOnSource("", 6e11))
+	assert.False(t, m.FallbackOnSource("", 4e11))
+
+	m.FallbackWhen = []string{"error"}
+	assert.True(t, m.FallbackOnError(true))
+	assert.True(t, m.FallbackOnError(false))
+}
+
+func TestAllowsEnv(t *testing.T) {
+	m := Metric{}
+	assert.True(t, m.AllowsEnv(""))
+	assert.True(t, m.AllowsEnv("AWS_RDS"))
+
+	m.ExcludedEnvs = []string{ExecEnvCloud}
+	assert.True(t, m.AllowsEnv(""))
+	assert.True(t, m.AllowsEnv(ExecEnvUnknown))
+	assert.False(t, m.AllowsEnv("AWS_RDS"))
+	assert.False(t, m.AllowsEnv("NEON"))
+
+	p := Preset{EnvRestrictions: EnvRestrictions{OnlyEnvs: []string{"AWS_AURORA"}}}
+	assert.True(t, p.AllowsEnv("AWS_AURORA"))
+	assert.False(t, p.AllowsEnv("AWS_RDS"))
+	assert.False(t, p.AllowsEnv(""))
+
+	p.ExcludedEnvs = []string{"AWS_AURORA"}
+	assert.False(t, p.AllowsEnv("AWS_AURORA"), "exclusions should win")
+}
+
+func TestStorageOptions(t *testing.T) {
+	var m Metric
+	assert.True(t, m.Storage.IsEmpty())
+	err := yaml.Unmarshal([]byte(`
+storage:
+    tablespace: cheap
+    fillfactor: 100
+    autovacuum:
+        vacuum_scale_factor: 0.01
+`), &m)
+	assert.NoError(t, err)
+	assert.False(t, m.Storage.IsEmpty())
+	assert.Equal(t, "cheap", m.Storage.Tablespace)
+	assert.Equal(t, 100, m.Storage.Fillfactor)
+	assert.Equal(t, 0.01, m.Storage.Autovacuum["vacuum_scale_factor"])
+}
+
+func TestComposite(t *testing.T) {
+	var m Metric
+	err := yaml.Unmarshal([]byte(`
+composite:
+    merge: append
+    statements:
+        - name: locks
+          sqls:
+              11: select 11
+              14: select 14
+`), &m)
+	assert.NoError(t, err)
+	assert.Equal(t, CompositeMergeAppend, m.Composite.Merge)
+	if assert.Len(t, m.Composite.Statements, 1) {
+		assert.Equal(t, "locks", m.Composite.Statements[0].Name)
+		assert.Equal(t, "select 11", m.Composite.Statements[0].SQLs.Get(13))
+		assert.Equal(t, "select 14", m.Composite.Statements[0].SQLs.Get(16))
+	}
+}
@@ -0,0 +1,34 @@
+package reaper
+
+import (
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+// alignedTick returns the interval boundary, counted from the Unix epoch, the time belongs to
+func alignedTick(t time.Time, interval time.Duration) time.Time {
+	if interval <= 0 {
+		return t
+	}
+	ns := t.UnixNano()
+	return time.Unix(0, ns-ns%int64(interval))
+}
+
+// untilNextTick returns the time left till the next interval boundary
+func untilNextTick(now time.Time, interval time.Duration) time.Duration {
+	if interval <= 0 {
+		return interval
+	}
+	return alignedTick(now, interval).Add(interval).Sub(now)
+}
+
+// alignTimestamps replaces the fetch time of all rows with the scheduled tick time, so that the
+// measurements of different sources are comparable regardless of the scheduling drift and fetch duration
+func alignTimestamps(msgs []metrics.MeasurementEnvelope, tick time.Time) {
+	for _, msg := range msgs {
+		for _, row := range msg.Data {
+			row[epochColumnName] = tick.UnixNano()
+		}
+	}
+}
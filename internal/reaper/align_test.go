@@ -0,0 +1,33 @@
+package reaper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlignedTick(t *testing.T) {
+	ts := time.Date(2025, 1, 1, 12, 0, 7, 350_000_000, time.UTC)
+	assert.True(t, time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC).Equal(alignedTick(ts, time.Minute)))
+	assert.True(t, time.Date(2025, 1, 1, 12, 0, 5, 0, time.UTC).Equal(alignedTick(ts, 5*time.Second)))
+	assert.True(t, ts.Equal(alignedTick(ts, 0)))
+
+	assert.Equal(t, 2650*time.Millisecond, untilNextTick(ts, 5*time.Second))
+	assert.Equal(t, 52650*time.Millisecond, untilNextTick(ts, time.Minute))
+	assert.Equal(t, time.Minute, untilNextTick(alignedTick(ts, time.Minute), time.Minute), "on the boundary the next one is due")
+}
+
+func TestAlignTimestamps(t *testing.T) {
+	tick := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	msgs := []metrics.MeasurementEnvelope{{Data: metrics.Measurements{
+		{epochColumnName: tick.Add(1234 * time.Millisecond).UnixNano(), "value": 1},
+		{"value": 2},
+	}}}
+	alignTimestamps(msgs, tick)
+	for _, row := range msgs[0].Data {
+		assert.Equal(t, tick.UnixNano(), row[epochColumnName])
+	}
+	assert.Equal(t, 1, msgs[0].Data[0]["value"])
+}
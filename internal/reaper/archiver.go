@@ -0,0 +1,44 @@
+package reaper
+
+import (
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+const archiverMetric = "archiver"
+
+// ArchiverStuckDetector follows the consecutive fetches of the archiver metric to detect stuck WAL archiving,
+// i.e. failing or hanging archive_command while WAL segments are waiting to be archived. It works without any
+// helpers or privileges as only pg_stat_archiver and the current WAL position are needed
+type ArchiverStuckDetector struct {
+	archivedCount int64     // at the last observed archiving progress
+	archivedLSN   int64     // current WAL position at the last observed archiving progress
+	stuckSince    time.Time // zero if archiving is progressing
+	initialized   bool
+}
+
+// Detect adds the stuck_seconds and is_stuck_int columns to the archiver row. Archiving is considered stuck since
+// the first fetch after the last archiving progress where it's failing or a whole WAL segment was written since
+func (d *ArchiverStuckDetector) Detect(row metrics.Measurement, now time.Time, threshold time.Duration) {
+	archived, _ := row["archived_count"].(int64)
+	lsn, hasLSN := row["current_wal_lsn_b"].(int64)
+	segmentSize, _ := row["wal_segment_size_b"].(int64)
+	failing, _ := metrics.ToFloat(row["is_failing_int"])
+	if !d.initialized || archived != d.archivedCount { // first fetch, progress or stats reset
+		*d = ArchiverStuckDetector{archivedCount: archived, archivedLSN: lsn, initialized: true}
+	}
+	pending := hasLSN && segmentSize > 0 && lsn-d.archivedLSN >= segmentSize
+	if d.stuckSince.IsZero() && (failing > 0 || pending) {
+		d.stuckSince = now
+	}
+	var stuck time.Duration
+	if !d.stuckSince.IsZero() {
+		stuck = now.Sub(d.stuckSince)
+	}
+	row["stuck_seconds"] = int64(stuck.Seconds())
+	row["is_stuck_int"] = 0
+	if threshold > 0 && !d.stuckSince.IsZero() && stuck >= threshold {
+		row["is_stuck_int"] = 1
+	}
+}
@@ -0,0 +1,50 @@
+package reaper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchiverStuckDetector(t *testing.T) {
+	var d ArchiverStuckDetector
+	now := time.Now()
+	threshold := 5 * time.Minute
+	const segment = int64(16 << 20)
+	fetch := func(archived, lsn int64, failing int, at time.Duration) metrics.Measurement {
+		row := metrics.Measurement{"archived_count": archived, "current_wal_lsn_b": lsn, "wal_segment_size_b": segment, "is_failing_int": failing}
+		d.Detect(row, now.Add(at), threshold)
+		return row
+	}
+
+	row := fetch(10, 100, 0, 0)
+	assert.Equal(t, int64(0), row["stuck_seconds"])
+	assert.Equal(t, 0, row["is_stuck_int"])
+
+	row = fetch(10, 100+segment/2, 0, time.Minute)
+	assert.Equal(t, int64(0), row["stuck_seconds"], "no complete segment to archive yet")
+
+	row = fetch(10, 100+segment, 0, 2*time.Minute)
+	assert.Equal(t, int64(0), row["stuck_seconds"], "pending segment, stuck from now on")
+	row = fetch(10, 100+2*segment, 0, 6*time.Minute)
+	assert.Equal(t, int64(240), row["stuck_seconds"])
+	assert.Equal(t, 0, row["is_stuck_int"])
+	row = fetch(10, 100+2*segment, 0, 7*time.Minute)
+	assert.Equal(t, int64(300), row["stuck_seconds"])
+	assert.Equal(t, 1, row["is_stuck_int"])
+
+	row = fetch(12, 100+2*segment, 0, 8*time.Minute)
+	assert.Equal(t, int64(0), row["stuck_seconds"], "archiving progressed")
+	assert.Equal(t, 0, row["is_stuck_int"])
+
+	fetch(12, 100+2*segment, 1, 9*time.Minute)
+	row = fetch(12, 100+2*segment, 1, 15*time.Minute)
+	assert.Equal(t, 1, row["is_stuck_int"], "failing without WAL position, e.g. on a standby")
+
+	row = metrics.Measurement{"archived_count": int64(12), "is_failing_int": 1}
+	d.Detect(row, now.Add(16*time.Minute), 0)
+	assert.Equal(t, int64(420), row["stuck_seconds"])
+	assert.Equal(t, 0, row["is_stuck_int"], "disabled threshold")
+}
@@ -0,0 +1,104 @@
+package reaper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/db"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/jackc/pgx/v5"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// AuditRecord describes a single statement executed on a monitored DB
+type AuditRecord struct {
+	Time       time.Time `json:"time"`
+	Source     string    `json:"source"`
+	Component  string    `json:"component"`
+	Metric     string    `json:"metric,omitempty"`
+	SQLHash    string    `json:"sql_hash"`
+	DurationMs float64   `json:"duration_ms"`
+	Rows       int64     `json:"rows"`
+	Error      string    `json:"error,omitempty"`
+}
+
+const defaultQueryComponent = "reaper"
+
+type queryOriginKey struct{}
+
+type queryOrigin struct {
+	component string
+	metric    string
+}
+
+// WithQueryOrigin marks the queries executed with the context as initiated by the pgwatch component, e.g. gatherer
+func WithQueryOrigin(ctx context.Context, component, metric string) context.Context {
+	return context.WithValue(ctx, queryOriginKey{}, queryOrigin{component: component, metric: metric})
+}
+
+// queryMetric returns the metric the queries executed with the context are fetching, empty if none
+func queryMetric(ctx context.Context) string {
+	origin, _ := ctx.Value(queryOriginKey{}).(queryOrigin)
+	return origin.metric
+}
+
+// metricApplicationName returns the application_name of the metric queries, e.g. pgwatch:db_stats
+func metricApplicationName(metric string) string {
+	return db.ApplicationName + ":" + metric
+}
+
+var auditLog io.Writer // nil if auditing is disabled
+var auditLogLock sync.Mutex
+
+// InitAuditLog enables the audit of all statements sent to monitored DBs if the audit log file is set
+func InitAuditLog(opts sources.CmdOpts) {
+	auditLogLock.Lock()
+	defer auditLogLock.Unlock()
+	if opts.AuditLog == "" {
+		auditLog = nil
+		return
+	}
+	auditLog = &lumberjack.Logger{
+		Filename:   opts.AuditLog,
+		MaxSize:    opts.AuditLogSize,
+		MaxBackups: opts.AuditLogNumber,
+	}
+}
+
+// hashSQL returns the statement fingerprint stored in the audit log instead of the statement itself
+func hashSQL(sql string) string {
+	h := sha256.Sum256([]byte(sql))
+	return hex.EncodeToString(h[:8])
+}
+
+// AuditQuery writes the executed statement to the audit log if enabled
+func AuditQuery(ctx context.Context, dbUnique, sql string, duration time.Duration, data pgx.TraceQueryEndData) {
+	auditLogLock.Lock()
+	defer auditLogLock.Unlock()
+	if auditLog == nil {
+		return
+	}
+	origin, ok := ctx.Value(queryOriginKey{}).(queryOrigin)
+	if !ok {
+		origin.component = defaultQueryComponent
+	}
+	rec := AuditRecord{
+		Time:       time.Now(),
+		Source:     dbUnique,
+		Component:  origin.component,
+		Metric:     origin.metric,
+		SQLHash:    hashSQL(sql),
+		DurationMs: float64(duration.Microseconds()) / 1000,
+		Rows:       data.CommandTag.RowsAffected(),
+	}
+	if data.Err != nil {
+		rec.Error = data.Err.Error()
+	}
+	b, _ := json.Marshal(rec)
+	_, _ = auditLog.Write(append(b, '\n'))
+}
@@ -0,0 +1,52 @@
+package reaper
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditQuery(t *testing.T) {
+	AuditQuery(context.Background(), "audit_db", "select 1", time.Second, pgx.TraceQueryEndData{}) // disabled, noop
+
+	file := filepath.Join(t.TempDir(), "audit.log")
+	InitAuditLog(sources.CmdOpts{AuditLog: file, AuditLogSize: 1, AuditLogNumber: 1})
+	defer InitAuditLog(sources.CmdOpts{})
+
+	ctx := WithQueryOrigin(context.Background(), "gatherer", "db_stats")
+	AuditQuery(ctx, "audit_db", "select * from pg_stat_database", 1500*time.Microsecond,
+		pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("SELECT 3")})
+	AuditQuery(context.Background(), "audit_db", "select 1", time.Millisecond,
+		pgx.TraceQueryEndData{Err: errors.New("canceled")})
+
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer f.Close()
+	var recs []AuditRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec AuditRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &rec))
+		recs = append(recs, rec)
+	}
+	require.Len(t, recs, 2)
+	assert.Equal(t, "audit_db", recs[0].Source)
+	assert.Equal(t, "gatherer", recs[0].Component)
+	assert.Equal(t, "db_stats", recs[0].Metric)
+	assert.Equal(t, hashSQL("select * from pg_stat_database"), recs[0].SQLHash)
+	assert.Equal(t, 1.5, recs[0].DurationMs)
+	assert.Equal(t, int64(3), recs[0].Rows)
+	assert.Equal(t, defaultQueryComponent, recs[1].Component)
+	assert.Equal(t, "canceled", recs[1].Error)
+}
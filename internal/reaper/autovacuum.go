@@ -0,0 +1,80 @@
+package reaper
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+const specialMetricAutovacuumHealth = "autovacuum_health"
+
+// sqlTablesOverVacuumThreshold returns the tables having more dead tuples than needed to trigger an autovacuum,
+// also considering the per table storage parameters
+const sqlTablesOverVacuumThreshold = `select /* pgwatch_generated */
+  s.relid::int8 as relid,
+  s.n_dead_tup::int8 as n_dead_tup,
+  extract(epoch from coalesce(greatest(s.last_autovacuum, s.last_vacuum), '1970-01-01'::timestamptz))::int8 as last_vacuum_s,
+  exists (select 1 from pg_stat_progress_vacuum p where p.relid = s.relid) as vacuum_running
+from
+  pg_stat_user_tables s
+  join pg_class c on c.oid = s.relid
+where
+  s.n_dead_tup > coalesce((select option_value::int8 from pg_options_to_table(c.reloptions) where option_name = 'autovacuum_vacuum_threshold'),
+      current_setting('autovacuum_vacuum_threshold')::int8)
+    + coalesce((select option_value::float8 from pg_options_to_table(c.reloptions) where option_name = 'autovacuum_vacuum_scale_factor'),
+      current_setting('autovacuum_vacuum_scale_factor')::float8) * greatest(c.reltuples, 0)`
+
+// vacuumBacklog is a table over the autovacuum threshold since the given time
+type vacuumBacklog struct {
+	since      time.Time
+	lastVacuum int64
+}
+
+var vacuumBacklogs = make(map[string]map[int64]vacuumBacklog) // [db1][relid]=backlog
+var vacuumBacklogsLock sync.Mutex
+
+// FetchAutovacuumHealth samples the tables over the autovacuum threshold and returns the starvation indicators
+// of the database. A table is starving if it's over the threshold for at least starvationThreshold without being
+// vacuumed in the meantime, as the state is kept by pgwatch the durations only cover the time since its start
+func FetchAutovacuumHealth(ctx context.Context, dbUnique string, now time.Time, starvationThreshold time.Duration) (metrics.Measurements, error) {
+	data, err := DBExecReadByDbUniqueName(ctx, dbUnique, sqlTablesOverVacuumThreshold)
+	if err != nil {
+		return nil, err
+	}
+	vacuumBacklogsLock.Lock()
+	defer vacuumBacklogsLock.Unlock()
+	prev := vacuumBacklogs[dbUnique]
+	backlogs := make(map[int64]vacuumBacklog, len(data))
+	var deadTuples, running, starving, maxStarvation int64
+	for _, row := range data {
+		relid, _ := row["relid"].(int64)
+		lastVacuum, _ := row["last_vacuum_s"].(int64)
+		dead, _ := row["n_dead_tup"].(int64)
+		isRunning, _ := row["vacuum_running"].(bool)
+		backlog, ok := prev[relid]
+		if !ok || backlog.lastVacuum != lastVacuum {
+			backlog = vacuumBacklog{since: now, lastVacuum: lastVacuum}
+		}
+		backlogs[relid] = backlog
+		deadTuples += dead
+		if isRunning {
+			running++
+			continue
+		}
+		if waiting := now.Sub(backlog.since); starvationThreshold > 0 && waiting >= starvationThreshold {
+			starving++
+			maxStarvation = max(maxStarvation, int64(waiting.Seconds()))
+		}
+	}
+	vacuumBacklogs[dbUnique] = backlogs
+	return metrics.Measurements{{
+		epochColumnName:           now.UnixNano(),
+		"tables_over_threshold":   int64(len(data)),
+		"dead_tup_over_threshold": deadTuples,
+		"tables_being_vacuumed":   running,
+		"starving_tables":         starving,
+		"max_starvation_seconds":  maxStarvation,
+	}}, nil
+}
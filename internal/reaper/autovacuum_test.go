@@ -0,0 +1,56 @@
+package reaper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchAutovacuumHealth(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer conn.Close()
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{{Source: sources.Source{Name: "db1", Kind: sources.SourcePostgres}, Conn: conn}})
+	defer UpdateMonitoredDBCache(nil)
+	ctx := context.Background()
+	now := time.Now()
+	cols := []string{"relid", "n_dead_tup", "last_vacuum_s", "vacuum_running"}
+
+	expectGuardQuery(conn, "from pg_stat_user_tables", pgxmock.NewRows(cols).
+		AddRow(int64(1), int64(1000), int64(0), false).
+		AddRow(int64(2), int64(500), int64(100), true))
+	data, err := FetchAutovacuumHealth(ctx, "db1", now, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), data[0]["tables_over_threshold"])
+	assert.Equal(t, int64(1500), data[0]["dead_tup_over_threshold"])
+	assert.Equal(t, int64(1), data[0]["tables_being_vacuumed"])
+	assert.Equal(t, int64(0), data[0]["starving_tables"])
+
+	expectGuardQuery(conn, "from pg_stat_user_tables", pgxmock.NewRows(cols).
+		AddRow(int64(1), int64(2000), int64(0), false).
+		AddRow(int64(2), int64(600), int64(100), false).
+		AddRow(int64(3), int64(100), int64(50), false))
+	data, err = FetchAutovacuumHealth(ctx, "db1", now.Add(90*time.Minute), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), data[0]["starving_tables"], "new table 3 is not starving yet")
+	assert.Equal(t, int64(5400), data[0]["max_starvation_seconds"])
+
+	expectGuardQuery(conn, "from pg_stat_user_tables", pgxmock.NewRows(cols).
+		AddRow(int64(1), int64(2000), int64(200), false).
+		AddRow(int64(3), int64(100), int64(50), false))
+	data, err = FetchAutovacuumHealth(ctx, "db1", now.Add(3*time.Hour), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), data[0]["starving_tables"], "table 1 vacuumed in the meantime, table 2 below threshold")
+	assert.Equal(t, int64(5400), data[0]["max_starvation_seconds"])
+	assert.NoError(t, conn.ExpectationsWereMet())
+
+	UpdateMonitoredDBCache(nil)
+	vacuumBacklogsLock.Lock()
+	assert.NotContains(t, vacuumBacklogs, "db1", "state of removed sources is dropped")
+	vacuumBacklogsLock.Unlock()
+}
@@ -0,0 +1,152 @@
+package reaper
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+const (
+	availabilityMetricName = "availability" // internal metric with the rolling uptime percentages of every monitored DB
+	availabilityInterval   = time.Minute
+	availabilityRetention  = 30 * 24 * time.Hour // the longest rolling window reported
+)
+
+// availability rolling windows, the column suffixes and their lengths
+var availabilityWindows = []struct {
+	suffix string
+	length time.Duration
+}{
+	{"day", 24 * time.Hour},
+	{"week", 7 * 24 * time.Hour},
+	{"month", availabilityRetention},
+}
+
+// availabilityBucket accumulates the observed state of a monitored DB during a single hour
+type availabilityBucket struct {
+	hour     time.Time
+	observed time.Duration
+	up       time.Duration
+	writable time.Duration // up and not in recovery
+}
+
+var availabilityHistory = make(map[string][]availabilityBucket) // oldest first, hourly buckets within the retention
+var instanceUpState = make(map[string]bool)                     // last instance_up result, missing if not fetched
+var availabilityLock sync.Mutex
+
+// RecordInstanceUp registers the outcome of the instance_up metric fetch
+func RecordInstanceUp(dbUnique string, up bool) {
+	availabilityLock.Lock()
+	instanceUpState[dbUnique] = up
+	availabilityLock.Unlock()
+}
+
+// recordAvailability adds the state observed during the period ending now to the hourly buckets of the DB
+func recordAvailability(dbUnique string, now time.Time, period time.Duration, up, writable bool) {
+	hour := now.Truncate(time.Hour)
+	buckets := availabilityHistory[dbUnique]
+	if len(buckets) == 0 || !buckets[len(buckets)-1].hour.Equal(hour) {
+		buckets = append(buckets, availabilityBucket{hour: hour})
+	}
+	b := &buckets[len(buckets)-1]
+	b.observed += period
+	if up {
+		b.up += period
+	}
+	if writable {
+		b.writable += period
+	}
+	for len(buckets) > 0 && now.Sub(buckets[0].hour) >= availabilityRetention+time.Hour {
+		buckets = buckets[1:]
+	}
+	availabilityHistory[dbUnique] = buckets
+}
+
+// availabilityPercentages returns the share of the observed time the DB was up and writable
+// within the window ending now, ok is false if the DB was not observed at all
+func availabilityPercentages(buckets []availabilityBucket, now time.Time, window time.Duration) (uptime, writable float64, ok bool) {
+	var observed, up, rw time.Duration
+	for _, b := range buckets {
+		if now.Sub(b.hour) >= window {
+			continue
+		}
+		observed += b.observed
+		up += b.up
+		rw += b.writable
+	}
+	if observed == 0 {
+		return 0, 0, false
+	}
+	return 100 * up.Seconds() / observed.Seconds(), 100 * rw.Seconds() / observed.Seconds(), true
+}
+
+// AvailabilityMeasurements records the current state of all monitored DBs, combining the instance_up
+// results, the unreachable state and the recovery state, and returns their rolling availability. The DBs
+// in a downtime of all metrics or the availability metric are up and their state is not recorded
+func AvailabilityMeasurements(sinceLast time.Duration) []metrics.MeasurementEnvelope {
+	now := time.Now()
+	monitoredDbCacheLock.RLock()
+	mdbs := make([]metrics.MeasurementEnvelope, 0, len(monitoredDbCache))
+	inDowntime := make(map[string]bool)
+	for dbUnique, md := range monitoredDbCache {
+		mdbs = append(mdbs, metrics.MeasurementEnvelope{DBName: dbUnique, SourceType: string(md.Kind), CustomTags: md.CustomTags})
+		inDowntime[dbUnique] = len(activeDowntimes(dbUnique, md.Group, availabilityMetricName, now)) > 0
+	}
+	monitoredDbCacheLock.RUnlock()
+
+	unreachableDBsLock.RLock()
+	unreachable := make(map[string]bool, len(unreachableDB))
+	for dbUnique := range unreachableDB {
+		unreachable[dbUnique] = true
+	}
+	unreachableDBsLock.RUnlock()
+
+	MonitoredDatabasesSettingsLock.RLock()
+	inRecovery := make(map[string]bool, len(mdbs))
+	for _, msg := range mdbs {
+		inRecovery[msg.DBName] = MonitoredDatabasesSettings[msg.DBName].IsInRecovery
+	}
+	MonitoredDatabasesSettingsLock.RUnlock()
+
+	availabilityLock.Lock()
+	defer availabilityLock.Unlock()
+	msgs := make([]metrics.MeasurementEnvelope, 0, len(mdbs))
+	for _, msg := range mdbs {
+		up := !unreachable[msg.DBName]
+		if instanceUp, ok := instanceUpState[msg.DBName]; ok && !instanceUp {
+			up = false
+		}
+		if sinceLast > 0 && !inDowntime[msg.DBName] {
+			recordAvailability(msg.DBName, now, sinceLast, up, up && !inRecovery[msg.DBName])
+		}
+		row := metrics.Measurement{epochColumnName: now.UnixNano(), "is_up": 0, "in_recovery_int": 0, "in_downtime_int": 0}
+		if up || inDowntime[msg.DBName] {
+			row["is_up"] = 1
+		}
+		if inDowntime[msg.DBName] {
+			row["in_downtime_int"] = 1
+		}
+		if inRecovery[msg.DBName] {
+			row["in_recovery_int"] = 1
+		}
+		for _, w := range availabilityWindows {
+			if uptime, writable, ok := availabilityPercentages(availabilityHistory[msg.DBName], now, w.length); ok {
+				row["uptime_pct_"+w.suffix] = uptime
+				row["writable_pct_"+w.suffix] = writable
+			}
+		}
+		msg.MetricName = availabilityMetricName
+		msg.Data = metrics.Measurements{row}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+// forgetAvailability drops the availability history of the DBs no longer monitored
+func forgetAvailability(dbUnique string) {
+	availabilityLock.Lock()
+	delete(availabilityHistory, dbUnique)
+	delete(instanceUpState, dbUnique)
+	availabilityLock.Unlock()
+}
@@ -0,0 +1,63 @@
+package reaper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAvailabilityPercentages(t *testing.T) {
+	defer forgetAvailability("sla_db")
+	now := time.Date(2026, 5, 10, 12, 30, 0, 0, time.UTC)
+	recordAvailability("sla_db", now.Add(-10*24*time.Hour), time.Hour, false, false) // outage 10 days ago
+	recordAvailability("sla_db", now.Add(-2*time.Hour), 3*time.Hour, true, false)    // in recovery
+	recordAvailability("sla_db", now, time.Hour, true, true)
+
+	buckets := availabilityHistory["sla_db"]
+	assert.Len(t, buckets, 3)
+	uptime, writable, ok := availabilityPercentages(buckets, now, 24*time.Hour)
+	assert.True(t, ok)
+	assert.Equal(t, 100.0, uptime)
+	assert.Equal(t, 25.0, writable)
+
+	uptime, _, ok = availabilityPercentages(buckets, now, availabilityRetention)
+	assert.True(t, ok)
+	assert.Equal(t, 80.0, uptime, "the outage is within the month window")
+
+	_, _, ok = availabilityPercentages(nil, now, time.Hour)
+	assert.False(t, ok)
+
+	recordAvailability("sla_db", now.Add(availabilityRetention), time.Hour, true, true)
+	assert.Len(t, availabilityHistory["sla_db"], 2, "buckets beyond the retention are dropped")
+}
+
+func TestAvailabilityMeasurements(t *testing.T) {
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{
+		{Source: sources.Source{Name: "sla_db", Kind: sources.SourcePostgres, CustomTags: map[string]string{"env": "test"}}},
+	})
+	defer UpdateMonitoredDBCache(nil)
+	defer forgetAvailability("sla_db")
+
+	msgs := AvailabilityMeasurements(time.Minute)
+	assert.Len(t, msgs, 1)
+	assert.Equal(t, availabilityMetricName, msgs[0].MetricName)
+	assert.Equal(t, "test", msgs[0].CustomTags["env"])
+	row := msgs[0].Data[0]
+	assert.Equal(t, 1, row["is_up"])
+	assert.Equal(t, 100.0, row["uptime_pct_day"])
+	assert.Equal(t, 100.0, row["writable_pct_month"])
+
+	RecordInstanceUp("sla_db", false)
+	row = AvailabilityMeasurements(time.Minute)[0].Data[0]
+	assert.Equal(t, 0, row["is_up"])
+	assert.Equal(t, 50.0, row["uptime_pct_week"])
+
+	RecordInstanceUp("sla_db", true)
+	SetDBUnreachableState("sla_db")
+	defer ClearDBUnreachableStateIfAny("sla_db")
+	row = AvailabilityMeasurements(time.Minute)[0].Data[0]
+	assert.Equal(t, 0, row["is_up"], "unreachable DBs are down even if instance_up is not fetched")
+	assert.InDelta(t, 33.3, row["uptime_pct_day"], 0.1)
+}
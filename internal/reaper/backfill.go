@@ -0,0 +1,53 @@
+package reaper
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+const (
+	backfilledColumn  = "backfilled"
+	backfillGapColumn = "backfill_gap_s"
+)
+
+// IsBackfillMetric returns true if the metric is listed in --backfill
+func (r *Reaper) IsBackfillMetric(metricName string) bool {
+	for _, m := range strings.Split(r.opts.Metrics.Backfill, ",") {
+		if strings.TrimSpace(m) == metricName {
+			return true
+		}
+	}
+	return false
+}
+
+// backfillGap returns the time passed since the metric of the source was stored last,
+// if the sinks can tell it and it's longer than two intervals, i.e. the collector was down
+func (r *Reaper) backfillGap(ctx context.Context, dbUnique, storageName string, interval time.Duration) time.Duration {
+	if r.lastMeasurements == nil {
+		return 0
+	}
+	last, err := r.lastMeasurements.LastMeasurementTime(dbUnique, storageName)
+	if err != nil {
+		log.GetLogger(ctx).WithField("source", dbUnique).WithField("metric", storageName).WithError(err).Debug("cannot determine the backfill gap")
+		return 0
+	}
+	if gap := time.Since(last); !last.IsZero() && gap > 2*interval {
+		return gap
+	}
+	return 0
+}
+
+// markBackfilled annotates the counters fetched right after a collector downtime as catch-up rows,
+// so that dashboards can tell the rates calculated from them span the whole gap
+func markBackfilled(msgs []metrics.MeasurementEnvelope, gap time.Duration) {
+	for _, msg := range msgs {
+		for _, row := range msg.Data {
+			row[backfilledColumn] = true
+			row[backfillGapColumn] = int64(gap.Seconds())
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package reaper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/cmdopts"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+type lastMeasurementsMock struct {
+	last time.Time
+	err  error
+}
+
+func (m lastMeasurementsMock) LastMeasurementTime(_, _ string) (time.Time, error) {
+	return m.last, m.err
+}
+
+func TestIsBackfillMetric(t *testing.T) {
+	opts := &cmdopts.Options{}
+	opts.Metrics.Backfill = "stat_statements, db_stats"
+	r := NewReaper(opts, nil, nil)
+	assert.True(t, r.IsBackfillMetric("stat_statements"))
+	assert.True(t, r.IsBackfillMetric("db_stats"))
+	assert.False(t, r.IsBackfillMetric("wal"))
+
+	opts.Metrics.Backfill = ""
+	assert.False(t, r.IsBackfillMetric("db_stats"))
+}
+
+func TestBackfillGap(t *testing.T) {
+	ctx := context.Background()
+	r := NewReaper(&cmdopts.Options{}, nil, nil)
+	assert.Zero(t, r.backfillGap(ctx, "db1", "db_stats", time.Minute), "no sinks")
+
+	r.lastMeasurements = lastMeasurementsMock{err: errors.New("expected")}
+	assert.Zero(t, r.backfillGap(ctx, "db1", "db_stats", time.Minute))
+
+	r.lastMeasurements = lastMeasurementsMock{}
+	assert.Zero(t, r.backfillGap(ctx, "db1", "db_stats", time.Minute), "nothing stored yet")
+
+	r.lastMeasurements = lastMeasurementsMock{last: time.Now().Add(-90 * time.Second)}
+	assert.Zero(t, r.backfillGap(ctx, "db1", "db_stats", time.Minute), "within two intervals")
+
+	r.lastMeasurements = lastMeasurementsMock{last: time.Now().Add(-time.Hour)}
+	assert.InDelta(t, time.Hour, r.backfillGap(ctx, "db1", "db_stats", time.Minute), float64(time.Second))
+}
+
+func TestMarkBackfilled(t *testing.T) {
+	msgs := []metrics.MeasurementEnvelope{{Data: metrics.Measurements{{"calls": 1}, {"calls": 2}}}}
+	markBackfilled(msgs, time.Hour)
+	for _, row := range msgs[0].Data {
+		assert.Equal(t, true, row[backfilledColumn])
+		assert.Equal(t, int64(3600), row[backfillGapColumn])
+	}
+}
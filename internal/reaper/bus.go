@@ -0,0 +1,132 @@
+package reaper
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+// Subscription receives the measurements and server events published on the bus
+type Subscription struct {
+	Name     string
+	C        <-chan []metrics.MeasurementEnvelope
+	ch       chan []metrics.MeasurementEnvelope
+	lossless bool // blocks the delivery when full instead of dropping, used by the sinks only
+	received atomic.Int64
+	dropped  atomic.Int64
+}
+
+// SubscriptionStats describes the delivery to a single bus subscriber
+type SubscriptionStats struct {
+	Name     string `json:"name"`
+	Queue    int    `json:"queue"`
+	QueueCap int    `json:"queue_cap"`
+	Received int64  `json:"received"`
+	Dropped  int64  `json:"dropped"`
+}
+
+// Bus distributes the measurement batches sent by the gatherers to all subscribers, so that new
+// consumers, e.g. alerting or streaming, can be added without touching the fetch path. A slow
+// subscriber only loses its own batches and never delays the sinks or the other subscribers
+type Bus struct {
+	sync.RWMutex
+	subs      []*Subscription
+	published atomic.Int64 // batches
+	rows      atomic.Int64 // measurement rows of the batches
+}
+
+// Subscribe registers a new consumer of the published batches with its own queue of the given size.
+// Lossless subscribers hold up the delivery while their queue is full
+func (b *Bus) Subscribe(name string, buffer int, lossless bool) *Subscription {
+	ch := make(chan []metrics.MeasurementEnvelope, buffer)
+	s := &Subscription{Name: name, C: ch, ch: ch, lossless: lossless}
+	b.Lock()
+	b.subs = append(b.subs, s)
+	b.Unlock()
+	return s
+}
+
+// Unsubscribe removes the consumer and closes its channel
+func (b *Bus) Unsubscribe(s *Subscription) {
+	b.Lock()
+	defer b.Unlock()
+	if i := slices.Index(b.subs, s); i >= 0 {
+		b.subs = slices.Delete(b.subs, i, i+1)
+		close(s.ch)
+	}
+}
+
+// Publish delivers the batch to all subscribers
+func (b *Bus) Publish(ctx context.Context, msgs []metrics.MeasurementEnvelope) {
+	b.published.Add(1)
+	for _, msg := range msgs {
+		b.rows.Add(int64(len(msg.Data)))
+	}
+	b.RLock()
+	defer b.RUnlock()
+	for _, s := range b.subs {
+		if s.lossless {
+			select {
+			case s.ch <- msgs:
+				s.received.Add(1)
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+		select {
+		case s.ch <- msgs:
+			s.received.Add(1)
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// Run publishes the batches sent to the measurement channel until the context is canceled
+func (b *Bus) Run(ctx context.Context, in <-chan []metrics.MeasurementEnvelope) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msgs := <-in:
+			b.Publish(ctx, msgs)
+		}
+	}
+}
+
+// Published returns the number of batches and measurement rows published since the start
+func (b *Bus) Published() (batches, rows int64) {
+	return b.published.Load(), b.rows.Load()
+}
+
+// Stats returns the delivery statistics of the subscribers in the subscription order
+func (b *Bus) Stats() []SubscriptionStats {
+	b.RLock()
+	defer b.RUnlock()
+	stats := make([]SubscriptionStats, 0, len(b.subs))
+	for _, s := range b.subs {
+		stats = append(stats, SubscriptionStats{
+			Name:     s.Name,
+			Queue:    len(s.ch),
+			QueueCap: cap(s.ch),
+			Received: s.received.Load(),
+			Dropped:  s.dropped.Load(),
+		})
+	}
+	return stats
+}
+
+// Subscribe registers a new consumer of the measurements and server events, e.g. a streamer.
+// Batches are dropped for the subscriber while its queue is full
+func (r *Reaper) Subscribe(name string, buffer int) *Subscription {
+	return r.bus.Subscribe(name, buffer, false)
+}
+
+// Unsubscribe removes the consumer registered with Subscribe
+func (r *Reaper) Unsubscribe(s *Subscription) {
+	r.bus.Unsubscribe(s)
+}
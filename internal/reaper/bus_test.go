@@ -0,0 +1,46 @@
+package reaper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/cmdopts"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := NewReaper(&cmdopts.Options{}, nil, nil)
+	sinksSub := r.bus.Subscribe("sinks", 0, true)
+	streamer := r.Subscribe("streamer", 1)
+	go r.bus.Run(ctx, r.measurementCh)
+
+	for _, db := range []string{"db1", "db2", "db3"} {
+		r.measurementCh <- []metrics.MeasurementEnvelope{{DBName: db}}
+		select {
+		case msgs := <-sinksSub.C:
+			assert.Equal(t, db, msgs[0].DBName, "lossless subscribers get every batch in order")
+		case <-time.After(time.Second):
+			t.Fatal("batch not delivered")
+		}
+	}
+
+	stats := r.State().Subscribers
+	require.Len(t, stats, 2)
+	assert.EqualValues(t, 3, stats[0].Received)
+	assert.Zero(t, stats[0].Dropped)
+	assert.Equal(t, "streamer", stats[1].Name)
+	assert.EqualValues(t, 1, stats[1].Received)
+	assert.EqualValues(t, 2, stats[1].Dropped, "slow subscribers lose their own batches only")
+	assert.Equal(t, "db1", (<-streamer.C)[0].DBName)
+
+	r.Unsubscribe(streamer)
+	_, ok := <-streamer.C
+	assert.False(t, ok, "channel closed on unsubscribe")
+	assert.Len(t, r.State().Subscribers, 1)
+	r.Unsubscribe(streamer) // no-op
+}
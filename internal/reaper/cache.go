@@ -4,9 +4,12 @@ import (
 	"context"
 	"fmt"
 	"maps"
+	"slices"
 	"sync"
 	"time"
 
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/drift"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/faults"
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
@@ -26,13 +29,12 @@ var lastDBSizeFetchTime = make(map[string]time.Time) // cached for DB_SIZE_CACHI
 var lastDBSizeCheckLock sync.RWMutex
 
 var prevLoopMonitoredDBs sources.MonitoredDatabases // to be able to detect DBs removed from config
-var undersizedDBs = make(map[string]bool)           // DBs below the --min-db-size-mb limit, if set
-var undersizedDBsLock = sync.RWMutex{}
-var recoveryIgnoredDBs = make(map[string]bool) // DBs in recovery state and OnlyIfMaster specified in config
-var recoveryIgnoredDBsLock = sync.RWMutex{}
 
 var hostMetricIntervalMap = make(map[string]float64) // [db1_metric] = 30
 
+var unsupportedPresetsWarned = make(map[[2]string]bool) // [db1, preset] = true, to warn only once per source and preset
+var unsupportedPresetsWarnedLock sync.Mutex
+
 var lastSQLFetchError sync.Map
 
 func InitPGVersionInfoFetchingLockIfNil(md *sources.MonitoredDatabase) {
@@ -51,6 +53,58 @@ func UpdateMonitoredDBCache(data sources.MonitoredDatabases) {
 	monitoredDbCacheLock.Lock()
 	monitoredDbCache = monitoredDbCacheNew
 	monitoredDbCacheLock.Unlock()
+	forgetRemovedSources(monitoredDbCacheNew)
+}
+
+// forgetRemovedSources drops the internal metrics state kept for sources not monitored anymore
+func forgetRemovedSources(monitored map[string]*sources.MonitoredDatabase) {
+	removed := func(dbUnique string) bool {
+		_, ok := monitored[dbUnique]
+		return !ok
+	}
+	monitoringOverheadLock.Lock()
+	maps.DeleteFunc(monitoringOverhead, func(dbUnique string, _ SourceOverhead) bool { return removed(dbUnique) })
+	maps.DeleteFunc(monitoringOverheadReported, func(dbUnique string, _ SourceOverhead) bool { return removed(dbUnique) })
+	monitoringOverheadLock.Unlock()
+
+	clockDriftLock.Lock()
+	maps.DeleteFunc(clockDrifts, func(dbUnique string, _ ClockDrift) bool { return removed(dbUnique) })
+	maps.DeleteFunc(clockDriftLastWarning, func(dbUnique string, _ time.Time) bool { return removed(dbUnique) })
+	clockDriftLock.Unlock()
+
+	unsupportedPresetsWarnedLock.Lock()
+	maps.DeleteFunc(unsupportedPresetsWarned, func(key [2]string, _ bool) bool { return removed(key[0]) })
+	unsupportedPresetsWarnedLock.Unlock()
+
+	vacuumBacklogsLock.Lock()
+	maps.DeleteFunc(vacuumBacklogs, func(dbUnique string, _ map[int64]vacuumBacklog) bool { return removed(dbUnique) })
+	vacuumBacklogsLock.Unlock()
+
+	tempSpillCountersLock.Lock()
+	maps.DeleteFunc(tempSpillCounters, func(dbUnique string, _ metrics.TempFileUsage) bool { return removed(dbUnique) })
+	tempSpillCountersLock.Unlock()
+
+	checkpointCountersLock.Lock()
+	maps.DeleteFunc(checkpointCounters, func(dbUnique string, _ metrics.Measurement) bool { return removed(dbUnique) })
+	checkpointCountersLock.Unlock()
+
+	tlsDowngradesLock.Lock()
+	maps.DeleteFunc(tlsDowngrades, func(dbUnique string, _ int64) bool { return removed(dbUnique) })
+	tlsDowngradesLock.Unlock()
+
+	schemaMismatchesLock.Lock()
+	maps.DeleteFunc(schemaMismatches, func(_ string, sm *SchemaMismatch) bool { return removed(sm.Source) })
+	schemaMismatchesLock.Unlock()
+
+	indexUsagesLock.Lock()
+	maps.DeleteFunc(indexUsages, func(dbUnique string, _ map[int64]indexUsage) bool { return removed(dbUnique) })
+	indexUsagesLock.Unlock()
+
+	configDriftReportsLock.Lock()
+	configDriftReports = slices.DeleteFunc(configDriftReports, func(r drift.Report) bool { return removed(r.Source) })
+	configDriftReportsLock.Unlock()
+
+	forgetDormancy(removed)
 }
 
 func GetMonitoredDatabaseByUniqueName(name string) (*sources.MonitoredDatabase, error) {
@@ -77,9 +131,33 @@ func GetMetricVersionProperties(metric string, _ MonitoredDatabaseSettings, metr
 	return mdm.MetricDefs[metric], nil
 }
 
+// GetPresetMetrics returns the metrics of a preset or nil if the preset is restricted to other execution environments
+func GetPresetMetrics(ctx context.Context, dbUnique, presetName, execEnv string) map[string]float64 {
+	metricDefMapLock.RLock()
+	preset := metricDefinitionMap.PresetDefs[presetName]
+	metricDefMapLock.RUnlock()
+	if !preset.AllowsEnv(execEnv) {
+		unsupportedPresetsWarnedLock.Lock()
+		warned := unsupportedPresetsWarned[[2]string{dbUnique, presetName}]
+		unsupportedPresetsWarned[[2]string{dbUnique, presetName}] = true
+		unsupportedPresetsWarnedLock.Unlock()
+		l := log.GetLogger(ctx).WithField("source", dbUnique)
+		if warned {
+			l.Debugf("preset '%s' is not supported in the %s execution environment", presetName, execEnv)
+		} else {
+			l.Warningf("preset '%s' is not supported in the %s execution environment, no metrics will be gathered", presetName, execEnv)
+		}
+		return nil
+	}
+	return preset.Metrics
+}
+
 // LoadMetricDefs loads metric definitions from the reader
 func LoadMetricDefs(r metrics.Reader) (err error) {
 	var metricDefs *metrics.Metrics
+	if err = faults.ConfigError(); err != nil {
+		return
+	}
 	if metricDefs, err = r.GetMetrics(); err != nil {
 		return
 	}
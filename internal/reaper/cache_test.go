@@ -0,0 +1,48 @@
+package reaper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPresetMetrics(t *testing.T) {
+	metricDefMapLock.Lock()
+	metricDefinitionMap.PresetDefs = metrics.PresetDefs{
+		"any":    {Metrics: map[string]float64{"db_stats": 60}},
+		"aurora": {Metrics: map[string]float64{"db_stats_aurora": 60}, EnvRestrictions: metrics.EnvRestrictions{OnlyEnvs: []string{execEnvAwsAurora}}},
+	}
+	metricDefMapLock.Unlock()
+	defer func() {
+		metricDefMapLock.Lock()
+		metricDefinitionMap.PresetDefs = nil
+		metricDefMapLock.Unlock()
+	}()
+
+	ctx := context.Background()
+	assert.Equal(t, map[string]float64{"db_stats": 60}, GetPresetMetrics(ctx, "db1", "any", execEnvAwsRds))
+	assert.Equal(t, map[string]float64{"db_stats_aurora": 60}, GetPresetMetrics(ctx, "db1", "aurora", execEnvAwsAurora))
+	assert.Nil(t, GetPresetMetrics(ctx, "db1", "aurora", execEnvAwsRds))
+	assert.True(t, unsupportedPresetsWarned[[2]string{"db1", "aurora"}], "next checks are logged at debug level")
+	assert.Nil(t, GetPresetMetrics(ctx, "db1", "aurora", execEnvAwsRds))
+	assert.Nil(t, GetPresetMetrics(ctx, "db1", "missing", ""))
+}
+
+func TestForgetRemovedSources(t *testing.T) {
+	RecordQueryOverhead("kept", time.Second)
+	RecordQueryOverhead("removed", time.Second)
+	RecordClockDrift("removed", metrics.Measurements{{epochColumnName: time.Now().UnixNano()}}, time.Now(), time.Millisecond)
+	unsupportedPresetsWarned[[2]string{"removed", "aurora"}] = true
+
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{{Source: sources.Source{Name: "kept"}}})
+	defer UpdateMonitoredDBCache(nil)
+
+	assert.Equal(t, int64(1), GetMonitoringOverhead("kept").Queries)
+	assert.Zero(t, GetMonitoringOverhead("removed").Queries)
+	assert.NotContains(t, clockDrifts, "removed")
+	assert.NotContains(t, unsupportedPresetsWarned, [2]string{"removed", "aurora"})
+}
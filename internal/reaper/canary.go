@@ -0,0 +1,91 @@
+package reaper
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/db"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+)
+
+const (
+	canaryMetricName = "canary" // internal metric with the latency of a write/read round trip on opted-in sources
+	canaryInterval   = time.Minute
+	canaryTimeout    = 10 * time.Second
+)
+
+// canaryRoundTrip inserts, reads back and deletes a row in the canary table measuring every step.
+// Failures are stored too, as the lost read-write capability is what the check is for
+func canaryRoundTrip(ctx context.Context, conn db.PgxIface, dbUnique string) metrics.Measurement {
+	ctx, cancel := context.WithTimeout(ctx, canaryTimeout)
+	defer cancel()
+	start := time.Now()
+	row := metrics.Measurement{epochColumnName: start.UnixNano(), "success_int": 0}
+	step := func(column string, f func() error) error {
+		t := time.Now()
+		err := f()
+		row[column] = float64(time.Since(t).Microseconds()) / 1000
+		return err
+	}
+
+	var id int64
+	var found int
+	err := step("insert_ms", func() error {
+		return conn.QueryRow(ctx, `insert /* pgwatch_generated */ into pgwatch_canary.canary(source) values ($1) returning id`, dbUnique).Scan(&id)
+	})
+	if err == nil {
+		err = step("select_ms", func() error {
+			return conn.QueryRow(ctx, `select /* pgwatch_generated */ count(*) from pgwatch_canary.canary where id = $1`, id).Scan(&found)
+		})
+	}
+	if err == nil && found != 1 {
+		err = errors.New("inserted canary row not found")
+	}
+	if err == nil {
+		err = step("delete_ms", func() error {
+			_, err := conn.Exec(ctx, `delete /* pgwatch_generated */ from pgwatch_canary.canary where id = $1`, id)
+			return err
+		})
+	}
+	row["total_ms"] = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		log.GetLogger(ctx).WithField("source", dbUnique).WithError(err).Warning("canary round trip failed")
+		row["error_kind"] = string(ClassifyFetchError(err))
+		return row
+	}
+	row["success_int"] = 1
+	return row
+}
+
+// CanaryMeasurements performs the canary round trip on all connected primaries with the canary host config set
+func CanaryMeasurements(ctx context.Context) []metrics.MeasurementEnvelope {
+	monitoredDbCacheLock.RLock()
+	mdbs := make([]*sources.MonitoredDatabase, 0, len(monitoredDbCache))
+	for _, md := range monitoredDbCache {
+		if md.HostConfig.Canary && md.Conn != nil && md.IsPostgresSource() {
+			mdbs = append(mdbs, md)
+		}
+	}
+	monitoredDbCacheLock.RUnlock()
+
+	msgs := make([]metrics.MeasurementEnvelope, 0, len(mdbs))
+	for _, md := range mdbs {
+		MonitoredDatabasesSettingsLock.RLock()
+		inRecovery := MonitoredDatabasesSettings[md.Name].IsInRecovery
+		MonitoredDatabasesSettingsLock.RUnlock()
+		if inRecovery {
+			continue // standbys are read-only
+		}
+		msgs = append(msgs, metrics.MeasurementEnvelope{
+			DBName:     md.Name,
+			SourceType: string(md.Kind),
+			MetricName: canaryMetricName,
+			CustomTags: md.CustomTags,
+			Data:       metrics.Measurements{canaryRoundTrip(ctx, md.Conn, md.Name)},
+		})
+	}
+	return msgs
+}
@@ -0,0 +1,69 @@
+package reaper
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanaryRoundTrip(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	conn.ExpectQuery(`insert .* into pgwatch_canary\.canary`).WithArgs("canary_db").WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(int64(42)))
+	conn.ExpectQuery(`select .* from pgwatch_canary\.canary`).WithArgs(int64(42)).WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(1))
+	conn.ExpectExec(`delete .* from pgwatch_canary\.canary`).WithArgs(int64(42)).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	row := canaryRoundTrip(context.Background(), conn, "canary_db")
+	assert.NoError(t, conn.ExpectationsWereMet())
+	assert.Equal(t, 1, row["success_int"])
+	for _, column := range []string{"insert_ms", "select_ms", "delete_ms", "total_ms"} {
+		assert.Contains(t, row, column)
+	}
+
+	conn.ExpectQuery(`insert .* into pgwatch_canary\.canary`).WithArgs("canary_db").WillReturnError(&pgconn.PgError{Code: sqlStateReadOnlySQLTransaction})
+	row = canaryRoundTrip(context.Background(), conn, "canary_db")
+	assert.NoError(t, conn.ExpectationsWereMet())
+	assert.Equal(t, 0, row["success_int"])
+	assert.Equal(t, string(FetchErrorRecovery), row["error_kind"])
+	assert.NotContains(t, row, "select_ms", "no further steps after a failure")
+
+	conn.ExpectQuery(`insert .* into pgwatch_canary\.canary`).WithArgs("canary_db").WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(int64(43)))
+	conn.ExpectQuery(`select .* from pgwatch_canary\.canary`).WithArgs(int64(43)).WillReturnError(errors.New("connection reset"))
+	row = canaryRoundTrip(context.Background(), conn, "canary_db")
+	assert.NoError(t, conn.ExpectationsWereMet())
+	assert.Equal(t, 0, row["success_int"])
+}
+
+func TestCanaryMeasurements(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer conn.Close()
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{
+		{Source: sources.Source{Name: "not_opted_in", Kind: sources.SourcePostgres}, Conn: conn},
+		{Source: sources.Source{Name: "canary_standby", Kind: sources.SourcePostgres, HostConfig: sources.HostConfigAttrs{Canary: true}}, Conn: conn},
+		{Source: sources.Source{Name: "canary_db", Kind: sources.SourcePostgres, HostConfig: sources.HostConfigAttrs{Canary: true}}, Conn: conn},
+	})
+	defer UpdateMonitoredDBCache(nil)
+	MonitoredDatabasesSettingsLock.Lock()
+	MonitoredDatabasesSettings["canary_standby"] = MonitoredDatabaseSettings{IsInRecovery: true}
+	MonitoredDatabasesSettingsLock.Unlock()
+	defer func() {
+		MonitoredDatabasesSettingsLock.Lock()
+		delete(MonitoredDatabasesSettings, "canary_standby")
+		MonitoredDatabasesSettingsLock.Unlock()
+	}()
+
+	conn.ExpectQuery(`insert .* into pgwatch_canary\.canary`).WithArgs("canary_db").WillReturnError(errors.New("relation does not exist"))
+	msgs := CanaryMeasurements(context.Background())
+	assert.NoError(t, conn.ExpectationsWereMet(), "only the opted-in primary is checked")
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "canary_db", msgs[0].DBName)
+	assert.Equal(t, canaryMetricName, msgs[0].MetricName)
+}
@@ -0,0 +1,65 @@
+package reaper
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+// specialMetricCheckpointEfficiency reads the checkpoint counters with the version specific SQL of the metric,
+// i.e. pg_stat_bgwriter or as of Postgres 17 pg_stat_checkpointer and pg_stat_io, and stores the indicators
+// calculated from the counter deltas of consecutive fetches instead
+const specialMetricCheckpointEfficiency = "checkpoint_efficiency"
+
+var checkpointCounterColumns = []string{"checkpoints_timed", "checkpoints_req", "write_time", "sync_time", "backend_fsyncs"}
+
+var checkpointCounters = make(map[string]metrics.Measurement) // [db1]=counters at the last fetch
+var checkpointCountersLock sync.Mutex
+
+// CheckpointEfficiency returns the checkpoint indicators of the period between the previous and the current
+// counters: the number of timed and requested checkpoints, the requested checkpoints percentage, the average
+// write and sync time per checkpoint in milliseconds and the fsyncs done by backends instead of the checkpointer
+func CheckpointEfficiency(prev, curr metrics.Measurement) metrics.Measurement {
+	delta := make(map[string]float64, len(checkpointCounterColumns))
+	for _, col := range checkpointCounterColumns {
+		c, okCurr := metrics.ToFloat(curr[col])
+		p, okPrev := metrics.ToFloat(prev[col])
+		if !okCurr || !okPrev || c < p { // stats reset
+			return nil
+		}
+		delta[col] = c - p
+	}
+	row := metrics.Measurement{
+		epochColumnName:     curr[epochColumnName],
+		"checkpoints_timed": int64(delta["checkpoints_timed"]),
+		"checkpoints_req":   int64(delta["checkpoints_req"]),
+		"backend_fsyncs":    int64(delta["backend_fsyncs"]),
+	}
+	if checkpoints := delta["checkpoints_timed"] + delta["checkpoints_req"]; checkpoints > 0 {
+		row["requested_pct"] = 100 * delta["checkpoints_req"] / checkpoints
+		row["avg_write_time_ms"] = delta["write_time"] / checkpoints
+		row["avg_sync_time_ms"] = delta["sync_time"] / checkpoints
+	}
+	return row
+}
+
+// FetchCheckpointEfficiency reads the checkpoint counters of the instance and returns the indicators since
+// the previous fetch, nothing on the first fetch or after a statistics reset
+func FetchCheckpointEfficiency(ctx context.Context, dbUnique string, sql string) (metrics.Measurements, error) {
+	data, err := DBExecReadByDbUniqueName(ctx, dbUnique, sql)
+	if err != nil || len(data) == 0 {
+		return nil, err
+	}
+	checkpointCountersLock.Lock()
+	prev, ok := checkpointCounters[dbUnique]
+	checkpointCounters[dbUnique] = data[0]
+	checkpointCountersLock.Unlock()
+	if !ok {
+		return nil, nil
+	}
+	if row := CheckpointEfficiency(prev, data[0]); row != nil {
+		return metrics.Measurements{row}, nil
+	}
+	return nil, nil
+}
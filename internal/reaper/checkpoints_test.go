@@ -0,0 +1,61 @@
+package reaper
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpointEfficiency(t *testing.T) {
+	prev := metrics.Measurement{"checkpoints_timed": int64(10), "checkpoints_req": int64(2), "write_time": 1000.0, "sync_time": 100.0, "backend_fsyncs": int64(0)}
+	curr := metrics.Measurement{epochColumnName: int64(1), "checkpoints_timed": int64(13), "checkpoints_req": int64(3), "write_time": 5000.0, "sync_time": 300.0, "backend_fsyncs": int64(5)}
+	row := CheckpointEfficiency(prev, curr)
+	assert.Equal(t, int64(3), row["checkpoints_timed"])
+	assert.Equal(t, int64(1), row["checkpoints_req"])
+	assert.Equal(t, int64(5), row["backend_fsyncs"])
+	assert.InDelta(t, 25.0, row["requested_pct"], 1e-9)
+	assert.InDelta(t, 1000.0, row["avg_write_time_ms"], 1e-9)
+	assert.InDelta(t, 50.0, row["avg_sync_time_ms"], 1e-9)
+
+	row = CheckpointEfficiency(prev, prev)
+	assert.Equal(t, int64(0), row["checkpoints_timed"])
+	assert.NotContains(t, row, "requested_pct", "no checkpoints in the period")
+
+	assert.Nil(t, CheckpointEfficiency(curr, prev), "stats reset")
+	assert.Nil(t, CheckpointEfficiency(metrics.Measurement{}, curr))
+}
+
+func TestFetchCheckpointEfficiency(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer conn.Close()
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{{Source: sources.Source{Name: "db1", Kind: sources.SourcePostgres}, Conn: conn}})
+	defer UpdateMonitoredDBCache(nil)
+	ctx := context.Background()
+	cols := []string{"epoch_ns", "checkpoints_timed", "checkpoints_req", "write_time", "sync_time", "backend_fsyncs"}
+	sql := "select * from pg_stat_checkpointer"
+
+	expectGuardQuery(conn, "from pg_stat_checkpointer", pgxmock.NewRows(cols).AddRow(int64(1), int64(10), int64(0), 100.0, 10.0, int64(0)))
+	data, err := FetchCheckpointEfficiency(ctx, "db1", sql)
+	require.NoError(t, err)
+	assert.Empty(t, data, "no delta on the first fetch")
+
+	expectGuardQuery(conn, "from pg_stat_checkpointer", pgxmock.NewRows(cols).AddRow(int64(2), int64(11), int64(1), 300.0, 30.0, int64(0)))
+	data, err = FetchCheckpointEfficiency(ctx, "db1", sql)
+	require.NoError(t, err)
+	require.Len(t, data, 1)
+	assert.Equal(t, int64(2), data[0][epochColumnName])
+	assert.InDelta(t, 50.0, data[0]["requested_pct"], 1e-9)
+	assert.InDelta(t, 10.0, data[0]["avg_sync_time_ms"], 1e-9)
+	assert.NoError(t, conn.ExpectationsWereMet())
+
+	UpdateMonitoredDBCache(nil)
+	checkpointCountersLock.Lock()
+	assert.NotContains(t, checkpointCounters, "db1", "state of removed sources is dropped")
+	checkpointCountersLock.Unlock()
+}
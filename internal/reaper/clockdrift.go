@@ -0,0 +1,92 @@
+package reaper
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+const (
+	clockDriftMetricName   = "clock_drift" // internal metric with the clock difference between the monitored server and pgwatch
+	clockDriftInterval     = time.Minute
+	clockDriftWarnInterval = 10 * time.Minute
+)
+
+// ClockDrift is the best sample of the server clock offset, positive if the server is ahead.
+// The real offset lies within Uncertainty, i.e. the duration of the sampled query
+type ClockDrift struct {
+	Drift       time.Duration
+	Uncertainty time.Duration
+}
+
+// Exceeds returns true if the drift is certainly bigger than the threshold
+func (cd ClockDrift) Exceeds(threshold time.Duration) bool {
+	return threshold > 0 && max(cd.Drift, -cd.Drift)-cd.Uncertainty > threshold
+}
+
+var clockDrifts = make(map[string]ClockDrift) // best samples since the last report
+var clockDriftLastWarning = make(map[string]time.Time)
+var clockDriftLock sync.Mutex
+
+// RecordClockDrift compares the server side epoch_ns of fetched data with the collector time the query started at.
+// Server now() returns the transaction start time, so the query duration bounds the measurement error
+func RecordClockDrift(dbUnique string, data metrics.Measurements, started time.Time, duration time.Duration) {
+	if len(data) == 0 {
+		return
+	}
+	epochNs, ok := data[0][epochColumnName].(int64)
+	if !ok {
+		return
+	}
+	sample := ClockDrift{Drift: time.Unix(0, epochNs).Sub(started), Uncertainty: duration}
+	clockDriftLock.Lock()
+	defer clockDriftLock.Unlock()
+	if best, ok := clockDrifts[dbUnique]; !ok || sample.Uncertainty < best.Uncertainty {
+		clockDrifts[dbUnique] = sample
+	}
+}
+
+// ClockDriftMeasurements returns the best clock drift samples since the previous call
+// and logs a warning for sources where the drift exceeds the threshold
+func ClockDriftMeasurements(ctx context.Context, threshold time.Duration) []metrics.MeasurementEnvelope {
+	clockDriftLock.Lock()
+	defer clockDriftLock.Unlock()
+	samples := clockDrifts
+	clockDrifts = make(map[string]ClockDrift)
+
+	now := time.Now()
+	msgs := make([]metrics.MeasurementEnvelope, 0, len(samples))
+	for dbUnique, cd := range samples {
+		md, err := GetMonitoredDatabaseByUniqueName(dbUnique)
+		if err != nil {
+			continue
+		}
+		exceedsInt := 0
+		if cd.Exceeds(threshold) {
+			exceedsInt = 1
+		}
+		if exceedsInt == 1 && now.Sub(clockDriftLastWarning[dbUnique]) > clockDriftWarnInterval {
+			log.GetLogger(ctx).
+				WithField("source", dbUnique).
+				WithField("drift", cd.Drift.Truncate(time.Millisecond)).
+				Warning("clock of the monitored server is out of sync, graphs combining server and collector timestamps will be skewed")
+			clockDriftLastWarning[dbUnique] = now
+		}
+		msgs = append(msgs, metrics.MeasurementEnvelope{
+			DBName:     dbUnique,
+			SourceType: string(md.Kind),
+			MetricName: clockDriftMetricName,
+			CustomTags: md.CustomTags,
+			Data: metrics.Measurements{{
+				epochColumnName:         now.UnixNano(),
+				"drift_ms":              float64(cd.Drift.Microseconds()) / 1000,
+				"uncertainty_ms":        float64(cd.Uncertainty.Microseconds()) / 1000,
+				"exceeds_threshold_int": exceedsInt,
+			}},
+		})
+	}
+	return msgs
+}
@@ -0,0 +1,38 @@
+package reaper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClockDriftExceeds(t *testing.T) {
+	assert.False(t, ClockDrift{Drift: 3 * time.Second}.Exceeds(0), "check disabled")
+	assert.True(t, ClockDrift{Drift: 3 * time.Second}.Exceeds(time.Second))
+	assert.True(t, ClockDrift{Drift: -3 * time.Second}.Exceeds(time.Second))
+	assert.False(t, ClockDrift{Drift: 3 * time.Second, Uncertainty: 2500 * time.Millisecond}.Exceeds(time.Second), "might be just a slow query")
+}
+
+func TestClockDriftMeasurements(t *testing.T) {
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{{Source: sources.Source{Name: "drift_db", Kind: sources.SourcePostgres}}})
+	defer UpdateMonitoredDBCache(nil)
+
+	started := time.Now()
+	RecordClockDrift("drift_db", metrics.Measurements{{epochColumnName: started.Add(5 * time.Second).UnixNano()}}, started, 2*time.Second)
+	RecordClockDrift("drift_db", metrics.Measurements{{epochColumnName: started.Add(3 * time.Second).UnixNano()}}, started, 10*time.Millisecond)
+	RecordClockDrift("drift_db", metrics.Measurements{{"no_epoch": 1}}, started, 0)
+	RecordClockDrift("unknown_db", metrics.Measurements{{epochColumnName: started.UnixNano()}}, started, 0)
+
+	msgs := ClockDriftMeasurements(context.Background(), time.Second)
+	assert.Len(t, msgs, 1)
+	assert.Equal(t, clockDriftMetricName, msgs[0].MetricName)
+	assert.Equal(t, 3000.0, msgs[0].Data[0]["drift_ms"], "the most precise sample should be used")
+	assert.Equal(t, 10.0, msgs[0].Data[0]["uncertainty_ms"])
+	assert.Equal(t, 1, msgs[0].Data[0]["exceeds_threshold_int"])
+
+	assert.Empty(t, ClockDriftMeasurements(context.Background(), time.Second), "samples should be reset after reporting")
+}
@@ -0,0 +1,85 @@
+package reaper
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+const (
+	metricCompatibilityMetricName = "metric_compatibility" // internal metric listing the gaps in metric definitions per monitored DB
+	metricCompatibilityInterval   = time.Hour
+)
+
+// getMonitoredVersions returns the monitored Postgres sources grouped by server major version
+func getMonitoredVersions() map[int][]string {
+	monitoredDbCacheLock.RLock()
+	defer monitoredDbCacheLock.RUnlock()
+	MonitoredDatabasesSettingsLock.RLock()
+	defer MonitoredDatabasesSettingsLock.RUnlock()
+
+	versions := make(map[int][]string)
+	for dbUnique, md := range monitoredDbCache {
+		ver, ok := MonitoredDatabasesSettings[dbUnique]
+		if !ok || !md.IsPostgresSource() || ver.Version == 0 {
+			continue
+		}
+		versions[ver.Version] = append(versions[ver.Version], dbUnique)
+	}
+	return versions
+}
+
+// GetCompatibilityReport checks the current metric definitions against all monitored server versions
+func GetCompatibilityReport() []metrics.CompatibilityReport {
+	versions := getMonitoredVersions()
+	metricDefMapLock.RLock()
+	defs := maps.Clone(metricDefinitionMap.MetricDefs)
+	metricDefMapLock.RUnlock()
+
+	reports := make([]metrics.CompatibilityReport, 0, len(versions))
+	for _, version := range slices.Sorted(maps.Keys(versions)) {
+		report := defs.CheckCompatibility(version)
+		report.Sources = versions[version]
+		slices.Sort(report.Sources)
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// CompatibilityReport implements the web server interface for the compatibility matrix
+func (r *Reaper) CompatibilityReport() []metrics.CompatibilityReport {
+	return GetCompatibilityReport()
+}
+
+// MetricCompatibilityMeasurements returns the compatibility report rows for every monitored Postgres source
+func MetricCompatibilityMeasurements() []metrics.MeasurementEnvelope {
+	now := time.Now().UnixNano()
+	msgs := make([]metrics.MeasurementEnvelope, 0)
+	for _, report := range GetCompatibilityReport() {
+		row := metrics.Measurement{
+			epochColumnName:         now,
+			"version":               report.Version,
+			"missing_metrics_count": len(report.MissingMetrics),
+			"missing_metrics":       strings.Join(report.MissingMetrics, ","),
+			"uncovered_views_count": len(report.UncoveredViews),
+			"uncovered_views":       strings.Join(report.UncoveredViews, ","),
+		}
+		for _, dbUnique := range report.Sources {
+			md, err := GetMonitoredDatabaseByUniqueName(dbUnique)
+			if err != nil {
+				continue
+			}
+			msgs = append(msgs, metrics.MeasurementEnvelope{
+				DBName:     dbUnique,
+				SourceType: string(md.Kind),
+				MetricName: metricCompatibilityMetricName,
+				CustomTags: md.CustomTags,
+				Data:       metrics.Measurements{maps.Clone(row)},
+			})
+		}
+	}
+	return msgs
+}
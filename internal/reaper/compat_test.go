@@ -0,0 +1,56 @@
+package reaper
+
+import (
+	"testing"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricCompatibility(t *testing.T) {
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{
+		{Source: sources.Source{Name: "pg15", Kind: sources.SourcePostgres}},
+		{Source: sources.Source{Name: "pg16", Kind: sources.SourcePostgres, CustomTags: map[string]string{"env": "test"}}},
+		{Source: sources.Source{Name: "bouncer", Kind: sources.SourcePgBouncer}},
+	})
+	MonitoredDatabasesSettingsLock.Lock()
+	MonitoredDatabasesSettings["pg15"] = MonitoredDatabaseSettings{Version: 15}
+	MonitoredDatabasesSettings["pg16"] = MonitoredDatabaseSettings{Version: 16}
+	MonitoredDatabasesSettings["bouncer"] = MonitoredDatabaseSettings{Version: 1_22_00}
+	MonitoredDatabasesSettingsLock.Unlock()
+	metricDefMapLock.Lock()
+	metricDefinitionMap.MetricDefs = metrics.MetricDefs{"stat_io": {SQLs: metrics.SQLs{16: "select * from pg_stat_io"}}}
+	metricDefMapLock.Unlock()
+	defer func() {
+		UpdateMonitoredDBCache(nil)
+		MonitoredDatabasesSettingsLock.Lock()
+		clear(MonitoredDatabasesSettings)
+		MonitoredDatabasesSettingsLock.Unlock()
+		metricDefMapLock.Lock()
+		metricDefinitionMap.MetricDefs = nil
+		metricDefMapLock.Unlock()
+	}()
+
+	reports := GetCompatibilityReport()
+	assert.Len(t, reports, 2, "poolers should be skipped")
+	assert.Equal(t, 15, reports[0].Version)
+	assert.Equal(t, []string{"pg15"}, reports[0].Sources)
+	assert.Equal(t, []string{"stat_io"}, reports[0].MissingMetrics)
+	assert.Equal(t, 16, reports[1].Version)
+	assert.Empty(t, reports[1].MissingMetrics)
+	assert.NotContains(t, reports[1].UncoveredViews, "pg_stat_io")
+
+	msgs := MetricCompatibilityMeasurements()
+	assert.Len(t, msgs, 2)
+	for _, msg := range msgs {
+		assert.Equal(t, metricCompatibilityMetricName, msg.MetricName)
+		if msg.DBName == "pg15" {
+			assert.Equal(t, "stat_io", msg.Data[0]["missing_metrics"])
+			assert.Equal(t, 1, msg.Data[0]["missing_metrics_count"])
+		} else {
+			assert.Equal(t, "test", msg.CustomTags["env"])
+			assert.Equal(t, 0, msg.Data[0]["missing_metrics_count"])
+		}
+	}
+}
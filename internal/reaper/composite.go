@@ -0,0 +1,75 @@
+package reaper
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/cmdopts"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+)
+
+// FetchCompositeStatements executes the additional statements of a composite metric and merges their rows into
+// the rows of the metric query. Statements without SQL for the server version are skipped
+func FetchCompositeStatements(ctx context.Context, msg MetricFetchConfig, md *sources.MonitoredDatabase, dbVersion int,
+	composite metrics.Composite, data metrics.Measurements, opts *cmdopts.Options) (metrics.Measurements, error) {
+	results := make([]metrics.Measurements, len(composite.Statements))
+	for i, stmt := range composite.Statements {
+		sql := stmt.SQLs.Get(dbVersion)
+		if sql == "" {
+			continue
+		}
+		if md.IsPostgresSource() {
+			sql = TagMetricSQL(sql, msg.MetricName, GetCollectorID(opts))
+		}
+		rows, err := DBExecReadByDbUniqueName(ctx, msg.DBUniqueName, sql)
+		if err != nil {
+			return nil, fmt.Errorf("composite statement %s: %w", stmt.Name, err)
+		}
+		results[i] = rows
+	}
+	return MergeCompositeRows(composite, data, results)
+}
+
+// MergeCompositeRows merges the rows of the composite statements, in the order of the statements, into the rows of
+// the metric query. "join" merges the columns of the rows with the same tag values, the first value of a column wins,
+// and keeps the rows without a match. "append" adds all rows tagged with the name of their statement
+func MergeCompositeRows(composite metrics.Composite, data metrics.Measurements, results []metrics.Measurements) (metrics.Measurements, error) {
+	switch composite.Merge {
+	case "", metrics.CompositeMergeJoin:
+		byTags := make(map[string]metrics.Measurement, len(data))
+		for _, row := range data {
+			byTags[rowTagsKey(row)] = row
+		}
+		for _, rows := range results {
+			for _, row := range rows {
+				key := rowTagsKey(row)
+				merged, ok := byTags[key]
+				if !ok {
+					byTags[key] = row
+					data = append(data, row)
+					continue
+				}
+				for col, v := range row {
+					if _, exists := merged[col]; !exists {
+						merged[col] = v
+					}
+				}
+			}
+		}
+	case metrics.CompositeMergeAppend:
+		for _, row := range data {
+			row[metrics.CompositeStatementTag] = metrics.CompositeMainStatement
+		}
+		for i, rows := range results {
+			for _, row := range rows {
+				row[metrics.CompositeStatementTag] = composite.Statements[i].Name
+				data = append(data, row)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown composite merge mode %q, expected %s or %s", composite.Merge,
+			metrics.CompositeMergeJoin, metrics.CompositeMergeAppend)
+	}
+	return data, nil
+}
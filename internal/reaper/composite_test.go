@@ -0,0 +1,80 @@
+package reaper
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/cmdopts"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeCompositeRows(t *testing.T) {
+	composite := metrics.Composite{Statements: []metrics.CompositeStatement{{Name: "locks"}, {Name: "waits"}}}
+	main := func() metrics.Measurements {
+		return metrics.Measurements{
+			{"epoch_ns": int64(1), "tag_db": "a", "xact_commit": int64(10)},
+			{"epoch_ns": int64(1), "tag_db": "b", "xact_commit": int64(20)},
+		}
+	}
+	results := func() []metrics.Measurements {
+		return []metrics.Measurements{
+			{{"epoch_ns": int64(2), "tag_db": "a", "locks": int64(3)}, {"epoch_ns": int64(2), "tag_db": "c", "locks": int64(1)}},
+			nil, // no SQL for the version
+		}
+	}
+
+	data, err := MergeCompositeRows(composite, main(), results())
+	require.NoError(t, err)
+	require.Len(t, data, 3)
+	assert.Equal(t, metrics.Measurement{"epoch_ns": int64(1), "tag_db": "a", "xact_commit": int64(10), "locks": int64(3)},
+		metrics.Measurement(data[0]), "joined on the tags, the first value wins")
+	assert.NotContains(t, data[1], "locks")
+	assert.Equal(t, "c", data[2]["tag_db"], "rows without a match are kept")
+
+	composite.Merge = metrics.CompositeMergeAppend
+	data, err = MergeCompositeRows(composite, main(), results())
+	require.NoError(t, err)
+	require.Len(t, data, 4)
+	assert.Equal(t, metrics.CompositeMainStatement, data[1][metrics.CompositeStatementTag])
+	assert.Equal(t, "locks", data[2][metrics.CompositeStatementTag])
+	assert.Equal(t, int64(3), data[2]["locks"])
+
+	composite.Merge = "union"
+	_, err = MergeCompositeRows(composite, main(), results())
+	assert.EqualError(t, err, `unknown composite merge mode "union", expected join or append`)
+}
+
+func TestFetchCompositeStatements(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer conn.Close()
+	md := &sources.MonitoredDatabase{Source: sources.Source{Name: "composite_db", Kind: sources.SourcePostgres}, Conn: conn}
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{md})
+	defer UpdateMonitoredDBCache(nil)
+	composite := metrics.Composite{Statements: []metrics.CompositeStatement{
+		{Name: "old", SQLs: metrics.SQLs{17: "select old"}},
+		{Name: "locks", SQLs: metrics.SQLs{11: "select locks"}},
+	}}
+	msg := MetricFetchConfig{DBUniqueName: "composite_db", MetricName: "activity"}
+	opts := &cmdopts.Options{}
+	opts.Metrics.CollectorID = "c1"
+
+	expectGuardQuery(conn, `/\* pgwatch metric=activity collector=c1 \*/ select locks`,
+		pgxmock.NewRows([]string{"tag_db", "locks"}).AddRow("a", int64(3)))
+	data, err := FetchCompositeStatements(context.Background(), msg, md, 16, composite,
+		metrics.Measurements{{"tag_db": "a", "backends": int64(5)}}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, metrics.Measurements{{"tag_db": "a", "backends": int64(5), "locks": int64(3)}}, data)
+
+	conn.ExpectBegin()
+	conn.ExpectExec("SET LOCAL").WillReturnResult(pgxmock.NewResult("SET", 0))
+	conn.ExpectQuery("select locks").WillReturnError(assert.AnError)
+	conn.ExpectCommit()
+	_, err = FetchCompositeStatements(context.Background(), msg, md, 16, composite, nil, opts)
+	assert.ErrorContains(t, err, "composite statement locks")
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
@@ -0,0 +1,130 @@
+package reaper
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/drift"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+)
+
+const (
+	configDriftMetricName = "config_drift" // internal metric with the settings deviating from the group consensus or the golden config
+	configDriftInterval   = time.Hour
+)
+
+// sqlConfigDriftSettings returns the server settings as shown by SHOW, the compile-time and session ones are left out
+const sqlConfigDriftSettings = `select /* pgwatch_generated */ name::text, current_setting(name) as value
+from pg_settings where context <> 'internal' and source not in ('client', 'session')`
+
+var configDriftReports []drift.Report // of the last analysis, for the web UI
+var configDriftReportsLock sync.RWMutex
+
+// instanceKey returns the host and port of the source, the same for all databases of an instance
+func instanceKey(md *sources.MonitoredDatabase) string {
+	if md.ConnConfig == nil {
+		return md.Name
+	}
+	return fmt.Sprintf("%s:%d", md.ConnConfig.ConnConfig.Host, md.ConnConfig.ConnConfig.Port)
+}
+
+// driftGroup returns the group the settings of the source are compared within, its HA cluster if set
+func driftGroup(md *sources.MonitoredDatabase) string {
+	return cmp.Or(md.ClusterName, md.Group, "default")
+}
+
+// fetchSettings reads the server settings of the source
+func fetchSettings(ctx context.Context, dbUnique string) (drift.Settings, error) {
+	data, err := DBExecReadByDbUniqueName(ctx, dbUnique, sqlConfigDriftSettings)
+	if err != nil {
+		return nil, err
+	}
+	settings := make(drift.Settings, len(data))
+	for _, row := range data {
+		name, _ := row["name"].(string)
+		settings[name], _ = row["value"].(string)
+	}
+	return settings, nil
+}
+
+// ConfigDriftReport implements the web server interface for the configuration drift of the last analysis
+func (r *Reaper) ConfigDriftReport() []drift.Report {
+	configDriftReportsLock.RLock()
+	defer configDriftReportsLock.RUnlock()
+	return slices.Clone(configDriftReports)
+}
+
+// ConfigDriftMeasurements compares the settings of the monitored Postgres instances within their groups and with
+// the golden configuration file, if given. The settings of every instance are read once, via its first database,
+// and the outcome is stored for all of its databases
+func ConfigDriftMeasurements(ctx context.Context, goldenFile string) []metrics.MeasurementEnvelope {
+	logger := log.GetLogger(ctx).WithField("metric", configDriftMetricName)
+	golden, err := drift.LoadGolden(goldenFile)
+	if err != nil {
+		logger.WithError(err).Warning("could not read the golden configuration, comparing with the group consensus only")
+	}
+
+	monitoredDbCacheLock.RLock()
+	instances := make(map[string][]*sources.MonitoredDatabase)
+	for _, md := range monitoredDbCache {
+		if md.IsPostgresSource() && md.Conn != nil {
+			instances[instanceKey(md)] = append(instances[instanceKey(md)], md)
+		}
+	}
+	monitoredDbCacheLock.RUnlock()
+
+	hosts := make([]drift.Host, 0, len(instances))
+	dbs := make(map[string][]*sources.MonitoredDatabase) // [reported source]=databases of the instance
+	for _, key := range slices.Sorted(maps.Keys(instances)) {
+		mdbs := instances[key]
+		slices.SortFunc(mdbs, func(a, b *sources.MonitoredDatabase) int { return strings.Compare(a.Name, b.Name) })
+		settings, err := fetchSettings(ctx, mdbs[0].Name)
+		if err != nil {
+			logger.WithField("source", mdbs[0].Name).WithError(err).Debug("could not read the server settings")
+			continue
+		}
+		hosts = append(hosts, drift.Host{Source: mdbs[0].Name, Group: driftGroup(mdbs[0]), Settings: settings})
+		dbs[mdbs[0].Name] = mdbs
+	}
+	reports := drift.Analyze(hosts, golden)
+	configDriftReportsLock.Lock()
+	configDriftReports = reports
+	configDriftReportsLock.Unlock()
+
+	now := time.Now().UnixNano()
+	msgs := make([]metrics.MeasurementEnvelope, 0, len(reports))
+	for _, r := range reports {
+		deviations := make([]string, 0, len(r.Deviations))
+		for _, d := range r.Deviations {
+			deviations = append(deviations, d.String())
+		}
+		if len(deviations) > 0 {
+			logger.WithField("source", r.Source).WithField("group", r.Group).Debugf("configuration drift: %s", strings.Join(deviations, "; "))
+		}
+		row := metrics.Measurement{
+			epochColumnName:          now,
+			"drift_group":            r.Group,
+			"group_size":             r.GroupSize,
+			"drifted_settings_count": len(r.Deviations),
+			"drifted_settings":       strings.Join(deviations, ", "),
+		}
+		for _, md := range dbs[r.Source] {
+			msgs = append(msgs, metrics.MeasurementEnvelope{
+				DBName:     md.Name,
+				SourceType: string(md.Kind),
+				MetricName: configDriftMetricName,
+				CustomTags: md.CustomTags,
+				Data:       metrics.Measurements{maps.Clone(row)},
+			})
+		}
+	}
+	return msgs
+}
@@ -0,0 +1,66 @@
+package reaper
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigDriftMeasurements(t *testing.T) {
+	newSource := func(name, connStr string, conn pgxmock.PgxPoolIface) *sources.MonitoredDatabase {
+		cfg, err := pgxpool.ParseConfig(connStr)
+		require.NoError(t, err)
+		return &sources.MonitoredDatabase{Source: sources.Source{Name: name, Group: "prod", Kind: sources.SourcePostgres}, Conn: conn, ConnConfig: cfg}
+	}
+	expectSettings := func(conn pgxmock.PgxPoolIface, workMem string) {
+		conn.ExpectBegin()
+		conn.ExpectExec("SET LOCAL").WillReturnResult(pgxmock.NewResult("SET", 0))
+		conn.ExpectQuery("from pg_settings").WillReturnRows(pgxmock.NewRows([]string{"name", "value"}).
+			AddRow("work_mem", workMem).AddRow("port", "5432"))
+		conn.ExpectCommit()
+	}
+	conns := make([]pgxmock.PgxPoolIface, 4)
+	for i := range conns {
+		var err error
+		conns[i], err = pgxmock.NewPool()
+		require.NoError(t, err)
+	}
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{
+		newSource("host1_db1", "postgres://host1/db1", conns[0]),
+		newSource("host1_db2", "postgres://host1/db2", conns[1]), // same instance, not read again
+		newSource("host2", "postgres://host2/db", conns[2]),
+		newSource("host3", "postgres://host3/db", conns[3]),
+	})
+	defer UpdateMonitoredDBCache(nil)
+	expectSettings(conns[0], "64MB")
+	expectSettings(conns[2], "4MB")
+	expectSettings(conns[3], "4MB")
+
+	msgs := ConfigDriftMeasurements(context.Background(), "")
+	for _, conn := range conns {
+		assert.NoError(t, conn.ExpectationsWereMet())
+	}
+	require.Len(t, msgs, 4)
+	drifted := make(map[string]any)
+	for _, msg := range msgs {
+		assert.Equal(t, configDriftMetricName, msg.MetricName)
+		assert.Equal(t, 3, msg.Data[0]["group_size"])
+		drifted[msg.DBName] = msg.Data[0]["drifted_settings"]
+	}
+	assert.Equal(t, "work_mem=64MB (expected: 4MB, consensus)", drifted["host1_db1"])
+	assert.Equal(t, drifted["host1_db1"], drifted["host1_db2"], "all databases of the instance get the outcome")
+	assert.Equal(t, "", drifted["host2"])
+
+	r := &Reaper{}
+	reports := r.ConfigDriftReport()
+	require.Len(t, reports, 3)
+	assert.Equal(t, "host1_db1", reports[0].Source)
+
+	forgetRemovedSources(nil)
+	assert.Empty(t, r.ConfigDriftReport())
+}
@@ -0,0 +1,144 @@
+package reaper
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	connectLatencyMetricName = "connect_latency" // internal metric with the connection establishment timing of the monitored DBs
+	connectLatencyInterval   = time.Minute
+)
+
+// connectTiming collects the phases of a single connection attempt, DNS lookups and TCP dials
+// of all tried hosts and fallbacks are summed up. The TLS handshake happens lazily when the
+// startup message is sent, so it is only measurable together with the authentication
+type connectTiming struct {
+	start time.Time
+	dns   time.Duration
+	tcp   time.Duration
+}
+
+type connectTimingKey struct{}
+
+// ConnectLatency accumulates the connection establishment timing of a monitored DB since the last report
+type ConnectLatency struct {
+	Connects int64
+	Failures int64
+	Total    time.Duration
+	TotalMax time.Duration
+	DNS      time.Duration
+	TCP      time.Duration
+	TLS      bool
+}
+
+var connectLatencies = make(map[string]ConnectLatency)
+var connectLatencyLock sync.Mutex
+
+// RecordConnectLatency registers a finished connection attempt to the monitored DB
+func RecordConnectLatency(dbUnique string, t *connectTiming, usesTLS bool, err error) {
+	connectLatencyLock.Lock()
+	defer connectLatencyLock.Unlock()
+	cl := connectLatencies[dbUnique]
+	if err != nil {
+		cl.Failures++
+		connectLatencies[dbUnique] = cl
+		return
+	}
+	total := time.Since(t.start)
+	cl.Connects++
+	cl.Total += total
+	cl.TotalMax = max(cl.TotalMax, total)
+	cl.DNS += t.dns
+	cl.TCP += t.tcp
+	cl.TLS = usesTLS
+	connectLatencies[dbUnique] = cl
+}
+
+func (t *overheadTracer) TraceConnectStart(ctx context.Context, data pgx.TraceConnectStartData) context.Context {
+	ctx = t.TraceLog.TraceConnectStart(ctx, data)
+	return context.WithValue(ctx, connectTimingKey{}, &connectTiming{start: time.Now()})
+}
+
+func (t *overheadTracer) TraceConnectEnd(ctx context.Context, data pgx.TraceConnectEndData) {
+	if timing, ok := ctx.Value(connectTimingKey{}).(*connectTiming); ok {
+		var usesTLS bool
+		if data.Conn != nil {
+			_, usesTLS = data.Conn.PgConn().Conn().(*tls.Conn)
+		}
+		RecordConnectLatency(t.dbUnique, timing, usesTLS, data.Err)
+	}
+	t.TraceLog.TraceConnectEnd(ctx, data)
+}
+
+// withConnectTiming wraps the DNS lookup and dial functions of the pool config
+// to measure the phases of the connection attempts traced by the overhead tracer
+func withConnectTiming(conf *pgxpool.Config) {
+	lookup, dial := conf.ConnConfig.LookupFunc, conf.ConnConfig.DialFunc
+	if lookup == nil || dial == nil {
+		return
+	}
+	conf.ConnConfig.LookupFunc = func(ctx context.Context, host string) ([]string, error) {
+		start := time.Now()
+		addrs, err := lookup(ctx, host)
+		if timing, ok := ctx.Value(connectTimingKey{}).(*connectTiming); ok {
+			timing.dns += time.Since(start)
+		}
+		return addrs, err
+	}
+	conf.ConnConfig.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		start := time.Now()
+		conn, err := dial(ctx, network, addr)
+		if timing, ok := ctx.Value(connectTimingKey{}).(*connectTiming); ok {
+			timing.tcp += time.Since(start)
+		}
+		return conn, err
+	}
+}
+
+// ConnectLatencyMeasurements returns the average and maximum connection establishment timing
+// of the monitored DBs new connections were opened to since the previous call
+func ConnectLatencyMeasurements(time.Duration) []metrics.MeasurementEnvelope {
+	connectLatencyLock.Lock()
+	samples := connectLatencies
+	connectLatencies = make(map[string]ConnectLatency)
+	connectLatencyLock.Unlock()
+
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	now := time.Now()
+	msgs := make([]metrics.MeasurementEnvelope, 0, len(samples))
+	for dbUnique, cl := range samples {
+		md, err := GetMonitoredDatabaseByUniqueName(dbUnique)
+		if err != nil {
+			continue
+		}
+		row := metrics.Measurement{epochColumnName: now.UnixNano(), "connects": cl.Connects, "failures": cl.Failures}
+		if cl.Connects > 0 {
+			n := time.Duration(cl.Connects)
+			row["total_ms_avg"] = ms(cl.Total / n)
+			row["total_ms_max"] = ms(cl.TotalMax)
+			row["dns_ms_avg"] = ms(cl.DNS / n)
+			row["tcp_ms_avg"] = ms(cl.TCP / n)
+			row["tls_auth_ms_avg"] = ms((cl.Total - cl.DNS - cl.TCP) / n)
+			row["tls_int"] = 0
+			if cl.TLS {
+				row["tls_int"] = 1
+			}
+		}
+		msgs = append(msgs, metrics.MeasurementEnvelope{
+			DBName:     dbUnique,
+			SourceType: string(md.Kind),
+			MetricName: connectLatencyMetricName,
+			CustomTags: md.CustomTags,
+			Data:       metrics.Measurements{row},
+		})
+	}
+	return msgs
+}
@@ -0,0 +1,79 @@
+package reaper
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/tracelog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectLatencyMeasurements(t *testing.T) {
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{
+		{Source: sources.Source{Name: "latency_db", Kind: sources.SourcePostgres, CustomTags: map[string]string{"env": "test"}}},
+	})
+	defer UpdateMonitoredDBCache(nil)
+
+	now := time.Now()
+	RecordConnectLatency("latency_db", &connectTiming{start: now.Add(-30 * time.Millisecond), dns: 5 * time.Millisecond, tcp: 10 * time.Millisecond}, true, nil)
+	RecordConnectLatency("latency_db", &connectTiming{start: now.Add(-10 * time.Millisecond), dns: 5 * time.Millisecond, tcp: 10 * time.Millisecond}, true, nil)
+	RecordConnectLatency("latency_db", &connectTiming{start: now}, false, errors.New("connection refused"))
+	RecordConnectLatency("removed_db", &connectTiming{start: now}, false, nil)
+
+	msgs := ConnectLatencyMeasurements(time.Minute)
+	require.Len(t, msgs, 1, "sources no longer monitored are skipped")
+	assert.Equal(t, connectLatencyMetricName, msgs[0].MetricName)
+	assert.Equal(t, "test", msgs[0].CustomTags["env"])
+	row := msgs[0].Data[0]
+	assert.EqualValues(t, 2, row["connects"])
+	assert.EqualValues(t, 1, row["failures"])
+	assert.Equal(t, 5.0, row["dns_ms_avg"])
+	assert.Equal(t, 10.0, row["tcp_ms_avg"])
+	assert.GreaterOrEqual(t, row["total_ms_max"], 30.0)
+	assert.GreaterOrEqual(t, row["tls_auth_ms_avg"], 5.0)
+	assert.Equal(t, 1, row["tls_int"])
+
+	assert.Empty(t, ConnectLatencyMeasurements(time.Minute), "only connections opened since the previous call are reported")
+}
+
+func TestConnectTiming(t *testing.T) {
+	conf, err := pgxpool.ParseConfig("postgres://foo@db.invalid:5432/bar?connect_timeout=1")
+	require.NoError(t, err)
+	conf.ConnConfig.Tracer = &tracelog.TraceLog{Logger: log.NewPgxLogger(log.FallbackLogger), LogLevel: tracelog.LogLevelNone}
+	conf.ConnConfig.LookupFunc = func(context.Context, string) ([]string, error) {
+		time.Sleep(5 * time.Millisecond)
+		return []string{"127.0.0.1"}, nil
+	}
+	conf.ConnConfig.DialFunc = func(context.Context, string, string) (net.Conn, error) {
+		time.Sleep(5 * time.Millisecond)
+		return nil, errors.New("connection refused")
+	}
+	require.NoError(t, WithOverheadTracer("timed_db")(conf))
+	defer func() {
+		connectLatencyLock.Lock()
+		delete(connectLatencies, "timed_db")
+		connectLatencyLock.Unlock()
+	}()
+
+	tracer := conf.ConnConfig.Tracer.(*overheadTracer)
+	ctx := tracer.TraceConnectStart(context.Background(), pgx.TraceConnectStartData{ConnConfig: conf.ConnConfig})
+	timing := ctx.Value(connectTimingKey{}).(*connectTiming)
+	_, _ = conf.ConnConfig.LookupFunc(ctx, "db.invalid")
+	_, _ = conf.ConnConfig.DialFunc(ctx, "tcp", "127.0.0.1:5432")
+	assert.GreaterOrEqual(t, timing.dns, 5*time.Millisecond)
+	assert.GreaterOrEqual(t, timing.tcp, 5*time.Millisecond)
+
+	_, err = pgx.ConnectConfig(context.Background(), conf.ConnConfig)
+	assert.Error(t, err)
+	connectLatencyLock.Lock()
+	assert.EqualValues(t, 1, connectLatencies["timed_db"].Failures, "failed attempts of the pool connections are counted")
+	connectLatencyLock.Unlock()
+}
@@ -0,0 +1,124 @@
+package reaper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+// After the connection limit of a monitored server is hit, all fetches of the host are paused for the backoff
+// period, doubled on every repeated hit. Afterwards only a single fetch of the host may run at a time for
+// connLimitReducedFactor backoff periods, so that the pools don't open more than one connection each
+const (
+	connLimitBackoffMin    = 30 * time.Second
+	connLimitBackoffMax    = 10 * time.Minute
+	connLimitReducedFactor = 4
+)
+
+var errConnLimitBackoff = errors.New("connection limit of the host reached, backing off")
+
+// connLimitState tracks the connection limit exhaustion of a physical host, guarded by hostLimitersLock
+type connLimitState struct {
+	hits         int64
+	backoff      time.Duration
+	backoffUntil time.Time
+	reducedUntil time.Time
+	reduced      chan struct{} // the single fetch slot while recovering
+}
+
+var connLimits = make(map[string]*connLimitState) // [host:port]=state
+
+// idleReleaser is implemented by pgxpool.Pool, closing the idle connections frees the server slots
+type idleReleaser interface {
+	Reset()
+}
+
+// recordConnLimitHit starts or prolongs the backoff of the host, false is returned if the host is backing off already
+func recordConnLimitHit(dbUnique string, now time.Time) (host string, backoff time.Duration, started bool) {
+	hostLimitersLock.Lock()
+	defer hostLimitersLock.Unlock()
+	host = fetchHost(dbUnique)
+	cl, ok := connLimits[host]
+	if !ok {
+		cl = &connLimitState{reduced: make(chan struct{}, 1)}
+		connLimits[host] = cl
+	}
+	if now.Before(cl.backoffUntil) {
+		return host, cl.backoff, false
+	}
+	if now.Before(cl.reducedUntil) {
+		cl.backoff = min(cl.backoff*2, connLimitBackoffMax)
+	} else {
+		cl.backoff = connLimitBackoffMin
+	}
+	cl.hits++
+	cl.backoffUntil = now.Add(cl.backoff)
+	cl.reducedUntil = cl.backoffUntil.Add(cl.backoff * connLimitReducedFactor)
+	return host, cl.backoff, true
+}
+
+// connLimitSlot returns the single fetch slot to acquire if the host is recovering from
+// the connection limit exhaustion, errConnLimitBackoff while still backing off. Must be called with the lock held
+func connLimitSlot(host string, now time.Time) (chan struct{}, error) {
+	cl, ok := connLimits[host]
+	if !ok || !now.Before(cl.reducedUntil) {
+		return nil, nil
+	}
+	if now.Before(cl.backoffUntil) {
+		return nil, errConnLimitBackoff
+	}
+	return cl.reduced, nil
+}
+
+// releaseIdleConnections closes the idle pool connections of all the monitored DBs of the host
+func releaseIdleConnections(host string) (released int) {
+	monitoredDbCacheLock.RLock()
+	dbs := maps.Clone(monitoredDbCache)
+	monitoredDbCacheLock.RUnlock()
+	hostLimitersLock.Lock()
+	for dbUnique := range dbs {
+		if fetchHost(dbUnique) != host {
+			delete(dbs, dbUnique)
+		}
+	}
+	hostLimitersLock.Unlock()
+	for _, md := range dbs {
+		if p, ok := md.Conn.(idleReleaser); ok {
+			p.Reset()
+			released++
+		}
+	}
+	return
+}
+
+// handleConnectionLimit backs off all fetches of the host of the monitored DB, releases the idle connections
+// of its pools and stores a server event, instead of every gatherer retrying on its own
+func (r *Reaper) handleConnectionLimit(ctx context.Context, dbUnique string) {
+	host, backoff, started := recordConnLimitHit(dbUnique, time.Now())
+	if !started {
+		return
+	}
+	pools := releaseIdleConnections(host)
+	message := fmt.Sprintf("Connection limit of host %s reached (via \"%s\"), pausing fetches for %v", host, dbUnique, backoff)
+	log.GetLogger(ctx).WithField("source", dbUnique).WithField("pools", pools).Warning(message)
+	md, err := GetMonitoredDatabaseByUniqueName(dbUnique)
+	if err != nil {
+		return
+	}
+	event := metrics.MeasurementEnvelope{
+		DBName:     md.Name,
+		SourceType: string(md.Kind),
+		MetricName: "object_changes",
+		Data:       metrics.Measurements{{"details": message, epochColumnName: time.Now().UnixNano()}},
+		CustomTags: md.CustomTags,
+	}
+	select {
+	case r.measurementCh <- []metrics.MeasurementEnvelope{event}:
+	case <-ctx.Done():
+	}
+}
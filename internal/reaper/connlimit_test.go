@@ -0,0 +1,83 @@
+package reaper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/cmdopts"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordConnLimitHit(t *testing.T) {
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{
+		{Source: sources.Source{Name: "full1", ConnStr: "postgres://fullhost:5432/db1"}},
+		{Source: sources.Source{Name: "full2", ConnStr: "postgres://fullhost:5432/db2"}},
+	})
+	defer UpdateMonitoredDBCache(nil)
+	defer func() {
+		hostLimitersLock.Lock()
+		delete(connLimits, "fullhost:5432")
+		hostLimitersLock.Unlock()
+	}()
+
+	now := time.Now()
+	host, backoff, started := recordConnLimitHit("full1", now)
+	assert.True(t, started)
+	assert.Equal(t, "fullhost:5432", host)
+	assert.Equal(t, connLimitBackoffMin, backoff)
+	_, _, started = recordConnLimitHit("full2", now.Add(time.Second))
+	assert.False(t, started, "the whole host is backing off already")
+
+	hostLimitersLock.Lock()
+	_, err := connLimitSlot(host, now.Add(time.Second))
+	assert.ErrorIs(t, err, errConnLimitBackoff)
+	reduced, err := connLimitSlot(host, now.Add(connLimitBackoffMin+time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cap(reduced), "single fetch at a time while recovering")
+	reduced, err = connLimitSlot(host, now.Add(connLimitBackoffMin*(connLimitReducedFactor+1)+time.Second))
+	assert.NoError(t, err)
+	assert.Nil(t, reduced, "recovered")
+	hostLimitersLock.Unlock()
+
+	_, backoff, started = recordConnLimitHit("full2", now.Add(connLimitBackoffMin+time.Second))
+	assert.True(t, started)
+	assert.Equal(t, 2*connLimitBackoffMin, backoff, "repeated hits while recovering double the backoff")
+}
+
+func TestHandleConnectionLimit(t *testing.T) {
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{
+		{Source: sources.Source{Name: "limited", ConnStr: "postgres://limitedhost:5432/db1", Kind: sources.SourcePostgres}},
+	})
+	defer UpdateMonitoredDBCache(nil)
+	defer func() {
+		hostLimitersLock.Lock()
+		delete(connLimits, "limitedhost:5432")
+		hostLimitersLock.Unlock()
+	}()
+	r := NewReaper(&cmdopts.Options{}, nil, nil)
+	ctx := context.Background()
+
+	r.handleConnectionLimit(ctx, "limited")
+	r.handleConnectionLimit(ctx, "limited")
+	require.Len(t, r.measurementCh, 1, "one event per backoff")
+	event := (<-r.measurementCh)[0]
+	assert.Equal(t, "object_changes", event.MetricName)
+	assert.Contains(t, event.Data[0]["details"], "Connection limit of host limitedhost:5432 reached")
+
+	_, err := acquireFetchSlot(ctx, "limited", 0, time.Second)
+	assert.ErrorIs(t, err, errConnLimitBackoff, "backoff applies without the fetch limit too")
+	assert.Equal(t, FetchErrorBackoff, ClassifyFetchError(err))
+	assert.Equal(t, FetchErrorActionSkip, ClassifyFetchError(err).Action(false))
+
+	var limit HostFetchLimit
+	for _, fl := range HostFetchLimits() {
+		if fl.Host == "limitedhost:5432" {
+			limit = fl
+		}
+	}
+	assert.EqualValues(t, 1, limit.ConnLimitHits)
+	assert.NotEmpty(t, limit.BackoffUntil)
+}
@@ -0,0 +1,209 @@
+package reaper
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/db"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	connectionSecurityMetricName = "connection_security" // internal metric with the encryption and authentication of the monitoring connections
+	connectionSecurityInterval   = time.Hour
+	connectionSecurityTimeout    = 10 * time.Second
+)
+
+// effectiveSSLMode derives the sslmode from the TLS settings pgx made out of the connection string
+func effectiveSSLMode(cfg *pgconn.Config) string {
+	hasPlainFallback := slices.ContainsFunc(cfg.Fallbacks, func(f *pgconn.FallbackConfig) bool { return f.TLSConfig == nil })
+	switch {
+	case cfg.TLSConfig == nil && slices.ContainsFunc(cfg.Fallbacks, func(f *pgconn.FallbackConfig) bool { return f.TLSConfig != nil }):
+		return "allow"
+	case cfg.TLSConfig == nil:
+		return "disable"
+	case cfg.TLSConfig.InsecureSkipVerify && cfg.TLSConfig.VerifyPeerCertificate != nil:
+		return "verify-ca"
+	case cfg.TLSConfig.InsecureSkipVerify && hasPlainFallback:
+		return "prefer"
+	case cfg.TLSConfig.InsecureSkipVerify:
+		return "require"
+	}
+	return "verify-full"
+}
+
+var tlsDowngrades = make(map[string]int64) // [db1]=plaintext connections made although TLS was asked for, since the last measurement
+var tlsDowngradesLock sync.Mutex
+
+// applyTLSPolicy removes the connection attempts the TLS policy forbids from the config
+func applyTLSPolicy(cfg *pgconn.Config, policy string) error {
+	plaintext := func(f *pgconn.FallbackConfig) bool { return f.TLSConfig == nil }
+	switch policy {
+	case "", sources.TLSPolicyPreferWithWarning:
+	case sources.TLSPolicyRequireVerify:
+		if mode := effectiveSSLMode(cfg); mode != "verify-ca" && mode != "verify-full" {
+			return fmt.Errorf("tls_policy %s needs sslmode verify-ca or verify-full, got %s", policy, mode)
+		}
+		cfg.Fallbacks = slices.DeleteFunc(cfg.Fallbacks, plaintext)
+	case sources.TLSPolicyDisallowDowngrade:
+		if cfg.TLSConfig != nil {
+			cfg.Fallbacks = slices.DeleteFunc(cfg.Fallbacks, plaintext)
+		}
+	default:
+		return fmt.Errorf("unknown tls_policy %q", policy)
+	}
+	return nil
+}
+
+// isTLSConn returns true if the connection to the server is encrypted
+func isTLSConn(conn *pgconn.PgConn) bool {
+	_, ok := conn.Conn().(*tls.Conn)
+	return ok
+}
+
+// WithTLSPolicy enforces the TLS policy of the source and records every connection established without TLS
+// although the sslmode asked for it, i.e. the plaintext fallback of sslmode=prefer
+func WithTLSPolicy(dbUnique, policy string) db.ConnConfigCallback {
+	return func(conf *pgxpool.Config) error {
+		if err := applyTLSPolicy(&conf.ConnConfig.Config, policy); err != nil {
+			return err
+		}
+		if conf.ConnConfig.TLSConfig == nil {
+			return nil
+		}
+		afterConnect := conf.AfterConnect
+		conf.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+			if !isTLSConn(conn.PgConn()) {
+				tlsDowngradesLock.Lock()
+				tlsDowngrades[dbUnique]++
+				tlsDowngradesLock.Unlock()
+				l := log.GetLogger(ctx).WithField("source", dbUnique)
+				if policy == sources.TLSPolicyPreferWithWarning {
+					l.Warning("connected without TLS, the server does not support it")
+				} else {
+					l.Debug("connected without TLS, the server does not support it")
+				}
+			}
+			if afterConnect != nil {
+				return afterConnect(ctx, conn)
+			}
+			return nil
+		}
+		return nil
+	}
+}
+
+// drainTLSDowngrades returns the number of downgraded connections since the last call
+func drainTLSDowngrades(dbUnique string) int64 {
+	tlsDowngradesLock.Lock()
+	defer tlsDowngradesLock.Unlock()
+	n := tlsDowngrades[dbUnique]
+	delete(tlsDowngrades, dbUnique)
+	return n
+}
+
+// tlsMeasurement describes the encryption of the established connection
+func tlsMeasurement(state *tls.ConnectionState, host string, now time.Time) metrics.Measurement {
+	row := metrics.Measurement{"ssl_int": 0}
+	if state == nil {
+		return row
+	}
+	row["ssl_int"] = 1
+	row["tls_version"] = tls.VersionName(state.Version)
+	row["cipher"] = tls.CipherSuiteName(state.CipherSuite)
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		row["cert_not_after_s"] = cert.NotAfter.Unix()
+		row["cert_expires_in_days"] = int64(cert.NotAfter.Sub(now).Hours() / 24)
+		row["cert_san_match_int"] = 0
+		if cert.VerifyHostname(host) == nil {
+			row["cert_san_match_int"] = 1
+		}
+	}
+	return row
+}
+
+// getConnectionSecurity inspects a pooled connection of the monitored DB
+func getConnectionSecurity(ctx context.Context, md *sources.MonitoredDatabase, now time.Time) (metrics.Measurement, error) {
+	ctx, cancel := context.WithTimeout(ctx, connectionSecurityTimeout)
+	defer cancel()
+	conn, err := md.Conn.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	cfg := md.Conn.Config().ConnConfig
+	var state *tls.ConnectionState
+	if tlsConn, ok := conn.Conn().PgConn().Conn().(*tls.Conn); ok {
+		s := tlsConn.ConnectionState()
+		state = &s
+	}
+	row := tlsMeasurement(state, cfg.Host, now)
+	row[epochColumnName] = now.UnixNano()
+	row["sslmode"] = effectiveSSLMode(&cfg.Config)
+	row["tls_policy"] = md.HostConfig.TLSPolicy
+	row["downgraded_int"] = 0
+	if cfg.TLSConfig != nil && state == nil {
+		row["downgraded_int"] = 1
+	}
+	row["downgraded_connects"] = drainTLSDowngrades(md.Name)
+
+	// system_user is "auth_method:identity" or NULL if no identity was authenticated, i.e. trust
+	MonitoredDatabasesSettingsLock.RLock()
+	version := MonitoredDatabasesSettings[md.Name].Version
+	MonitoredDatabasesSettingsLock.RUnlock()
+	if md.IsPostgresSource() && version >= 16 {
+		var systemUser *string
+		if err = conn.QueryRow(ctx, "select /* pgwatch_generated */ system_user").Scan(&systemUser); err != nil {
+			return nil, err
+		}
+		row["auth_method"] = "trust"
+		if systemUser != nil {
+			row["auth_method"], _, _ = strings.Cut(*systemUser, ":")
+		}
+	}
+	return row, nil
+}
+
+// ConnectionSecurityMeasurements returns the security posture of the monitoring connection to every source:
+// sslmode, TLS version and cipher, server certificate expiry and host name match, authentication method, and the
+// connections made without TLS although it was asked for
+func ConnectionSecurityMeasurements(ctx context.Context) []metrics.MeasurementEnvelope {
+	monitoredDbCacheLock.RLock()
+	mdbs := make([]*sources.MonitoredDatabase, 0, len(monitoredDbCache))
+	for _, md := range monitoredDbCache {
+		if md.Conn != nil {
+			mdbs = append(mdbs, md)
+		}
+	}
+	monitoredDbCacheLock.RUnlock()
+
+	now := time.Now()
+	msgs := make([]metrics.MeasurementEnvelope, 0, len(mdbs))
+	for _, md := range mdbs {
+		row, err := getConnectionSecurity(ctx, md, now)
+		if err != nil {
+			log.GetLogger(ctx).WithField("source", md.Name).WithError(err).Debug("could not inspect connection security")
+			continue
+		}
+		msgs = append(msgs, metrics.MeasurementEnvelope{
+			DBName:     md.Name,
+			SourceType: string(md.Kind),
+			MetricName: connectionSecurityMetricName,
+			CustomTags: md.CustomTags,
+			Data:       metrics.Measurements{row},
+		})
+	}
+	return msgs
+}
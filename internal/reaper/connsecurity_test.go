@@ -0,0 +1,134 @@
+package reaper
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEffectiveSSLMode(t *testing.T) {
+	for _, mode := range []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"} {
+		cfg, err := pgconn.ParseConfig("postgres://localhost/db?sslmode=" + mode)
+		require.NoError(t, err)
+		assert.Equal(t, mode, effectiveSSLMode(cfg), mode)
+	}
+	cfg, err := pgconn.ParseConfig("postgres://localhost/db")
+	require.NoError(t, err)
+	assert.Equal(t, "prefer", effectiveSSLMode(cfg), "default sslmode")
+}
+
+func TestTLSMeasurement(t *testing.T) {
+	now := time.Now()
+	row := tlsMeasurement(nil, "localhost", now)
+	assert.Equal(t, 0, row["ssl_int"])
+	assert.NotContains(t, row, "tls_version")
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"db.example.com"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(30*24*time.Hour + time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	state := &tls.ConnectionState{
+		Version:          tls.VersionTLS13,
+		CipherSuite:      tls.TLS_AES_128_GCM_SHA256,
+		PeerCertificates: []*x509.Certificate{cert},
+	}
+	row = tlsMeasurement(state, "db.example.com", now)
+	assert.Equal(t, 1, row["ssl_int"])
+	assert.Equal(t, "TLS 1.3", row["tls_version"])
+	assert.Equal(t, "TLS_AES_128_GCM_SHA256", row["cipher"])
+	assert.Equal(t, cert.NotAfter.Unix(), row["cert_not_after_s"])
+	assert.Equal(t, int64(30), row["cert_expires_in_days"])
+	assert.Equal(t, 1, row["cert_san_match_int"])
+
+	row = tlsMeasurement(state, "10.0.0.1", now)
+	assert.Equal(t, 0, row["cert_san_match_int"])
+}
+
+func TestConnectionSecurityMeasurements(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer conn.Close()
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{
+		{Source: sources.Source{Name: "no_conn", Kind: sources.SourcePostgres}},
+		{Source: sources.Source{Name: "unreachable", Kind: sources.SourcePostgres}, Conn: conn},
+	})
+	defer UpdateMonitoredDBCache(nil)
+
+	// sources without a connection or failing to provide one are skipped
+	assert.Empty(t, ConnectionSecurityMeasurements(context.Background()))
+}
+
+func TestApplyTLSPolicy(t *testing.T) {
+	parse := func(sslmode string) *pgconn.Config {
+		cfg, err := pgconn.ParseConfig("postgres://localhost/db?sslmode=" + sslmode)
+		require.NoError(t, err)
+		return cfg
+	}
+
+	cfg := parse("prefer")
+	require.NoError(t, applyTLSPolicy(cfg, ""))
+	assert.Equal(t, "prefer", effectiveSSLMode(cfg), "no policy keeps the plaintext fallback")
+	require.NoError(t, applyTLSPolicy(cfg, sources.TLSPolicyPreferWithWarning))
+	assert.Equal(t, "prefer", effectiveSSLMode(cfg))
+
+	require.NoError(t, applyTLSPolicy(cfg, sources.TLSPolicyDisallowDowngrade))
+	assert.Equal(t, "require", effectiveSSLMode(cfg), "plaintext fallback dropped")
+
+	cfg = parse("allow")
+	require.NoError(t, applyTLSPolicy(cfg, sources.TLSPolicyDisallowDowngrade))
+	assert.Equal(t, "allow", effectiveSSLMode(cfg), "no TLS asked for, nothing to downgrade")
+
+	for _, mode := range []string{"disable", "allow", "prefer", "require"} {
+		assert.Error(t, applyTLSPolicy(parse(mode), sources.TLSPolicyRequireVerify), mode)
+	}
+	cfg = parse("verify-full")
+	require.NoError(t, applyTLSPolicy(cfg, sources.TLSPolicyRequireVerify))
+	assert.Equal(t, "verify-full", effectiveSSLMode(cfg))
+
+	assert.Error(t, applyTLSPolicy(parse("prefer"), "maybe"))
+}
+
+func TestWithTLSPolicy(t *testing.T) {
+	conf, err := pgxpool.ParseConfig("postgres://localhost/db?sslmode=prefer")
+	require.NoError(t, err)
+	require.NoError(t, WithTLSPolicy("tls_src", sources.TLSPolicyPreferWithWarning)(conf))
+	assert.NotNil(t, conf.AfterConnect, "downgrades are recorded if TLS is asked for")
+	assert.Zero(t, drainTLSDowngrades("tls_src"))
+
+	tlsDowngradesLock.Lock()
+	tlsDowngrades["tls_src"] = 2
+	tlsDowngradesLock.Unlock()
+	assert.EqualValues(t, 2, drainTLSDowngrades("tls_src"))
+	assert.Zero(t, drainTLSDowngrades("tls_src"), "drained")
+
+	conf, err = pgxpool.ParseConfig("postgres://localhost/db?sslmode=disable")
+	require.NoError(t, err)
+	require.NoError(t, WithTLSPolicy("tls_src", "")(conf))
+	assert.Nil(t, conf.AfterConnect, "nothing to downgrade")
+
+	conf, err = pgxpool.ParseConfig("postgres://localhost/db?sslmode=require")
+	require.NoError(t, err)
+	assert.Error(t, WithTLSPolicy("tls_src", sources.TLSPolicyRequireVerify)(conf))
+}
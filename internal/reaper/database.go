@@ -11,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/cmdopts"
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/db"
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
@@ -31,7 +32,7 @@ func InitSQLConnPoolForMonitoredDBIfNil(ctx context.Context, md *sources.Monitor
 	md.Conn, err = db.New(ctx, md.ConnStr, func(conf *pgxpool.Config) error {
 		conf.MaxConns = int32(maxConns)
 		return nil
-	})
+	}, WithOverheadTracer(md.Name))
 	if err != nil {
 		return err
 	}
@@ -74,19 +75,103 @@ func DBExecReadByDbUniqueName(ctx context.Context, dbUnique string, sql string,
 	}
 	defer func() { _ = tx.Commit(ctx) }()
 	if md.IsPostgresSource() {
-		_, err = tx.Exec(ctx, "SET LOCAL lock_timeout TO '100ms'")
-		if err != nil {
-			return nil, err
+		setLocals := []string{"SET LOCAL lock_timeout TO '100ms'"}
+		if deadline, ok := ctx.Deadline(); ok { // let the server cancel the query first, client-side deadline is just a safety net
+			if stmtTimeout := time.Until(deadline) - clientTimeoutMargin; stmtTimeout > 0 {
+				setLocals = append(setLocals, fmt.Sprintf("SET LOCAL statement_timeout TO '%dms'", stmtTimeout.Milliseconds()))
+			}
+		}
+		if metric := queryMetric(ctx); metric != "" { // attribute the load to the metric on the server, e.g. in pg_stat_activity
+			setLocals = append(setLocals, fmt.Sprintf("SET LOCAL application_name TO '%s'", strings.ReplaceAll(metricApplicationName(metric), "'", "''")))
+		}
+		if !md.HostConfig.ViaPgBouncer { // a single round trip, poolers may refuse multi-statement queries
+			setLocals = []string{strings.Join(setLocals, "; ")}
+		}
+		for _, setLocal := range setLocals {
+			if _, err = tx.Exec(ctx, setLocal); err != nil {
+				return nil, err
+			}
+		}
+	}
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	var bytesFetched int64
+	data, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (map[string]any, error) {
+		for _, v := range row.RawValues() {
+			bytesFetched += int64(len(v))
+		}
+		return pgx.RowToMap(row)
+	})
+	RecordBytesFetched(dbUnique, bytesFetched)
+	if err == nil && len(data) > 0 && md.IsPostgresSource() {
+		addAuroraInstanceTag(ctx, tx, dbUnique, data)
+	}
+	return data, err
+}
+
+// addAuroraInstanceTag tags the rows with the identifier of the Aurora instance the query was executed on.
+// Cluster endpoints route connections to different instances, so it's checked in the same transaction
+func addAuroraInstanceTag(ctx context.Context, tx pgx.Tx, dbUnique string, data metrics.Measurements) {
+	MonitoredDatabasesSettingsLock.RLock()
+	execEnv := MonitoredDatabasesSettings[dbUnique].ExecEnv
+	MonitoredDatabasesSettingsLock.RUnlock()
+	if execEnv != execEnvAwsAurora {
+		return
+	}
+	var instanceID string
+	if err := tx.QueryRow(ctx, `select /* pgwatch_generated */ aurora_db_instance_identifier()`).Scan(&instanceID); err != nil {
+		log.GetLogger(ctx).WithField("source", dbUnique).Debugf("failed to determine Aurora instance identifier: %v", err)
+		return
+	}
+	for _, dr := range data {
+		if _, ok := dr[auroraInstanceTag]; !ok { // all instances of an Aurora cluster share the system identifier
+			dr[auroraInstanceTag] = instanceID
+		}
+	}
+}
+
+// WithFetchTimeout returns a context with the client-side deadline for a single metric fetch.
+// The deadline is set slightly above the server-side statement_timeout so that the server
+// has a chance to cancel the query first and a hung network can't block a gatherer forever
+func WithFetchTimeout(ctx context.Context, msg MetricFetchConfig, opts *cmdopts.Options) (context.Context, context.CancelFunc) {
+	stmtTimeout := opts.Sources.StatementTimeout
+	if msg.StmtTimeoutOverride > 0 {
+		stmtTimeout = time.Duration(msg.StmtTimeoutOverride) * time.Second
+	}
+	if stmtTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, stmtTimeout+clientTimeoutMargin)
+}
+
+// withFallbackTimeout returns a context with a fresh fetch deadline for a fallback metric, as the
+// deadline of the original fetch might be already exceeded. Cancellation of the parent is honored
+func withFallbackTimeout(ctx context.Context, msg MetricFetchConfig, opts *cmdopts.Options) (context.Context, context.CancelFunc) {
+	fallbackCtx, cancel := WithFetchTimeout(context.WithoutCancel(ctx), msg, opts)
+	stop := context.AfterFunc(ctx, func() {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			cancel()
 		}
+	})
+	return fallbackCtx, func() {
+		stop()
+		cancel()
 	}
-	return DBExecRead(ctx, tx, sql, args...)
 }
 
 const (
-	execEnvUnknown       = "UNKNOWN"
+	execEnvUnknown       = metrics.ExecEnvUnknown
 	execEnvAzureSingle   = "AZURE_SINGLE"
 	execEnvAzureFlexible = "AZURE_FLEXIBLE"
 	execEnvGoogle        = "GOOGLE"
+	execEnvAlloyDB       = "ALLOYDB"
+	execEnvAwsRds        = "AWS_RDS"
+	execEnvAwsAurora     = "AWS_AURORA"
+	execEnvCrunchyBridge = "CRUNCHY_BRIDGE"
+	execEnvSupabase      = "SUPABASE"
+	execEnvNeon          = "NEON"
 )
 
 func DBGetSizeMB(ctx context.Context, dbUnique string) (int64, error) {
@@ -128,12 +213,20 @@ func DBGetSizeMB(ctx context.Context, dbUnique string) (int64, error) {
 	return lastDBSize, nil
 }
 
+// TryDiscoverExecutionEnv detects managed services based on version strings, vendor specific settings,
+// functions and roles. More specific checks go first, e.g. AlloyDB also exposes some Cloud SQL settings
 func TryDiscoverExecutionEnv(ctx context.Context, dbUnique string) (execEnv string) {
 	sql := `select /* pgwatch_generated */
 	case
 	  when exists (select * from pg_settings where name = 'pg_qs.host_database' and setting = 'azure_sys') and version() ~* 'compiled by Visual C' then 'AZURE_SINGLE'
 	  when exists (select * from pg_settings where name = 'pg_qs.host_database' and setting = 'azure_sys') and version() ~* 'compiled by gcc' then 'AZURE_FLEXIBLE'
+	  when exists (select * from pg_settings where name ~ '^alloydb[._]') then 'ALLOYDB'
 	  when exists (select * from pg_settings where name = 'cloudsql.supported_extensions') then 'GOOGLE'
+	  when exists (select * from pg_proc where proname = 'aurora_version') then 'AWS_AURORA'
+	  when exists (select * from pg_settings where name = 'rds.extensions') then 'AWS_RDS'
+	  when exists (select * from pg_settings where name ~ '^neon\.') then 'NEON'
+	  when exists (select * from pg_roles where rolname = 'supabase_admin') then 'SUPABASE'
+	  when version() ~* 'crunchy' or exists (select * from pg_roles where rolname = 'crunchy_superuser') then 'CRUNCHY_BRIDGE'
 	else
 	  'UNKNOWN'
 	end as exec_env`
@@ -246,7 +339,9 @@ FROM
 			return dbSettings, nil
 		}
 
-		if dbSettings.ExecEnv != "" {
+		if md, err := GetMonitoredDatabaseByUniqueName(dbUnique); err == nil && md.HostConfig.ExecEnv > "" {
+			dbNewSettings.ExecEnv = md.HostConfig.ExecEnv
+		} else if dbSettings.ExecEnv != "" {
 			dbNewSettings.ExecEnv = dbSettings.ExecEnv // carry over as not likely to change ever
 		} else {
 			l.Debugf("determining the execution env...")
@@ -898,6 +993,9 @@ func TryCreateMetricsFetchingHelpers(ctx context.Context, md *sources.MonitoredD
 		return err
 	}
 	conf.DefaultQueryExecMode = pgx.QueryExecModeExec
+	if md.HostConfig.ViaPgBouncer {
+		applyPgBouncerPassthrough(ctx, md.Name, conf)
+	}
 	c, err := pgx.ConnectConfig(ctx, conf)
 	if err != nil {
 		return nil
@@ -947,6 +1045,7 @@ func CloseResourcesForRemovedMonitoredDBs(metricsWriter *sinks.MultiWriter, curr
 		if _, ok := curDBsMap[prevDB.Name]; !ok { // removed from config
 			prevDB.Conn.Close()
 			_ = metricsWriter.SyncMetrics(prevDB.Name, "", "remove")
+			forgetAvailability(prevDB.Name)
 		}
 	}
 
@@ -970,39 +1069,3 @@ func ClearDBUnreachableStateIfAny(dbUnique string) {
 	delete(unreachableDB, dbUnique)
 	unreachableDBsLock.Unlock()
 }
-
-func SetUndersizedDBState(dbUnique string, state bool) {
-	undersizedDBsLock.Lock()
-	undersizedDBs[dbUnique] = state
-	undersizedDBsLock.Unlock()
-}
-
-func IsDBUndersized(dbUnique string) bool {
-	undersizedDBsLock.RLock()
-	defer undersizedDBsLock.RUnlock()
-	undersized, ok := undersizedDBs[dbUnique]
-	if ok {
-		return undersized
-	}
-	return false
-}
-
-func SetRecoveryIgnoredDBState(dbUnique string, state bool) {
-	recoveryIgnoredDBsLock.Lock()
-	recoveryIgnoredDBs[dbUnique] = state
-	recoveryIgnoredDBsLock.Unlock()
-}
-
-func IsDBIgnoredBasedOnRecoveryState(dbUnique string) bool {
-	recoveryIgnoredDBsLock.RLock()
-	defer recoveryIgnoredDBsLock.RUnlock()
-	recoveryIgnored, ok := recoveryIgnoredDBs[dbUnique]
-	if ok {
-		return recoveryIgnored
-	}
-	return false
-}
-
-func IsDBDormant(dbUnique string) bool {
-	return IsDBUndersized(dbUnique) || IsDBIgnoredBasedOnRecoveryState(dbUnique)
-}
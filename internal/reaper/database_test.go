@@ -0,0 +1,84 @@
+package reaper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/cmdopts"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithFetchTimeout(t *testing.T) {
+	opts := &cmdopts.Options{}
+	opts.Sources.StatementTimeout = time.Minute
+
+	ctx, cancel := WithFetchTimeout(context.Background(), MetricFetchConfig{}, opts)
+	deadline, ok := ctx.Deadline()
+	cancel()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute+clientTimeoutMargin), deadline, time.Second)
+
+	ctx, cancel = WithFetchTimeout(context.Background(), MetricFetchConfig{StmtTimeoutOverride: 300}, opts)
+	deadline, ok = ctx.Deadline()
+	cancel()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(5*time.Minute+clientTimeoutMargin), deadline, time.Second, "per metric override should win")
+
+	opts.Sources.StatementTimeout = 0
+	ctx, cancel = WithFetchTimeout(context.Background(), MetricFetchConfig{}, opts)
+	_, ok = ctx.Deadline()
+	assert.False(t, ok, "no deadline should be set if timeouts are disabled")
+	cancel()
+	assert.Error(t, ctx.Err())
+}
+
+func TestDBExecReadAuroraInstanceTag(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{{Source: sources.Source{Name: "aurora1", Kind: sources.SourcePostgres}, Conn: conn}})
+	MonitoredDatabasesSettingsLock.Lock()
+	MonitoredDatabasesSettings["aurora1"] = MonitoredDatabaseSettings{ExecEnv: execEnvAwsAurora}
+	MonitoredDatabasesSettingsLock.Unlock()
+	defer func() {
+		UpdateMonitoredDBCache(nil)
+		MonitoredDatabasesSettingsLock.Lock()
+		delete(MonitoredDatabasesSettings, "aurora1")
+		MonitoredDatabasesSettingsLock.Unlock()
+	}()
+
+	conn.ExpectBegin()
+	conn.ExpectExec("SET LOCAL lock_timeout").WillReturnResult(pgxmock.NewResult("SET", 1))
+	conn.ExpectQuery("select 1").WillReturnRows(pgxmock.NewRows([]string{"x"}).AddRow(1))
+	conn.ExpectQuery("aurora_db_instance_identifier").WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow("instance-1"))
+	conn.ExpectCommit()
+
+	data, err := DBExecReadByDbUniqueName(context.Background(), "aurora1", "select 1 as x")
+	assert.NoError(t, err)
+	assert.Equal(t, "instance-1", data[0][auroraInstanceTag], "instance is checked in the same transaction as the metric query")
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
+
+func TestDBExecReadMetricApplicationName(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{{Source: sources.Source{Name: "appname1", Kind: sources.SourcePostgres}, Conn: conn}})
+	defer UpdateMonitoredDBCache(nil)
+
+	conn.ExpectBegin()
+	conn.ExpectExec("^SET LOCAL lock_timeout TO '100ms'; SET LOCAL application_name TO 'pgwatch:db_stats'$").WillReturnResult(pgxmock.NewResult("SET", 0))
+	conn.ExpectQuery("select 1").WillReturnRows(pgxmock.NewRows([]string{"x"}).AddRow(1))
+	conn.ExpectCommit()
+	_, err = DBExecReadByDbUniqueName(WithQueryOrigin(context.Background(), "gatherer", "db_stats"), "appname1", "select 1 as x")
+	assert.NoError(t, err)
+
+	conn.ExpectBegin()
+	conn.ExpectExec("^SET LOCAL lock_timeout TO '100ms'$").WillReturnResult(pgxmock.NewResult("SET", 0))
+	conn.ExpectQuery("select 1").WillReturnRows(pgxmock.NewRows([]string{"x"}).AddRow(1))
+	conn.ExpectCommit()
+	_, err = DBExecReadByDbUniqueName(WithQueryOrigin(context.Background(), "testdata", ""), "appname1", "select 1 as x")
+	assert.NoError(t, err, "only metric queries are attributed")
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
@@ -0,0 +1,120 @@
+package reaper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+// DerivedMetricsCalculator calculates the derived metrics of a gathered metric. The rows of the previous
+// fetch are kept for the delta() and rate() expressions, matched by the tag columns
+type DerivedMetricsCalculator struct {
+	prev   map[string]metrics.Measurement // [tag values]=row
+	warned map[string]bool                // invalid definitions are reported once per gatherer
+}
+
+// rowTagsKey returns the identity of a row within the metric, i.e. its tag values
+func rowTagsKey(row metrics.Measurement) string {
+	var key strings.Builder
+	for _, col := range slices.Sorted(maps.Keys(row)) {
+		if strings.HasPrefix(col, "tag_") {
+			key.WriteString(fmt.Sprintf("%s=%v;", col, row[col]))
+		}
+	}
+	return key.String()
+}
+
+// Calculate returns the derived metrics of the fetched measurements, the columns that can't be
+// calculated yet, e.g. rate() on the first fetch or after a statistics reset, are left out
+func (c *DerivedMetricsCalculator) Calculate(ctx context.Context, msg metrics.MeasurementEnvelope) []metrics.MeasurementEnvelope {
+	derived := msg.MetricDef.Derived
+	if len(derived) == 0 {
+		return nil
+	}
+	if c.warned == nil {
+		c.warned = make(map[string]bool)
+	}
+	prev := c.prev
+	c.prev = make(map[string]metrics.Measurement, len(msg.Data))
+	for _, row := range msg.Data {
+		c.prev[rowTagsKey(row)] = maps.Clone(row)
+	}
+	exprs := make(map[string]metrics.Expr)
+	for _, name := range slices.Sorted(maps.Keys(derived)) {
+		for col, expr := range derived[name] {
+			e, err := metrics.ParseExpr(expr)
+			if err != nil {
+				c.warn(ctx, name, col, err)
+				continue
+			}
+			exprs[name+"."+col] = e
+		}
+	}
+
+	var envelopes []metrics.MeasurementEnvelope
+	for _, name := range slices.Sorted(maps.Keys(derived)) {
+		var data metrics.Measurements
+		for _, row := range msg.Data {
+			last := prev[rowTagsKey(row)]
+			if reset, _ := row[statsResetColumn].(bool); reset {
+				last = nil // counters are not comparable to the previous fetch
+			}
+			var seconds float64
+			if last != nil {
+				cur, _ := metrics.ToFloat(row[epochColumnName])
+				before, _ := metrics.ToFloat(last[epochColumnName])
+				seconds = (cur - before) / 1e9
+			}
+			derivedRow := metrics.Measurement{}
+			for col := range derived[name] {
+				e, ok := exprs[name+"."+col]
+				if !ok {
+					continue
+				}
+				v, err := e.Eval(row, last, seconds)
+				switch {
+				case err == nil:
+					derivedRow[col] = v
+				case !errors.Is(err, metrics.ErrNoPreviousRow) && !errors.Is(err, metrics.ErrDivisionByZero):
+					c.warn(ctx, name, col, err)
+				}
+			}
+			if len(derivedRow) == 0 {
+				continue
+			}
+			for col, v := range row {
+				if col == epochColumnName || strings.HasPrefix(col, "tag_") {
+					derivedRow[col] = v
+				}
+			}
+			data = append(data, derivedRow)
+		}
+		if len(data) > 0 {
+			envelopes = append(envelopes, metrics.MeasurementEnvelope{
+				DBName:           msg.DBName,
+				SourceType:       msg.SourceType,
+				MetricName:       name,
+				CustomTags:       msg.CustomTags,
+				Data:             data,
+				MetricDef:        metrics.Metric{Gauges: []string{"*"}},
+				RealDbname:       msg.RealDbname,
+				SystemIdentifier: msg.SystemIdentifier,
+			})
+		}
+	}
+	return envelopes
+}
+
+func (c *DerivedMetricsCalculator) warn(ctx context.Context, name, col string, err error) {
+	if c.warned[name+"."+col] {
+		return
+	}
+	c.warned[name+"."+col] = true
+	log.GetLogger(ctx).WithField("derived_metric", name).WithField("column", col).WithError(err).Warning("could not calculate derived metric column")
+}
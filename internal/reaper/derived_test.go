@@ -0,0 +1,52 @@
+package reaper
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDerivedMetricsCalculator(t *testing.T) {
+	var c DerivedMetricsCalculator
+	ctx := context.Background()
+	msg := metrics.MeasurementEnvelope{
+		DBName:     "db1",
+		MetricName: "db_stats",
+		CustomTags: map[string]string{"env": "prod"},
+		MetricDef: metrics.Metric{MetricAttrs: metrics.MetricAttrs{Derived: metrics.DerivedMetrics{
+			"db_kpis": {"hit_ratio": "100 * blks_hit / (blks_hit + blks_read)", "tps": "rate(xact_commit)"},
+			"broken":  {"bad": "blks_hit +", "missing": "no_such_column"},
+		}}},
+	}
+	assert.Nil(t, c.Calculate(ctx, msg), "no rows")
+
+	msg.Data = metrics.Measurements{{epochColumnName: int64(10e9), "tag_datname": "db1", "blks_hit": int64(90), "blks_read": int64(10), "xact_commit": int64(100)}}
+	derived := c.Calculate(ctx, msg)
+	if assert.Len(t, derived, 1) {
+		assert.Equal(t, "db_kpis", derived[0].MetricName)
+		assert.Equal(t, "db1", derived[0].DBName)
+		assert.Equal(t, msg.CustomTags, derived[0].CustomTags)
+		assert.Equal(t, []string{"*"}, derived[0].MetricDef.Gauges)
+		assert.Equal(t, metrics.Measurements{{epochColumnName: int64(10e9), "tag_datname": "db1", "hit_ratio": 90.0}}, derived[0].Data, "no rate on first fetch")
+	}
+
+	msg.Data = metrics.Measurements{
+		{epochColumnName: int64(20e9), "tag_datname": "db1", "blks_hit": int64(100), "blks_read": int64(0), "xact_commit": int64(150)},
+		{epochColumnName: int64(20e9), "tag_datname": "db2", "blks_hit": int64(0), "blks_read": int64(0), "xact_commit": int64(5)},
+	}
+	derived = c.Calculate(ctx, msg)
+	if assert.Len(t, derived, 1) {
+		assert.Equal(t, metrics.Measurements{{epochColumnName: int64(20e9), "tag_datname": "db1", "hit_ratio": 100.0, "tps": 5.0}}, derived[0].Data,
+			"rows matched by tags, division by zero left out")
+	}
+
+	msg.Data = metrics.Measurements{{epochColumnName: int64(30e9), "tag_datname": "db1", "blks_hit": int64(1), "blks_read": int64(1), "xact_commit": int64(10), statsResetColumn: true}}
+	derived = c.Calculate(ctx, msg)
+	if assert.Len(t, derived, 1) {
+		assert.Equal(t, metrics.Measurements{{epochColumnName: int64(30e9), "tag_datname": "db1", "hit_ratio": 50.0}}, derived[0].Data, "no rate after a stats reset")
+	}
+	assert.True(t, c.warned["broken.bad"])
+	assert.True(t, c.warned["broken.missing"])
+}
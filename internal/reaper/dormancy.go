@@ -0,0 +1,174 @@
+package reaper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+)
+
+// DormancyState tells why a source is temporarily not monitored
+type DormancyState struct {
+	Undersized      bool      `json:"undersized"`       // below the --min-db-size-mb limit
+	RecoveryIgnored bool      `json:"recovery_ignored"` // in recovery and only_if_master is set
+	Since           time.Time `json:"since"`            // last state change
+}
+
+// Dormant returns true if the source should not be monitored for any reason
+func (s DormancyState) Dormant() bool {
+	return s.Undersized || s.RecoveryIgnored
+}
+
+func (s DormancyState) String() string {
+	switch {
+	case s.Undersized && s.RecoveryIgnored:
+		return "dormant (undersized, in recovery)"
+	case s.Undersized:
+		return "dormant (undersized)"
+	case s.RecoveryIgnored:
+		return "dormant (in recovery)"
+	}
+	return "active"
+}
+
+var dormancyStates = make(map[string]DormancyState) // [db1]=state, only non-active sources are kept
+var dormancyStatesLock sync.RWMutex
+var dormancyStateFile string // persistence is disabled if empty
+
+// LoadDormancyStates restores the dormancy states saved before the restart
+// and enables saving them to the file on every change
+func LoadDormancyStates(fileName string) error {
+	dormancyStatesLock.Lock()
+	defer dormancyStatesLock.Unlock()
+	dormancyStateFile = fileName
+	if fileName == "" {
+		return nil
+	}
+	b, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	states := make(map[string]DormancyState)
+	if err = json.Unmarshal(b, &states); err != nil {
+		return fmt.Errorf("invalid dormancy state file %s: %w", fileName, err)
+	}
+	dormancyStates = states
+	return nil
+}
+
+// saveDormancyStates writes the states to the file atomically, must be called with the lock held
+func saveDormancyStates() error {
+	if dormancyStateFile == "" {
+		return nil
+	}
+	b, _ := json.Marshal(dormancyStates)
+	tmp := dormancyStateFile + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dormancyStateFile)
+}
+
+// updateDormancy applies the change to the source state and returns the states before and after it
+func updateDormancy(dbUnique string, change func(*DormancyState)) (prev, curr DormancyState, err error) {
+	dormancyStatesLock.Lock()
+	defer dormancyStatesLock.Unlock()
+	prev = dormancyStates[dbUnique]
+	curr = prev
+	change(&curr)
+	if curr.Undersized == prev.Undersized && curr.RecoveryIgnored == prev.RecoveryIgnored {
+		return prev, prev, nil
+	}
+	curr.Since = time.Now()
+	if curr.Dormant() {
+		dormancyStates[dbUnique] = curr
+	} else {
+		delete(dormancyStates, dbUnique)
+	}
+	return prev, curr, saveDormancyStates()
+}
+
+// SetDBSizeDormancy updates the undersized state of the source with hysteresis: a source becomes dormant
+// when its size drops below minSizeMB, but is resumed only after it grows hysteresisPct percent above it.
+// Unknown size, i.e. 0, keeps the current state, minSizeMB of 0 disables the check
+func SetDBSizeDormancy(dbUnique string, sizeMB, minSizeMB int64, hysteresisPct int) (prev, curr DormancyState, err error) {
+	return updateDormancy(dbUnique, func(s *DormancyState) {
+		switch {
+		case minSizeMB <= 0: // size filter is disabled
+			s.Undersized = false
+		case sizeMB == 0:
+		case sizeMB < minSizeMB:
+			s.Undersized = true
+		case sizeMB >= minSizeMB+minSizeMB*int64(hysteresisPct)/100:
+			s.Undersized = false
+		}
+	})
+}
+
+// SetRecoveryDormancy updates the state of the source ignored while in recovery due to only_if_master
+func SetRecoveryDormancy(dbUnique string, ignored bool) (prev, curr DormancyState, err error) {
+	return updateDormancy(dbUnique, func(s *DormancyState) {
+		s.RecoveryIgnored = ignored
+	})
+}
+
+// GetDormancyState returns the current dormancy state of the source
+func GetDormancyState(dbUnique string) DormancyState {
+	dormancyStatesLock.RLock()
+	defer dormancyStatesLock.RUnlock()
+	return dormancyStates[dbUnique]
+}
+
+func IsDBUndersized(dbUnique string) bool {
+	return GetDormancyState(dbUnique).Undersized
+}
+
+func IsDBIgnoredBasedOnRecoveryState(dbUnique string) bool {
+	return GetDormancyState(dbUnique).RecoveryIgnored
+}
+
+func IsDBDormant(dbUnique string) bool {
+	return GetDormancyState(dbUnique).Dormant()
+}
+
+// forgetDormancy drops the states of the sources not monitored anymore
+func forgetDormancy(removed func(dbUnique string) bool) {
+	dormancyStatesLock.Lock()
+	defer dormancyStatesLock.Unlock()
+	maps.DeleteFunc(dormancyStates, func(dbUnique string, _ DormancyState) bool { return removed(dbUnique) })
+}
+
+// reportDormancyChange logs the dormancy state transition of the source and stores it as a server event
+func (r *Reaper) reportDormancyChange(ctx context.Context, md *sources.MonitoredDatabase, prev, curr DormancyState, err error) {
+	l := log.GetLogger(ctx).WithField("source", md.Name)
+	if err != nil {
+		l.WithError(err).Warning("could not save dormancy state")
+	}
+	if prev.String() == curr.String() {
+		return
+	}
+	message := fmt.Sprintf("Source \"%s\" changed from %s to %s", md.Name, prev, curr)
+	l.Info(message)
+	event := metrics.MeasurementEnvelope{
+		DBName:     md.Name,
+		SourceType: string(md.Kind),
+		MetricName: "object_changes",
+		Data:       metrics.Measurements{{"details": message, epochColumnName: time.Now().UnixNano()}},
+		CustomTags: md.CustomTags,
+	}
+	select {
+	case r.measurementCh <- []metrics.MeasurementEnvelope{event}:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,90 @@
+package reaper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/cmdopts"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetDBSizeDormancy(t *testing.T) {
+	defer forgetDormancy(func(string) bool { return true })
+	for _, tc := range []struct {
+		sizeMB  int64
+		dormant bool
+	}{
+		{sizeMB: 150, dormant: false},
+		{sizeMB: 99, dormant: true},
+		{sizeMB: 0, dormant: true},    // unknown size keeps the state
+		{sizeMB: 105, dormant: true},  // within the hysteresis band
+		{sizeMB: 110, dormant: false}, // 10% above the threshold
+		{sizeMB: 105, dormant: false}, // within the hysteresis band, but active
+		{sizeMB: 99, dormant: true},
+	} {
+		_, curr, err := SetDBSizeDormancy("db1", tc.sizeMB, 100, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.dormant, curr.Undersized, "size %d MB", tc.sizeMB)
+		assert.Equal(t, tc.dormant, IsDBUndersized("db1"))
+	}
+	_, curr, _ := SetDBSizeDormancy("db1", 0, 0, 10)
+	assert.False(t, curr.Dormant(), "disabled size filter resumes the source")
+}
+
+func TestDormancyStates(t *testing.T) {
+	defer forgetDormancy(func(string) bool { return true })
+	prev, curr, _ := SetRecoveryDormancy("db1", true)
+	assert.Equal(t, "active", prev.String())
+	assert.Equal(t, "dormant (in recovery)", curr.String())
+	assert.True(t, IsDBIgnoredBasedOnRecoveryState("db1"))
+	assert.False(t, IsDBUndersized("db1"), "recovery state must not affect the size state")
+	assert.True(t, IsDBDormant("db1"))
+
+	_, curr, _ = SetDBSizeDormancy("db1", 10, 100, 10)
+	assert.Equal(t, "dormant (undersized, in recovery)", curr.String())
+	_, curr, _ = SetRecoveryDormancy("db1", false)
+	assert.Equal(t, "dormant (undersized)", curr.String())
+	_, curr, _ = SetDBSizeDormancy("db1", 200, 100, 10)
+	assert.False(t, IsDBDormant("db1"))
+	assert.Empty(t, dormancyStates, "active sources are not kept")
+}
+
+func TestDormancyStatesPersistence(t *testing.T) {
+	defer func() {
+		forgetDormancy(func(string) bool { return true })
+		_ = LoadDormancyStates("")
+	}()
+	fileName := filepath.Join(t.TempDir(), "dormancy.json")
+	require.NoError(t, LoadDormancyStates(fileName), "missing file is not an error")
+
+	_, _, err := SetDBSizeDormancy("db1", 10, 100, 10)
+	assert.NoError(t, err)
+	assert.FileExists(t, fileName)
+
+	forgetDormancy(func(string) bool { return true })
+	assert.False(t, IsDBUndersized("db1"))
+	require.NoError(t, LoadDormancyStates(fileName))
+	assert.True(t, IsDBUndersized("db1"), "state should survive the restart")
+	_, curr, _ := SetDBSizeDormancy("db1", 105, 100, 10)
+	assert.True(t, curr.Undersized, "hysteresis should survive the restart")
+
+	require.NoError(t, os.WriteFile(fileName, []byte("garbage"), 0600))
+	assert.Error(t, LoadDormancyStates(fileName))
+}
+
+func TestReportDormancyChange(t *testing.T) {
+	r := NewReaper(&cmdopts.Options{}, nil, nil)
+	md := &sources.MonitoredDatabase{Source: sources.Source{Name: "db1", Kind: sources.SourcePostgres}}
+
+	r.reportDormancyChange(context.Background(), md, DormancyState{}, DormancyState{}, nil)
+	assert.Empty(t, r.measurementCh, "no event without a state change")
+
+	r.reportDormancyChange(context.Background(), md, DormancyState{}, DormancyState{Undersized: true}, nil)
+	msgs := <-r.measurementCh
+	assert.Equal(t, "object_changes", msgs[0].MetricName)
+	assert.Equal(t, `Source "db1" changed from active to dormant (undersized)`, msgs[0].Data[0]["details"])
+}
@@ -0,0 +1,152 @@
+package reaper
+
+import (
+	"cmp"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/alerting"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+const downtimeInterval = time.Minute
+
+var downtimes = make(map[string]alerting.Downtime) // [id]=downtime, the ended ones are dropped
+var downtimesLock sync.RWMutex
+var downtimeFile string // persistence is disabled if empty
+
+// LoadDowntimes restores the downtimes saved before the restart and enables saving them to the file on every change
+func LoadDowntimes(fileName string) error {
+	downtimesLock.Lock()
+	defer downtimesLock.Unlock()
+	downtimeFile = fileName
+	if fileName == "" {
+		return nil
+	}
+	b, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	loaded := make(map[string]alerting.Downtime)
+	if err = json.Unmarshal(b, &loaded); err != nil {
+		return fmt.Errorf("invalid downtime file %s: %w", fileName, err)
+	}
+	downtimes = loaded
+	return nil
+}
+
+// saveDowntimes writes the downtimes to the file atomically, must be called with the lock held
+func saveDowntimes() error {
+	if downtimeFile == "" {
+		return nil
+	}
+	b, _ := json.Marshal(downtimes)
+	tmp := downtimeFile + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, downtimeFile)
+}
+
+// AddDowntime schedules the planned maintenance window and returns it with its ID assigned
+func (r *Reaper) AddDowntime(d alerting.Downtime) (alerting.Downtime, error) {
+	if err := d.Validate(time.Now()); err != nil {
+		return d, err
+	}
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	d.ID = hex.EncodeToString(id)
+	downtimesLock.Lock()
+	defer downtimesLock.Unlock()
+	downtimes[d.ID] = d
+	return d, saveDowntimes()
+}
+
+// Downtimes returns the current and future downtimes ordered by their start
+func (r *Reaper) Downtimes() []alerting.Downtime {
+	downtimesLock.RLock()
+	defer downtimesLock.RUnlock()
+	return slices.SortedFunc(maps.Values(downtimes), func(a, b alerting.Downtime) int {
+		return cmp.Or(a.Start.Compare(b.Start), cmp.Compare(a.ID, b.ID))
+	})
+}
+
+// DeleteDowntime cancels the downtime, ending it immediately if already in effect
+func (r *Reaper) DeleteDowntime(id string) error {
+	downtimesLock.Lock()
+	defer downtimesLock.Unlock()
+	if _, ok := downtimes[id]; !ok {
+		return fmt.Errorf("%w: %s", alerting.ErrDowntimeNotFound, id)
+	}
+	delete(downtimes, id)
+	return saveDowntimes()
+}
+
+// activeDowntimes returns the downtimes in effect matching the metric of the monitored DB
+func activeDowntimes(dbUnique, group, metric string, now time.Time) (active []alerting.Downtime) {
+	downtimesLock.RLock()
+	defer downtimesLock.RUnlock()
+	for _, d := range downtimes {
+		if d.Active(now) && d.Matches(dbUnique, group, metric) {
+			active = append(active, d)
+		}
+	}
+	return
+}
+
+// DowntimeMeasurements drops the ended downtimes and returns the downtime state of all monitored DBs: a row per
+// active downtime, labeled with the silenced metric, or a single inactive row, so that the alert rules can skip them
+func DowntimeMeasurements(time.Duration) []metrics.MeasurementEnvelope {
+	now := time.Now()
+	downtimesLock.Lock()
+	count := len(downtimes)
+	maps.DeleteFunc(downtimes, func(_ string, d alerting.Downtime) bool { return !now.Before(d.End) })
+	if len(downtimes) < count {
+		_ = saveDowntimes() // the ended downtimes left in the file have no effect anyway
+	}
+	all := slices.Collect(maps.Values(downtimes))
+	downtimesLock.Unlock()
+
+	monitoredDbCacheLock.RLock()
+	defer monitoredDbCacheLock.RUnlock()
+	msgs := make([]metrics.MeasurementEnvelope, 0, len(monitoredDbCache))
+	for dbUnique, md := range monitoredDbCache {
+		var rows metrics.Measurements
+		for _, d := range all {
+			if !d.Active(now) || !d.Matches(dbUnique, md.Group, d.Metric) {
+				continue
+			}
+			rows = append(rows, metrics.Measurement{
+				epochColumnName:       now.UnixNano(),
+				"tag_downtime_id":     d.ID,
+				"tag_silenced_metric": d.Metric,
+				"active":              1,
+				"reason":              d.Reason,
+				"creator":             d.Creator,
+				"end_time":            d.End.Format(time.RFC3339),
+			})
+		}
+		if len(rows) == 0 {
+			rows = metrics.Measurements{{epochColumnName: now.UnixNano(), "active": 0}}
+		}
+		msgs = append(msgs, metrics.MeasurementEnvelope{
+			DBName:     dbUnique,
+			SourceType: string(md.Kind),
+			MetricName: alerting.DowntimeMetric,
+			CustomTags: md.CustomTags,
+			Data:       rows,
+		})
+	}
+	return msgs
+}
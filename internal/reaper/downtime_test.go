@@ -0,0 +1,102 @@
+package reaper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/alerting"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/cmdopts"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDowntimes(t *testing.T) {
+	defer func() {
+		clear(downtimes)
+		_ = LoadDowntimes("")
+	}()
+	fileName := filepath.Join(t.TempDir(), "downtimes.json")
+	require.NoError(t, LoadDowntimes(fileName), "missing file is not an error")
+	r := NewReaper(&cmdopts.Options{}, nil, nil)
+
+	_, err := r.AddDowntime(alerting.Downtime{End: time.Now().Add(time.Hour)})
+	assert.Error(t, err, "unscoped downtimes are rejected")
+	later, err := r.AddDowntime(alerting.Downtime{Group: "prod", Start: time.Now().Add(time.Hour), End: time.Now().Add(2 * time.Hour)})
+	require.NoError(t, err)
+	now, err := r.AddDowntime(alerting.Downtime{DBName: "db1", End: time.Now().Add(time.Hour), Reason: "upgrade", Creator: "admin"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, now.ID)
+	assert.Equal(t, []string{now.ID, later.ID}, downtimeIDs(r.Downtimes()), "ordered by the start")
+	assert.FileExists(t, fileName)
+
+	clear(downtimes)
+	require.NoError(t, LoadDowntimes(fileName))
+	assert.Len(t, r.Downtimes(), 2, "downtimes should survive the restart")
+	assert.NoError(t, r.DeleteDowntime(later.ID))
+	assert.ErrorIs(t, r.DeleteDowntime(later.ID), alerting.ErrDowntimeNotFound, "already deleted")
+	assert.Equal(t, []string{now.ID}, downtimeIDs(r.Downtimes()))
+	assert.Equal(t, "upgrade", r.Downtimes()[0].Reason)
+
+	require.NoError(t, os.WriteFile(fileName, []byte("garbage"), 0600))
+	assert.Error(t, LoadDowntimes(fileName))
+}
+
+func downtimeIDs(dts []alerting.Downtime) (ids []string) {
+	for _, d := range dts {
+		ids = append(ids, d.ID)
+	}
+	return
+}
+
+func TestDowntimeMeasurements(t *testing.T) {
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{
+		{Source: sources.Source{Name: "db1", Kind: sources.SourcePostgres, Group: "prod"}},
+		{Source: sources.Source{Name: "db2", Kind: sources.SourcePostgres, Group: "staging"}},
+	})
+	defer UpdateMonitoredDBCache(nil)
+	defer clear(downtimes)
+	downtimes["ended"] = alerting.Downtime{ID: "ended", DBName: "db2", Start: time.Now().Add(-time.Hour), End: time.Now().Add(-time.Minute)}
+	downtimes["archiver"] = alerting.Downtime{ID: "archiver", Group: "prod", Metric: "archiver", Start: time.Now().Add(-time.Hour), End: time.Now().Add(time.Hour), Reason: "WAL archive migration"}
+
+	msgs := DowntimeMeasurements(time.Minute)
+	require.Len(t, msgs, 2)
+	assert.NotContains(t, downtimes, "ended", "ended downtimes are dropped")
+	byDB := make(map[string]metrics.Measurements)
+	for _, msg := range msgs {
+		assert.Equal(t, alerting.DowntimeMetric, msg.MetricName)
+		byDB[msg.DBName] = msg.Data
+	}
+	require.Len(t, byDB["db1"], 1)
+	assert.Equal(t, 1, byDB["db1"][0]["active"])
+	assert.Equal(t, "archiver", byDB["db1"][0]["tag_silenced_metric"])
+	assert.Equal(t, "WAL archive migration", byDB["db1"][0]["reason"])
+	assert.Equal(t, 0, byDB["db2"][0]["active"], "an inactive row replaces the previous state")
+	assert.NotContains(t, byDB["db2"][0], "tag_silenced_metric")
+}
+
+func TestAvailabilityInDowntime(t *testing.T) {
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{{Source: sources.Source{Name: "maint_db", Kind: sources.SourcePostgres}}})
+	defer UpdateMonitoredDBCache(nil)
+	defer forgetAvailability("maint_db")
+	defer clear(downtimes)
+
+	AvailabilityMeasurements(time.Minute)
+	downtimes["archiver"] = alerting.Downtime{ID: "archiver", DBName: "maint_db", Metric: "archiver", Start: time.Now().Add(-time.Hour), End: time.Now().Add(time.Hour)}
+	downtimes["upgrade"] = alerting.Downtime{ID: "upgrade", DBName: "maint_db", Start: time.Now().Add(-time.Hour), End: time.Now().Add(time.Hour)}
+	SetDBUnreachableState("maint_db")
+	defer ClearDBUnreachableStateIfAny("maint_db")
+	row := AvailabilityMeasurements(time.Minute)[0].Data[0]
+	assert.Equal(t, 1, row["is_up"], "not marked unreachable during the planned maintenance")
+	assert.Equal(t, 1, row["in_downtime_int"])
+	assert.Equal(t, 100.0, row["uptime_pct_day"], "the downtime is not accounted")
+
+	delete(downtimes, "upgrade")
+	row = AvailabilityMeasurements(time.Minute)[0].Data[0]
+	assert.Equal(t, 0, row["is_up"], "the downtimes of other metrics don't apply")
+	assert.Equal(t, 0, row["in_downtime_int"])
+	assert.Equal(t, 50.0, row["uptime_pct_day"])
+}
@@ -0,0 +1,92 @@
+package reaper
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sinks"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/webserver"
+)
+
+// EffectiveSource is a monitored source as the reaper sees it, i.e. after the continuous discovery,
+// the environment expansion, the preset resolution and the switching to the standby configuration
+type EffectiveSource struct {
+	Name         string             `json:"name"`
+	Kind         sources.Kind       `json:"kind"`
+	Group        string             `json:"group"`
+	ConnStr      string             `json:"conn_str"`
+	InRecovery   bool               `json:"in_recovery"`
+	Preset       string             `json:"preset,omitempty"`
+	Metrics      map[string]float64 `json:"metrics"`
+	CustomTags   map[string]string  `json:"custom_tags,omitempty"`
+	OnlyIfMaster bool               `json:"only_if_master"`
+}
+
+// EffectiveConfig is the fully resolved configuration the reaper is working with
+type EffectiveConfig struct {
+	Options struct {
+		Sources sources.CmdOpts
+		Metrics metrics.CmdOpts
+		Sinks   sinks.CmdOpts
+		Logging log.CmdOpts
+		WebUI   webserver.CmdOpts
+	} `json:"options"`
+	Sources []EffectiveSource `json:"sources"`
+}
+
+// effectiveMetrics returns the preset and metrics in effect for the source depending on its recovery state,
+// the standby configuration is used only if defined, the same way the main loop switches it
+func effectiveMetrics(md *sources.MonitoredDatabase, inRecovery bool) (string, map[string]float64) {
+	preset, metricsConfig := md.PresetMetrics, md.Metrics
+	if inRecovery && (md.PresetMetricsStandby > "" || len(md.MetricsStandby) > 0) {
+		preset, metricsConfig = md.PresetMetricsStandby, md.MetricsStandby
+	}
+	if len(metricsConfig) > 0 {
+		return "", metricsConfig
+	}
+	metricDefMapLock.RLock()
+	defer metricDefMapLock.RUnlock()
+	return preset, metricDefinitionMap.PresetDefs[preset].Metrics
+}
+
+// EffectiveConfig() returns the configuration the reaper is currently working with.
+// Passwords are not masked, callers exposing it must redact the output
+func (r *Reaper) EffectiveConfig() any {
+	var ec EffectiveConfig
+	ec.Options.Sources = r.opts.Sources
+	ec.Options.Metrics = r.opts.Metrics
+	ec.Options.Sinks = r.opts.Sinks
+	ec.Options.Logging = r.opts.Logging
+	ec.Options.WebUI = r.opts.WebUI
+
+	monitoredDbCacheLock.RLock()
+	mdbs := slices.Collect(maps.Values(monitoredDbCache))
+	monitoredDbCacheLock.RUnlock()
+	ec.Sources = make([]EffectiveSource, 0, len(mdbs))
+	for _, md := range mdbs {
+		MonitoredDatabasesSettingsLock.RLock()
+		inRecovery := MonitoredDatabasesSettings[md.Name].IsInRecovery
+		MonitoredDatabasesSettingsLock.RUnlock()
+		preset, metricsConfig := effectiveMetrics(md, inRecovery)
+		metricsConfig = routeStandbyMetrics(md, metricsConfig)
+		ec.Sources = append(ec.Sources, EffectiveSource{
+			Name:         md.Name,
+			Kind:         md.Kind,
+			Group:        md.Group,
+			ConnStr:      md.ConnStr,
+			InRecovery:   inRecovery,
+			Preset:       preset,
+			Metrics:      metricsConfig,
+			CustomTags:   md.CustomTags,
+			OnlyIfMaster: md.OnlyIfMaster,
+		})
+	}
+	slices.SortFunc(ec.Sources, func(a, b EffectiveSource) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return ec
+}
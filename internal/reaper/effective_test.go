@@ -0,0 +1,50 @@
+package reaper
+
+import (
+	"testing"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/cmdopts"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEffectiveConfig(t *testing.T) {
+	metricDefMapLock.Lock()
+	prevPresets := metricDefinitionMap.PresetDefs
+	metricDefinitionMap.PresetDefs = metrics.PresetDefs{
+		"basic":   {Metrics: map[string]float64{"db_stats": 60}},
+		"standby": {Metrics: map[string]float64{"wal_receiver": 30}},
+	}
+	metricDefMapLock.Unlock()
+	defer func() {
+		metricDefMapLock.Lock()
+		metricDefinitionMap.PresetDefs = prevPresets
+		metricDefMapLock.Unlock()
+		UpdateMonitoredDBCache(nil)
+	}()
+
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{
+		{Source: sources.Source{Name: "replica", PresetMetrics: "basic", PresetMetricsStandby: "standby"}},
+		{Source: sources.Source{Name: "primary", ConnStr: "postgresql://localhost/db", PresetMetrics: "basic", PresetMetricsStandby: "standby"}},
+		{Source: sources.Source{Name: "custom", Metrics: map[string]float64{"locks": 10}}},
+	})
+	MonitoredDatabasesSettingsLock.Lock()
+	MonitoredDatabasesSettings["replica"] = MonitoredDatabaseSettings{IsInRecovery: true}
+	MonitoredDatabasesSettingsLock.Unlock()
+	defer func() {
+		MonitoredDatabasesSettingsLock.Lock()
+		delete(MonitoredDatabasesSettings, "replica")
+		MonitoredDatabasesSettingsLock.Unlock()
+	}()
+
+	opts := &cmdopts.Options{}
+	opts.Sources.Refresh = 120
+	ec := NewReaper(opts, nil, nil).EffectiveConfig().(EffectiveConfig)
+	assert.Equal(t, 120, ec.Options.Sources.Refresh)
+	assert.Equal(t, []EffectiveSource{
+		{Name: "custom", Metrics: map[string]float64{"locks": 10}},
+		{Name: "primary", ConnStr: "postgresql://localhost/db", Preset: "basic", Metrics: map[string]float64{"db_stats": 60}},
+		{Name: "replica", InRecovery: true, Preset: "standby", Metrics: map[string]float64{"wal_receiver": 30}},
+	}, ec.Sources)
+}
@@ -0,0 +1,132 @@
+package reaper
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/faults"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// FetchErrorKind classifies failed metric fetches to drive the gatherer behavior and stats
+type FetchErrorKind string
+
+const (
+	FetchErrorUnknown            FetchErrorKind = "unknown"
+	FetchErrorRecovery           FetchErrorKind = "recovery"               // primary only functionality called on a standby
+	FetchErrorPermissionDenied   FetchErrorKind = "permission_denied"      // missing grants or helpers
+	FetchErrorUndefinedObject    FetchErrorKind = "undefined_object"       // missing relation, column, function, extension
+	FetchErrorTimeout            FetchErrorKind = "timeout"                // statement or lock timeout, client-side deadline
+	FetchErrorConnection         FetchErrorKind = "connection"             // connection refused or lost, server shutting down
+	FetchErrorTooManyConnections FetchErrorKind = "too_many_connections"   // server or role connection limit reached
+	FetchErrorResources          FetchErrorKind = "insufficient_resources" // disk full, out of memory
+	FetchErrorFetchLimit         FetchErrorKind = "fetch_limit"            // no free in-flight fetch slot of the host within the interval
+	FetchErrorBackoff            FetchErrorKind = "backoff"                // skipped while the host recovers from the connection limit exhaustion
+	FetchErrorOverload           FetchErrorKind = "overload"               // bulk metric skipped by the overload guard of the source
+)
+
+// FetchErrorAction defines what the gatherer should do after a failed fetch
+type FetchErrorAction int
+
+const (
+	FetchErrorActionRetry   FetchErrorAction = iota // try again on the next interval
+	FetchErrorActionSkip                            // expected state, don't count as failure
+	FetchErrorActionDisable                         // won't go away by itself, pause the gatherer for a while
+)
+
+const fetchErrorDisablePeriod = time.Hour // how long to pause a gatherer after a non-recoverable error
+
+// SQLSTATE codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	sqlStateReadOnlySQLTransaction     = "25006"
+	sqlStateInsufficientPrivilege      = "42501"
+	sqlStateUndefinedColumn            = "42703"
+	sqlStateUndefinedFunction          = "42883"
+	sqlStateUndefinedTable             = "42P01"
+	sqlStateUndefinedObject            = "42704"
+	sqlStateInvalidSchemaName          = "3F000"
+	sqlStateFeatureNotSupported        = "0A000"
+	sqlStateTooManyConnections         = "53300"
+	sqlStateObjectNotInPrerequisite    = "55000"
+	sqlStateLockNotAvailable           = "55P03"
+	sqlStateQueryCanceled              = "57014"
+	sqlStateAdminShutdown              = "57P01"
+	sqlStateCrashShutdown              = "57P02"
+	sqlStateCannotConnectNow           = "57P03"
+	sqlStateConnectionExceptionClass   = "08"
+	sqlStateInsufficientResourcesClass = "53"
+)
+
+// ClassifyFetchError maps an error returned by a metric fetch to its kind
+func ClassifyFetchError(err error) FetchErrorKind {
+	if err == nil {
+		return ""
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case sqlStateObjectNotInPrerequisite, sqlStateReadOnlySQLTransaction:
+			return FetchErrorRecovery
+		case sqlStateInsufficientPrivilege:
+			return FetchErrorPermissionDenied
+		case sqlStateUndefinedColumn, sqlStateUndefinedFunction, sqlStateUndefinedTable,
+			sqlStateUndefinedObject, sqlStateInvalidSchemaName, sqlStateFeatureNotSupported:
+			return FetchErrorUndefinedObject
+		case sqlStateQueryCanceled, sqlStateLockNotAvailable:
+			return FetchErrorTimeout
+		case sqlStateTooManyConnections:
+			return FetchErrorTooManyConnections
+		case sqlStateAdminShutdown, sqlStateCrashShutdown, sqlStateCannotConnectNow:
+			return FetchErrorConnection
+		}
+		switch {
+		case strings.HasPrefix(pgErr.Code, sqlStateConnectionExceptionClass):
+			return FetchErrorConnection
+		case strings.HasPrefix(pgErr.Code, sqlStateInsufficientResourcesClass):
+			return FetchErrorResources
+		}
+		return FetchErrorUnknown
+	}
+	switch {
+	case errors.Is(err, errFetchLimitReached):
+		return FetchErrorFetchLimit
+	case errors.Is(err, errConnLimitBackoff):
+		return FetchErrorBackoff
+	case errors.Is(err, errServerOverloaded):
+		return FetchErrorOverload
+	case errors.Is(err, context.DeadlineExceeded) || pgconn.Timeout(err):
+		return FetchErrorTimeout
+	case errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET):
+		return FetchErrorConnection
+	}
+	return FetchErrorUnknown
+}
+
+// Action returns the gatherer behavior for the error kind. Recovery errors are expected
+// only on standbys, e.g. 55000 is also raised by pg_stat_statements not being preloaded
+func (k FetchErrorKind) Action(inRecovery bool) FetchErrorAction {
+	switch k {
+	case FetchErrorRecovery:
+		if inRecovery {
+			return FetchErrorActionSkip
+		}
+	case FetchErrorPermissionDenied, FetchErrorUndefinedObject:
+		return FetchErrorActionDisable
+	case FetchErrorBackoff, FetchErrorOverload:
+		return FetchErrorActionSkip
+	}
+	return FetchErrorActionRetry
+}
+
+// injectedFetchTimeout returns the statement timeout injected for the source or its host, if any
+func injectedFetchTimeout(md *sources.MonitoredDatabase) error {
+	var host string
+	if md.ConnConfig != nil {
+		host = md.ConnConfig.ConnConfig.Host
+	}
+	return faults.FetchTimeout(md.Name, host)
+}
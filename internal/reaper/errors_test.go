@@ -0,0 +1,60 @@
+package reaper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/faults"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyFetchError(t *testing.T) {
+	pgErr := func(code string) error {
+		return fmt.Errorf("wrapped: %w", &pgconn.PgError{Code: code})
+	}
+	for err, kind := range map[error]FetchErrorKind{
+		pgErr("55000"):           FetchErrorRecovery,
+		pgErr("25006"):           FetchErrorRecovery,
+		pgErr("42501"):           FetchErrorPermissionDenied,
+		pgErr("42P01"):           FetchErrorUndefinedObject,
+		pgErr("42883"):           FetchErrorUndefinedObject,
+		pgErr("57014"):           FetchErrorTimeout,
+		pgErr("53300"):           FetchErrorTooManyConnections,
+		pgErr("53100"):           FetchErrorResources,
+		pgErr("53200"):           FetchErrorResources,
+		pgErr("57P01"):           FetchErrorConnection,
+		pgErr("08006"):           FetchErrorConnection,
+		pgErr("22012"):           FetchErrorUnknown,
+		context.DeadlineExceeded: FetchErrorTimeout,
+		fmt.Errorf("dial: %w", syscall.ECONNREFUSED): FetchErrorConnection,
+		errors.New("boom"):                           FetchErrorUnknown,
+	} {
+		assert.Equal(t, kind, ClassifyFetchError(err), err.Error())
+	}
+	assert.Equal(t, FetchErrorKind(""), ClassifyFetchError(nil))
+}
+
+func TestFetchErrorKindAction(t *testing.T) {
+	assert.Equal(t, FetchErrorActionSkip, FetchErrorRecovery.Action(true))
+	assert.Equal(t, FetchErrorActionRetry, FetchErrorRecovery.Action(false), "primary lacking a prerequisite")
+	assert.Equal(t, FetchErrorActionDisable, FetchErrorPermissionDenied.Action(false))
+	assert.Equal(t, FetchErrorActionDisable, FetchErrorUndefinedObject.Action(false))
+	assert.Equal(t, FetchErrorActionRetry, FetchErrorTimeout.Action(false))
+	assert.Equal(t, FetchErrorActionRetry, FetchErrorConnection.Action(false))
+	assert.Equal(t, FetchErrorActionRetry, FetchErrorTooManyConnections.Action(false))
+	assert.Equal(t, FetchErrorActionRetry, FetchErrorResources.Action(false))
+	assert.Equal(t, FetchErrorActionRetry, FetchErrorUnknown.Action(false))
+}
+
+func TestInjectedFetchTimeout(t *testing.T) {
+	faults.Init(faults.CmdOpts{FetchTimeouts: []string{"injected_db"}})
+	defer faults.Init(faults.CmdOpts{})
+	err := injectedFetchTimeout(&sources.MonitoredDatabase{Source: sources.Source{Name: "injected_db"}})
+	assert.Equal(t, FetchErrorTimeout, ClassifyFetchError(err), "handled like a real statement timeout")
+	assert.NoError(t, injectedFetchTimeout(&sources.MonitoredDatabase{Source: sources.Source{Name: "other_db"}}))
+}
@@ -0,0 +1,161 @@
+package reaper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+const (
+	extensionsInventoryMetricName = "extensions_inventory" // internal metric listing the installed extensions per monitored DB
+	extensionsInventoryInterval   = time.Hour
+	outdatedVersionRecoTopic      = "outdated_version"
+	advisoryFeedTimeout           = 30 * time.Second
+)
+
+var regexPgMinorVersion = regexp.MustCompile(`PostgreSQL (\d+\.\d+)`)
+
+// AdvisoryFeed lists the versions the monitored servers should be at least on, e.g.
+//
+//	{"postgres": {"17": "17.4", "16": "16.8"}, "extensions": {"pg_stat_statements": "1.10"}}
+type AdvisoryFeed struct {
+	Postgres   map[string]string `json:"postgres"`   // latest minor release per major version
+	Extensions map[string]string `json:"extensions"` // minimal recommended version per extension
+}
+
+// LoadAdvisoryFeed reads the advisory feed from the file or the http(s) URL
+func LoadAdvisoryFeed(ctx context.Context, location string) (*AdvisoryFeed, error) {
+	var (
+		b   []byte
+		err error
+	)
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		ctx, cancel := context.WithTimeout(ctx, advisoryFeedTimeout)
+		defer cancel()
+		var req *http.Request
+		if req, err = http.NewRequestWithContext(ctx, http.MethodGet, location, nil); err != nil {
+			return nil, err
+		}
+		var resp *http.Response
+		if resp, err = http.DefaultClient.Do(req); err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("advisory feed returned %s", resp.Status)
+		}
+		b, err = io.ReadAll(resp.Body)
+	} else {
+		b, err = os.ReadFile(location)
+	}
+	if err != nil {
+		return nil, err
+	}
+	feed := &AdvisoryFeed{}
+	if err = json.Unmarshal(b, feed); err != nil {
+		return nil, fmt.Errorf("invalid advisory feed %s: %w", location, err)
+	}
+	return feed, nil
+}
+
+// formatExtVersion converts the version from the VersionToInt() format back to "major.minor"
+func formatExtVersion(v int) string {
+	return fmt.Sprintf("%d.%d", v/10000, v/100%100)
+}
+
+// Check returns an outdated_version recommendation for the server and every extension behind the feed versions
+func (f *AdvisoryFeed) Check(ver MonitoredDatabaseSettings) metrics.Measurements {
+	recos := make(metrics.Measurements, 0)
+	reco := func(object, installed, latest string) {
+		recos = append(recos, metrics.Measurement{
+			"tag_reco_topic":  outdatedVersionRecoTopic,
+			"tag_object_name": object,
+			"recommendation":  fmt.Sprintf("%s %s is installed, while %s is available, consider upgrading for security fixes", object, installed, latest),
+			"extra_info":      latest,
+			"major_ver":       ver.Version,
+		})
+	}
+	if m := regexPgMinorVersion.FindStringSubmatch(ver.VersionStr); m != nil {
+		if latest, ok := f.Postgres[fmt.Sprint(ver.Version)]; ok && VersionToInt(m[1]) < VersionToInt(latest) {
+			reco("PostgreSQL", m[1], latest)
+		}
+	}
+	for _, ext := range slices.Sorted(maps.Keys(ver.Extensions)) {
+		if latest, ok := f.Extensions[ext]; ok && ver.Extensions[ext] < VersionToInt(latest) {
+			reco(ext, formatExtVersion(ver.Extensions[ext]), latest)
+		}
+	}
+	return recos
+}
+
+// ExtensionsInventoryMeasurements returns the installed extensions of every monitored Postgres DB
+// and, if the advisory feed is given, the outdated_version recommendations
+func ExtensionsInventoryMeasurements(ctx context.Context, advisoryFeed string) []metrics.MeasurementEnvelope {
+	var feed *AdvisoryFeed
+	if advisoryFeed > "" {
+		var err error
+		if feed, err = LoadAdvisoryFeed(ctx, advisoryFeed); err != nil {
+			log.GetLogger(ctx).WithError(err).Error("could not load advisory feed")
+		}
+	}
+
+	now := time.Now().UnixNano()
+	msgs := make([]metrics.MeasurementEnvelope, 0)
+	for _, dbUniques := range getMonitoredVersions() {
+		for _, dbUnique := range dbUniques {
+			md, err := GetMonitoredDatabaseByUniqueName(dbUnique)
+			if err != nil {
+				continue
+			}
+			MonitoredDatabasesSettingsLock.RLock()
+			ver := MonitoredDatabasesSettings[dbUnique]
+			MonitoredDatabasesSettingsLock.RUnlock()
+
+			rows := make(metrics.Measurements, 0, len(ver.Extensions))
+			for _, ext := range slices.Sorted(maps.Keys(ver.Extensions)) {
+				rows = append(rows, metrics.Measurement{
+					epochColumnName: now,
+					"tag_extname":   ext,
+					"version":       formatExtVersion(ver.Extensions[ext]),
+					"version_num":   ver.Extensions[ext],
+				})
+			}
+			if len(rows) > 0 {
+				msgs = append(msgs, metrics.MeasurementEnvelope{
+					DBName:     dbUnique,
+					SourceType: string(md.Kind),
+					MetricName: extensionsInventoryMetricName,
+					CustomTags: md.CustomTags,
+					Data:       rows,
+				})
+			}
+			if feed == nil {
+				continue
+			}
+			if recos := feed.Check(ver); len(recos) > 0 {
+				for _, r := range recos {
+					r[epochColumnName] = now
+				}
+				msgs = append(msgs, metrics.MeasurementEnvelope{
+					DBName:     dbUnique,
+					SourceType: string(md.Kind),
+					MetricName: recoMetricName,
+					CustomTags: md.CustomTags,
+					Data:       recos,
+				})
+			}
+		}
+	}
+	return msgs
+}
@@ -0,0 +1,100 @@
+package reaper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAdvisoryFeed = `{"postgres": {"17": "17.4"}, "extensions": {"pg_stat_statements": "1.11", "postgis": "3.4"}}`
+
+func TestLoadAdvisoryFeed(t *testing.T) {
+	ctx := context.Background()
+	fileName := filepath.Join(t.TempDir(), "feed.json")
+	require.NoError(t, os.WriteFile(fileName, []byte(testAdvisoryFeed), 0600))
+	feed, err := LoadAdvisoryFeed(ctx, fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, "17.4", feed.Postgres["17"])
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(testAdvisoryFeed))
+	}))
+	defer ts.Close()
+	feed, err = LoadAdvisoryFeed(ctx, ts.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "3.4", feed.Extensions["postgis"])
+
+	ts.Config.Handler = http.NotFoundHandler()
+	_, err = LoadAdvisoryFeed(ctx, ts.URL)
+	assert.Error(t, err)
+
+	require.NoError(t, os.WriteFile(fileName, []byte("garbage"), 0600))
+	_, err = LoadAdvisoryFeed(ctx, fileName)
+	assert.Error(t, err)
+}
+
+func TestAdvisoryFeedCheck(t *testing.T) {
+	feed := &AdvisoryFeed{
+		Postgres:   map[string]string{"17": "17.4"},
+		Extensions: map[string]string{"pg_stat_statements": "1.11", "postgis": "3.4"},
+	}
+	ver := MonitoredDatabaseSettings{
+		Version:    17,
+		VersionStr: "PostgreSQL 17.2 on x86_64-pc-linux-gnu",
+		Extensions: map[string]int{"pg_stat_statements": VersionToInt("1.10"), "postgis": VersionToInt("3.5"), "plpgsql": VersionToInt("1.0")},
+	}
+	recos := feed.Check(ver)
+	if assert.Len(t, recos, 2) {
+		assert.Equal(t, "PostgreSQL", recos[0]["tag_object_name"])
+		assert.Equal(t, outdatedVersionRecoTopic, recos[0]["tag_reco_topic"])
+		assert.Equal(t, "pg_stat_statements", recos[1]["tag_object_name"])
+		assert.Equal(t, "pg_stat_statements 1.10 is installed, while 1.11 is available, consider upgrading for security fixes", recos[1]["recommendation"])
+	}
+
+	ver.VersionStr = "PostgreSQL 17.4 on x86_64-pc-linux-gnu"
+	ver.Extensions["pg_stat_statements"] = VersionToInt("1.11")
+	assert.Empty(t, feed.Check(ver), "up to date server")
+}
+
+func TestExtensionsInventoryMeasurements(t *testing.T) {
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{{Source: sources.Source{Name: "ext_db1", Kind: sources.SourcePostgres}}})
+	MonitoredDatabasesSettingsLock.Lock()
+	MonitoredDatabasesSettings["ext_db1"] = MonitoredDatabaseSettings{
+		Version:    17,
+		VersionStr: "PostgreSQL 17.2 on x86_64-pc-linux-gnu",
+		Extensions: map[string]int{"pg_stat_statements": VersionToInt("1.10")},
+	}
+	MonitoredDatabasesSettingsLock.Unlock()
+	defer func() {
+		UpdateMonitoredDBCache(nil)
+		MonitoredDatabasesSettingsLock.Lock()
+		delete(MonitoredDatabasesSettings, "ext_db1")
+		MonitoredDatabasesSettingsLock.Unlock()
+	}()
+
+	msgs := ExtensionsInventoryMeasurements(context.Background(), "")
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, extensionsInventoryMetricName, msgs[0].MetricName)
+		assert.Equal(t, "pg_stat_statements", msgs[0].Data[0]["tag_extname"])
+		assert.Equal(t, "1.10", msgs[0].Data[0]["version"])
+	}
+
+	fileName := filepath.Join(t.TempDir(), "feed.json")
+	require.NoError(t, os.WriteFile(fileName, []byte(testAdvisoryFeed), 0600))
+	msgs = ExtensionsInventoryMeasurements(context.Background(), fileName)
+	if assert.Len(t, msgs, 2) {
+		assert.Equal(t, recoMetricName, msgs[1].MetricName)
+		assert.Len(t, msgs[1].Data, 2)
+		assert.Contains(t, msgs[1].Data[0], epochColumnName)
+	}
+
+	msgs = ExtensionsInventoryMeasurements(context.Background(), filepath.Join(t.TempDir(), "missing.json"))
+	assert.Len(t, msgs, 1, "inventory is stored even if the feed is not available")
+}
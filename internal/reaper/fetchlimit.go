@@ -0,0 +1,178 @@
+package reaper
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var errFetchLimitReached = errors.New("in-flight fetch limit of the host reached")
+
+// HostFetchLimit describes the saturation of the in-flight fetch limit of a physical host
+type HostFetchLimit struct {
+	Host     string `json:"host"`
+	Limit    int    `json:"limit"`
+	InFlight int    `json:"in_flight"`
+	Queued   int    `json:"queued"`
+	Waits    int64  `json:"waits"`    // fetches that had to queue for a free slot
+	Skipped  int64  `json:"skipped"`  // fetches not started within their interval
+	MaxWait  string `json:"max_wait"` // longest queueing of a fetch
+
+	ConnLimitHits int64  `json:"conn_limit_hits"`         // times the connection limit of the server was reached
+	BackoffUntil  string `json:"backoff_until,omitempty"` // fetches are paused, then run one at a time, until then
+	ReducedUntil  string `json:"reduced_until,omitempty"`
+}
+
+// hostLimiter limits the concurrent metric fetches of all the monitored DBs of a physical host,
+// independently of the per DB connection pools
+type hostLimiter struct {
+	slots   chan struct{}
+	queued  int
+	waits   int64
+	skipped int64
+	maxWait time.Duration
+}
+
+var hostLimiters = make(map[string]*hostLimiter) // [host:port]=limiter
+var fetchHosts = make(map[string]string)         // [connstr]=host:port, parsing is not cheap
+var hostLimitersLock sync.Mutex
+
+// fetchHost returns the host and port of the monitored DB, the DB name itself is used if not resolvable.
+// Must be called with the lock held
+func fetchHost(dbUnique string) string {
+	md, err := GetMonitoredDatabaseByUniqueName(dbUnique)
+	if err != nil {
+		return dbUnique
+	}
+	if host, ok := fetchHosts[md.ConnStr]; ok {
+		return host
+	}
+	host := dbUnique
+	if conf, err := pgconn.ParseConfig(md.ConnStr); err == nil {
+		host = net.JoinHostPort(conf.Host, strconv.Itoa(int(conf.Port)))
+	}
+	fetchHosts[md.ConnStr] = host
+	return host
+}
+
+// waitSlot takes a slot of the semaphore waiting at most the given period
+func waitSlot(ctx context.Context, slots chan struct{}, maxWait time.Duration) error {
+	select {
+	case slots <- struct{}{}:
+		return nil
+	case <-time.After(maxWait):
+		return errFetchLimitReached
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// acquire takes a fetch slot of the host waiting at most the given period
+func (hl *hostLimiter) acquire(ctx context.Context, maxWait time.Duration) (err error) {
+	select {
+	case hl.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	hostLimitersLock.Lock()
+	hl.queued++
+	hl.waits++
+	hostLimitersLock.Unlock()
+	start := time.Now()
+	defer func() {
+		hostLimitersLock.Lock()
+		defer hostLimitersLock.Unlock()
+		hl.queued--
+		hl.maxWait = max(hl.maxWait, time.Since(start))
+		if err != nil {
+			hl.skipped++
+		}
+	}()
+	return waitSlot(ctx, hl.slots, maxWait)
+}
+
+// acquireFetchSlot waits for a free fetch slot of the physical host of the monitored DB for at most
+// the given period. The returned function releases the slot. A limit of 0 disables the limiting, the
+// backoff after the connection limit of the host was reached applies nevertheless
+func acquireFetchSlot(ctx context.Context, dbUnique string, limit int, maxWait time.Duration) (release func(), err error) {
+	var hl *hostLimiter
+	hostLimitersLock.Lock()
+	host := fetchHost(dbUnique)
+	reduced, err := connLimitSlot(host, time.Now())
+	if err == nil && limit > 0 {
+		var ok bool
+		if hl, ok = hostLimiters[host]; !ok || cap(hl.slots) != limit {
+			hl = &hostLimiter{slots: make(chan struct{}, limit)}
+			hostLimiters[host] = hl
+		}
+	}
+	hostLimitersLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	releaseReduced := func() {}
+	if reduced != nil {
+		if err = waitSlot(ctx, reduced, maxWait); err != nil {
+			return nil, err
+		}
+		releaseReduced = func() { <-reduced }
+	}
+	if hl == nil {
+		return releaseReduced, nil
+	}
+	if err = hl.acquire(ctx, maxWait); err != nil {
+		releaseReduced()
+		return nil, err
+	}
+	return func() {
+		<-hl.slots
+		releaseReduced()
+	}, nil
+}
+
+// HostFetchLimits returns the saturation of the in-flight fetch limits and the connection limit backoffs sorted by host
+func HostFetchLimits() []HostFetchLimit {
+	hostLimitersLock.Lock()
+	defer hostLimitersLock.Unlock()
+	byHost := make(map[string]*HostFetchLimit, len(hostLimiters))
+	for host, hl := range hostLimiters {
+		byHost[host] = &HostFetchLimit{
+			Host:     host,
+			Limit:    cap(hl.slots),
+			InFlight: len(hl.slots),
+			Queued:   hl.queued,
+			Waits:    hl.waits,
+			Skipped:  hl.skipped,
+			MaxWait:  hl.maxWait.Round(time.Millisecond).String(),
+		}
+	}
+	now := time.Now()
+	for host, cl := range connLimits {
+		fl, ok := byHost[host]
+		if !ok {
+			fl = &HostFetchLimit{Host: host}
+			byHost[host] = fl
+		}
+		fl.ConnLimitHits = cl.hits
+		if now.Before(cl.backoffUntil) {
+			fl.BackoffUntil = cl.backoffUntil.Format(time.RFC3339)
+		}
+		if now.Before(cl.reducedUntil) {
+			fl.ReducedUntil = cl.reducedUntil.Format(time.RFC3339)
+		}
+	}
+	limits := make([]HostFetchLimit, 0, len(byHost))
+	for _, fl := range byHost {
+		limits = append(limits, *fl)
+	}
+	slices.SortFunc(limits, func(a, b HostFetchLimit) int { return cmp.Compare(a.Host, b.Host) })
+	return limits
+}
@@ -0,0 +1,52 @@
+package reaper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquireFetchSlot(t *testing.T) {
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{
+		{Source: sources.Source{Name: "db1", ConnStr: "postgres://host1:5432/db1"}},
+		{Source: sources.Source{Name: "db2", ConnStr: "postgres://host1:5432/db2"}},
+		{Source: sources.Source{Name: "db3", ConnStr: "postgres://host2:5432/db3"}},
+	})
+	defer UpdateMonitoredDBCache(nil)
+	ctx := context.Background()
+
+	release, err := acquireFetchSlot(ctx, "db1", 0, 0)
+	require.NoError(t, err, "no limit")
+	release()
+	assert.Empty(t, HostFetchLimits())
+
+	release1, err := acquireFetchSlot(ctx, "db1", 2, time.Second)
+	require.NoError(t, err)
+	release2, err := acquireFetchSlot(ctx, "db2", 2, time.Second)
+	require.NoError(t, err)
+	release3, err := acquireFetchSlot(ctx, "db3", 2, time.Second)
+	require.NoError(t, err, "other hosts have their own slots")
+	defer release3()
+
+	_, err = acquireFetchSlot(ctx, "db2", 2, 10*time.Millisecond)
+	assert.ErrorIs(t, err, errFetchLimitReached)
+	assert.Equal(t, FetchErrorFetchLimit, ClassifyFetchError(err))
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		release1()
+	}()
+	release, err = acquireFetchSlot(ctx, "db2", 2, time.Second)
+	require.NoError(t, err, "queued until a slot is released")
+	release()
+	release2()
+
+	limits := HostFetchLimits()
+	require.Len(t, limits, 2)
+	assert.Equal(t, HostFetchLimit{Host: "host1:5432", Limit: 2, Waits: 2, Skipped: 1, MaxWait: limits[0].MaxWait}, limits[0])
+	assert.Equal(t, 1, limits[1].InFlight)
+}
@@ -3,7 +3,9 @@ package reaper
 import (
 	"context"
 	"os"
+	"sync"
 
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics/psutil"
 )
@@ -30,11 +32,21 @@ const (
 
 var directlyFetchableOSMetrics = map[string]bool{metricPsutilCPU: true, metricPsutilDisk: true, metricPsutilDiskIoTotal: true, metricPsutilMem: true, metricCPULoad: true}
 
+// unsupportedOSMetrics are the source and metric pairs found not directly fetchable at runtime, e.g. on a platform
+// gopsutil doesn't support or for a data directory not on the host, they are fetched via the metric SQL instead
+var unsupportedOSMetrics sync.Map
+
 func IsDirectlyFetchableMetric(metric string) bool {
 	_, ok := directlyFetchableOSMetrics[metric]
 	return ok
 }
 
+// isDirectlyFetchable returns true if the metric of the source is fetched directly from the OS
+func isDirectlyFetchable(dbUnique, metric string) bool {
+	_, unsupported := unsupportedOSMetrics.Load([2]string{dbUnique, metric})
+	return !unsupported && IsDirectlyFetchableMetric(metric)
+}
+
 func FetchStatsDirectlyFromOS(ctx context.Context, msg MetricFetchConfig, vme MonitoredDatabaseSettings, mvp metrics.Metric) ([]metrics.MeasurementEnvelope, error) {
 	var data []map[string]any
 	var err error
@@ -50,6 +62,12 @@ func FetchStatsDirectlyFromOS(ctx context.Context, msg MetricFetchConfig, vme Mo
 	} else if msg.MetricName == metricPsutilMem {
 		data, err = psutil.GetGoPsutilMem()
 	}
+	if psutil.IsNotSupported(err) {
+		if _, loaded := unsupportedOSMetrics.LoadOrStore([2]string{msg.DBUniqueName, msg.MetricName}, true); !loaded {
+			log.GetLogger(ctx).WithError(err).Warningf("[%s] not available directly from the OS, fetching via the metric SQL", msg.MetricName)
+		}
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
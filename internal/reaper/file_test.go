@@ -0,0 +1,89 @@
+package reaper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/cmdopts"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchStatsDirectlyFromOSUnsupported(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer conn.Close()
+	md := &sources.MonitoredDatabase{Source: sources.Source{Name: "remote_db", Kind: sources.SourcePostgres}, Conn: conn}
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{md})
+	defer UpdateMonitoredDBCache(nil)
+	defer unsupportedOSMetrics.Clear()
+
+	conn.ExpectBegin()
+	conn.ExpectExec("SET LOCAL").WillReturnResult(pgxmock.NewResult("SET", 0))
+	conn.ExpectQuery("current_setting").WillReturnRows(pgxmock.NewRows([]string{"dd", "ld", "pgver"}).
+		AddRow("/nonexistent/pgdata", "log", int32(170000)))
+	conn.ExpectCommit()
+	conn.ExpectBegin()
+	conn.ExpectExec("SET LOCAL").WillReturnResult(pgxmock.NewResult("SET", 0))
+	conn.ExpectQuery("pg_tablespace").WillReturnRows(pgxmock.NewRows([]string{"name", "location"}))
+	conn.ExpectCommit()
+
+	assert.True(t, isDirectlyFetchable("remote_db", metricPsutilDisk))
+	msg := MetricFetchConfig{DBUniqueName: "remote_db", MetricName: metricPsutilDisk}
+	msgs, err := FetchStatsDirectlyFromOS(context.Background(), msg, MonitoredDatabaseSettings{}, metrics.Metric{})
+	assert.NoError(t, err, "falls back to the metric SQL")
+	assert.Nil(t, msgs)
+	assert.False(t, isDirectlyFetchable("remote_db", metricPsutilDisk), "not tried directly anymore")
+	assert.True(t, isDirectlyFetchable("local_db", metricPsutilDisk), "other sources are not affected")
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
+
+func TestFetchMeasurementsFallsBackToSQL(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer conn.Close()
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{{Source: sources.Source{Name: "os_db", Kind: sources.SourcePostgres}, Conn: conn}})
+	defer UpdateMonitoredDBCache(nil)
+	MonitoredDatabasesSettingsLock.Lock()
+	MonitoredDatabasesSettings["os_db"] = MonitoredDatabaseSettings{Version: 170000, LastCheckedOn: time.Now()}
+	MonitoredDatabasesSettingsLock.Unlock()
+	defer func() {
+		MonitoredDatabasesSettingsLock.Lock()
+		delete(MonitoredDatabasesSettings, "os_db")
+		MonitoredDatabasesSettingsLock.Unlock()
+	}()
+	metricDefMapLock.Lock()
+	prevDefs := metricDefinitionMap.MetricDefs
+	metricDefinitionMap.MetricDefs = metrics.MetricDefs{metricPsutilDisk: {SQLs: metrics.SQLs{11: "select * from get_psutil_disk()"}}}
+	metricDefMapLock.Unlock()
+	defer func() {
+		metricDefMapLock.Lock()
+		metricDefinitionMap.MetricDefs = prevDefs
+		metricDefMapLock.Unlock()
+	}()
+
+	// the direct OS read fails to determine the disk paths
+	conn.ExpectBegin()
+	conn.ExpectExec("SET LOCAL").WillReturnResult(pgxmock.NewResult("SET", 0))
+	conn.ExpectQuery("current_setting").WillReturnError(assert.AnError)
+	conn.ExpectCommit()
+	conn.ExpectBegin()
+	conn.ExpectExec("SET LOCAL").WillReturnResult(pgxmock.NewResult("SET", 0))
+	conn.ExpectQuery("get_psutil_disk").WillReturnRows(pgxmock.NewRows([]string{"epoch_ns", "tag_path", "percent"}).
+		AddRow(time.Now().UnixNano(), "/pgdata", 42.0))
+	conn.ExpectCommit()
+
+	opts := &cmdopts.Options{}
+	opts.Metrics.DirectOSStats = true
+	r := NewReaper(opts, nil, nil)
+	msg := MetricFetchConfig{DBUniqueName: "os_db", MetricName: metricPsutilDisk, Source: sources.SourcePostgres, Interval: time.Minute}
+	msgs, err := r.fetchMeasurements(context.Background(), msg, MonitoredDatabaseSettings{}, metrics.Metric{}, nil)
+	assert.NoError(t, err, "the metric SQL is used instead")
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "/pgdata", msgs[0].Data[0]["tag_path"])
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
@@ -0,0 +1,47 @@
+//go:build !windows
+
+package reaper
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// fileRunLock is an exclusive flock() of the lock file
+type fileRunLock struct {
+	file *os.File
+}
+
+func (l *fileRunLock) Release() {
+	_ = syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	_ = l.file.Close()
+}
+
+// lockFile takes the exclusive lock of the file without waiting and records the PID in it, the lock file itself
+// is left in place
+func lockFile(name, collectorID string) (RunLock, error) {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		_ = f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("another pgwatch collector with the id %q is already running on this host (lock file %s), "+
+				"set a distinct --collector-id or --run-lock=off to run both", collectorID, name)
+		}
+		return nil, err
+	}
+	// the PID lets a collector started with --handover find this one
+	if err = f.Truncate(0); err == nil {
+		_, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &fileRunLock{file: f}, nil
+}
@@ -0,0 +1,37 @@
+package reaper
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// fileRunLock is an exclusive LockFileEx() of the lock file
+type fileRunLock struct {
+	file *os.File
+}
+
+func (l *fileRunLock) Release() {
+	_ = windows.UnlockFileEx(windows.Handle(l.file.Fd()), 0, 1, 0, &windows.Overlapped{})
+	_ = l.file.Close()
+}
+
+// lockFile takes the exclusive lock of the file without waiting, the lock file itself is left in place
+func lockFile(name, collectorID string) (RunLock, error) {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	err = windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &windows.Overlapped{})
+	if err != nil {
+		_ = f.Close()
+		if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+			return nil, fmt.Errorf("another pgwatch collector with the id %q is already running on this host (lock file %s), "+
+				"set a distinct --collector-id or --run-lock=off to run both", collectorID, name)
+		}
+		return nil, err
+	}
+	return &fileRunLock{file: f}, nil
+}
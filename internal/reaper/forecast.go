@@ -0,0 +1,216 @@
+package reaper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sinks"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+)
+
+const (
+	growthForecastMetricName = "growth_forecast" // internal metric with the projected database and largest table sizes
+	growthForecastInterval   = time.Hour
+	growthForecastHistory    = 30 * 24 * time.Hour // stored history the trends are fitted on
+	growthForecastMinHistory = 24 * time.Hour      // shorter histories are not projected
+	growthForecastTopTables  = 10
+)
+
+// growth forecast models
+const (
+	growthModelLinear      = "linear"
+	growthModelExponential = "exponential"
+)
+
+// growthForecastHorizons are the projection horizons, in days
+var growthForecastHorizons = []int{30, 90}
+
+// growthTrend is a size trend fitted on the history, the time is in days relative to the latest point
+type growthTrend struct {
+	model string
+	a, b  float64 // linear: size = a + b*t, exponential: size = a * e^(b*t)
+	r2    float64 // coefficient of determination of the fit
+}
+
+// at returns the size projected by the trend after the given days
+func (g growthTrend) at(days float64) float64 {
+	if g.model == growthModelExponential {
+		return g.a * math.Exp(g.b*days)
+	}
+	return g.a + g.b*days
+}
+
+// perDay returns the growth per day at the latest point
+func (g growthTrend) perDay() float64 {
+	if g.model == growthModelExponential {
+		return g.a * g.b
+	}
+	return g.b
+}
+
+// daysUntil returns the days until the trend reaches the size, ok is false if it never does
+func (g growthTrend) daysUntil(size float64) (days float64, ok bool) {
+	current := g.at(0)
+	switch {
+	case current >= size:
+		return 0, true
+	case g.b <= 0:
+		return 0, false
+	case g.model == growthModelExponential:
+		return math.Log(size/current) / g.b, true
+	default:
+		return (size - current) / g.b, true
+	}
+}
+
+// leastSquares fits y = a + b*x
+func leastSquares(x, y []float64) (a, b float64) {
+	n := float64(len(x))
+	var sx, sy, sxx, sxy float64
+	for i := range x {
+		sx += x[i]
+		sy += y[i]
+		sxx += x[i] * x[i]
+		sxy += x[i] * y[i]
+	}
+	if d := n*sxx - sx*sx; d != 0 {
+		b = (n*sxy - sx*sy) / d
+	}
+	return (sy - b*sx) / n, b
+}
+
+// rSquared returns the coefficient of determination of the trend on the observed sizes
+func rSquared(g growthTrend, days, sizes []float64) float64 {
+	var mean, ssRes, ssTot float64
+	for _, s := range sizes {
+		mean += s
+	}
+	mean /= float64(len(sizes))
+	for i, s := range sizes {
+		ssRes += (s - g.at(days[i])) * (s - g.at(days[i]))
+		ssTot += (s - mean) * (s - mean)
+	}
+	if ssTot == 0 {
+		return 1 // constant size, fitted exactly by a flat line
+	}
+	return 1 - ssRes/ssTot
+}
+
+// fitGrowthTrend fits a linear and, if all sizes are positive, an exponential trend on the history and returns
+// the better fitting one. ok is false if the history is too short to be projected
+func fitGrowthTrend(history []sinks.HistoryPoint) (trend growthTrend, ok bool) {
+	if len(history) < 3 || history[len(history)-1].Time.Sub(history[0].Time) < growthForecastMinHistory {
+		return growthTrend{}, false
+	}
+	latest := history[len(history)-1].Time
+	days := make([]float64, len(history))
+	sizes := make([]float64, len(history))
+	logSizes := make([]float64, 0, len(history))
+	for i, p := range history {
+		days[i] = p.Time.Sub(latest).Hours() / 24
+		sizes[i] = p.Value
+		if p.Value > 0 {
+			logSizes = append(logSizes, math.Log(p.Value))
+		}
+	}
+	a, b := leastSquares(days, sizes)
+	trend = growthTrend{model: growthModelLinear, a: a, b: b}
+	trend.r2 = rSquared(trend, days, sizes)
+	if len(logSizes) == len(sizes) {
+		a, b = leastSquares(days, logSizes)
+		exp := growthTrend{model: growthModelExponential, a: math.Exp(a), b: b}
+		if exp.r2 = rSquared(exp, days, sizes); exp.r2 > trend.r2 {
+			trend = exp
+		}
+	}
+	return trend, true
+}
+
+// growthForecastRow returns the projections of the history, capacity is the space available in bytes, 0 if unknown
+func growthForecastRow(history []sinks.HistoryPoint, capacity float64, now time.Time) (metrics.Measurement, bool) {
+	trend, ok := fitGrowthTrend(history)
+	if !ok {
+		return nil, false
+	}
+	row := metrics.Measurement{
+		epochColumnName:    now.UnixNano(),
+		"model":            trend.model,
+		"size_b":           int64(history[len(history)-1].Value),
+		"growth_b_per_day": int64(trend.perDay()),
+		"fit_r2":           trend.r2,
+		"history_days":     history[len(history)-1].Time.Sub(history[0].Time).Hours() / 24,
+	}
+	for _, d := range growthForecastHorizons {
+		row[fmt.Sprintf("projected_size_b_%dd", d)] = int64(math.Max(trend.at(float64(d)), 0))
+	}
+	if capacity > 0 {
+		row["capacity_b"] = int64(capacity)
+		if days, ok := trend.daysUntil(capacity); ok {
+			row["days_until_full"] = math.Round(days*10) / 10
+		}
+	}
+	return row, true
+}
+
+// GrowthForecastMeasurements projects the database size and the sizes of the largest tables of the monitored DBs
+// from the db_size and table_stats history stored in the sinks. Nothing is returned if no sink can read it back
+func GrowthForecastMeasurements(ctx context.Context, history sinks.HistoryReader) []metrics.MeasurementEnvelope {
+	monitoredDbCacheLock.RLock()
+	mdbs := make([]*sources.MonitoredDatabase, 0, len(monitoredDbCache))
+	for _, md := range monitoredDbCache {
+		if md.IsPostgresSource() {
+			mdbs = append(mdbs, md)
+		}
+	}
+	monitoredDbCacheLock.RUnlock()
+
+	now := time.Now()
+	since := now.Add(-growthForecastHistory)
+	msgs := make([]metrics.MeasurementEnvelope, 0, len(mdbs))
+	for _, md := range mdbs {
+		logger := log.GetLogger(ctx).WithField("source", md.Name).WithField("metric", growthForecastMetricName)
+		var data metrics.Measurements
+		dbSize, err := history.ColumnHistory(md.Name, "db_size", "size_b", "", since, 1)
+		if errors.Is(err, errors.ErrUnsupported) {
+			logger.Debug("no sink can read back the stored history")
+			return nil
+		}
+		if err != nil {
+			logger.WithError(err).Warning("could not read the db_size history")
+			continue
+		}
+		if row, ok := growthForecastRow(dbSize[""], md.HostConfig.DiskCapacityGB*(1<<30), now); ok {
+			row["tag_kind"] = "database"
+			data = append(data, row)
+		}
+		tables, err := history.ColumnHistory(md.Name, "table_stats", "total_relation_size_b", "table_full_name", since, growthForecastTopTables)
+		if err != nil {
+			logger.WithError(err).Warning("could not read the table_stats history")
+		}
+		for _, table := range slices.Sorted(maps.Keys(tables)) {
+			if row, ok := growthForecastRow(tables[table], 0, now); ok {
+				row["tag_kind"] = "table"
+				row["tag_table_full_name"] = table
+				data = append(data, row)
+			}
+		}
+		if len(data) == 0 {
+			continue
+		}
+		msgs = append(msgs, metrics.MeasurementEnvelope{
+			DBName:     md.Name,
+			SourceType: string(md.Kind),
+			MetricName: growthForecastMetricName,
+			CustomTags: md.CustomTags,
+			Data:       data,
+		})
+	}
+	return msgs
+}
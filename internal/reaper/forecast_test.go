@@ -0,0 +1,102 @@
+package reaper
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sinks"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// growthHistory returns hourly points of the size function over the given days, ending now
+func growthHistory(days int, size func(day float64) float64) (history []sinks.HistoryPoint) {
+	now := time.Now().Truncate(time.Hour)
+	for h := -days * 24; h <= 0; h++ {
+		history = append(history, sinks.HistoryPoint{Time: now.Add(time.Duration(h) * time.Hour), Value: size(float64(h) / 24)})
+	}
+	return
+}
+
+func TestFitGrowthTrend(t *testing.T) {
+	_, ok := fitGrowthTrend(growthHistory(0, func(float64) float64 { return 1 }))
+	assert.False(t, ok, "a single point")
+	_, ok = fitGrowthTrend(growthHistory(1, func(float64) float64 { return 1 })[:12])
+	assert.False(t, ok, "less than a day of history")
+
+	trend, ok := fitGrowthTrend(growthHistory(30, func(d float64) float64 { return 1000 + 10*d }))
+	require.True(t, ok)
+	assert.Equal(t, growthModelLinear, trend.model)
+	assert.InDelta(t, 10, trend.perDay(), 0.01)
+	assert.InDelta(t, 1300, trend.at(30), 0.1)
+	days, ok := trend.daysUntil(2000)
+	assert.True(t, ok)
+	assert.InDelta(t, 100, days, 0.1)
+
+	trend, ok = fitGrowthTrend(growthHistory(30, func(d float64) float64 { return 1000 * math.Exp(0.05*d) }))
+	require.True(t, ok)
+	assert.Equal(t, growthModelExponential, trend.model)
+	assert.InDelta(t, 1000*math.Exp(0.05*90), trend.at(90), 1)
+	assert.Greater(t, trend.r2, 0.999)
+
+	trend, ok = fitGrowthTrend(growthHistory(30, func(d float64) float64 { return 1000 - d }))
+	require.True(t, ok)
+	_, ok = trend.daysUntil(2000)
+	assert.False(t, ok, "shrinking never gets full")
+	days, ok = trend.daysUntil(500)
+	assert.True(t, ok)
+	assert.Zero(t, days, "already above the capacity")
+}
+
+func TestGrowthForecastRow(t *testing.T) {
+	now := time.Now()
+	row, ok := growthForecastRow(growthHistory(10, func(d float64) float64 { return 1 << 30 }), 0, now)
+	require.True(t, ok)
+	assert.Equal(t, int64(1<<30), row["projected_size_b_90d"])
+	assert.NotContains(t, row, "days_until_full", "no capacity configured")
+
+	row, ok = growthForecastRow(growthHistory(10, func(d float64) float64 { return 1e9 + 1e8*d }), 2e9, now)
+	require.True(t, ok)
+	assert.Equal(t, growthModelLinear, row["model"])
+	assert.Equal(t, int64(2e9), row["capacity_b"])
+	assert.InDelta(t, 10, row["days_until_full"], 0.1)
+	assert.InDelta(t, 4e9, float64(row["projected_size_b_30d"].(int64)), 1e6)
+	assert.InDelta(t, 10, row["history_days"], 0.01)
+}
+
+type historyMock map[string]map[string][]sinks.HistoryPoint // [metric][tag value]=history
+
+func (m historyMock) ColumnHistory(_, metricName, _, _ string, _ time.Time, _ int) (map[string][]sinks.HistoryPoint, error) {
+	if m == nil {
+		return nil, errors.ErrUnsupported
+	}
+	return m[metricName], nil
+}
+
+func TestGrowthForecastMeasurements(t *testing.T) {
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{
+		{Source: sources.Source{Name: "db1", Kind: sources.SourcePostgres, HostConfig: sources.HostConfigAttrs{DiskCapacityGB: 2}}},
+	})
+	defer UpdateMonitoredDBCache(nil)
+
+	assert.Empty(t, GrowthForecastMeasurements(context.Background(), historyMock(nil)), "no sink with history")
+
+	msgs := GrowthForecastMeasurements(context.Background(), historyMock{
+		"db_size": {"": growthHistory(7, func(d float64) float64 { return (1 << 30) + (1<<27)*d })},
+		"table_stats": {
+			"public.big":   growthHistory(7, func(d float64) float64 { return 1 << 29 }),
+			"public.young": growthHistory(0, func(d float64) float64 { return 1 << 20 }),
+		},
+	})
+	require.Len(t, msgs, 1)
+	assert.Equal(t, growthForecastMetricName, msgs[0].MetricName)
+	require.Len(t, msgs[0].Data, 2, "too short histories are skipped")
+	assert.Equal(t, "database", msgs[0].Data[0]["tag_kind"])
+	assert.InDelta(t, 8, msgs[0].Data[0]["days_until_full"], 0.1)
+	assert.Equal(t, "public.big", msgs[0].Data[1]["tag_table_full_name"])
+	assert.NotContains(t, msgs[0].Data[1], "days_until_full")
+}
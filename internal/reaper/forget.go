@@ -0,0 +1,89 @@
+package reaper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+)
+
+const forgetCheckInterval = time.Minute
+
+var scheduledForgets = make(map[string]time.Time) // source = forget after
+var scheduledForgetsLock sync.Mutex
+
+// ForgetSource removes the stored measurements of a decommissioned source from the sinks, immediately
+// or after the embargo period. Sources still being monitored can't be forgotten
+func (r *Reaper) ForgetSource(name string, embargo time.Duration) error {
+	if name == "" {
+		return errors.New("source name is required")
+	}
+	if _, err := GetMonitoredDatabaseByUniqueName(name); err == nil {
+		return fmt.Errorf("source %q is still monitored, remove it from the configuration first", name)
+	}
+	if embargo > 0 {
+		scheduledForgetsLock.Lock()
+		scheduledForgets[name] = time.Now().Add(embargo)
+		scheduledForgetsLock.Unlock()
+		return nil
+	}
+	mw := r.measurementsWriter.Load()
+	if mw == nil {
+		return errors.New("sinks are not initialized yet")
+	}
+	return mw.ForgetSource(name)
+}
+
+// ScheduledForgets returns the sources waiting for the embargo period to pass to be forgotten
+func (r *Reaper) ScheduledForgets() map[string]time.Time {
+	scheduledForgetsLock.Lock()
+	defer scheduledForgetsLock.Unlock()
+	return maps.Clone(scheduledForgets)
+}
+
+// CancelForget cancels the scheduled removal of the source data, false is returned if none is scheduled
+func (r *Reaper) CancelForget(name string) bool {
+	scheduledForgetsLock.Lock()
+	defer scheduledForgetsLock.Unlock()
+	_, ok := scheduledForgets[name]
+	delete(scheduledForgets, name)
+	return ok
+}
+
+// runScheduledForgets forgets the sources whose embargo period has passed. The sources added back
+// to the configuration in the meantime are kept
+func (r *Reaper) runScheduledForgets(ctx context.Context, now time.Time) {
+	scheduledForgetsLock.Lock()
+	due := make([]string, 0)
+	for name, after := range scheduledForgets {
+		if !now.Before(after) {
+			due = append(due, name)
+			delete(scheduledForgets, name)
+		}
+	}
+	scheduledForgetsLock.Unlock()
+	for _, name := range due {
+		l := log.GetLogger(ctx).WithField("source", name)
+		if err := r.ForgetSource(name, 0); err != nil {
+			l.WithError(err).Error("could not forget source")
+			continue
+		}
+		l.Info("source forgotten after the embargo period")
+	}
+}
+
+// WatchScheduledForgets periodically forgets the sources whose embargo period has passed
+func (r *Reaper) WatchScheduledForgets(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-time.After(forgetCheckInterval):
+			r.runScheduledForgets(ctx, now)
+		}
+	}
+}
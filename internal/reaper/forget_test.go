@@ -0,0 +1,54 @@
+package reaper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/cmdopts"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sinks"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/stretchr/testify/assert"
+)
+
+type forgettingWriter struct {
+	sinks.JSONWriter
+	forgotten []string
+}
+
+func (w *forgettingWriter) ForgetSource(dbUnique string) error {
+	w.forgotten = append(w.forgotten, dbUnique)
+	return nil
+}
+
+func TestForgetSource(t *testing.T) {
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{{Source: sources.Source{Name: "active"}}})
+	defer UpdateMonitoredDBCache(nil)
+	r := NewReaper(&cmdopts.Options{}, nil, nil)
+
+	assert.Error(t, r.ForgetSource("", 0))
+	assert.ErrorContains(t, r.ForgetSource("active", 0), "still monitored")
+	assert.ErrorContains(t, r.ForgetSource("old", 0), "not initialized")
+
+	w := &forgettingWriter{}
+	mw := &sinks.MultiWriter{}
+	mw.AddWriter(w)
+	r.measurementsWriter.Store(mw)
+	assert.NoError(t, r.ForgetSource("old", 0))
+	assert.Equal(t, []string{"old"}, w.forgotten)
+
+	assert.NoError(t, r.ForgetSource("old2", time.Hour))
+	assert.NoError(t, r.ForgetSource("old3", time.Hour))
+	assert.NoError(t, r.ForgetSource("active2", time.Hour))
+	assert.Len(t, r.ScheduledForgets(), 3)
+	assert.True(t, r.CancelForget("old3"))
+	assert.False(t, r.CancelForget("old3"), "nothing scheduled anymore")
+
+	r.runScheduledForgets(context.Background(), time.Now())
+	assert.Equal(t, []string{"old"}, w.forgotten, "embargo period not passed yet")
+
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{{Source: sources.Source{Name: "active2"}}})
+	r.runScheduledForgets(context.Background(), time.Now().Add(2*time.Hour))
+	assert.Equal(t, []string{"old", "old2"}, w.forgotten, "sources added back in the meantime are kept")
+	assert.Empty(t, r.ScheduledForgets())
+}
@@ -0,0 +1,243 @@
+package reaper
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+// index recommendation topics
+const (
+	dropIndexRecoTopic    = "drop_index"
+	rebuildIndexRecoTopic = "rebuild_index"
+)
+
+const sqlIndexDefinitions = `select /* pgwatch_generated */
+  i.indexrelid::int8 as oid,
+  quote_ident(n.nspname) || '.' || quote_ident(ci.relname) as index_name,
+  quote_ident(n.nspname) || '.' || quote_ident(ct.relname) as table_name,
+  am.amname::text as access_method,
+  i.indkey::text as columns,
+  i.indclass::text as opclasses,
+  coalesce(pg_get_expr(i.indexprs, i.indrelid), '') as expressions,
+  coalesce(pg_get_expr(i.indpred, i.indrelid), '') as predicate,
+  i.indisunique as is_unique,
+  i.indisvalid as is_valid,
+  i.indisprimary or i.indisreplident or exists (select from pg_constraint where conindid = i.indexrelid) as is_constraint,
+  pg_relation_size(i.indexrelid) as size_b,
+  coalesce(s.idx_scan, 0) as idx_scan
+from
+  pg_index i
+  join pg_class ci on ci.oid = i.indexrelid
+  join pg_class ct on ct.oid = i.indrelid
+  join pg_namespace n on n.oid = ci.relnamespace
+  join pg_am am on am.oid = ci.relam
+  left join pg_stat_user_indexes s on s.indexrelid = i.indexrelid
+where
+  n.nspname not in ('pg_catalog', 'information_schema', 'pg_toast')
+  and n.nspname not like 'pg_temp%'
+  and n.nspname not like E'\\_timescaledb%'`
+
+// indexDefinition is an index of the monitored DB as read by sqlIndexDefinitions
+type indexDefinition struct {
+	oid         int64
+	name, table string
+	method      string
+	columns     []string // attribute numbers, 0 for expressions
+	opclasses   []string
+	expressions string
+	predicate   string
+	unique      bool
+	valid       bool
+	constraint  bool // backs a primary key, unique or exclusion constraint or the replica identity
+	size, scans int64
+	unusedSince time.Time // zero if the usage is not tracked
+}
+
+// indexUsage is the scan count of an index and the time it was last seen increasing or first seen
+type indexUsage struct {
+	scans int64
+	since time.Time
+}
+
+var indexUsages = make(map[string]map[int64]indexUsage) // [dbUnique][index oid]
+var indexUsagesLock sync.Mutex
+
+func newIndexDefinition(row metrics.Measurement) indexDefinition {
+	idx := indexDefinition{}
+	idx.oid, _ = row["oid"].(int64)
+	idx.name, _ = row["index_name"].(string)
+	idx.table, _ = row["table_name"].(string)
+	idx.method, _ = row["access_method"].(string)
+	columns, _ := row["columns"].(string)
+	idx.columns = strings.Fields(columns)
+	opclasses, _ := row["opclasses"].(string)
+	idx.opclasses = strings.Fields(opclasses)
+	idx.expressions, _ = row["expressions"].(string)
+	idx.predicate, _ = row["predicate"].(string)
+	idx.unique, _ = row["is_unique"].(bool)
+	idx.valid, _ = row["is_valid"].(bool)
+	idx.constraint, _ = row["is_constraint"].(bool)
+	idx.size, _ = row["size_b"].(int64)
+	idx.scans, _ = row["idx_scan"].(int64)
+	return idx
+}
+
+// key returns the definition of the index apart from its name, equal for duplicate indexes
+func (idx indexDefinition) key() string {
+	return strings.Join([]string{idx.table, idx.method, strings.Join(idx.columns, ","), strings.Join(idx.opclasses, ","),
+		idx.expressions, idx.predicate}, "\x00")
+}
+
+// droppable returns true if the index can be dropped without changing the constraints of the table
+func (idx indexDefinition) droppable() bool {
+	return !idx.unique && !idx.constraint
+}
+
+// coveredBy returns true if the index is a btree on a leading column prefix of the other one, so the other
+// one serves the same queries
+func (idx indexDefinition) coveredBy(other indexDefinition) bool {
+	return idx.method == "btree" && other.method == "btree" && idx.table == other.table &&
+		idx.expressions == "" && other.expressions == "" && idx.predicate == other.predicate &&
+		len(idx.columns) < len(other.columns) &&
+		slices.Equal(idx.columns, other.columns[:len(idx.columns)]) &&
+		slices.Equal(idx.opclasses, other.opclasses[:len(idx.opclasses)])
+}
+
+// trackIndexUsage updates the scan counts of the indexes of the DB and sets the time since they are unused.
+// Indexes of a DB in recovery are not tracked, as the primary's usage counts
+func trackIndexUsage(dbUnique string, indexes []indexDefinition, now time.Time) {
+	indexUsagesLock.Lock()
+	defer indexUsagesLock.Unlock()
+	prev := indexUsages[dbUnique]
+	usages := make(map[int64]indexUsage, len(indexes))
+	for i, idx := range indexes {
+		u, ok := prev[idx.oid]
+		if !ok || idx.scans != u.scans { // new, used or its statistics reset
+			u = indexUsage{scans: idx.scans, since: now}
+		}
+		usages[idx.oid] = u
+		indexes[i].unusedSince = u.since
+	}
+	indexUsages[dbUnique] = usages
+}
+
+// indexRecommendation returns a recommendations row about the index
+func indexRecommendation(epochNs int64, topic string, idx indexDefinition, recommendation, info string) metrics.Measurement {
+	return metrics.Measurement{
+		epochColumnName:       epochNs,
+		"tag_reco_topic":      topic,
+		"tag_object_name":     idx.name,
+		"recommendation":      recommendation,
+		"extra_info":          info,
+		"estimated_savings_b": idx.size,
+	}
+}
+
+// analyzeIndexes returns the DROP suggestions for the unused, duplicate and overlapping indexes and the REBUILD
+// suggestions for the invalid ones. unusedAge is how long an index has to be without scans to be reported
+func analyzeIndexes(indexes []indexDefinition, now time.Time, unusedAge time.Duration, version int) (recos metrics.Measurements) {
+	epochNs := now.UnixNano()
+	reported := make(map[int64]bool)
+	slices.SortFunc(indexes, func(a, b indexDefinition) int { return strings.Compare(a.name, b.name) })
+
+	for _, idx := range indexes {
+		if idx.valid {
+			continue
+		}
+		reindex := "REINDEX INDEX "
+		if version >= 120000 {
+			reindex = "REINDEX INDEX CONCURRENTLY "
+		}
+		recos = append(recos, indexRecommendation(epochNs, rebuildIndexRecoTopic, idx, reindex+idx.name+";",
+			fmt.Sprintf("invalid index of %s, e.g. after a failed CREATE INDEX CONCURRENTLY, is maintained but never used; "+
+				"rebuild or drop it", idx.table)))
+		reported[idx.oid] = true
+	}
+
+	duplicates := make(map[string][]indexDefinition)
+	for _, idx := range indexes {
+		if idx.valid {
+			duplicates[idx.key()] = append(duplicates[idx.key()], idx)
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(duplicates)) {
+		same := duplicates[key]
+		if len(same) < 2 {
+			continue
+		}
+		// keep the constraint backing index, else the most used one
+		keep := slices.MaxFunc(same, func(a, b indexDefinition) int {
+			if a.droppable() != b.droppable() {
+				if a.droppable() {
+					return -1
+				}
+				return 1
+			}
+			return cmp.Or(cmp.Compare(a.scans, b.scans), strings.Compare(b.name, a.name))
+		})
+		for _, idx := range same {
+			if idx.oid == keep.oid || !idx.droppable() {
+				continue
+			}
+			recos = append(recos, indexRecommendation(epochNs, dropIndexRecoTopic, idx, "DROP INDEX "+idx.name+";",
+				fmt.Sprintf("duplicate of %s", keep.name)))
+			reported[idx.oid] = true
+		}
+	}
+
+	for _, idx := range indexes {
+		if reported[idx.oid] || !idx.valid || !idx.droppable() {
+			continue
+		}
+		for _, other := range indexes {
+			if other.valid && !reported[other.oid] && idx.coveredBy(other) {
+				recos = append(recos, indexRecommendation(epochNs, dropIndexRecoTopic, idx, "DROP INDEX "+idx.name+";",
+					fmt.Sprintf("overlaps with %s covering the same leading columns", other.name)))
+				reported[idx.oid] = true
+				break
+			}
+		}
+	}
+
+	for _, idx := range indexes {
+		if reported[idx.oid] || !idx.valid || !idx.droppable() || idx.unusedSince.IsZero() {
+			continue
+		}
+		if unused := now.Sub(idx.unusedSince); unused >= unusedAge {
+			recos = append(recos, indexRecommendation(epochNs, dropIndexRecoTopic, idx, "DROP INDEX "+idx.name+";",
+				fmt.Sprintf("not scanned for %d days, make sure to also check pg_stat_user_indexes.idx_scan on the replicas "+
+					"if they are used for queries", int(unused.Hours()/24))))
+		}
+	}
+	return
+}
+
+// GetIndexRecommendations reads the index definitions and usage of the DB and returns the recommendations of
+// the collector-side analysis, see analyzeIndexes. Unused indexes are only reported once they were observed
+// without scans for unusedAge, i.e. the usage history is kept in memory since the pgwatch start
+func GetIndexRecommendations(ctx context.Context, dbUnique string, vme MonitoredDatabaseSettings, unusedAge time.Duration) (metrics.Measurements, error) {
+	data, err := DBExecReadByDbUniqueName(ctx, dbUnique, sqlIndexDefinitions)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	indexes := make([]indexDefinition, 0, len(data))
+	for _, row := range data {
+		indexes = append(indexes, newIndexDefinition(row))
+	}
+	if !vme.IsInRecovery {
+		trackIndexUsage(dbUnique, indexes, now)
+	}
+	recos := analyzeIndexes(indexes, now, unusedAge, vme.Version)
+	log.GetLogger(ctx).WithField("source", dbUnique).Debugf("%d index recommendations for %d indexes", len(recos), len(indexes))
+	return recos, nil
+}
@@ -0,0 +1,101 @@
+package reaper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func btreeIndex(oid int64, name string, columns ...string) indexDefinition {
+	opclasses := make([]string, len(columns))
+	for i := range opclasses {
+		opclasses[i] = "1978"
+	}
+	return indexDefinition{oid: oid, name: name, table: "public.t", method: "btree", columns: columns, opclasses: opclasses,
+		valid: true, size: oid * 1000}
+}
+
+func TestAnalyzeIndexes(t *testing.T) {
+	now := time.Now()
+	pk := btreeIndex(1, "public.t_pkey", "1")
+	pk.unique, pk.constraint = true, true
+	dupOfPk := btreeIndex(2, "public.t_id_idx", "1")
+	ab := btreeIndex(3, "public.t_a_b_idx", "2", "3")
+	a := btreeIndex(4, "public.t_a_idx", "2")
+	invalid := btreeIndex(5, "public.t_c_idx", "4")
+	invalid.valid = false
+	unused := btreeIndex(6, "public.t_d_idx", "5")
+	unused.unusedSince = now.Add(-40 * 24 * time.Hour)
+	used := btreeIndex(7, "public.t_e_idx", "6")
+	used.unusedSince = now.Add(-time.Hour)
+	gin := btreeIndex(8, "public.t_f_idx", "2")
+	gin.method = "gin"
+
+	recos := analyzeIndexes([]indexDefinition{pk, dupOfPk, ab, a, invalid, unused, used, gin}, now, 30*24*time.Hour, 160000)
+	byObject := make(map[string]string)
+	for _, r := range recos {
+		byObject[r["tag_object_name"].(string)] = r["tag_reco_topic"].(string) + ": " + r["extra_info"].(string)
+	}
+	assert.Len(t, recos, 4)
+	assert.Equal(t, "drop_index: duplicate of public.t_pkey", byObject["public.t_id_idx"], "the constraint index is kept")
+	assert.Equal(t, "drop_index: overlaps with public.t_a_b_idx covering the same leading columns", byObject["public.t_a_idx"])
+	assert.Contains(t, byObject["public.t_c_idx"], "rebuild_index: invalid index")
+	assert.Contains(t, byObject["public.t_d_idx"], "drop_index: not scanned for 40 days")
+	for _, r := range recos {
+		if r["tag_object_name"] == "public.t_c_idx" {
+			assert.Equal(t, "REINDEX INDEX CONCURRENTLY public.t_c_idx;", r["recommendation"])
+			assert.Equal(t, int64(5000), r["estimated_savings_b"])
+		}
+	}
+
+	dup1, dup2 := btreeIndex(1, "public.dup1", "1"), btreeIndex(2, "public.dup2", "1")
+	dup1.scans = 10
+	recos = analyzeIndexes([]indexDefinition{dup1, dup2}, now, time.Hour, 110000)
+	require.Len(t, recos, 1)
+	assert.Equal(t, "public.dup2", recos[0]["tag_object_name"], "the most used duplicate is kept")
+}
+
+func TestTrackIndexUsage(t *testing.T) {
+	defer forgetRemovedSources(nil)
+	start := time.Now().Add(-48 * time.Hour)
+	indexes := []indexDefinition{{oid: 1, scans: 5}, {oid: 2, scans: 0}}
+	trackIndexUsage("db1", indexes, start)
+	assert.Equal(t, start, indexes[0].unusedSince)
+
+	now := start.Add(24 * time.Hour)
+	indexes = []indexDefinition{{oid: 1, scans: 6}, {oid: 2, scans: 0}, {oid: 3}}
+	trackIndexUsage("db1", indexes, now)
+	assert.Equal(t, now, indexes[0].unusedSince, "scanned since")
+	assert.Equal(t, start, indexes[1].unusedSince, "not scanned since")
+	assert.Equal(t, now, indexes[2].unusedSince, "new index")
+
+	forgetRemovedSources(nil)
+	assert.Empty(t, indexUsages)
+}
+
+func TestGetIndexRecommendations(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{{Source: sources.Source{Name: "db1", Kind: sources.SourcePostgres}, Conn: conn}})
+	defer UpdateMonitoredDBCache(nil)
+
+	cols := []string{"oid", "index_name", "table_name", "access_method", "columns", "opclasses", "expressions", "predicate",
+		"is_unique", "is_valid", "is_constraint", "size_b", "idx_scan"}
+	conn.ExpectBegin()
+	conn.ExpectExec("SET LOCAL").WillReturnResult(pgxmock.NewResult("SET", 0))
+	conn.ExpectQuery("from\\s+pg_index").WillReturnRows(pgxmock.NewRows(cols).
+		AddRow(int64(1), "public.a1", "public.t", "btree", "1 2", "1978 1978", "", "", false, true, false, int64(100), int64(0)).
+		AddRow(int64(2), "public.a2", "public.t", "btree", "1 2", "1978 1978", "", "", false, true, false, int64(100), int64(3)))
+	conn.ExpectCommit()
+	recos, err := GetIndexRecommendations(context.Background(), "db1", MonitoredDatabaseSettings{Version: 160000}, time.Hour)
+	assert.NoError(t, err)
+	require.Len(t, recos, 1)
+	assert.Equal(t, "DROP INDEX public.a1;", recos[0]["recommendation"])
+	assert.Contains(t, indexUsages, "db1", "primary usage is tracked")
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
@@ -0,0 +1,26 @@
+package reaper
+
+import (
+	"context"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/ingest"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+// cachedMetricDef returns the definition of a metric from the global cache
+func cachedMetricDef(name string) (metrics.Metric, bool) {
+	metricDefMapLock.RLock()
+	defer metricDefMapLock.RUnlock()
+	m, ok := metricDefinitionMap.MetricDefs[name]
+	return m, ok
+}
+
+// ServeIngest accepts the measurements pushed by remote agents over gRPC and publishes them on the bus
+// like the locally gathered ones, until the context is cancelled
+func (r *Reaper) ServeIngest(ctx context.Context) {
+	s := ingest.NewServer(r.measurementCh, cachedMetricDef, r.opts.Ingest.Token)
+	if err := s.Serve(ctx, r.opts.Ingest.Listen); err != nil {
+		log.GetLogger(ctx).WithError(err).Error("could not serve the ingest endpoint")
+	}
+}
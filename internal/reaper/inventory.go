@@ -0,0 +1,149 @@
+package reaper
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/jackc/pgx/v5"
+)
+
+// inventory export formats
+const (
+	InventoryFormatJSON = "json"
+	InventoryFormatCSV  = "csv"
+)
+
+const inventoryWebhookTimeout = 30 * time.Second
+
+var lastInventoryExport time.Time
+
+// InventoryItem describes a monitored database for the external asset management systems
+type InventoryItem struct {
+	Name       string            `json:"name"`
+	Group      string            `json:"group"`
+	Kind       sources.Kind      `json:"kind"`
+	Host       string            `json:"host"`
+	Port       uint16            `json:"port"`
+	DBName     string            `json:"dbname"`
+	Version    string            `json:"version"`
+	InRecovery bool              `json:"in_recovery"`
+	SizeBytes  int64             `json:"size_bytes"`
+	Extensions map[string]int    `json:"extensions"`
+	Tags       map[string]string `json:"tags"`
+}
+
+// GetInventory returns the inventory of the monitored databases with the versions, sizes
+// and extensions as of their last check
+func GetInventory(mdbs sources.MonitoredDatabases) []InventoryItem {
+	items := make([]InventoryItem, 0, len(mdbs))
+	for _, md := range mdbs {
+		item := InventoryItem{
+			Name:  md.Name,
+			Group: md.Group,
+			Kind:  md.Kind,
+			Tags:  md.CustomTags,
+		}
+		if c, err := pgx.ParseConfig(md.ConnStr); err == nil {
+			item.Host, item.Port, item.DBName = c.Host, c.Port, c.Database
+		}
+		MonitoredDatabasesSettingsLock.RLock()
+		if ver, ok := MonitoredDatabasesSettings[md.Name]; ok {
+			item.Version = ver.VersionStr
+			item.InRecovery = ver.IsInRecovery
+			item.SizeBytes = ver.ApproxDBSizeB
+			item.Extensions = ver.Extensions
+			if ver.RealDbname > "" {
+				item.DBName = ver.RealDbname
+			}
+		}
+		MonitoredDatabasesSettingsLock.RUnlock()
+		items = append(items, item)
+	}
+	return items
+}
+
+// joinSorted formats the map as "k1=v1;k2=v2" for a CSV column
+func joinSorted[V any](m map[string]V) string {
+	pairs := make([]string, 0, len(m))
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", k, m[k]))
+	}
+	return strings.Join(pairs, ";")
+}
+
+// MarshalInventory formats the inventory as JSON or CSV
+func MarshalInventory(items []InventoryItem, format string) ([]byte, error) {
+	switch format {
+	case InventoryFormatJSON:
+		return json.Marshal(items)
+	case InventoryFormatCSV:
+		var b bytes.Buffer
+		w := csv.NewWriter(&b)
+		_ = w.Write([]string{"name", "group", "kind", "host", "port", "dbname", "version", "in_recovery", "size_bytes", "extensions", "tags"})
+		for _, i := range items {
+			_ = w.Write([]string{i.Name, i.Group, string(i.Kind), i.Host, strconv.Itoa(int(i.Port)), i.DBName, i.Version,
+				strconv.FormatBool(i.InRecovery), strconv.FormatInt(i.SizeBytes, 10), joinSorted(i.Extensions), joinSorted(i.Tags)})
+		}
+		w.Flush()
+		return b.Bytes(), w.Error()
+	}
+	return nil, fmt.Errorf("unknown inventory format %s", format)
+}
+
+// ExportInventory writes the inventory of the monitored databases to the --inventory-export file,
+// or posts it if a webhook URL is given
+func ExportInventory(ctx context.Context, opts sources.CmdOpts, mdbs sources.MonitoredDatabases) error {
+	data, err := MarshalInventory(GetInventory(mdbs), opts.InventoryExportFormat)
+	if err != nil {
+		return err
+	}
+	target := opts.InventoryExport
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		tmp := target + ".tmp"
+		if err = os.WriteFile(tmp, data, 0644); err != nil {
+			return err
+		}
+		return os.Rename(tmp, target)
+	}
+	ctx, cancel := context.WithTimeout(ctx, inventoryWebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", map[string]string{InventoryFormatJSON: "application/json", InventoryFormatCSV: "text/csv"}[opts.InventoryExportFormat])
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("inventory webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// syncInventory exports the inventory once per --inventory-export-interval if enabled
+func syncInventory(ctx context.Context, opts sources.CmdOpts, mdbs sources.MonitoredDatabases) {
+	if opts.InventoryExport == "" || time.Since(lastInventoryExport) < opts.InventoryExportInterval {
+		return
+	}
+	lastInventoryExport = time.Now()
+	go func() {
+		if err := ExportInventory(ctx, opts, mdbs); err != nil {
+			log.GetLogger(ctx).WithError(err).Error("could not export inventory")
+		}
+	}()
+}
@@ -0,0 +1,34 @@
+package reaper
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+)
+
+// configHash returns the hash of the sources and metric definitions the reaper works with, changing with every
+// applied configuration change incl. the continuous discovery ones. Only the hash is stored, so the connection
+// strings are not leaked
+func configHash(mdbs sources.MonitoredDatabases) string {
+	srcs := make([]sources.Source, 0, len(mdbs))
+	for _, md := range mdbs {
+		srcs = append(srcs, md.Source)
+	}
+	slices.SortFunc(srcs, func(a, b sources.Source) int { return cmp.Compare(a.Name, b.Name) })
+	metricDefMapLock.RLock()
+	b, err := json.Marshal(struct {
+		Sources []sources.Source
+		Metrics *metrics.Metrics
+	}{srcs, metricDefinitionMap})
+	metricDefMapLock.RUnlock()
+	if err != nil {
+		return ""
+	}
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,21 @@
+package reaper
+
+import (
+	"testing"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigHash(t *testing.T) {
+	db1 := &sources.MonitoredDatabase{Source: sources.Source{Name: "db1", Metrics: map[string]float64{"db_stats": 60}}}
+	db2 := &sources.MonitoredDatabase{Source: sources.Source{Name: "db2"}}
+
+	h := configHash(sources.MonitoredDatabases{db1, db2})
+	assert.Len(t, h, 64)
+	assert.Equal(t, h, configHash(sources.MonitoredDatabases{db2, db1}), "order of the sources does not matter")
+
+	db1.Metrics["db_stats"] = 120
+	assert.NotEqual(t, h, configHash(sources.MonitoredDatabases{db1, db2}))
+	assert.NotEqual(t, h, configHash(sources.MonitoredDatabases{db1}))
+}
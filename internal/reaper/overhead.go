@@ -0,0 +1,160 @@
+package reaper
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/db"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/tracelog"
+)
+
+const (
+	monitoringOverheadMetricName = "monitoring_overhead" // internal metric with the footprint pgwatch leaves on every monitored DB
+	monitoringOverheadInterval   = time.Minute
+)
+
+// SourceOverhead accumulates the load generated by pgwatch on a single monitored DB
+type SourceOverhead struct {
+	Queries      int64
+	QueryTime    time.Duration
+	BytesFetched int64
+	FetchErrors  map[FetchErrorKind]int64
+}
+
+var monitoringOverhead = make(map[string]SourceOverhead) // cumulative, since pgwatch start
+var monitoringOverheadReported = make(map[string]SourceOverhead)
+var monitoringOverheadLock sync.Mutex
+
+// RecordQueryOverhead registers a single query executed on the monitored DB
+func RecordQueryOverhead(dbUnique string, duration time.Duration) {
+	monitoringOverheadLock.Lock()
+	o := monitoringOverhead[dbUnique]
+	o.Queries++
+	o.QueryTime += duration
+	monitoringOverhead[dbUnique] = o
+	monitoringOverheadLock.Unlock()
+}
+
+// RecordBytesFetched registers the size of the metric data fetched from the monitored DB
+func RecordBytesFetched(dbUnique string, bytesFetched int64) {
+	monitoringOverheadLock.Lock()
+	o := monitoringOverhead[dbUnique]
+	o.BytesFetched += bytesFetched
+	monitoringOverhead[dbUnique] = o
+	monitoringOverheadLock.Unlock()
+}
+
+type queryStartKey struct{}
+
+type queryStart struct {
+	time time.Time
+	sql  string
+}
+
+// overheadTracer records every query executed on the monitored DB connection pool,
+// including the ones not issued by metric fetches, and passes the events on to the default query logger
+type overheadTracer struct {
+	*tracelog.TraceLog
+	dbUnique string
+}
+
+func (t *overheadTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	ctx = t.TraceLog.TraceQueryStart(ctx, conn, data)
+	return context.WithValue(ctx, queryStartKey{}, queryStart{time: time.Now(), sql: data.SQL})
+}
+
+func (t *overheadTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	if start, ok := ctx.Value(queryStartKey{}).(queryStart); ok {
+		duration := time.Since(start.time)
+		RecordQueryOverhead(t.dbUnique, duration)
+		AuditQuery(ctx, t.dbUnique, start.sql, duration, data)
+	}
+	t.TraceLog.TraceQueryEnd(ctx, conn, data)
+}
+
+// WithOverheadTracer returns a pool config callback recording the overhead of all queries on the monitored DB,
+// writing them to the audit log if enabled, and the connection establishment latency
+func WithOverheadTracer(dbUnique string) db.ConnConfigCallback {
+	return func(conf *pgxpool.Config) error {
+		if tl, ok := conf.ConnConfig.Tracer.(*tracelog.TraceLog); ok {
+			conf.ConnConfig.Tracer = &overheadTracer{TraceLog: tl, dbUnique: dbUnique}
+			withConnectTiming(conf)
+		}
+		return nil
+	}
+}
+
+// RecordFetchError registers a failed metric fetch on the monitored DB
+func RecordFetchError(dbUnique string, kind FetchErrorKind) {
+	monitoringOverheadLock.Lock()
+	o := monitoringOverhead[dbUnique]
+	if o.FetchErrors == nil {
+		o.FetchErrors = make(map[FetchErrorKind]int64)
+	}
+	o.FetchErrors[kind]++
+	monitoringOverhead[dbUnique] = o
+	monitoringOverheadLock.Unlock()
+}
+
+// GetMonitoringOverhead returns the accumulated overhead for the given monitored DB
+func GetMonitoringOverhead(dbUnique string) SourceOverhead {
+	monitoringOverheadLock.Lock()
+	defer monitoringOverheadLock.Unlock()
+	o := monitoringOverhead[dbUnique]
+	o.FetchErrors = maps.Clone(o.FetchErrors)
+	return o
+}
+
+// getConnectionsHeld returns the number of open connections in the monitored DB pool
+func getConnectionsHeld(md *sources.MonitoredDatabase) int32 {
+	if md.Conn == nil {
+		return 0
+	}
+	return md.Conn.Stat().TotalConns()
+}
+
+// MonitoringOverheadMeasurements returns the overhead measurements for all monitored DBs
+// with the query rate calculated since the previous call
+func MonitoringOverheadMeasurements(sinceLast time.Duration) []metrics.MeasurementEnvelope {
+	monitoredDbCacheLock.RLock()
+	defer monitoredDbCacheLock.RUnlock()
+	monitoringOverheadLock.Lock()
+	defer monitoringOverheadLock.Unlock()
+
+	now := time.Now().UnixNano()
+	msgs := make([]metrics.MeasurementEnvelope, 0, len(monitoredDbCache))
+	for dbUnique, md := range monitoredDbCache {
+		total := monitoringOverhead[dbUnique]
+		prev := monitoringOverheadReported[dbUnique]
+		monitoringOverheadReported[dbUnique] = total
+		var queriesPerMinute float64
+		if sinceLast > 0 {
+			queriesPerMinute = float64(total.Queries-prev.Queries) / sinceLast.Minutes()
+		}
+		row := metrics.Measurement{
+			epochColumnName:      now,
+			"queries_total":      total.Queries,
+			"queries_per_minute": queriesPerMinute,
+			"query_time_total_s": total.QueryTime.Seconds(),
+			"bytes_fetched":      total.BytesFetched,
+			"connections_held":   getConnectionsHeld(md),
+		}
+		for kind, count := range total.FetchErrors {
+			row["fetch_errors_"+string(kind)] = count
+		}
+		msgs = append(msgs, metrics.MeasurementEnvelope{
+			DBName:     dbUnique,
+			SourceType: string(md.Kind),
+			MetricName: monitoringOverheadMetricName,
+			CustomTags: md.CustomTags,
+			Data:       metrics.Measurements{row},
+		})
+	}
+	return msgs
+}
@@ -0,0 +1,72 @@
+package reaper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/tracelog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonitoringOverhead(t *testing.T) {
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{
+		{Source: sources.Source{Name: "overhead_db", Kind: sources.SourcePostgres, CustomTags: map[string]string{"env": "test"}}},
+	})
+	defer UpdateMonitoredDBCache(nil)
+
+	RecordQueryOverhead("overhead_db", time.Second)
+	RecordQueryOverhead("overhead_db", 2*time.Second)
+	RecordBytesFetched("overhead_db", 100)
+	RecordBytesFetched("overhead_db", 50)
+	o := GetMonitoringOverhead("overhead_db")
+	assert.Equal(t, int64(2), o.Queries)
+	assert.Equal(t, 3*time.Second, o.QueryTime)
+	assert.Equal(t, int64(150), o.BytesFetched)
+
+	msgs := MonitoringOverheadMeasurements(time.Minute)
+	assert.Len(t, msgs, 1)
+	assert.Equal(t, monitoringOverheadMetricName, msgs[0].MetricName)
+	assert.Equal(t, "test", msgs[0].CustomTags["env"])
+	row := msgs[0].Data[0]
+	assert.Equal(t, int64(2), row["queries_total"])
+	assert.Equal(t, 2.0, row["queries_per_minute"])
+	assert.Equal(t, 3.0, row["query_time_total_s"])
+	assert.Equal(t, int64(150), row["bytes_fetched"])
+	assert.Equal(t, int32(0), row["connections_held"])
+	assert.NotContains(t, row, "fetch_errors_timeout")
+
+	RecordQueryOverhead("overhead_db", time.Second)
+	RecordFetchError("overhead_db", FetchErrorTimeout)
+	RecordFetchError("overhead_db", FetchErrorTimeout)
+	assert.Equal(t, int64(2), GetMonitoringOverhead("overhead_db").FetchErrors[FetchErrorTimeout])
+	msgs = MonitoringOverheadMeasurements(30 * time.Second)
+	row = msgs[0].Data[0]
+	assert.Equal(t, int64(3), row["queries_total"], "totals should be cumulative")
+	assert.Equal(t, 2.0, row["queries_per_minute"], "rate should only count queries since the previous report")
+	assert.Equal(t, int64(2), row["fetch_errors_timeout"])
+}
+
+func TestOverheadTracer(t *testing.T) {
+	conf, err := pgxpool.ParseConfig("postgres://foo@localhost/bar")
+	assert.NoError(t, err)
+	conf.ConnConfig.Tracer = &tracelog.TraceLog{Logger: log.NewPgxLogger(log.FallbackLogger), LogLevel: tracelog.LogLevelNone}
+	assert.NoError(t, WithOverheadTracer("traced_db")(conf))
+	tracer, ok := conf.ConnConfig.Tracer.(*overheadTracer)
+	assert.True(t, ok)
+	defer func() {
+		monitoringOverheadLock.Lock()
+		delete(monitoringOverhead, "traced_db")
+		monitoringOverheadLock.Unlock()
+	}()
+
+	for range 3 { // e.g. QueryRow, Exec and Begin are all traced the same way
+		ctx := tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "select 1"})
+		tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+	}
+	assert.Equal(t, int64(3), GetMonitoringOverhead("traced_db").Queries)
+}
@@ -0,0 +1,105 @@
+package reaper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+)
+
+// The load signals of a source are reused by its bulk metrics for overloadCheckInterval,
+// an overloaded server not answering the cheap checks quickly is not delaying the gatherers either
+const (
+	overloadCheckInterval = 15 * time.Second
+	overloadCheckTimeout  = 5 * time.Second
+)
+
+var errServerOverloaded = errors.New("server overloaded, bulk metric skipped")
+
+const (
+	sqlActiveBackends = `select /* pgwatch_generated */ count(*)::int8 as active_backends from pg_stat_activity where state = 'active' and pid != pg_backend_pid()`
+	sqlLoadAverage    = `select /* pgwatch_generated */ load_1min from get_load_average()`
+)
+
+type overloadCheck struct {
+	checked time.Time
+	reason  string // empty if not overloaded
+}
+
+var overloadChecks = make(map[string]overloadCheck) // [db1]=last check
+var overloadChecksLock sync.Mutex
+
+// checkOverload returns why the server is considered overloaded, empty if it's not. The load average is
+// only checked if the get_load_average() helper is available
+func checkOverload(ctx context.Context, dbUnique string, guard sources.OverloadGuard) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, overloadCheckTimeout)
+	defer cancel()
+	if guard.MaxActiveBackends > 0 {
+		data, err := DBExecReadByDbUniqueName(ctx, dbUnique, sqlActiveBackends)
+		if err != nil {
+			return "", err
+		}
+		if active, ok := firstValue(data, "active_backends").(int64); ok && active > int64(guard.MaxActiveBackends) {
+			return fmt.Sprintf("%d active backends > %d", active, guard.MaxActiveBackends), nil
+		}
+	}
+	if guard.MaxLoadAvg > 0 {
+		data, err := DBExecReadByDbUniqueName(ctx, dbUnique, sqlLoadAverage)
+		if err != nil {
+			log.GetLogger(ctx).WithError(err).Debug("load average not available for the overload guard")
+			return "", nil
+		}
+		if load, ok := firstValue(data, "load_1min").(float64); ok && load > guard.MaxLoadAvg {
+			return fmt.Sprintf("load average %.2f > %.2f", load, guard.MaxLoadAvg), nil
+		}
+	}
+	return "", nil
+}
+
+// firstValue returns the column value of the first row, nil if there are no rows
+func firstValue(data metrics.Measurements, column string) any {
+	if len(data) == 0 {
+		return nil
+	}
+	return data[0][column]
+}
+
+// guardOverload returns errServerOverloaded if the bulk metric should be skipped this cycle
+// as the monitored server is above the overload guard thresholds of the source
+func guardOverload(ctx context.Context, dbUnique string, now time.Time) error {
+	md, err := GetMonitoredDatabaseByUniqueName(dbUnique)
+	if err != nil || !md.HostConfig.OverloadGuard.Enabled() {
+		return nil
+	}
+	overloadChecksLock.Lock()
+	last, ok := overloadChecks[dbUnique]
+	overloadChecksLock.Unlock()
+	if !ok || now.Sub(last.checked) >= overloadCheckInterval {
+		// not checked under the lock, a hanging server must not delay the guards of the other sources
+		reason, err := checkOverload(ctx, dbUnique, md.HostConfig.OverloadGuard)
+		if err != nil {
+			// the guard must not stop the monitoring, the metric fetch will report the problem anyway
+			log.GetLogger(ctx).WithError(err).Debug("could not check the overload guard signals")
+		}
+		l := log.GetLogger(ctx).WithField("source", dbUnique)
+		switch {
+		case reason > "" && last.reason == "":
+			l.WithField("reason", reason).Warning("server overloaded, skipping bulk metrics")
+		case reason == "" && last.reason > "":
+			l.Info("server not overloaded anymore, resuming bulk metrics")
+		}
+		last = overloadCheck{checked: now, reason: reason}
+		overloadChecksLock.Lock()
+		overloadChecks[dbUnique] = last
+		overloadChecksLock.Unlock()
+	}
+	if last.reason > "" {
+		return fmt.Errorf("%w: %s", errServerOverloaded, last.reason)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package reaper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func expectGuardQuery(conn pgxmock.PgxPoolIface, query string, rows *pgxmock.Rows) {
+	conn.ExpectBegin()
+	conn.ExpectExec("SET LOCAL").WillReturnResult(pgxmock.NewResult("SET", 0))
+	conn.ExpectQuery(query).WillReturnRows(rows)
+	conn.ExpectCommit()
+}
+
+func TestGuardOverload(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer conn.Close()
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{
+		{Source: sources.Source{Name: "unguarded", Kind: sources.SourcePostgres}, Conn: conn},
+		{Source: sources.Source{Name: "guarded", Kind: sources.SourcePostgres, HostConfig: sources.HostConfigAttrs{
+			OverloadGuard: sources.OverloadGuard{MaxActiveBackends: 10, MaxLoadAvg: 4}}}, Conn: conn},
+	})
+	defer UpdateMonitoredDBCache(nil)
+	defer func() {
+		overloadChecksLock.Lock()
+		delete(overloadChecks, "guarded")
+		overloadChecksLock.Unlock()
+	}()
+	ctx := context.Background()
+	now := time.Now()
+
+	assert.NoError(t, guardOverload(ctx, "unguarded", now), "no queries without thresholds")
+
+	expectGuardQuery(conn, "from pg_stat_activity", pgxmock.NewRows([]string{"active_backends"}).AddRow(int64(25)))
+	err = guardOverload(ctx, "guarded", now)
+	assert.ErrorIs(t, err, errServerOverloaded)
+	assert.ErrorContains(t, err, "25 active backends > 10")
+	assert.Equal(t, FetchErrorOverload, ClassifyFetchError(err))
+	assert.Equal(t, FetchErrorActionSkip, ClassifyFetchError(err).Action(false))
+	assert.ErrorIs(t, guardOverload(ctx, "guarded", now.Add(time.Second)), errServerOverloaded, "cached within the check interval")
+	assert.NoError(t, conn.ExpectationsWereMet())
+
+	expectGuardQuery(conn, "from pg_stat_activity", pgxmock.NewRows([]string{"active_backends"}).AddRow(int64(3)))
+	expectGuardQuery(conn, "from get_load_average", pgxmock.NewRows([]string{"load_1min"}).AddRow(6.5))
+	assert.ErrorContains(t, guardOverload(ctx, "guarded", now.Add(overloadCheckInterval)), "load average 6.50 > 4.00")
+	assert.NoError(t, conn.ExpectationsWereMet())
+
+	expectGuardQuery(conn, "from pg_stat_activity", pgxmock.NewRows([]string{"active_backends"}).AddRow(int64(3)))
+	conn.ExpectBegin()
+	conn.ExpectExec("SET LOCAL").WillReturnResult(pgxmock.NewResult("SET", 0))
+	conn.ExpectQuery("from get_load_average").WillReturnError(assert.AnError)
+	conn.ExpectCommit()
+	assert.NoError(t, guardOverload(ctx, "guarded", now.Add(2*overloadCheckInterval)), "missing load average helper is ignored")
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
@@ -0,0 +1,55 @@
+package reaper
+
+import (
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+)
+
+const (
+	patroniClusterMembersMetricName = "patroni_cluster_members" // internal metric with the Patroni cluster membership seen at discovery time
+	patroniClusterMembersInterval   = time.Minute
+)
+
+var patroniMembersReported = make(map[string]time.Time) // last reported discovery per source
+var patroniMembersReportedLock sync.Mutex
+
+// PatroniClusterMembersMeasurements returns the Patroni cluster members of every source
+// seen on the discovery not reported yet
+func PatroniClusterMembersMeasurements(membership []sources.PatroniMembership) []metrics.MeasurementEnvelope {
+	patroniMembersReportedLock.Lock()
+	defer patroniMembersReportedLock.Unlock()
+	msgs := make([]metrics.MeasurementEnvelope, 0)
+	current := make(map[string]bool, len(membership))
+	for _, pm := range membership {
+		current[pm.Source.Name] = true
+		if !pm.ResolvedAt.After(patroniMembersReported[pm.Source.Name]) {
+			continue
+		}
+		patroniMembersReported[pm.Source.Name] = pm.ResolvedAt
+		data := make(metrics.Measurements, 0, len(pm.Members))
+		for _, m := range pm.Members {
+			data = append(data, metrics.Measurement{
+				epochColumnName: pm.ResolvedAt.UnixNano(),
+				"tag_scope":     m.Scope,
+				"tag_member":    m.Name,
+				"role":          m.Role,
+			})
+		}
+		if len(data) == 0 {
+			continue
+		}
+		msgs = append(msgs, metrics.MeasurementEnvelope{
+			DBName:     pm.Source.Name,
+			SourceType: string(pm.Source.Kind),
+			MetricName: patroniClusterMembersMetricName,
+			CustomTags: pm.Source.CustomTags,
+			Data:       data,
+		})
+	}
+	maps.DeleteFunc(patroniMembersReported, func(name string, _ time.Time) bool { return !current[name] })
+	return msgs
+}
@@ -0,0 +1,36 @@
+package reaper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatroniClusterMembersMeasurements(t *testing.T) {
+	pm := sources.PatroniMembership{
+		Source: sources.Source{Name: "batman", Kind: sources.SourcePatroniNamespace, CustomTags: map[string]string{"env": "prod"}},
+		Members: []sources.PatroniClusterMember{
+			{Scope: "batman", Name: "node1", Role: "primary"},
+			{Scope: "batman", Name: "node2", Role: "replica"},
+		},
+		ResolvedAt: time.Now(),
+	}
+	defer PatroniClusterMembersMeasurements(nil)
+
+	msgs := PatroniClusterMembersMeasurements([]sources.PatroniMembership{pm})
+	assert.Len(t, msgs, 1)
+	assert.Equal(t, patroniClusterMembersMetricName, msgs[0].MetricName)
+	assert.Equal(t, "batman", msgs[0].DBName)
+	assert.Equal(t, "prod", msgs[0].CustomTags["env"])
+	assert.Len(t, msgs[0].Data, 2)
+	assert.Equal(t, "node2", msgs[0].Data[1]["tag_member"])
+	assert.Equal(t, "replica", msgs[0].Data[1]["role"])
+	assert.Equal(t, pm.ResolvedAt.UnixNano(), msgs[0].Data[1][epochColumnName])
+
+	assert.Empty(t, PatroniClusterMembersMeasurements([]sources.PatroniMembership{pm}), "the same discovery should be reported once")
+
+	pm.ResolvedAt = pm.ResolvedAt.Add(time.Minute)
+	assert.Len(t, PatroniClusterMembersMeasurements([]sources.PatroniMembership{pm}), 1)
+}
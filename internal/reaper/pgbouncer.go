@@ -0,0 +1,41 @@
+package reaper
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/db"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// pgBouncerStartupParams are the startup parameters tracked by PgBouncer, connections with other ones are refused
+// unless they are listed in its ignore_startup_parameters
+var pgBouncerStartupParams = []string{"application_name", "client_encoding", "datestyle", "standard_conforming_strings", "timezone"}
+
+// applyPgBouncerPassthrough adapts the connection config of a Postgres source reached through PgBouncer in
+// transaction pooling mode. The server connection is switched between transactions, so no prepared statements
+// are used, and the startup parameters PgBouncer does not track are dropped
+func applyPgBouncerPassthrough(ctx context.Context, dbUnique string, conf *pgx.ConnConfig) {
+	conf.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
+	for name := range conf.RuntimeParams {
+		if !slices.Contains(pgBouncerStartupParams, strings.ToLower(name)) {
+			log.GetLogger(ctx).WithField("source", dbUnique).WithField("param", name).
+				Warning("startup parameter not supported by PgBouncer ignored")
+			delete(conf.RuntimeParams, name)
+		}
+	}
+}
+
+// WithPgBouncerPassthrough returns a pool config callback adapting the connections of a source with the
+// via_pgbouncer host config to PgBouncer, see applyPgBouncerPassthrough
+func WithPgBouncerPassthrough(ctx context.Context, dbUnique string, enabled bool) db.ConnConfigCallback {
+	return func(conf *pgxpool.Config) error {
+		if enabled {
+			applyPgBouncerPassthrough(ctx, dbUnique, conf.ConnConfig)
+		}
+		return nil
+	}
+}
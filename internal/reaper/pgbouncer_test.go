@@ -0,0 +1,48 @@
+package reaper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithPgBouncerPassthrough(t *testing.T) {
+	conf, err := pgxpool.ParseConfig("postgres://localhost:6432/db?application_name=pgwatch&search_path=public&TimeZone=UTC")
+	require.NoError(t, err)
+	require.NoError(t, WithPgBouncerPassthrough(context.Background(), "bouncer_src", false)(conf))
+	assert.Equal(t, pgx.QueryExecModeCacheStatement, conf.ConnConfig.DefaultQueryExecMode, "disabled by default")
+	assert.Contains(t, conf.ConnConfig.RuntimeParams, "search_path")
+
+	require.NoError(t, WithPgBouncerPassthrough(context.Background(), "bouncer_src", true)(conf))
+	assert.Equal(t, pgx.QueryExecModeSimpleProtocol, conf.ConnConfig.DefaultQueryExecMode, "no prepared statements")
+	assert.Equal(t, map[string]string{"application_name": "pgwatch", "TimeZone": "UTC"}, conf.ConnConfig.RuntimeParams,
+		"only the parameters tracked by PgBouncer are kept")
+}
+
+func TestDBExecReadViaPgBouncer(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	md := &sources.MonitoredDatabase{Source: sources.Source{Name: "bouncer_src", Kind: sources.SourcePostgres}, Conn: conn}
+	md.HostConfig.ViaPgBouncer = true
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{md})
+	defer UpdateMonitoredDBCache(nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	conn.ExpectBegin()
+	conn.ExpectExec("^SET LOCAL lock_timeout TO '100ms'$").WillReturnResult(pgxmock.NewResult("SET", 0))
+	conn.ExpectExec("^SET LOCAL statement_timeout TO '\\d+ms'$").WillReturnResult(pgxmock.NewResult("SET", 0))
+	conn.ExpectQuery("select 1").WillReturnRows(pgxmock.NewRows([]string{"x"}).AddRow(1))
+	conn.ExpectCommit()
+
+	_, err = DBExecReadByDbUniqueName(ctx, "bouncer_src", "select 1 as x")
+	assert.NoError(t, err)
+	assert.NoError(t, conn.ExpectationsWereMet(), "one statement per query")
+}
@@ -0,0 +1,36 @@
+package reaper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sinks"
+)
+
+// Probe fetches the metrics of a monitored source on demand for the /probe endpoint of the Prometheus sink.
+// The measurements are returned only, not stored, and the errors of the failed metrics are joined
+func (r *Reaper) Probe(ctx context.Context, dbUnique string, metricNames []string) (msgs []metrics.MeasurementEnvelope, err error) {
+	md, e := GetMonitoredDatabaseByUniqueName(dbUnique)
+	if e != nil {
+		return nil, fmt.Errorf("%w: %s", sinks.ErrProbeTargetNotFound, dbUnique)
+	}
+	for _, metricName := range metricNames {
+		mfm := MetricFetchConfig{
+			DBUniqueName:     md.Name,
+			DBUniqueNameOrig: md.GetDatabaseName(),
+			MetricName:       metricName,
+			Source:           md.Kind,
+			Interval:         time.Second * time.Duration(md.Metrics[metricName]),
+		}
+		envelopes, e := FetchMetrics(WithQueryOrigin(ctx, "probe", metricName), mfm, make(map[string]map[string]string), nil, contextPrometheusScrape, r.opts)
+		if e != nil {
+			err = errors.Join(err, fmt.Errorf("%s: %w", metricName, e))
+			continue
+		}
+		msgs = append(msgs, envelopes...)
+	}
+	return
+}
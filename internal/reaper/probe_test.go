@@ -0,0 +1,17 @@
+package reaper
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/cmdopts"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sinks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProbeUnknownTarget(t *testing.T) {
+	r := NewReaper(&cmdopts.Options{}, nil, nil)
+	msgs, err := r.Probe(context.Background(), "probe_unknown", []string{"instance_up"})
+	assert.ErrorIs(t, err, sinks.ErrProbeTargetNotFound)
+	assert.Empty(t, msgs)
+}
@@ -0,0 +1,53 @@
+package reaper
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/cmdopts"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+)
+
+var hostname = sync.OnceValue(func() string {
+	h, _ := os.Hostname()
+	return h
+})
+
+// GetCollectorID returns the identifier of this pgwatch instance, hostname by default
+func GetCollectorID(opts *cmdopts.Options) string {
+	if opts.Metrics.CollectorID > "" {
+		return opts.Metrics.CollectorID
+	}
+	return hostname()
+}
+
+// only these characters are kept in the query comment, so it can't be closed early
+var regexUnsafeTagChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// TagMetricSQL prepends a comment with the metric name and collector id to the SQL,
+// so monitoring queries can be identified server-side, e.g. in pg_stat_statements
+func TagMetricSQL(sql, metricName, collectorID string) string {
+	sanitize := func(s string) string {
+		return regexUnsafeTagChars.ReplaceAllString(s, "_")
+	}
+	return fmt.Sprintf("/* pgwatch metric=%s collector=%s */ %s", sanitize(metricName), sanitize(collectorID), sql)
+}
+
+// LogSlowMetric logs the metric query if its execution took longer than the configured threshold
+func LogSlowMetric(ctx context.Context, msg MetricFetchConfig, sql string, duration time.Duration, rows int, opts *cmdopts.Options) {
+	threshold := opts.Metrics.SlowMetricThreshold
+	if threshold <= 0 || duration < threshold {
+		return
+	}
+	log.GetLogger(ctx).
+		WithField("source", msg.DBUniqueName).
+		WithField("metric", msg.MetricName).
+		WithField("duration", duration.Truncate(time.Millisecond)).
+		WithField("rows", rows).
+		WithField("sql", sql).
+		Warning("slow metric query")
+}
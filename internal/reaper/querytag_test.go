@@ -0,0 +1,28 @@
+package reaper
+
+import (
+	"testing"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/cmdopts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCollectorID(t *testing.T) {
+	opts := &cmdopts.Options{}
+	assert.Equal(t, hostname(), GetCollectorID(opts))
+	opts.Metrics.CollectorID = "collector1"
+	assert.Equal(t, "collector1", GetCollectorID(opts))
+}
+
+func TestTagMetricSQL(t *testing.T) {
+	assert.Equal(t, "/* pgwatch metric=db_stats collector=host1 */ select 1",
+		TagMetricSQL("select 1", "db_stats", "host1"))
+	assert.Equal(t, "/* pgwatch metric=db_stats collector=host-1.local */ select 1",
+		TagMetricSQL("select 1", "db_stats", "host-1.local"))
+	assert.Equal(t, "/* pgwatch metric=db_stats collector=evil____drop_table_x___ */ select 1",
+		TagMetricSQL("select 1", "db_stats", "evil*/; drop table x;/*"),
+		"comment terminators should be removed")
+	assert.Equal(t, "/* pgwatch metric=db_stats collector=____drop_table_x */ select 1",
+		TagMetricSQL("select 1", "db_stats", "**//drop table x"),
+		"nested terminators can't survive sanitizing")
+}
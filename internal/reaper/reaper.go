@@ -1,8 +1,10 @@
 package reaper
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"runtime/debug"
 	"slices"
 	"strings"
 	"sync"
@@ -19,6 +21,15 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+const patroniRoleCheckInterval = 5 * time.Second   // how often the DCS of Patroni sources is checked for switchovers
+const databaseListCheckInterval = 30 * time.Second // how often postgres-continuous-discovery instances are checked for new or dropped databases
+
+// the measurements queued at shutdown are written to the sinks for at most drainTimeout
+const (
+	drainTimeout      = 10 * time.Second
+	drainPollInterval = 100 * time.Millisecond
+)
+
 var monitoredDbs = make(sources.MonitoredDatabases, 0)
 var hostLastKnownStatusInRecovery = make(map[string]bool) // isInRecovery
 var metricConfig map[string]float64                       // set to host.Metrics or host.MetricsStandby (in case optional config defined and in recovery state
@@ -30,15 +41,35 @@ type Reaper struct {
 	opts                *cmdopts.Options
 	sourcesReaderWriter sources.ReaderWriter
 	metricsReaderWriter metrics.ReaderWriter
-	measurementCh       chan []metrics.MeasurementEnvelope
+	measurementCh       chan []metrics.MeasurementEnvelope // published on the bus
+	bus                 Bus
+	refreshCh           chan struct{}
+	lastMeasurements    sinks.LastMeasurementReader       // used to detect the gaps to backfill
+	measurementsWriter  atomic.Pointer[sinks.MultiWriter] // for the sinks health and the admin API calls
+	updateAdvisory      atomic.Pointer[UpdateAdvisory]    // outcome of the last --update-check
+	fetchErrors         *log.Throttler                    // collapses the fetch errors of many sources on an outage
+	throughput          *throughputRing                   // per-minute collector throughput for the stats API
 }
 
+// measurement batches queued for the sinks, fewer in the agent mode to keep the footprint small on the edge hosts
+const (
+	measurementQueueSize      = 10000
+	agentMeasurementQueueSize = 500
+)
+
 func NewReaper(opts *cmdopts.Options, sourcesReaderWriter sources.ReaderWriter, metricsReaderWriter metrics.ReaderWriter) *Reaper {
+	queueSize := measurementQueueSize
+	if opts.Mode == cmdopts.ModeAgent {
+		queueSize = agentMeasurementQueueSize
+	}
 	return &Reaper{
 		opts:                opts,
 		sourcesReaderWriter: sourcesReaderWriter,
 		metricsReaderWriter: metricsReaderWriter,
-		measurementCh:       make(chan []metrics.MeasurementEnvelope, 10000),
+		measurementCh:       make(chan []metrics.MeasurementEnvelope, queueSize),
+		refreshCh:           make(chan struct{}, 1),
+		fetchErrors:         log.NewThrottler(opts.Logging.LogThrottle),
+		throughput:          newThroughputRing(opts.StatsHistory),
 	}
 }
 
@@ -47,6 +78,81 @@ func (r *Reaper) Ready() bool {
 	return r.ready.Load()
 }
 
+// Refresh() wakes up the main loop to re-read the metric definitions and sources immediately
+func (r *Reaper) Refresh() {
+	select {
+	case r.refreshCh <- struct{}{}:
+	default: // refresh is already pending
+	}
+}
+
+// WatchPatroniRoles() requests an immediate refresh as soon as a Patroni cluster reports a role change,
+// so that presets are swapped within seconds after a switchover instead of waiting for the next refresh
+func (r *Reaper) WatchPatroniRoles(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(patroniRoleCheckInterval):
+		}
+		if changed := sources.PatroniRolesChanged(); len(changed) > 0 {
+			log.GetLogger(ctx).WithField("sources", changed).Info("Patroni role change detected, refreshing sources...")
+			r.Refresh()
+		}
+	}
+}
+
+// WatchDatabaseLists() requests an immediate refresh as soon as databases are created or dropped on a
+// postgres-continuous-discovery instance, so that they are monitored without waiting for the next refresh
+func (r *Reaper) WatchDatabaseLists(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(databaseListCheckInterval):
+		}
+		if changed := sources.DatabaseListsChanged(ctx); len(changed) > 0 {
+			log.GetLogger(ctx).WithField("sources", changed).Info("database list change detected, refreshing sources...")
+			r.Refresh()
+		}
+	}
+}
+
+// waitForRefresh() blocks until the next main loop iteration is due or a refresh is requested.
+// It returns false if the context is cancelled
+func (r *Reaper) waitForRefresh(ctx context.Context) bool {
+	select {
+	case <-time.After(time.Second * time.Duration(r.opts.Sources.Refresh)):
+	case <-r.refreshCh:
+		logger := log.GetLogger(ctx)
+		logger.Info("immediate refresh requested, re-reading metric definitions and sources...")
+		if err := LoadMetricDefs(r.metricsReaderWriter); err != nil {
+			logger.WithError(err).Error("could not refresh metric definitions")
+		}
+	case <-ctx.Done():
+		return false
+	}
+	return true
+}
+
+// drain waits at shutdown until the queued measurements are passed to the sinks, e.g. before a new collector takes
+// over on a handover. The gatherers are stopped already, the queue is checked to stay empty for a poll interval
+func (r *Reaper) drain(logger log.LoggerIface) {
+	deadline := time.Now().Add(drainTimeout)
+	for idle := 0; idle < 2; {
+		if queued := len(r.measurementCh); queued > 0 {
+			idle = 0
+			if time.Now().After(deadline) {
+				logger.WithField("batches", queued).Warning("measurements still queued at shutdown are dropped")
+				return
+			}
+		} else {
+			idle++
+		}
+		time.Sleep(drainPollInterval)
+	}
+}
+
 // Reap() starts the main monitoring loop. It is responsible for fetching metrics measurements
 // from the sources and storing them to the sinks. It also manages the lifecycle of
 // the metric gatherers. In case of a source or metric definition change, it will
@@ -67,18 +173,81 @@ func (r *Reaper) Reap(mainContext context.Context) (err error) {
 		return err
 	}
 	go SyncMetricDefs(mainContext, metricsReaderWriter)
+	go r.fetchErrors.Run(mainContext, logger)
 
-	if measurementsWriter, err = sinks.NewMultiWriter(mainContext, &opts.Sinks, metricDefinitionMap); err != nil {
+	opts.Sinks.CollectorID = GetCollectorID(opts)
+	opts.Sinks.StatsHistory = opts.StatsHistory
+	InitAuditLog(opts.Sources)
+	if err = LoadDormancyStates(opts.Sources.DormancyStateFile); err != nil {
+		logger.WithError(err).Warning("could not restore dormancy states")
+	}
+	if err = LoadDowntimes(opts.Sources.DowntimeFile); err != nil {
+		logger.WithError(err).Warning("could not restore downtimes")
+	}
+	// the sinks outlive the main context to write the measurements still queued at shutdown
+	sinksCtx, stopSinks := context.WithCancel(context.WithoutCancel(mainContext))
+	defer stopSinks()
+	if measurementsWriter, err = sinks.NewMultiWriter(sinksCtx, &opts.Sinks, metricDefinitionMap); err != nil {
 		logger.Fatal(err)
 	}
-	go measurementsWriter.WriteMeasurements(mainContext, r.measurementCh)
+	measurementsWriter.SetProbe(r.Probe)
+	go measurementsWriter.WriteMeasurements(sinksCtx, r.bus.Subscribe("sinks", 0, true).C)
+	go r.bus.Run(sinksCtx, r.measurementCh)
+	go r.throughput.Run(mainContext, &r.bus)
+	r.lastMeasurements = measurementsWriter
+	r.measurementsWriter.Store(measurementsWriter)
 
 	if monitoredDbs, err = monitoredDbs.SyncFromReader(sourcesReaderWriter); err != nil {
 		logger.Fatal("could not fetch active hosts - check config!", err)
 	}
 
+	if r.IsTestdataMode() {
+		return r.GenerateTestData(mainContext)
+	}
+
+	if err = measurementsWriter.RecordStart(opts.Version, configHash(monitoredDbs)); err != nil {
+		logger.WithError(err).Warning("could not record the collector start")
+	}
+
 	// at this stage we have all the metric definitions, the sinks and the sources configured
 	r.ready.Store(true)
+	go SyncInternalMetricToDatastore(mainContext, r.measurementCh, monitoringOverheadInterval, MonitoringOverheadMeasurements)
+	go SyncInternalMetricToDatastore(mainContext, r.measurementCh, availabilityInterval, AvailabilityMeasurements)
+	go SyncInternalMetricToDatastore(mainContext, r.measurementCh, connectLatencyInterval, ConnectLatencyMeasurements)
+	go SyncInternalMetricToDatastore(mainContext, r.measurementCh, downtimeInterval, DowntimeMeasurements)
+	go r.WatchPatroniRoles(mainContext)
+	go r.WatchDatabaseLists(mainContext)
+	go r.WatchScheduledForgets(mainContext)
+	if opts.UpdateCheck {
+		go r.WatchForUpdates(mainContext)
+	}
+	if opts.Ingest.Listen > "" {
+		go r.ServeIngest(mainContext)
+	}
+	go SyncInternalMetricToDatastore(mainContext, r.measurementCh, patroniClusterMembersInterval, func(time.Duration) []metrics.MeasurementEnvelope {
+		return PatroniClusterMembersMeasurements(sources.ResolvedPatroniMembership())
+	})
+	go SyncInternalMetricToDatastore(mainContext, r.measurementCh, metricCompatibilityInterval, func(time.Duration) []metrics.MeasurementEnvelope {
+		return MetricCompatibilityMeasurements()
+	})
+	go SyncInternalMetricToDatastore(mainContext, r.measurementCh, clockDriftInterval, func(time.Duration) []metrics.MeasurementEnvelope {
+		return ClockDriftMeasurements(mainContext, opts.Metrics.ClockDriftThreshold)
+	})
+	go SyncInternalMetricToDatastore(mainContext, r.measurementCh, extensionsInventoryInterval, func(time.Duration) []metrics.MeasurementEnvelope {
+		return ExtensionsInventoryMeasurements(mainContext, opts.Metrics.AdvisoryFeed)
+	})
+	go SyncInternalMetricToDatastore(mainContext, r.measurementCh, connectionSecurityInterval, func(time.Duration) []metrics.MeasurementEnvelope {
+		return ConnectionSecurityMeasurements(mainContext)
+	})
+	go SyncInternalMetricToDatastore(mainContext, r.measurementCh, canaryInterval, func(time.Duration) []metrics.MeasurementEnvelope {
+		return CanaryMeasurements(mainContext)
+	})
+	go SyncInternalMetricToDatastore(mainContext, r.measurementCh, growthForecastInterval, func(time.Duration) []metrics.MeasurementEnvelope {
+		return GrowthForecastMeasurements(mainContext, measurementsWriter)
+	})
+	go SyncInternalMetricToDatastore(mainContext, r.measurementCh, configDriftInterval, func(time.Duration) []metrics.MeasurementEnvelope {
+		return ConfigDriftMeasurements(mainContext, opts.Metrics.GoldenConfig)
+	})
 
 	for { //main loop
 		hostsToShutDownDueToRoleChange := make(map[string]bool) // hosts went from master to standby and have "only if master" set
@@ -95,6 +264,7 @@ func (r *Reaper) Reap(mainContext context.Context) (err error) {
 			go SyncMonitoredDBsToDatastore(mainContext, monitoredDbs, r.measurementCh)
 			lastMonitoredDBsUpdate = time.Now()
 		}
+		syncInventory(mainContext, opts.Sources, monitoredDbs)
 
 		logger.
 			WithField("sources", len(monitoredDbs)).
@@ -117,8 +287,10 @@ func (r *Reaper) Reap(mainContext context.Context) (err error) {
 			dbUniqueOrig := monitoredDB.GetDatabaseName()
 			srcType := monitoredDB.Kind
 
-			if monitoredDB.Connect(mainContext, opts.Sources) != nil {
-				logger.Warningf("could not init connection, retrying on next iteration: %w", err)
+			if err := monitoredDB.Connect(mainContext, opts.Sources, WithOverheadTracer(dbUnique),
+				WithTLSPolicy(dbUnique, monitoredDB.HostConfig.TLSPolicy),
+				WithPgBouncerPassthrough(mainContext, dbUnique, monitoredDB.HostConfig.ViaPgBouncer)); err != nil {
+				logger.WithError(err).Warning("could not init connection, retrying on next iteration")
 				continue
 			}
 
@@ -141,7 +313,7 @@ func (r *Reaper) Reap(mainContext context.Context) (err error) {
 					return monitoredDB.Metrics
 				}
 				if monitoredDB.PresetMetrics > "" {
-					return metricDefinitionMap.PresetDefs[monitoredDB.PresetMetrics].Metrics
+					return GetPresetMetrics(mainContext, dbUnique, monitoredDB.PresetMetrics, ver.ExecEnv)
 				}
 				return nil
 			}()
@@ -152,7 +324,7 @@ func (r *Reaper) Reap(mainContext context.Context) (err error) {
 						return monitoredDB.MetricsStandby
 					}
 					if monitoredDB.PresetMetricsStandby > "" {
-						return metricDefinitionMap.PresetDefs[monitoredDB.PresetMetricsStandby].Metrics
+						return GetPresetMetrics(mainContext, dbUnique, monitoredDB.PresetMetricsStandby, ver.ExecEnv)
 					}
 					return nil
 				}()
@@ -167,27 +339,27 @@ func (r *Reaper) Reap(mainContext context.Context) (err error) {
 			}
 
 			if monitoredDB.IsPostgresSource() {
-				var DBSizeMB int64
+				var DBSizeMB, minDbSizeMB int64
 
 				if opts.Sources.MinDbSizeMB >= 8 { // an empty DB is a bit less than 8MB
-					DBSizeMB, _ = DBGetSizeMB(mainContext, dbUnique) // ignore errors, i.e. only remove from monitoring when we're certain it's under the threshold
-					if DBSizeMB != 0 {
-						if DBSizeMB < opts.Sources.MinDbSizeMB {
-							logger.Infof("[%s] DB will be ignored due to the --min-db-size-mb filter. Current (up to %v cached) DB size = %d MB", dbUnique, dbSizeCachingInterval, DBSizeMB)
-							hostsToShutDownDueToRoleChange[dbUnique] = true // for the case when DB size was previosly above the threshold
-							SetUndersizedDBState(dbUnique, true)
-							continue
-						}
-						SetUndersizedDBState(dbUnique, false)
-					}
+					minDbSizeMB = opts.Sources.MinDbSizeMB
+					DBSizeMB, _ = DBGetSizeMB(mainContext, dbUnique) // ignore errors, i.e. keep the current state when the size is unknown
+				}
+				prev, curr, serr := SetDBSizeDormancy(dbUnique, DBSizeMB, minDbSizeMB, opts.Sources.MinDbSizeHysteresis)
+				r.reportDormancyChange(mainContext, monitoredDB, prev, curr, serr)
+				if curr.Undersized {
+					logger.Debugf("[%s] DB ignored due to the --min-db-size-mb filter. Current (up to %v cached) DB size = %d MB", dbUnique, dbSizeCachingInterval, DBSizeMB)
+					hostsToShutDownDueToRoleChange[dbUnique] = true // for the case when DB size was previosly above the threshold
+					continue
 				}
 				ver, err := GetMonitoredDatabaseSettings(mainContext, dbUnique, monitoredDB.Kind, false)
 				if err == nil { // ok to ignore error, re-tried on next loop
 					lastKnownStatusInRecovery := hostLastKnownStatusInRecovery[dbUnique]
-					if ver.IsInRecovery && monitoredDB.OnlyIfMaster {
-						logger.Infof("[%s] to be removed from monitoring due to 'master only' property and status change", dbUnique)
+					prev, curr, serr := SetRecoveryDormancy(dbUnique, ver.IsInRecovery && monitoredDB.OnlyIfMaster)
+					r.reportDormancyChange(mainContext, monitoredDB, prev, curr, serr)
+					if curr.RecoveryIgnored {
+						logger.Debugf("[%s] DB ignored due to 'master only' property", dbUnique)
 						hostsToShutDownDueToRoleChange[dbUnique] = true
-						SetRecoveryIgnoredDBState(dbUnique, true)
 						continue
 					} else if lastKnownStatusInRecovery != ver.IsInRecovery {
 						if ver.IsInRecovery && len(monitoredDB.MetricsStandby) > 0 {
@@ -198,7 +370,6 @@ func (r *Reaper) Reap(mainContext context.Context) (err error) {
 							logger.Warningf("Switching metrics collection for \"%s\" to primary config...", dbUnique)
 							metricConfig = monitoredDB.Metrics
 							hostLastKnownStatusInRecovery[dbUnique] = false
-							SetRecoveryIgnoredDBState(dbUnique, false)
 						}
 					}
 				}
@@ -210,6 +381,7 @@ func (r *Reaper) Reap(mainContext context.Context) (err error) {
 				}
 			}
 
+			metricConfig = routeStandbyMetrics(monitoredDB, metricConfig)
 			for metricName, interval := range metricConfig {
 				metric := metricName
 				metricDefOk := false
@@ -322,18 +494,22 @@ func (r *Reaper) Reap(mainContext context.Context) (err error) {
 					logger.Warningf("Could not find PG version info for DB %s, skipping shutdown check of metric worker process for %s", db, metric)
 					continue
 				}
-				if verInfo.IsInRecovery && dbInfo.PresetMetricsStandby > "" || !verInfo.IsInRecovery && dbInfo.PresetMetrics > "" {
-					continue // no need to check presets for single metric disabling
-				}
-				if verInfo.IsInRecovery && len(dbInfo.MetricsStandby) > 0 {
-					currentMetricConfig = dbInfo.MetricsStandby
+				if routed, active := standbyRoutedMetricState(dbInfo, metric, verInfo.IsInRecovery); routed {
+					singleMetricDisabled = !active
 				} else {
-					currentMetricConfig = dbInfo.Metrics
-				}
+					if verInfo.IsInRecovery && dbInfo.PresetMetricsStandby > "" || !verInfo.IsInRecovery && dbInfo.PresetMetrics > "" {
+						continue // no need to check presets for single metric disabling
+					}
+					if verInfo.IsInRecovery && len(dbInfo.MetricsStandby) > 0 {
+						currentMetricConfig = dbInfo.MetricsStandby
+					} else {
+						currentMetricConfig = dbInfo.Metrics
+					}
 
-				interval, isMetricActive := currentMetricConfig[metric]
-				if !isMetricActive || interval <= 0 {
-					singleMetricDisabled = true
+					interval, isMetricActive := currentMetricConfig[metric]
+					if !isMetricActive || interval <= 0 {
+						singleMetricDisabled = true
+					}
 				}
 			}
 
@@ -362,15 +538,56 @@ func (r *Reaper) Reap(mainContext context.Context) (err error) {
 		prevLoopMonitoredDBs = slices.Clone(monitoredDbs)
 
 		logger.Debugf("main sleeping %ds...", opts.Sources.Refresh)
-		select {
-		case <-time.After(time.Second * time.Duration(opts.Sources.Refresh)):
-			if monitoredDbs, err = monitoredDbs.SyncFromReader(sourcesReaderWriter); err != nil {
-				logger.Error("could not fetch active hosts, using last valid config data:", err)
+		if !r.waitForRefresh(mainContext) {
+			r.drain(logger)
+			if err := measurementsWriter.RecordStop(); err != nil {
+				logger.WithError(err).Warning("could not record the collector shutdown")
+			}
+			if err := measurementsWriter.Close(); err != nil {
+				logger.WithError(err).Warning("could not save the state of the sinks")
 			}
-		case <-mainContext.Done():
 			return
 		}
+		if mds, err := monitoredDbs.SyncFromReader(sourcesReaderWriter); err != nil {
+			logger.Error("could not fetch active hosts, using last valid config data:", err)
+		} else {
+			monitoredDbs = mds
+		}
+		if err := measurementsWriter.RecordConfig(configHash(monitoredDbs)); err != nil {
+			logger.WithError(err).Warning("could not record the collector configuration")
+		}
+	}
+}
+
+// fetchMeasurements fetches the metric directly from the OS if possible and via the metric SQL otherwise,
+// the latter also if the direct OS read fails
+func (r *Reaper) fetchMeasurements(ctx context.Context, mfm MetricFetchConfig, vme MonitoredDatabaseSettings, mvp metrics.Metric,
+	hostState map[string]map[string]string) (metricStoreMessages []metrics.MeasurementEnvelope, err error) {
+	// 1st try local overrides for some metrics if operating in push mode
+	if r.opts.Metrics.DirectOSStats && isDirectlyFetchable(mfm.DBUniqueName, mfm.MetricName) {
+		metricStoreMessages, err = FetchStatsDirectlyFromOS(ctx, mfm, vme, mvp)
+		if err != nil {
+			log.GetLogger(ctx).WithField("source", mfm.DBUniqueName).WithField("metric", mfm.MetricName).
+				WithError(err).Errorf("Could not reader metric directly from OS")
+		}
+	}
+	if metricStoreMessages != nil {
+		return metricStoreMessages, nil
+	}
+	var release func()
+	if mvp.IsBulk {
+		if err = guardOverload(ctx, mfm.DBUniqueName, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	// surplus fetches of the host queue at most until the next one is due
+	if release, err = acquireFetchSlot(ctx, mfm.DBUniqueName, r.opts.Sources.MaxParallelFetchesPerHost, mfm.Interval); err != nil {
+		return nil, err
 	}
+	defer release()
+	fetchCtx, cancelFetch := WithFetchTimeout(ctx, mfm, r.opts)
+	defer cancelFetch()
+	return FetchMetrics(fetchCtx, mfm, hostState, r.measurementCh, "", r.opts)
 }
 
 // metrics.ControlMessage notifies of shutdown + interval change
@@ -382,14 +599,27 @@ func (r *Reaper) reapMetricMeasurementsFromSource(ctx context.Context,
 
 	hostState := make(map[string]map[string]string)
 	var lastUptimeS int64 = -1 // used for "server restarted" event detection
-	var lastErrorNotificationTime time.Time
+	var statsResetDetector StatsResetDetector
+	var derivedCalculator DerivedMetricsCalculator
+	var archiverStuckDetector ArchiverStuckDetector
 	var vme MonitoredDatabaseSettings
 	var mvp metrics.Metric
 	var err error
 	failedFetches := 0
 	lastDBVersionFetchTime := time.Unix(0, 0) // check DB ver. ev. 5 min
+	// the first measurements after a collector downtime are marked as catch-up rows
+	backfillPending := r.IsBackfillMetric(metricName)
 
 	l := log.GetLogger(ctx).WithField("source", dbUniqueName).WithField("metric", metricName)
+	defer func() { // log the crash of the gatherer for the error report, the process exits anyway
+		if p := recover(); p != nil {
+			l.WithField(log.CallstackField, string(debug.Stack())).Error(p)
+			panic(p)
+		}
+	}()
+	ctx = WithQueryOrigin(ctx, "gatherer", metricName)
+	status := startGathererStatus(dbUniqueName, metricName)
+	defer status.stop()
 	if metricName == specialMetricServerLogEventCounts {
 		mdb, err := GetMonitoredDatabaseByUniqueName(dbUniqueName)
 		if err != nil {
@@ -418,46 +648,55 @@ func (r *Reaper) reapMetricMeasurementsFromSource(ctx context.Context,
 			}
 		}
 
-		var metricStoreMessages []metrics.MeasurementEnvelope
-		var err error
 		mfm := MetricFetchConfig{
 			DBUniqueName:        dbUniqueName,
 			DBUniqueNameOrig:    dbUniqueNameOrig,
 			MetricName:          metricName,
 			Source:              srcType,
 			Interval:            time.Second * time.Duration(interval),
-			StmtTimeoutOverride: 0,
+			StmtTimeoutOverride: mvp.StatementTimeoutSeconds,
 		}
 
-		// 1st try local overrides for some metrics if operating in push mode
-		if r.opts.Metrics.DirectOSStats && IsDirectlyFetchableMetric(metricName) {
-			metricStoreMessages, err = FetchStatsDirectlyFromOS(ctx, mfm, vme, mvp)
-			if err != nil {
-				l.WithError(err).Errorf("Could not reader metric directly from OS")
-			}
-		}
 		t1 := time.Now()
-		if metricStoreMessages == nil {
-			metricStoreMessages, err = FetchMetrics(ctx, mfm, hostState, r.measurementCh, "", r.opts)
-		}
+		metricStoreMessages, err := r.fetchMeasurements(ctx, mfm, vme, mvp, hostState)
 		t2 := time.Now()
+		status.update(err)
 
 		if t2.Sub(t1) > (time.Second * time.Duration(interval)) {
 			l.Warningf("Total fetching time of %vs bigger than %vs interval", t2.Sub(t1).Truncate(time.Millisecond*100).Seconds(), interval)
 		}
 
+		sleepInterval := time.Second * time.Duration(interval)
+		if r.opts.Metrics.AlignTimestamps {
+			sleepInterval = untilNextTick(time.Now(), sleepInterval)
+		}
 		if err != nil {
-			failedFetches++
-			// complain only 1x per 10min per host/metric...
-			if lastErrorNotificationTime.IsZero() || lastErrorNotificationTime.Add(time.Second*time.Duration(600)).Before(time.Now()) {
-				l.WithError(err).Error("failed to fetch metric data")
-				if failedFetches > 1 {
-					l.Errorf("Total failed fetches: %d", failedFetches)
-				}
-				lastErrorNotificationTime = time.Now()
+			errKind := ClassifyFetchError(err)
+			RecordFetchError(dbUniqueName, errKind)
+			if errKind == FetchErrorTooManyConnections {
+				r.handleConnectionLimit(ctx, dbUniqueName)
+			}
+			MonitoredDatabasesSettingsLock.RLock()
+			inRecovery := MonitoredDatabasesSettings[dbUniqueName].IsInRecovery
+			MonitoredDatabasesSettingsLock.RUnlock()
+			switch errKind.Action(inRecovery) {
+			case FetchErrorActionSkip:
+				l.WithError(err).WithField("kind", errKind).Debug("metric not available in the current server state")
+			case FetchErrorActionDisable:
+				failedFetches++
+				sleepInterval = max(sleepInterval, fetchErrorDisablePeriod)
+				r.fetchErrors.Error(l.WithField("kind", errKind), "metric "+metricName, dbUniqueName, err,
+					fmt.Sprintf("failed to fetch metric data, pausing gatherer for %v", sleepInterval))
+			default:
+				failedFetches++
+				r.fetchErrors.Error(l.WithField("kind", errKind).WithField("failed_fetches", failedFetches), "metric "+metricName, dbUniqueName, err,
+					"failed to fetch metric data")
 			}
 		} else if metricStoreMessages != nil {
 			if len(metricStoreMessages[0].Data) > 0 {
+				if r.opts.Metrics.AlignTimestamps {
+					alignTimestamps(metricStoreMessages, alignedTick(t1, mfm.Interval))
+				}
 
 				// pick up "server restarted" events here to avoid doing extra selects from CheckForPGObjectChangesAndStore code
 				if metricName == "db_stats" {
@@ -467,23 +706,40 @@ func (r *Reaper) reapMetricMeasurementsFromSource(ctx context.Context,
 							if postmasterUptimeS.(int64) < lastUptimeS { // restart (or possibly also failover when host is routed) happened
 								message := "Detected server restart (or failover) of \"" + dbUniqueName + "\""
 								l.Warning(message)
-								detectedChangesSummary := make(metrics.Measurements, 0)
-								entry := metrics.Measurement{"details": message, "epoch_ns": (metricStoreMessages[0].Data)[0]["epoch_ns"]}
-								detectedChangesSummary = append(detectedChangesSummary, entry)
-								metricStoreMessages = append(metricStoreMessages,
-									metrics.MeasurementEnvelope{
-										DBName:     dbUniqueName,
-										SourceType: string(srcType),
-										MetricName: "object_changes",
-										Data:       detectedChangesSummary,
-										CustomTags: metricStoreMessages[0].CustomTags,
-									})
+								metricStoreMessages = append(metricStoreMessages, newServerEvent(metricStoreMessages[0], srcType, message))
 							}
 						}
 						lastUptimeS = postmasterUptimeS.(int64)
 					}
 				}
 
+				if backfillPending {
+					backfillPending = false
+					if gap := r.backfillGap(ctx, dbUniqueName, cmp.Or(mvp.StorageName, metricName), mfm.Interval); gap > 0 {
+						markBackfilled(metricStoreMessages, gap)
+						message := fmt.Sprintf("Backfilled %v gap of metric \"%s\" of \"%s\"", gap.Truncate(time.Second), metricName, dbUniqueName)
+						l.Info(message)
+						metricStoreMessages = append(metricStoreMessages, newServerEvent(metricStoreMessages[0], srcType, message))
+					}
+				}
+
+				// flag counter discontinuities so that rates can be calculated correctly downstream
+				if statsResetMetrics[metricName] {
+					row := metricStoreMessages[0].Data[0]
+					if statsResetDetector.Detect(row, mvp.Gauges) {
+						row[statsResetColumn] = true
+						message := "Detected statistics reset (" + metricName + ") of \"" + dbUniqueName + "\""
+						l.Warning(message)
+						metricStoreMessages = append(metricStoreMessages, newServerEvent(metricStoreMessages[0], srcType, message))
+					}
+				}
+
+				if metricName == archiverMetric {
+					archiverStuckDetector.Detect(metricStoreMessages[0].Data[0], time.Now(), r.opts.Metrics.ArchivingStuckThreshold)
+				}
+
+				metricStoreMessages = append(metricStoreMessages, derivedCalculator.Calculate(ctx, metricStoreMessages[0])...)
+
 				r.measurementCh <- metricStoreMessages
 			}
 		}
@@ -491,12 +747,23 @@ func (r *Reaper) reapMetricMeasurementsFromSource(ctx context.Context,
 		select {
 		case <-ctx.Done():
 			return
-		case <-time.After(time.Second * time.Duration(interval)):
-			l.Debugf("MetricGathererLoop slept for %s", time.Second*time.Duration(interval))
+		case <-time.After(sleepInterval):
+			l.Debugf("MetricGathererLoop slept for %s", sleepInterval)
 		}
 	}
 }
 
+// newServerEvent returns an "object_changes" entry describing a cluster level event detected from the metric data
+func newServerEvent(msg metrics.MeasurementEnvelope, srcType sources.Kind, message string) metrics.MeasurementEnvelope {
+	return metrics.MeasurementEnvelope{
+		DBName:     msg.DBName,
+		SourceType: string(srcType),
+		MetricName: "object_changes",
+		Data:       metrics.Measurements{{"details": message, epochColumnName: msg.Data[0][epochColumnName]}},
+		CustomTags: msg.CustomTags,
+	}
+}
+
 func StoreMetrics(metrics []metrics.MeasurementEnvelope, storageCh chan<- []metrics.MeasurementEnvelope) (int, error) {
 	if len(metrics) > 0 {
 		storageCh <- metrics
@@ -507,7 +774,7 @@ func StoreMetrics(metrics []metrics.MeasurementEnvelope, storageCh chan<- []metr
 
 func SyncMonitoredDBsToDatastore(ctx context.Context, monitoredDbs []*sources.MonitoredDatabase, persistenceChannel chan []metrics.MeasurementEnvelope) {
 	if len(monitoredDbs) > 0 {
-		msms := make([]metrics.MeasurementEnvelope, len(monitoredDbs))
+		msms := make([]metrics.MeasurementEnvelope, 0, len(monitoredDbs))
 		now := time.Now()
 
 		for _, mdb := range monitoredDbs {
@@ -520,6 +787,12 @@ func SyncMonitoredDBsToDatastore(ctx context.Context, monitoredDbs []*sources.Mo
 			for k, v := range mdb.CustomTags {
 				db[tagPrefix+k] = v
 			}
+			for k, v := range mdb.OwnershipTags() { // explicitly set ownership takes precedence over custom tags
+				db[tagPrefix+k] = v
+			}
+			if mdb.Notes > "" {
+				db["notes"] = mdb.Notes
+			}
 			msms = append(msms, metrics.MeasurementEnvelope{
 				DBName:     mdb.Name,
 				MetricName: monitoredDbsDatastoreSyncMetricName,
@@ -535,6 +808,30 @@ func SyncMonitoredDBsToDatastore(ctx context.Context, monitoredDbs []*sources.Mo
 	}
 }
 
+// SyncInternalMetricToDatastore periodically stores the measurements of an internal metric, i.e. one
+// gathered by pgwatch itself, for all monitored DBs. measure gets the time passed since its previous call
+func SyncInternalMetricToDatastore(ctx context.Context, storageCh chan<- []metrics.MeasurementEnvelope, interval time.Duration,
+	measure func(sinceLast time.Duration) []metrics.MeasurementEnvelope) {
+	lastRun := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-time.After(interval):
+			msgs := measure(now.Sub(lastRun))
+			lastRun = now
+			if len(msgs) == 0 {
+				continue
+			}
+			select {
+			case storageCh <- msgs:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
 func AddDbnameSysinfoIfNotExistsToQueryResultData(data metrics.Measurements, ver MonitoredDatabaseSettings, opts *cmdopts.Options) metrics.Measurements {
 	enrichedData := make(metrics.Measurements, 0)
 	for _, dr := range data {
@@ -622,16 +919,6 @@ func FetchMetrics(ctx context.Context,
 		log.GetLogger(ctx).Error("failed to fetch pg version for ", msg.DBUniqueName, msg.MetricName, err)
 		return nil, err
 	}
-	if msg.MetricName == specialMetricDbSize || msg.MetricName == specialMetricTableStats {
-		if dbSettings.ExecEnv == execEnvAzureSingle && dbSettings.ApproxDBSizeB > 1e12 { // 1TB
-			subsMetricName := msg.MetricName + "_approx"
-			mvpApprox, err := GetMetricVersionProperties(subsMetricName, dbSettings, nil)
-			if err == nil && mvpApprox.StorageName == msg.MetricName {
-				log.GetLogger(ctx).Infof("[%s:%s] Transparently swapping metric to %s due to hard-coded rules...", msg.DBUniqueName, msg.MetricName, subsMetricName)
-				msg.MetricName = subsMetricName
-			}
-		}
-	}
 	dbVersion = dbSettings.Version
 
 	if msg.Source == sources.SourcePgBouncer {
@@ -651,6 +938,18 @@ func FetchMetrics(ctx context.Context,
 		return nil, err
 	}
 
+	if msg.FallbackFor > "" && mvp.StorageName == "" {
+		mvp.StorageName = msg.FallbackFor // store fallback data under the original metric name
+	}
+	if mvp.FallbackOnSource(dbSettings.ExecEnv, dbSettings.ApproxDBSizeB) && msg.FallbackDepth < maxFallbackDepth {
+		log.GetLogger(ctx).Infof("[%s:%s] transparently swapping metric to %s due to the %s execution environment", msg.DBUniqueName, msg.MetricName, mvp.FallbackMetric, dbSettings.ExecEnv)
+		return FetchMetrics(ctx, msg.FallbackTo(mvp), hostState, storageCh, context, opts)
+	}
+	if !mvp.AllowsEnv(dbSettings.ExecEnv) {
+		log.GetLogger(ctx).Debugf("[%s:%s] Skipping fetching as metric is not supported in the %s execution environment", msg.DBUniqueName, msg.MetricName, dbSettings.ExecEnv)
+		return nil, nil
+	}
+
 	isCacheable = IsCacheableMetric(msg, mvp)
 	if isCacheable && opts.Metrics.InstanceLevelCacheMaxSeconds > 0 && msg.Interval.Seconds() > float64(opts.Metrics.InstanceLevelCacheMaxSeconds) {
 		cachedData = GetFromInstanceCacheIfNotOlderThanSeconds(msg, opts.Metrics.InstanceLevelCacheMaxSeconds)
@@ -673,10 +972,42 @@ func FetchMetrics(ctx context.Context,
 		return nil, nil
 	}
 
+	if mvp.RunIfSQL > "" && md.IsPostgresSource() {
+		holds, err := metricPredicateHolds(ctx, msg.DBUniqueName, mvp.RunIfSQL)
+		if err != nil {
+			log.GetLogger(ctx).WithError(err).Infof("[%s:%s] failed to check the run_if_sql predicate", msg.DBUniqueName, msg.MetricName)
+			return nil, err
+		}
+		if !holds {
+			log.GetLogger(ctx).Debugf("[%s:%s] Skipping fetching as the run_if_sql predicate does not hold", msg.DBUniqueName, msg.MetricName)
+			return nil, nil
+		}
+	}
+
 	if msg.MetricName == specialMetricChangeEvents && context != contextPrometheusScrape { // special handling, multiple queries + stateful
 		CheckForPGObjectChangesAndStore(ctx, msg.DBUniqueName, dbSettings, storageCh, hostState) // TODO no hostState for Prometheus currently
 	} else if msg.MetricName == recoMetricName && context != contextPrometheusScrape {
-		if data, err = GetRecommendations(ctx, msg.DBUniqueName, dbSettings); err != nil {
+		if data, err = GetRecommendations(ctx, msg.DBUniqueName, dbSettings, time.Duration(opts.Metrics.UnusedIndexDays)*24*time.Hour); err != nil {
+			return nil, err
+		}
+	} else if msg.MetricName == specialMetricAutovacuumHealth {
+		if data, err = FetchAutovacuumHealth(ctx, msg.DBUniqueName, time.Now(), opts.Metrics.VacuumStarvationThreshold); err != nil {
+			return nil, err
+		}
+	} else if msg.MetricName == specialMetricSchemaStats {
+		if data, err = FetchSchemaStats(ctx, msg.DBUniqueName); err != nil {
+			return nil, err
+		}
+	} else if msg.MetricName == specialMetricCheckpointEfficiency {
+		if data, err = FetchCheckpointEfficiency(ctx, msg.DBUniqueName, sql); err != nil {
+			return nil, err
+		}
+	} else if msg.MetricName == specialMetricTempSpills {
+		if data, err = FetchTempSpills(ctx, msg.DBUniqueName); err != nil {
+			return nil, err
+		}
+	} else if msg.MetricName == specialMetricStmtSummary {
+		if data, err = FetchStmtSummary(ctx, msg, hostState, storageCh, context, opts); err != nil {
 			return nil, err
 		}
 	} else if msg.Source == sources.SourcePgPool {
@@ -684,11 +1015,29 @@ func FetchMetrics(ctx context.Context,
 			return nil, err
 		}
 	} else {
-		data, err = DBExecReadByDbUniqueName(ctx, msg.DBUniqueName, sql)
+		if md.IsPostgresSource() { // poolers' admin consoles don't understand comments
+			sql = TagMetricSQL(sql, msg.MetricName, GetCollectorID(opts))
+		}
+		t1 := time.Now()
+		if err = injectedFetchTimeout(md); err == nil {
+			data, err = DBExecReadByDbUniqueName(ctx, msg.DBUniqueName, sql)
+		}
+		if err == nil && len(mvp.Composite.Statements) > 0 {
+			data, err = FetchCompositeStatements(ctx, msg, md, dbVersion, mvp.Composite, data, opts)
+		}
+		LogSlowMetric(ctx, msg, sql, time.Since(t1), len(data), opts)
+		RecordClockDrift(msg.DBUniqueName, data, t1, time.Since(t1))
 
 		if err != nil {
+			errKind := ClassifyFetchError(err)
+			if mvp.FallbackOnError(errKind == FetchErrorTimeout) && msg.FallbackDepth < maxFallbackDepth {
+				log.GetLogger(ctx).WithError(err).Infof("[%s:%s] fetching fallback metric %s", msg.DBUniqueName, msg.MetricName, mvp.FallbackMetric)
+				fallbackCtx, cancel := withFallbackTimeout(ctx, msg, opts)
+				defer cancel()
+				return FetchMetrics(fallbackCtx, msg.FallbackTo(mvp), hostState, storageCh, context, opts)
+			}
 			// let's soften errors to "info" from functions that expect the server to be a primary to reduce noise
-			if strings.Contains(err.Error(), "recovery is in progress") {
+			if errKind == FetchErrorRecovery {
 				MonitoredDatabasesSettingsLock.RLock()
 				ver := MonitoredDatabasesSettings[msg.DBUniqueName]
 				MonitoredDatabasesSettingsLock.RUnlock()
@@ -700,16 +1049,17 @@ func FetchMetrics(ctx context.Context,
 
 			if msg.MetricName == specialMetricInstanceUp {
 				log.GetLogger(ctx).WithError(err).Debugf("[%s:%s] failed to fetch metrics. marking instance as not up", msg.DBUniqueName, msg.MetricName)
+				RecordInstanceUp(msg.DBUniqueName, false)
 				data = make(metrics.Measurements, 1)
 				data[0] = metrics.Measurement{"epoch_ns": time.Now().UnixNano(), "is_up": 0} // should be updated if the "instance_up" metric definition is changed
 				goto send_to_storageChannel
 			}
 
-			if strings.Contains(err.Error(), "connection refused") {
+			if errKind == FetchErrorConnection {
 				SetDBUnreachableState(msg.DBUniqueName)
 			}
 
-			log.GetLogger(ctx).Infof("[%s:%s] failed to fetch metrics: %s", msg.DBUniqueName, msg.MetricName, err)
+			log.GetLogger(ctx).WithField("kind", errKind).Infof("[%s:%s] failed to fetch metrics: %s", msg.DBUniqueName, msg.MetricName, err)
 
 			return nil, err
 		}
@@ -720,9 +1070,14 @@ func FetchMetrics(ctx context.Context,
 		}
 
 		ClearDBUnreachableStateIfAny(msg.DBUniqueName)
-
+		if msg.MetricName == specialMetricInstanceUp {
+			RecordInstanceUp(msg.DBUniqueName, true)
+		}
 	}
 
+	CheckFetchedSchema(ctx, msg.DBUniqueName, msg.MetricName, mvp, data)
+	data = mvp.Scrub.Apply(data) // before caching, so that sensitive data isn't kept in memory either
+
 	if isCacheable && opts.Metrics.InstanceLevelCacheMaxSeconds > 0 && msg.Interval.Seconds() > float64(opts.Metrics.InstanceLevelCacheMaxSeconds) {
 		PutToInstanceCache(msg, data)
 	}
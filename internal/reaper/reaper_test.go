@@ -0,0 +1,94 @@
+package reaper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/cmdopts"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddDbnameSysinfoIfNotExistsToQueryResultData(t *testing.T) {
+	opts := &cmdopts.Options{}
+	opts.Sinks.RealDbnameField = "real_dbname"
+	opts.Sinks.SystemIdentifierField = "sys_id"
+	ver := MonitoredDatabaseSettings{RealDbname: "db1", SystemIdentifier: "123"}
+
+	data := AddDbnameSysinfoIfNotExistsToQueryResultData(metrics.Measurements{{"x": 1}}, ver, opts)
+	assert.Equal(t, metrics.Measurements{{"x": 1, "real_dbname": "db1", "sys_id": "123"}}, data)
+}
+
+func TestSyncMonitoredDBsToDatastore(t *testing.T) {
+	md := &sources.MonitoredDatabase{Source: sources.Source{
+		Name:       "db1",
+		Group:      "billing",
+		CustomTags: map[string]string{"env": "prod", "team": "platform"},
+		Team:       "payments",
+		Contact:    "#payments-oncall",
+		Notes:      "runbook: https://wiki/db1",
+	}}
+	ch := make(chan []metrics.MeasurementEnvelope, 1)
+	SyncMonitoredDBsToDatastore(context.Background(), sources.MonitoredDatabases{md}, ch)
+	msgs := <-ch
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "db1", msgs[0].DBName)
+	assert.Equal(t, monitoredDbsDatastoreSyncMetricName, msgs[0].MetricName)
+	data := msgs[0].Data[0]
+	assert.Equal(t, "prod", data["tag_env"])
+	assert.Equal(t, "payments", data["tag_team"], "the ownership takes precedence over the custom tags")
+	assert.Equal(t, "#payments-oncall", data["tag_contact"])
+	assert.NotContains(t, data, "tag_owner")
+	assert.Equal(t, "runbook: https://wiki/db1", data["notes"])
+}
+
+func TestRefreshWakesMainLoop(t *testing.T) {
+	opts := &cmdopts.Options{}
+	opts.Sources.Refresh = 3600
+	reader, err := metrics.NewDefaultMetricReader(context.Background())
+	assert.NoError(t, err)
+	r := NewReaper(opts, nil, reader)
+	metricDefMapLock.Lock()
+	prevDefs := metricDefinitionMap.MetricDefs
+	metricDefinitionMap.MetricDefs = nil
+	metricDefMapLock.Unlock()
+	defer func() {
+		metricDefMapLock.Lock()
+		metricDefinitionMap.MetricDefs = prevDefs
+		metricDefMapLock.Unlock()
+	}()
+
+	r.Refresh()
+	r.Refresh() // pending refresh requests are merged
+	start := time.Now()
+	assert.True(t, r.waitForRefresh(context.Background()))
+	assert.Less(t, time.Since(start), time.Second)
+	metricDefMapLock.RLock()
+	assert.NotEmpty(t, metricDefinitionMap.MetricDefs, "metric definitions should be re-read")
+	metricDefMapLock.RUnlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, r.waitForRefresh(ctx), "no more refresh should be pending")
+}
+
+func TestReaperDrain(t *testing.T) {
+	r := NewReaper(&cmdopts.Options{}, nil, nil)
+	for range 3 {
+		r.measurementCh <- []metrics.MeasurementEnvelope{{MetricName: "cpu"}}
+	}
+	go func() {
+		for range 3 {
+			time.Sleep(drainPollInterval)
+			<-r.measurementCh
+		}
+	}()
+	start := time.Now()
+	r.drain(log.GetLogger(context.Background()))
+	assert.Empty(t, r.measurementCh, "the queued measurements are passed on before the shutdown")
+	assert.Less(t, time.Since(start), drainTimeout)
+}
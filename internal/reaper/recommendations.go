@@ -8,6 +8,7 @@ import (
 
 	"errors"
 
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
 )
 
@@ -19,9 +20,6 @@ const (
 	specialMetricPgbouncer            = "^pgbouncer_(stats|pools)$"
 	specialMetricPgpoolStats          = "pgpool_stats"
 	specialMetricInstanceUp           = "instance_up"
-	specialMetricDbSize               = "db_size"     // can be transparently switched to db_size_approx on instances with very slow FS access (Azure Single Server)
-	specialMetricTableStats           = "table_stats" // can be transparently switched to table_stats_approx on instances with very slow FS (Azure Single Server)
-
 )
 
 var specialMetrics = map[string]bool{recoMetricName: true, specialMetricChangeEvents: true, specialMetricServerLogEventCounts: true}
@@ -43,7 +41,9 @@ func GetAllRecoMetricsForVersion(vme MonitoredDatabaseSettings) (map[string]metr
 	return mvpMap, nil
 }
 
-func GetRecommendations(ctx context.Context, dbUnique string, vme MonitoredDatabaseSettings) (metrics.Measurements, error) {
+// GetRecommendations executes all the reco_* metrics and adds the index recommendations of the collector-side analysis,
+// indexes without scans for unusedIndexAge are reported as unused
+func GetRecommendations(ctx context.Context, dbUnique string, vme MonitoredDatabaseSettings, unusedIndexAge time.Duration) (metrics.Measurements, error) {
 	retData := make(metrics.Measurements, 0)
 	startTimeEpochNs := time.Now().UnixNano()
 
@@ -63,6 +63,14 @@ func GetRecommendations(ctx context.Context, dbUnique string, vme MonitoredDatab
 			retData = append(retData, d)
 		}
 	}
+	if indexRecos, e := GetIndexRecommendations(ctx, dbUnique, vme, unusedIndexAge); e != nil {
+		log.GetLogger(ctx).WithField("source", dbUnique).WithError(e).Warning("could not analyze the indexes")
+	} else {
+		for _, d := range indexRecos {
+			d["major_ver"] = vme.Version / 10
+			retData = append(retData, d)
+		}
+	}
 	if len(retData) == 0 { // insert a dummy entry minimally so that Grafana can show at least a dropdown
 		dummy := make(metrics.Measurement)
 		dummy["tag_reco_topic"] = "dummy"
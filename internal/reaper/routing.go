@@ -0,0 +1,47 @@
+package reaper
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+)
+
+// routeStandbyMetrics applies the standby metrics routing of Patroni clusters to the metric configuration
+// of the source: the standby metrics are removed from the primary and added to its designated replica
+// with the intervals of the primary
+func routeStandbyMetrics(md *sources.MonitoredDatabase, metricConfig map[string]float64) map[string]float64 {
+	if md.StandbyMetricsTo == "" && md.StandbyMetricsFrom == "" {
+		return metricConfig
+	}
+	var primaryConfig map[string]float64
+	if md.StandbyMetricsFrom > "" {
+		primary, err := GetMonitoredDatabaseByUniqueName(md.StandbyMetricsFrom)
+		if err != nil {
+			return metricConfig
+		}
+		_, primaryConfig = effectiveMetrics(primary, false)
+	}
+	routed := maps.Clone(metricConfig)
+	if routed == nil {
+		routed = make(map[string]float64, len(md.HostConfig.StandbyMetrics))
+	}
+	for _, metric := range md.HostConfig.StandbyMetrics {
+		if md.StandbyMetricsTo > "" {
+			delete(routed, metric)
+		} else if interval := primaryConfig[metric]; interval > 0 {
+			routed[metric] = interval
+		}
+	}
+	return routed
+}
+
+// standbyRoutedMetricState tells if the metric is subject to the standby metrics routing of the source
+// and, if so, whether its gatherer should be running
+func standbyRoutedMetricState(md *sources.MonitoredDatabase, metric string, inRecovery bool) (routed, active bool) {
+	if !slices.Contains(md.HostConfig.StandbyMetrics, metric) {
+		return false, false
+	}
+	_, metricConfig := effectiveMetrics(md, inRecovery)
+	return true, routeStandbyMetrics(md, metricConfig)[metric] > 0
+}
@@ -0,0 +1,34 @@
+package reaper
+
+import (
+	"testing"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteStandbyMetrics(t *testing.T) {
+	primary := &sources.MonitoredDatabase{Source: sources.Source{Name: "node1",
+		Metrics: map[string]float64{"table_stats": 300, "wal": 60}}, StandbyMetricsTo: "node2"}
+	primary.HostConfig.StandbyMetrics = []string{"table_stats", "bloat"}
+	replica := &sources.MonitoredDatabase{Source: sources.Source{Name: "node2",
+		Metrics: map[string]float64{"table_stats": 300, "wal": 60}, MetricsStandby: map[string]float64{"replication": 60}}, StandbyMetricsFrom: "node1"}
+	replica.HostConfig.StandbyMetrics = primary.HostConfig.StandbyMetrics
+	other := &sources.MonitoredDatabase{Source: sources.Source{Name: "other"}}
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{primary, replica, other})
+	defer UpdateMonitoredDBCache(nil)
+
+	assert.Equal(t, map[string]float64{"wal": 60}, routeStandbyMetrics(primary, primary.Metrics))
+	assert.Equal(t, map[string]float64{"table_stats": 300, "wal": 60}, primary.Metrics, "source config must not be changed")
+	assert.Equal(t, map[string]float64{"replication": 60, "table_stats": 300}, routeStandbyMetrics(replica, replica.MetricsStandby))
+	assert.Equal(t, map[string]float64{"x": 1}, routeStandbyMetrics(other, map[string]float64{"x": 1}))
+
+	routed, active := standbyRoutedMetricState(primary, "table_stats", false)
+	assert.True(t, routed)
+	assert.False(t, active, "routed metric is stopped on the primary")
+	routed, active = standbyRoutedMetricState(replica, "table_stats", true)
+	assert.True(t, routed)
+	assert.True(t, active, "routed metric is started on the replica")
+	routed, _ = standbyRoutedMetricState(replica, "replication", true)
+	assert.False(t, routed)
+}
@@ -0,0 +1,26 @@
+package reaper
+
+import (
+	"context"
+	"fmt"
+)
+
+// metricPredicateHolds executes the run_if_sql predicate of a metric, e.g. checking whether an optional
+// extension is installed. The metric is fetched only if the predicate returns a single true boolean
+func metricPredicateHolds(ctx context.Context, dbUnique, sql string) (bool, error) {
+	data, err := DBExecReadByDbUniqueName(ctx, dbUnique, sql)
+	if err != nil || len(data) == 0 {
+		return false, err
+	}
+	if len(data[0]) != 1 {
+		return false, fmt.Errorf("run_if_sql must return a single boolean column, got %d columns", len(data[0]))
+	}
+	for _, v := range data[0] {
+		holds, ok := v.(bool)
+		if !ok && v != nil {
+			return false, fmt.Errorf("run_if_sql must return a boolean, got %T", v)
+		}
+		return holds, nil
+	}
+	return false, nil
+}
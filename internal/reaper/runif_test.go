@@ -0,0 +1,47 @@
+package reaper
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricPredicateHolds(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer conn.Close()
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{{Source: sources.Source{Name: "runif_db", Kind: sources.SourcePostgres}, Conn: conn}})
+	defer UpdateMonitoredDBCache(nil)
+	ctx := context.Background()
+	const predicate = "select to_regclass"
+
+	for _, tc := range []struct {
+		name  string
+		rows  *pgxmock.Rows
+		holds bool
+		err   string
+	}{
+		{"true", pgxmock.NewRows([]string{"ok"}).AddRow(true), true, ""},
+		{"false", pgxmock.NewRows([]string{"ok"}).AddRow(false), false, ""},
+		{"null", pgxmock.NewRows([]string{"ok"}).AddRow(nil), false, ""},
+		{"no rows", pgxmock.NewRows([]string{"ok"}), false, ""},
+		{"not a boolean", pgxmock.NewRows([]string{"ok"}).AddRow(int64(1)), false, "run_if_sql must return a boolean, got int64"},
+		{"several columns", pgxmock.NewRows([]string{"a", "b"}).AddRow(true, true), false, "run_if_sql must return a single boolean column, got 2 columns"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			expectGuardQuery(conn, predicate, tc.rows)
+			holds, err := metricPredicateHolds(ctx, "runif_db", predicate)
+			if tc.err > "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.holds, holds)
+		})
+	}
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
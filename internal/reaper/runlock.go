@@ -0,0 +1,132 @@
+package reaper
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/cmdopts"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/db"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/handover"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/jackc/pgx/v5"
+)
+
+// Run lock kinds, see --run-lock
+const (
+	RunLockAuto = "auto" // advisory lock in the configuration database if used, lock file otherwise
+	RunLockFile = "file"
+	RunLockOff  = "off"
+)
+
+// RunLock prevents several collectors with the same identity from running at the same time.
+// Both kinds of locks are released by the OS or Postgres if the process dies
+type RunLock interface {
+	Release()
+}
+
+type noRunLock struct{}
+
+func (noRunLock) Release() {}
+
+// advisoryRunLock is a session level advisory lock held by a dedicated configuration database connection
+type advisoryRunLock struct {
+	conn db.PgxConnIface
+}
+
+func (l *advisoryRunLock) Release() {
+	_ = l.conn.Close(context.Background())
+}
+
+// tryAdvisoryRunLock takes the advisory lock of the collector id, the connection is closed if it fails
+func tryAdvisoryRunLock(ctx context.Context, conn db.PgxConnIface, collectorID string) (RunLock, error) {
+	var locked bool
+	err := conn.QueryRow(ctx, "select pg_try_advisory_lock(hashtext('pgwatch_collector'), hashtext($1))", collectorID).Scan(&locked)
+	if err == nil && !locked {
+		err = fmt.Errorf("another pgwatch collector with the id %q is already running against the configuration database, "+
+			"set a distinct --collector-id or --run-lock=off to run both", collectorID)
+	}
+	if err != nil {
+		_ = conn.Close(ctx)
+		return nil, err
+	}
+	return &advisoryRunLock{conn: conn}, nil
+}
+
+// runLockFileName returns the lock file of the collector id in the temporary directory
+func runLockFileName(collectorID string) string {
+	return filepath.Join(os.TempDir(), "pgwatch-"+regexUnsafeTagChars.ReplaceAllString(collectorID, "_")+".lock")
+}
+
+// AcquireRunLock fails fast if another collector with the same collector id is running already. The advisory lock
+// covers collectors on any host sharing the configuration database, the lock file only the ones on this host
+func AcquireRunLock(ctx context.Context, opts *cmdopts.Options) (RunLock, error) {
+	collectorID := GetCollectorID(opts)
+	switch {
+	case opts.Metrics.RunLock == RunLockOff:
+		return noRunLock{}, nil
+	case opts.Metrics.RunLock == RunLockAuto && opts.IsPgConnStr(opts.Sources.Sources):
+		conn, err := pgx.Connect(ctx, opts.Sources.Sources)
+		if err != nil {
+			return nil, err
+		}
+		return tryAdvisoryRunLock(ctx, conn, collectorID)
+	default:
+		return lockFile(runLockFileName(collectorID), collectorID)
+	}
+}
+
+// runLockRetryInterval is the pause between the attempts to take the run lock over
+const runLockRetryInterval = 500 * time.Millisecond
+
+// TakeOverRunLock asks the running collector with the same collector id to exit and waits up to --handover-timeout
+// until it has drained and released the run lock. The collector is signalled by pid, e.g. the parent process on
+// an upgrade, or by the PID recorded in the lock file. With the advisory lock it has to be stopped by other means
+func TakeOverRunLock(ctx context.Context, opts *cmdopts.Options, pid int) (RunLock, error) {
+	logger := log.GetLogger(ctx)
+	if pid == 0 {
+		lock, err := AcquireRunLock(ctx, opts)
+		if err == nil {
+			return lock, nil // nothing to take over
+		}
+		pid = runLockHolder(opts)
+	}
+	if pid > 0 {
+		logger.WithField("pid", pid).Info("asking the running collector to hand over")
+		if err := handover.Terminate(pid); err != nil {
+			return nil, fmt.Errorf("could not signal the running collector: %w", err)
+		}
+	} else {
+		logger.Warning("waiting for the running collector to be stopped")
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Metrics.HandoverTimeout)
+	defer cancel()
+	for {
+		lock, err := AcquireRunLock(ctx, opts)
+		if err == nil {
+			return lock, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("the running collector didn't hand over within %v: %w", opts.Metrics.HandoverTimeout, err)
+		case <-time.After(runLockRetryInterval):
+		}
+	}
+}
+
+// runLockHolder returns the PID recorded in the lock file of the collector id, 0 if unknown or not a file lock
+func runLockHolder(opts *cmdopts.Options) int {
+	if opts.Metrics.RunLock == RunLockOff || opts.Metrics.RunLock == RunLockAuto && opts.IsPgConnStr(opts.Sources.Sources) {
+		return 0
+	}
+	b, err := os.ReadFile(runLockFileName(GetCollectorID(opts)))
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(b)))
+	return pid
+}
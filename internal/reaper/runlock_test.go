@@ -0,0 +1,90 @@
+package reaper
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/cmdopts"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileRunLock(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	opts := &cmdopts.Options{}
+	opts.Metrics.CollectorID = "test/collector"
+	opts.Metrics.RunLock = RunLockAuto
+	opts.Sources.Sources = "sources.yaml"
+	ctx := context.Background()
+
+	lock, err := AcquireRunLock(ctx, opts)
+	require.NoError(t, err)
+	_, err = AcquireRunLock(ctx, opts)
+	assert.ErrorContains(t, err, `collector with the id "test/collector" is already running`)
+
+	opts.Metrics.CollectorID = "other"
+	other, err := AcquireRunLock(ctx, opts)
+	assert.NoError(t, err, "distinct collector ids don't conflict")
+	other.Release()
+
+	opts.Metrics.CollectorID = "test/collector"
+	opts.Metrics.RunLock = RunLockOff
+	_, err = AcquireRunLock(ctx, opts)
+	assert.NoError(t, err)
+
+	lock.Release()
+	opts.Metrics.RunLock = RunLockFile
+	lock, err = AcquireRunLock(ctx, opts)
+	assert.NoError(t, err, "lock released")
+	lock.Release()
+}
+
+func TestAdvisoryRunLock(t *testing.T) {
+	ctx := context.Background()
+	conn, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	conn.ExpectQuery("pg_try_advisory_lock").WithArgs("collector1").WillReturnRows(pgxmock.NewRows([]string{"locked"}).AddRow(true))
+	conn.ExpectClose()
+	lock, err := tryAdvisoryRunLock(ctx, conn, "collector1")
+	require.NoError(t, err)
+	lock.Release()
+	assert.NoError(t, conn.ExpectationsWereMet())
+
+	conn, err = pgxmock.NewConn()
+	require.NoError(t, err)
+	conn.ExpectQuery("pg_try_advisory_lock").WithArgs("collector1").WillReturnRows(pgxmock.NewRows([]string{"locked"}).AddRow(false))
+	conn.ExpectClose()
+	_, err = tryAdvisoryRunLock(ctx, conn, "collector1")
+	assert.ErrorContains(t, err, "already running against the configuration database")
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
+
+func TestTakeOverRunLock(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	opts := &cmdopts.Options{}
+	opts.Metrics.CollectorID = "takeover"
+	opts.Metrics.RunLock = RunLockFile
+	opts.Metrics.HandoverTimeout = 500 * time.Millisecond
+	ctx := context.Background()
+
+	lock, err := TakeOverRunLock(ctx, opts, 0)
+	require.NoError(t, err, "nothing to take over")
+	assert.Equal(t, os.Getpid(), runLockHolder(opts))
+	// clear the recorded PID not to signal the test itself
+	require.NoError(t, os.WriteFile(runLockFileName("takeover"), nil, 0600))
+
+	_, err = TakeOverRunLock(ctx, opts, 0)
+	assert.ErrorContains(t, err, "didn't hand over within 500ms")
+
+	opts.Metrics.HandoverTimeout = 5 * time.Second
+	go func() {
+		time.Sleep(runLockRetryInterval)
+		lock.Release()
+	}()
+	lock, err = TakeOverRunLock(ctx, opts, 0)
+	require.NoError(t, err, "taken over once released")
+	lock.Release()
+}
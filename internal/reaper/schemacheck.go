@@ -0,0 +1,64 @@
+package reaper
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+// SchemaMismatch counts the fetches of a metric whose rows didn't match the declared column attributes or gauges
+type SchemaMismatch struct {
+	Source     string   `json:"source"`
+	Metric     string   `json:"metric"`
+	Fetches    int64    `json:"fetches"`
+	Mismatches []string `json:"mismatches"` // of the last mismatching fetch
+}
+
+var schemaMismatches = make(map[string]*SchemaMismatch) // [db1+metric1]=mismatch
+var schemaMismatchesLock sync.Mutex
+
+// CheckFetchedSchema validates the fetched rows against the metric definition. Mismatches are counted and
+// every new one is logged once per source and metric, so that definition drift, e.g. a column renamed in the
+// SQL of a new Postgres version, doesn't go unnoticed
+func CheckFetchedSchema(ctx context.Context, dbUnique, metricName string, mvp metrics.Metric, data metrics.Measurements) {
+	mismatches := mvp.SchemaMismatches(data)
+	if len(mismatches) == 0 {
+		return
+	}
+	key := dbUnique + dbMetricJoinStr + metricName
+	schemaMismatchesLock.Lock()
+	sm, ok := schemaMismatches[key]
+	if !ok {
+		sm = &SchemaMismatch{Source: dbUnique, Metric: metricName}
+		schemaMismatches[key] = sm
+	}
+	prev := sm.Mismatches
+	sm.Fetches++
+	sm.Mismatches = mismatches
+	schemaMismatchesLock.Unlock()
+
+	for _, m := range mismatches {
+		if !slices.Contains(prev, m) {
+			log.GetLogger(ctx).WithField("source", dbUnique).WithField("metric", metricName).
+				Warningf("fetched rows don't match the metric definition: %s", m)
+		}
+	}
+}
+
+// SchemaMismatches returns the metrics with mismatching fetched rows sorted by source and metric
+func SchemaMismatches() []SchemaMismatch {
+	schemaMismatchesLock.Lock()
+	defer schemaMismatchesLock.Unlock()
+	s := make([]SchemaMismatch, 0, len(schemaMismatches))
+	for _, sm := range schemaMismatches {
+		s = append(s, *sm)
+	}
+	slices.SortFunc(s, func(a, b SchemaMismatch) int {
+		return cmp.Or(cmp.Compare(a.Source, b.Source), cmp.Compare(a.Metric, b.Metric))
+	})
+	return s
+}
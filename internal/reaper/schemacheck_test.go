@@ -0,0 +1,40 @@
+package reaper
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func findSchemaMismatch(dbUnique, metric string) *SchemaMismatch {
+	for _, sm := range SchemaMismatches() {
+		if sm.Source == dbUnique && sm.Metric == metric {
+			return &sm
+		}
+	}
+	return nil
+}
+
+func TestCheckFetchedSchema(t *testing.T) {
+	ctx := context.Background()
+	mvp := metrics.Metric{Gauges: []string{"numbackends"}}
+	data := metrics.Measurements{{"epoch_ns": int64(1), "numbackends": 3}}
+
+	CheckFetchedSchema(ctx, "schema_src", "db_stats", mvp, data)
+	assert.Nil(t, findSchemaMismatch("schema_src", "db_stats"), "matching rows aren't counted")
+
+	mvp.Gauges = []string{"numbackends", "blks_hit"}
+	CheckFetchedSchema(ctx, "schema_src", "db_stats", mvp, data)
+	CheckFetchedSchema(ctx, "schema_src", "db_stats", mvp, data)
+	found := findSchemaMismatch("schema_src", "db_stats")
+	require.NotNil(t, found)
+	assert.EqualValues(t, 2, found.Fetches)
+	assert.Equal(t, []string{"gauge column blks_hit not fetched"}, found.Mismatches)
+
+	forgetRemovedSources(map[string]*sources.MonitoredDatabase{})
+	assert.Nil(t, findSchemaMismatch("schema_src", "db_stats"), "forgotten with the source")
+}
@@ -0,0 +1,88 @@
+package reaper
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+const specialMetricSchemaStats = "schema_stats"
+
+// schemaStatsChunkSize is the number of relations read per query, so that the sizes of huge
+// catalogs are not calculated in a single long-running statement holding the locks
+var schemaStatsChunkSize = 5000
+
+const sqlSchemaStatsChunk = `select /* pgwatch_generated */
+  c.oid::int8 as oid,
+  n.nspname::text as schema,
+  c.relkind::text as relkind,
+  case when c.relkind in ('r', 'm') then pg_table_size(c.oid) when c.relkind in ('i', 'S') then pg_relation_size(c.oid) else 0 end::int8 as size_b
+from
+  pg_class c
+  join pg_namespace n on n.oid = c.relnamespace
+where
+  c.oid > $1
+  and c.relkind in ('r', 'p', 'i', 'I', 'v', 'm', 'S')
+  and n.nspname not in ('pg_catalog', 'information_schema', 'pg_toast')
+  and n.nspname not like 'pg_temp%'
+  and n.nspname not like 'pg_toast_temp%'
+order by
+  c.oid
+limit $2`
+
+// schemaObjectColumns map the relation kinds to the counted object columns
+var schemaObjectColumns = map[string]string{
+	"r": "tables",
+	"p": "tables",
+	"i": "indexes",
+	"I": "indexes",
+	"v": "views",
+	"m": "materialized_views",
+	"S": "sequences",
+}
+
+// FetchSchemaStats returns the relation counts and sizes per schema. The catalog is read in chunks by oid
+// and aggregated client-side instead of a single giant GROUP BY over all relations
+func FetchSchemaStats(ctx context.Context, dbUnique string) (metrics.Measurements, error) {
+	epochNs := time.Now().UnixNano()
+	schemas := make(map[string]metrics.Measurement)
+	var lastOid int64
+	for {
+		data, err := DBExecReadByDbUniqueName(ctx, dbUnique, sqlSchemaStatsChunk, lastOid, schemaStatsChunkSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range data {
+			lastOid, _ = row["oid"].(int64)
+			schema, _ := row["schema"].(string)
+			relkind, _ := row["relkind"].(string)
+			size, _ := row["size_b"].(int64)
+			stats, ok := schemas[schema]
+			if !ok {
+				stats = metrics.Measurement{epochColumnName: epochNs, "tag_schema": schema, "tables": int64(0), "indexes": int64(0),
+					"views": int64(0), "materialized_views": int64(0), "sequences": int64(0),
+					"table_size_b": int64(0), "index_size_b": int64(0), "total_size_b": int64(0)}
+				schemas[schema] = stats
+			}
+			col := schemaObjectColumns[relkind]
+			stats[col] = stats[col].(int64) + 1
+			if col == "indexes" {
+				stats["index_size_b"] = stats["index_size_b"].(int64) + size
+			} else {
+				stats["table_size_b"] = stats["table_size_b"].(int64) + size
+			}
+			stats["total_size_b"] = stats["total_size_b"].(int64) + size
+		}
+		if len(data) < schemaStatsChunkSize {
+			break
+		}
+	}
+	res := make(metrics.Measurements, 0, len(schemas))
+	for _, schema := range slices.Sorted(maps.Keys(schemas)) {
+		res = append(res, schemas[schema])
+	}
+	return res, nil
+}
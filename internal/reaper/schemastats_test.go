@@ -0,0 +1,66 @@
+package reaper
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func expectSchemaStatsChunk(conn pgxmock.PgxPoolIface, lastOid int64, rows *pgxmock.Rows) {
+	conn.ExpectBegin()
+	conn.ExpectExec("SET LOCAL").WillReturnResult(pgxmock.NewResult("SET", 0))
+	conn.ExpectQuery("from pg_class").WithArgs(lastOid, schemaStatsChunkSize).WillReturnRows(rows)
+	conn.ExpectCommit()
+}
+
+func TestFetchSchemaStats(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer conn.Close()
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{{Source: sources.Source{Name: "db1", Kind: sources.SourcePostgres}, Conn: conn}})
+	defer UpdateMonitoredDBCache(nil)
+	defer func(size int) { schemaStatsChunkSize = size }(schemaStatsChunkSize)
+	schemaStatsChunkSize = 3
+
+	cols := []string{"oid", "schema", "relkind", "size_b"}
+	expectSchemaStatsChunk(conn, 0, pgxmock.NewRows(cols).
+		AddRow(int64(100), "app", "r", int64(8192)).
+		AddRow(int64(101), "app", "i", int64(4096)).
+		AddRow(int64(102), "billing", "p", int64(0)))
+	expectSchemaStatsChunk(conn, 102, pgxmock.NewRows(cols).
+		AddRow(int64(103), "app", "S", int64(100)).
+		AddRow(int64(104), "billing", "v", int64(0)))
+
+	data, err := FetchSchemaStats(context.Background(), "db1")
+	require.NoError(t, err)
+	assert.NoError(t, conn.ExpectationsWereMet())
+	require.Len(t, data, 2)
+	assert.Equal(t, "app", data[0]["tag_schema"])
+	assert.Equal(t, int64(1), data[0]["tables"])
+	assert.Equal(t, int64(1), data[0]["indexes"])
+	assert.Equal(t, int64(1), data[0]["sequences"])
+	assert.Equal(t, int64(8292), data[0]["table_size_b"])
+	assert.Equal(t, int64(4096), data[0]["index_size_b"])
+	assert.Equal(t, int64(12388), data[0]["total_size_b"])
+	assert.Equal(t, "billing", data[1]["tag_schema"])
+	assert.Equal(t, int64(1), data[1]["tables"])
+	assert.Equal(t, int64(1), data[1]["views"])
+	assert.Equal(t, int64(0), data[1]["total_size_b"])
+	assert.Equal(t, data[0][epochColumnName], data[1][epochColumnName])
+
+	expectSchemaStatsChunk(conn, 0, pgxmock.NewRows(cols))
+	data, err = FetchSchemaStats(context.Background(), "db1")
+	assert.NoError(t, err)
+	assert.Empty(t, data)
+
+	conn.ExpectBegin()
+	conn.ExpectExec("SET LOCAL").WillReturnResult(pgxmock.NewResult("SET", 0))
+	conn.ExpectQuery("from pg_class").WithArgs(int64(0), schemaStatsChunkSize).WillReturnError(assert.AnError)
+	conn.ExpectCommit()
+	_, err = FetchSchemaStats(context.Background(), "db1")
+	assert.ErrorIs(t, err, assert.AnError)
+}
@@ -0,0 +1,179 @@
+package reaper
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sinks"
+)
+
+// GathererStatus describes a running per source/per metric gatherer
+type GathererStatus struct {
+	Source        string    `json:"source"`
+	Metric        string    `json:"metric"`
+	Started       time.Time `json:"started"`
+	LastFetch     time.Time `json:"last_fetch"`
+	LastError     string    `json:"last_error,omitempty"`
+	FailedFetches int       `json:"failed_fetches"`
+}
+
+var gathererStatuses = make(map[string]*GathererStatus) // [db1+metric1]=status
+var gathererStatusesLock sync.Mutex
+
+// startGathererStatus registers a running gatherer, the returned status is updated by the gatherer itself
+func startGathererStatus(dbUnique, metric string) *GathererStatus {
+	gathererStatusesLock.Lock()
+	defer gathererStatusesLock.Unlock()
+	s := &GathererStatus{Source: dbUnique, Metric: metric, Started: time.Now()}
+	gathererStatuses[dbUnique+dbMetricJoinStr+metric] = s
+	return s
+}
+
+// stop removes the gatherer status unless the gatherer has already been restarted
+func (s *GathererStatus) stop() {
+	gathererStatusesLock.Lock()
+	defer gathererStatusesLock.Unlock()
+	key := s.Source + dbMetricJoinStr + s.Metric
+	if gathererStatuses[key] == s {
+		delete(gathererStatuses, key)
+	}
+}
+
+// update records the outcome of a single fetch of the gatherer
+func (s *GathererStatus) update(err error) {
+	gathererStatusesLock.Lock()
+	defer gathererStatusesLock.Unlock()
+	s.LastFetch = time.Now()
+	if err != nil {
+		s.LastError = err.Error()
+		s.FailedFetches++
+	} else {
+		s.LastError = ""
+	}
+}
+
+// State is a snapshot of the reaper internals used for troubleshooting. Its JSON returned by the /stats REST API
+// is frozen, new stats are added to StatsV2 only
+type State struct {
+	Goroutines          int                 `json:"goroutines"`
+	Gatherers           []GathererStatus    `json:"gatherers"`
+	MeasurementQueue    int                 `json:"measurement_queue"`
+	MeasurementQueueCap int                 `json:"measurement_queue_cap"`
+	MonitoredDBCache    int                 `json:"monitored_db_cache"`
+	InstanceMetricCache int                 `json:"instance_metric_cache"`
+	MetricDefs          int                 `json:"metric_defs"`
+	PresetDefs          int                 `json:"preset_defs"`
+	Sinks               []sinks.SinkHealth  `json:"sinks"`
+	Subscribers         []SubscriptionStats `json:"subscribers"`
+	FetchLimits         []HostFetchLimit    `json:"fetch_limits"`
+	SchemaMismatches    []SchemaMismatch    `json:"schema_mismatches"`
+	Update              *UpdateAdvisory     `json:"update,omitempty"`
+}
+
+// State() returns the current state of the reaper internals
+func (r *Reaper) State() State {
+	s := State{
+		Goroutines:          runtime.NumGoroutine(),
+		MeasurementQueue:    len(r.measurementCh),
+		MeasurementQueueCap: cap(r.measurementCh),
+		Subscribers:         r.bus.Stats(),
+		FetchLimits:         HostFetchLimits(),
+		SchemaMismatches:    SchemaMismatches(),
+	}
+
+	gathererStatusesLock.Lock()
+	s.Gatherers = make([]GathererStatus, 0, len(gathererStatuses))
+	for _, gs := range gathererStatuses {
+		s.Gatherers = append(s.Gatherers, *gs)
+	}
+	gathererStatusesLock.Unlock()
+	slices.SortFunc(s.Gatherers, func(a, b GathererStatus) int {
+		return cmp.Or(cmp.Compare(a.Source, b.Source), cmp.Compare(a.Metric, b.Metric))
+	})
+
+	monitoredDbCacheLock.RLock()
+	s.MonitoredDBCache = len(monitoredDbCache)
+	monitoredDbCacheLock.RUnlock()
+
+	instanceMetricCacheLock.RLock()
+	s.InstanceMetricCache = len(instanceMetricCache)
+	instanceMetricCacheLock.RUnlock()
+
+	metricDefMapLock.RLock()
+	s.MetricDefs = len(metricDefinitionMap.MetricDefs)
+	s.PresetDefs = len(metricDefinitionMap.PresetDefs)
+	metricDefMapLock.RUnlock()
+
+	if mw := r.measurementsWriter.Load(); mw != nil {
+		s.Sinks = mw.Health()
+	}
+	s.Update = r.updateAdvisory.Load()
+	return s
+}
+
+// Stats() returns the state of the reaper internals and the sinks health for the REST API
+func (r *Reaper) Stats() any {
+	return r.State()
+}
+
+// LogState() writes the current state of the reaper internals to the log
+func (r *Reaper) LogState(ctx context.Context) {
+	s := r.State()
+	logger := log.GetLogger(ctx)
+	for _, gs := range s.Gatherers {
+		logger.WithField("source", gs.Source).
+			WithField("metric", gs.Metric).
+			WithField("started", gs.Started).
+			WithField("last_fetch", gs.LastFetch).
+			WithField("last_error", gs.LastError).
+			WithField("failed_fetches", gs.FailedFetches).
+			Info("gatherer state")
+	}
+	for _, sh := range s.Sinks {
+		logger.WithField("sink", sh.Sink).
+			WithField("healthy", sh.Healthy).
+			WithField("last_error", sh.LastError).
+			WithField("write_timeouts", sh.WriteTimeouts).
+			WithField("dropped_writes", sh.DroppedWrites).
+			Info("sink state")
+	}
+	for _, ss := range s.Subscribers {
+		logger.WithField("subscriber", ss.Name).
+			WithField("queue", fmt.Sprintf("%d/%d", ss.Queue, ss.QueueCap)).
+			WithField("received", ss.Received).
+			WithField("dropped", ss.Dropped).
+			Info("bus subscriber state")
+	}
+	for _, fl := range s.FetchLimits {
+		logger.WithField("host", fl.Host).
+			WithField("in_flight", fmt.Sprintf("%d/%d", fl.InFlight, fl.Limit)).
+			WithField("queued", fl.Queued).
+			WithField("waits", fl.Waits).
+			WithField("skipped", fl.Skipped).
+			WithField("max_wait", fl.MaxWait).
+			WithField("conn_limit_hits", fl.ConnLimitHits).
+			WithField("backoff_until", fl.BackoffUntil).
+			Info("host fetch limit state")
+	}
+	for _, sm := range s.SchemaMismatches {
+		logger.WithField("source", sm.Source).
+			WithField("metric", sm.Metric).
+			WithField("fetches", sm.Fetches).
+			WithField("mismatches", sm.Mismatches).
+			Info("schema mismatch state")
+	}
+	logger.WithField("goroutines", s.Goroutines).
+		WithField("gatherers", len(s.Gatherers)).
+		WithField("measurement_queue", fmt.Sprintf("%d/%d", s.MeasurementQueue, s.MeasurementQueueCap)).
+		WithField("monitored_db_cache", s.MonitoredDBCache).
+		WithField("instance_metric_cache", s.InstanceMetricCache).
+		WithField("metric_defs", s.MetricDefs).
+		WithField("preset_defs", s.PresetDefs).
+		Info("reaper state")
+}
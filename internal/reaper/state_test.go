@@ -0,0 +1,73 @@
+package reaper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/cmdopts"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sinks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestState(t *testing.T) {
+	r := NewReaper(&cmdopts.Options{}, nil, nil)
+	r.measurementCh <- []metrics.MeasurementEnvelope{{DBName: "db1"}}
+
+	s1 := startGathererStatus("db2", "wal")
+	s2 := startGathererStatus("db1", "db_stats")
+	s2.update(errors.New("connection refused"))
+	s2.update(errors.New("connection refused"))
+
+	s := r.State()
+	assert.Positive(t, s.Goroutines)
+	assert.Equal(t, 1, s.MeasurementQueue)
+	assert.Equal(t, 10000, s.MeasurementQueueCap)
+	if assert.Len(t, s.Gatherers, 2) {
+		assert.Equal(t, "db1", s.Gatherers[0].Source, "gatherers are sorted")
+		assert.Equal(t, "connection refused", s.Gatherers[0].LastError)
+		assert.Equal(t, 2, s.Gatherers[0].FailedFetches)
+		assert.Equal(t, "wal", s.Gatherers[1].Metric)
+	}
+	assert.Nil(t, s.Sinks, "no sinks before reaping")
+	mw := &sinks.MultiWriter{}
+	mw.AddWriter(&sinks.JSONWriter{})
+	r.measurementsWriter.Store(mw)
+	if s = r.Stats().(State); assert.Len(t, s.Sinks, 1) {
+		assert.True(t, s.Sinks[0].Healthy)
+	}
+	r.LogState(context.Background())
+
+	s2.update(nil)
+	assert.Empty(t, r.State().Gatherers[0].LastError)
+
+	restarted := startGathererStatus("db2", "wal")
+	s1.stop()
+	assert.Len(t, r.State().Gatherers, 2, "stopping a replaced gatherer keeps the new status")
+	restarted.stop()
+	s2.stop()
+	assert.Empty(t, r.State().Gatherers)
+}
+
+func TestStatsV2(t *testing.T) {
+	r := NewReaper(&cmdopts.Options{Version: "3.1.0", StatsWindow: 5 * time.Minute}, nil, nil)
+	SetDBUnreachableState("v2_unreachable")
+	defer ClearDBUnreachableStateIfAny("v2_unreachable")
+
+	s := r.StatsV2().(StatsV2)
+	assert.Equal(t, StatsSchemaVersion, s.SchemaVersion)
+	assert.Equal(t, "3.1.0", s.Collector.Version)
+	assert.False(t, s.Collector.Ready)
+	assert.Equal(t, 10000, s.Collector.MeasurementQueueCap)
+	assert.Contains(t, s.Discovery.Unreachable, "v2_unreachable")
+
+	b, err := json.Marshal(s)
+	require.NoError(t, err)
+	for _, list := range []string{`"sinks":[]`, `"subscribers":[]`, `"update":null`, `"window_seconds":300`} {
+		assert.Contains(t, string(b), list, "lists are never null")
+	}
+}
@@ -0,0 +1,55 @@
+package reaper
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+const (
+	statsResetColumn = "stats_reset"  // added to rows fetched right after a counter reset
+	lastResetColumn  = "last_reset_s" // seconds since the stats_reset timestamp of the statistics view
+)
+
+// single row cumulative statistics metrics where counter resets are detected
+var statsResetMetrics = map[string]bool{
+	"db_stats":     true,
+	"bgwriter":     true,
+	"checkpointer": true,
+}
+
+// StatsResetDetector compares consecutive fetches of a single row statistics metric to detect counter resets
+type StatsResetDetector struct {
+	prev metrics.Measurement
+}
+
+// Detect returns true if the statistics were reset since the previous fetch,
+// i.e. the stats_reset timestamp moved or some counter decreased
+func (d *StatsResetDetector) Detect(row metrics.Measurement, gauges []string) bool {
+	prev := d.prev
+	d.prev = maps.Clone(row)
+	if prev == nil {
+		return false
+	}
+	cur, curOk := metrics.ToFloat(row[lastResetColumn])
+	last, lastOk := metrics.ToFloat(prev[lastResetColumn])
+	if curOk && (!lastOk || cur < last) { // first ever reset sets stats_reset from NULL
+		return true
+	}
+	if slices.Contains(gauges, "*") {
+		return false
+	}
+	for col, v := range row {
+		if col == epochColumnName || col == lastResetColumn || strings.HasPrefix(col, "tag_") || slices.Contains(gauges, col) {
+			continue
+		}
+		cur, curOk := metrics.ToFloat(v)
+		last, lastOk := metrics.ToFloat(prev[col])
+		if curOk && lastOk && cur < last {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package reaper
+
+import (
+	"testing"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsResetDetector(t *testing.T) {
+	var d StatsResetDetector
+	gauges := []string{"numbackends"}
+
+	assert.False(t, d.Detect(metrics.Measurement{"xact_commit": int64(100), "numbackends": int64(10)}, gauges), "nothing to compare on first fetch")
+	assert.False(t, d.Detect(metrics.Measurement{"xact_commit": int64(150), "numbackends": int64(5)}, gauges), "gauges can decrease")
+	assert.True(t, d.Detect(metrics.Measurement{"xact_commit": int64(10), "numbackends": int64(5)}, gauges), "counter decreased")
+	assert.False(t, d.Detect(metrics.Measurement{"xact_commit": int64(20), "tag_sys_id": "1"}, gauges))
+
+	assert.True(t, d.Detect(metrics.Measurement{"xact_commit": int64(30), lastResetColumn: int32(100)}, gauges), "stats_reset was NULL before")
+	assert.False(t, d.Detect(metrics.Measurement{"xact_commit": int64(40), lastResetColumn: int32(160)}, gauges))
+	assert.True(t, d.Detect(metrics.Measurement{"xact_commit": int64(45), lastResetColumn: int32(5)}, gauges), "stats_reset moved")
+
+	assert.False(t, d.Detect(metrics.Measurement{"xact_commit": int64(1), lastResetColumn: int32(65)}, []string{"*"}), "no counters to check")
+
+	dbStats := metrics.GetDefaultMetrics().MetricDefs["db_stats"]
+	d = StatsResetDetector{}
+	assert.False(t, d.Detect(metrics.Measurement{"xact_commit": int64(100), "invalid_indexes": int64(2), "in_recovery_int": 1}, dbStats.Gauges))
+	assert.False(t, d.Detect(metrics.Measurement{"xact_commit": int64(110), "invalid_indexes": int64(0), "in_recovery_int": 0}, dbStats.Gauges),
+		"index rebuild and promotion are not resets")
+}
@@ -0,0 +1,134 @@
+package reaper
+
+import (
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sinks"
+)
+
+// StatsSchemaVersion is the version of the StatsV2 schema
+const StatsSchemaVersion = 2
+
+// StatsV2 is the documented schema of the /v2/stats REST API. It's stable across upgrades: fields incl. the ones
+// of the nested types are never removed, renamed or retyped, new fields may be added. Lists are never null.
+// The /stats REST API keeps returning the frozen State
+type StatsV2 struct {
+	SchemaVersion int                `json:"schema_version"`
+	GeneratedAt   time.Time          `json:"generated_at"`
+	Collector     CollectorStatsV2   `json:"collector"`
+	Fetchers      FetcherStatsV2     `json:"fetchers"`
+	Sinks         []sinks.SinkHealth `json:"sinks"`
+	Caches        CacheStatsV2       `json:"caches"`
+	Discovery     DiscoveryStatsV2   `json:"discovery"`
+	Update        *UpdateAdvisory    `json:"update"` // null unless --update-check is set
+	Throughput    ThroughputStatsV2  `json:"throughput"`
+}
+
+// CollectorStatsV2 describes the collector process
+type CollectorStatsV2 struct {
+	Version             string              `json:"version"`
+	CollectorID         string              `json:"collector_id"`
+	Ready               bool                `json:"ready"`
+	Goroutines          int                 `json:"goroutines"`
+	MeasurementQueue    int                 `json:"measurement_queue"`
+	MeasurementQueueCap int                 `json:"measurement_queue_cap"`
+	Subscribers         []SubscriptionStats `json:"subscribers"` // consumers of the measurement bus
+}
+
+// FetcherStatsV2 describes the gathering of the metrics
+type FetcherStatsV2 struct {
+	Gatherers        []GathererStatus `json:"gatherers"`
+	FetchLimits      []HostFetchLimit `json:"fetch_limits"`
+	SchemaMismatches []SchemaMismatch `json:"schema_mismatches"`
+}
+
+// CacheStatsV2 are the sizes of the in-memory caches
+type CacheStatsV2 struct {
+	MonitoredDBs    int `json:"monitored_dbs"`
+	InstanceMetrics int `json:"instance_metrics"`
+	MetricDefs      int `json:"metric_defs"`
+	PresetDefs      int `json:"preset_defs"`
+}
+
+// DiscoveryStatsV2 describes the databases resolved from the configured sources
+type DiscoveryStatsV2 struct {
+	DatabasesByKind map[string]int `json:"databases_by_kind"` // monitored databases per source kind
+	Unreachable     []string       `json:"unreachable"`       // databases whose last fetch failed to connect
+	Dormant         []string       `json:"dormant"`           // databases not gathered due to their size or recovery state
+}
+
+// ThroughputStatsV2 is the average collector throughput of the --stats-window, zero rates and time
+// in the first minute after the start
+type ThroughputStatsV2 struct {
+	WindowSeconds int `json:"window_seconds"`
+	ThroughputSample
+}
+
+// orEmpty returns an empty slice instead of nil, so that lists are serialized as [] instead of null
+func orEmpty[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
+
+// StatsV2 returns the state of the collector internals in the versioned schema for the REST API
+func (r *Reaper) StatsV2() any {
+	s := r.State()
+	v2 := StatsV2{
+		SchemaVersion: StatsSchemaVersion,
+		GeneratedAt:   time.Now(),
+		Collector: CollectorStatsV2{
+			Version:             r.opts.Version,
+			CollectorID:         GetCollectorID(r.opts),
+			Ready:               r.Ready(),
+			Goroutines:          s.Goroutines,
+			MeasurementQueue:    s.MeasurementQueue,
+			MeasurementQueueCap: s.MeasurementQueueCap,
+			Subscribers:         orEmpty(s.Subscribers),
+		},
+		Fetchers: FetcherStatsV2{
+			Gatherers:        orEmpty(s.Gatherers),
+			FetchLimits:      orEmpty(s.FetchLimits),
+			SchemaMismatches: orEmpty(s.SchemaMismatches),
+		},
+		Sinks: orEmpty(s.Sinks),
+		Caches: CacheStatsV2{
+			MonitoredDBs:    s.MonitoredDBCache,
+			InstanceMetrics: s.InstanceMetricCache,
+			MetricDefs:      s.MetricDefs,
+			PresetDefs:      s.PresetDefs,
+		},
+		Discovery: DiscoveryStatsV2{DatabasesByKind: make(map[string]int)},
+		Update:    s.Update,
+		Throughput: ThroughputStatsV2{
+			WindowSeconds:    int(r.opts.StatsWindow.Seconds()),
+			ThroughputSample: r.throughput.Summary(r.opts.StatsWindow),
+		},
+	}
+
+	monitoredDbCacheLock.RLock()
+	for _, md := range monitoredDbCache {
+		v2.Discovery.DatabasesByKind[string(md.Kind)]++
+	}
+	monitoredDbCacheLock.RUnlock()
+
+	unreachableDBsLock.RLock()
+	v2.Discovery.Unreachable = slices.Sorted(maps.Keys(unreachableDB))
+	unreachableDBsLock.RUnlock()
+
+	dormancyStatesLock.RLock()
+	for dbUnique, ds := range dormancyStates {
+		if ds.Dormant() {
+			v2.Discovery.Dormant = append(v2.Discovery.Dormant, dbUnique)
+		}
+	}
+	dormancyStatesLock.RUnlock()
+	slices.Sort(v2.Discovery.Dormant)
+
+	v2.Discovery.Unreachable = orEmpty(v2.Discovery.Unreachable)
+	v2.Discovery.Dormant = orEmpty(v2.Discovery.Dormant)
+	return v2
+}
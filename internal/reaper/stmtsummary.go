@@ -0,0 +1,67 @@
+package reaper
+
+import (
+	"context"
+	"math"
+	"slices"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/cmdopts"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+const (
+	specialMetricStmtSummary = "stmt_summary"
+	stmtSummarySourceMetric  = "stat_statements_no_query_text" // no query texts needed for the summary
+)
+
+// stmtSummaryPercentiles are the percentiles of the mean execution time of the queries stored as mean_time_pNN
+var stmtSummaryPercentiles = map[string]float64{"mean_time_p50": 0.5, "mean_time_p95": 0.95, "mean_time_p99": 0.99}
+
+// percentile returns the nearest-rank percentile of the sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// SummarizeStatements returns a single row distribution summary of the pg_stat_statements rows: the number of queries,
+// total calls and time and the percentiles of the mean execution time of the queries in milliseconds
+func SummarizeStatements(data metrics.Measurements) metrics.Measurements {
+	var calls, totalTime float64
+	means := make([]float64, 0, len(data))
+	for _, row := range data {
+		c, okCalls := metrics.ToFloat(row["calls"])
+		t, okTime := metrics.ToFloat(row["total_time"])
+		if !okCalls || !okTime || c <= 0 {
+			continue
+		}
+		calls += c
+		totalTime += t
+		means = append(means, t/c)
+	}
+	if len(means) == 0 {
+		return nil
+	}
+	slices.Sort(means)
+	summary := metrics.Measurement{
+		epochColumnName: data[0][epochColumnName],
+		"queries":       int64(len(means)),
+		"calls":         int64(calls),
+		"total_time":    totalTime,
+	}
+	for col, p := range stmtSummaryPercentiles {
+		summary[col] = percentile(means, p)
+	}
+	return metrics.Measurements{summary}
+}
+
+// FetchStmtSummary fetches the pg_stat_statements data and returns its summary only, so that the per query
+// cardinality is not stored, e.g. in Prometheus
+func FetchStmtSummary(ctx context.Context, msg MetricFetchConfig, hostState map[string]map[string]string,
+	storageCh chan<- []metrics.MeasurementEnvelope, context string, opts *cmdopts.Options) (metrics.Measurements, error) {
+	msg.MetricName = stmtSummarySourceMetric
+	envelopes, err := FetchMetrics(ctx, msg, hostState, storageCh, context, opts)
+	if err != nil || len(envelopes) == 0 {
+		return nil, err
+	}
+	return SummarizeStatements(envelopes[0].Data), nil
+}
@@ -0,0 +1,33 @@
+package reaper
+
+import (
+	"testing"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarizeStatements(t *testing.T) {
+	assert.Nil(t, SummarizeStatements(nil))
+	assert.Nil(t, SummarizeStatements(metrics.Measurements{{"calls": int64(0), "total_time": 0.0}}))
+
+	data := metrics.Measurements{}
+	for i := 1; i <= 100; i++ {
+		data = append(data, metrics.Measurement{epochColumnName: int64(1e9), "tag_queryid": i, "calls": int64(10), "total_time": float64(10 * i)})
+	}
+	data = append(data, metrics.Measurement{epochColumnName: int64(1e9), "tag_queryid": "no-stats"})
+	summary := SummarizeStatements(data)
+	assert.Equal(t, metrics.Measurements{{
+		epochColumnName: int64(1e9),
+		"queries":       int64(100),
+		"calls":         int64(1000),
+		"total_time":    50500.0,
+		"mean_time_p50": 50.0,
+		"mean_time_p95": 95.0,
+		"mean_time_p99": 99.0,
+	}}, summary)
+
+	summary = SummarizeStatements(metrics.Measurements{{"calls": int64(4), "total_time": 2.0}})
+	assert.Equal(t, 0.5, summary[0]["mean_time_p50"])
+	assert.Equal(t, 0.5, summary[0]["mean_time_p99"], "single query")
+}
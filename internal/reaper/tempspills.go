@@ -0,0 +1,78 @@
+package reaper
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+const specialMetricTempSpills = "temp_spills"
+
+const sqlTempSpillCounters = `select /* pgwatch_generated */
+  temp_files::int8 as temp_files,
+  temp_bytes::int8 as temp_bytes
+from
+  pg_stat_database
+where
+  datname = current_database()`
+
+// tempSpillsUnattributed is the query id of the spill volume not matched to any logged query
+const tempSpillsUnattributed = "unattributed"
+
+var tempSpillCounters = make(map[string]metrics.TempFileUsage) // [db1]=pg_stat_database counters at the last fetch
+var tempSpillCountersLock sync.Mutex
+
+// FetchTempSpills returns the temporary file volume written since the previous fetch. The pg_stat_database
+// delta is attributed to query ids using the temporary files logged in the meantime (requires log parsing and
+// log_temp_files), the rest is reported with the "unattributed" query id
+func FetchTempSpills(ctx context.Context, dbUnique string) (metrics.Measurements, error) {
+	data, err := DBExecReadByDbUniqueName(ctx, dbUnique, sqlTempSpillCounters)
+	logged := metrics.DrainTempFiles(dbUnique)
+	if err != nil || len(data) == 0 {
+		return nil, err
+	}
+	var curr metrics.TempFileUsage
+	curr.Files, _ = data[0]["temp_files"].(int64)
+	curr.Bytes, _ = data[0]["temp_bytes"].(int64)
+
+	tempSpillCountersLock.Lock()
+	prev, ok := tempSpillCounters[dbUnique]
+	tempSpillCounters[dbUnique] = curr
+	tempSpillCountersLock.Unlock()
+	if !ok || curr.Files < prev.Files || curr.Bytes < prev.Bytes { // first fetch or stats reset
+		return nil, nil
+	}
+
+	epochNs := time.Now().UnixNano()
+	deltaBytes := curr.Bytes - prev.Bytes
+	unattributed := metrics.TempFileUsage{Files: curr.Files - prev.Files, Bytes: deltaBytes}
+	res := make(metrics.Measurements, 0, len(logged)+1)
+	newRow := func(queryID string, usage metrics.TempFileUsage) metrics.Measurement {
+		row := metrics.Measurement{
+			epochColumnName: epochNs,
+			"tag_queryid":   queryID,
+			"temp_files":    usage.Files,
+			"temp_bytes":    usage.Bytes,
+		}
+		if deltaBytes > 0 {
+			row["share_pct"] = 100 * float64(usage.Bytes) / float64(deltaBytes)
+		}
+		return row
+	}
+	for _, queryID := range slices.Sorted(maps.Keys(logged)) {
+		if queryID == "" {
+			continue
+		}
+		usage := logged[queryID]
+		res = append(res, newRow(queryID, usage))
+		unattributed.Files -= usage.Files
+		unattributed.Bytes -= usage.Bytes
+	}
+	unattributed.Files = max(unattributed.Files, 0)
+	unattributed.Bytes = max(unattributed.Bytes, 0)
+	return append(res, newRow(tempSpillsUnattributed, unattributed)), nil
+}
@@ -0,0 +1,57 @@
+package reaper
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchTempSpills(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer conn.Close()
+	UpdateMonitoredDBCache(sources.MonitoredDatabases{{Source: sources.Source{Name: "db1", Kind: sources.SourcePostgres}, Conn: conn}})
+	defer UpdateMonitoredDBCache(nil)
+	ctx := context.Background()
+	cols := []string{"temp_files", "temp_bytes"}
+
+	metrics.RecordTempFile("db1", "42", 100)
+	expectGuardQuery(conn, "from pg_stat_database", pgxmock.NewRows(cols).AddRow(int64(10), int64(1000)))
+	data, err := FetchTempSpills(ctx, "db1")
+	require.NoError(t, err)
+	assert.Empty(t, data, "no delta on the first fetch")
+	assert.Nil(t, metrics.DrainTempFiles("db1"), "files logged before the first fetch are dropped")
+
+	metrics.RecordTempFile("db1", "42", 300)
+	metrics.RecordTempFile("db1", "42", 100)
+	metrics.RecordTempFile("db1", "7", 100)
+	metrics.RecordTempFile("db1", "", 50)
+	expectGuardQuery(conn, "from pg_stat_database", pgxmock.NewRows(cols).AddRow(int64(15), int64(2000)))
+	data, err = FetchTempSpills(ctx, "db1")
+	require.NoError(t, err)
+	require.Len(t, data, 3)
+	assert.Equal(t, "42", data[0]["tag_queryid"])
+	assert.Equal(t, int64(2), data[0]["temp_files"])
+	assert.Equal(t, int64(400), data[0]["temp_bytes"])
+	assert.InDelta(t, 40.0, data[0]["share_pct"], 1e-9)
+	assert.Equal(t, "7", data[1]["tag_queryid"])
+	assert.Equal(t, tempSpillsUnattributed, data[2]["tag_queryid"])
+	assert.Equal(t, int64(2), data[2]["temp_files"])
+	assert.Equal(t, int64(500), data[2]["temp_bytes"], "includes the files logged without query id")
+
+	expectGuardQuery(conn, "from pg_stat_database", pgxmock.NewRows(cols).AddRow(int64(1), int64(100)))
+	data, err = FetchTempSpills(ctx, "db1")
+	require.NoError(t, err)
+	assert.Empty(t, data, "no delta after a stats reset")
+	assert.NoError(t, conn.ExpectationsWereMet())
+
+	UpdateMonitoredDBCache(nil)
+	tempSpillCountersLock.Lock()
+	assert.NotContains(t, tempSpillCounters, "db1", "state of removed sources is dropped")
+	tempSpillCountersLock.Unlock()
+}
@@ -0,0 +1,284 @@
+package reaper
+
+// This file implements the test data generation mode (--testdata-days). Every configured
+// metric is fetched once from every monitored source and the result is then replayed back
+// in time, shaped by a workload profile and a per column jitter, to any configured sink.
+// The main usage is storage sizing and dashboard performance testing.
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+const (
+	TestdataProfileSteady  = "steady"  // constant load
+	TestdataProfileDiurnal = "diurnal" // sine shaped load with a peak at noon and a valley at midnight
+	TestdataProfileBursty  = "bursty"  // constant load with occasional multi-interval bursts
+	TestdataProfileSpiky   = "spiky"   // single interval spikes and failure injection, i.e. gaps in data
+
+	testdataCounterGrowth = 0.001 // relative growth of a counter value per interval at load factor 1
+	testdataMaxBatchRows  = 1000  // max rows in a single measurement envelope sent to sinks
+)
+
+// WorkloadProfile returns the load factor for the given interval. If ok is false
+// the interval should be skipped simulating a failed fetch.
+type WorkloadProfile func(t time.Time) (factor float64, ok bool)
+
+// NewWorkloadProfile returns a workload profile by its name. Stateful profiles
+// (bursty, spiky) should not be shared between sources.
+func NewWorkloadProfile(name string, rnd *rand.Rand) (WorkloadProfile, error) {
+	switch name {
+	case TestdataProfileSteady, "":
+		return func(time.Time) (float64, bool) { return 1, true }, nil
+	case TestdataProfileDiurnal:
+		return func(t time.Time) (float64, bool) {
+			dayFraction := float64(t.Hour()*3600+t.Minute()*60+t.Second()) / 86400
+			return 1 - 0.6*math.Cos(2*math.Pi*dayFraction), true
+		}, nil
+	case TestdataProfileBursty:
+		var burstLeft int
+		var burstFactor float64
+		return func(time.Time) (float64, bool) {
+			if burstLeft == 0 && rnd.Float64() < 0.02 {
+				burstLeft = 3 + rnd.IntN(10)
+				burstFactor = 2 + 3*rnd.Float64()
+			}
+			if burstLeft > 0 {
+				burstLeft--
+				return burstFactor, true
+			}
+			return 1, true
+		}, nil
+	case TestdataProfileSpiky:
+		var failureLeft int
+		return func(time.Time) (float64, bool) {
+			if failureLeft > 0 {
+				failureLeft--
+				return 0, false
+			}
+			switch r := rnd.Float64(); {
+			case r < 0.005:
+				failureLeft = rnd.IntN(20)
+				return 0, false
+			case r < 0.03:
+				return 5 + 5*rnd.Float64(), true
+			}
+			return 1, true
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown test data workload profile: %s", name)
+}
+
+// TestdataGenerator produces measurements for a single source metric based on a sample fetch
+type TestdataGenerator struct {
+	Sample   metrics.MeasurementEnvelope
+	Interval time.Duration
+	Profile  WorkloadProfile
+	Jitter   float64 // max deviation in percent
+	rnd      *rand.Rand
+	counters map[string]float64 // accumulated load per row and column for counter values
+}
+
+func NewTestdataGenerator(sample metrics.MeasurementEnvelope, interval time.Duration, profile WorkloadProfile, jitter float64, rnd *rand.Rand) *TestdataGenerator {
+	return &TestdataGenerator{
+		Sample:   sample,
+		Interval: interval,
+		Profile:  profile,
+		Jitter:   jitter,
+		rnd:      rnd,
+		counters: make(map[string]float64),
+	}
+}
+
+func (g *TestdataGenerator) isGauge(column string) bool {
+	gauges := g.Sample.MetricDef.Gauges
+	return len(gauges) > 0 && (gauges[0] == "*" || slices.Contains(gauges, column))
+}
+
+func (g *TestdataGenerator) jitter(v float64) float64 {
+	if g.Jitter <= 0 {
+		return v
+	}
+	return v * (1 + (g.rnd.Float64()*2-1)*g.Jitter/100)
+}
+
+// value returns the generated value keeping the original data type.
+// Non-numeric values are returned unchanged.
+func (g *TestdataGenerator) value(rowIdx int, column string, v any, factor float64) any {
+	var base float64
+	switch n := v.(type) {
+	case int64:
+		base = float64(n)
+	case int32:
+		base = float64(n)
+	case int:
+		base = float64(n)
+	case float64:
+		base = n
+	case float32:
+		base = float64(n)
+	default:
+		return v
+	}
+	var gen float64
+	if g.isGauge(column) {
+		gen = g.jitter(base * factor)
+	} else { // counters should never decrease
+		key := fmt.Sprintf("%d%s%s", rowIdx, dbMetricJoinStr, column)
+		g.counters[key] += math.Max(g.jitter(factor), 0)
+		gen = base + math.Max(math.Abs(base)*testdataCounterGrowth, 1)*g.counters[key]
+	}
+	switch v.(type) {
+	case int64:
+		return int64(math.Round(gen))
+	case int32:
+		return int32(math.Round(gen))
+	case int:
+		return int(math.Round(gen))
+	}
+	return gen
+}
+
+// Rows returns generated measurements for the given point in time or nil
+// if the workload profile decided to skip the interval
+func (g *TestdataGenerator) Rows(t time.Time) metrics.Measurements {
+	factor, ok := g.Profile(t)
+	if !ok {
+		return nil
+	}
+	rows := make(metrics.Measurements, 0, len(g.Sample.Data))
+	for i, sampleRow := range g.Sample.Data {
+		row := make(metrics.Measurement, len(sampleRow))
+		for k, v := range sampleRow {
+			if k == epochColumnName || strings.HasPrefix(k, tagPrefix) {
+				row[k] = v
+				continue
+			}
+			row[k] = g.value(i, k, v, factor)
+		}
+		row[epochColumnName] = t.UnixNano()
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// Generate sends generated measurements in the [from, to) time range to the storage channel
+func (g *TestdataGenerator) Generate(ctx context.Context, dbUnique string, from, to time.Time, storageCh chan<- []metrics.MeasurementEnvelope) (rowsTotal int, err error) {
+	batch := make(metrics.Measurements, 0, testdataMaxBatchRows)
+	send := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		msg := g.Sample
+		msg.DBName = dbUnique
+		msg.Data = batch
+		select {
+		case storageCh <- []metrics.MeasurementEnvelope{msg}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		rowsTotal += len(batch)
+		batch = make(metrics.Measurements, 0, testdataMaxBatchRows)
+		return nil
+	}
+	for t := from; t.Before(to); t = t.Add(g.Interval) {
+		batch = append(batch, g.Rows(t)...)
+		if len(batch) >= testdataMaxBatchRows {
+			if err = send(); err != nil {
+				return
+			}
+		}
+	}
+	err = send()
+	return
+}
+
+// GenerateTestData fetches every configured metric once for every monitored source
+// and generates measurements for the last --testdata-days days
+func (r *Reaper) GenerateTestData(ctx context.Context) (err error) {
+	opts := r.opts.Metrics
+	logger := log.GetLogger(ctx)
+	ctx = WithQueryOrigin(ctx, "testdata", "")
+	rnd := rand.New(rand.NewPCG(uint64(time.Now().UnixNano()), 0))
+	to := time.Now()
+	from := to.Add(-time.Hour * 24 * time.Duration(opts.TestdataDays))
+	totalRows := 0
+
+	logger.WithField("days", opts.TestdataDays).
+		WithField("multiplier", opts.TestdataMultiplier).
+		WithField("profile", opts.TestdataProfile).
+		Info("generating test data")
+
+	UpdateMonitoredDBCache(monitoredDbs)
+	for _, md := range monitoredDbs {
+		l := logger.WithField("source", md.Name)
+		if err = md.Connect(ctx, r.opts.Sources, WithOverheadTracer(md.Name), WithTLSPolicy(md.Name, md.HostConfig.TLSPolicy)); err != nil {
+			l.WithError(err).Warning("could not connect, skipping test data generation")
+			continue
+		}
+		InitPGVersionInfoFetchingLockIfNil(md)
+		metricConfig := md.Metrics
+		if len(metricConfig) == 0 && md.PresetMetrics > "" {
+			metricDefMapLock.RLock()
+			metricConfig = metricDefinitionMap.PresetDefs[md.PresetMetrics].Metrics
+			metricDefMapLock.RUnlock()
+		}
+		for metricName, interval := range metricConfig {
+			if _, isSpecialMetric := specialMetrics[metricName]; isSpecialMetric || interval <= 0 {
+				continue
+			}
+			mfm := MetricFetchConfig{
+				DBUniqueName:     md.Name,
+				DBUniqueNameOrig: md.GetDatabaseName(),
+				MetricName:       metricName,
+				Source:           md.Kind,
+				Interval:         time.Second * time.Duration(interval),
+			}
+			samples, e := FetchMetrics(ctx, mfm, make(map[string]map[string]string), r.measurementCh, "", r.opts)
+			if e != nil || len(samples) == 0 || len(samples[0].Data) == 0 {
+				l.WithField("metric", metricName).WithError(e).Warning("no sample data fetched, skipping metric")
+				continue
+			}
+			for i := range opts.TestdataMultiplier {
+				dbUnique := md.Name
+				if opts.TestdataMultiplier > 1 {
+					dbUnique = fmt.Sprintf("%s-%d", md.Name, i+1)
+				}
+				profile, e := NewWorkloadProfile(opts.TestdataProfile, rnd)
+				if e != nil {
+					return e
+				}
+				g := NewTestdataGenerator(samples[0], mfm.Interval, profile, opts.TestdataJitter, rnd)
+				rows, e := g.Generate(ctx, dbUnique, from, to, r.measurementCh)
+				totalRows += rows
+				if e != nil {
+					return e
+				}
+				l.WithField("metric", samples[0].MetricName).WithField("rows", rows).Debug("test data generated")
+			}
+		}
+	}
+	logger.WithField("rows", totalRows).Info("test data generation finished, waiting for sinks to flush...")
+	for len(r.measurementCh) > 0 && ctx.Err() == nil {
+		time.Sleep(100 * time.Millisecond)
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(2 * r.opts.Sinks.BatchingDelay):
+	}
+	return nil
+}
+
+// IsTestdataMode returns true if the reaper should generate test data instead of regular monitoring
+func (r *Reaper) IsTestdataMode() bool {
+	return r.opts.Metrics.TestdataDays > 0
+}
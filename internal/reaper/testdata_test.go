@@ -0,0 +1,98 @@
+package reaper
+
+import (
+	"context"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewWorkloadProfile(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(1, 2))
+	for _, name := range []string{TestdataProfileSteady, TestdataProfileDiurnal, TestdataProfileBursty, TestdataProfileSpiky} {
+		p, err := NewWorkloadProfile(name, rnd)
+		assert.NoError(t, err, name)
+		assert.NotNil(t, p, name)
+	}
+	_, err := NewWorkloadProfile("foo", rnd)
+	assert.Error(t, err)
+
+	diurnal, _ := NewWorkloadProfile(TestdataProfileDiurnal, rnd)
+	midnight := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	noon := midnight.Add(12 * time.Hour)
+	low, _ := diurnal(midnight)
+	high, _ := diurnal(noon)
+	assert.InDelta(t, 0.4, low, 0.0001)
+	assert.InDelta(t, 1.6, high, 0.0001)
+
+	spiky, _ := NewWorkloadProfile(TestdataProfileSpiky, rnd)
+	gaps := 0
+	for i := range 10000 {
+		if _, ok := spiky(midnight.Add(time.Duration(i) * time.Minute)); !ok {
+			gaps++
+		}
+	}
+	assert.Positive(t, gaps, "spiky profile should inject failures")
+}
+
+func TestTestdataGenerator(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(1, 2))
+	steady, _ := NewWorkloadProfile(TestdataProfileSteady, rnd)
+	sample := metrics.MeasurementEnvelope{
+		MetricName: "test_metric",
+		MetricDef:  metrics.Metric{Gauges: []string{"gauge"}},
+		Data: metrics.Measurements{
+			{epochColumnName: int64(1), "tag_name": "foo", "gauge": int64(100), "counter": int64(1000), "ratio": 0.5, "text": "bar"},
+		},
+	}
+	g := NewTestdataGenerator(sample, time.Minute, steady, 10, rnd)
+
+	start := time.Now()
+	var prevCounter int64
+	for i := range 100 {
+		ts := start.Add(time.Duration(i) * time.Minute)
+		rows := g.Rows(ts)
+		assert.Len(t, rows, 1)
+		row := rows[0]
+		assert.Equal(t, ts.UnixNano(), row[epochColumnName])
+		assert.Equal(t, "foo", row["tag_name"])
+		assert.Equal(t, "bar", row["text"])
+		assert.InDelta(t, 100, row["gauge"].(int64), 10, "gauge should respect jitter")
+		assert.IsType(t, float64(0), row["ratio"])
+		counter := row["counter"].(int64)
+		assert.GreaterOrEqual(t, counter, prevCounter, "counters should not decrease")
+		prevCounter = counter
+	}
+}
+
+func TestTestdataGeneratorGenerate(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(1, 2))
+	steady, _ := NewWorkloadProfile(TestdataProfileSteady, rnd)
+	sample := metrics.MeasurementEnvelope{
+		DBName:     "orig",
+		MetricName: "test_metric",
+		Data:       metrics.Measurements{{"value": int64(1)}, {"value": int64(2)}},
+	}
+	g := NewTestdataGenerator(sample, time.Minute, steady, 0, rnd)
+	ch := make(chan []metrics.MeasurementEnvelope, 100)
+	to := time.Now()
+	rows, err := g.Generate(context.Background(), "copy-1", to.Add(-24*time.Hour), to, ch)
+	assert.NoError(t, err)
+	assert.Equal(t, 24*60*2, rows)
+	close(ch)
+	received := 0
+	for msgs := range ch {
+		assert.Equal(t, "copy-1", msgs[0].DBName)
+		assert.LessOrEqual(t, len(msgs[0].Data), testdataMaxBatchRows+len(sample.Data))
+		received += len(msgs[0].Data)
+	}
+	assert.Equal(t, rows, received)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = g.Generate(ctx, "copy-1", to.Add(-24*time.Hour), to, make(chan []metrics.MeasurementEnvelope))
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,155 @@
+package reaper
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sinks"
+)
+
+// throughputResolution is the period of a throughput sample
+const throughputResolution = time.Minute
+
+// ThroughputSample is the collector throughput per second in a period
+type ThroughputSample struct {
+	Time         time.Time `json:"time"` // end of the period
+	Batches      float64   `json:"batches_per_s"`
+	Rows         float64   `json:"rows_per_s"`
+	Queries      float64   `json:"queries_per_s"` // executed on the monitored DBs
+	BytesFetched float64   `json:"bytes_fetched_per_s"`
+	FetchErrors  float64   `json:"fetch_errors_per_s"`
+}
+
+// ThroughputHistory is the response of the throughput history REST API
+type ThroughputHistory struct {
+	ResolutionSeconds int                `json:"resolution_seconds"`
+	Samples           []ThroughputSample `json:"samples"` // oldest first
+}
+
+// throughputCounters are the cumulative counters the rates are calculated from
+type throughputCounters struct {
+	batches, rows, queries, bytesFetched, fetchErrors int64
+}
+
+func readThroughputCounters(b *Bus) (c throughputCounters) {
+	c.batches, c.rows = b.Published()
+	monitoringOverheadLock.Lock()
+	for _, o := range monitoringOverhead {
+		c.queries += o.Queries
+		c.bytesFetched += o.BytesFetched
+		for _, n := range o.FetchErrors {
+			c.fetchErrors += n
+		}
+	}
+	monitoringOverheadLock.Unlock()
+	return
+}
+
+// throughputRing keeps the per-minute throughput samples of a limited period, so that the collector
+// throughput can be charted without an external time series database
+type throughputRing struct {
+	sync.RWMutex
+	samples  []ThroughputSample
+	next     int // index of the oldest sample once full
+	full     bool
+	last     throughputCounters
+	lastTime time.Time
+}
+
+func newThroughputRing(period time.Duration) *throughputRing {
+	return &throughputRing{samples: make([]ThroughputSample, max(1, int(period/throughputResolution)))}
+}
+
+// add records the rates since the previous counters, the first call sets the baseline only
+func (tr *throughputRing) add(t time.Time, c throughputCounters) {
+	tr.Lock()
+	defer tr.Unlock()
+	if secs := t.Sub(tr.lastTime).Seconds(); !tr.lastTime.IsZero() && secs > 0 {
+		rate := func(cur, prev int64) float64 { return float64(cur-prev) / secs }
+		tr.samples[tr.next] = ThroughputSample{
+			Time:         t,
+			Batches:      rate(c.batches, tr.last.batches),
+			Rows:         rate(c.rows, tr.last.rows),
+			Queries:      rate(c.queries, tr.last.queries),
+			BytesFetched: rate(c.bytesFetched, tr.last.bytesFetched),
+			FetchErrors:  rate(c.fetchErrors, tr.last.fetchErrors),
+		}
+		tr.next = (tr.next + 1) % len(tr.samples)
+		tr.full = tr.full || tr.next == 0
+	}
+	tr.last, tr.lastTime = c, t
+}
+
+// Samples returns the samples oldest first
+func (tr *throughputRing) Samples() []ThroughputSample {
+	tr.RLock()
+	defer tr.RUnlock()
+	if !tr.full {
+		return slices.Clone(tr.samples[:tr.next])
+	}
+	return slices.Concat(tr.samples[tr.next:], tr.samples[:tr.next])
+}
+
+// Summary returns the average rates of the samples in the window before the latest one, rounded up to whole minutes
+func (tr *throughputRing) Summary(window time.Duration) (s ThroughputSample) {
+	samples := tr.Samples()
+	if len(samples) == 0 {
+		return
+	}
+	s.Time = samples[len(samples)-1].Time
+	from := s.Time.Add(-window)
+	var n float64
+	for _, x := range samples {
+		if !x.Time.After(from) {
+			continue
+		}
+		s.Batches += x.Batches
+		s.Rows += x.Rows
+		s.Queries += x.Queries
+		s.BytesFetched += x.BytesFetched
+		s.FetchErrors += x.FetchErrors
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	s.Batches /= n
+	s.Rows /= n
+	s.Queries /= n
+	s.BytesFetched /= n
+	s.FetchErrors /= n
+	return
+}
+
+// Run samples the counters every minute until the context is cancelled
+func (tr *throughputRing) Run(ctx context.Context, b *Bus) {
+	tr.add(time.Now(), readThroughputCounters(b))
+	ticker := time.NewTicker(throughputResolution)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-ticker.C:
+			tr.add(t, readThroughputCounters(b))
+		}
+	}
+}
+
+// Throughput returns the per-minute collector throughput of the --stats-history period for the REST API
+func (r *Reaper) Throughput() any {
+	return ThroughputHistory{
+		ResolutionSeconds: int(throughputResolution.Seconds()),
+		Samples:           orEmpty(r.throughput.Samples()),
+	}
+}
+
+// WriteStats returns the per-minute write statistics of every sink by metric for the REST API
+func (r *Reaper) WriteStats() any {
+	if mw := r.measurementsWriter.Load(); mw != nil {
+		return mw.WriteStats()
+	}
+	return []sinks.SinkWriteStats{}
+}
@@ -0,0 +1,62 @@
+package reaper
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/cmdopts"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/sinks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThroughputRing(t *testing.T) {
+	tr := newThroughputRing(3 * time.Minute)
+	start := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+	assert.Empty(t, tr.Samples())
+	assert.Zero(t, tr.Summary(5*time.Minute), "no samples before the first minute")
+
+	for i := range 5 {
+		n := int64(i * (i + 1) / 2 * 60) // rates of 0, 60, 120, ... per minute
+		tr.add(start.Add(time.Duration(i)*time.Minute), throughputCounters{batches: n, rows: 10 * n, fetchErrors: int64(i)})
+	}
+	samples := tr.Samples()
+	require.Len(t, samples, 3, "only the history period is kept")
+	assert.Equal(t, start.Add(2*time.Minute), samples[0].Time, "oldest first")
+	assert.Equal(t, []float64{2, 3, 4}, []float64{samples[0].Batches, samples[1].Batches, samples[2].Batches})
+	assert.Equal(t, 40.0, samples[2].Rows)
+	assert.InDelta(t, 1.0/60, samples[2].FetchErrors, 1e-9)
+
+	s := tr.Summary(2 * time.Minute)
+	assert.Equal(t, start.Add(4*time.Minute), s.Time)
+	assert.Equal(t, 3.5, s.Batches, "average of the last two minutes")
+	assert.Equal(t, 4.0, tr.Summary(30*time.Second).Batches, "rounded up to whole minutes")
+	assert.Equal(t, 3.0, tr.Summary(time.Hour).Batches, "all samples")
+	assert.Zero(t, tr.Summary(0).Batches)
+}
+
+func TestReaperThroughput(t *testing.T) {
+	r := NewReaper(&cmdopts.Options{StatsHistory: time.Hour}, nil, nil)
+	r.bus.Publish(context.Background(), []metrics.MeasurementEnvelope{{Data: metrics.Measurements{{}, {}}}, {Data: metrics.Measurements{{}}}})
+	batches, rows := r.bus.Published()
+	assert.Equal(t, int64(1), batches)
+	assert.Equal(t, int64(3), rows)
+
+	b, err := json.Marshal(r.Throughput())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"resolution_seconds":60,"samples":[]}`, string(b))
+}
+
+func TestReaperWriteStats(t *testing.T) {
+	r := NewReaper(&cmdopts.Options{}, nil, nil)
+	assert.Equal(t, []sinks.SinkWriteStats{}, r.WriteStats(), "no sinks before reaping")
+	mw := &sinks.MultiWriter{}
+	mw.AddWriter(&sinks.JSONWriter{})
+	r.measurementsWriter.Store(mw)
+	if stats := r.WriteStats().([]sinks.SinkWriteStats); assert.Len(t, stats, 1) {
+		assert.Empty(t, stats[0].Buckets)
+	}
+}
@@ -1,8 +1,10 @@
 package reaper
 
 import (
+	"cmp"
 	"time"
 
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
 )
 
@@ -21,8 +23,10 @@ const (
 	monitoredDbsDatastoreSyncMetricName      = "configured_dbs" // FYI - for Postgres datastore there's also the admin.all_unique_dbnames table with all recent DB unique names with some metric data
 
 	dbSizeCachingInterval = 30 * time.Minute
-	dbMetricJoinStr       = "¤¤¤" // just some unlikely string for a DB name to avoid using maps of maps for DB+metric data
-
+	dbMetricJoinStr       = "¤¤¤"           // just some unlikely string for a DB name to avoid using maps of maps for DB+metric data
+	clientTimeoutMargin   = 5 * time.Second // client-side fetch deadline is set this much above the server-side statement_timeout
+	maxFallbackDepth      = 3               // protects from cycles in metric fallback chains
+	auroraInstanceTag     = "tag_aurora_instance"
 )
 
 type MetricFetchConfig struct {
@@ -33,6 +37,18 @@ type MetricFetchConfig struct {
 	Interval            time.Duration
 	CreatedOn           time.Time
 	StmtTimeoutOverride int64
+	FallbackFor         string // storage name of the original metric if fetching a fallback metric
+	FallbackDepth       int
+}
+
+// FallbackTo returns the fetch config for the fallback metric of the given metric
+func (msg MetricFetchConfig) FallbackTo(mvp metrics.Metric) MetricFetchConfig {
+	if msg.FallbackFor == "" {
+		msg.FallbackFor = cmp.Or(mvp.StorageName, msg.MetricName)
+	}
+	msg.MetricName = mvp.FallbackMetric
+	msg.FallbackDepth++
+	return msg
 }
 
 type ChangeDetectionResults struct { // for passing around DDL/index/config change detection results
@@ -0,0 +1,28 @@
+package reaper
+
+import (
+	"testing"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricFetchConfigFallbackTo(t *testing.T) {
+	msg := MetricFetchConfig{DBUniqueName: "db", MetricName: "db_size"}
+	mvp := metrics.Metric{MetricAttrs: metrics.MetricAttrs{FallbackMetric: "db_size_approx"}}
+
+	fallback := msg.FallbackTo(mvp)
+	assert.Equal(t, "db_size_approx", fallback.MetricName)
+	assert.Equal(t, "db_size", fallback.FallbackFor)
+	assert.Equal(t, 1, fallback.FallbackDepth)
+	assert.Equal(t, "db", fallback.DBUniqueName)
+
+	mvp.FallbackMetric = "db_size_cheapest"
+	fallback = fallback.FallbackTo(mvp)
+	assert.Equal(t, "db_size_cheapest", fallback.MetricName)
+	assert.Equal(t, "db_size", fallback.FallbackFor, "chained fallbacks should keep the original storage name")
+	assert.Equal(t, 2, fallback.FallbackDepth)
+
+	mvp.StorageName = "size"
+	assert.Equal(t, "size", msg.FallbackTo(mvp).FallbackFor)
+}
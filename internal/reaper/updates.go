@@ -0,0 +1,143 @@
+package reaper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+const (
+	updateCheckInterval  = 24 * time.Hour
+	updateCheckTimeout   = 30 * time.Second
+	maxUpdateHighlights  = 10
+	maxReleaseFeedLength = 1 << 20
+)
+
+// releaseFeedURL returns the latest pgwatch release in the GitHub releases API format
+var releaseFeedURL = "https://api.github.com/repos/cybertec-postgresql/pgwatch/releases/latest"
+
+// UpdateAdvisory is the outcome of the last check for a newer pgwatch release. Nothing is ever downloaded or
+// updated automatically
+type UpdateAdvisory struct {
+	CheckedAt         time.Time `json:"checked_at"`
+	RunningVersion    string    `json:"running_version"`
+	LatestVersion     string    `json:"latest_version,omitempty"`
+	UpdateAvailable   bool      `json:"update_available"`
+	ReleaseURL        string    `json:"release_url,omitempty"`
+	Highlights        []string  `json:"highlights,omitempty"`          // changelog bullet points of the latest release
+	NewBuiltinMetrics []string  `json:"new_builtin_metrics,omitempty"` // built-in metric definitions missing from the configured ones
+	Error             string    `json:"error,omitempty"`
+}
+
+// releaseInfo is the subset of the GitHub release fields used
+type releaseInfo struct {
+	TagName string `json:"tag_name"`
+	HTMLURL string `json:"html_url"`
+	Body    string `json:"body"`
+}
+
+// releaseHighlights returns the bullet points of the release notes, at most maxUpdateHighlights
+func releaseHighlights(body string) (highlights []string) {
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if item, ok := strings.CutPrefix(line, "- "); ok {
+			highlights = append(highlights, item)
+		} else if item, ok := strings.CutPrefix(line, "* "); ok {
+			highlights = append(highlights, item)
+		}
+		if len(highlights) == maxUpdateHighlights {
+			break
+		}
+	}
+	return
+}
+
+// fetchLatestRelease reads the latest release from the feed
+func fetchLatestRelease(ctx context.Context, url string) (*releaseInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, updateCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("release feed returned %s", resp.Status)
+	}
+	release := &releaseInfo{}
+	if err = json.NewDecoder(io.LimitReader(resp.Body, maxReleaseFeedLength)).Decode(release); err != nil {
+		return nil, fmt.Errorf("invalid release feed %s: %w", url, err)
+	}
+	return release, nil
+}
+
+// newBuiltinMetrics returns the metrics built into this version which are missing from the configured definitions,
+// e.g. if they are read from a configuration database initialized by an older version
+func newBuiltinMetrics() []string {
+	builtin := metrics.GetDefaultMetrics()
+	metricDefMapLock.RLock()
+	defer metricDefMapLock.RUnlock()
+	var missing []string
+	for name := range builtin.MetricDefs {
+		if _, ok := metricDefinitionMap.MetricDefs[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	slices.Sort(missing)
+	return missing
+}
+
+// CheckForUpdates compares the running version with the latest release of the feed
+func CheckForUpdates(ctx context.Context, url, runningVersion string) *UpdateAdvisory {
+	a := &UpdateAdvisory{CheckedAt: time.Now(), RunningVersion: runningVersion, NewBuiltinMetrics: newBuiltinMetrics()}
+	release, err := fetchLatestRelease(ctx, url)
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	a.LatestVersion = release.TagName
+	a.ReleaseURL = release.HTMLURL
+	running := VersionToInt(runningVersion)
+	if a.UpdateAvailable = running > 0 && VersionToInt(release.TagName) > running; a.UpdateAvailable {
+		a.Highlights = releaseHighlights(release.Body)
+	}
+	return a
+}
+
+// WatchForUpdates checks for a newer release daily, logs it and keeps the outcome for the /stats endpoint
+func (r *Reaper) WatchForUpdates(ctx context.Context) {
+	logger := log.GetLogger(ctx)
+	for first := true; ; first = false {
+		a := CheckForUpdates(ctx, releaseFeedURL, r.opts.Version)
+		r.updateAdvisory.Store(a)
+		switch {
+		case a.Error != "":
+			logger.WithError(errors.New(a.Error)).Warning("could not check for pgwatch updates")
+		case a.UpdateAvailable:
+			logger.WithField("running", a.RunningVersion).WithField("latest", a.LatestVersion).WithField("url", a.ReleaseURL).
+				Infof("a newer pgwatch release is available: %s", strings.Join(a.Highlights, "; "))
+		}
+		if first && len(a.NewBuiltinMetrics) > 0 {
+			logger.WithField("metrics", a.NewBuiltinMetrics).Info("built-in metric definitions missing from the configured ones")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(updateCheckInterval):
+		}
+	}
+}
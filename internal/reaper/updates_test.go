@@ -0,0 +1,44 @@
+package reaper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReleaseHighlights(t *testing.T) {
+	body := "## What's Changed\r\n- Add temp_spills metric\n* Fix sink timeouts\nSee the docs\n"
+	assert.Equal(t, []string{"Add temp_spills metric", "Fix sink timeouts"}, releaseHighlights(body))
+	assert.Empty(t, releaseHighlights("no bullets"))
+}
+
+func TestCheckForUpdates(t *testing.T) {
+	ctx := context.Background()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"tag_name": "v3.3.0", "html_url": "https://example.com/v3.3.0", "body": "- Faster sinks"}`))
+	}))
+	defer ts.Close()
+
+	a := CheckForUpdates(ctx, ts.URL, "3.2.1")
+	assert.Empty(t, a.Error)
+	assert.True(t, a.UpdateAvailable)
+	assert.Equal(t, "v3.3.0", a.LatestVersion)
+	assert.Equal(t, "https://example.com/v3.3.0", a.ReleaseURL)
+	assert.Equal(t, []string{"Faster sinks"}, a.Highlights)
+
+	a = CheckForUpdates(ctx, ts.URL, "3.3.0")
+	assert.False(t, a.UpdateAvailable)
+	assert.Empty(t, a.Highlights)
+
+	a = CheckForUpdates(ctx, ts.URL, "unknown")
+	assert.False(t, a.UpdateAvailable, "development builds are not compared")
+	assert.Equal(t, "v3.3.0", a.LatestVersion)
+
+	ts.Close()
+	a = CheckForUpdates(ctx, ts.URL, "3.2.1")
+	assert.NotEmpty(t, a.Error)
+	assert.False(t, a.UpdateAvailable)
+}
@@ -0,0 +1,264 @@
+package report
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"text/template"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/db"
+	"github.com/jackc/pgx/v5"
+)
+
+// baselineCounters are the cumulative db_stats columns compared as rates per second
+var baselineCounters = []string{"xact_commit", "xact_rollback", "tup_returned", "tup_fetched", "tup_inserted", "tup_updated",
+	"tup_deleted", "blks_read", "blks_hit", "temp_bytes", "deadlocks"}
+
+// baselineSettings are the settings of the settings metric affecting the query plans and performance
+var baselineSettings = []string{"server_version", "shared_buffers", "work_mem", "effective_cache_size", "random_page_cost",
+	"default_statistics_target", "max_parallel_workers_per_gather", "jit", "synchronous_commit"}
+
+// Baseline comparison sections
+const (
+	SectionThroughput = "throughput"
+	SectionLatency    = "latency"
+	SectionSettings   = "settings"
+)
+
+// Baseline is a labelled snapshot of the key metrics of a source over a time window
+type Baseline struct {
+	Label      string             `json:"label"`
+	Source     string             `json:"source"`
+	From       time.Time          `json:"from"`
+	To         time.Time          `json:"to"`
+	Throughput map[string]float64 `json:"throughput"` // per second rates of the db_stats counters, tps and cache hit ratio
+	Latency    map[string]float64 `json:"latency"`    // statement calls and mean time of stat_statements
+	Settings   map[string]string  `json:"settings"`   // as of the end of the window
+}
+
+// FetchBaseline calculates the baseline of the source from the measurements stored within the window
+func FetchBaseline(ctx context.Context, conn db.PgxIface, source, label string, from, to time.Time) (*Baseline, error) {
+	b := &Baseline{Label: label, Source: source, From: from, To: to,
+		Throughput: map[string]float64{}, Latency: map[string]float64{}, Settings: map[string]string{}}
+	tables, err := existingTables(ctx, conn, []string{"db_stats", "settings", "stat_statements"})
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range tables {
+		switch table {
+		case "db_stats":
+			err = b.fetchThroughput(ctx, conn)
+		case "settings":
+			err = b.fetchSettings(ctx, conn)
+		case "stat_statements":
+			err = b.fetchLatency(ctx, conn)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %w", table, err)
+		}
+	}
+	if len(b.Throughput)+len(b.Latency)+len(b.Settings) == 0 {
+		return nil, fmt.Errorf("no measurements of %s stored between %s and %s", source,
+			from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	return b, nil
+}
+
+// fetchThroughput sums up the counter increases, a decrease is a statistics reset and is skipped
+func (b *Baseline) fetchThroughput(ctx context.Context, conn db.PgxIface) error {
+	rows, err := conn.Query(ctx, `with d as (
+		  select time, key, value::float8 - lag(value::float8) over (partition by key order by time) as delta
+		  from public.db_stats, jsonb_each_text(data)
+		  where dbname = $1 and time between $2 and $3 and key = any($4)
+		)
+		select key, sum(greatest(delta, 0)) / extract(epoch from max(time) - min(time))::float8
+		from d group by key having max(time) > min(time)`, b.Source, b.From, b.To, baselineCounters)
+	if err != nil {
+		return err
+	}
+	var key string
+	var rate float64
+	if _, err = pgx.ForEachRow(rows, []any{&key, &rate}, func() error {
+		b.Throughput[key] = rate
+		return nil
+	}); err != nil || len(b.Throughput) == 0 {
+		return err
+	}
+	b.Throughput["tps"] = b.Throughput["xact_commit"] + b.Throughput["xact_rollback"]
+	if blks := b.Throughput["blks_hit"] + b.Throughput["blks_read"]; blks > 0 {
+		b.Throughput["cache_hit_pct"] = 100 * b.Throughput["blks_hit"] / blks
+	}
+	return nil
+}
+
+// fetchLatency sums up the calls and time increases of the top statements
+func (b *Baseline) fetchLatency(ctx context.Context, conn db.PgxIface) error {
+	var calls, totalTime, seconds float64
+	err := conn.QueryRow(ctx, `with d as (
+		  select time,
+		    (data->>'calls')::float8 - lag((data->>'calls')::float8) over w as calls,
+		    (data->>'total_time')::float8 - lag((data->>'total_time')::float8) over w as total_time
+		  from public.stat_statements
+		  where dbname = $1 and time between $2 and $3
+		  window w as (partition by tag_data->>'queryid' order by time)
+		)
+		select coalesce(sum(calls) filter (where calls > 0 and total_time >= 0), 0),
+		  coalesce(sum(total_time) filter (where calls > 0 and total_time >= 0), 0),
+		  coalesce(extract(epoch from max(time) - min(time))::float8, 0)
+		from d`, b.Source, b.From, b.To).Scan(&calls, &totalTime, &seconds)
+	if err != nil || calls == 0 || seconds == 0 {
+		return err
+	}
+	b.Latency["calls_per_s"] = calls / seconds
+	b.Latency["mean_time_ms"] = totalTime / calls
+	return nil
+}
+
+func (b *Baseline) fetchSettings(ctx context.Context, conn db.PgxIface) error {
+	var data map[string]any
+	err := conn.QueryRow(ctx, `select data from public.settings where dbname = $1 and time between $2 and $3
+		order by time desc limit 1`, b.Source, b.From, b.To).Scan(&data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	for _, name := range baselineSettings {
+		if v, ok := data[name]; ok && v != nil {
+			b.Settings[name] = fmt.Sprint(v)
+		}
+	}
+	return err
+}
+
+// SaveBaseline stores the baseline in the sink, replacing the one of the source with the same label
+func SaveBaseline(ctx context.Context, conn db.PgxIface, b *Baseline) error {
+	if _, err := conn.Exec(ctx, `create table if not exists admin.baselines (
+		  dbname text not null,
+		  label text not null,
+		  captured_on timestamptz not null default now(),
+		  snapshot jsonb not null,
+		  primary key (dbname, label)
+		)`); err != nil {
+		return err
+	}
+	_, err := conn.Exec(ctx, `insert into admin.baselines (dbname, label, snapshot) values ($1, $2, $3)
+		on conflict (dbname, label) do update set captured_on = now(), snapshot = excluded.snapshot`, b.Source, b.Label, b)
+	return err
+}
+
+// LoadBaseline reads the stored baseline of the source with the label
+func LoadBaseline(ctx context.Context, conn db.PgxIface, source, label string) (*Baseline, error) {
+	var snapshot []byte
+	err := conn.QueryRow(ctx, `select snapshot from admin.baselines where dbname = $1 and label = $2`, source, label).Scan(&snapshot)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("no baseline %q of %s captured", label, source)
+	}
+	if err != nil {
+		return nil, err
+	}
+	b := &Baseline{}
+	return b, json.Unmarshal(snapshot, b)
+}
+
+// BaselineDiff is a compared value of two baselines
+type BaselineDiff struct {
+	Section   string   `json:"section"`
+	Name      string   `json:"name"`
+	Before    string   `json:"before"`
+	After     string   `json:"after"`
+	ChangePct *float64 `json:"change_pct,omitempty"` // of the numeric values
+	Changed   bool     `json:"changed"`
+}
+
+// Comparison is the diff report of two baselines
+type Comparison struct {
+	Before *Baseline      `json:"before"`
+	After  *Baseline      `json:"after"`
+	Diffs  []BaselineDiff `json:"diffs"`
+}
+
+// names returns the sorted keys of both maps
+func names[V any](before, after map[string]V) []string {
+	all := slices.AppendSeq(slices.Collect(maps.Keys(before)), maps.Keys(after))
+	slices.Sort(all)
+	return slices.Compact(all)
+}
+
+func compareValues(section string, before, after map[string]float64) (diffs []BaselineDiff) {
+	for _, name := range names(before, after) {
+		d := BaselineDiff{Section: section, Name: name, Before: "-", After: "-"}
+		b, okBefore := before[name]
+		a, okAfter := after[name]
+		if okBefore {
+			d.Before = fmt.Sprintf("%.2f", b)
+		}
+		if okAfter {
+			d.After = fmt.Sprintf("%.2f", a)
+		}
+		if okBefore && okAfter && b != 0 {
+			pct := math.Round(10000*(a-b)/b) / 100
+			d.ChangePct = &pct
+		}
+		d.Changed = d.Before != d.After
+		diffs = append(diffs, d)
+	}
+	return
+}
+
+// Compare returns the differences of the after baseline to the before one
+func Compare(before, after *Baseline) *Comparison {
+	c := &Comparison{Before: before, After: after}
+	c.Diffs = append(c.Diffs, compareValues(SectionThroughput, before.Throughput, after.Throughput)...)
+	c.Diffs = append(c.Diffs, compareValues(SectionLatency, before.Latency, after.Latency)...)
+	for _, name := range names(before.Settings, after.Settings) {
+		b, a := cmp.Or(before.Settings[name], "-"), cmp.Or(after.Settings[name], "-")
+		c.Diffs = append(c.Diffs, BaselineDiff{Section: SectionSettings, Name: name, Before: b, After: a, Changed: a != b})
+	}
+	return c
+}
+
+var comparisonTemplate = template.Must(template.New("comparison").Funcs(map[string]any{
+	"window": func(b *Baseline) string {
+		return b.From.Format("2006-01-02 15:04") + " - " + b.To.Format("2006-01-02 15:04 MST")
+	},
+	"cell": templateFuncs["cell"],
+	"pct": func(p *float64) string {
+		if p == nil {
+			return ""
+		}
+		return fmt.Sprintf("%+.2f%%", *p)
+	},
+	"mark": func(changed bool) string {
+		if changed {
+			return "**changed**"
+		}
+		return ""
+	},
+}).Parse(`# pgwatch baseline comparison of {{.After.Source}}
+
+- Before: {{if .Before.Label}}{{.Before.Label}}, {{end}}{{window .Before}}
+- After: {{if .After.Label}}{{.After.Label}}, {{end}}{{window .After}}
+
+| Section | Value | Before | After | Change |
+|---------|-------|--------|-------|--------|
+{{range .Diffs}}| {{.Section}} | {{.Name}} | {{cell .Before}} | {{cell .After}} | {{if .ChangePct}}{{pct .ChangePct}}{{else}}{{mark .Changed}}{{end}} |
+{{end}}`))
+
+// Write renders the comparison in the given format, markdown or json
+func (c *Comparison) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatMarkdown:
+		return comparisonTemplate.Execute(w, c)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(c)
+	}
+	return fmt.Errorf("unknown report format %q", format)
+}
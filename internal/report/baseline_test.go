@@ -0,0 +1,108 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchBaseline(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer conn.Close()
+	to := time.Now()
+	from := to.Add(-time.Hour)
+
+	conn.ExpectQuery("from pg_class").WithArgs(pgxmock.AnyArg()).
+		WillReturnRows(pgxmock.NewRows([]string{"relname"}).AddRow("db_stats").AddRow("settings").AddRow("stat_statements"))
+	conn.ExpectQuery("from public.db_stats").WithArgs("db1", from, to, baselineCounters).
+		WillReturnRows(pgxmock.NewRows([]string{"key", "rate"}).
+			AddRow("xact_commit", 90.0).AddRow("xact_rollback", 10.0).AddRow("blks_hit", 99.0).AddRow("blks_read", 1.0))
+	conn.ExpectQuery("from public.settings").WithArgs("db1", from, to).
+		WillReturnRows(pgxmock.NewRows([]string{"data"}).
+			AddRow(map[string]any{"server_version": "16.4", "work_mem": "4MB", "random_page_cost": 1.1, "fsync": "on"}))
+	conn.ExpectQuery("from public.stat_statements").WithArgs("db1", from, to).
+		WillReturnRows(pgxmock.NewRows([]string{"calls", "total_time", "seconds"}).AddRow(3600.0, 7200.0, 3600.0))
+
+	b, err := FetchBaseline(context.Background(), conn, "db1", "before", from, to)
+	require.NoError(t, err)
+	assert.NoError(t, conn.ExpectationsWereMet())
+
+	assert.Equal(t, 100.0, b.Throughput["tps"])
+	assert.Equal(t, 99.0, b.Throughput["cache_hit_pct"])
+	assert.Equal(t, map[string]float64{"calls_per_s": 1, "mean_time_ms": 2}, b.Latency)
+	assert.Equal(t, map[string]string{"server_version": "16.4", "work_mem": "4MB", "random_page_cost": "1.1"}, b.Settings,
+		"only the plan-relevant settings")
+}
+
+func TestFetchBaselineNoMeasurements(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.ExpectQuery("from pg_class").WithArgs(pgxmock.AnyArg()).WillReturnRows(pgxmock.NewRows([]string{"relname"}))
+
+	_, err = FetchBaseline(context.Background(), conn, "db1", "", time.Now().Add(-time.Hour), time.Now())
+	assert.ErrorContains(t, err, "no measurements of db1")
+}
+
+func TestSaveLoadBaseline(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer conn.Close()
+	b := &Baseline{Label: "before", Source: "db1", Throughput: map[string]float64{"tps": 1}}
+
+	conn.ExpectExec("create table if not exists admin.baselines").WillReturnResult(pgxmock.NewResult("CREATE", 0))
+	conn.ExpectExec("insert into admin.baselines").WithArgs("db1", "before", b).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	assert.NoError(t, SaveBaseline(context.Background(), conn, b))
+
+	conn.ExpectQuery("from admin.baselines").WithArgs("db1", "before").
+		WillReturnRows(pgxmock.NewRows([]string{"snapshot"}).AddRow([]byte(`{"label":"before","source":"db1","throughput":{"tps":1}}`)))
+	loaded, err := LoadBaseline(context.Background(), conn, "db1", "before")
+	assert.NoError(t, err)
+	assert.Equal(t, b.Throughput, loaded.Throughput)
+	assert.Equal(t, "before", loaded.Label)
+
+	conn.ExpectQuery("from admin.baselines").WithArgs("db1", "after").WillReturnRows(pgxmock.NewRows([]string{"snapshot"}))
+	_, err = LoadBaseline(context.Background(), conn, "db1", "after")
+	assert.ErrorContains(t, err, `no baseline "after" of db1`)
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
+
+func TestCompare(t *testing.T) {
+	before := &Baseline{Label: "before", Source: "db1",
+		Throughput: map[string]float64{"tps": 100, "deadlocks": 0},
+		Latency:    map[string]float64{"mean_time_ms": 2},
+		Settings:   map[string]string{"server_version": "15.8", "work_mem": "4MB"}}
+	after := &Baseline{Label: "after", Source: "db1",
+		Throughput: map[string]float64{"tps": 150, "deadlocks": 0},
+		Latency:    map[string]float64{"mean_time_ms": 1, "calls_per_s": 5},
+		Settings:   map[string]string{"server_version": "16.4", "work_mem": "4MB", "jit": "0"}}
+
+	c := Compare(before, after)
+	pct := func(p float64) *float64 { return &p }
+	assert.Equal(t, []BaselineDiff{
+		{Section: SectionThroughput, Name: "deadlocks", Before: "0.00", After: "0.00"},
+		{Section: SectionThroughput, Name: "tps", Before: "100.00", After: "150.00", ChangePct: pct(50), Changed: true},
+		{Section: SectionLatency, Name: "calls_per_s", Before: "-", After: "5.00", Changed: true},
+		{Section: SectionLatency, Name: "mean_time_ms", Before: "2.00", After: "1.00", ChangePct: pct(-50), Changed: true},
+		{Section: SectionSettings, Name: "jit", Before: "-", After: "0", Changed: true},
+		{Section: SectionSettings, Name: "server_version", Before: "15.8", After: "16.4", Changed: true},
+		{Section: SectionSettings, Name: "work_mem", Before: "4MB", After: "4MB"},
+	}, c.Diffs)
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Write(&buf, FormatMarkdown))
+	assert.Contains(t, buf.String(), "| throughput | tps | 100.00 | 150.00 | +50.00% |")
+	assert.Contains(t, buf.String(), "| settings | server_version | 15.8 | 16.4 | **changed** |")
+
+	buf.Reset()
+	require.NoError(t, c.Write(&buf, FormatJSON))
+	assert.True(t, json.Valid(buf.Bytes()))
+	assert.Error(t, c.Write(&buf, "xml"))
+}
@@ -0,0 +1,211 @@
+// Package report summarizes the measurements stored in the Postgres sink
+package report
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/db"
+	"github.com/jackc/pgx/v5"
+)
+
+// metric tables the fleet report reads, missing ones are skipped
+var fleetMetrics = []string{"settings", "db_size", "archiver", "backup_age_pgbackrest", "backup_age_walg", "recommendations"}
+
+// FleetOptions control the period and length of the fleet report
+type FleetOptions struct {
+	Days int // period of the growth and the measurements considered
+	Top  int // max rows of the top lists
+}
+
+// VersionCount is a bar of the PostgreSQL versions histogram
+type VersionCount struct {
+	Version string   `json:"version"` // major version
+	Sources []string `json:"sources"`
+}
+
+// SourceSize is the database size of a source and its growth over the report period
+type SourceSize struct {
+	Source  string `json:"source"`
+	SizeB   int64  `json:"size_b"`
+	GrowthB int64  `json:"growth_b"`
+}
+
+// Problem is a failing check of a source as of its last measurement
+type Problem struct {
+	Source string    `json:"source"`
+	Check  string    `json:"check"` // metric reporting the failure
+	Detail string    `json:"detail"`
+	Time   time.Time `json:"time"`
+}
+
+// RecommendationCount is the number of recommendations of the last fetch of a source
+type RecommendationCount struct {
+	Source          string `json:"source"`
+	Recommendations int    `json:"recommendations"`
+}
+
+// Fleet summarizes all the sources stored in the sink
+type Fleet struct {
+	GeneratedAt        time.Time             `json:"generated_at"`
+	Days               int                   `json:"days"`
+	Sources            int                   `json:"sources"` // with a database size measured within the period
+	TotalSizeB         int64                 `json:"total_size_b"`
+	Versions           []VersionCount        `json:"versions"`
+	TopGrowers         []SourceSize          `json:"top_growers"`
+	FailingBackups     []Problem             `json:"failing_backups"`
+	FailingArchiving   []Problem             `json:"failing_archiving"`
+	TopRecommendations []RecommendationCount `json:"top_recommendations"`
+}
+
+// majorVersion returns the major part of a server_version, e.g. 16 for 16.4 and 9.6 for 9.6.24
+func majorVersion(version string) string {
+	if version == "" {
+		return "unknown"
+	}
+	version, _, _ = strings.Cut(version, " ")
+	parts := strings.Split(version, ".")
+	if len(parts) > 2 || len(parts) == 2 && strings.HasPrefix(version, "9.") {
+		return parts[0] + "." + parts[1]
+	}
+	return parts[0]
+}
+
+// existingTables returns the metric tables of the sink out of the given ones
+func existingTables(ctx context.Context, conn db.PgxIface, tables []string) ([]string, error) {
+	rows, err := conn.Query(ctx, `select c.relname::text from pg_class c join pg_namespace n on n.oid = c.relnamespace
+		where n.nspname = 'public' and c.relkind in ('r', 'p') and c.relname = any($1) order by 1`, tables)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// FetchFleet reads the fleet summary from the Postgres sink
+func FetchFleet(ctx context.Context, conn db.PgxIface, opts FleetOptions) (*Fleet, error) {
+	f := &Fleet{
+		GeneratedAt:        time.Now(),
+		Days:               opts.Days,
+		Versions:           []VersionCount{},
+		TopGrowers:         []SourceSize{},
+		FailingBackups:     []Problem{},
+		FailingArchiving:   []Problem{},
+		TopRecommendations: []RecommendationCount{},
+	}
+	tables, err := existingTables(ctx, conn, fleetMetrics)
+	if err != nil {
+		return nil, err
+	}
+	period := fmt.Sprintf("%d days", opts.Days)
+	for _, table := range tables {
+		switch table {
+		case "settings":
+			err = f.fetchVersions(ctx, conn, period)
+		case "db_size":
+			err = f.fetchSizes(ctx, conn, period, opts.Top)
+		case "archiver":
+			err = f.fetchArchiving(ctx, conn, period)
+		case "backup_age_pgbackrest", "backup_age_walg":
+			err = f.fetchBackups(ctx, conn, table, period)
+		case "recommendations":
+			err = f.fetchRecommendations(ctx, conn, period, opts.Top)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not summarize %s: %w", table, err)
+		}
+	}
+	slices.SortFunc(f.FailingBackups, func(a, b Problem) int {
+		return cmp.Or(cmp.Compare(a.Source, b.Source), cmp.Compare(a.Check, b.Check))
+	})
+	return f, nil
+}
+
+func (f *Fleet) fetchVersions(ctx context.Context, conn db.PgxIface, period string) error {
+	rows, err := conn.Query(ctx, `select distinct on (dbname) dbname, coalesce(data->>'server_version', '')
+		from public.settings where time > now() - $1::interval order by dbname, time desc`, period)
+	if err != nil {
+		return err
+	}
+	var source, version string
+	sources := make(map[string][]string)
+	_, err = pgx.ForEachRow(rows, []any{&source, &version}, func() error {
+		major := majorVersion(version)
+		sources[major] = append(sources[major], source)
+		return nil
+	})
+	for major, srcs := range sources {
+		f.Versions = append(f.Versions, VersionCount{Version: major, Sources: srcs})
+	}
+	slices.SortFunc(f.Versions, func(a, b VersionCount) int { return strings.Compare(a.Version, b.Version) })
+	return err
+}
+
+func (f *Fleet) fetchSizes(ctx context.Context, conn db.PgxIface, period string, top int) error {
+	rows, err := conn.Query(ctx, `select dbname,
+		  (array_agg((data->>'size_b')::int8 order by time desc))[1] as size_b,
+		  (array_agg((data->>'size_b')::int8 order by time desc))[1] - (array_agg((data->>'size_b')::int8 order by time))[1] as growth_b
+		from public.db_size where time > now() - $1::interval and data ? 'size_b'
+		group by dbname order by growth_b desc, dbname`, period)
+	if err != nil {
+		return err
+	}
+	sizes, err := pgx.CollectRows(rows, pgx.RowToStructByPos[SourceSize])
+	if err != nil {
+		return err
+	}
+	f.Sources = len(sizes)
+	for _, s := range sizes {
+		f.TotalSizeB += s.SizeB
+		if s.GrowthB > 0 && len(f.TopGrowers) < top {
+			f.TopGrowers = append(f.TopGrowers, s)
+		}
+	}
+	return nil
+}
+
+func (f *Fleet) fetchArchiving(ctx context.Context, conn db.PgxIface, period string) error {
+	rows, err := conn.Query(ctx, `select dbname, 'archiver',
+		  case when (data->>'is_stuck_int')::int = 1 then format('stuck for %s seconds', data->>'stuck_seconds')
+		  else format('last failure %s seconds ago', data->>'seconds_since_last_failure') end,
+		  time
+		from (select distinct on (dbname) dbname, data, time from public.archiver
+		      where time > now() - $1::interval order by dbname, time desc) last
+		where (data->>'is_failing_int')::int = 1 or (data->>'is_stuck_int')::int = 1
+		order by dbname`, period)
+	if err != nil {
+		return err
+	}
+	f.FailingArchiving, err = pgx.CollectRows(rows, pgx.RowToStructByPos[Problem])
+	return err
+}
+
+func (f *Fleet) fetchBackups(ctx context.Context, conn db.PgxIface, table, period string) error {
+	rows, err := conn.Query(ctx, `select dbname, $2::text, coalesce(data->>'message', ''), time
+		from (select distinct on (dbname) dbname, data, time from public.`+pgx.Identifier{table}.Sanitize()+`
+		      where time > now() - $1::interval order by dbname, time desc) last
+		where (data->>'retcode')::int <> 0
+		order by dbname`, period, table)
+	if err != nil {
+		return err
+	}
+	problems, err := pgx.CollectRows(rows, pgx.RowToStructByPos[Problem])
+	f.FailingBackups = append(f.FailingBackups, problems...)
+	return err
+}
+
+func (f *Fleet) fetchRecommendations(ctx context.Context, conn db.PgxIface, period string, top int) error {
+	rows, err := conn.Query(ctx, `with last as (
+		  select dbname, max(time) as time from public.recommendations where time > now() - $1::interval group by dbname
+		)
+		select r.dbname, count(*)::int from public.recommendations r join last using (dbname, time)
+		group by r.dbname order by 2 desc, 1 limit $2`, period, top)
+	if err != nil {
+		return err
+	}
+	f.TopRecommendations, err = pgx.CollectRows(rows, pgx.RowToStructByPos[RecommendationCount])
+	return err
+}
@@ -0,0 +1,70 @@
+package report
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMajorVersion(t *testing.T) {
+	for version, major := range map[string]string{
+		"16.4":                      "16",
+		"17beta1":                   "17beta1",
+		"9.6.24":                    "9.6",
+		"15.8 (Debian 15.8-1.pgdg)": "15",
+		"":                          "unknown",
+	} {
+		assert.Equal(t, major, majorVersion(version), version)
+	}
+}
+
+func TestFetchFleet(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer conn.Close()
+	now := time.Now()
+
+	conn.ExpectQuery("from pg_class").WithArgs(fleetMetrics).
+		WillReturnRows(pgxmock.NewRows([]string{"relname"}).
+			AddRow("archiver").AddRow("backup_age_walg").AddRow("db_size").AddRow("recommendations").AddRow("settings"))
+	conn.ExpectQuery("from public.archiver").WithArgs("30 days").
+		WillReturnRows(pgxmock.NewRows([]string{"dbname", "check", "detail", "time"}).AddRow("db2", "archiver", "stuck for 600 seconds", now))
+	conn.ExpectQuery(`from public."backup_age_walg"`).WithArgs("30 days", "backup_age_walg").
+		WillReturnRows(pgxmock.NewRows([]string{"dbname", "check", "detail", "time"}).AddRow("db1", "backup_age_walg", "wal-g failed", now))
+	conn.ExpectQuery("from public.db_size").WithArgs("30 days").
+		WillReturnRows(pgxmock.NewRows([]string{"dbname", "size_b", "growth_b"}).
+			AddRow("db1", int64(3000), int64(1000)).AddRow("db2", int64(500), int64(10)).AddRow("db3", int64(100), int64(0)))
+	conn.ExpectQuery("from public.recommendations").WithArgs("30 days", 1).
+		WillReturnRows(pgxmock.NewRows([]string{"dbname", "count"}).AddRow("db3", 4))
+	conn.ExpectQuery("from public.settings").WithArgs("30 days").
+		WillReturnRows(pgxmock.NewRows([]string{"dbname", "server_version"}).AddRow("db1", "16.4").AddRow("db2", "16.2").AddRow("db3", "13.1"))
+
+	f, err := FetchFleet(context.Background(), conn, FleetOptions{Days: 30, Top: 1})
+	require.NoError(t, err)
+	assert.NoError(t, conn.ExpectationsWereMet())
+
+	assert.Equal(t, 3, f.Sources)
+	assert.EqualValues(t, 3600, f.TotalSizeB)
+	assert.Equal(t, []SourceSize{{Source: "db1", SizeB: 3000, GrowthB: 1000}}, f.TopGrowers, "top limited")
+	assert.Equal(t, []VersionCount{{Version: "13", Sources: []string{"db3"}}, {Version: "16", Sources: []string{"db1", "db2"}}}, f.Versions)
+	assert.Equal(t, "wal-g failed", f.FailingBackups[0].Detail)
+	assert.Equal(t, "db2", f.FailingArchiving[0].Source)
+	assert.Equal(t, []RecommendationCount{{Source: "db3", Recommendations: 4}}, f.TopRecommendations)
+}
+
+func TestFetchFleetEmptySink(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.ExpectQuery("from pg_class").WithArgs(fleetMetrics).WillReturnRows(pgxmock.NewRows([]string{"relname"}))
+
+	f, err := FetchFleet(context.Background(), conn, FleetOptions{Days: 30, Top: 10})
+	require.NoError(t, err)
+	assert.Zero(t, f.Sources)
+	assert.NotNil(t, f.Versions, "lists are never null")
+	assert.NotNil(t, f.FailingBackups)
+}
@@ -0,0 +1,140 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// Report formats, see --format
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatJSON     = "json"
+)
+
+// FormatBytes returns the size in binary units, e.g. 1.5 GiB
+func FormatBytes(b int64) string {
+	const unit = 1024
+	if b < unit && b > -unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit || n <= -unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
+
+var templateFuncs = map[string]any{
+	"bytes": FormatBytes,
+	"join":  strings.Join,
+	"cell":  func(s string) string { return strings.ReplaceAll(s, "|", `\|`) },
+	"time":  func(f *Fleet) string { return f.GeneratedAt.Format("2006-01-02 15:04:05 MST") },
+}
+
+var markdownTemplate = template.Must(template.New("fleet").Funcs(templateFuncs).Parse(`# pgwatch fleet report
+
+Generated at {{time .}} from the measurements of the last {{.Days}} days.
+
+## Summary
+
+- Sources: {{.Sources}}
+- Total size: {{bytes .TotalSizeB}}
+
+## PostgreSQL versions
+{{if .Versions}}
+| Version | Sources | Names |
+|---------|---------|-------|
+{{range .Versions}}| {{.Version}} | {{len .Sources}} | {{join .Sources ", "}} |
+{{end}}{{else}}
+None measured.
+{{end}}
+## Top growers
+{{if .TopGrowers}}
+| Source | Size | Growth |
+|--------|------|--------|
+{{range .TopGrowers}}| {{.Source}} | {{bytes .SizeB}} | {{bytes .GrowthB}} |
+{{end}}{{else}}
+None grew.
+{{end}}
+## Failing backups
+{{if .FailingBackups}}
+| Source | Check | Detail |
+|--------|-------|--------|
+{{range .FailingBackups}}| {{.Source}} | {{.Check}} | {{cell .Detail}} |
+{{end}}{{else}}
+None failing.
+{{end}}
+## Failing WAL archiving
+{{if .FailingArchiving}}
+| Source | Detail |
+|--------|--------|
+{{range .FailingArchiving}}| {{.Source}} | {{cell .Detail}} |
+{{end}}{{else}}
+None failing.
+{{end}}
+## Most recommendations
+{{if .TopRecommendations}}
+| Source | Recommendations |
+|--------|-----------------|
+{{range .TopRecommendations}}| {{.Source}} | {{.Recommendations}} |
+{{end}}{{else}}
+None.
+{{end}}`))
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("fleet").Funcs(templateFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>pgwatch fleet report</title></head>
+<body>
+<h1>pgwatch fleet report</h1>
+<p>Generated at {{time .}} from the measurements of the last {{.Days}} days.</p>
+<h2>Summary</h2>
+<ul><li>Sources: {{.Sources}}</li><li>Total size: {{bytes .TotalSizeB}}</li></ul>
+<h2>PostgreSQL versions</h2>
+{{if .Versions}}<table>
+<tr><th>Version</th><th>Sources</th><th>Names</th></tr>
+{{range .Versions}}<tr><td>{{.Version}}</td><td>{{len .Sources}}</td><td>{{join .Sources ", "}}</td></tr>
+{{end}}</table>{{else}}<p>None measured.</p>{{end}}
+<h2>Top growers</h2>
+{{if .TopGrowers}}<table>
+<tr><th>Source</th><th>Size</th><th>Growth</th></tr>
+{{range .TopGrowers}}<tr><td>{{.Source}}</td><td>{{bytes .SizeB}}</td><td>{{bytes .GrowthB}}</td></tr>
+{{end}}</table>{{else}}<p>None grew.</p>{{end}}
+<h2>Failing backups</h2>
+{{if .FailingBackups}}<table>
+<tr><th>Source</th><th>Check</th><th>Detail</th></tr>
+{{range .FailingBackups}}<tr><td>{{.Source}}</td><td>{{.Check}}</td><td>{{.Detail}}</td></tr>
+{{end}}</table>{{else}}<p>None failing.</p>{{end}}
+<h2>Failing WAL archiving</h2>
+{{if .FailingArchiving}}<table>
+<tr><th>Source</th><th>Detail</th></tr>
+{{range .FailingArchiving}}<tr><td>{{.Source}}</td><td>{{.Detail}}</td></tr>
+{{end}}</table>{{else}}<p>None failing.</p>{{end}}
+<h2>Most recommendations</h2>
+{{if .TopRecommendations}}<table>
+<tr><th>Source</th><th>Recommendations</th></tr>
+{{range .TopRecommendations}}<tr><td>{{.Source}}</td><td>{{.Recommendations}}</td></tr>
+{{end}}</table>{{else}}<p>None.</p>{{end}}
+</body>
+</html>
+`))
+
+// Write renders the report in the given format
+func (f *Fleet) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatMarkdown:
+		return markdownTemplate.Execute(w, f)
+	case FormatHTML:
+		return htmlTemplate.Execute(w, f)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(f)
+	}
+	return fmt.Errorf("unknown report format %q", format)
+}
@@ -0,0 +1,52 @@
+package report
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512 B", FormatBytes(512))
+	assert.Equal(t, "1.5 KiB", FormatBytes(1536))
+	assert.Equal(t, "2.0 GiB", FormatBytes(2<<30))
+	assert.Equal(t, "-1.0 MiB", FormatBytes(-1<<20))
+}
+
+func TestFleetWrite(t *testing.T) {
+	f := &Fleet{
+		GeneratedAt:      time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Days:             30,
+		Sources:          2,
+		TotalSizeB:       3 << 30,
+		Versions:         []VersionCount{{Version: "16", Sources: []string{"db1", "db2"}}},
+		TopGrowers:       []SourceSize{{Source: "db1", SizeB: 2 << 30, GrowthB: 1 << 30}},
+		FailingBackups:   []Problem{{Source: "db2", Check: "backup_age_walg", Detail: "a | b <c>"}},
+		FailingArchiving: []Problem{},
+	}
+
+	var b strings.Builder
+	require.NoError(t, f.Write(&b, FormatMarkdown))
+	md := b.String()
+	assert.Contains(t, md, "- Total size: 3.0 GiB")
+	assert.Contains(t, md, "| 16 | 2 | db1, db2 |")
+	assert.Contains(t, md, "| db1 | 2.0 GiB | 1.0 GiB |")
+	assert.Contains(t, md, `| db2 | backup_age_walg | a \| b <c> |`, "pipes are escaped in the cells")
+	assert.Contains(t, md, "## Failing WAL archiving\n\nNone failing.")
+
+	b.Reset()
+	require.NoError(t, f.Write(&b, FormatHTML))
+	assert.Contains(t, b.String(), "<td>a | b &lt;c&gt;</td>", "html is escaped")
+
+	b.Reset()
+	require.NoError(t, f.Write(&b, FormatJSON))
+	var decoded Fleet
+	require.NoError(t, json.Unmarshal([]byte(b.String()), &decoded))
+	assert.Equal(t, f.TopGrowers, decoded.TopGrowers)
+
+	assert.Error(t, f.Write(&b, "pdf"))
+}
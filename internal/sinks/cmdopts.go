@@ -9,4 +9,21 @@ type CmdOpts struct {
 	Retention             int           `long:"retention" mapstructure:"retention" description:"If set, metrics older than that will be deleted" default:"14" env:"PW_RETENTION"`
 	RealDbnameField       string        `long:"real-dbname-field" mapstructure:"real-dbname-field" description:"Tag key for real database name" env:"PW_REAL_DBNAME_FIELD" default:"real_dbname"`
 	SystemIdentifierField string        `long:"system-identifier-field" mapstructure:"system-identifier-field" description:"Tag key for system identifier value" env:"PW_SYSTEM_IDENTIFIER_FIELD" default:"sys_id"`
+	DuplicateGuard        string        `long:"duplicate-guard" mapstructure:"duplicate-guard" description:"Detect sources written to a Postgres sink by several collectors: report overlaps or drop measurements of sources owned by another collector" choice:"off" choice:"warn" choice:"drop" default:"off" env:"PW_DUPLICATE_GUARD"`
+	WriteTimeout          time.Duration `long:"sink-write-timeout" mapstructure:"sink-write-timeout" description:"Max time to wait for a sink to accept measurements, further writes to a hanging sink are dropped. Set to 0 to disable" default:"30s" env:"PW_SINK_WRITE_TIMEOUT"`
+	HealthCheckInterval   time.Duration `long:"sink-health-check-interval" mapstructure:"sink-health-check-interval" description:"How often to check the sinks availability. Set to 0 to disable" default:"1m" env:"PW_SINK_HEALTH_CHECK_INTERVAL"`
+	PromTLSCert           string        `long:"prometheus-tls-cert" mapstructure:"prometheus-tls-cert" description:"Certificate file to serve the Prometheus scrape endpoint over HTTPS" env:"PW_PROMETHEUS_TLS_CERT"`
+	PromTLSKey            string        `long:"prometheus-tls-key" mapstructure:"prometheus-tls-key" description:"Private key file of the Prometheus scrape endpoint certificate" env:"PW_PROMETHEUS_TLS_KEY"`
+	PromUser              string        `long:"prometheus-user" mapstructure:"prometheus-user" description:"Basic auth user required to scrape the Prometheus endpoint" env:"PW_PROMETHEUS_USER"`
+	PromPassword          string        `long:"prometheus-password" mapstructure:"prometheus-password" description:"Basic auth password required to scrape the Prometheus endpoint" env:"PW_PROMETHEUS_PASSWORD"`
+	PromToken             string        `long:"prometheus-token" mapstructure:"prometheus-token" description:"Bearer token accepted to scrape the Prometheus endpoint" env:"PW_PROMETHEUS_TOKEN"`
+	PromAllowedCIDRs      string        `long:"prometheus-allowed-cidrs" mapstructure:"prometheus-allowed-cidrs" description:"Comma separated networks allowed to scrape the Prometheus endpoint, e.g. 10.0.0.0/8. All if empty" env:"PW_PROMETHEUS_ALLOWED_CIDRS"`
+	PromExemplars         bool          `long:"prometheus-exemplars" mapstructure:"prometheus-exemplars" description:"Serve the OpenMetrics format with exemplars, e.g. query ids, attached to the counters of metrics having exemplar_columns" env:"PW_PROMETHEUS_EXEMPLARS"`
+	PromCacheFile         string        `long:"prometheus-cache-file" mapstructure:"prometheus-cache-file" description:"File to keep the measurements served to Prometheus across restarts. Disabled if empty" env:"PW_PROMETHEUS_CACHE_FILE"`
+	MetricNameRemaps      []string      `long:"metric-name-remap" mapstructure:"metric-name-remap" description:"Store a metric under another name as [sink_type:]metric=name, e.g. prometheus:db_stats=database, can be used multiple times" env:"PW_METRIC_NAME_REMAP"`
+	MetricNamePrefixes    []string      `long:"metric-name-prefix" mapstructure:"metric-name-prefix" description:"Prefix for the stored metric names as [sink_type:]prefix, e.g. prometheus:pg_, can be used multiple times" env:"PW_METRIC_NAME_PREFIX"`
+	SpoolDir              string        `long:"sink-spool-dir" mapstructure:"sink-spool-dir" description:"Directory to keep the measurements the grpc sinks could not push, replayed once the collector is reachable again. Disabled if empty" env:"PW_SINK_SPOOL_DIR"`
+	SpoolMaxSize          int64         `long:"sink-spool-max-size" mapstructure:"sink-spool-max-size" description:"Max size of the spool of a grpc sink in MB, further measurements are dropped until it's replayed" default:"100" env:"PW_SINK_SPOOL_MAX_SIZE"`
+	CollectorID           string        `no-flag:"true"` // set by the reaper, identifies the source owner for the duplicate guard
+	StatsHistory          time.Duration `no-flag:"true"` // set by the reaper from --stats-history, period of the write statistics
 }
@@ -0,0 +1,46 @@
+package sinks
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+// recordColumns stores the declared columns of the metric with their units in the admin.metric_columns dictionary,
+// only if they changed since the last time
+func (pgw *PostgresWriter) recordColumns(msg metrics.MeasurementEnvelope) error {
+	attrs := msg.MetricDef.ColumnAttrs
+	if len(attrs) == 0 {
+		return nil
+	}
+	if recorded, ok := pgw.columns[msg.MetricName]; ok && maps.Equal(recorded, attrs) {
+		return nil
+	}
+	if pgw.columns == nil {
+		if _, err := pgw.sinkDb.Exec(pgw.ctx, sqlMetricColumns); err != nil {
+			return err // sinks created by older versions lack the dictionary table
+		}
+		pgw.columns = make(map[string]metrics.ColumnAttrs)
+	}
+	names := slices.Sorted(maps.Keys(attrs))
+	types := make([]string, len(names))
+	units := make([]string, len(names))
+	scales := make([]float64, len(names))
+	for i, name := range names {
+		types[i], units[i], scales[i] = attrs[name].Type, attrs[name].Unit, cmp.Or(attrs[name].Scale, 1)
+	}
+	sql := `WITH stale AS (
+	DELETE FROM admin.metric_columns WHERE metric = $1 AND NOT column_name = ANY($2)
+)
+INSERT INTO admin.metric_columns AS c (metric, column_name, data_type, unit, scale)
+SELECT $1, n, nullif(t, ''), nullif(u, ''), s FROM unnest($2::text[], $3::text[], $4::text[], $5::float8[]) AS a(n, t, u, s)
+ON CONFLICT (metric, column_name) DO UPDATE
+SET data_type = excluded.data_type, unit = excluded.unit, scale = excluded.scale, updated_on = now()`
+	if _, err := pgw.sinkDb.Exec(pgw.ctx, sql, msg.MetricName, names, types, units, scales); err != nil {
+		return err
+	}
+	pgw.columns[msg.MetricName] = maps.Clone(attrs)
+	return nil
+}
@@ -0,0 +1,40 @@
+package sinks
+
+import (
+	"testing"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordColumns(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	pgw := PostgresWriter{ctx: ctx, sinkDb: conn}
+	msg := metrics.MeasurementEnvelope{MetricName: "db_stats", MetricDef: metrics.Metric{MetricAttrs: metrics.MetricAttrs{
+		ColumnAttrs: metrics.ColumnAttrs{
+			"blks_read":   {Type: metrics.ColumnTypeInt, Unit: metrics.ColumnUnitBytes, Scale: 8192},
+			"tag_datname": {Type: metrics.ColumnTypeText},
+		}}}}
+
+	assert.NoError(t, pgw.recordColumns(metrics.MeasurementEnvelope{MetricName: "wal"}), "nothing declared")
+
+	conn.ExpectExec("create table if not exists admin.metric_columns").WillReturnResult(pgxmock.NewResult("CREATE", 0))
+	conn.ExpectExec("INSERT INTO admin.metric_columns").
+		WithArgs("db_stats", []string{"blks_read", "tag_datname"}, []string{"int", "text"}, []string{"bytes", ""}, []float64{8192, 1}).
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	assert.NoError(t, pgw.recordColumns(msg))
+	assert.NoError(t, pgw.recordColumns(msg), "unchanged columns are not recorded again")
+
+	msg.MetricDef.ColumnAttrs = metrics.ColumnAttrs{"blks_read": {Unit: metrics.ColumnUnitBytes}}
+	conn.ExpectExec("INSERT INTO admin.metric_columns").
+		WithArgs("db_stats", []string{"blks_read"}, []string{""}, []string{"bytes"}, []float64{1}).
+		WillReturnError(assert.AnError)
+	assert.Error(t, pgw.recordColumns(msg))
+	conn.ExpectExec("INSERT INTO admin.metric_columns").WithArgs("db_stats", pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	assert.NoError(t, pgw.recordColumns(msg), "retried after a failure")
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
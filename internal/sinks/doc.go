@@ -4,7 +4,11 @@
 //   - PostgreSQL and flavours,
 //   - Prometheus,
 //   - plain JSON files,
-//   - and RPC servers.
+//   - InfluxDB v2 and other line protocol storages, e.g. VictoriaMetrics,
+//   - Kafka topics,
+//   - Elasticsearch and OpenSearch indices,
+//   - RPC servers,
+//   - and the gRPC ingest endpoint of another pgwatch collector.
 //
 // To ensure the simultaneous storage of data in several storages, the `MultiWriter` class is implemented.
 package sinks
@@ -0,0 +1,94 @@
+package sinks
+
+import (
+	"slices"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/jackc/pgx/v5"
+)
+
+// duplicate guard policies
+const (
+	DuplicateGuardOff  = "off"
+	DuplicateGuardWarn = "warn"
+	DuplicateGuardDrop = "drop"
+)
+
+var (
+	sourceOwnerRefresh = time.Minute      // how often the ownership of a source is renewed and checked
+	sourceOwnerLease   = 10 * time.Minute // ownership of a source not renewed for that long can be taken over
+)
+
+type sourceOwner struct {
+	checked   time.Time
+	collector string // empty if the source is owned by this collector
+}
+
+// ClaimSources registers this collector as the owner of the sources not owned by another live collector
+// and returns the sources owned by other collectors together with their owners
+func (pgw *PostgresWriter) ClaimSources(dbnames []string) (map[string]string, error) {
+	sql := `WITH claimed AS (
+	INSERT INTO admin.source_owners AS o (dbname, collector)
+	SELECT unnest($1::text[]), $2
+	ON CONFLICT (dbname) DO UPDATE SET collector = excluded.collector, last_seen = now()
+	WHERE o.collector = excluded.collector OR o.last_seen < now() - $3 * interval '1 second'
+	RETURNING dbname
+)
+SELECT dbname, collector FROM admin.source_owners
+WHERE dbname = ANY($1) AND dbname NOT IN (SELECT dbname FROM claimed)`
+	rows, err := pgw.sinkDb.Query(pgw.ctx, sql, dbnames, pgw.opts.CollectorID, int(sourceOwnerLease.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	var dbname, collector string
+	owners := make(map[string]string)
+	_, err = pgx.ForEachRow(rows, []any{&dbname, &collector}, func() error {
+		owners[dbname] = collector
+		return nil
+	})
+	return owners, err
+}
+
+// ForeignSources returns the sources of the measurements written by other collectors to the sink.
+// Ownership is renewed once per sourceOwnerRefresh and every detected overlap is reported
+func (pgw *PostgresWriter) ForeignSources(msgs []metrics.MeasurementEnvelope) map[string]string {
+	if pgw.opts == nil || pgw.opts.DuplicateGuard == "" || pgw.opts.DuplicateGuard == DuplicateGuardOff {
+		return nil
+	}
+	now := time.Now()
+	toCheck := make([]string, 0)
+	for _, msg := range msgs {
+		if o, ok := pgw.owners[msg.DBName]; (!ok || now.Sub(o.checked) > sourceOwnerRefresh) && !slices.Contains(toCheck, msg.DBName) {
+			toCheck = append(toCheck, msg.DBName)
+		}
+	}
+	if len(toCheck) > 0 {
+		logger := log.GetLogger(pgw.ctx)
+		owners, err := pgw.ClaimSources(toCheck)
+		if err != nil {
+			logger.Error("Failed to register sources ownership: ", err)
+		} else {
+			for _, dbname := range toCheck {
+				collector := owners[dbname]
+				if collector > "" {
+					l := logger.WithField("source", dbname).WithField("collector", collector)
+					if pgw.opts.DuplicateGuard == DuplicateGuardDrop {
+						l.Warning("source is monitored by another collector, dropping measurements")
+					} else {
+						l.Warning("source is monitored by another collector, measurements are duplicated")
+					}
+				}
+				pgw.owners[dbname] = sourceOwner{checked: now, collector: collector}
+			}
+		}
+	}
+	foreign := make(map[string]string)
+	for dbname, o := range pgw.owners {
+		if o.collector > "" {
+			foreign[dbname] = o.collector
+		}
+	}
+	return foreign
+}
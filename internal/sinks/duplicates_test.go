@@ -0,0 +1,41 @@
+package sinks
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForeignSources(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	pgw := PostgresWriter{
+		ctx:    ctx,
+		sinkDb: conn,
+		opts:   &CmdOpts{DuplicateGuard: DuplicateGuardWarn, CollectorID: "collector1"},
+		owners: make(map[string]sourceOwner),
+	}
+	msgs := []metrics.MeasurementEnvelope{{DBName: "db1"}, {DBName: "db2"}, {DBName: "db1"}}
+
+	conn.ExpectQuery("INSERT INTO admin\\.source_owners").
+		WithArgs([]string{"db1", "db2"}, "collector1", int(sourceOwnerLease.Seconds())).
+		WillReturnRows(pgxmock.NewRows([]string{"dbname", "collector"}).AddRow("db2", "collector2"))
+	assert.Equal(t, map[string]string{"db2": "collector2"}, pgw.ForeignSources(msgs))
+
+	// cached ownership is not checked again
+	assert.Equal(t, map[string]string{"db2": "collector2"}, pgw.ForeignSources(msgs))
+
+	conn.ExpectQuery("INSERT INTO admin\\.source_owners").
+		WithArgs([]string{"db3"}, "collector1", int(sourceOwnerLease.Seconds())).
+		WillReturnError(errors.New("expected"))
+	assert.Equal(t, map[string]string{"db2": "collector2"}, pgw.ForeignSources([]metrics.MeasurementEnvelope{{DBName: "db3"}}))
+	assert.NotContains(t, pgw.owners, "db3", "failed checks are retried")
+
+	assert.NoError(t, conn.ExpectationsWereMet())
+
+	pgw.opts.DuplicateGuard = DuplicateGuardOff
+	assert.Nil(t, pgw.ForeignSources(msgs))
+}
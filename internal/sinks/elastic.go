@@ -0,0 +1,300 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+// elasticMaxBulkSize splits the batches into bulk requests well below the default http.max_content_length of 100MB
+const elasticMaxBulkSize = 5 << 20
+
+// elasticBuffers holds the buffers the bulk requests are encoded into, not to allocate new ones for every batch
+var elasticBuffers = sync.Pool{New: func() any { return &elasticBuffer{} }}
+
+type elasticBuffer struct {
+	b    []byte
+	keys []string // sorted tag or column names of the current row
+	tags map[string]string
+}
+
+// ElasticWriter is a sink indexing the measurements into Elasticsearch or OpenSearch via the bulk API. Every row is
+// a document with the "@timestamp", "dbname", "metric", "tags" and "data" fields, stored in the daily index of the
+// metric named <prefix>-<metric>-YYYY.MM.DD. The index template of the prefix is put on the first write
+type ElasticWriter struct {
+	ctx       context.Context
+	client    *http.Client
+	baseURL   string
+	prefix    string
+	user      *url.Userinfo
+	apiKey    string
+	templated atomic.Bool
+}
+
+// NewElasticWriter creates a writer for the server at address given as
+// [user:password@]host:port[/prefix][?api_key=key&tls=true], the index prefix defaults to "pgwatch"
+func NewElasticWriter(ctx context.Context, address string, opts *CmdOpts) (*ElasticWriter, error) {
+	u, err := url.Parse("elastic://" + address)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("elastic sink address %s should be host:port[/prefix]", log.Redact(address))
+	}
+	prefix := strings.ToLower(strings.Trim(u.Path, "/"))
+	if prefix == "" {
+		prefix = "pgwatch"
+	}
+	if strings.ContainsAny(prefix, `/\*?"<>| ,#:`) || strings.HasPrefix(prefix, "_") || strings.HasPrefix(prefix, "-") {
+		return nil, fmt.Errorf("invalid elastic index prefix %s", prefix)
+	}
+	scheme := "http"
+	if u.Query().Get("tls") == "true" {
+		scheme = "https"
+	}
+	timeout := opts.WriteTimeout
+	if timeout <= 0 {
+		timeout = influxDefaultTimeout
+	}
+	l := log.GetLogger(ctx).WithField("sink", "elastic").WithField("address", u.Host)
+	return &ElasticWriter{
+		ctx:     log.WithLogger(ctx, l),
+		client:  &http.Client{Timeout: timeout},
+		baseURL: scheme + "://" + u.Host,
+		prefix:  prefix,
+		user:    u.User,
+		apiKey:  u.Query().Get("api_key"),
+	}, nil
+}
+
+// Write indexes the measurements, split into bulk requests of at most elasticMaxBulkSize
+func (ew *ElasticWriter) Write(msgs []metrics.MeasurementEnvelope) error {
+	if ew.ctx.Err() != nil {
+		return ew.ctx.Err()
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	if !ew.templated.Load() {
+		if err := ew.putIndexTemplate(); err != nil {
+			return err
+		}
+		ew.templated.Store(true)
+	}
+	eb := elasticBuffers.Get().(*elasticBuffer)
+	defer elasticBuffers.Put(eb)
+	eb.b = eb.b[:0]
+	now := time.Now()
+	for _, msg := range msgs {
+		for _, row := range msg.Data {
+			eb.appendDoc(ew.prefix, msg, row, now)
+			if len(eb.b) >= elasticMaxBulkSize {
+				if err := ew.bulk(eb.b); err != nil {
+					return err
+				}
+				eb.b = eb.b[:0]
+			}
+		}
+	}
+	if len(eb.b) == 0 {
+		return nil
+	}
+	return ew.bulk(eb.b)
+}
+
+// bulk sends the NDJSON actions and documents, the error of the first rejected document is returned
+func (ew *ElasticWriter) bulk(body []byte) error {
+	req, err := http.NewRequestWithContext(ew.ctx, http.MethodPost, ew.baseURL+"/_bulk", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	resp, err := ew.do(req)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+			Error  struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err = json.Unmarshal(resp, &result); err != nil || !result.Errors {
+		return err
+	}
+	var failed int
+	var first string
+	for _, item := range result.Items {
+		for _, r := range item {
+			if r.Status >= http.StatusBadRequest {
+				if failed++; first == "" {
+					first = r.Error.Type + ": " + r.Error.Reason
+				}
+			}
+		}
+	}
+	return fmt.Errorf("elastic: %d of %d documents rejected: %s", failed, len(result.Items), first)
+}
+
+// putIndexTemplate creates or updates the index template of the prefix, so that the tags are stored as keywords
+func (ew *ElasticWriter) putIndexTemplate() error {
+	template := map[string]any{
+		"index_patterns": []string{ew.prefix + "-*"},
+		"template": map[string]any{
+			"mappings": map[string]any{
+				"dynamic_templates": []any{
+					map[string]any{"tags": map[string]any{
+						"path_match": "tags.*",
+						"mapping":    map[string]any{"type": "keyword"},
+					}},
+				},
+				"properties": map[string]any{
+					"@timestamp": map[string]any{"type": "date"},
+					"dbname":     map[string]any{"type": "keyword"},
+					"metric":     map[string]any{"type": "keyword"},
+				},
+			},
+		},
+	}
+	body, _ := json.Marshal(template)
+	req, err := http.NewRequestWithContext(ew.ctx, http.MethodPut, ew.baseURL+"/_index_template/"+ew.prefix, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	_, err = ew.do(req)
+	return err
+}
+
+// Ping checks the server answers
+func (ew *ElasticWriter) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ew.baseURL+"/", nil)
+	if err != nil {
+		return err
+	}
+	_, err = ew.do(req)
+	return err
+}
+
+// do sends the request with the credentials and returns the response body, or the error reason of the server
+func (ew *ElasticWriter) do(req *http.Request) ([]byte, error) {
+	switch {
+	case ew.apiKey > "":
+		req.Header.Set("Authorization", "ApiKey "+ew.apiKey)
+	case ew.user != nil:
+		password, _ := ew.user.Password()
+		req.SetBasicAuth(ew.user.Username(), password)
+	}
+	resp, err := ew.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || resp.StatusCode < http.StatusBadRequest {
+		return body, err
+	}
+	var apiErr struct {
+		Error struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &apiErr) == nil && apiErr.Error.Reason > "" {
+		return nil, fmt.Errorf("elastic: %s: %s: %s", resp.Status, apiErr.Error.Type, apiErr.Error.Reason)
+	}
+	if len(body) > 4096 {
+		body = body[:4096]
+	}
+	return nil, fmt.Errorf("elastic: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
+
+// SyncMetric is a no-op, the indices are created by the server on the first write
+func (ew *ElasticWriter) SyncMetric(_, _, _ string) error {
+	return nil
+}
+
+// appendDoc appends the index action and the document of the row. The custom tags and the "tag_" columns,
+// without the prefix, are the tags, the other columns the data. The values not representable in JSON, e.g. NaN,
+// are skipped. The timestamp of the rows without the epoch column is now
+func (eb *elasticBuffer) appendDoc(prefix string, msg metrics.MeasurementEnvelope, row metrics.Measurement, now time.Time) {
+	if eb.tags == nil {
+		eb.tags = make(map[string]string)
+	}
+	clear(eb.tags)
+	for k, v := range msg.CustomTags {
+		eb.tags[k] = v
+	}
+	for k, v := range row {
+		if v != nil && strings.HasPrefix(k, "tag_") {
+			eb.tags[k[4:]] = promString(v)
+		}
+	}
+	ts := now
+	if epochNs, ok := row[epochColumnName].(int64); ok {
+		ts = time.Unix(0, epochNs)
+	}
+	ts = ts.UTC()
+	b := append(eb.b, `{"index":{"_index":`...)
+	b = appendJSONString(b, prefix+"-"+strings.ToLower(msg.MetricName)+"-"+ts.Format("2006.01.02"))
+	b = append(b, `}}`+"\n"+`{"@timestamp":"`...)
+	b = ts.AppendFormat(b, time.RFC3339Nano)
+	b = append(b, `","dbname":`...)
+	b = appendJSONString(b, msg.DBName)
+	b = append(b, `,"metric":`...)
+	b = appendJSONString(b, msg.MetricName)
+	b = append(b, `,"tags":{`...)
+	eb.keys = eb.keys[:0]
+	for k := range eb.tags {
+		eb.keys = append(eb.keys, k)
+	}
+	slices.Sort(eb.keys)
+	for i, k := range eb.keys {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendJSONString(b, k)
+		b = append(b, ':')
+		b = appendJSONString(b, eb.tags[k])
+	}
+	b = append(b, `},"data":{`...)
+	eb.keys = eb.keys[:0]
+	for k := range row {
+		if k != epochColumnName && !strings.HasPrefix(k, "tag_") {
+			eb.keys = append(eb.keys, k)
+		}
+	}
+	slices.Sort(eb.keys)
+	sep := false
+	for _, k := range eb.keys {
+		start := len(b)
+		if sep {
+			b = append(b, ',')
+		}
+		b = appendJSONString(b, k)
+		b = append(b, ':')
+		var err error
+		if b, err = appendJSONValue(b, row[k]); err != nil {
+			b = b[:start]
+			continue
+		}
+		sep = true
+	}
+	eb.b = append(b, "}}\n"...)
+}
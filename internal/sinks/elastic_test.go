@@ -0,0 +1,153 @@
+package sinks
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeElastic accepts the index template and bulk requests, the documents with "reject" in the dbname fail
+type fakeElastic struct {
+	sync.Mutex
+	template map[string]any
+	indices  []string
+	docs     []map[string]any
+	bulks    int
+	auth     string
+}
+
+func (fe *fakeElastic) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fe.Lock()
+	defer fe.Unlock()
+	user, password, _ := r.BasicAuth()
+	fe.auth = user + ":" + password
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/":
+		_, _ = w.Write([]byte(`{"tagline":"You Know, for Search"}`))
+	case r.Method == http.MethodPut && r.URL.Path == "/_index_template/pgwatch":
+		_ = json.NewDecoder(r.Body).Decode(&fe.template)
+		_, _ = w.Write([]byte(`{"acknowledged":true}`))
+	case r.Method == http.MethodPost && r.URL.Path == "/_bulk":
+		fe.bulks++
+		var items []string
+		errors := false
+		scanner := bufio.NewScanner(r.Body)
+		scanner.Buffer(nil, 10<<20)
+		for scanner.Scan() {
+			var action struct {
+				Index struct {
+					Index string `json:"_index"`
+				} `json:"index"`
+			}
+			if err := json.Unmarshal(scanner.Bytes(), &action); err != nil || !scanner.Scan() {
+				http.Error(w, `{"error":{"type":"parse_exception","reason":"malformed action"}}`, http.StatusBadRequest)
+				return
+			}
+			var doc map[string]any
+			if err := json.Unmarshal(scanner.Bytes(), &doc); err != nil {
+				http.Error(w, `{"error":{"type":"parse_exception","reason":"malformed document"}}`, http.StatusBadRequest)
+				return
+			}
+			if strings.Contains(doc["dbname"].(string), "reject") {
+				errors = true
+				items = append(items, `{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse"}}}`)
+				continue
+			}
+			fe.indices = append(fe.indices, action.Index.Index)
+			fe.docs = append(fe.docs, doc)
+			items = append(items, `{"index":{"status":201}}`)
+		}
+		resp, _ := json.Marshal(map[string]any{"errors": errors, "items": json.RawMessage("[" + strings.Join(items, ",") + "]")})
+		_, _ = w.Write(resp)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestElasticWriter(t *testing.T) {
+	fe := &fakeElastic{}
+	srv := httptest.NewServer(fe)
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ew, err := NewElasticWriter(ctx, "elastic:secret@"+strings.TrimPrefix(srv.URL, "http://"), &CmdOpts{})
+	require.NoError(t, err)
+	assert.NoError(t, ew.Ping(ctx))
+	assert.NoError(t, ew.SyncMetric("db1", "change_events", "add"))
+	assert.NoError(t, ew.Write(nil))
+	assert.Nil(t, fe.template, "not created before the first write")
+
+	ts := time.Date(2024, 5, 1, 23, 59, 59, 123000000, time.UTC)
+	msgs := []metrics.MeasurementEnvelope{
+		{DBName: "db1", MetricName: "change_events", CustomTags: map[string]string{"env": "prod"},
+			Data: metrics.Measurements{{"epoch_ns": ts.UnixNano(), "details": "table altered", "tag_object": "public.t1"}}},
+		{DBName: "db2", MetricName: "Server_Log_Event_Counts",
+			Data: metrics.Measurements{
+				{"epoch_ns": ts.Add(time.Second).UnixNano(), "error": int64(2), "ratio": math.NaN()},
+				{"epoch_ns": ts.Add(time.Second).UnixNano(), "error": int64(3)},
+			}},
+	}
+	require.NoError(t, ew.Write(msgs))
+	assert.Equal(t, "elastic:secret", fe.auth)
+	assert.Equal(t, []any{"pgwatch-*"}, fe.template["index_patterns"])
+	assert.Equal(t, []string{"pgwatch-change_events-2024.05.01", "pgwatch-server_log_event_counts-2024.05.02",
+		"pgwatch-server_log_event_counts-2024.05.02"}, fe.indices, "daily indices of the metrics by the measurement time")
+	assert.Equal(t, map[string]any{
+		"@timestamp": "2024-05-01T23:59:59.123Z",
+		"dbname":     "db1",
+		"metric":     "change_events",
+		"tags":       map[string]any{"env": "prod", "object": "public.t1"},
+		"data":       map[string]any{"details": "table altered"},
+	}, fe.docs[0])
+	assert.Equal(t, map[string]any{"error": 2.0}, fe.docs[1]["data"], "NaN skipped")
+
+	msgs[0].DBName = "reject_db"
+	assert.EqualError(t, ew.Write(msgs), "elastic: 1 of 3 documents rejected: mapper_parsing_exception: failed to parse")
+
+	_, err = NewElasticWriter(ctx, "localhost:9200/_bad", &CmdOpts{})
+	assert.Error(t, err)
+	cancel()
+	assert.Error(t, ew.Write(msgs), "closed with the context")
+}
+
+func TestElasticWriterErrors(t *testing.T) {
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":{"type":"security_exception","reason":"unable to authenticate"},"status":401}`))
+	}))
+	defer srv.Close()
+	ew, err := NewElasticWriter(context.Background(), strings.TrimPrefix(srv.URL, "http://")+"/logs?api_key=c2VjcmV0", &CmdOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, "logs", ew.prefix)
+	msgs := []metrics.MeasurementEnvelope{{DBName: "db1", MetricName: "db_stats", Data: metrics.Measurements{{"xact_commit": int64(1)}}}}
+	assert.EqualError(t, ew.Write(msgs), "elastic: 401 Unauthorized: security_exception: unable to authenticate")
+	assert.Equal(t, "ApiKey c2VjcmV0", auth)
+	assert.False(t, ew.templated.Load(), "retried on the next write")
+}
+
+func TestElasticBulkSplit(t *testing.T) {
+	fe := &fakeElastic{}
+	srv := httptest.NewServer(fe)
+	defer srv.Close()
+	ew, err := NewElasticWriter(context.Background(), strings.TrimPrefix(srv.URL, "http://"), &CmdOpts{})
+	require.NoError(t, err)
+	big := string(bytes.Repeat([]byte{'x'}, elasticMaxBulkSize/2))
+	msg := metrics.MeasurementEnvelope{DBName: "db1", MetricName: "stat_statements", Data: metrics.Measurements{{"query": big}, {"query": big}, {"query": big}}}
+	require.NoError(t, ew.Write([]metrics.MeasurementEnvelope{msg}))
+	assert.Len(t, fe.docs, 3)
+	assert.Equal(t, 2, fe.bulks, "split not to exceed the bulk size")
+}
@@ -0,0 +1,96 @@
+package sinks
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+)
+
+// pgMaxIdentifierLen is the default max_identifier_length of Postgres in bytes, longer names are truncated
+const pgMaxIdentifierLen = 63
+
+// promName returns the name escaped to the Prometheus metric and label name charset [a-zA-Z_][a-zA-Z0-9_]*,
+// case is preserved, invalid characters incl. non-ASCII ones are replaced with "_"
+func promName(name string) string {
+	var b strings.Builder
+	b.Grow(len(name) + 1)
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// promLabelName returns the name escaped for a Prometheus label, names starting with "__" are reserved
+func promLabelName(name string) string {
+	name = promName(name)
+	if strings.HasPrefix(name, "__") {
+		name = "_" + strings.TrimLeft(name, "_")
+	}
+	return name
+}
+
+// promLabelValue returns the label value as valid UTF-8, the only requirement of Prometheus
+func promLabelValue(value string) string {
+	return strings.ToValidUTF8(value, "�")
+}
+
+// pgText returns the value without NUL characters and invalid UTF-8 rejected by the Postgres text and jsonb types
+func pgText(value string) string {
+	value = strings.ToValidUTF8(value, "�")
+	if strings.IndexByte(value, 0) >= 0 {
+		value = strings.ReplaceAll(value, "\x00", "")
+	}
+	return value
+}
+
+// pgIdentifier returns the identifier as Postgres stores it, i.e. truncated to the max identifier length
+// without splitting multi-byte characters
+func pgIdentifier(name string) string {
+	if len(name) <= pgMaxIdentifierLen {
+		return name
+	}
+	name = name[:pgMaxIdentifierLen]
+	for !utf8.ValidString(name) {
+		name = name[:len(name)-1]
+	}
+	return name
+}
+
+// nameCollisions detects distinct names stored under the same escaped name by a sink, as their series or
+// rows get mixed up. Every collision is reported once
+type nameCollisions struct {
+	sync.Mutex
+	originals map[string]string // [sink.kind.escaped]=original, empty if reported
+}
+
+var escapedNames = &nameCollisions{originals: make(map[string]string)}
+
+// check records that the original name of the given kind, e.g. "label", is stored as escaped
+func (c *nameCollisions) check(ctx context.Context, sink, kind, original, escaped string) {
+	key := sink + "." + kind + "." + escaped
+	c.Lock()
+	defer c.Unlock()
+	prev, ok := c.originals[key]
+	if !ok {
+		c.originals[key] = original
+		return
+	}
+	if prev != original && prev != "" {
+		log.GetLogger(ctx).WithField("sink", sink).WithField(kind, escaped).
+			Warningf("%s names %q and %q are both stored as %q and can't be told apart", kind, prev, original, escaped)
+		c.originals[key] = "" // reported
+	}
+}
@@ -0,0 +1,66 @@
+package sinks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPromName(t *testing.T) {
+	assert.Equal(t, "MyDB_stats", promName("MyDB.stats"))
+	assert.Equal(t, "_1st_metric", promName("1st metric"))
+	assert.Equal(t, "caf__stats", promName("café-stats"), "case is preserved, non-ASCII characters replaced")
+	assert.Equal(t, "_reserved", promLabelName("__reserved"))
+	assert.Equal(t, "dbname", promLabelName("dbname"))
+	assert.Equal(t, "db�", promLabelValue("db\xff"))
+}
+
+func TestPgEscaping(t *testing.T) {
+	assert.Equal(t, "ab", pgText("a\x00b"))
+	assert.Equal(t, "Ünïcödé", pgText("Ünïcödé"))
+
+	assert.Equal(t, "short", pgIdentifier("short"))
+	long := strings.Repeat("a", 62) + "ü" // 64 bytes
+	assert.Equal(t, strings.Repeat("a", 62), pgIdentifier(long), "multi-byte characters are not split")
+	assert.Len(t, pgIdentifier(strings.Repeat("b", 100)), pgMaxIdentifierLen)
+}
+
+func TestNameCollisions(t *testing.T) {
+	c := &nameCollisions{originals: make(map[string]string)}
+	ctx := context.Background()
+	c.check(ctx, "prometheus", "label", "my-tag", "my_tag")
+	c.check(ctx, "prometheus", "label", "my-tag", "my_tag")
+	assert.Equal(t, "my-tag", c.originals["prometheus.label.my_tag"])
+	c.check(ctx, "prometheus", "label", "my_tag", "my_tag")
+	assert.Equal(t, "", c.originals["prometheus.label.my_tag"], "collision reported")
+	c.check(ctx, "postgres", "label", "my_tag", "my_tag")
+	assert.Equal(t, "my_tag", c.originals["postgres.label.my_tag"], "sinks are independent")
+}
+
+func TestPrometheusExoticNames(t *testing.T) {
+	promw := newTestPrometheusWriter()
+	_ = promw.SyncMetric("Ünïcödé db", "my.metric", "add")
+	defer func() { _ = promw.SyncMetric("Ünïcödé db", "", "remove") }()
+	assert.NoError(t, promw.Write([]metrics.MeasurementEnvelope{{
+		DBName:     "Ünïcödé db",
+		MetricName: "my.metric",
+		CustomTags: map[string]string{"team-name": "Ops"},
+		Data:       metrics.Measurements{{epochColumnName: time.Now().UnixNano(), "tag_Schema.Name": "Öffentlich", "rows-read": int64(5)}},
+	}}))
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rr := httptest.NewRecorder()
+	promw.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	body := rr.Body.String()
+	assert.Contains(t, body, "pgwatch_my_metric_rows_read{")
+	assert.Contains(t, body, `dbname="Ünïcödé db"`)
+	assert.Contains(t, body, `Schema_Name="Öffentlich"`)
+	assert.Contains(t, body, `team_name="Ops"`)
+}
@@ -0,0 +1,168 @@
+package sinks
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/api/pb"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/ingest"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// GRPCWriter is a sink pushing the measurements to the ingest endpoint of a central pgwatch collector, e.g.
+// from a lightweight agent running on the database host. The collector routes, batches and stores them in its sinks.
+// If a spool directory is set, the measurements that could not be pushed are kept on disk and pushed later in order
+type GRPCWriter struct {
+	ctx      context.Context
+	conn     *grpc.ClientConn
+	client   pb.IngestClient
+	token    string
+	spool    *spool
+	spooling atomic.Bool // the collector is unreachable and the measurements are spooled
+	stats    *writeStats // to account the replays of the spool as retries
+}
+
+// NewGRPCWriter connects to the collector at address given as host:port[?token=secret&tls=true]
+func NewGRPCWriter(ctx context.Context, address string, opts *CmdOpts) (*GRPCWriter, error) {
+	u, err := url.Parse("grpc://" + address)
+	if err != nil {
+		return nil, err
+	}
+	creds := insecure.NewCredentials()
+	if u.Query().Get("tls") == "true" {
+		creds = credentials.NewTLS(&tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12})
+	}
+	conn, err := grpc.NewClient(u.Host, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("could not create gRPC client for %s: %w", u.Host, err)
+	}
+	l := log.GetLogger(ctx).WithField("sink", "grpc").WithField("address", u.Host)
+	gw := &GRPCWriter{
+		ctx:    log.WithLogger(ctx, l),
+		conn:   conn,
+		client: pb.NewIngestClient(conn),
+		token:  u.Query().Get("token"),
+	}
+	if opts.SpoolDir > "" {
+		if err = os.MkdirAll(opts.SpoolDir, 0700); err != nil {
+			return nil, err
+		}
+		name := "grpc_" + strings.NewReplacer(":", "_", "/", "_").Replace(u.Host) + ".spool"
+		gw.spool = newSpool(filepath.Join(opts.SpoolDir, name), opts.SpoolMaxSize<<20)
+	}
+	go gw.watchCtx()
+	return gw, nil
+}
+
+// Write pushes the measurements in a single call, after the spooled ones if any
+func (gw *GRPCWriter) Write(msgs []metrics.MeasurementEnvelope) error {
+	if gw.ctx.Err() != nil {
+		return gw.ctx.Err()
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	req := &pb.PushRequest{Measurements: make([]*pb.MeasurementEnvelope, 0, len(msgs))}
+	for _, msg := range msgs {
+		req.Measurements = append(req.Measurements, ingest.ToProto(msg))
+	}
+	if gw.spool == nil {
+		return gw.push(req)
+	}
+	err := gw.spool.Replay(gw.replay)
+	if err != nil && !isTransient(err) {
+		log.GetLogger(gw.ctx).WithError(err).Error("could not replay the spooled measurements")
+		err = nil
+	}
+	if err == nil {
+		err = gw.push(req)
+	}
+	if err == nil || !isTransient(err) {
+		if gw.spooling.CompareAndSwap(true, false) && err == nil {
+			log.GetLogger(gw.ctx).Info("collector is reachable again, spooled measurements pushed")
+		}
+		return err
+	}
+	b, e := proto.Marshal(req)
+	if e == nil {
+		e = gw.spool.Append(b)
+	}
+	if e != nil {
+		return errors.Join(err, e)
+	}
+	if !gw.spooling.Swap(true) {
+		log.GetLogger(gw.ctx).WithError(err).Warning("collector is unreachable, spooling measurements")
+	}
+	return nil
+}
+
+func (gw *GRPCWriter) push(req *pb.PushRequest) error {
+	ctx := gw.ctx
+	if gw.token > "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+gw.token)
+	}
+	_, err := gw.client.Push(ctx, req)
+	return err
+}
+
+// replay pushes a spooled batch. Batches rejected by the collector are dropped, not to block the spool forever
+func (gw *GRPCWriter) replay(batch []byte) error {
+	req := new(pb.PushRequest)
+	if err := proto.Unmarshal(batch, req); err != nil {
+		log.GetLogger(gw.ctx).WithError(err).Error("dropping corrupted spooled measurements")
+		return nil
+	}
+	err := gw.push(req)
+	if gw.stats != nil {
+		names := make([]string, 0, len(req.Measurements))
+		for _, e := range req.Measurements {
+			names = append(names, e.GetMetricName())
+		}
+		gw.stats.retried(time.Now(), slices.Compact(slices.Sorted(slices.Values(names)))...)
+	}
+	if status.Code(err) == codes.InvalidArgument {
+		log.GetLogger(gw.ctx).WithError(err).Error("dropping spooled measurements rejected by the collector")
+		return nil
+	}
+	return err
+}
+
+// isTransient returns true if the push may succeed later, e.g. once the network is back
+func isTransient(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
+
+func (gw *GRPCWriter) setWriteStats(ws *writeStats) {
+	gw.stats = ws
+}
+
+// SyncMetric is a no-op, the collector manages the storage of the pushed metrics itself
+func (gw *GRPCWriter) SyncMetric(_, _, _ string) error {
+	return nil
+}
+
+func (gw *GRPCWriter) watchCtx() {
+	<-gw.ctx.Done()
+	gw.conn.Close()
+}
@@ -0,0 +1,89 @@
+package sinks
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/ingest"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+)
+
+// freeAddress returns a local address nothing listens on
+func freeAddress(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestGRPCWriter(t *testing.T) {
+	addr := freeAddress(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := make(chan []metrics.MeasurementEnvelope, 1)
+	srv := ingest.NewServer(ch, func(string) (metrics.Metric, bool) { return metrics.Metric{}, false }, "secret")
+	go func() { _ = srv.Serve(ctx, addr) }()
+
+	msgs := []metrics.MeasurementEnvelope{{DBName: "db1", MetricName: "cpu_load", Data: metrics.Measurements{{"epoch_ns": int64(1), "load_1min": 0.5}}}}
+	gw, err := NewGRPCWriter(ctx, addr+"?token=secret", &CmdOpts{})
+	require.NoError(t, err)
+	assert.NoError(t, gw.SyncMetric("db1", "cpu_load", "add"))
+	assert.NoError(t, gw.Write(nil))
+	require.Eventually(t, func() bool { return gw.Write(msgs) == nil }, 5*time.Second, 50*time.Millisecond)
+	got := <-ch
+	assert.Equal(t, "db1", got[0].DBName)
+	assert.Equal(t, 0.5, got[0].Data[0]["load_1min"])
+
+	unauthorized, err := NewGRPCWriter(ctx, addr, &CmdOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(unauthorized.Write(msgs)))
+
+	cancel()
+	assert.Error(t, gw.Write(msgs), "closed with the context")
+}
+
+func TestGRPCWriterSpool(t *testing.T) {
+	addr := freeAddress(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gw, err := NewGRPCWriter(ctx, addr, &CmdOpts{SpoolDir: t.TempDir(), SpoolMaxSize: 1})
+	require.NoError(t, err)
+	gw.setWriteStats(newWriteStats(time.Hour))
+
+	batch := func(db string) []metrics.MeasurementEnvelope {
+		return []metrics.MeasurementEnvelope{{DBName: db, MetricName: "cpu_load", Data: metrics.Measurements{{"epoch_ns": int64(1)}}}}
+	}
+	assert.NoError(t, gw.Write(batch("db1")), "collector is down, spooled")
+	assert.NoError(t, gw.Write(batch("db2")))
+	assert.True(t, gw.spooling.Load())
+	assert.Positive(t, gw.spool.Size())
+
+	ch := make(chan []metrics.MeasurementEnvelope, 3)
+	srv := ingest.NewServer(ch, func(string) (metrics.Metric, bool) { return metrics.Metric{}, false }, "")
+	go func() { _ = srv.Serve(ctx, addr) }()
+	require.Eventually(t, func() bool {
+		gw.conn.ResetConnectBackoff()
+		return gw.conn.GetState() == connectivity.Ready
+	}, 5*time.Second, 50*time.Millisecond)
+	require.NoError(t, gw.Write(batch("db3")))
+	assert.False(t, gw.spooling.Load())
+	assert.Zero(t, gw.spool.Size(), "spool replayed")
+	var dbs []string
+	for len(ch) > 0 {
+		dbs = append(dbs, (<-ch)[0].DBName)
+	}
+	assert.Equal(t, []string{"db1", "db2", "db3"}, dbs, "spooled measurements are pushed first")
+	var retries int64
+	for _, b := range gw.stats.get("grpc").Buckets {
+		retries += b.Metrics[0].Retries
+	}
+	assert.EqualValues(t, 3, retries, "failed and successful replays are accounted as retries")
+}
@@ -0,0 +1,171 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/faults"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+// Pinger is implemented by the sinks able to check the availability of their storage
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// HealthReporter returns the health of the configured sinks
+type HealthReporter interface {
+	Health() []SinkHealth
+}
+
+// MaintenanceReporter is implemented by the sinks running background maintenance of their storage
+type MaintenanceReporter interface {
+	MaintenanceStats() any
+}
+
+// SinkHealth describes the state of a sink as seen by the writes and the periodic health checks
+type SinkHealth struct {
+	Sink          string    `json:"sink"`
+	Healthy       bool      `json:"healthy"`
+	LastError     string    `json:"last_error,omitempty"`
+	LastErrorTime time.Time `json:"last_error_time"`
+	LastWriteTime time.Time `json:"last_write_time"`       // last successful write
+	LastPingTime  time.Time `json:"last_ping_time"`        // last successful health check
+	WriteTimeouts int64     `json:"write_timeouts"`        // writes not finished within --sink-write-timeout
+	DroppedWrites int64     `json:"dropped_writes"`        // batches dropped while the sink was busy with a timed out write
+	Maintenance   any       `json:"maintenance,omitempty"` // background storage maintenance stats, if any
+}
+
+// sinkState tracks the health of a single writer of the MultiWriter
+type sinkState struct {
+	sync.Mutex
+	health  SinkHealth
+	pending atomic.Bool // a timed out write is still in progress
+	stats   *writeStats
+}
+
+func newSinkState(name string, statsHistory time.Duration) *sinkState {
+	return &sinkState{health: SinkHealth{Sink: name, Healthy: true}, stats: newWriteStats(statsHistory)}
+}
+
+func (s *sinkState) get() SinkHealth {
+	s.Lock()
+	defer s.Unlock()
+	return s.health
+}
+
+// update records the outcome of a write or a health check
+func (s *sinkState) update(err error, ping bool) {
+	s.Lock()
+	defer s.Unlock()
+	s.health.Healthy = err == nil
+	switch {
+	case err != nil:
+		s.health.LastError = err.Error()
+		s.health.LastErrorTime = time.Now()
+	case ping:
+		s.health.LastPingTime = time.Now()
+	default:
+		s.health.LastWriteTime = time.Now()
+	}
+}
+
+func (s *sinkState) count(counter *int64) {
+	s.Lock()
+	*counter++
+	s.Unlock()
+}
+
+// writeOrFail writes the measurements unless a write error is injected, and accounts the write
+func (s *sinkState) writeOrFail(w Writer, msgs []metrics.MeasurementEnvelope) error {
+	start := time.Now()
+	err := faults.SinkWriteError(s.health.Sink)
+	if err == nil {
+		err = w.Write(msgs)
+	}
+	s.stats.record(start, msgs, time.Since(start), err)
+	return err
+}
+
+// write stores the measurements to the sink within the timeout. A timed out write is left running,
+// the following batches are dropped until it finishes, so that a hanging sink doesn't block the others
+func (s *sinkState) write(w Writer, msgs []metrics.MeasurementEnvelope, timeout time.Duration) error {
+	if timeout <= 0 {
+		err := s.writeOrFail(w, msgs)
+		s.update(err, false)
+		return err
+	}
+	if !s.pending.CompareAndSwap(false, true) {
+		s.count(&s.health.DroppedWrites)
+		err := fmt.Errorf("sink %s is busy with a timed out write, measurements dropped", s.health.Sink)
+		s.stats.record(time.Now(), msgs, 0, err)
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		err := s.writeOrFail(w, msgs)
+		s.pending.Store(false)
+		s.update(err, false)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		s.count(&s.health.WriteTimeouts)
+		err := fmt.Errorf("write to sink %s timed out after %v", s.health.Sink, timeout)
+		s.update(err, false)
+		return err
+	}
+}
+
+// Health returns the health of every sink
+func (mw *MultiWriter) Health() []SinkHealth {
+	mw.Lock()
+	defer mw.Unlock()
+	health := make([]SinkHealth, 0, len(mw.states))
+	for i, s := range mw.states {
+		h := s.get()
+		if r, ok := mw.writers[i].(MaintenanceReporter); ok {
+			h.Maintenance = r.MaintenanceStats()
+		}
+		health = append(health, h)
+	}
+	return health
+}
+
+// CheckHealth pings the sinks supporting it
+func (mw *MultiWriter) CheckHealth(ctx context.Context, timeout time.Duration) {
+	mw.Lock()
+	writers, states := mw.writers, mw.states
+	mw.Unlock()
+	for i, w := range writers {
+		p, ok := w.(Pinger)
+		if !ok {
+			continue
+		}
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := p.Ping(pingCtx)
+		cancel()
+		if err != nil {
+			log.GetLogger(ctx).WithField("sink", states[i].health.Sink).WithError(err).Warning("sink health check failed")
+		}
+		states[i].update(err, true)
+	}
+}
+
+// watchHealth runs the health checks on the interval until the context is cancelled
+func (mw *MultiWriter) watchHealth(ctx context.Context, interval time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		mw.CheckHealth(ctx, interval)
+	}
+}
@@ -0,0 +1,100 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/faults"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+type SlowWriter struct {
+	MockWriter
+	release chan struct{}
+	pingErr error
+}
+
+func (sw *SlowWriter) Write(_ []metrics.MeasurementEnvelope) error {
+	<-sw.release
+	return nil
+}
+
+func (sw *SlowWriter) Ping(_ context.Context) error {
+	return sw.pingErr
+}
+
+func TestMultiWriterWriteTimeout(t *testing.T) {
+	sw := &SlowWriter{release: make(chan struct{})}
+	mw := &MultiWriter{writeTimeout: 10 * time.Millisecond}
+	mw.AddWriter(&MockWriter{})
+	mw.AddWriter(sw)
+	msgs := []metrics.MeasurementEnvelope{{DBName: "db"}}
+
+	assert.NoError(t, mw.states[0].write(mw.writers[0], msgs, mw.writeTimeout))
+	assert.ErrorContains(t, mw.states[1].write(sw, msgs, mw.writeTimeout), "timed out")
+	assert.ErrorContains(t, mw.states[1].write(sw, msgs, mw.writeTimeout), "busy", "writes must be dropped while the previous one hangs")
+
+	health := mw.Health()
+	assert.Len(t, health, 2)
+	assert.True(t, health[0].Healthy)
+	assert.False(t, health[0].LastWriteTime.IsZero())
+	assert.False(t, health[1].Healthy)
+	assert.EqualValues(t, 1, health[1].WriteTimeouts)
+	assert.EqualValues(t, 1, health[1].DroppedWrites)
+
+	close(sw.release) // the sink recovers
+	assert.Eventually(t, func() bool { return mw.Health()[1].Healthy }, time.Second, 5*time.Millisecond)
+	assert.NoError(t, mw.states[1].write(sw, msgs, mw.writeTimeout))
+}
+
+func TestMultiWriterCheckHealth(t *testing.T) {
+	sw := &SlowWriter{pingErr: errors.New("connection refused")}
+	mw := &MultiWriter{}
+	mw.AddWriter(&MockWriter{})
+	mw.AddWriter(sw)
+
+	mw.CheckHealth(context.Background(), time.Second)
+	health := mw.Health()
+	assert.True(t, health[0].Healthy, "sinks without ping support are not checked")
+	assert.False(t, health[1].Healthy)
+	assert.Equal(t, "connection refused", health[1].LastError)
+
+	sw.pingErr = nil
+	mw.CheckHealth(context.Background(), time.Second)
+	health = mw.Health()
+	assert.True(t, health[1].Healthy)
+	assert.False(t, health[1].LastPingTime.IsZero())
+}
+
+func TestMultiWriterInjectedWriteErrors(t *testing.T) {
+	faults.Init(faults.CmdOpts{SinkWriteErrors: 1, Seed: 1})
+	defer faults.Init(faults.CmdOpts{})
+	mw := &MultiWriter{}
+	mw.AddWriter(&MockWriter{})
+	msgs := []metrics.MeasurementEnvelope{{DBName: "db"}}
+
+	assert.ErrorIs(t, mw.states[0].write(mw.writers[0], msgs, 0), faults.ErrInjected)
+	assert.False(t, mw.Health()[0].Healthy, "injected errors are handled as real ones")
+
+	faults.Init(faults.CmdOpts{})
+	assert.NoError(t, mw.states[0].write(mw.writers[0], msgs, 0))
+	assert.True(t, mw.Health()[0].Healthy)
+}
+
+func TestPrometheusSinksHealth(t *testing.T) {
+	mw := &MultiWriter{}
+	mw.AddWriter(&MockWriter{})
+	promw := &PrometheusWriter{PrometheusNamespace: "pgwatch"}
+	ch := make(chan prometheus.Metric, 10)
+	promw.collectSinksHealth(ch)
+	assert.Len(t, ch, 0, "no health reporter set")
+
+	promw.sinksHealth = mw
+	promw.collectSinksHealth(ch)
+	assert.Len(t, ch, 3)
+	assert.Contains(t, (<-ch).Desc().String(), "pgwatch_sink_up")
+}
@@ -0,0 +1,270 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+// influxDefaultTimeout bounds the HTTP requests if no --sink-write-timeout is set
+const influxDefaultTimeout = 30 * time.Second
+
+// influxBuffers holds the buffers the batches are encoded into, not to allocate new ones for every batch
+var influxBuffers = sync.Pool{New: func() any { return &influxBuffer{} }}
+
+type influxBuffer struct {
+	b    []byte
+	keys []string // sorted tag or field names of the current row
+	tags map[string]string
+}
+
+// InfluxWriter is a sink writing the measurements in the line protocol to the HTTP API of InfluxDB v2,
+// or of any other storage accepting it on /api/v2/write, e.g. VictoriaMetrics. Every metric is stored
+// as a measurement, the "tag_" columns, the custom tags and the source name as tags, the others as fields
+type InfluxWriter struct {
+	ctx      context.Context
+	client   *http.Client
+	writeURL string
+	token    string
+	health   string
+}
+
+// NewInfluxWriter creates a writer for the server at address given as host:port/bucket[?org=name&token=secret&tls=true]
+func NewInfluxWriter(ctx context.Context, address string, opts *CmdOpts) (*InfluxWriter, error) {
+	u, err := url.Parse("influx://" + address)
+	if err != nil {
+		return nil, err
+	}
+	bucket := strings.Trim(u.Path, "/")
+	if u.Host == "" || bucket == "" {
+		return nil, fmt.Errorf("influx sink address %s should be host:port/bucket", log.Redact(address))
+	}
+	scheme := "http"
+	if u.Query().Get("tls") == "true" {
+		scheme = "https"
+	}
+	params := url.Values{"bucket": {bucket}, "precision": {"ns"}}
+	if org := u.Query().Get("org"); org > "" {
+		params.Set("org", org)
+	}
+	timeout := opts.WriteTimeout
+	if timeout <= 0 {
+		timeout = influxDefaultTimeout
+	}
+	l := log.GetLogger(ctx).WithField("sink", "influx").WithField("address", u.Host)
+	return &InfluxWriter{
+		ctx:      log.WithLogger(ctx, l),
+		client:   &http.Client{Timeout: timeout},
+		writeURL: scheme + "://" + u.Host + "/api/v2/write?" + params.Encode(),
+		token:    u.Query().Get("token"),
+		health:   scheme + "://" + u.Host + "/health",
+	}, nil
+}
+
+// Write posts the measurements in a single request
+func (iw *InfluxWriter) Write(msgs []metrics.MeasurementEnvelope) error {
+	if iw.ctx.Err() != nil {
+		return iw.ctx.Err()
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	ib := influxBuffers.Get().(*influxBuffer)
+	defer influxBuffers.Put(ib)
+	ib.b = ib.b[:0]
+	now := time.Now().UnixNano()
+	for _, msg := range msgs {
+		ib.appendEnvelope(msg, now)
+	}
+	if len(ib.b) == 0 {
+		return nil
+	}
+	req, err := http.NewRequestWithContext(iw.ctx, http.MethodPost, iw.writeURL, bytes.NewReader(ib.b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	return iw.do(req, http.StatusNoContent)
+}
+
+// Ping checks the health endpoint of the server
+func (iw *InfluxWriter) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, iw.health, nil)
+	if err != nil {
+		return err
+	}
+	return iw.do(req, http.StatusOK)
+}
+
+// do sends the request with the token and returns the error message of the server if the status is not the expected one
+func (iw *InfluxWriter) do(req *http.Request, status int) error {
+	if iw.token > "" {
+		req.Header.Set("Authorization", "Token "+iw.token)
+	}
+	resp, err := iw.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode == status || status == http.StatusNoContent && resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var apiErr struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &apiErr) == nil && apiErr.Message > "" {
+		return fmt.Errorf("influx: %s: %s", resp.Status, apiErr.Message)
+	}
+	return fmt.Errorf("influx: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
+
+// SyncMetric is a no-op, the measurements are created by the server on the first write
+func (iw *InfluxWriter) SyncMetric(_, _, _ string) error {
+	return nil
+}
+
+// appendEnvelope appends a line per row of the measurements, the rows without fields are skipped.
+// The timestamp of the rows without the epoch column is now
+func (ib *influxBuffer) appendEnvelope(msg metrics.MeasurementEnvelope, now int64) {
+	if ib.tags == nil {
+		ib.tags = make(map[string]string)
+	}
+	for _, row := range msg.Data {
+		clear(ib.tags)
+		for k, v := range msg.CustomTags {
+			ib.tags[k] = v
+		}
+		ib.tags["dbname"] = msg.DBName
+		for k, v := range row {
+			if v != nil && strings.HasPrefix(k, "tag_") {
+				ib.tags[k[4:]] = promString(v)
+			}
+		}
+		line := len(ib.b)
+		ib.b = appendInfluxName(ib.b, msg.MetricName, ", ")
+		ib.keys = ib.keys[:0]
+		for k, v := range ib.tags {
+			if k > "" && v > "" {
+				ib.keys = append(ib.keys, k)
+			}
+		}
+		slices.Sort(ib.keys)
+		for _, k := range ib.keys {
+			ib.b = append(ib.b, ',')
+			ib.b = appendInfluxName(ib.b, k, ",= ")
+			ib.b = append(ib.b, '=')
+			ib.b = appendInfluxName(ib.b, ib.tags[k], ",= ")
+		}
+		ib.keys = ib.keys[:0]
+		for k, v := range row {
+			if v != nil && k != epochColumnName && !strings.HasPrefix(k, "tag_") {
+				ib.keys = append(ib.keys, k)
+			}
+		}
+		slices.Sort(ib.keys)
+		sep := byte(' ')
+		for _, k := range ib.keys {
+			fieldStart := len(ib.b)
+			ib.b = append(ib.b, sep)
+			ib.b = appendInfluxName(ib.b, k, ",= ")
+			ib.b = append(ib.b, '=')
+			var ok bool
+			if ib.b, ok = appendInfluxValue(ib.b, row[k]); !ok {
+				ib.b = ib.b[:fieldStart]
+				continue
+			}
+			sep = ','
+		}
+		if sep == ' ' {
+			ib.b = ib.b[:line] // no fields, the line protocol requires at least one
+			continue
+		}
+		epochNs, ok := row[epochColumnName].(int64)
+		if !ok {
+			epochNs = now
+		}
+		ib.b = append(ib.b, ' ')
+		ib.b = strconv.AppendInt(ib.b, epochNs, 10)
+		ib.b = append(ib.b, '\n')
+	}
+}
+
+// appendInfluxName appends the measurement name, tag key or value, or field key with the special characters
+// backslash escaped. Newlines are not allowed in the line protocol and are written as \n. A backslash before
+// a special character or at the end would escape the one following, so it's doubled
+func appendInfluxName(b []byte, s, special string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\n':
+			b = append(b, '\\', 'n')
+		case strings.IndexByte(special, c) >= 0,
+			c == '\\' && (i == len(s)-1 || strings.IndexByte(special, s[i+1]) >= 0):
+			b = append(b, '\\', c)
+		default:
+			b = append(b, c)
+		}
+	}
+	return b
+}
+
+// appendInfluxValue appends the field value in the line protocol, false is returned for values not representable
+// in it, i.e. NaN and infinity. The types not returned by the metric queries usually are stored as JSON strings
+func appendInfluxValue(b []byte, v any) ([]byte, bool) {
+	switch val := v.(type) {
+	case string:
+		return appendInfluxString(b, val), true
+	case bool:
+		return strconv.AppendBool(b, val), true
+	case int:
+		return append(strconv.AppendInt(b, int64(val), 10), 'i'), true
+	case int16:
+		return append(strconv.AppendInt(b, int64(val), 10), 'i'), true
+	case int32:
+		return append(strconv.AppendInt(b, int64(val), 10), 'i'), true
+	case int64:
+		return append(strconv.AppendInt(b, val, 10), 'i'), true
+	case uint32:
+		return append(strconv.AppendUint(b, uint64(val), 10), 'u'), true
+	case uint64:
+		return append(strconv.AppendUint(b, val, 10), 'u'), true
+	case float32:
+		return appendInfluxFloat(b, float64(val), 32)
+	case float64:
+		return appendInfluxFloat(b, val, 64)
+	case time.Time:
+		return appendInfluxString(b, val.Format(time.RFC3339Nano)), true
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return b, false
+	}
+	return appendInfluxString(b, string(data)), true
+}
+
+func appendInfluxFloat(b []byte, f float64, bits int) ([]byte, bool) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return b, false
+	}
+	return strconv.AppendFloat(b, f, 'g', -1, bits), true
+}
+
+// appendInfluxString appends the quoted string field value
+func appendInfluxString(b []byte, s string) []byte {
+	b = append(b, '"')
+	b = appendInfluxName(b, s, `"\`)
+	return append(b, '"')
+}
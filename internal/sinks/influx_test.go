@@ -0,0 +1,88 @@
+package sinks
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInfluxWriter(t *testing.T) {
+	var body, query, auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.WriteHeader(http.StatusOK)
+		case "/api/v2/write":
+			b, _ := io.ReadAll(r.Body)
+			body, query, auth = string(b), r.URL.RawQuery, r.Header.Get("Authorization")
+			if strings.Contains(body, "bad") {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"code":"invalid","message":"unable to parse"}`))
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	address := strings.TrimPrefix(srv.URL, "http://")
+	iw, err := NewInfluxWriter(ctx, address+"/pgwatch?org=dba&token=secret", &CmdOpts{})
+	require.NoError(t, err)
+	assert.NoError(t, iw.Ping(ctx))
+	assert.NoError(t, iw.SyncMetric("db1", "db_stats", "add"))
+	assert.NoError(t, iw.Write(nil))
+
+	msgs := []metrics.MeasurementEnvelope{{
+		DBName:     "db 1",
+		MetricName: "table_stats",
+		CustomTags: map[string]string{"env": "prod"},
+		Data: metrics.Measurements{
+			{epochColumnName: int64(1700000000000000000), "tag_table": "public.t,1", "seq_scan": int64(5), "ratio": 0.5, "is_part": false, "name": `say "hi"`},
+			{epochColumnName: int64(1700000000000000001), "tag_table": "empty", "n": nil, "nan": math.NaN()},
+		},
+	}}
+	require.NoError(t, iw.Write(msgs))
+	assert.Equal(t, "Token secret", auth)
+	assert.Equal(t, "bucket=pgwatch&org=dba&precision=ns", query)
+	assert.Equal(t, `table_stats,dbname=db\ 1,env=prod,table=public.t\,1 is_part=false,name="say \"hi\"",ratio=0.5,seq_scan=5i 1700000000000000000`+"\n", body,
+		"tags and fields are sorted, the row with no storable field is skipped")
+
+	msgs[0].MetricName = "bad"
+	assert.EqualError(t, iw.Write(msgs), "influx: 400 Bad Request: unable to parse")
+
+	_, err = NewInfluxWriter(ctx, address, &CmdOpts{})
+	assert.Error(t, err, "bucket is required")
+
+	cancel()
+	assert.Error(t, iw.Write(msgs), "closed with the context")
+}
+
+func TestInfluxEncoding(t *testing.T) {
+	ib := &influxBuffer{}
+	ib.appendEnvelope(metrics.MeasurementEnvelope{
+		DBName:     "db1",
+		MetricName: "m",
+		Data: metrics.Measurements{{
+			"tag_path":  `C:\`,
+			"tag_multi": "a\nb",
+			"u":         uint64(7),
+			"f32":       float32(0.1),
+			"big":       1e21,
+			"t":         time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			"j":         map[string]any{"k": 1},
+			"s":         `back\slash`,
+		}},
+	}, 42)
+	assert.Equal(t, `m,dbname=db1,multi=a\nb,path=C:\\ big=1e+21,f32=0.1,j="{\"k\":1}",s="back\\slash",t="2024-01-02T03:04:05Z",u=7u 42`+"\n", string(ib.b))
+}
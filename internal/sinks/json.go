@@ -2,7 +2,7 @@ package sinks
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
@@ -37,24 +37,43 @@ func (jw *JSONWriter) Write(msgs []metrics.MeasurementEnvelope) error {
 	if len(msgs) == 0 {
 		return nil
 	}
-	enc := json.NewEncoder(jw.lw)
 	t1 := time.Now()
+	jb := jsonBuffers.Get().(*jsonBuffer)
+	defer func() {
+		if cap(jb.b) <= 2*jsonFlushSize { // not to keep the buffers of huge rows
+			jsonBuffers.Put(jb)
+		}
+	}()
+	jb.b = jb.b[:0]
 	for _, msg := range msgs {
-		dataRow := map[string]any{
-			"metric":      msg.MetricName,
-			"data":        msg.Data,
-			"dbname":      msg.DBName,
-			"custom_tags": msg.CustomTags,
+		if err := jb.appendEnvelope(msg); err != nil {
+			return errors.Join(err, jw.flush(jb))
 		}
-		if err := enc.Encode(dataRow); err != nil {
-			return err
+		jb.b = append(jb.b, '\n')
+		if len(jb.b) >= jsonFlushSize {
+			if err := jw.flush(jb); err != nil {
+				return err
+			}
 		}
 	}
+	if err := jw.flush(jb); err != nil {
+		return err
+	}
 	diff := time.Since(t1)
 	log.GetLogger(jw.ctx).WithField("rows", len(msgs)).WithField("elapsed", diff).Info("measurements written")
 	return nil
 }
 
+// flush writes the encoded measurements to the file, always whole lines not to split them on rotation
+func (jw *JSONWriter) flush(jb *jsonBuffer) error {
+	if len(jb.b) == 0 {
+		return nil
+	}
+	_, err := jw.lw.Write(jb.b)
+	jb.b = jb.b[:0]
+	return err
+}
+
 func (jw *JSONWriter) watchCtx() {
 	<-jw.ctx.Done()
 	jw.lw.Close()
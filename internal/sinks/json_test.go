@@ -3,11 +3,16 @@ package sinks
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"math"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestJSONWriter_Write(t *testing.T) {
@@ -65,3 +70,87 @@ func TestJSONWriter_SyncMetric(t *testing.T) {
 	assert.Error(t, err, "context canceled")
 
 }
+
+// benchMeasurements returns a batch of n rows of a typical metric, for the sink benchmarks
+func benchMeasurements(n int) []metrics.MeasurementEnvelope {
+	data := make(metrics.Measurements, 0, n)
+	now := time.Now().UnixNano()
+	for i := range n {
+		data = append(data, metrics.Measurement{
+			epochColumnName:  now,
+			"tag_table_name": fmt.Sprintf("public.table_%d", i),
+			"seq_scan":       int64(i * 10),
+			"idx_scan":       int64(i * 100),
+			"n_live_tup":     int64(i * 1000),
+			"bloat_ratio":    float64(i) / 3,
+			"is_partitioned": i%2 == 0,
+			"last_vacuum":    "2024-01-01 00:00:00+00",
+		})
+	}
+	return []metrics.MeasurementEnvelope{{
+		MetricName: "table_stats",
+		DBName:     "bench_db",
+		CustomTags: map[string]string{"env": "prod", "team": "db"},
+		Data:       data,
+	}}
+}
+
+func BenchmarkJSONWriter_Write(b *testing.B) {
+	ctx := log.WithLogger(context.Background(), log.Init(log.CmdOpts{LogLevel: "error"}))
+	jw, err := NewJSONWriter(ctx, b.TempDir()+"/bench.json")
+	require.NoError(b, err)
+	jw.lw.MaxSize = 10 // rotate often not to fill the disk
+	jw.lw.Compress = false
+	b.Cleanup(func() { _ = jw.lw.Close() })
+	msgs := benchMeasurements(1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if err := jw.Write(msgs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestJSONWriter_Encoding(t *testing.T) {
+	// the output must stay the same as of encoding/json used before
+	msgs := []metrics.MeasurementEnvelope{
+		benchMeasurements(3)[0],
+		{MetricName: "nulls"},
+		{
+			MetricName: `m"<&>`,
+			DBName:     "db\u2028\xff\t\x01",
+			CustomTags: map[string]string{"b": "2", "a": "1\\"},
+			Data: metrics.Measurements{
+				nil,
+				{
+					"int": 1, "int32": int32(-2), "uint64": uint64(3), "f32": float32(0.1), "small": 1e-7, "big": 1e21,
+					"zero": 0.0, "neg": -1.5, "bool": false, "nil": nil, "time": time.Unix(0, 0).UTC(),
+					"arr": []any{1, "x"}, "map": map[string]any{"k": "<v>"}, "bytes": []byte("ab"), "\x7f": "é",
+				},
+			},
+		},
+	}
+	var expected []byte
+	for _, msg := range msgs {
+		line, err := json.Marshal(map[string]any{
+			"metric":      msg.MetricName,
+			"data":        msg.Data,
+			"dbname":      msg.DBName,
+			"custom_tags": msg.CustomTags,
+		})
+		require.NoError(t, err)
+		expected = append(append(expected, line...), '\n')
+	}
+
+	tempFile := t.TempDir() + "/test.json"
+	jw, err := NewJSONWriter(context.Background(), tempFile)
+	require.NoError(t, err)
+	require.NoError(t, jw.Write(msgs))
+	file, err := os.ReadFile(tempFile)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(file))
+
+	err = jw.Write([]metrics.MeasurementEnvelope{{MetricName: "nan", Data: metrics.Measurements{{"v": math.NaN()}}}})
+	assert.Error(t, err, "unsupported values are rejected as before")
+}
@@ -0,0 +1,202 @@
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+// jsonFlushSize is the size of the encoded measurements written to the file at once, it also bounds the pooled buffers
+const jsonFlushSize = 1 << 20
+
+// jsonBuffers holds the buffers the batches are encoded into, not to allocate new ones for every batch
+var jsonBuffers = sync.Pool{New: func() any { return &jsonBuffer{} }}
+
+type jsonBuffer struct {
+	b    []byte
+	keys []string // sorted column names of the current row
+}
+
+// appendEnvelope appends the measurements as a JSON object with the "custom_tags", "data", "dbname" and "metric"
+// keys. The output is the same as of encoding/json, but the rows are encoded without reflection and allocations
+func (jb *jsonBuffer) appendEnvelope(msg metrics.MeasurementEnvelope) (err error) {
+	b := append(jb.b, `{"custom_tags":`...)
+	if msg.CustomTags == nil {
+		b = append(b, "null"...)
+	} else {
+		jb.keys = jb.keys[:0]
+		for k := range msg.CustomTags {
+			jb.keys = append(jb.keys, k)
+		}
+		slices.Sort(jb.keys)
+		b = append(b, '{')
+		for i, k := range jb.keys {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = appendJSONString(b, k)
+			b = append(b, ':')
+			b = appendJSONString(b, msg.CustomTags[k])
+		}
+		b = append(b, '}')
+	}
+	b = append(b, `,"data":`...)
+	if msg.Data == nil {
+		b = append(b, "null"...)
+	} else {
+		b = append(b, '[')
+		for i, row := range msg.Data {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			if b, err = jb.appendRow(b, row); err != nil {
+				return err
+			}
+		}
+		b = append(b, ']')
+	}
+	b = append(b, `,"dbname":`...)
+	b = appendJSONString(b, msg.DBName)
+	b = append(b, `,"metric":`...)
+	b = appendJSONString(b, msg.MetricName)
+	jb.b = append(b, '}')
+	return nil
+}
+
+// appendRow appends the row as a JSON object with sorted keys
+func (jb *jsonBuffer) appendRow(b []byte, row metrics.Measurement) (_ []byte, err error) {
+	if row == nil {
+		return append(b, "null"...), nil
+	}
+	jb.keys = jb.keys[:0]
+	for k := range row {
+		jb.keys = append(jb.keys, k)
+	}
+	slices.Sort(jb.keys)
+	b = append(b, '{')
+	for i, k := range jb.keys {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendJSONString(b, k)
+		b = append(b, ':')
+		if b, err = appendJSONValue(b, row[k]); err != nil {
+			return b, err
+		}
+	}
+	return append(b, '}'), nil
+}
+
+// appendJSONValue appends the value of a column, the types not returned by the metric queries usually
+// are left to encoding/json
+func appendJSONValue(b []byte, v any) ([]byte, error) {
+	switch val := v.(type) {
+	case nil:
+		return append(b, "null"...), nil
+	case string:
+		return appendJSONString(b, val), nil
+	case bool:
+		return strconv.AppendBool(b, val), nil
+	case int:
+		return strconv.AppendInt(b, int64(val), 10), nil
+	case int16:
+		return strconv.AppendInt(b, int64(val), 10), nil
+	case int32:
+		return strconv.AppendInt(b, int64(val), 10), nil
+	case int64:
+		return strconv.AppendInt(b, val, 10), nil
+	case uint32:
+		return strconv.AppendUint(b, uint64(val), 10), nil
+	case uint64:
+		return strconv.AppendUint(b, val, 10), nil
+	case float32:
+		return appendJSONFloat(b, float64(val), 32)
+	case float64:
+		return appendJSONFloat(b, val, 64)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return b, err
+	}
+	return append(b, data...), nil
+}
+
+// appendJSONFloat appends the number formatted as encoding/json does, i.e. exponents for very small and large ones only
+func appendJSONFloat(b []byte, f float64, bits int) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return b, fmt.Errorf("json: unsupported value: %s", strconv.FormatFloat(f, 'g', -1, bits))
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	b = strconv.AppendFloat(b, f, format, -1, bits)
+	if format == 'e' { // clean up e-09 to e-9
+		if n := len(b); n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b, nil
+}
+
+const jsonHex = "0123456789abcdef"
+
+// appendJSONString appends the quoted string escaped as encoding/json does, incl. the HTML characters
+// and invalid UTF-8 replaced with U+FFFD
+func appendJSONString(b []byte, s string) []byte {
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && c != '<' && c != '>' && c != '&' {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				b = append(b, '\\', c)
+			case '\b':
+				b = append(b, '\\', 'b')
+			case '\f':
+				b = append(b, '\\', 'f')
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', jsonHex[c>>4], jsonHex[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			b = append(b, s[start:i]...)
+			b = append(b, "\ufffd"...)
+		case r == '\u2028' || r == '\u2029':
+			b = append(b, s[start:i]...)
+			b = append(b, '\\', 'u', '2', '0', '2', jsonHex[r&0xF])
+		default:
+			i += size
+			continue
+		}
+		i += size
+		start = i
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"')
+}
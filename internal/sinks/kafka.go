@@ -0,0 +1,487 @@
+package sinks
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+const (
+	kafkaDefaultTimeout = 30 * time.Second
+	kafkaMaxBatchSize   = 1000000 // of the record batches, below the default message.max.bytes of the brokers
+	kafkaClientID       = "pgwatch"
+	kafkaMaxRespSize    = 100 << 20 // of the responses, not to allocate whatever length a broker claims
+
+	kafkaAPIProduce  = 0
+	kafkaAPIMetadata = 3
+)
+
+// kafkaErrors are the names of the error codes returned by the brokers for the produced records
+var kafkaErrors = map[int16]string{
+	2:  "CORRUPT_MESSAGE",
+	3:  "UNKNOWN_TOPIC_OR_PARTITION",
+	5:  "LEADER_NOT_AVAILABLE",
+	6:  "NOT_LEADER_OR_FOLLOWER",
+	7:  "REQUEST_TIMED_OUT",
+	10: "MESSAGE_TOO_LARGE",
+	19: "NOT_ENOUGH_REPLICAS",
+	29: "TOPIC_AUTHORIZATION_FAILED",
+	87: "INVALID_RECORD",
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// KafkaWriter is a sink publishing the measurements to a Kafka topic, e.g. to feed a streaming pipeline.
+// Every measurement envelope is a record with the JSON written by the jsonfile sink as the value and the
+// source name as the key, so the records of a source land in the same partition, as chosen by the Java client
+type KafkaWriter struct {
+	ctx       context.Context
+	bootstrap []string
+	topic     string
+	tls       bool
+	timeout   time.Duration
+
+	sync.Mutex
+	correlationID int32
+	conns         map[int32]net.Conn // to the brokers by node id
+	addrs         map[int32]string   // of the brokers by node id
+	leaders       []int32            // leader node id by partition, nil if the metadata need a refresh
+}
+
+// NewKafkaWriter creates a writer for the brokers and topic given as host:port[,host:port]/topic[?tls=true].
+// The brokers are connected on the first write
+func NewKafkaWriter(ctx context.Context, address string, opts *CmdOpts) (*KafkaWriter, error) {
+	hosts, topic, _ := strings.Cut(address, "/")
+	topic, query, _ := strings.Cut(topic, "?")
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if hosts == "" || topic == "" {
+		return nil, fmt.Errorf("kafka sink address %s should be host:port[,host:port]/topic", address)
+	}
+	timeout := opts.WriteTimeout
+	if timeout <= 0 {
+		timeout = kafkaDefaultTimeout
+	}
+	l := log.GetLogger(ctx).WithField("sink", "kafka").WithField("topic", topic)
+	kw := &KafkaWriter{
+		ctx:       log.WithLogger(ctx, l),
+		bootstrap: strings.Split(hosts, ","),
+		topic:     topic,
+		tls:       params.Get("tls") == "true",
+		timeout:   timeout,
+		conns:     make(map[int32]net.Conn),
+	}
+	go kw.watchCtx()
+	return kw, nil
+}
+
+// kafkaChunk is a record batch being built for a partition
+type kafkaChunk struct {
+	records []byte
+	count   int
+}
+
+// kafkaPartitionData is a record batch to produce to a partition
+type kafkaPartitionData struct {
+	partition int32
+	batch     []byte
+}
+
+// Write publishes a record per measurement envelope, in a produce request per partition leader. The records
+// of a partition exceeding the batch size are sent in further requests
+func (kw *KafkaWriter) Write(msgs []metrics.MeasurementEnvelope) error {
+	if kw.ctx.Err() != nil {
+		return kw.ctx.Err()
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	kw.Lock()
+	defer kw.Unlock()
+	if kw.leaders == nil {
+		if err := kw.refreshMetadata(); err != nil {
+			return err
+		}
+	}
+	jb := jsonBuffers.Get().(*jsonBuffer)
+	defer jsonBuffers.Put(jb)
+	chunks := make(map[int32][]*kafkaChunk)
+	for _, msg := range msgs {
+		jb.b = jb.b[:0]
+		if err := jb.appendEnvelope(msg); err != nil {
+			log.GetLogger(kw.ctx).WithError(err).Errorf("could not encode [%s:%s]", msg.DBName, msg.MetricName)
+			continue
+		}
+		key := []byte(msg.DBName)
+		p := kafkaPartition(key, len(kw.leaders))
+		c := chunks[p]
+		if len(c) == 0 || c[len(c)-1].count > 0 && len(c[len(c)-1].records)+len(jb.b) > kafkaMaxBatchSize {
+			c = append(c, &kafkaChunk{})
+			chunks[p] = c
+		}
+		last := c[len(c)-1]
+		last.records = appendKafkaRecord(last.records, last.count, key, jb.b)
+		last.count++
+	}
+	now := time.Now().UnixMilli()
+	var err error
+	for round := 0; ; round++ {
+		byLeader := make(map[int32][]kafkaPartitionData)
+		for p, c := range chunks {
+			if round < len(c) {
+				byLeader[kw.leaders[p]] = append(byLeader[kw.leaders[p]], kafkaPartitionData{p, kafkaRecordBatch(nil, c[round], now)})
+			}
+		}
+		if len(byLeader) == 0 {
+			return err
+		}
+		for leader, data := range byLeader {
+			err = errors.Join(err, kw.produce(leader, data))
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// produce sends the record batches to the leader of their partitions, waiting for all in-sync replicas
+func (kw *KafkaWriter) produce(leader int32, data []kafkaPartitionData) error {
+	b := binary.BigEndian.AppendUint16(nil, math.MaxUint16) // no transactional id
+	b = binary.BigEndian.AppendUint16(b, math.MaxUint16)    // acks=-1
+	b = binary.BigEndian.AppendUint32(b, uint32(kw.timeout.Milliseconds()))
+	b = binary.BigEndian.AppendUint32(b, 1)
+	b = appendKafkaString(b, kw.topic)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(data)))
+	for _, d := range data {
+		b = binary.BigEndian.AppendUint32(b, uint32(d.partition))
+		b = binary.BigEndian.AppendUint32(b, uint32(len(d.batch)))
+		b = append(b, d.batch...)
+	}
+	conn, err := kw.conn(leader)
+	if err != nil {
+		kw.leaders = nil
+		return err
+	}
+	resp, err := kw.request(conn, kafkaAPIProduce, 3, b)
+	if err != nil {
+		kw.closeConn(leader)
+		return err
+	}
+	d := &kafkaDecoder{b: resp}
+	for range d.int32() {
+		_ = d.string()
+		for range d.int32() {
+			partition, code := d.int32(), d.int16()
+			_, _ = d.int64(), d.int64() // base offset, log append time
+			if code == 0 || d.err != nil {
+				continue
+			}
+			switch code {
+			case 3, 5, 6:
+				kw.leaders = nil // moved, refresh before the next write
+			}
+			name, ok := kafkaErrors[code]
+			if !ok {
+				name = "error code " + strconv.Itoa(int(code))
+			}
+			err = errors.Join(err, fmt.Errorf("kafka: partition %d of %s: %s", partition, kw.topic, name))
+		}
+	}
+	return errors.Join(err, d.err)
+}
+
+// refreshMetadata looks up the brokers and the partition leaders of the topic via the bootstrap brokers
+func (kw *KafkaWriter) refreshMetadata() (err error) {
+	b := binary.BigEndian.AppendUint32(nil, 1)
+	b = appendKafkaString(b, kw.topic)
+	b = append(b, 1) // allow auto topic creation, if enabled on the brokers
+	for _, addr := range kw.bootstrap {
+		var conn net.Conn
+		if conn, err = kw.dial(addr); err != nil {
+			continue
+		}
+		var resp []byte
+		resp, err = kw.request(conn, kafkaAPIMetadata, 4, b)
+		_ = conn.Close()
+		if err == nil {
+			return kw.parseMetadata(resp)
+		}
+	}
+	return fmt.Errorf("kafka: no bootstrap broker available: %w", err)
+}
+
+func (kw *KafkaWriter) parseMetadata(resp []byte) error {
+	d := &kafkaDecoder{b: resp}
+	_ = d.int32() // throttle time
+	addrs := make(map[int32]string)
+	for range d.int32() {
+		node, host, port := d.int32(), d.string(), d.int32()
+		_ = d.string() // rack
+		addrs[node] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	_, _ = d.string(), d.int32() // cluster id, controller id
+	var leaders []int32
+	for range d.int32() {
+		code, name := d.int16(), d.string()
+		_ = d.bool() // internal
+		n := d.int32()
+		if name == kw.topic && code != 0 {
+			return fmt.Errorf("kafka: topic %s: error code %d", kw.topic, code)
+		}
+		leaders = make([]int32, max(n, 0))
+		for range n {
+			_ = d.int16() // partition error, the leader is checked instead
+			partition, leader := d.int32(), d.int32()
+			for range d.int32() { // replicas
+				_ = d.int32()
+			}
+			for range d.int32() { // in-sync replicas
+				_ = d.int32()
+			}
+			if partition < 0 || partition >= n || leader < 0 {
+				return fmt.Errorf("kafka: no leader for partition %d of %s", partition, kw.topic)
+			}
+			leaders[partition] = leader
+		}
+	}
+	if d.err != nil {
+		return d.err
+	}
+	if len(leaders) == 0 {
+		return fmt.Errorf("kafka: topic %s has no partitions", kw.topic)
+	}
+	for node, addr := range kw.addrs {
+		if addrs[node] != addr {
+			kw.closeConn(node)
+		}
+	}
+	kw.addrs, kw.leaders = addrs, leaders
+	return nil
+}
+
+// conn returns the connection to the broker, connecting if needed
+func (kw *KafkaWriter) conn(node int32) (net.Conn, error) {
+	if conn, ok := kw.conns[node]; ok {
+		return conn, nil
+	}
+	addr, ok := kw.addrs[node]
+	if !ok {
+		return nil, fmt.Errorf("kafka: unknown broker %d", node)
+	}
+	conn, err := kw.dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	kw.conns[node] = conn
+	return conn, nil
+}
+
+func (kw *KafkaWriter) dial(addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: kw.timeout}
+	if !kw.tls {
+		return dialer.DialContext(kw.ctx, "tcp", addr)
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	return (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}}).DialContext(kw.ctx, "tcp", addr)
+}
+
+func (kw *KafkaWriter) closeConn(node int32) {
+	if conn, ok := kw.conns[node]; ok {
+		_ = conn.Close()
+		delete(kw.conns, node)
+	}
+}
+
+// request sends the request and returns the response body following the correlation id
+func (kw *KafkaWriter) request(conn net.Conn, apiKey, version int16, body []byte) ([]byte, error) {
+	kw.correlationID++
+	b := make([]byte, 4, 4+14+len(kafkaClientID)+len(body))
+	b = binary.BigEndian.AppendUint16(b, uint16(apiKey))
+	b = binary.BigEndian.AppendUint16(b, uint16(version))
+	b = binary.BigEndian.AppendUint32(b, uint32(kw.correlationID))
+	b = appendKafkaString(b, kafkaClientID)
+	b = append(b, body...)
+	binary.BigEndian.PutUint32(b, uint32(len(b)-4))
+	if err := conn.SetDeadline(time.Now().Add(kw.timeout)); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(b); err != nil {
+		return nil, err
+	}
+	var size [4]byte
+	if _, err := io.ReadFull(conn, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > kafkaMaxRespSize {
+		return nil, fmt.Errorf("kafka: response of %d bytes exceeds the limit of %d bytes", n, kafkaMaxRespSize)
+	}
+	resp := make([]byte, n)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+	if len(resp) < 4 || int32(binary.BigEndian.Uint32(resp)) != kw.correlationID {
+		return nil, errors.New("kafka: unexpected response")
+	}
+	return resp[4:], nil
+}
+
+// Ping checks the brokers are reachable by refreshing the metadata of the topic
+func (kw *KafkaWriter) Ping(context.Context) error {
+	kw.Lock()
+	defer kw.Unlock()
+	return kw.refreshMetadata()
+}
+
+// SyncMetric is a no-op, the consumers of the topic manage the storage of the metrics
+func (kw *KafkaWriter) SyncMetric(_, _, _ string) error {
+	return nil
+}
+
+func (kw *KafkaWriter) watchCtx() {
+	<-kw.ctx.Done()
+	kw.Lock()
+	defer kw.Unlock()
+	for node := range kw.conns {
+		kw.closeConn(node)
+	}
+}
+
+// kafkaPartition returns the partition of the key as the default partitioner of the Java client does
+func kafkaPartition(key []byte, partitions int) int32 {
+	return int32((murmur2(key) & 0x7fffffff) % int32(partitions))
+}
+
+// murmur2 is the 32-bit MurmurHash2 with the seed used by Kafka
+func murmur2(data []byte) int32 {
+	const m, r = 0x5bd1e995, 24
+	n := len(data)
+	h := uint32(0x9747b28c) ^ uint32(n)
+	for i := 0; i+4 <= n; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+	tail := data[n&^3:]
+	switch len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return int32(h)
+}
+
+// appendKafkaRecord appends a record without headers, timestamped as the batch
+func appendKafkaRecord(b []byte, offsetDelta int, key, value []byte) []byte {
+	var head, valueLen [3 * binary.MaxVarintLen64]byte
+	h := append(head[:0], 0) // attributes
+	h = binary.AppendVarint(h, 0)
+	h = binary.AppendVarint(h, int64(offsetDelta))
+	h = binary.AppendVarint(h, int64(len(key)))
+	v := binary.AppendVarint(valueLen[:0], int64(len(value)))
+	b = binary.AppendVarint(b, int64(len(h)+len(key)+len(v)+len(value)+1))
+	b = append(b, h...)
+	b = append(b, key...)
+	b = append(b, v...)
+	b = append(b, value...)
+	return append(b, 0) // headers
+}
+
+// kafkaRecordBatch appends the records as an uncompressed record batch (magic 2) without idempotence
+func kafkaRecordBatch(b []byte, c *kafkaChunk, timestamp int64) []byte {
+	start := len(b)
+	b = binary.BigEndian.AppendUint64(b, 0)              // base offset
+	b = binary.BigEndian.AppendUint32(b, 0)              // length, set below
+	b = binary.BigEndian.AppendUint32(b, math.MaxUint32) // partition leader epoch
+	b = append(b, 2)                                     // magic
+	b = binary.BigEndian.AppendUint32(b, 0)              // crc, set below
+	attributes := len(b)
+	b = binary.BigEndian.AppendUint16(b, 0) // no compression, create time
+	b = binary.BigEndian.AppendUint32(b, uint32(c.count-1))
+	b = binary.BigEndian.AppendUint64(b, uint64(timestamp))
+	b = binary.BigEndian.AppendUint64(b, uint64(timestamp))
+	b = binary.BigEndian.AppendUint64(b, math.MaxUint64) // producer id
+	b = binary.BigEndian.AppendUint16(b, math.MaxUint16) // producer epoch
+	b = binary.BigEndian.AppendUint32(b, math.MaxUint32) // base sequence
+	b = binary.BigEndian.AppendUint32(b, uint32(c.count))
+	b = append(b, c.records...)
+	binary.BigEndian.PutUint32(b[start+8:], uint32(len(b)-start-12))
+	binary.BigEndian.PutUint32(b[attributes-4:], crc32.Checksum(b[attributes:], castagnoli))
+	return b
+}
+
+func appendKafkaString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// kafkaDecoder reads the fields of a response, the first error is kept and zero values are returned after it
+type kafkaDecoder struct {
+	b   []byte
+	err error
+}
+
+func (d *kafkaDecoder) next(n int) []byte {
+	if d.err == nil && len(d.b) < n {
+		d.err = errors.New("kafka: short response")
+	}
+	if d.err != nil {
+		return make([]byte, n)
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *kafkaDecoder) bool() bool {
+	return d.next(1)[0] != 0
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	return int16(binary.BigEndian.Uint16(d.next(2)))
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	return int32(binary.BigEndian.Uint32(d.next(4)))
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	return int64(binary.BigEndian.Uint64(d.next(8)))
+}
+
+// string reads a nullable string, null is returned as empty
+func (d *kafkaDecoder) string() string {
+	n := d.int16()
+	if n <= 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
@@ -0,0 +1,267 @@
+package sinks
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type kafkaRecord struct {
+	partition int32
+	key       string
+	value     map[string]any
+}
+
+// fakeKafkaBroker answers the metadata and produce requests of a single broker cluster with the topic partitions
+type fakeKafkaBroker struct {
+	t          *testing.T
+	ln         net.Listener
+	partitions int32
+	errorCode  int16 // returned for the produced batches
+	sync.Mutex
+	records []kafkaRecord
+	batches int
+}
+
+func newFakeKafkaBroker(t *testing.T, partitions int32) *fakeKafkaBroker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	fb := &fakeKafkaBroker{t: t, ln: ln, partitions: partitions}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go fb.serve(conn)
+		}
+	}()
+	return fb
+}
+
+func (fb *fakeKafkaBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+		d := &kafkaDecoder{b: req}
+		apiKey, version, correlationID := d.int16(), d.int16(), d.int32()
+		assert.Equal(fb.t, kafkaClientID, d.string())
+		resp := binary.BigEndian.AppendUint32(nil, uint32(correlationID))
+		switch apiKey {
+		case kafkaAPIMetadata:
+			assert.EqualValues(fb.t, 4, version)
+			resp = fb.metadata(resp, d)
+		case kafkaAPIProduce:
+			assert.EqualValues(fb.t, 3, version)
+			resp = fb.produce(resp, d)
+		}
+		require.NoError(fb.t, d.err)
+		out := binary.BigEndian.AppendUint32(nil, uint32(len(resp)))
+		if _, err := conn.Write(append(out, resp...)); err != nil {
+			return
+		}
+	}
+}
+
+func (fb *fakeKafkaBroker) metadata(b []byte, d *kafkaDecoder) []byte {
+	require.EqualValues(fb.t, 1, d.int32())
+	topic := d.string()
+	_ = d.bool()
+	host, port, _ := net.SplitHostPort(fb.ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+	b = binary.BigEndian.AppendUint32(b, 0) // throttle
+	b = binary.BigEndian.AppendUint32(b, 1)
+	b = binary.BigEndian.AppendUint32(b, 1) // node id
+	b = appendKafkaString(b, host)
+	b = binary.BigEndian.AppendUint32(b, uint32(p))
+	b = binary.BigEndian.AppendUint16(b, 0xffff) // rack
+	b = appendKafkaString(b, "cluster")
+	b = binary.BigEndian.AppendUint32(b, 1) // controller
+	b = binary.BigEndian.AppendUint32(b, 1)
+	b = binary.BigEndian.AppendUint16(b, 0)
+	b = appendKafkaString(b, topic)
+	b = append(b, 0)
+	b = binary.BigEndian.AppendUint32(b, uint32(fb.partitions))
+	for i := range fb.partitions {
+		b = binary.BigEndian.AppendUint16(b, 0)
+		b = binary.BigEndian.AppendUint32(b, uint32(i))
+		b = binary.BigEndian.AppendUint32(b, 1) // leader
+		b = binary.BigEndian.AppendUint32(b, 1)
+		b = binary.BigEndian.AppendUint32(b, 1)
+		b = binary.BigEndian.AppendUint32(b, 1)
+		b = binary.BigEndian.AppendUint32(b, 1)
+	}
+	return b
+}
+
+func (fb *fakeKafkaBroker) produce(b []byte, d *kafkaDecoder) []byte {
+	assert.EqualValues(fb.t, -1, d.int16(), "no transactional id")
+	assert.EqualValues(fb.t, -1, d.int16(), "acks=all")
+	_ = d.int32()
+	require.EqualValues(fb.t, 1, d.int32())
+	topic := d.string()
+	n := d.int32()
+	b = binary.BigEndian.AppendUint32(b, 1)
+	b = appendKafkaString(b, topic)
+	b = binary.BigEndian.AppendUint32(b, uint32(n))
+	for range n {
+		partition := d.int32()
+		batch := d.next(int(d.int32()))
+		fb.decodeBatch(partition, batch)
+		b = binary.BigEndian.AppendUint32(b, uint32(partition))
+		b = binary.BigEndian.AppendUint16(b, uint16(fb.errorCode))
+		b = binary.BigEndian.AppendUint64(b, 0)
+		b = binary.BigEndian.AppendUint64(b, 0)
+	}
+	return binary.BigEndian.AppendUint32(b, 0) // throttle
+}
+
+func (fb *fakeKafkaBroker) decodeBatch(partition int32, batch []byte) {
+	t := fb.t
+	require.Greater(t, len(batch), 61)
+	assert.EqualValues(t, len(batch)-12, binary.BigEndian.Uint32(batch[8:]))
+	assert.EqualValues(t, 2, batch[16], "magic")
+	assert.Equal(t, crc32.Checksum(batch[21:], crc32.MakeTable(crc32.Castagnoli)), binary.BigEndian.Uint32(batch[17:]))
+	count := int(binary.BigEndian.Uint32(batch[57:]))
+	assert.EqualValues(t, count-1, binary.BigEndian.Uint32(batch[23:]), "last offset delta")
+	rest := batch[61:]
+	fb.Lock()
+	fb.batches++
+	fb.Unlock()
+	varint := func() int {
+		v, n := binary.Varint(rest)
+		require.Positive(t, n)
+		rest = rest[n:]
+		return int(v)
+	}
+	for i := range count {
+		length := varint()
+		end := len(rest) - length
+		rest = rest[1:] // attributes
+		assert.Zero(t, varint(), "timestamp delta")
+		assert.Equal(t, i, varint(), "offset delta")
+		key := string(rest[:varint()])
+		rest = rest[len(key):]
+		valueLen := varint()
+		var value map[string]any
+		require.NoError(t, json.Unmarshal(rest[:valueLen], &value))
+		rest = rest[valueLen:]
+		assert.Zero(t, varint(), "no headers")
+		assert.Equal(t, end, len(rest))
+		fb.Lock()
+		fb.records = append(fb.records, kafkaRecord{partition, key, value})
+		fb.Unlock()
+	}
+	assert.Empty(t, rest)
+}
+
+func TestKafkaWriter(t *testing.T) {
+	fb := newFakeKafkaBroker(t, 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	kw, err := NewKafkaWriter(ctx, "127.0.0.1:1,"+fb.ln.Addr().String()+"/pgwatch", &CmdOpts{})
+	require.NoError(t, err)
+	assert.NoError(t, kw.SyncMetric("db1", "db_stats", "add"))
+	assert.NoError(t, kw.Write(nil))
+
+	msgs := []metrics.MeasurementEnvelope{
+		{DBName: "db1", MetricName: "db_stats", Data: metrics.Measurements{{"epoch_ns": int64(1), "xact_commit": int64(5)}}},
+		{DBName: "db2", MetricName: "db_stats", Data: metrics.Measurements{{"epoch_ns": int64(1), "xact_commit": int64(7)}}},
+		{DBName: "db1", MetricName: "cpu_load", Data: metrics.Measurements{{"epoch_ns": int64(1), "load_1min": 0.5}}},
+	}
+	require.NoError(t, kw.Write(msgs), "the unreachable bootstrap broker is skipped")
+	assert.NoError(t, kw.Ping(ctx))
+	require.Len(t, fb.records, 3)
+	partitions := make(map[string]int32)
+	for _, r := range fb.records {
+		assert.Equal(t, r.key, r.value["dbname"])
+		assert.Equal(t, kafkaPartition([]byte(r.key), 3), r.partition)
+		if p, ok := partitions[r.key]; ok {
+			assert.Equal(t, p, r.partition, "records of a source are in the same partition")
+		}
+		partitions[r.key] = r.partition
+	}
+
+	fb.errorCode = 6
+	assert.EqualError(t, kw.Write(msgs[:1]), "kafka: partition "+strconv.Itoa(int(partitions["db1"]))+" of pgwatch: NOT_LEADER_OR_FOLLOWER")
+	assert.Nil(t, kw.leaders, "metadata refreshed on the next write")
+	fb.errorCode = 0
+	assert.NoError(t, kw.Write(msgs[:1]))
+
+	_, err = NewKafkaWriter(ctx, "127.0.0.1:9092", &CmdOpts{})
+	assert.Error(t, err, "topic is required")
+	cancel()
+	assert.Error(t, kw.Write(msgs), "closed with the context")
+}
+
+func TestKafkaBatchSplit(t *testing.T) {
+	fb := newFakeKafkaBroker(t, 1)
+	kw, err := NewKafkaWriter(context.Background(), fb.ln.Addr().String()+"/pgwatch", &CmdOpts{})
+	require.NoError(t, err)
+	big := make([]byte, kafkaMaxBatchSize/3)
+	for i := range big {
+		big[i] = 'x'
+	}
+	msg := metrics.MeasurementEnvelope{DBName: "db1", MetricName: "stat_statements", Data: metrics.Measurements{{"query": string(big)}}}
+	require.NoError(t, kw.Write([]metrics.MeasurementEnvelope{msg, msg, msg}))
+	assert.Len(t, fb.records, 3)
+	assert.Equal(t, 2, fb.batches, "split not to exceed the batch size")
+}
+
+func TestKafkaResponseSizeLimit(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		if _, err := io.CopyN(io.Discard, conn, int64(binary.BigEndian.Uint32(size[:]))); err != nil {
+			return
+		}
+		_, _ = conn.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	}()
+	kw, err := NewKafkaWriter(context.Background(), ln.Addr().String()+"/pgwatch", &CmdOpts{})
+	require.NoError(t, err)
+	assert.ErrorContains(t, kw.Write([]metrics.MeasurementEnvelope{
+		{DBName: "db1", MetricName: "db_stats", Data: metrics.Measurements{{"epoch_ns": int64(1)}}},
+	}), "kafka: response of 4294967295 bytes exceeds the limit of 104857600 bytes")
+}
+
+func TestMurmur2(t *testing.T) {
+	// the values of the Java client, org.apache.kafka.common.utils.Utils.murmur2
+	for key, hash := range map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	} {
+		assert.Equal(t, hash, murmur2([]byte(key)), key)
+	}
+}
@@ -0,0 +1,73 @@
+package sinks
+
+import (
+	"context"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+)
+
+// lifecycleStopTimeout is how long recording the shutdown may take, the sink context is already cancelled then
+var lifecycleStopTimeout = 5 * time.Second
+
+// collectorRun is the admin.collector_runs record of this collector
+type collectorRun struct {
+	id         int64
+	configHash string
+}
+
+// RecordStart registers the start of this collector, the previous runs of it not stopped cleanly are marked
+// as unclean with their last seen time as the stop time
+func (pgw *PostgresWriter) RecordStart(version, configHash string) error {
+	if _, err := pgw.sinkDb.Exec(pgw.ctx, sqlMetricCollectorLifecycle); err != nil {
+		return err // sinks created by older versions lack the lifecycle tables
+	}
+	sql := `WITH unclean AS (
+	UPDATE admin.collector_runs SET shutdown = 'unclean', stopped_on = last_seen
+	WHERE collector = $1 AND shutdown = 'running'
+	RETURNING id
+)
+INSERT INTO admin.collector_runs (collector, version, config_hash) VALUES ($1, $2, $3)
+RETURNING id, (SELECT count(*) FROM unclean)`
+	var unclean int64
+	if err := pgw.sinkDb.QueryRow(pgw.ctx, sql, pgw.opts.CollectorID, version, configHash).Scan(&pgw.run.id, &unclean); err != nil {
+		return err
+	}
+	pgw.run.configHash = configHash
+	if unclean > 0 {
+		log.GetLogger(pgw.ctx).WithField("collector", pgw.opts.CollectorID).Warning("previous run of the collector was not stopped cleanly")
+	}
+	return nil
+}
+
+// RecordConfig marks this collector as alive and records the configuration reload if the hash changed
+func (pgw *PostgresWriter) RecordConfig(configHash string) error {
+	if pgw.run.id == 0 {
+		return nil
+	}
+	if configHash == pgw.run.configHash {
+		_, err := pgw.sinkDb.Exec(pgw.ctx, `UPDATE admin.collector_runs SET last_seen = now() WHERE id = $1`, pgw.run.id)
+		return err
+	}
+	sql := `WITH reload AS (
+	INSERT INTO admin.collector_config_reloads (run_id, config_hash) VALUES ($1, $2)
+)
+UPDATE admin.collector_runs SET last_seen = now(), config_hash = $2 WHERE id = $1`
+	if _, err := pgw.sinkDb.Exec(pgw.ctx, sql, pgw.run.id, configHash); err != nil {
+		return err
+	}
+	pgw.run.configHash = configHash
+	return nil
+}
+
+// RecordStop registers the clean shutdown of this collector
+func (pgw *PostgresWriter) RecordStop() error {
+	if pgw.run.id == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(pgw.ctx), lifecycleStopTimeout)
+	defer cancel()
+	_, err := pgw.sinkDb.Exec(ctx, `UPDATE admin.collector_runs SET shutdown = 'clean', stopped_on = now(), last_seen = now()
+WHERE id = $1`, pgw.run.id)
+	return err
+}
@@ -0,0 +1,46 @@
+package sinks
+
+import (
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectorLifecycle(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	pgw := PostgresWriter{ctx: ctx, sinkDb: conn, opts: &CmdOpts{CollectorID: "collector1"}}
+
+	assert.NoError(t, pgw.RecordConfig("hash1"), "nothing recorded before the start")
+	assert.NoError(t, pgw.RecordStop())
+
+	conn.ExpectExec("create table if not exists admin.collector_runs").WillReturnResult(pgxmock.NewResult("CREATE", 0))
+	conn.ExpectQuery("INSERT INTO admin.collector_runs").WithArgs("collector1", "3.0.0", "hash1").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "unclean"}).AddRow(int64(7), int64(1)))
+	assert.NoError(t, pgw.RecordStart("3.0.0", "hash1"))
+	assert.EqualValues(t, 7, pgw.run.id)
+
+	conn.ExpectExec("UPDATE admin.collector_runs SET last_seen").WithArgs(int64(7)).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	assert.NoError(t, pgw.RecordConfig("hash1"), "unchanged configuration only marks the collector alive")
+
+	conn.ExpectExec("INSERT INTO admin.collector_config_reloads").WithArgs(int64(7), "hash2").WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	assert.NoError(t, pgw.RecordConfig("hash2"))
+	assert.Equal(t, "hash2", pgw.run.configHash)
+
+	conn.ExpectExec("SET shutdown = 'clean'").WithArgs(int64(7)).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	assert.NoError(t, pgw.RecordStop())
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
+
+func TestCollectorLifecycleOldSink(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	pgw := PostgresWriter{ctx: ctx, sinkDb: conn, opts: &CmdOpts{CollectorID: "collector1"}}
+
+	conn.ExpectExec("create table if not exists admin.collector_runs").WillReturnError(assert.AnError)
+	assert.Error(t, pgw.RecordStart("3.0.0", "hash1"), "e.g. missing privileges")
+	assert.NoError(t, pgw.RecordStop(), "nothing to record without a start")
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
@@ -0,0 +1,258 @@
+package sinks
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/jackc/pgx/v5"
+)
+
+// listingReconcileInterval is the period of the full reconciliation of the admin.all_distinct_dbname_metrics
+// listing, the incremental registrations keep it up to date in between
+const listingReconcileInterval = 24 * time.Hour
+
+// ListingStats describes the maintenance of the admin.all_distinct_dbname_metrics listing table
+type ListingStats struct {
+	Registered              int64     `json:"registered"`     // source and metric pairs added on registration
+	FullRefreshes           int64     `json:"full_refreshes"` // completed full reconciliations
+	LastFullRefresh         time.Time `json:"last_full_refresh"`
+	LastFullRefreshDuration string    `json:"last_full_refresh_duration"`
+	PartitionCatalog        bool      `json:"partition_catalog"` // the last reconciliation read the partition catalog instead of the metric tables
+	Added                   int64     `json:"added"`             // entries added by the full reconciliations
+	Removed                 int64     `json:"removed"`           // stale entries removed by the full reconciliations
+	LastError               string    `json:"last_error,omitempty"`
+}
+
+// listing keeps track of the source and metric pairs already present in the listing table,
+// so that only new registrations hit the measurements database
+type listing struct {
+	sync.Mutex
+	listed map[[2]string]bool
+	stats  ListingStats
+}
+
+// MaintenanceStats returns the runtime statistics of the listing table maintenance
+func (pgw *PostgresWriter) MaintenanceStats() any {
+	pgw.listing.Lock()
+	defer pgw.listing.Unlock()
+	return pgw.listing.stats
+}
+
+// AddDBUniqueMetricToListingTable registers the source and metric pair in the listing table unless already done
+func (pgw *PostgresWriter) AddDBUniqueMetricToListingTable(dbUnique, metric string) error {
+	key := [2]string{dbUnique, metric}
+	pgw.listing.Lock()
+	defer pgw.listing.Unlock()
+	if pgw.listing.listed[key] {
+		return nil
+	}
+	sql := `insert into admin.all_distinct_dbname_metrics
+			select $1, $2
+			where not exists (
+				select * from admin.all_distinct_dbname_metrics where dbname = $1 and metric = $2
+			)`
+	if _, err := pgw.sinkDb.Exec(pgw.ctx, sql, dbUnique, metric); err != nil {
+		return err
+	}
+	if pgw.listing.listed == nil {
+		pgw.listing.listed = make(map[[2]string]bool)
+	}
+	pgw.listing.listed[key] = true
+	pgw.listing.stats.Registered++
+	return nil
+}
+
+// ForgetSource removes all the stored measurements of the source, i.e. rows or whole partitions
+// depending on the storage schema, and its listing table entries
+func (pgw *PostgresWriter) ForgetSource(dbUnique string) error {
+	var removed int
+	if err := pgw.sinkDb.QueryRow(pgw.ctx, `select admin.remove_single_dbname_data($1)`, dbUnique).Scan(&removed); err != nil {
+		return err
+	}
+	log.GetLogger(pgw.ctx).WithField("source", dbUnique).WithField("removed", removed).Info("source data removed")
+	pgw.listing.Lock()
+	for key := range pgw.listing.listed {
+		if key[0] == dbUnique {
+			delete(pgw.listing.listed, key)
+		}
+	}
+	pgw.listing.Unlock()
+	return nil
+}
+
+// forgetListed makes the following registrations check the listing table again,
+// as the entries might have been removed by a reconciliation of any pgwatch instance
+func (pgw *PostgresWriter) forgetListed() {
+	pgw.listing.Lock()
+	pgw.listing.listed = nil
+	pgw.listing.Unlock()
+}
+
+// maintainUniqueSources is a background task that maintains a listing of unique sources for each metric.
+// This is used to avoid listing the same source multiple times in Grafana dropdowns. The listing is
+// updated incrementally on registrations, the periodic full reconciliation removes the sources
+// whose data is gone, e.g. due to the retention
+func (pgw *PostgresWriter) maintainUniqueSources() {
+	logger := log.GetLogger(pgw.ctx)
+	// due to metrics deletion the listing can go out of sync (a trigger not really wanted)
+	sqlGetAdvisoryLock := `SELECT pg_try_advisory_lock(1571543679778230000) AS have_lock` // 1571543679778230000 is just a random bigint
+
+	for {
+		select {
+		case <-pgw.ctx.Done():
+			return
+		case <-time.After(listingReconcileInterval):
+		}
+		pgw.forgetListed()
+		var lock bool
+		logger.Infof("Trying to get metricsDb listing maintainer advisory lock...") // to only have one "maintainer" in case of a "push" setup, as can get costly
+		if err := pgw.sinkDb.QueryRow(pgw.ctx, sqlGetAdvisoryLock).Scan(&lock); err != nil {
+			logger.Error("Getting metricsDb listing maintainer advisory lock failed:", err)
+			continue
+		}
+		if !lock {
+			logger.Info("Skipping admin.all_distinct_dbname_metrics maintenance as another instance has the advisory lock...")
+			continue
+		}
+		pgw.reconcileListing(time.Minute)
+	}
+}
+
+// getListedDbnames returns the dbnames having data for every top level metric table. For the metric-dbname-time
+// storage schema they are read from the partition catalog, otherwise every metric table is scanned
+// with a pause in between not to overload the measurements database
+func (pgw *PostgresWriter) getListedDbnames(pause time.Duration) (found map[string][]string, fromCatalog bool, err error) {
+	sqlTopLevelMetrics := `SELECT table_name FROM admin.get_top_level_metric_tables()`
+	sqlDistinct := `
+	WITH RECURSIVE t(dbname) AS (
+		SELECT MIN(dbname) AS dbname FROM %s
+		UNION
+		SELECT (SELECT MIN(dbname) FROM %s WHERE dbname > t.dbname) FROM t )
+	SELECT dbname FROM t WHERE dbname NOTNULL ORDER BY 1`
+	// dbname partitions without any time partitions left hold no data
+	sqlPartitions := `
+	SELECT p.relname, replace((regexp_match(pg_get_expr(c.relpartbound, c.oid), $$FOR VALUES IN \('(.*)'\)$$))[1], '''''', '''')
+	FROM pg_inherits i
+	JOIN pg_class c ON c.oid = i.inhrelid
+	JOIN pg_class p ON p.oid = i.inhparent
+	JOIN pg_namespace n ON n.oid = p.relnamespace
+	WHERE pg_catalog.obj_description(p.oid, 'pg_class') = 'pgwatch-generated-metric-lvl' AND EXISTS (SELECT FROM pg_inherits t WHERE t.inhparent = c.oid)`
+
+	rows, err := pgw.sinkDb.Query(pgw.ctx, sqlTopLevelMetrics)
+	if err != nil {
+		return nil, false, err
+	}
+	tables, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, false, err
+	}
+	found = make(map[string][]string, len(tables))
+	for _, tableName := range tables {
+		_, metric := splitStorageTable(tableName)
+		found[metric] = nil
+	}
+
+	if pgw.metricSchema == DbStorageSchemaPostgres {
+		rows, err := pgw.sinkDb.Query(pgw.ctx, sqlPartitions)
+		if err != nil {
+			return nil, false, err
+		}
+		var metric, dbname string
+		if _, err = pgx.ForEachRow(rows, []any{&metric, &dbname}, func() error {
+			if _, ok := found[metric]; ok {
+				found[metric] = append(found[metric], dbname)
+			}
+			return nil
+		}); err != nil {
+			return nil, false, err
+		}
+		return found, true, nil
+	}
+
+	for i, tableName := range tables {
+		if i > 0 {
+			select {
+			case <-pgw.ctx.Done():
+				return nil, false, pgw.ctx.Err()
+			case <-time.After(pause):
+			}
+		}
+		rows, err := pgw.sinkDb.Query(pgw.ctx, fmt.Sprintf(sqlDistinct, tableName, tableName))
+		if err != nil {
+			return nil, false, fmt.Errorf("could not list sources of %s: %w", tableName, err)
+		}
+		dbnames, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return nil, false, fmt.Errorf("could not list sources of %s: %w", tableName, err)
+		}
+		_, metric := splitStorageTable(tableName)
+		found[metric] = dbnames
+	}
+	return found, false, nil
+}
+
+// reconcileListing brings the listing table in line with the data present in the metric tables
+func (pgw *PostgresWriter) reconcileListing(pause time.Duration) {
+	logger := log.GetLogger(pgw.ctx)
+	sqlDelete := `DELETE FROM admin.all_distinct_dbname_metrics WHERE NOT dbname = ANY($1) and metric = $2`
+	sqlAdd := `
+		INSERT INTO admin.all_distinct_dbname_metrics SELECT u, $2 FROM (select unnest($1::text[]) as u) x
+		WHERE NOT EXISTS (select * from admin.all_distinct_dbname_metrics where dbname = u and metric = $2)
+		RETURNING *`
+
+	logger.Info("Refreshing admin.all_distinct_dbname_metrics listing table...")
+	start := time.Now()
+	found, fromCatalog, err := pgw.getListedDbnames(pause)
+	if err != nil {
+		logger.Errorf("Could not refresh Postgres all_distinct_dbname_metrics listing table: %s", err)
+		pgw.listing.Lock()
+		pgw.listing.stats.LastError = err.Error()
+		pgw.listing.Unlock()
+		return
+	}
+
+	var added, removed int64
+	var lastErr error
+	for metricName, dbnames := range found {
+		logger.Debugf("Refreshing all_distinct_dbname_metrics listing for metric: %s", metricName)
+		if dbnames == nil {
+			dbnames = []string{} // delete all entries for given metric
+		}
+		cmdTag, err := pgw.sinkDb.Exec(pgw.ctx, sqlDelete, dbnames, metricName)
+		if err != nil {
+			logger.Errorf("Could not refresh Postgres all_distinct_dbname_metrics listing table for metric '%s': %s", metricName, err)
+			lastErr = err
+			continue
+		} else if cmdTag.RowsAffected() > 0 {
+			logger.Infof("Removed %d stale entries from all_distinct_dbname_metrics listing table for metric: %s", cmdTag.RowsAffected(), metricName)
+			removed += cmdTag.RowsAffected()
+		}
+		if len(dbnames) == 0 {
+			continue
+		}
+		cmdTag, err = pgw.sinkDb.Exec(pgw.ctx, sqlAdd, dbnames, metricName)
+		if err != nil {
+			logger.Errorf("Could not refresh Postgres all_distinct_dbname_metrics listing table for metric '%s': %s", metricName, err)
+			lastErr = err
+		} else if cmdTag.RowsAffected() > 0 {
+			logger.Infof("Added %d entry to the Postgres all_distinct_dbname_metrics listing table for metric: %s", cmdTag.RowsAffected(), metricName)
+			added += cmdTag.RowsAffected()
+		}
+	}
+
+	pgw.listing.Lock()
+	defer pgw.listing.Unlock()
+	s := &pgw.listing.stats
+	s.FullRefreshes++
+	s.LastFullRefresh = time.Now()
+	s.LastFullRefreshDuration = time.Since(start).Round(time.Millisecond).String()
+	s.PartitionCatalog = fromCatalog
+	s.Added += added
+	s.Removed += removed
+	s.LastError = ""
+	if lastErr != nil {
+		s.LastError = lastErr.Error()
+	}
+}
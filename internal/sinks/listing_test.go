@@ -0,0 +1,90 @@
+package sinks
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddDBUniqueMetricToListingTable(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	pgw := &PostgresWriter{ctx: ctx, sinkDb: conn}
+
+	conn.ExpectExec("insert into admin\\.all_distinct_dbname_metrics").WithArgs("db1", "cpu").WillReturnError(errors.New("failed"))
+	assert.Error(t, pgw.AddDBUniqueMetricToListingTable("db1", "cpu"))
+	conn.ExpectExec("insert into admin\\.all_distinct_dbname_metrics").WithArgs("db1", "cpu").WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	assert.NoError(t, pgw.AddDBUniqueMetricToListingTable("db1", "cpu"))
+	assert.NoError(t, pgw.AddDBUniqueMetricToListingTable("db1", "cpu"), "registered pairs don't hit the database")
+	assert.NoError(t, conn.ExpectationsWereMet())
+	assert.EqualValues(t, 1, pgw.MaintenanceStats().(ListingStats).Registered)
+
+	pgw.forgetListed()
+	conn.ExpectExec("insert into admin\\.all_distinct_dbname_metrics").WithArgs("db1", "cpu").WillReturnResult(pgxmock.NewResult("INSERT", 0))
+	assert.NoError(t, pgw.AddDBUniqueMetricToListingTable("db1", "cpu"))
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
+
+func TestReconcileListing(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	pgw := &PostgresWriter{ctx: ctx, sinkDb: conn, metricSchema: DbStorageSchemaPostgres}
+
+	conn.ExpectQuery("get_top_level_metric_tables").WillReturnRows(pgxmock.NewRows([]string{"table_name"}).AddRow("public.cpu").AddRow("public.wal"))
+	conn.ExpectQuery("FROM pg_inherits").WillReturnRows(pgxmock.NewRows([]string{"relname", "dbname"}).AddRow("cpu", "db1").AddRow("cpu", "db2"))
+	conn.ExpectExec("DELETE FROM admin\\.all_distinct_dbname_metrics").WithArgs([]string{"db1", "db2"}, "cpu").WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	conn.ExpectExec("INSERT INTO admin\\.all_distinct_dbname_metrics").WithArgs([]string{"db1", "db2"}, "cpu").WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	conn.ExpectExec("DELETE FROM admin\\.all_distinct_dbname_metrics").WithArgs([]string{}, "wal").WillReturnResult(pgxmock.NewResult("DELETE", 3))
+	conn.MatchExpectationsInOrder(false)
+	pgw.reconcileListing(0)
+	assert.NoError(t, conn.ExpectationsWereMet())
+	stats := pgw.MaintenanceStats().(ListingStats)
+	assert.True(t, stats.PartitionCatalog, "no metric table scans for the metric-dbname-time schema")
+	assert.EqualValues(t, 1, stats.FullRefreshes)
+	assert.EqualValues(t, 2, stats.Added)
+	assert.EqualValues(t, 4, stats.Removed)
+	assert.Empty(t, stats.LastError)
+
+	pgw.metricSchema = DbStorageSchemaTimescale
+	conn.MatchExpectationsInOrder(true)
+	conn.ExpectQuery("get_top_level_metric_tables").WillReturnRows(pgxmock.NewRows([]string{"table_name"}).AddRow("public.cpu"))
+	conn.ExpectQuery("WITH RECURSIVE").WillReturnError(errors.New("canceling statement"))
+	pgw.reconcileListing(0)
+	assert.NoError(t, conn.ExpectationsWereMet())
+	stats = pgw.MaintenanceStats().(ListingStats)
+	assert.EqualValues(t, 1, stats.FullRefreshes)
+	assert.Contains(t, stats.LastError, "canceling statement")
+}
+
+func TestSinkHealthMaintenance(t *testing.T) {
+	mw := &MultiWriter{}
+	mw.AddWriter(&MockWriter{})
+	mw.AddWriter(&PostgresWriter{})
+	health := mw.Health()
+	assert.Nil(t, health[0].Maintenance)
+	assert.IsType(t, ListingStats{}, health[1].Maintenance)
+}
+
+func TestForgetSource(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	pgw := &PostgresWriter{ctx: ctx, sinkDb: conn}
+	mw := &MultiWriter{}
+	mw.AddWriter(&MockWriter{})
+	mw.AddWriter(pgw)
+
+	conn.ExpectExec("insert into admin\\.all_distinct_dbname_metrics").WithArgs("old", "cpu").WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	require.NoError(t, pgw.AddDBUniqueMetricToListingTable("old", "cpu"))
+
+	conn.ExpectQuery("remove_single_dbname_data").WithArgs("old").WillReturnError(errors.New("permission denied"))
+	assert.ErrorContains(t, mw.ForgetSource("old"), "permission denied", "sinks without the support are skipped")
+	conn.ExpectQuery("remove_single_dbname_data").WithArgs("old").WillReturnRows(pgxmock.NewRows([]string{"removed"}).AddRow(42))
+	assert.NoError(t, mw.ForgetSource("old"))
+
+	conn.ExpectExec("insert into admin\\.all_distinct_dbname_metrics").WithArgs("old", "cpu").WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	assert.NoError(t, pgw.AddDBUniqueMetricToListingTable("old", "cpu"), "forgotten sources are registered again")
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
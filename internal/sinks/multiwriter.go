@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
@@ -17,16 +19,56 @@ type Writer interface {
 	Write(msgs []metrics.MeasurementEnvelope) error
 }
 
+// LastMeasurementReader is implemented by the sinks able to tell when a metric of a source was stored last
+type LastMeasurementReader interface {
+	LastMeasurementTime(dbUnique, metricName string) (time.Time, error)
+}
+
+// HistoryPoint is a stored value of a metric column, averaged over an hour
+type HistoryPoint struct {
+	Time  time.Time
+	Value float64
+}
+
+// HistoryReader is implemented by the sinks able to read back the stored values of a metric column. The series
+// are keyed by the value of the tag column, or "" if no tag is given, and only the top series with the largest
+// latest values are returned
+type HistoryReader interface {
+	ColumnHistory(dbUnique, metricName, column, tag string, since time.Time, top int) (map[string][]HistoryPoint, error)
+}
+
+// Forgetter is implemented by the sinks able to remove all the stored data of a source
+type Forgetter interface {
+	ForgetSource(dbUnique string) error
+}
+
+// LifecycleRecorder is implemented by the sinks able to keep the history of the collector starts,
+// configuration reloads and shutdowns
+type LifecycleRecorder interface {
+	RecordStart(version, configHash string) error
+	RecordConfig(configHash string) error
+	RecordStop() error
+}
+
+// Prober is implemented by the sinks able to serve the measurements of a source fetched on demand
+type Prober interface {
+	SetProbe(fn ProbeFunc)
+}
+
 // MultiWriter ensures the simultaneous storage of data in several storages.
 type MultiWriter struct {
-	writers []Writer
+	writers      []Writer
+	states       []*sinkState       // health of the writers, same order
+	names        []*MetricNameRules // metric renaming of the writers, same order
+	writeTimeout time.Duration
+	statsHistory time.Duration // of the per-metric write statistics
 	sync.Mutex
 }
 
 // NewMultiWriter creates and returns new instance of MultiWriter struct.
 func NewMultiWriter(ctx context.Context, opts *CmdOpts, metricDefs *metrics.Metrics) (mw *MultiWriter, err error) {
 	var w Writer
-	mw = &MultiWriter{}
+	mw = &MultiWriter{writeTimeout: opts.WriteTimeout, statsHistory: opts.StatsHistory}
 	for _, s := range opts.Sinks {
 		scheme, path, found := strings.Cut(s, "://")
 		if !found || scheme == "" || path == "" {
@@ -38,36 +80,158 @@ func NewMultiWriter(ctx context.Context, opts *CmdOpts, metricDefs *metrics.Metr
 		case "postgres", "postgresql":
 			w, err = NewPostgresWriter(ctx, s, opts, metricDefs)
 		case "prometheus":
-			w, err = NewPrometheusWriter(ctx, path)
+			w, err = NewPrometheusWriter(ctx, path, opts)
 		case "rpc":
 			w, err = NewRPCWriter(ctx, path)
+		case "grpc":
+			w, err = NewGRPCWriter(ctx, path, opts)
+		case "influx":
+			w, err = NewInfluxWriter(ctx, path, opts)
+		case "kafka":
+			w, err = NewKafkaWriter(ctx, path, opts)
+		case "elastic", "opensearch":
+			w, err = NewElasticWriter(ctx, path, opts)
 		default:
 			return nil, fmt.Errorf("unknown schema %s in sink URI %s", scheme, s)
 		}
 		if err != nil {
 			return nil, err
 		}
-		mw.AddWriter(w)
+		if promw, ok := w.(*PrometheusWriter); ok {
+			promw.sinksHealth = mw
+		}
+		names, err := NewMetricNameRules(scheme, opts.MetricNameRemaps, opts.MetricNamePrefixes)
+		if err != nil {
+			return nil, err
+		}
+		mw.addWriter(log.Redact(s), w, names)
 	}
 	if len(mw.writers) == 0 {
 		return nil, errors.New("no sinks specified for measurements")
 	}
+	if opts.HealthCheckInterval > 0 {
+		go mw.watchHealth(ctx, opts.HealthCheckInterval)
+	}
 	return mw, nil
 }
 
 func (mw *MultiWriter) AddWriter(w Writer) {
+	mw.addWriter(fmt.Sprintf("%T", w), w, nil)
+}
+
+func (mw *MultiWriter) addWriter(name string, w Writer, names *MetricNameRules) {
 	mw.Lock()
+	state := newSinkState(name, mw.statsHistory)
+	if r, ok := w.(writeStatsRecorder); ok {
+		r.setWriteStats(state.stats)
+	}
 	mw.writers = append(mw.writers, w)
+	mw.states = append(mw.states, state)
+	mw.names = append(mw.names, names)
 	mw.Unlock()
 }
 
 func (mw *MultiWriter) SyncMetrics(dbUnique, metricName, op string) (err error) {
+	for i, w := range mw.writers {
+		err = errors.Join(err, w.SyncMetric(dbUnique, mw.names[i].Apply(metricName), op))
+	}
+	return
+}
+
+// ForgetSource removes the data of the source from all the sinks supporting it
+func (mw *MultiWriter) ForgetSource(dbUnique string) (err error) {
+	for _, w := range mw.writers {
+		if f, ok := w.(Forgetter); ok {
+			err = errors.Join(err, f.ForgetSource(dbUnique))
+		}
+	}
+	return
+}
+
+// SetProbe enables on-demand fetching in all the sinks supporting it, the metric names are renamed like the stored ones
+func (mw *MultiWriter) SetProbe(fn ProbeFunc) {
+	for i, w := range mw.writers {
+		if p, ok := w.(Prober); ok {
+			names := mw.names[i]
+			p.SetProbe(func(ctx context.Context, dbUnique string, metricNames []string) ([]metrics.MeasurementEnvelope, error) {
+				msgs, err := fn(ctx, dbUnique, metricNames)
+				return names.ApplyAll(msgs), err
+			})
+		}
+	}
+}
+
+// RecordStart registers the start of the collector in all the sinks supporting it
+func (mw *MultiWriter) RecordStart(version, configHash string) (err error) {
+	for _, w := range mw.writers {
+		if r, ok := w.(LifecycleRecorder); ok {
+			err = errors.Join(err, r.RecordStart(version, configHash))
+		}
+	}
+	return
+}
+
+// RecordConfig registers the collector as alive with the configuration in all the sinks supporting it
+func (mw *MultiWriter) RecordConfig(configHash string) (err error) {
+	for _, w := range mw.writers {
+		if r, ok := w.(LifecycleRecorder); ok {
+			err = errors.Join(err, r.RecordConfig(configHash))
+		}
+	}
+	return
+}
+
+// RecordStop registers the clean shutdown of the collector in all the sinks supporting it
+func (mw *MultiWriter) RecordStop() (err error) {
+	for _, w := range mw.writers {
+		if r, ok := w.(LifecycleRecorder); ok {
+			err = errors.Join(err, r.RecordStop())
+		}
+	}
+	return
+}
+
+// Close saves the state of the sinks implementing io.Closer at shutdown, e.g. the Prometheus cache snapshot
+// to be restored by the next collector
+func (mw *MultiWriter) Close() (err error) {
 	for _, w := range mw.writers {
-		err = errors.Join(err, w.SyncMetric(dbUnique, metricName, op))
+		if c, ok := w.(io.Closer); ok {
+			err = errors.Join(err, c.Close())
+		}
+	}
+	return
+}
+
+// LastMeasurementTime returns the latest time the metric of the source was stored to any of the sinks
+// supporting it, errors.ErrUnsupported is returned if none of them does
+func (mw *MultiWriter) LastMeasurementTime(dbUnique, metricName string) (last time.Time, err error) {
+	err = errors.ErrUnsupported
+	for i, w := range mw.writers {
+		if r, ok := w.(LastMeasurementReader); ok {
+			t, e := r.LastMeasurementTime(dbUnique, mw.names[i].Apply(metricName))
+			if e != nil {
+				return time.Time{}, e
+			}
+			err = nil
+			if t.After(last) {
+				last = t
+			}
+		}
 	}
 	return
 }
 
+// ColumnHistory returns the stored history of the metric column from the first sink supporting it,
+// errors.ErrUnsupported is returned if none of them does
+func (mw *MultiWriter) ColumnHistory(dbUnique, metricName, column, tag string, since time.Time, top int) (map[string][]HistoryPoint, error) {
+	for i, w := range mw.writers {
+		if r, ok := w.(HistoryReader); ok {
+			return r.ColumnHistory(dbUnique, mw.names[i].Apply(metricName), column, tag, since, top)
+		}
+	}
+	return nil, errors.ErrUnsupported
+}
+
 func (mw *MultiWriter) WriteMeasurements(ctx context.Context, storageCh <-chan []metrics.MeasurementEnvelope) {
 	var err error
 	logger := log.GetLogger(ctx)
@@ -76,8 +240,8 @@ func (mw *MultiWriter) WriteMeasurements(ctx context.Context, storageCh <-chan [
 		case <-ctx.Done():
 			return
 		case msg := <-storageCh:
-			for _, w := range mw.writers {
-				err = w.Write(msg)
+			for i, w := range mw.writers {
+				err = mw.states[i].write(w, mw.names[i].ApplyAll(msg), mw.writeTimeout)
 				if err != nil {
 					logger.Error(err)
 				}
@@ -2,7 +2,9 @@ package sinks
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
 	"github.com/stretchr/testify/assert"
@@ -75,3 +77,123 @@ func TestWriteMeasurements(t *testing.T) {
 	c()
 	close(storageCh)
 }
+
+type recordingWriter struct {
+	synced  []string
+	written []string
+}
+
+func (w *recordingWriter) SyncMetric(_, metricName, _ string) error {
+	w.synced = append(w.synced, metricName)
+	return nil
+}
+
+func (w *recordingWriter) Write(msgs []metrics.MeasurementEnvelope) error {
+	for _, msg := range msgs {
+		w.written = append(w.written, msg.MetricName)
+	}
+	return nil
+}
+
+func TestMultiWriterMetricNameRules(t *testing.T) {
+	mw := &MultiWriter{}
+	plain, prefixed := &recordingWriter{}, &recordingWriter{}
+	mw.addWriter("plain", plain, nil)
+	mw.addWriter("prefixed", prefixed, &MetricNameRules{Prefix: "pg_"})
+
+	assert.NoError(t, mw.SyncMetrics("db1", "wal", "add"))
+	assert.Equal(t, []string{"wal"}, plain.synced)
+	assert.Equal(t, []string{"pg_wal"}, prefixed.synced)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	storageCh := make(chan []metrics.MeasurementEnvelope)
+	go mw.WriteMeasurements(ctx, storageCh)
+	storageCh <- []metrics.MeasurementEnvelope{{MetricName: "wal"}}
+	storageCh <- nil // the previous batch is written when the next one is received
+	assert.Equal(t, []string{"wal"}, plain.written)
+	assert.Equal(t, []string{"pg_wal"}, prefixed.written)
+}
+
+type MockLastWriter struct {
+	MockWriter
+	last time.Time
+	err  error
+}
+
+func (mw *MockLastWriter) LastMeasurementTime(_, _ string) (time.Time, error) {
+	return mw.last, mw.err
+}
+
+func TestMultiWriterLastMeasurementTime(t *testing.T) {
+	mw := &MultiWriter{}
+	mw.AddWriter(&MockWriter{})
+	_, err := mw.LastMeasurementTime("db", "metric")
+	assert.ErrorIs(t, err, errors.ErrUnsupported)
+
+	now := time.Now()
+	mw.AddWriter(&MockLastWriter{last: now.Add(-time.Hour)})
+	mw.AddWriter(&MockLastWriter{last: now})
+	last, err := mw.LastMeasurementTime("db", "metric")
+	assert.NoError(t, err)
+	assert.Equal(t, now, last, "the latest time should be returned")
+
+	mw.AddWriter(&MockLastWriter{err: errors.New("expected")})
+	_, err = mw.LastMeasurementTime("db", "metric")
+	assert.Error(t, err)
+}
+
+type MockHistoryWriter struct {
+	MockWriter
+	metricName string
+}
+
+func (mw *MockHistoryWriter) ColumnHistory(_, metricName, _, _ string, _ time.Time, _ int) (map[string][]HistoryPoint, error) {
+	mw.metricName = metricName
+	return map[string][]HistoryPoint{"": {{Time: time.Now(), Value: 1}}}, nil
+}
+
+func TestMultiWriterColumnHistory(t *testing.T) {
+	mw := &MultiWriter{}
+	mw.AddWriter(&MockWriter{})
+	_, err := mw.ColumnHistory("db", "metric", "col", "", time.Now(), 1)
+	assert.ErrorIs(t, err, errors.ErrUnsupported)
+
+	hw := &MockHistoryWriter{}
+	mw.addWriter("history", hw, &MetricNameRules{Remaps: map[string]string{"metric": "renamed"}})
+	history, err := mw.ColumnHistory("db", "metric", "col", "", time.Now(), 1)
+	assert.NoError(t, err)
+	assert.Len(t, history[""], 1)
+	assert.Equal(t, "renamed", hw.metricName, "the stored metric name should be read")
+}
+
+type MockLifecycleWriter struct {
+	MockWriter
+	events []string
+}
+
+func (mw *MockLifecycleWriter) RecordStart(version, configHash string) error {
+	mw.events = append(mw.events, "start "+version+" "+configHash)
+	return nil
+}
+
+func (mw *MockLifecycleWriter) RecordConfig(configHash string) error {
+	mw.events = append(mw.events, "config "+configHash)
+	return nil
+}
+
+func (mw *MockLifecycleWriter) RecordStop() error {
+	mw.events = append(mw.events, "stop")
+	return errors.New("stop failed")
+}
+
+func TestMultiWriterLifecycle(t *testing.T) {
+	lw := &MockLifecycleWriter{}
+	mw := &MultiWriter{}
+	mw.AddWriter(&MockWriter{})
+	mw.AddWriter(lw)
+	assert.NoError(t, mw.RecordStart("3.0.0", "hash1"))
+	assert.NoError(t, mw.RecordConfig("hash2"))
+	assert.EqualError(t, mw.RecordStop(), "stop failed")
+	assert.Equal(t, []string{"start 3.0.0 hash1", "config hash2", "stop"}, lw.events)
+}
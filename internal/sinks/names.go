@@ -0,0 +1,89 @@
+package sinks
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+// sinkTypes are the sink URI schemes the metric name rules can be restricted to
+var sinkTypes = []string{"jsonfile", "postgres", "prometheus", "rpc", "influx", "kafka", "elastic"}
+
+// MetricNameRules rename the metrics stored to a sink, on top of the storage_name of the metric definitions.
+// The remaps are applied first, then the prefix is added to all names
+type MetricNameRules struct {
+	Remaps map[string]string // [metric]=stored name
+	Prefix string
+}
+
+// splitSinkType returns the sink type the rule is restricted to, empty if it applies to all sinks
+func splitSinkType(rule string) (sinkType, value string) {
+	if t, v, found := strings.Cut(rule, ":"); found && slices.Contains(sinkTypes, t) {
+		return t, v
+	}
+	return "", rule
+}
+
+// NewMetricNameRules returns the metric name rules of a sink type from the "[sink_type:]metric=name" remaps and
+// "[sink_type:]prefix" prefixes, nil if none apply. The sink type specific prefix overrides the general one
+func NewMetricNameRules(sinkType string, remaps, prefixes []string) (*MetricNameRules, error) {
+	switch sinkType {
+	case "postgresql":
+		sinkType = "postgres"
+	case "opensearch":
+		sinkType = "elastic"
+	}
+	rules := &MetricNameRules{Remaps: make(map[string]string)}
+	var typePrefix bool
+	for _, prefix := range prefixes {
+		t, p := splitSinkType(prefix)
+		switch {
+		case t == sinkType:
+			rules.Prefix, typePrefix = p, true
+		case t == "" && !typePrefix:
+			rules.Prefix = p
+		}
+	}
+	for _, remap := range remaps {
+		t, r := splitSinkType(remap)
+		from, to, found := strings.Cut(r, "=")
+		if !found || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid metric name remap %q, expected [sink_type:]metric=name", remap)
+		}
+		if t == "" || t == sinkType {
+			if _, ok := rules.Remaps[from]; !ok || t != "" {
+				rules.Remaps[from] = to
+			}
+		}
+	}
+	if len(rules.Remaps) == 0 && rules.Prefix == "" {
+		return nil, nil
+	}
+	return rules, nil
+}
+
+// Apply returns the name to store the metric under
+func (r *MetricNameRules) Apply(metricName string) string {
+	if r == nil || metricName == "" {
+		return metricName
+	}
+	if name, ok := r.Remaps[metricName]; ok {
+		metricName = name
+	}
+	return r.Prefix + metricName
+}
+
+// ApplyAll returns the measurements with the metric names to store them under, the data is shared
+func (r *MetricNameRules) ApplyAll(msgs []metrics.MeasurementEnvelope) []metrics.MeasurementEnvelope {
+	if r == nil {
+		return msgs
+	}
+	renamed := make([]metrics.MeasurementEnvelope, len(msgs))
+	for i, msg := range msgs {
+		msg.MetricName = r.Apply(msg.MetricName)
+		renamed[i] = msg
+	}
+	return renamed
+}
@@ -0,0 +1,48 @@
+package sinks
+
+import (
+	"testing"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMetricNameRules(t *testing.T) {
+	remaps := []string{"db_stats=database", "prometheus:db_stats=pg_database", "postgres:wal=wal_stats"}
+	prefixes := []string{"pgwatch_", "prometheus:pg_"}
+
+	rules, err := NewMetricNameRules("prometheus", remaps, prefixes)
+	require.NoError(t, err)
+	assert.Equal(t, "pg_pg_database", rules.Apply("db_stats"))
+	assert.Equal(t, "pg_wal", rules.Apply("wal"))
+	assert.Equal(t, "", rules.Apply(""), "empty names mean all metrics")
+
+	rules, err = NewMetricNameRules("postgresql", remaps, prefixes)
+	require.NoError(t, err)
+	assert.Equal(t, "pgwatch_database", rules.Apply("db_stats"))
+	assert.Equal(t, "pgwatch_wal_stats", rules.Apply("wal"))
+
+	rules, err = NewMetricNameRules("jsonfile", []string{"rpc:db_stats=database"}, []string{"prometheus:pg_"})
+	require.NoError(t, err)
+	assert.Nil(t, rules, "no rules for the sink type")
+	assert.Equal(t, "db_stats", rules.Apply("db_stats"))
+
+	rules, err = NewMetricNameRules("jsonfile", nil, []string{"pg:"})
+	require.NoError(t, err)
+	assert.Equal(t, "pg:wal", rules.Apply("wal"), "unknown sink types are part of the prefix")
+
+	for _, remap := range []string{"db_stats", "prometheus:=database", "db_stats="} {
+		_, err = NewMetricNameRules("jsonfile", []string{remap}, nil)
+		assert.Error(t, err, remap)
+	}
+}
+
+func TestMetricNameRulesApplyAll(t *testing.T) {
+	msgs := []metrics.MeasurementEnvelope{{MetricName: "wal", DBName: "db1"}}
+	rules := &MetricNameRules{Prefix: "pg_"}
+	renamed := rules.ApplyAll(msgs)
+	assert.Equal(t, "pg_wal", renamed[0].MetricName)
+	assert.Equal(t, "db1", renamed[0].DBName)
+	assert.Equal(t, "wal", msgs[0].MetricName, "measurements are shared with the other sinks")
+}
@@ -40,6 +40,7 @@ func NewWriterFromPostgresConn(ctx context.Context, conn db.PgxPoolIface, opts *
 		input:      make(chan []metrics.MeasurementEnvelope, cacheLimit),
 		lastError:  make(chan error),
 		sinkDb:     conn,
+		owners:     make(map[string]sourceOwner),
 	}
 	if err = db.Init(ctx, pgw.sinkDb, func(ctx context.Context, conn db.PgxIface) error {
 		l.Info("initialising measurements database...")
@@ -59,6 +60,15 @@ func NewWriterFromPostgresConn(ctx context.Context, conn db.PgxPoolIface, opts *
 	if err = pgw.ReadMetricSchemaType(); err != nil {
 		return
 	}
+	if err = pgw.ensureStorageSchemaFunctions(); err != nil {
+		return
+	}
+	if opts.DuplicateGuard > "" && opts.DuplicateGuard != DuplicateGuardOff {
+		// sinks created by older versions lack the ownership table
+		if _, err = pgw.sinkDb.Exec(ctx, sqlMetricSourceOwners); err != nil {
+			return
+		}
+	}
 	if err = pgw.EnsureBuiltinMetricDummies(); err != nil {
 		return
 	}
@@ -87,6 +97,15 @@ var sqlMetricChangeChunkIntervalTimescale string
 //go:embed sql/change_compression_interval.sql
 var sqlMetricChangeCompressionIntervalTimescale string
 
+//go:embed sql/source_owners.sql
+var sqlMetricSourceOwners string
+
+//go:embed sql/collector_lifecycle.sql
+var sqlMetricCollectorLifecycle string
+
+//go:embed sql/metric_columns.sql
+var sqlMetricColumns string
+
 var (
 	metricSchemaSQLs = []string{
 		sqlMetricAdminSchema,
@@ -95,6 +114,9 @@ var (
 		sqlMetricEnsurePartitionTimescale,
 		sqlMetricChangeChunkIntervalTimescale,
 		sqlMetricChangeCompressionIntervalTimescale,
+		sqlMetricSourceOwners,
+		sqlMetricCollectorLifecycle,
+		sqlMetricColumns,
 	}
 )
 
@@ -110,6 +132,11 @@ type PostgresWriter struct {
 	opts         *CmdOpts
 	input        chan []metrics.MeasurementEnvelope
 	lastError    chan error
+	owners       map[string]sourceOwner            // duplicate guard cache, only accessed from the poll loop
+	storageOpts  map[string]metrics.StorageOptions // [table]=partition creation options, only accessed from the poll loop
+	columns      map[string]metrics.ColumnAttrs    // [metric]=columns recorded in admin.metric_columns, only accessed from the poll loop
+	listing      listing                           // registered sources and metrics, maintenance stats
+	run          collectorRun                      // lifecycle record of this collector
 }
 
 type ExistingPartitionInfo struct {
@@ -244,11 +271,23 @@ func (pgw *PostgresWriter) flush(msgs []metrics.MeasurementEnvelope) {
 	pgPartBoundsDbName := make(map[string]map[string]ExistingPartitionInfo) // metric=[dbname=min/max]
 	var err error
 
+	if pgw.storageOpts == nil {
+		pgw.storageOpts = make(map[string]metrics.StorageOptions)
+	}
+	foreign := pgw.ForeignSources(msgs)
 	for _, msg := range msgs {
 		if len(msg.Data) == 0 {
 			continue
 		}
+		if _, ok := foreign[msg.DBName]; ok && pgw.opts.DuplicateGuard == DuplicateGuardDrop {
+			continue
+		}
 		logger.WithField("data", msg.Data).WithField("len", len(msg.Data)).Debug("sending to postgres")
+		pgw.storageOpts[storageTable(msg)] = msg.MetricDef.Storage
+		if e := pgw.recordColumns(msg); e != nil {
+			logger.WithError(e).WithField("metric", msg.MetricName).Warning("could not record the metric columns")
+		}
+		escapedNames.check(pgw.ctx, "postgres", "metric", msg.MetricName, pgIdentifier(msg.MetricName))
 
 		for _, dataRow := range msg.Data {
 			var epochTime time.Time
@@ -261,7 +300,7 @@ func (pgw *PostgresWriter) flush(msgs []metrics.MeasurementEnvelope) {
 
 			if msg.CustomTags != nil {
 				for k, v := range msg.CustomTags {
-					tags[k] = fmt.Sprintf("%v", v)
+					tags[pgText(k)] = pgText(fmt.Sprintf("%v", v))
 				}
 			}
 
@@ -272,10 +311,11 @@ func (pgw *PostgresWriter) flush(msgs []metrics.MeasurementEnvelope) {
 				if k == epochColumnName {
 					epochNs = v.(int64)
 				} else if strings.HasPrefix(k, tagPrefix) {
-					tag := k[4:]
-					tags[tag] = fmt.Sprintf("%v", v)
+					tags[pgText(k[4:])] = pgText(fmt.Sprintf("%v", v))
+				} else if s, ok := v.(string); ok {
+					fields[pgText(k)] = pgText(s)
 				} else {
-					fields[k] = v
+					fields[pgText(k)] = v
 				}
 			}
 
@@ -292,7 +332,7 @@ func (pgw *PostgresWriter) flush(msgs []metrics.MeasurementEnvelope) {
 			var metricsArr []MeasurementMessagePostgres
 			var ok bool
 
-			metricNameTemp := msg.MetricName
+			metricNameTemp := storageTable(msg)
 
 			metricsArr, ok = metricsToStorePerMetric[metricNameTemp]
 			if !ok {
@@ -306,28 +346,28 @@ func (pgw *PostgresWriter) flush(msgs []metrics.MeasurementEnvelope) {
 
 			if pgw.metricSchema == DbStorageSchemaTimescale {
 				// set min/max timestamps to check/create partitions
-				bounds, ok := pgPartBounds[msg.MetricName]
+				bounds, ok := pgPartBounds[metricNameTemp]
 				if !ok || (ok && epochTime.Before(bounds.StartTime)) {
 					bounds.StartTime = epochTime
-					pgPartBounds[msg.MetricName] = bounds
+					pgPartBounds[metricNameTemp] = bounds
 				}
 				if !ok || (ok && epochTime.After(bounds.EndTime)) {
 					bounds.EndTime = epochTime
-					pgPartBounds[msg.MetricName] = bounds
+					pgPartBounds[metricNameTemp] = bounds
 				}
 			} else if pgw.metricSchema == DbStorageSchemaPostgres {
-				_, ok := pgPartBoundsDbName[msg.MetricName]
+				_, ok := pgPartBoundsDbName[metricNameTemp]
 				if !ok {
-					pgPartBoundsDbName[msg.MetricName] = make(map[string]ExistingPartitionInfo)
+					pgPartBoundsDbName[metricNameTemp] = make(map[string]ExistingPartitionInfo)
 				}
-				bounds, ok := pgPartBoundsDbName[msg.MetricName][msg.DBName]
+				bounds, ok := pgPartBoundsDbName[metricNameTemp][msg.DBName]
 				if !ok || (ok && epochTime.Before(bounds.StartTime)) {
 					bounds.StartTime = epochTime
-					pgPartBoundsDbName[msg.MetricName][msg.DBName] = bounds
+					pgPartBoundsDbName[metricNameTemp][msg.DBName] = bounds
 				}
 				if !ok || (ok && epochTime.After(bounds.EndTime)) {
 					bounds.EndTime = epochTime
-					pgPartBoundsDbName[msg.MetricName][msg.DBName] = bounds
+					pgPartBoundsDbName[metricNameTemp][msg.DBName] = bounds
 				}
 			}
 		}
@@ -355,6 +395,9 @@ func (pgw *PostgresWriter) flush(msgs []metrics.MeasurementEnvelope) {
 	for metricName, metrics := range metricsToStorePerMetric {
 
 		getTargetTable := func() pgx.Identifier {
+			if schema, metric := splitStorageTable(metricName); schema != defaultStorageSchema {
+				return pgx.Identifier{schema, metric}
+			}
 			return pgx.Identifier{metricName}
 		}
 
@@ -439,12 +482,14 @@ func (pgw *PostgresWriter) EnsureMetricTime(pgPartBounds map[string]ExistingPart
 func (pgw *PostgresWriter) EnsureMetricTimescale(pgPartBounds map[string]ExistingPartitionInfo, force bool) (err error) {
 	logger := log.GetLogger(pgw.ctx)
 	sqlEnsure := `select * from admin.ensure_partition_timescale($1)`
+	sqlEnsureSchema := `select * from admin.ensure_schema_partition_timescale($2, $1, $3, $4, $5)`
 	for metric := range pgPartBounds {
 		if strings.HasSuffix(metric, "_realtime") {
 			continue
 		}
 		if _, ok := partitionMapMetric[metric]; !ok {
-			if _, err = pgw.sinkDb.Exec(pgw.ctx, sqlEnsure, metric); err != nil {
+			sql, args := pgw.partitionQuery(metric, sqlEnsure, sqlEnsureSchema)
+			if _, err = pgw.sinkDb.Exec(pgw.ctx, sql, args...); err != nil {
 				logger.Errorf("Failed to create a TimescaleDB table for metric '%s': %v", metric, err)
 				return err
 			}
@@ -457,6 +502,7 @@ func (pgw *PostgresWriter) EnsureMetricTimescale(pgPartBounds map[string]Existin
 func (pgw *PostgresWriter) EnsureMetricDbnameTime(metricDbnamePartBounds map[string]map[string]ExistingPartitionInfo, force bool) (err error) {
 	var rows pgx.Rows
 	sqlEnsure := `select * from admin.ensure_partition_metric_dbname_time($1, $2, $3)`
+	sqlEnsureSchema := `select * from admin.ensure_schema_partition_metric_dbname_time($4, $1, $2, $3, 0, $5, $6, $7)`
 	for metric, dbnameTimestampMap := range metricDbnamePartBounds {
 		_, ok := partitionMapMetricDbname[metric]
 		if !ok {
@@ -469,7 +515,8 @@ func (pgw *PostgresWriter) EnsureMetricDbnameTime(metricDbnamePartBounds map[str
 			}
 			partInfo, ok := partitionMapMetricDbname[metric][dbname]
 			if !ok || (ok && (pb.StartTime.Before(partInfo.StartTime))) || force {
-				if rows, err = pgw.sinkDb.Query(pgw.ctx, sqlEnsure, metric, dbname, pb.StartTime); err != nil {
+				sql, args := pgw.partitionQuery(metric, sqlEnsure, sqlEnsureSchema, dbname, pb.StartTime)
+				if rows, err = pgw.sinkDb.Query(pgw.ctx, sql, args...); err != nil {
 					return
 				}
 				if partInfo, err = pgx.CollectOneRow(rows, pgx.RowToStructByPos[ExistingPartitionInfo]); err != nil {
@@ -478,7 +525,8 @@ func (pgw *PostgresWriter) EnsureMetricDbnameTime(metricDbnamePartBounds map[str
 				partitionMapMetricDbname[metric][dbname] = partInfo
 			}
 			if pb.EndTime.After(partInfo.EndTime) || pb.EndTime.Equal(partInfo.EndTime) || force {
-				if rows, err = pgw.sinkDb.Query(pgw.ctx, sqlEnsure, metric, dbname, pb.StartTime); err != nil {
+				sql, args := pgw.partitionQuery(metric, sqlEnsure, sqlEnsureSchema, dbname, pb.StartTime)
+				if rows, err = pgw.sinkDb.Query(pgw.ctx, sql, args...); err != nil {
 					return
 				}
 				if partInfo, err = pgx.CollectOneRow(rows, pgx.RowToStructByPos[ExistingPartitionInfo]); err != nil {
@@ -545,99 +593,6 @@ func (pgw *PostgresWriter) deleteOldPartitions(delay time.Duration) {
 	}
 }
 
-// maintainUniqueSources is a background task that maintains a listing of unique sources for each metric.
-// This is used to avoid listing the same source multiple times in Grafana dropdowns.
-func (pgw *PostgresWriter) maintainUniqueSources() {
-	logger := log.GetLogger(pgw.ctx)
-	// due to metrics deletion the listing can go out of sync (a trigger not really wanted)
-	sqlGetAdvisoryLock := `SELECT pg_try_advisory_lock(1571543679778230000) AS have_lock` // 1571543679778230000 is just a random bigint
-	sqlTopLevelMetrics := `SELECT table_name FROM admin.get_top_level_metric_tables()`
-	sqlDistinct := `
-	WITH RECURSIVE t(dbname) AS (
-		SELECT MIN(dbname) AS dbname FROM %s
-		UNION
-		SELECT (SELECT MIN(dbname) FROM %s WHERE dbname > t.dbname) FROM t )
-	SELECT dbname FROM t WHERE dbname NOTNULL ORDER BY 1`
-	sqlDelete := `DELETE FROM admin.all_distinct_dbname_metrics WHERE NOT dbname = ANY($1) and metric = $2`
-	sqlDeleteAll := `DELETE FROM admin.all_distinct_dbname_metrics WHERE metric = $1`
-	sqlAdd := `
-		INSERT INTO admin.all_distinct_dbname_metrics SELECT u, $2 FROM (select unnest($1::text[]) as u) x
-		WHERE NOT EXISTS (select * from admin.all_distinct_dbname_metrics where dbname = u and metric = $2)
-		RETURNING *`
-
-	for {
-		select {
-		case <-pgw.ctx.Done():
-			return
-		case <-time.After(time.Hour * 24):
-		}
-		var lock bool
-		logger.Infof("Trying to get metricsDb listing maintainer advisory lock...") // to only have one "maintainer" in case of a "push" setup, as can get costly
-		if err := pgw.sinkDb.QueryRow(pgw.ctx, sqlGetAdvisoryLock).Scan(&lock); err != nil {
-			logger.Error("Getting metricsDb listing maintainer advisory lock failed:", err)
-			continue
-		}
-		if !lock {
-			logger.Info("Skipping admin.all_distinct_dbname_metrics maintenance as another instance has the advisory lock...")
-			continue
-		}
-
-		logger.Info("Refreshing admin.all_distinct_dbname_metrics listing table...")
-		rows, _ := pgw.sinkDb.Query(pgw.ctx, sqlTopLevelMetrics)
-		allDistinctMetricTables, err := pgx.CollectRows(rows, pgx.RowTo[string])
-		if err != nil {
-			logger.Error(err)
-			continue
-		}
-
-		for _, tableName := range allDistinctMetricTables {
-			foundDbnamesMap := make(map[string]bool)
-			foundDbnamesArr := make([]string, 0)
-			metricName := strings.Replace(tableName, "public.", "", 1)
-
-			logger.Debugf("Refreshing all_distinct_dbname_metrics listing for metric: %s", metricName)
-			rows, _ := pgw.sinkDb.Query(pgw.ctx, fmt.Sprintf(sqlDistinct, tableName, tableName))
-			ret, err := pgx.CollectRows(rows, pgx.RowTo[string])
-			// ret, err := DBExecRead(mainContext, metricDb, fmt.Sprintf(sqlDistinct, tableName, tableName))
-			if err != nil {
-				logger.Errorf("Could not refresh Postgres all_distinct_dbname_metrics listing table for '%s': %s", metricName, err)
-				break
-			}
-			for _, drDbname := range ret {
-				foundDbnamesMap[drDbname] = true // "set" behaviour, don't want duplicates
-			}
-
-			// delete all that are not known and add all that are not there
-			for k := range foundDbnamesMap {
-				foundDbnamesArr = append(foundDbnamesArr, k)
-			}
-			if len(foundDbnamesArr) == 0 { // delete all entries for given metric
-				logger.Debugf("Deleting Postgres all_distinct_dbname_metrics listing table entries for metric '%s':", metricName)
-
-				_, err = pgw.sinkDb.Exec(pgw.ctx, sqlDeleteAll, metricName)
-				if err != nil {
-					logger.Errorf("Could not delete Postgres all_distinct_dbname_metrics listing table entries for metric '%s': %s", metricName, err)
-				}
-				continue
-			}
-			cmdTag, err := pgw.sinkDb.Exec(pgw.ctx, sqlDelete, foundDbnamesArr, metricName)
-			if err != nil {
-				logger.Errorf("Could not refresh Postgres all_distinct_dbname_metrics listing table for metric '%s': %s", metricName, err)
-			} else if cmdTag.RowsAffected() > 0 {
-				logger.Infof("Removed %d stale entries from all_distinct_dbname_metrics listing table for metric: %s", cmdTag.RowsAffected(), metricName)
-			}
-			cmdTag, err = pgw.sinkDb.Exec(pgw.ctx, sqlAdd, foundDbnamesArr, metricName)
-			if err != nil {
-				logger.Errorf("Could not refresh Postgres all_distinct_dbname_metrics listing table for metric '%s': %s", metricName, err)
-			} else if cmdTag.RowsAffected() > 0 {
-				logger.Infof("Added %d entry to the Postgres all_distinct_dbname_metrics listing table for metric: %s", cmdTag.RowsAffected(), metricName)
-			}
-			time.Sleep(time.Minute)
-		}
-
-	}
-}
-
 func (pgw *PostgresWriter) DropOldTimePartitions(metricAgeDaysThreshold int) (res int, err error) {
 	sqlOldPart := `select admin.drop_old_time_partitions($1, $2)`
 	err = pgw.sinkDb.QueryRow(pgw.ctx, sqlOldPart, metricAgeDaysThreshold, false).Scan(&res)
@@ -653,12 +608,66 @@ func (pgw *PostgresWriter) GetOldTimePartitions(metricAgeDaysThreshold int) ([]s
 	return nil, err
 }
 
-func (pgw *PostgresWriter) AddDBUniqueMetricToListingTable(dbUnique, metric string) error {
-	sql := `insert into admin.all_distinct_dbname_metrics
-			select $1, $2
-			where not exists (
-				select * from admin.all_distinct_dbname_metrics where dbname = $1 and metric = $2
-			)`
-	_, err := pgw.sinkDb.Exec(pgw.ctx, sql, dbUnique, metric)
-	return err
+// Ping checks the availability of the metrics database
+func (pgw *PostgresWriter) Ping(ctx context.Context) error {
+	return pgw.sinkDb.Ping(ctx)
+}
+
+// metricTable returns the quoted table of the metric, "" if there is none yet. The metric table is looked up
+// in all storage schemas
+func (pgw *PostgresWriter) metricTable(metricName string) (table string, err error) {
+	sqlTable := `SELECT format('%I.%I', n.nspname, c.relname) FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+	WHERE c.relname = $1 AND pg_catalog.obj_description(c.oid, 'pg_class') = 'pgwatch-generated-metric-lvl'
+	ORDER BY n.nspname = 'public' LIMIT 1`
+	err = pgw.sinkDb.QueryRow(pgw.ctx, sqlTable, metricName).Scan(&table)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return
+}
+
+// LastMeasurementTime returns the time of the latest stored measurement of the metric for the source,
+// zero time is returned if there is none
+func (pgw *PostgresWriter) LastMeasurementTime(dbUnique, metricName string) (time.Time, error) {
+	var last *time.Time
+	table, err := pgw.metricTable(metricName)
+	if err != nil || table == "" {
+		return time.Time{}, err
+	}
+	sql := `SELECT max(time) FROM ` + table + ` WHERE dbname = $1`
+	if err := pgw.sinkDb.QueryRow(pgw.ctx, sql, dbUnique).Scan(&last); err != nil {
+		return time.Time{}, err
+	}
+	if last == nil {
+		return time.Time{}, nil
+	}
+	return *last, nil
+}
+
+// ColumnHistory returns the hourly averages of the numeric metric column stored for the source since the given time,
+// per value of the tag column. Only the top series with the largest latest averages are returned
+func (pgw *PostgresWriter) ColumnHistory(dbUnique, metricName, column, tag string, since time.Time, top int) (map[string][]HistoryPoint, error) {
+	table, err := pgw.metricTable(metricName)
+	if err != nil || table == "" {
+		return nil, err
+	}
+	sql := `WITH h AS (
+		SELECT coalesce(tag_data->>$3, '') AS tag, date_trunc('hour', time) AS hour, avg((data->>$2)::float8) AS value
+		FROM ` + table + ` WHERE dbname = $1 AND time >= $4 AND jsonb_typeof(data->$2) = 'number' GROUP BY 1, 2
+	), top AS (
+		SELECT tag FROM (SELECT DISTINCT ON (tag) tag, value FROM h ORDER BY tag, hour DESC) latest ORDER BY value DESC LIMIT $5
+	)
+	SELECT tag, hour, value FROM h WHERE tag IN (SELECT tag FROM top) ORDER BY tag, hour`
+	rows, err := pgw.sinkDb.Query(pgw.ctx, sql, dbUnique, column, tag, since, top)
+	if err != nil {
+		return nil, err
+	}
+	history := make(map[string][]HistoryPoint)
+	var key string
+	var p HistoryPoint
+	_, err = pgx.ForEachRow(rows, []any{&key, &p.Time, &p.Value}, func() error {
+		history[key] = append(history[key], p)
+		return nil
+	})
+	return history, err
 }
@@ -39,6 +39,7 @@ func TestNewWriterFromPostgresConn(t *testing.T) {
 	conn.ExpectPing()
 	conn.ExpectQuery("SELECT EXISTS").WithArgs("admin").WillReturnRows(pgxmock.NewRows([]string{"schema_type"}).AddRow(true))
 	conn.ExpectQuery("SELECT schema_type").WillReturnRows(pgxmock.NewRows([]string{"schema_type"}).AddRow(true))
+	conn.ExpectQuery("SELECT to_regproc").WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
 	for _, m := range metrics.GetDefaultBuiltInMetrics() {
 		conn.ExpectExec("select admin.ensure_dummy_metrics_table").WithArgs(m).WillReturnResult(pgxmock.NewResult("EXECUTE", 1))
 	}
@@ -128,3 +129,81 @@ func TestPostgresWriter_EnsureMetricTime(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NoError(t, conn.ExpectationsWereMet())
 }
+
+func TestLastMeasurementTime(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	pgw := PostgresWriter{
+		ctx:    ctx,
+		sinkDb: conn,
+	}
+	now := time.Now()
+	expectTable := func(table string) {
+		conn.ExpectQuery("SELECT format").WithArgs("db_stats").WillReturnRows(pgxmock.NewRows([]string{"format"}).AddRow(table))
+	}
+	expectTable(`public.db_stats`)
+	conn.ExpectQuery(`SELECT max\(time\) FROM public.db_stats WHERE dbname = \$1`).
+		WithArgs("db1").
+		WillReturnRows(pgxmock.NewRows([]string{"max"}).AddRow(&now))
+	last, err := pgw.LastMeasurementTime("db1", "db_stats")
+	assert.NoError(t, err)
+	assert.Equal(t, now, last)
+
+	expectTable(`bulk.db_stats`)
+	conn.ExpectQuery(`SELECT max\(time\) FROM bulk.db_stats WHERE dbname = \$1`).
+		WithArgs("db1").
+		WillReturnRows(pgxmock.NewRows([]string{"max"}).AddRow(&now))
+	last, err = pgw.LastMeasurementTime("db1", "db_stats")
+	assert.NoError(t, err)
+	assert.Equal(t, now, last, "metric table in a storage schema")
+
+	conn.ExpectQuery("SELECT format").WithArgs("db_stats").WillReturnRows(pgxmock.NewRows([]string{"format"}))
+	last, err = pgw.LastMeasurementTime("db1", "db_stats")
+	assert.NoError(t, err)
+	assert.True(t, last.IsZero(), "no metric table yet")
+
+	expectTable(`public.db_stats`)
+	conn.ExpectQuery("SELECT max").
+		WithArgs("db1").
+		WillReturnRows(pgxmock.NewRows([]string{"max"}).AddRow(nil))
+	last, err = pgw.LastMeasurementTime("db1", "db_stats")
+	assert.NoError(t, err)
+	assert.True(t, last.IsZero(), "no measurements stored yet")
+
+	expectTable(`public.db_stats`)
+	conn.ExpectQuery("SELECT max").
+		WithArgs("db1").
+		WillReturnError(errors.New("expected"))
+	_, err = pgw.LastMeasurementTime("db1", "db_stats")
+	assert.Error(t, err)
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
+
+func TestColumnHistory(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	pgw := PostgresWriter{
+		ctx:    ctx,
+		sinkDb: conn,
+	}
+	since := time.Now().Add(-time.Hour * 24)
+	conn.ExpectQuery("SELECT format").WithArgs("table_stats").WillReturnRows(pgxmock.NewRows([]string{"format"}))
+	history, err := pgw.ColumnHistory("db1", "table_stats", "total_relation_size_b", "table_full_name", since, 2)
+	assert.NoError(t, err)
+	assert.Empty(t, history, "no metric table yet")
+
+	conn.ExpectQuery("SELECT format").WithArgs("table_stats").WillReturnRows(pgxmock.NewRows([]string{"format"}).AddRow("public.table_stats"))
+	conn.ExpectQuery(`FROM public.table_stats WHERE dbname = \$1`).
+		WithArgs("db1", "total_relation_size_b", "table_full_name", since, 2).
+		WillReturnRows(pgxmock.NewRows([]string{"tag", "hour", "value"}).
+			AddRow("a", since, 1.0).AddRow("a", since.Add(time.Hour), 2.0).AddRow("b", since, 3.0))
+	history, err = pgw.ColumnHistory("db1", "table_stats", "total_relation_size_b", "table_full_name", since, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []HistoryPoint{{since, 1}, {since.Add(time.Hour), 2}}, history["a"])
+	assert.Len(t, history["b"], 1)
+
+	conn.ExpectQuery("SELECT format").WithArgs("table_stats").WillReturnError(errors.New("expected"))
+	_, err = pgw.ColumnHistory("db1", "table_stats", "total_relation_size_b", "table_full_name", since, 2)
+	assert.Error(t, err)
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
@@ -0,0 +1,118 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/db"
+)
+
+// preflightTimeout limits the connectivity check of a single sink
+const preflightTimeout = 10 * time.Second
+
+// ErrSinkUnreachable marks the sinks connected lazily, the collector retries writing to them after the start
+var ErrSinkUnreachable = errors.New("sink unreachable")
+
+// CheckSink validates the sink URI and the connectivity of the sink without creating it. Schemas and tables
+// are not created, the problems the sink would fail with on the start are returned instead
+func CheckSink(ctx context.Context, uri string, opts *CmdOpts) error {
+	scheme, path, found := strings.Cut(uri, "://")
+	if !found || scheme == "" || path == "" {
+		return errors.New("malformed sink URI, expected <scheme>://<path>")
+	}
+	if _, err := NewMetricNameRules(scheme, opts.MetricNameRemaps, opts.MetricNamePrefixes); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, preflightTimeout)
+	defer cancel() // closes the connections of the lazily connected writers
+	switch scheme {
+	case "jsonfile":
+		return checkWritableDir(filepath.Dir(path))
+	case "postgres", "postgresql":
+		conn, err := db.New(ctx, uri)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		return checkPostgresSink(ctx, conn)
+	case "prometheus":
+		return nil // the address is checked with the other listeners
+	case "rpc":
+		conn, err := net.DialTimeout("tcp", path, preflightTimeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case "grpc":
+		_, err := url.Parse("grpc://" + path) // the agents spool while the collector is unreachable
+		return err
+	case "influx":
+		w, err := NewInfluxWriter(ctx, path, opts)
+		if err != nil {
+			return err
+		}
+		if err = w.Ping(ctx); err != nil {
+			return fmt.Errorf("%w: %w", ErrSinkUnreachable, err)
+		}
+	case "kafka":
+		w, err := NewKafkaWriter(ctx, path, opts)
+		if err != nil {
+			return err
+		}
+		if err = w.Ping(ctx); err != nil {
+			return fmt.Errorf("%w: %w", ErrSinkUnreachable, err)
+		}
+	case "elastic", "opensearch":
+		w, err := NewElasticWriter(ctx, path, opts)
+		if err != nil {
+			return err
+		}
+		if err = w.Ping(ctx); err != nil {
+			return fmt.Errorf("%w: %w", ErrSinkUnreachable, err)
+		}
+	default:
+		return fmt.Errorf("unknown schema %s, use one of jsonfile, postgresql, prometheus, rpc, grpc, influx, kafka or elastic", scheme)
+	}
+	return nil
+}
+
+// checkPostgresSink checks the measurements schema is present and up to date, or may be created by the sink
+func checkPostgresSink(ctx context.Context, conn db.PgxIface) error {
+	exists, err := db.DoesSchemaExist(ctx, conn, "admin")
+	if err != nil {
+		return err
+	}
+	var allowed bool
+	if !exists {
+		if err = conn.QueryRow(ctx, `SELECT has_database_privilege(current_database(), 'CREATE')`).Scan(&allowed); err == nil && !allowed {
+			err = errors.New("the measurements schema is missing and the user may not create it, run the sink with a privileged user once")
+		}
+		return err
+	}
+	var schemaType string
+	if err = conn.QueryRow(ctx, `SELECT schema_type FROM admin.storage_schema_type`).Scan(&schemaType); err != nil {
+		return fmt.Errorf("the measurements schema is incomplete: %w", err)
+	}
+	sql := `SELECT to_regprocedure('admin.ensure_schema_partition_metric_dbname_time(text,text,text,timestamptz,integer,text,text,boolean)') IS NOT NULL
+		OR has_schema_privilege('admin', 'CREATE')`
+	if err = conn.QueryRow(ctx, sql).Scan(&allowed); err == nil && !allowed {
+		err = errors.New("the measurements schema was created by an older version and the user may not upgrade it, run the sink with a privileged user once")
+	}
+	return err
+}
+
+// checkWritableDir checks the files of the sink can be created in the directory
+func checkWritableDir(dir string) error {
+	f, err := os.CreateTemp(dir, ".pgwatch-preflight-*")
+	if err != nil {
+		return err
+	}
+	return errors.Join(f.Close(), os.Remove(f.Name()))
+}
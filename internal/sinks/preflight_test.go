@@ -0,0 +1,79 @@
+package sinks
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSink(t *testing.T) {
+	dir := t.TempDir()
+	opts := &CmdOpts{}
+	assert.NoError(t, CheckSink(ctx, "jsonfile://"+filepath.Join(dir, "out.json"), opts))
+	entries, _ := os.ReadDir(dir)
+	assert.Empty(t, entries, "nothing left behind")
+	assert.Error(t, CheckSink(ctx, "jsonfile://"+filepath.Join(dir, "missing", "out.json"), opts))
+
+	assert.EqualError(t, CheckSink(ctx, "localhost:5432", opts), "malformed sink URI, expected <scheme>://<path>")
+	assert.ErrorContains(t, CheckSink(ctx, "graphite://localhost:2003", opts), "unknown schema graphite")
+	assert.NoError(t, CheckSink(ctx, "prometheus://localhost:9187/pgwatch", opts))
+	assert.Error(t, CheckSink(ctx, "jsonfile://out.json", &CmdOpts{MetricNameRemaps: []string{"bad"}}))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closed := ln.Addr().String()
+	require.NoError(t, ln.Close())
+	err = CheckSink(ctx, "rpc://"+closed, opts)
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrSinkUnreachable), "the rpc sink fails on the start")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	influx := "influx://" + strings.TrimPrefix(srv.URL, "http://") + "/pgwatch?org=pg"
+	assert.NoError(t, CheckSink(ctx, influx, opts))
+	assert.ErrorIs(t, CheckSink(ctx, "influx://"+closed+"/pgwatch", opts), ErrSinkUnreachable)
+	assert.ErrorIs(t, CheckSink(ctx, "kafka://"+closed+"/pgwatch", opts), ErrSinkUnreachable)
+	assert.ErrorIs(t, CheckSink(ctx, "elastic://"+closed+"/pgwatch", opts), ErrSinkUnreachable)
+	assert.NoError(t, CheckSink(ctx, "opensearch://"+strings.TrimPrefix(srv.URL, "http://"), opts))
+	assert.NoError(t, CheckSink(ctx, "grpc://"+closed, opts), "the agents spool")
+}
+
+func TestCheckPostgresSink(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	conn.ExpectQuery("SELECT EXISTS").WithArgs("admin").WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+	conn.ExpectQuery("has_database_privilege").WillReturnRows(pgxmock.NewRows([]string{"allowed"}).AddRow(true))
+	assert.NoError(t, checkPostgresSink(ctx, conn), "created on the start")
+
+	conn.ExpectQuery("SELECT EXISTS").WithArgs("admin").WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+	conn.ExpectQuery("has_database_privilege").WillReturnRows(pgxmock.NewRows([]string{"allowed"}).AddRow(false))
+	assert.ErrorContains(t, checkPostgresSink(ctx, conn), "may not create it")
+
+	conn.ExpectQuery("SELECT EXISTS").WithArgs("admin").WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+	conn.ExpectQuery("SELECT schema_type").WillReturnError(errors.New(`relation "admin.storage_schema_type" does not exist`))
+	assert.ErrorContains(t, checkPostgresSink(ctx, conn), "the measurements schema is incomplete")
+
+	conn.ExpectQuery("SELECT EXISTS").WithArgs("admin").WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+	conn.ExpectQuery("SELECT schema_type").WillReturnRows(pgxmock.NewRows([]string{"schema_type"}).AddRow("postgres"))
+	conn.ExpectQuery("SELECT to_regproc").WillReturnRows(pgxmock.NewRows([]string{"allowed"}).AddRow(false))
+	assert.ErrorContains(t, checkPostgresSink(ctx, conn), "may not upgrade it")
+
+	conn.ExpectQuery("SELECT EXISTS").WithArgs("admin").WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+	conn.ExpectQuery("SELECT schema_type").WillReturnRows(pgxmock.NewRows([]string{"schema_type"}).AddRow("timescale"))
+	conn.ExpectQuery("SELECT to_regproc").WillReturnRows(pgxmock.NewRows([]string{"allowed"}).AddRow(true))
+	assert.NoError(t, checkPostgresSink(ctx, conn))
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
@@ -0,0 +1,97 @@
+package sinks
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// newPromTLSConfig loads the certificate of the scrape endpoint, nil is returned if TLS is not configured
+func newPromTLSConfig(opts *CmdOpts) (*tls.Config, error) {
+	if opts.PromTLSCert == "" && opts.PromTLSKey == "" {
+		return nil, nil
+	}
+	if opts.PromTLSCert == "" || opts.PromTLSKey == "" {
+		return nil, errors.New("both --prometheus-tls-cert and --prometheus-tls-key must be specified")
+	}
+	cert, err := tls.LoadX509KeyPair(opts.PromTLSCert, opts.PromTLSKey)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+}
+
+// parseCIDRs parses the comma separated list of networks
+func parseCIDRs(list string) (nets []*net.IPNet, err error) {
+	for _, c := range strings.Split(list, ",") {
+		if c = strings.TrimSpace(c); c == "" {
+			continue
+		}
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --prometheus-allowed-cidrs network %q: %w", c, err)
+		}
+		nets = append(nets, n)
+	}
+	return
+}
+
+// isAddrAllowed checks the remote address of the request against the allowed networks
+func isAddrAllowed(remoteAddr string, nets []*net.IPNet) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	for _, n := range nets {
+		if ip != nil && n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func equalSecret(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// isScrapeAuthorized checks the bearer token or the basic auth credentials if any of them is configured
+func isScrapeAuthorized(opts *CmdOpts, r *http.Request) bool {
+	if opts.PromUser == "" && opts.PromToken == "" {
+		return true
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && opts.PromToken > "" {
+		return equalSecret(token, opts.PromToken)
+	}
+	if user, password, ok := r.BasicAuth(); ok && opts.PromUser > "" {
+		return equalSecret(user, opts.PromUser) && equalSecret(password, opts.PromPassword)
+	}
+	return false
+}
+
+// newPromAccessHandler restricts the scrape endpoint to the allowed networks and authorized scrapers,
+// as the metrics may reveal schema and query details
+func newPromAccessHandler(opts *CmdOpts, next http.Handler) (http.Handler, error) {
+	nets, err := parseCIDRs(opts.PromAllowedCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(nets) > 0 && !isAddrAllowed(r.RemoteAddr, nets) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if !isScrapeAuthorized(opts, r) {
+			if opts.PromUser > "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="pgwatch"`)
+			}
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}), nil
+}
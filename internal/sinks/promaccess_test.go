@@ -0,0 +1,77 @@
+package sinks
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromAccessHandler(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	scrape := func(h http.Handler, remoteAddr string, setAuth func(*http.Request)) int {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.RemoteAddr = remoteAddr
+		if setAuth != nil {
+			setAuth(req)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	h, err := newPromAccessHandler(&CmdOpts{}, ok)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, scrape(h, "192.0.2.1:1234", nil), "open by default")
+
+	_, err = newPromAccessHandler(&CmdOpts{PromAllowedCIDRs: "10.0.0.0/8,garbage"}, ok)
+	assert.Error(t, err)
+
+	opts := &CmdOpts{PromAllowedCIDRs: "10.0.0.0/8, ::1/128", PromUser: "prom", PromPassword: "secret", PromToken: "t0ken"}
+	h, err = newPromAccessHandler(opts, ok)
+	require.NoError(t, err)
+	basic := func(r *http.Request) { r.SetBasicAuth("prom", "secret") }
+	assert.Equal(t, http.StatusForbidden, scrape(h, "192.0.2.1:1234", basic))
+	assert.Equal(t, http.StatusUnauthorized, scrape(h, "10.1.2.3:1234", nil))
+	assert.Equal(t, http.StatusOK, scrape(h, "10.1.2.3:1234", basic))
+	assert.Equal(t, http.StatusOK, scrape(h, "[::1]:1234", basic))
+	assert.Equal(t, http.StatusUnauthorized, scrape(h, "10.1.2.3:1234", func(r *http.Request) { r.SetBasicAuth("prom", "wrong") }))
+	assert.Equal(t, http.StatusOK, scrape(h, "10.1.2.3:1234", func(r *http.Request) { r.Header.Set("Authorization", "Bearer t0ken") }))
+	assert.Equal(t, http.StatusUnauthorized, scrape(h, "10.1.2.3:1234", func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }))
+}
+
+func TestNewPromTLSConfig(t *testing.T) {
+	cfg, err := newPromTLSConfig(&CmdOpts{})
+	assert.NoError(t, err)
+	assert.Nil(t, cfg, "TLS is disabled by default")
+
+	_, err = newPromTLSConfig(&CmdOpts{PromTLSCert: "cert.pem"})
+	assert.Error(t, err, "key is missing")
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), DNSNames: []string{"localhost"}, NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+
+	cfg, err = newPromTLSConfig(&CmdOpts{PromTLSCert: certFile, PromTLSKey: keyFile})
+	assert.NoError(t, err)
+	assert.Len(t, cfg.Certificates, 1)
+}
@@ -2,20 +2,24 @@ package sinks
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
-	"net"
+	"maps"
 	"net/http"
-	"reflect"
 	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/handover"
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
 )
 
 // PrometheusWriter is a sink that allows to expose metric measurements to Prometheus scrapper.
@@ -25,14 +29,29 @@ type PrometheusWriter struct {
 	lastScrapeErrors                  prometheus.Gauge
 	totalScrapes, totalScrapeFailures prometheus.Counter
 	PrometheusNamespace               string
+	exemplars                         bool                        // OpenMetrics format with exemplars enabled
+	cacheFile                         string                      // async cache snapshot to survive restarts, disabled if empty
+	sinksHealth                       HealthReporter              // set by the MultiWriter to expose the sinks health
+	probe                             atomic.Pointer[ProbeFunc]   // on-demand fetching for /probe, set by the reaper
+	descs                             map[string]*prometheus.Desc // series descriptors by metric, column and label names
+	descsLock                         sync.RWMutex
 }
 
 const promInstanceUpStateMetric = "instance_up"
 
+// Prometheus sends the scrape timeout with every request, a part of it is reserved for the transfer
+const (
+	promScrapeTimeoutHeader = "X-Prometheus-Scrape-Timeout-Seconds"
+	promScrapeTimeoutShare  = 0.9
+)
+
+// promDescCacheSize is the number of cached series descriptors, the cache starts over once reached
+const promDescCacheSize = 10000
+
 // timestamps older than that will be ignored on the Prom scraper side anyway, so better don't emit at all and just log a notice
 const promScrapingStalenessHardDropLimit = time.Minute * time.Duration(10)
 
-func NewPrometheusWriter(ctx context.Context, connstr string) (promw *PrometheusWriter, err error) {
+func NewPrometheusWriter(ctx context.Context, connstr string, opts *CmdOpts) (promw *PrometheusWriter, err error) {
 	addr, namespace, found := strings.Cut(connstr, "/")
 	if !found {
 		namespace = "pgwatch"
@@ -42,6 +61,8 @@ func NewPrometheusWriter(ctx context.Context, connstr string) (promw *Prometheus
 	promw = &PrometheusWriter{
 		ctx:                 ctx,
 		PrometheusNamespace: namespace,
+		exemplars:           opts.PromExemplars,
+		cacheFile:           opts.PromCacheFile,
 		lastScrapeErrors: prometheus.NewGauge(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Name:      "exporter_last_scrape_errors",
@@ -59,20 +80,34 @@ func NewPrometheusWriter(ctx context.Context, connstr string) (promw *Prometheus
 		}),
 	}
 
-	if err = prometheus.Register(promw); err != nil {
-		return
+	mux := http.NewServeMux()
+	mux.HandleFunc("/probe", promw.serveProbe)
+	mux.Handle("/", promw)
+	handler, err := newPromAccessHandler(opts, mux)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := newPromTLSConfig(opts)
+	if err != nil {
+		return nil, err
 	}
 	promServer := &http.Server{
 		Addr:    addr,
-		Handler: promhttp.Handler(),
+		Handler: handler,
 	}
 
-	ln, err := net.Listen("tcp", promServer.Addr)
+	ln, err := handover.Listen(promServer.Addr)
 	if err != nil {
 		return nil, err
 	}
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
 
 	go func() { log.GetLogger(ctx).Error(promServer.Serve(ln)) }()
+	if promw.cacheFile > "" {
+		go promw.maintainSnapshot(promw.cacheFile)
+	}
 
 	l.Info(`measurements sink is activated`)
 	return
@@ -96,6 +131,7 @@ func (promw *PrometheusWriter) PromAsyncCacheAddMetricData(dbUnique, metric stri
 	defer promAsyncMetricCacheLock.Unlock()
 	if _, ok := promAsyncMetricCache[dbUnique]; ok {
 		promAsyncMetricCache[dbUnique][metric] = msgArr
+		delete(promRestored, [2]string{dbUnique, metric})
 	}
 }
 
@@ -119,6 +155,12 @@ func (promw *PrometheusWriter) PurgeMetricsFromPromAsyncCacheIfAny(dbUnique, met
 	}
 }
 
+// ForgetSource purges the cached measurements of the source
+func (promw *PrometheusWriter) ForgetSource(dbUnique string) error {
+	promw.PurgeMetricsFromPromAsyncCacheIfAny(dbUnique, "")
+	return nil
+}
+
 func (promw *PrometheusWriter) SyncMetric(dbUnique, metricName, op string) error {
 	switch op {
 	case "remove":
@@ -129,14 +171,52 @@ func (promw *PrometheusWriter) SyncMetric(dbUnique, metricName, op string) error
 	return nil
 }
 
+// ServeHTTP serves a scrape. If Prometheus sends its scrape timeout, the measurements are collected
+// only until the timeout is near and the scrape is flagged as incomplete, instead of being dropped as a whole
+func (promw *PrometheusWriter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var deadline time.Time
+	if timeout, err := strconv.ParseFloat(r.Header.Get(promScrapeTimeoutHeader), 64); err == nil && timeout > 0 {
+		deadline = time.Now().Add(time.Duration(timeout * promScrapeTimeoutShare * float64(time.Second)))
+	}
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(&promScrapeCollector{promw: promw, deadline: deadline})
+	promhttp.HandlerFor(prometheus.Gatherers{prometheus.DefaultGatherer, reg}, promhttp.HandlerOpts{EnableOpenMetrics: promw.exemplars}).ServeHTTP(w, r)
+}
+
+// promScrapeCollector collects the measurements of a single scrape within its deadline
+type promScrapeCollector struct {
+	promw    *PrometheusWriter
+	deadline time.Time
+}
+
+func (c *promScrapeCollector) Describe(_ chan<- *prometheus.Desc) {
+}
+
+func (c *promScrapeCollector) Collect(ch chan<- prometheus.Metric) {
+	c.promw.collect(ch, c.deadline)
+}
+
 func (promw *PrometheusWriter) Describe(_ chan<- *prometheus.Desc) {
 }
 
 func (promw *PrometheusWriter) Collect(ch chan<- prometheus.Metric) {
+	promw.collect(ch, time.Time{})
+}
+
+// collect sends the cached measurements until the deadline, if any, is reached
+func (promw *PrometheusWriter) collect(ch chan<- prometheus.Metric, deadline time.Time) {
 	var lastScrapeErrors float64
+	incomplete := false
+	defer func() {
+		ch <- promw.scrapeIncompleteMetric(incomplete)
+	}()
 	logger := log.GetLogger(promw.ctx)
 	promw.totalScrapes.Add(1)
 	ch <- promw.totalScrapes
+	promw.collectSinksHealth(ch)
+	if promw.cacheFile > "" {
+		ch <- promw.restoredMetric()
+	}
 
 	if len(promAsyncMetricCache) == 0 {
 		logger.Warning("No dbs configured for monitoring. Check config")
@@ -146,12 +226,25 @@ func (promw *PrometheusWriter) Collect(ch chan<- prometheus.Metric) {
 		return
 	}
 
+	// stale measurements are purged from the cache during the conversion, so iterate over a snapshot
+	promAsyncMetricCacheLock.RLock()
+	cache := make(map[string]map[string][]metrics.MeasurementEnvelope, len(promAsyncMetricCache))
 	for dbname, metricsMessages := range promAsyncMetricCache {
+		cache[dbname] = maps.Clone(metricsMessages)
+	}
+	promAsyncMetricCacheLock.RUnlock()
+
+collecting:
+	for dbname, metricsMessages := range cache {
 		promw.setInstanceUpDownState(ch, dbname)
 		for metric, metricMessages := range metricsMessages {
 			if metric == "change_events" {
 				continue // not supported
 			}
+			if !deadline.IsZero() && time.Now().After(deadline) {
+				incomplete = true
+				break collecting
+			}
 			if len(metricMessages) > 0 {
 				promMetrics := promw.MetricStoreMessageToPromMetrics(metricMessages[0])
 				for _, pm := range promMetrics { // collect & send later in batch? capMetricChan = 1000 limit in prometheus code
@@ -159,7 +252,9 @@ func (promw *PrometheusWriter) Collect(ch chan<- prometheus.Metric) {
 				}
 			}
 		}
-
+	}
+	if incomplete {
+		log.GetLogger(promw.ctx).Warning("scrape timeout reached, some measurements were skipped")
 	}
 
 	ch <- promw.totalScrapeFailures
@@ -169,6 +264,36 @@ func (promw *PrometheusWriter) Collect(ch chan<- prometheus.Metric) {
 	// atomic.StoreInt64(&lastSuccessfulDatastoreWriteTimeEpoch, time.Now().Unix())
 }
 
+// scrapeIncompleteMetric reports whether the scrape was cut short due to the Prometheus scrape timeout
+func (promw *PrometheusWriter) scrapeIncompleteMetric(incomplete bool) prometheus.Metric {
+	desc := prometheus.NewDesc(prometheus.BuildFQName(promw.PrometheusNamespace, "", "scrape_incomplete"),
+		"Whether some measurements were skipped to answer within the Prometheus scrape timeout", nil, nil)
+	v := 0.0
+	if incomplete {
+		v = 1
+	}
+	return prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v)
+}
+
+// collectSinksHealth exposes the health of all configured sinks as self-telemetry
+func (promw *PrometheusWriter) collectSinksHealth(ch chan<- prometheus.Metric) {
+	if promw.sinksHealth == nil {
+		return
+	}
+	upDesc := prometheus.NewDesc(prometheus.BuildFQName(promw.PrometheusNamespace, "sink", "up"), "Whether the last write or health check of the sink succeeded", []string{"sink"}, nil)
+	timeoutsDesc := prometheus.NewDesc(prometheus.BuildFQName(promw.PrometheusNamespace, "sink", "write_timeouts_total"), "Writes not finished within the sink write timeout", []string{"sink"}, nil)
+	droppedDesc := prometheus.NewDesc(prometheus.BuildFQName(promw.PrometheusNamespace, "sink", "dropped_writes_total"), "Measurement batches dropped while the sink was busy", []string{"sink"}, nil)
+	for _, h := range promw.sinksHealth.Health() {
+		up := 0.0
+		if h.Healthy {
+			up = 1
+		}
+		ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, up, h.Sink)
+		ch <- prometheus.MustNewConstMetric(timeoutsDesc, prometheus.CounterValue, float64(h.WriteTimeouts), h.Sink)
+		ch <- prometheus.MustNewConstMetric(droppedDesc, prometheus.CounterValue, float64(h.DroppedWrites), h.Sink)
+	}
+}
+
 func (promw *PrometheusWriter) setInstanceUpDownState(ch chan<- prometheus.Metric, dbName string) {
 	logger := log.GetLogger(promw.ctx)
 	data := make(metrics.Measurement)
@@ -219,10 +344,15 @@ func (promw *PrometheusWriter) MetricStoreMessageToPromMetrics(msg metrics.Measu
 		}
 	}
 
+	// the maps and slices are reused for all rows, the descriptors of the series are cached
+	labels := make(map[string]string)
+	fields := make(map[string]float64)
+	var labelKeys []string
+	var key []byte
 	for _, dr := range msg.Data {
-		labels := make(map[string]string)
-		fields := make(map[string]float64)
-		labels["dbname"] = msg.DBName
+		clear(labels)
+		clear(fields)
+		labels["dbname"] = promLabelValue(msg.DBName)
 
 		for k, v := range dr {
 			if v == nil || v == "" || k == epochColumnName {
@@ -230,67 +360,171 @@ func (promw *PrometheusWriter) MetricStoreMessageToPromMetrics(msg metrics.Measu
 			}
 
 			if strings.HasPrefix(k, "tag_") {
-				tag := k[4:]
-				labels[tag] = fmt.Sprintf("%v", v)
+				labels[promw.labelName(k[4:])] = promLabelValue(promString(v))
+			} else if f, ok := promValue(v); ok {
+				fields[k] = f
 			} else {
-				dataType := reflect.TypeOf(v).String()
-				if dataType == "float64" || dataType == "float32" || dataType == "int64" || dataType == "int32" || dataType == "int" {
-					f, err := strconv.ParseFloat(fmt.Sprintf("%v", v), 64)
-					if err != nil {
-						logger.Debugf("Skipping scraping column %s of [%s:%s]: %v", k, msg.DBName, msg.MetricName, err)
-					}
-					fields[k] = f
-				} else if dataType == "bool" {
-					if v.(bool) {
-						fields[k] = 1
-					} else {
-						fields[k] = 0
-					}
-				} else {
-					logger.Debugf("Skipping scraping column %s of [%s:%s], unsupported datatype: %s", k, msg.DBName, msg.MetricName, dataType)
-					continue
-				}
+				logger.Debugf("Skipping scraping column %s of [%s:%s], unsupported datatype: %T", k, msg.DBName, msg.MetricName, v)
 			}
 		}
-		if msg.CustomTags != nil {
-			for k, v := range msg.CustomTags {
-				labels[k] = fmt.Sprintf("%v", v)
-			}
+		for k, v := range msg.CustomTags {
+			labels[promw.labelName(k)] = promLabelValue(v)
 		}
 
-		labelKeys := make([]string, 0)
-		labelValues := make([]string, 0)
-		for k, v := range labels {
-			labelKeys = append(labelKeys, k)
-			labelValues = append(labelValues, v)
+		exemplarLabels := promw.exemplarLabels(msg.MetricDef.ExemplarColumns, dr)
+
+		labelKeys = slices.AppendSeq(labelKeys[:0], maps.Keys(labels))
+		slices.Sort(labelKeys)
+		labelPairs := make([]*dto.LabelPair, 0, len(labelKeys)) // shared by the samples of the row
+		for _, k := range labelKeys {
+			labelPairs = append(labelPairs, &dto.LabelPair{Name: proto.String(k), Value: proto.String(labels[k])})
 		}
 
 		for field, value := range fields {
+			attr := msg.MetricDef.ColumnAttrs[field]
+			value = attr.Scaled(value)
 			fieldPromDataType := prometheus.CounterValue
 			if msg.MetricName == promInstanceUpStateMetric ||
 				len(msg.MetricDef.Gauges) > 0 &&
 					(msg.MetricDef.Gauges[0] == "*" || slices.Contains(msg.MetricDef.Gauges, field)) {
 				fieldPromDataType = prometheus.GaugeValue
 			}
-			var desc *prometheus.Desc
-			if promw.PrometheusNamespace != "" {
-				if msg.MetricName == promInstanceUpStateMetric { // handle the special "instance_up" check
-					desc = prometheus.NewDesc(fmt.Sprintf("%s_%s", promw.PrometheusNamespace, msg.MetricName),
-						msg.MetricName, labelKeys, nil)
-				} else {
-					desc = prometheus.NewDesc(fmt.Sprintf("%s_%s_%s", promw.PrometheusNamespace, msg.MetricName, field),
-						msg.MetricName, labelKeys, nil)
-				}
-			} else {
-				if msg.MetricName == promInstanceUpStateMetric { // handle the special "instance_up" check
-					desc = prometheus.NewDesc(field, msg.MetricName, labelKeys, nil)
+			suffix := attr.UnitSuffix(field)
+			key = append(append(append(append(key[:0], msg.MetricName...), 0), field...), suffix...)
+			for _, k := range labelKeys {
+				key = append(append(key, 0), k...)
+			}
+			desc := promw.desc(key, msg.MetricName, field, suffix, labelKeys)
+			var m prometheus.Metric = &promSample{desc: desc, valueType: fieldPromDataType, value: value, labels: labelPairs, timestampMs: epochTime.UnixMilli()}
+			if fieldPromDataType == prometheus.CounterValue && len(exemplarLabels) > 0 {
+				if mx, err := prometheus.NewMetricWithExemplars(m, prometheus.Exemplar{Value: value, Labels: exemplarLabels, Timestamp: epochTime}); err == nil {
+					m = mx
 				} else {
-					desc = prometheus.NewDesc(fmt.Sprintf("%s_%s", msg.MetricName, field), msg.MetricName, labelKeys, nil)
+					logger.Debugf("Skipping exemplar of column %s of [%s:%s]: %v", field, msg.DBName, msg.MetricName, err)
 				}
 			}
-			m := prometheus.MustNewConstMetric(desc, fieldPromDataType, value, labelValues...)
-			promMetrics = append(promMetrics, prometheus.NewMetricWithTimestamp(epochTime, m))
+			promMetrics = append(promMetrics, m)
 		}
 	}
 	return promMetrics
 }
+
+// desc returns the cached descriptor of the series of the metric column with the label names, the key identifies them
+func (promw *PrometheusWriter) desc(key []byte, metric, field, unitSuffix string, labelKeys []string) *prometheus.Desc {
+	promw.descsLock.RLock()
+	desc, ok := promw.descs[string(key)]
+	promw.descsLock.RUnlock()
+	if ok {
+		return desc
+	}
+	name := field + unitSuffix
+	labelKeys = slices.Clone(labelKeys) // kept by the descriptor
+	if promw.PrometheusNamespace != "" {
+		if metric == promInstanceUpStateMetric { // handle the special "instance_up" check
+			desc = prometheus.NewDesc(promw.PrometheusNamespace+"_"+metric, metric, labelKeys, nil)
+		} else {
+			desc = prometheus.NewDesc(promw.metricName(promw.PrometheusNamespace+"_"+metric+"_"+name), metric, labelKeys, nil)
+		}
+	} else {
+		if metric == promInstanceUpStateMetric { // handle the special "instance_up" check
+			desc = prometheus.NewDesc(field, metric, labelKeys, nil)
+		} else {
+			desc = prometheus.NewDesc(promw.metricName(metric+"_"+name), metric, labelKeys, nil)
+		}
+	}
+	promw.descsLock.Lock()
+	defer promw.descsLock.Unlock()
+	if promw.descs == nil || len(promw.descs) >= promDescCacheSize {
+		promw.descs = make(map[string]*prometheus.Desc)
+	}
+	promw.descs[string(key)] = desc
+	return desc
+}
+
+// promSample is a timestamped sample of a series. Unlike the const metrics of client_golang, the label pairs
+// are built once per row and shared by its samples
+type promSample struct {
+	desc        *prometheus.Desc
+	valueType   prometheus.ValueType
+	value       float64
+	labels      []*dto.LabelPair // sorted by name as the variable labels of the descriptor
+	timestampMs int64
+}
+
+func (s *promSample) Desc() *prometheus.Desc {
+	return s.desc
+}
+
+func (s *promSample) Write(m *dto.Metric) error {
+	m.Label = s.labels
+	m.TimestampMs = &s.timestampMs
+	if s.valueType == prometheus.GaugeValue {
+		m.Gauge = &dto.Gauge{Value: &s.value}
+	} else {
+		m.Counter = &dto.Counter{Value: &s.value}
+	}
+	return nil
+}
+
+// promValue returns the column value as a Prometheus sample value, only numbers and booleans are supported
+func promValue(v any) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case float32: // the shortest decimal representation as before, e.g. 0.1 not 0.10000000149011612
+		f, err := strconv.ParseFloat(strconv.FormatFloat(float64(val), 'g', -1, 32), 64)
+		return f, err == nil
+	case int64:
+		return float64(val), true
+	case int32:
+		return float64(val), true
+	case int:
+		return float64(val), true
+	case bool:
+		if val {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// promString returns the column value as a label value
+func promString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+// exemplarLabels returns the exemplar labels of the measurement row, the "tag_" prefix of the columns is removed.
+// Nil is returned if exemplars are not enabled or the row has none of the columns
+func (promw *PrometheusWriter) exemplarLabels(columns []string, row map[string]any) prometheus.Labels {
+	if !promw.exemplars || len(columns) == 0 {
+		return nil
+	}
+	labels := make(prometheus.Labels, len(columns))
+	for _, col := range columns {
+		if v, ok := row[col]; ok && v != nil && v != "" {
+			labels[promLabelName(strings.TrimPrefix(col, "tag_"))] = promLabelValue(promString(v))
+		}
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	return labels
+}
+
+// metricName returns the Prometheus metric name of a column, distinct columns escaped to the same name are reported
+func (promw *PrometheusWriter) metricName(name string) string {
+	escaped := promName(name)
+	escapedNames.check(promw.ctx, "prometheus", "metric", name, escaped)
+	return escaped
+}
+
+// labelName returns the Prometheus label name of a tag, distinct tags escaped to the same name are reported
+func (promw *PrometheusWriter) labelName(name string) string {
+	escaped := promLabelName(name)
+	escapedNames.check(promw.ctx, "prometheus", "label", name, escaped)
+	return escaped
+}
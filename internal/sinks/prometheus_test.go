@@ -0,0 +1,126 @@
+package sinks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPrometheusWriter() *PrometheusWriter {
+	return &PrometheusWriter{
+		ctx:                 context.Background(),
+		PrometheusNamespace: "pgwatch",
+		lastScrapeErrors:    prometheus.NewGauge(prometheus.GaugeOpts{Name: "last_scrape_errors"}),
+		totalScrapes:        prometheus.NewCounter(prometheus.CounterOpts{Name: "total_scrapes"}),
+		totalScrapeFailures: prometheus.NewCounter(prometheus.CounterOpts{Name: "total_scrape_failures"}),
+	}
+}
+
+func TestPrometheusScrapeTimeout(t *testing.T) {
+	promw := newTestPrometheusWriter()
+	_ = promw.SyncMetric("db1", "cpu", "add")
+	defer func() { _ = promw.SyncMetric("db1", "", "remove") }()
+	assert.NoError(t, promw.Write([]metrics.MeasurementEnvelope{{
+		DBName:     "db1",
+		MetricName: "cpu",
+		Data:       metrics.Measurements{{epochColumnName: time.Now().UnixNano(), "load": 1.5}},
+	}}))
+
+	scrape := func(timeout string) string {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if timeout > "" {
+			req.Header.Set(promScrapeTimeoutHeader, timeout)
+		}
+		rr := httptest.NewRecorder()
+		promw.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		return rr.Body.String()
+	}
+
+	body := scrape("")
+	assert.Contains(t, body, "pgwatch_cpu_load")
+	assert.Contains(t, body, "pgwatch_scrape_incomplete 0")
+
+	body = scrape("10")
+	assert.Contains(t, body, "pgwatch_cpu_load", "enough time to collect everything")
+	assert.Contains(t, body, "pgwatch_scrape_incomplete 0")
+
+	body = scrape("0.000000001")
+	assert.NotContains(t, body, "pgwatch_cpu_load", "no time left to collect measurements")
+	assert.Contains(t, body, "pgwatch_scrape_incomplete 1")
+	assert.Contains(t, body, "total_scrapes", "self-telemetry is always returned")
+}
+
+func TestPrometheusExemplars(t *testing.T) {
+	promw := newTestPrometheusWriter()
+	_ = promw.SyncMetric("db1", "stat_statements", "add")
+	defer func() { _ = promw.SyncMetric("db1", "", "remove") }()
+	assert.NoError(t, promw.Write([]metrics.MeasurementEnvelope{{
+		DBName:     "db1",
+		MetricName: "stat_statements",
+		MetricDef:  metrics.Metric{Gauges: []string{"mean_time"}, MetricAttrs: metrics.MetricAttrs{ExemplarColumns: []string{"tag_queryid"}}},
+		Data:       metrics.Measurements{{epochColumnName: time.Now().UnixNano(), "tag_queryid": "-4211", "calls": int64(5), "mean_time": 1.5}},
+	}}))
+
+	scrape := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+		rr := httptest.NewRecorder()
+		promw.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		return rr.Body.String()
+	}
+
+	body := scrape()
+	assert.Contains(t, body, "pgwatch_stat_statements_calls")
+	assert.NotContains(t, body, "# {", "exemplars are disabled by default")
+
+	promw.exemplars = true
+	body = scrape()
+	assert.Contains(t, body, `# {queryid="-4211"} 5`)
+	assert.Regexp(t, `pgwatch_stat_statements_mean_time\{[^}]*\} 1.5 [0-9.e+]+\n`, body, "no exemplars for gauges")
+}
+
+func TestPrometheusColumnUnits(t *testing.T) {
+	promw := newTestPrometheusWriter()
+	_ = promw.SyncMetric("db1", "db_stats", "add")
+	defer func() { _ = promw.SyncMetric("db1", "", "remove") }()
+	assert.NoError(t, promw.Write([]metrics.MeasurementEnvelope{{
+		DBName:     "db1",
+		MetricName: "db_stats",
+		MetricDef: metrics.Metric{MetricAttrs: metrics.MetricAttrs{ColumnAttrs: metrics.ColumnAttrs{
+			"blks_read":     {Type: metrics.ColumnTypeInt, Unit: metrics.ColumnUnitBytes, Scale: 8192},
+			"blk_read_time": {Unit: metrics.ColumnUnitSeconds, Scale: 0.001},
+			"size_bytes":    {Unit: metrics.ColumnUnitBytes},
+			"xact_commit":   {Unit: metrics.ColumnUnitCount},
+		}}},
+		Data: metrics.Measurements{{epochColumnName: time.Now().UnixNano(), "blks_read": int64(2), "blk_read_time": 1500.0,
+			"size_bytes": int64(100), "xact_commit": int64(7)}},
+	}}))
+
+	rr := httptest.NewRecorder()
+	promw.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rr.Body.String()
+	assert.Contains(t, body, `pgwatch_db_stats_blks_read_bytes{dbname="db1"} 16384`, "scaled to the unit")
+	assert.Contains(t, body, `pgwatch_db_stats_blk_read_time_seconds{dbname="db1"} 1.5`)
+	assert.Contains(t, body, `pgwatch_db_stats_size_bytes{dbname="db1"} 100`, "suffix not repeated")
+	assert.Contains(t, body, `pgwatch_db_stats_xact_commit{dbname="db1"} 7`, "counts have no suffix")
+}
+
+func BenchmarkMetricStoreMessageToPromMetrics(b *testing.B) {
+	promw := newTestPrometheusWriter()
+	msg := benchMeasurements(1000)[0]
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if pm := promw.MetricStoreMessageToPromMetrics(msg); len(pm) == 0 {
+			b.Fatal("no metrics")
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package sinks
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// ProbeFunc fetches the metrics of a monitored source on demand, without storing the measurements
+type ProbeFunc func(ctx context.Context, dbUnique string, metricNames []string) ([]metrics.MeasurementEnvelope, error)
+
+// ErrProbeTargetNotFound is returned by a ProbeFunc if the target is not a monitored source
+var ErrProbeTargetNotFound = errors.New("probe target not found")
+
+// promProbeDefaultMetrics are probed if the metrics parameter is missing
+const promProbeDefaultMetrics = promInstanceUpStateMetric
+
+// SetProbe enables the /probe endpoint, fetching the metrics with fn
+func (promw *PrometheusWriter) SetProbe(fn ProbeFunc) {
+	promw.probe.Store(&fn)
+}
+
+// serveProbe serves /probe?target=<dbunique>&metrics=<metric1,metric2>, fetching the metrics of the target on demand,
+// so that a single source can be scraped like with the blackbox exporter, e.g. for multi-target setups or ad-hoc checks
+func (promw *PrometheusWriter) serveProbe(w http.ResponseWriter, r *http.Request) {
+	probe := promw.probe.Load()
+	if probe == nil {
+		http.Error(w, "probing is not available", http.StatusServiceUnavailable)
+		return
+	}
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+	var metricNames []string
+	for _, name := range strings.Split(cmp.Or(r.URL.Query().Get("metrics"), promProbeDefaultMetrics), ",") {
+		if name = strings.TrimSpace(name); name > "" {
+			metricNames = append(metricNames, name)
+		}
+	}
+
+	ctx := r.Context()
+	if timeout, err := strconv.ParseFloat(r.Header.Get(promScrapeTimeoutHeader), 64); err == nil && timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout*promScrapeTimeoutShare*float64(time.Second)))
+		defer cancel()
+	}
+	start := time.Now()
+	msgs, err := (*probe)(ctx, target, metricNames)
+	if errors.Is(err, ErrProbeTargetNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.GetLogger(promw.ctx).WithError(err).WithField("target", target).Warning("probe failed")
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(&promProbeCollector{promw: promw, msgs: msgs, success: err == nil, duration: time.Since(start)})
+	promhttp.HandlerFor(reg, promhttp.HandlerOpts{EnableOpenMetrics: promw.exemplars}).ServeHTTP(w, r)
+}
+
+// promProbeCollector collects the measurements of a single probe and whether all metrics were fetched
+type promProbeCollector struct {
+	promw    *PrometheusWriter
+	msgs     []metrics.MeasurementEnvelope
+	success  bool
+	duration time.Duration
+}
+
+func (c *promProbeCollector) Describe(_ chan<- *prometheus.Desc) {
+}
+
+func (c *promProbeCollector) Collect(ch chan<- prometheus.Metric) {
+	ns := c.promw.PrometheusNamespace
+	success := 0.0
+	if c.success {
+		success = 1
+	}
+	ch <- prometheus.MustNewConstMetric(prometheus.NewDesc(prometheus.BuildFQName(ns, "probe", "success"),
+		"Whether all metrics of the probe were fetched", nil, nil), prometheus.GaugeValue, success)
+	ch <- prometheus.MustNewConstMetric(prometheus.NewDesc(prometheus.BuildFQName(ns, "probe", "duration_seconds"),
+		"How long the probe took", nil, nil), prometheus.GaugeValue, c.duration.Seconds())
+	for _, msg := range c.msgs {
+		for _, pm := range c.promw.MetricStoreMessageToPromMetrics(msg) {
+			ch <- pm
+		}
+	}
+}
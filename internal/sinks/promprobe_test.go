@@ -0,0 +1,76 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheusProbe(t *testing.T) {
+	promw := newTestPrometheusWriter()
+	probe := func(query string) (int, string) {
+		rr := httptest.NewRecorder()
+		promw.serveProbe(rr, httptest.NewRequest(http.MethodGet, "/probe"+query, nil))
+		return rr.Code, rr.Body.String()
+	}
+
+	code, _ := probe("?target=db1")
+	assert.Equal(t, http.StatusServiceUnavailable, code, "probing not enabled")
+
+	var probed []string
+	promw.SetProbe(func(_ context.Context, dbUnique string, metricNames []string) ([]metrics.MeasurementEnvelope, error) {
+		if dbUnique != "db1" {
+			return nil, ErrProbeTargetNotFound
+		}
+		probed = metricNames
+		msgs := []metrics.MeasurementEnvelope{{
+			DBName:     dbUnique,
+			MetricName: "backends",
+			Data:       metrics.Measurements{{epochColumnName: time.Now().UnixNano(), "total": int64(7)}},
+		}}
+		if len(metricNames) > 2 {
+			return msgs, errors.New("relation does not exist")
+		}
+		return msgs, nil
+	})
+
+	code, _ = probe("")
+	assert.Equal(t, http.StatusBadRequest, code, "target is required")
+	code, _ = probe("?target=db2")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, body := probe("?target=db1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"instance_up"}, probed, "instance_up is probed by default")
+	assert.Contains(t, body, "pgwatch_probe_success 1")
+
+	code, body = probe("?target=db1&metrics=instance_up,%20backends,")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"instance_up", "backends"}, probed)
+	assert.Contains(t, body, `pgwatch_backends_total{dbname="db1"} 7`)
+	assert.Contains(t, body, "pgwatch_probe_duration_seconds")
+	assert.NotContains(t, body, "total_scrapes", "only the probed measurements are returned")
+
+	_, body = probe("?target=db1&metrics=instance_up,backends,broken")
+	assert.Contains(t, body, "pgwatch_probe_success 0", "partial results are flagged")
+	assert.Contains(t, body, "pgwatch_backends_total")
+}
+
+func TestMultiWriterSetProbe(t *testing.T) {
+	promw := newTestPrometheusWriter()
+	mw := &MultiWriter{}
+	mw.addWriter("prometheus", promw, &MetricNameRules{Remaps: map[string]string{"backends": "connections"}})
+	mw.AddWriter(&JSONWriter{})
+	mw.SetProbe(func(context.Context, string, []string) ([]metrics.MeasurementEnvelope, error) {
+		return []metrics.MeasurementEnvelope{{MetricName: "backends"}}, nil
+	})
+	msgs, err := (*promw.probe.Load())(context.Background(), "db1", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "connections", msgs[0].MetricName, "probed metrics are renamed like the stored ones")
+}
@@ -0,0 +1,149 @@
+package sinks
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// promSnapshotInterval is the period of saving the async cache to the snapshot file
+const promSnapshotInterval = time.Minute
+
+// promSnapshot is the on-disk format of the async cache
+type promSnapshot struct {
+	Saved time.Time                                           `json:"saved"`
+	Cache map[string]map[string][]metrics.MeasurementEnvelope `json:"cache"`
+}
+
+var promRestored = make(map[[2]string]bool) // [dbUnique, metric] served from the snapshot until fetched again, guarded by promAsyncMetricCacheLock
+
+var promSnapshotLock sync.Mutex // not to save the snapshot concurrently on shutdown
+
+// saveSnapshot writes the async cache to the file atomically
+func (promw *PrometheusWriter) saveSnapshot(fileName string) error {
+	promSnapshotLock.Lock()
+	defer promSnapshotLock.Unlock()
+	promAsyncMetricCacheLock.RLock()
+	b, err := json.Marshal(promSnapshot{Saved: time.Now(), Cache: promAsyncMetricCache})
+	promAsyncMetricCacheLock.RUnlock()
+	if err != nil {
+		return err
+	}
+	tmp := fileName + ".tmp"
+	if err = os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fileName)
+}
+
+// restoreNumbers converts the JSON numbers back to int64 or float64 as returned by the metric queries
+func restoreNumbers(data metrics.Measurements) {
+	for _, row := range data {
+		for k, v := range row {
+			if n, ok := v.(json.Number); ok {
+				if i, err := n.Int64(); err == nil {
+					row[k] = i
+				} else {
+					row[k], _ = n.Float64()
+				}
+			}
+		}
+	}
+}
+
+// loadSnapshot fills the async cache with the measurements saved before the restart, so that the scrapes
+// are not empty until all gatherers complete their first runs. Measurements too old to be scraped are skipped
+func (promw *PrometheusWriter) loadSnapshot(fileName string) (restored int, err error) {
+	b, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var snapshot promSnapshot
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err = dec.Decode(&snapshot); err != nil {
+		return 0, fmt.Errorf("invalid Prometheus cache snapshot %s: %w", fileName, err)
+	}
+	oldest := time.Now().Add(-promScrapingStalenessHardDropLimit)
+
+	promAsyncMetricCacheLock.Lock()
+	defer promAsyncMetricCacheLock.Unlock()
+	for dbUnique, metricsMessages := range snapshot.Cache {
+		for metric, msgs := range metricsMessages {
+			if len(msgs) == 0 || len(msgs[0].Data) == 0 {
+				continue
+			}
+			restoreNumbers(msgs[0].Data)
+			if epochNs, ok := msgs[0].Data[0][epochColumnName].(int64); !ok || time.Unix(0, epochNs).Before(oldest) {
+				continue
+			}
+			if _, ok := promAsyncMetricCache[dbUnique]; !ok {
+				promAsyncMetricCache[dbUnique] = make(map[string][]metrics.MeasurementEnvelope)
+			}
+			if _, ok := promAsyncMetricCache[dbUnique][metric]; ok {
+				continue // already fetched since the start
+			}
+			promAsyncMetricCache[dbUnique][metric] = msgs[:1]
+			promRestored[[2]string{dbUnique, metric}] = true
+			restored++
+		}
+	}
+	return restored, nil
+}
+
+// maintainSnapshot restores the async cache on startup and saves it periodically and on shutdown
+func (promw *PrometheusWriter) maintainSnapshot(fileName string) {
+	logger := log.GetLogger(promw.ctx).WithField("file", fileName)
+	if restored, err := promw.loadSnapshot(fileName); err != nil {
+		logger.WithError(err).Warning("could not restore Prometheus cache snapshot")
+	} else if restored > 0 {
+		logger.WithField("metrics", restored).Info("Prometheus cache restored from snapshot")
+	}
+	for {
+		select {
+		case <-promw.ctx.Done():
+			if err := promw.saveSnapshot(fileName); err != nil {
+				logger.WithError(err).Error("could not save Prometheus cache snapshot")
+			}
+			return
+		case <-time.After(promSnapshotInterval):
+			if err := promw.saveSnapshot(fileName); err != nil {
+				logger.WithError(err).Error("could not save Prometheus cache snapshot")
+			}
+		}
+	}
+}
+
+// Close saves the async cache snapshot, if enabled, before the shutdown
+func (promw *PrometheusWriter) Close() error {
+	if promw.cacheFile == "" {
+		return nil
+	}
+	return promw.saveSnapshot(promw.cacheFile)
+}
+
+// restoredMetric reports how many source metrics are still served from the snapshot taken before the restart
+func (promw *PrometheusWriter) restoredMetric() prometheus.Metric {
+	desc := prometheus.NewDesc(prometheus.BuildFQName(promw.PrometheusNamespace, "", "cache_restored_metrics"),
+		"Number of source metrics served from the cache snapshot taken before the restart, not fetched again yet", nil, nil)
+	promAsyncMetricCacheLock.RLock()
+	restored := 0
+	for key := range promRestored {
+		if _, ok := promAsyncMetricCache[key[0]][key[1]]; ok {
+			restored++
+		}
+	}
+	promAsyncMetricCacheLock.RUnlock()
+	return prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(restored))
+}
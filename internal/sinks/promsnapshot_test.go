@@ -0,0 +1,63 @@
+package sinks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrometheusCacheSnapshot(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "prom-cache.json")
+	promw := newTestPrometheusWriter()
+	promw.cacheFile = fileName
+	restored, err := promw.loadSnapshot(fileName)
+	assert.NoError(t, err, "missing snapshot is fine")
+	assert.Zero(t, restored)
+
+	_ = promw.SyncMetric("db1", "cpu", "add")
+	defer func() { _ = promw.SyncMetric("db1", "", "remove") }()
+	assert.NoError(t, promw.Write([]metrics.MeasurementEnvelope{{
+		DBName:     "db1",
+		MetricName: "cpu",
+		MetricDef:  metrics.Metric{Gauges: []string{"*"}},
+		Data:       metrics.Measurements{{epochColumnName: time.Now().UnixNano(), "load": 1.5, "cores": int64(8)}},
+	}}))
+	assert.NoError(t, promw.Write([]metrics.MeasurementEnvelope{{
+		DBName:     "db1",
+		MetricName: "wal",
+		Data:       metrics.Measurements{{epochColumnName: time.Now().Add(-time.Hour).UnixNano(), "xlog_location_b": int64(100)}},
+	}}))
+	require.NoError(t, promw.Close(), "saved on shutdown")
+
+	// simulate the restart
+	promw.PurgeMetricsFromPromAsyncCacheIfAny("db1", "")
+	restored, err = promw.loadSnapshot(fileName)
+	require.NoError(t, err)
+	assert.Equal(t, 1, restored, "measurements too old to be scraped are skipped")
+	cpu := promAsyncMetricCache["db1"]["cpu"][0]
+	assert.IsType(t, int64(0), cpu.Data[0]["cores"], "integers are restored as such")
+	assert.Equal(t, 1.5, cpu.Data[0]["load"])
+	assert.Equal(t, []string{"*"}, cpu.MetricDef.Gauges)
+
+	scrape := func() string {
+		rr := httptest.NewRecorder()
+		promw.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		return rr.Body.String()
+	}
+	body := scrape()
+	assert.Contains(t, body, `pgwatch_cpu_cores{dbname="db1"} 8`)
+	assert.Contains(t, body, "pgwatch_cache_restored_metrics 1")
+
+	assert.NoError(t, promw.Write([]metrics.MeasurementEnvelope{{
+		DBName:     "db1",
+		MetricName: "cpu",
+		Data:       metrics.Measurements{{epochColumnName: time.Now().UnixNano(), "load": 0.5}},
+	}}))
+	assert.Contains(t, scrape(), "pgwatch_cache_restored_metrics 0", "fetched again after the restart")
+}
@@ -0,0 +1,120 @@
+package sinks
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrSpoolFull is returned if a batch doesn't fit into the spool anymore
+var ErrSpoolFull = errors.New("spool is full")
+
+// spool keeps the batches a sink could not deliver in a file, in order, to be replayed once the target is
+// reachable again. The batches are length-prefixed, so the file is read one batch at a time on replay
+type spool struct {
+	path    string
+	maxSize int64
+	sync.Mutex
+}
+
+func newSpool(path string, maxSize int64) *spool {
+	return &spool{path: path, maxSize: maxSize}
+}
+
+// Size returns the size of the spooled batches in bytes
+func (s *spool) Size() int64 {
+	s.Lock()
+	defer s.Unlock()
+	return s.size()
+}
+
+func (s *spool) size() int64 {
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
+
+// Append adds the batch to the end of the spool
+func (s *spool) Append(batch []byte) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.maxSize > 0 && s.size()+int64(len(batch))+4 > s.maxSize {
+		return ErrSpoolFull
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(binary.BigEndian.AppendUint32(nil, uint32(len(batch))))
+	if err == nil {
+		_, err = f.Write(batch)
+	}
+	return errors.Join(err, f.Close())
+}
+
+// Replay sends the spooled batches in order. On the first failure the unsent batches are kept for the next replay
+func (s *spool) Replay(send func(batch []byte) error) error {
+	s.Lock()
+	defer s.Unlock()
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		var header [4]byte
+		if _, err = io.ReadFull(r, header[:]); err == io.EOF {
+			f.Close()
+			return os.Remove(s.path)
+		}
+		if err != nil {
+			return s.discard(f, err)
+		}
+		batch := make([]byte, binary.BigEndian.Uint32(header[:]))
+		if _, err = io.ReadFull(r, batch); err != nil {
+			return s.discard(f, err)
+		}
+		if err = send(batch); err != nil {
+			return errors.Join(err, s.truncate(f, offset))
+		}
+		offset += int64(len(header) + len(batch))
+	}
+}
+
+// truncate drops the first offset bytes of the spool, i.e. the already sent batches
+func (s *spool) truncate(f *os.File, offset int64) error {
+	if offset == 0 {
+		return nil
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), "spool")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, f)
+	if err = errors.Join(err, tmp.Close()); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// discard removes a corrupted spool, e.g. after a crash while appending, not to block the next batches
+func (s *spool) discard(f *os.File, cause error) error {
+	f.Close()
+	return errors.Join(fmt.Errorf("corrupted spool %s discarded: %w", s.path, cause), os.Remove(s.path))
+}
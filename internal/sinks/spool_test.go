@@ -0,0 +1,46 @@
+package sinks
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpool(t *testing.T) {
+	s := newSpool(filepath.Join(t.TempDir(), "test.spool"), 20)
+	assert.NoError(t, s.Replay(func([]byte) error { return errors.New("nothing to replay") }))
+	assert.Zero(t, s.Size())
+
+	require.NoError(t, s.Append([]byte("first")))
+	require.NoError(t, s.Append([]byte("second")))
+	assert.EqualValues(t, 19, s.Size())
+	assert.ErrorIs(t, s.Append([]byte("third")), ErrSpoolFull)
+
+	var sent []string
+	err := s.Replay(func(b []byte) error {
+		if string(b) == "second" {
+			return errors.New("unavailable")
+		}
+		sent = append(sent, string(b))
+		return nil
+	})
+	assert.EqualError(t, err, "unavailable")
+	assert.Equal(t, []string{"first"}, sent)
+	assert.EqualValues(t, 10, s.Size(), "sent batches are removed")
+
+	require.NoError(t, s.Replay(func(b []byte) error {
+		sent = append(sent, string(b))
+		return nil
+	}))
+	assert.Equal(t, []string{"first", "second"}, sent)
+	_, err = os.Stat(s.path)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	require.NoError(t, os.WriteFile(s.path, []byte{0, 0, 0, 9, 'x'}, 0600))
+	assert.ErrorContains(t, s.Replay(func([]byte) error { return nil }), "corrupted spool")
+	assert.Zero(t, s.Size(), "corrupted spool is discarded")
+}
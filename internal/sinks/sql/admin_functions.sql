@@ -5,10 +5,10 @@ CREATE OR REPLACE FUNCTION admin.get_top_level_metric_tables(
 )
 RETURNS SETOF text AS
 $SQL$
-  select nspname||'.'||quote_ident(c.relname) as tbl
+  select quote_ident(nspname)||'.'||quote_ident(c.relname) as tbl
   from pg_class c 
   join pg_namespace n on n.oid = c.relnamespace
-  where relkind in ('r', 'p') and nspname = 'public'
+  where relkind in ('r', 'p') and nspname <> 'subpartitions'
   and exists (select 1 from pg_attribute where attrelid = c.oid and attname = 'time')
   and pg_catalog.obj_description(c.oid, 'pg_class') = 'pgwatch-generated-metric-lvl'
   order by 1
@@ -188,7 +188,7 @@ BEGIN
                                join pg_catalog.pg_class cl on cl.relname = c.table_name
                                join pg_catalog.pg_namespace n on n.nspname = c.schema_name
                                join pg_catalog.pg_constraint co on co.conrelid = cl.oid
-                      where h.schema_name = 'public'
+                      where pg_catalog.obj_description(format('%I.%I', h.schema_name, h.table_name)::regclass, 'pg_class') = 'pgwatch-generated-metric-lvl'
             ) x where is_old)
             LOOP
                     raise notice 'would drop timescale old time sub-partition: %', r.chunk;
@@ -197,11 +197,11 @@ BEGIN
         else /* loop over all to level hypertables */
             FOR r IN (
                 select
-                  h.table_name::text as metric
+                  format('%I.%I', h.schema_name, h.table_name) as metric
                 from
                   _timescaledb_catalog.hypertable h
                 where
-                  h.schema_name = 'public'
+                  pg_catalog.obj_description(format('%I.%I', h.schema_name, h.table_name)::regclass, 'pg_class') = 'pgwatch-generated-metric-lvl'
             )
             LOOP
                 --raise notice 'dropping old timescale sub-partitions for hypertable: %', r.metric;
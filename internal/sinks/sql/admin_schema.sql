@@ -1,6 +1,6 @@
 /*
  "admin" schema - stores schema type, partition templates and data cleanup functions
- "public" schema - top level metric tables
+ "public" schema - top level metric tables, metrics with the "storage_schema" attribute are stored in that schema instead
  "subpartitions" schema - subpartitions of "public" schema top level metric tables (if using time / dbname-time partitioning)
 */
 
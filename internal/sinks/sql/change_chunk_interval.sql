@@ -19,9 +19,9 @@ BEGIN
   ON CONFLICT (key) DO UPDATE
     SET value = new_interval::text;
 
-  FOR r IN (SELECT quote_ident(table_name) as metric
+  FOR r IN (SELECT format('%I.%I', schema_name, table_name) as metric
                    FROM _timescaledb_catalog.hypertable
-                  WHERE schema_name = 'public')
+                  WHERE pg_catalog.obj_description(format('%I.%I', schema_name, table_name)::regclass, 'pg_class') = 'pgwatch-generated-metric-lvl')
   LOOP
     -- RAISE NOTICE 'setting % to %s ...', r.metric, new_interval;
     PERFORM set_chunk_time_interval(r.metric, new_interval);
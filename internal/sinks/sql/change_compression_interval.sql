@@ -20,20 +20,20 @@ BEGIN
   ON CONFLICT (key) DO UPDATE
     SET value = new_interval::text;
 
-  FOR r IN (SELECT quote_ident(table_name) as metric
+  FOR r IN (SELECT format('%I.%I', schema_name, table_name) as metric
                    FROM _timescaledb_catalog.hypertable
-                  WHERE schema_name = 'public')
+                  WHERE pg_catalog.obj_description(format('%I.%I', schema_name, table_name)::regclass, 'pg_class') = 'pgwatch-generated-metric-lvl')
   LOOP
     -- RAISE NOTICE 'setting % to %s ...', r.metric, new_interval;
     PERFORM set_chunk_time_interval(r.metric, new_interval);
 
     SELECT ((regexp_matches(extversion, '\d+\.\d+'))[1])::numeric INTO l_timescale_version FROM pg_extension WHERE extname = 'timescaledb';
     IF l_timescale_version >= 2.0 THEN
-        PERFORM remove_compression_policy(format('public.%I', r.metric), true);
-        PERFORM add_compression_policy(format('public.%I', r.metric), new_interval);
+        PERFORM remove_compression_policy(r.metric, true);
+        PERFORM add_compression_policy(r.metric, new_interval);
     ELSE
-        PERFORM remove_compress_chunks_policy(format('public.%I', r.metric));
-        PERFORM add_compress_chunks_policy(format('public.%I', r.metric), new_interval);
+        PERFORM remove_compress_chunks_policy(r.metric);
+        PERFORM add_compress_chunks_policy(r.metric, new_interval);
     END IF;
   END LOOP;
 
@@ -0,0 +1,25 @@
+/* collector starts, shutdowns and configuration reloads, to correlate data gaps with restarts */
+create table if not exists admin.collector_runs (
+  id int8 generated always as identity primary key,
+  collector text not null,
+  version text not null,
+  config_hash text not null,
+  started_on timestamptz not null default now(),
+  last_seen timestamptz not null default now(),
+  stopped_on timestamptz,
+  shutdown text not null default 'running' check (shutdown in ('running', 'clean', 'unclean'))
+);
+
+create index if not exists collector_runs_collector_started_on_idx on admin.collector_runs (collector, started_on);
+
+comment on table admin.collector_runs is 'lifecycle of the collectors, runs not stopped cleanly are marked on the next start';
+
+create table if not exists admin.collector_config_reloads (
+  run_id int8 not null references admin.collector_runs (id) on delete cascade,
+  reloaded_on timestamptz not null default now(),
+  config_hash text not null
+);
+
+create index if not exists collector_config_reloads_run_id_idx on admin.collector_config_reloads (run_id);
+
+comment on table admin.collector_config_reloads is 'changes of the sources and metrics configuration applied by the collectors';
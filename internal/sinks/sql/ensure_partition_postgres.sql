@@ -1,16 +1,22 @@
--- DROP FUNCTION admin.ensure_partition_metric_dbname_time(text,text,timestamp with time zone,integer);
--- select * from admin.ensure_partition_metric_dbname_time('wal', 'kala', now());
+-- DROP FUNCTION admin.ensure_schema_partition_metric_dbname_time(text,text,text,timestamp with time zone,integer,text,text,boolean);
+-- select * from admin.ensure_schema_partition_metric_dbname_time('bulk', 'stat_statements', 'kala', now(), 0, 'cheap', 'fillfactor=100');
 
-CREATE OR REPLACE FUNCTION admin.ensure_partition_metric_dbname_time(
+CREATE OR REPLACE FUNCTION admin.ensure_schema_partition_metric_dbname_time(
+    metric_schema text,
     metric text,
     dbname text,
     metric_timestamp timestamptz,
     partitions_to_precreate int default 0,
+    metric_tablespace text default '',
+    metric_storage_params text default '',
+    metric_unlogged boolean default false,
     OUT part_available_from timestamptz,
     OUT part_available_to timestamptz)
 RETURNS record AS
 /*
-  creates a top level metric table, a dbname partition and a time partition if not already existing.
+  creates a top level metric table in the given schema, a dbname partition and a time partition if not already existing.
+  sub-partitions of non-public top level tables are prefixed with the schema name.
+  all levels are created in the given tablespace, the storage parameters e.g. 'fillfactor=100' apply to the time partitions.
   returns time partition start/end date
 */
 $SQL$
@@ -24,8 +30,11 @@ DECLARE
   l_part_end date;
   l_sql text;
   ideal_length int;
-  l_unlogged text := '';
+  l_unlogged text := CASE WHEN metric_unlogged THEN 'UNLOGGED' ELSE '' END;
+  l_tablespace text := CASE WHEN metric_tablespace > '' THEN format('TABLESPACE %I', metric_tablespace) ELSE '' END;
+  l_with text := CASE WHEN metric_storage_params > '' THEN format('WITH (%s)', metric_storage_params) ELSE '' END;
   l_template_table text := 'admin.metrics_template';
+  l_part_prefix text := CASE WHEN metric_schema = 'public' THEN metric ELSE metric_schema || '_' || metric END;
   MAX_IDENT_LEN CONSTANT integer := current_setting('max_identifier_length')::int;
 BEGIN
 
@@ -37,25 +46,30 @@ BEGIN
   END IF;
 
   -- 1. level
+  IF to_regnamespace(metric_schema) IS NULL
+  THEN
+    EXECUTE format($$CREATE SCHEMA IF NOT EXISTS %I$$, metric_schema);
+  END IF;
+
   IF NOT EXISTS (SELECT 1
                    FROM pg_tables
                   WHERE tablename = metric
-                    AND schemaname = 'public')
+                    AND schemaname = metric_schema)
   THEN
     -- RAISE NOTICE 'creating partition % ...', metric;
-    EXECUTE format($$CREATE %s TABLE IF NOT EXISTS public.%s (LIKE %s INCLUDING INDEXES) PARTITION BY LIST (dbname)$$,
-                   l_unlogged, quote_ident(metric), l_template_table);
-    EXECUTE format($$COMMENT ON TABLE public.%s IS 'pgwatch-generated-metric-lvl'$$, quote_ident(metric));
+    EXECUTE format($$CREATE %s TABLE IF NOT EXISTS %I.%I (LIKE %s INCLUDING INDEXES) PARTITION BY LIST (dbname) %s$$,
+                   l_unlogged, metric_schema, metric, l_template_table, l_tablespace);
+    EXECUTE format($$COMMENT ON TABLE %I.%I IS 'pgwatch-generated-metric-lvl'$$, metric_schema, metric);
   END IF;
 
   -- 2. level
 
-  l_part_name_2nd := metric || '_' || dbname;
+  l_part_name_2nd := l_part_prefix || '_' || dbname;
 
-  IF char_length(l_part_name_2nd) > MAX_IDENT_LEN     -- use "dbname" hash instead of name for overly long ones
+  IF octet_length(l_part_name_2nd) > MAX_IDENT_LEN     -- use "dbname" hash instead of name for overly long ones
   THEN
-    ideal_length = MAX_IDENT_LEN - char_length(format('%s_', metric));
-    l_part_name_2nd := metric || '_' || substring(md5(dbname) from 1 for ideal_length);
+    ideal_length = MAX_IDENT_LEN - octet_length(format('%s_', l_part_prefix));
+    l_part_name_2nd := l_part_prefix || '_' || substring(md5(dbname) from 1 for ideal_length);
   END IF;
 
   IF NOT EXISTS (SELECT 1
@@ -64,15 +78,15 @@ BEGIN
                     AND schemaname = 'subpartitions')
   THEN
     --RAISE NOTICE 'creating partition % ...', l_part_name_2nd; 
-    EXECUTE format($$CREATE %s TABLE IF NOT EXISTS subpartitions.%s PARTITION OF public.%s FOR VALUES IN (%s) PARTITION BY RANGE (time)$$,
-                    l_unlogged, quote_ident(l_part_name_2nd), quote_ident(metric), quote_literal(dbname));
+    EXECUTE format($$CREATE %s TABLE IF NOT EXISTS subpartitions.%s PARTITION OF %I.%I FOR VALUES IN (%s) PARTITION BY RANGE (time) %s$$,
+                    l_unlogged, quote_ident(l_part_name_2nd), metric_schema, metric, quote_literal(dbname), l_tablespace);
     EXECUTE format($$COMMENT ON TABLE subpartitions.%s IS 'pgwatch-generated-metric-dbname-lvl'$$, quote_ident(l_part_name_2nd));
   END IF;
 
   -- 3. level
   FOR i IN 0..partitions_to_precreate LOOP
 
-  IF l_unlogged > '' THEN   /* realtime / unlogged metrics have always 1d partitions */
+  IF metric ~ 'realtime' THEN   /* realtime metrics have always 1d partitions */
       l_year := extract(year from (metric_timestamp + '1day'::interval * i));
       l_doy := extract(doy from (metric_timestamp + '1day'::interval * i));
 
@@ -87,12 +101,12 @@ BEGIN
           part_available_to := l_part_end;
       END IF;
 
-      l_part_name_3rd := format('%s_%s_y%sd%s', metric, dbname, l_year, to_char(l_doy, 'fm000' ));
+      l_part_name_3rd := format('%s_%s_y%sd%s', l_part_prefix, dbname, l_year, to_char(l_doy, 'fm000' ));
 
-      IF char_length(l_part_name_3rd) > MAX_IDENT_LEN     -- use "dbname" hash instead of name for overly long ones
+      IF octet_length(l_part_name_3rd) > MAX_IDENT_LEN     -- use "dbname" hash instead of name for overly long ones
       THEN
-          ideal_length = MAX_IDENT_LEN - char_length(format('%s__y%sd%s', metric, l_year, to_char(l_doy, 'fm000')));
-          l_part_name_3rd := format('%s_%s_y%sd%s', metric, substring(md5(dbname) from 1 for ideal_length), l_year, to_char(l_doy, 'fm000' ));
+          ideal_length = MAX_IDENT_LEN - octet_length(format('%s__y%sd%s', l_part_prefix, l_year, to_char(l_doy, 'fm000')));
+          l_part_name_3rd := format('%s_%s_y%sd%s', l_part_prefix, substring(md5(dbname) from 1 for ideal_length), l_year, to_char(l_doy, 'fm000' ));
       END IF;
   ELSE
       l_year := extract(isoyear from (metric_timestamp + '1month'::interval * i));
@@ -109,12 +123,12 @@ BEGIN
           part_available_to := l_part_end;
       END IF;
 
-      l_part_name_3rd := format('%s_%s_y%sw%s', metric, dbname, l_year, to_char(l_week, 'fm00' ));
+      l_part_name_3rd := format('%s_%s_y%sw%s', l_part_prefix, dbname, l_year, to_char(l_week, 'fm00' ));
 
-      IF char_length(l_part_name_3rd) > MAX_IDENT_LEN     -- use "dbname" hash instead of name for overly long ones
+      IF octet_length(l_part_name_3rd) > MAX_IDENT_LEN     -- use "dbname" hash instead of name for overly long ones
       THEN
-          ideal_length = MAX_IDENT_LEN - char_length(format('%s__y%sw%s', metric, l_year, to_char(l_week, 'fm00')));
-          l_part_name_3rd := format('%s_%s_y%sw%s', metric, substring(md5(dbname) from 1 for ideal_length), l_year, to_char(l_week, 'fm00' ));
+          ideal_length = MAX_IDENT_LEN - octet_length(format('%s__y%sw%s', l_part_prefix, l_year, to_char(l_week, 'fm00')));
+          l_part_name_3rd := format('%s_%s_y%sw%s', l_part_prefix, substring(md5(dbname) from 1 for ideal_length), l_year, to_char(l_week, 'fm00' ));
       END IF;
   END IF;
 
@@ -125,8 +139,8 @@ BEGIN
                     AND schemaname = 'subpartitions')
   THEN
     --RAISE NOTICE 'creating time sub-partition % ...', l_part_name_3rd;
-    l_sql := format($$CREATE %s TABLE IF NOT EXISTS subpartitions.%s PARTITION OF subpartitions.%s FOR VALUES FROM ('%s') TO ('%s')$$,
-                    l_unlogged, quote_ident(l_part_name_3rd), quote_ident(l_part_name_2nd), l_part_start, l_part_end);
+    l_sql := format($$CREATE %s TABLE IF NOT EXISTS subpartitions.%s PARTITION OF subpartitions.%s FOR VALUES FROM ('%s') TO ('%s') %s %s$$,
+                    l_unlogged, quote_ident(l_part_name_3rd), quote_ident(l_part_name_2nd), l_part_start, l_part_end, l_with, l_tablespace);
     EXECUTE l_sql;
     EXECUTE format($$COMMENT ON TABLE subpartitions.%s IS 'pgwatch-generated-metric-dbname-time-lvl'$$, quote_ident(l_part_name_3rd));
   END IF;
@@ -137,4 +151,25 @@ BEGIN
 END;
 $SQL$ LANGUAGE plpgsql;
 
+-- GRANT EXECUTE ON FUNCTION admin.ensure_schema_partition_metric_dbname_time(text,text,text,timestamp with time zone,integer,text,text,boolean) TO pgwatch;
+
+-- DROP FUNCTION admin.ensure_partition_metric_dbname_time(text,text,timestamp with time zone,integer);
+-- select * from admin.ensure_partition_metric_dbname_time('wal', 'kala', now());
+
+CREATE OR REPLACE FUNCTION admin.ensure_partition_metric_dbname_time(
+    metric text,
+    dbname text,
+    metric_timestamp timestamptz,
+    partitions_to_precreate int default 0,
+    OUT part_available_from timestamptz,
+    OUT part_available_to timestamptz)
+RETURNS record AS
+/*
+  creates a top level metric table in the public schema, a dbname partition and a time partition if not already existing.
+  returns time partition start/end date
+*/
+$SQL$
+  SELECT * FROM admin.ensure_schema_partition_metric_dbname_time('public', metric, dbname, metric_timestamp, partitions_to_precreate, '', '', false);
+$SQL$ LANGUAGE sql;
+
 -- GRANT EXECUTE ON FUNCTION admin.ensure_partition_metric_dbname_time(text,text,timestamp with time zone,integer) TO pgwatch;
@@ -1,19 +1,24 @@
--- DROP FUNCTION IF EXISTS public.ensure_partition_timescale(text);
--- select * from public.ensure_partition_timescale('wal');
+-- DROP FUNCTION IF EXISTS admin.ensure_schema_partition_timescale(text, text, text, text, boolean);
+-- select * from admin.ensure_schema_partition_timescale('bulk', 'stat_statements', 'cheap', 'fillfactor=100');
 
-CREATE OR REPLACE FUNCTION admin.ensure_partition_timescale(
-    metric text
+CREATE OR REPLACE FUNCTION admin.ensure_schema_partition_timescale(
+    metric_schema text,
+    metric text,
+    metric_tablespace text default '',
+    metric_storage_params text default '',
+    metric_unlogged boolean default false
 )
 RETURNS void AS
 /*
-  creates a top level metric table if not already existing.
+  creates a top level metric table in the given schema if not already existing.
+  the chunks are created in the given tablespace with the given storage parameters, e.g. 'fillfactor=100'.
   expects the "metrics_template" table to exist.
 */
 $SQL$
 DECLARE
     l_template_table text := 'admin.metrics_template';
     l_compression_policy text := $$
-      ALTER TABLE public.%I SET (
+      ALTER TABLE %I.%I SET (
         timescaledb.compress,
         timescaledb.compress_segmentby = 'dbname'
       );
@@ -21,6 +26,7 @@ DECLARE
     l_chunk_time_interval interval;
     l_compress_chunk_interval interval;
     l_timescale_version numeric;
+    l_with text := CASE WHEN metric_storage_params > '' THEN format('WITH (%s)', metric_storage_params) ELSE '' END;
 BEGIN
     --RAISE NOTICE 'creating partition % ...', metric;
 
@@ -29,7 +35,7 @@ BEGIN
     IF NOT EXISTS (SELECT *
                        FROM _timescaledb_catalog.hypertable
                       WHERE table_name = metric
-                        AND schema_name = 'public')
+                        AND schema_name = metric_schema)
       THEN
         SELECT value::interval INTO l_chunk_time_interval FROM admin.config WHERE key = 'timescale_chunk_interval';
         IF NOT FOUND THEN
@@ -41,21 +47,48 @@ BEGIN
             l_compress_chunk_interval := '1 day';
         END IF;
 
-        EXECUTE format($$CREATE TABLE IF NOT EXISTS public.%I (LIKE %s INCLUDING INDEXES)$$, metric, l_template_table);
-        EXECUTE format($$COMMENT ON TABLE public.%I IS 'pgwatch-generated-metric-lvl'$$, metric);
-        PERFORM create_hypertable(format('public.%I', metric), 'time', chunk_time_interval => l_chunk_time_interval);
-        EXECUTE format(l_compression_policy, metric);
+        IF to_regnamespace(metric_schema) IS NULL THEN
+            EXECUTE format($$CREATE SCHEMA IF NOT EXISTS %I$$, metric_schema);
+        END IF;
+
+        IF metric_unlogged THEN
+            RAISE WARNING 'unlogged hypertables are not supported, creating % as logged', metric;
+        END IF;
+
+        EXECUTE format($$CREATE TABLE IF NOT EXISTS %I.%I (LIKE %s INCLUDING INDEXES) %s$$, metric_schema, metric, l_template_table, l_with);
+        EXECUTE format($$COMMENT ON TABLE %I.%I IS 'pgwatch-generated-metric-lvl'$$, metric_schema, metric);
+        PERFORM create_hypertable(format('%I.%I', metric_schema, metric), 'time', chunk_time_interval => l_chunk_time_interval);
+        IF metric_tablespace > '' THEN
+            PERFORM attach_tablespace(metric_tablespace, format('%I.%I', metric_schema, metric));
+        END IF;
+        EXECUTE format(l_compression_policy, metric_schema, metric);
         SELECT ((regexp_matches(extversion, '\d+\.\d+'))[1])::numeric INTO l_timescale_version FROM pg_extension WHERE extname = 'timescaledb';
         IF l_timescale_version >= 2.0 THEN
-          PERFORM add_compression_policy(format('public.%I', metric), l_compress_chunk_interval);
+          PERFORM add_compression_policy(format('%I.%I', metric_schema, metric), l_compress_chunk_interval);
         ELSE
-          PERFORM add_compress_chunks_policy(format('public.%I', metric), l_compress_chunk_interval);
+          PERFORM add_compress_chunks_policy(format('%I.%I', metric_schema, metric), l_compress_chunk_interval);
         END IF;
     END IF;
 
 END;
 $SQL$ LANGUAGE plpgsql;
 
+-- GRANT EXECUTE ON FUNCTION admin.ensure_schema_partition_timescale(text, text, text, text, boolean) TO pgwatch;
+
+-- DROP FUNCTION IF EXISTS admin.ensure_partition_timescale(text);
+-- select * from admin.ensure_partition_timescale('wal');
+
+CREATE OR REPLACE FUNCTION admin.ensure_partition_timescale(
+    metric text
+)
+RETURNS void AS
+/*
+  creates a top level metric table in the public schema if not already existing.
+*/
+$SQL$
+  SELECT admin.ensure_schema_partition_timescale('public', metric, '', '', false);
+$SQL$ LANGUAGE sql;
+
 -- GRANT EXECUTE ON FUNCTION admin.ensure_partition_timescale(text) TO pgwatch;
 
 CREATE OR REPLACE FUNCTION admin.ensure_partition_metric_time(
@@ -0,0 +1,12 @@
+/* declared output columns of the metrics with their units, to generate dashboards and alert rules with correct units */
+create table if not exists admin.metric_columns (
+  metric text not null,
+  column_name text not null,
+  data_type text,
+  unit text,
+  scale float8 not null default 1,
+  updated_on timestamptz not null default now(),
+  primary key (metric, column_name)
+);
+
+comment on table admin.metric_columns is 'column_attrs of the metrics, the stored values multiplied by scale are in unit';
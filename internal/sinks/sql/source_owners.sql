@@ -0,0 +1,8 @@
+/* sources ownership per collector, maintained only if the --duplicate-guard option is enabled */
+create table if not exists admin.source_owners (
+  dbname text not null primary key,
+  collector text not null,
+  last_seen timestamptz not null default now()
+);
+
+comment on table admin.source_owners is 'identifies the collector writing measurements for every source';
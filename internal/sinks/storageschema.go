@@ -0,0 +1,90 @@
+package sinks
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+const defaultStorageSchema = "public"
+
+// storageTable returns the name of the metric table qualified with the storage schema of the metric
+// if it's not the default one. Realtime metrics are always stored in the default schema
+func storageTable(msg metrics.MeasurementEnvelope) string {
+	schema := msg.MetricDef.StorageSchema
+	if schema == "" || schema == defaultStorageSchema || strings.HasSuffix(msg.MetricName, "_realtime") {
+		return msg.MetricName
+	}
+	return schema + "." + msg.MetricName
+}
+
+// splitStorageTable returns the schema and the metric of the table name returned by storageTable
+func splitStorageTable(table string) (schema, metric string) {
+	if schema, metric, ok := strings.Cut(table, "."); ok {
+		return schema, metric
+	}
+	return defaultStorageSchema, table
+}
+
+var storageParamValue = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+
+// storageParams returns the storage parameters of the time partitions, e.g. "fillfactor=100, autovacuum_enabled=off"
+func storageParams(opts metrics.StorageOptions) (string, error) {
+	params := make([]string, 0, len(opts.Autovacuum)+1)
+	if opts.Fillfactor > 0 {
+		params = append(params, "fillfactor="+strconv.Itoa(opts.Fillfactor))
+	}
+	for _, name := range slices.Sorted(maps.Keys(opts.Autovacuum)) {
+		value := fmt.Sprint(opts.Autovacuum[name])
+		if !storageParamValue.MatchString(name) || !storageParamValue.MatchString(value) {
+			return "", fmt.Errorf("invalid autovacuum storage parameter %s: %s", name, value)
+		}
+		params = append(params, "autovacuum_"+name+"="+value)
+	}
+	return strings.Join(params, ", "), nil
+}
+
+// partitionQuery returns the partition maintenance query and its arguments for the metric table. Tables in the
+// default schema without storage options use the original functions, so that sinks created by older versions keep working
+func (pgw *PostgresWriter) partitionQuery(table, sql, schemaSQL string, args ...any) (string, []any) {
+	schema, metric := splitStorageTable(table)
+	args = append([]any{metric}, args...)
+	opts := pgw.storageOpts[table]
+	if schema == defaultStorageSchema && opts.IsEmpty() {
+		return sql, args
+	}
+	params, err := storageParams(opts)
+	if err != nil {
+		log.GetLogger(pgw.ctx).WithField("metric", metric).WithError(err).Warning("ignoring the storage parameters")
+	}
+	return schemaSQL, append(args, schema, opts.Tablespace, params, opts.Unlogged)
+}
+
+// ensureStorageSchemaFunctions installs the storage schema and options aware partitioning functions into sinks created by
+// older versions. Without the needed privileges only the metrics in the default schema without storage options can be written
+func (pgw *PostgresWriter) ensureStorageSchemaFunctions() error {
+	var exists bool
+	sql := `SELECT to_regprocedure('admin.ensure_schema_partition_metric_dbname_time(text,text,text,timestamptz,integer,text,text,boolean)') IS NOT NULL`
+	if err := pgw.sinkDb.QueryRow(pgw.ctx, sql).Scan(&exists); err != nil || exists {
+		return err
+	}
+	for _, sql := range []string{
+		sqlMetricAdminFunctions,
+		sqlMetricEnsurePartitionPostgres,
+		sqlMetricEnsurePartitionTimescale,
+		sqlMetricChangeChunkIntervalTimescale,
+		sqlMetricChangeCompressionIntervalTimescale,
+	} {
+		if _, err := pgw.sinkDb.Exec(pgw.ctx, sql); err != nil {
+			log.GetLogger(pgw.ctx).WithError(err).Warning("could not upgrade the partitioning functions, the storage_schema and storage metric attributes are not supported")
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package sinks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageTable(t *testing.T) {
+	bulk := metrics.Metric{MetricAttrs: metrics.MetricAttrs{StorageSchema: "bulk"}}
+	assert.Equal(t, "db_stats", storageTable(metrics.MeasurementEnvelope{MetricName: "db_stats"}))
+	assert.Equal(t, "bulk.stat_statements", storageTable(metrics.MeasurementEnvelope{MetricName: "stat_statements", MetricDef: bulk}))
+	assert.Equal(t, "stat_activity_realtime", storageTable(metrics.MeasurementEnvelope{MetricName: "stat_activity_realtime", MetricDef: bulk}))
+
+	schema, metric := splitStorageTable("bulk.stat_statements")
+	assert.Equal(t, "bulk", schema)
+	assert.Equal(t, "stat_statements", metric)
+	schema, metric = splitStorageTable("db_stats")
+	assert.Equal(t, defaultStorageSchema, schema)
+	assert.Equal(t, "db_stats", metric)
+}
+
+func TestStorageParams(t *testing.T) {
+	params, err := storageParams(metrics.StorageOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, params)
+
+	params, err = storageParams(metrics.StorageOptions{Fillfactor: 90, Autovacuum: map[string]any{
+		"vacuum_scale_factor":  0.01,
+		"analyze_scale_factor": 0.05,
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, "fillfactor=90, autovacuum_analyze_scale_factor=0.05, autovacuum_vacuum_scale_factor=0.01", params)
+
+	_, err = storageParams(metrics.StorageOptions{Autovacuum: map[string]any{"enabled": "off); drop table x; --"}})
+	assert.Error(t, err)
+}
+
+func TestEnsureMetricDbnameTimeStorageSchema(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	conn.MatchExpectationsInOrder(false) // map iteration order
+	pgw := PostgresWriter{ctx: ctx, sinkDb: conn}
+	delete(partitionMapMetricDbname, "db_stats")
+	delete(partitionMapMetricDbname, "bulk.stat_statements")
+	delete(partitionMapMetricDbname, "wal")
+	now := time.Now()
+	bounds := ExistingPartitionInfo{now, now}
+	partRows := func() *pgxmock.Rows {
+		return pgxmock.NewRows([]string{"part_available_from", "part_available_to"}).AddRow(now.Add(-time.Hour), now.Add(time.Hour))
+	}
+
+	conn.ExpectQuery(`admin\.ensure_partition_metric_dbname_time`).WithArgs("db_stats", "db1", now).WillReturnRows(partRows())
+	conn.ExpectQuery(`admin\.ensure_schema_partition_metric_dbname_time\(\$4, \$1, \$2, \$3, 0, \$5, \$6, \$7\)`).
+		WithArgs("stat_statements", "db1", now, "bulk", "", "", false).WillReturnRows(partRows())
+	conn.ExpectQuery(`admin\.ensure_schema_partition_metric_dbname_time`).
+		WithArgs("wal", "db1", now, "public", "cheap", "fillfactor=100, autovacuum_enabled=off", true).WillReturnRows(partRows())
+	pgw.storageOpts = map[string]metrics.StorageOptions{
+		"wal": {Tablespace: "cheap", Fillfactor: 100, Autovacuum: map[string]any{"enabled": "off"}, Unlogged: true},
+	}
+	err = pgw.EnsureMetricDbnameTime(map[string]map[string]ExistingPartitionInfo{
+		"db_stats":             {"db1": bounds},
+		"bulk.stat_statements": {"db1": bounds},
+		"wal":                  {"db1": bounds},
+	}, false)
+	assert.NoError(t, err)
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
+
+func TestEnsureStorageSchemaFunctions(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	pgw := PostgresWriter{ctx: ctx, sinkDb: conn}
+
+	conn.ExpectQuery("SELECT to_regproc").WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+	assert.NoError(t, pgw.ensureStorageSchemaFunctions())
+
+	conn.ExpectQuery("SELECT to_regproc").WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+	for range 5 {
+		conn.ExpectExec("CREATE OR REPLACE FUNCTION").WillReturnResult(pgxmock.NewResult("CREATE", 0))
+	}
+	assert.NoError(t, pgw.ensureStorageSchemaFunctions(), "sinks created by older versions are upgraded")
+
+	conn.ExpectQuery("SELECT to_regproc").WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+	conn.ExpectExec("CREATE OR REPLACE FUNCTION").WillReturnError(assert.AnError)
+	assert.NoError(t, pgw.ensureStorageSchemaFunctions(), "missing privileges only disable the storage schemas")
+	assert.NoError(t, conn.ExpectationsWereMet())
+}
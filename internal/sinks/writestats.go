@@ -0,0 +1,165 @@
+package sinks
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+)
+
+// writeStatsResolution is the period of a write statistics bucket
+const writeStatsResolution = time.Minute
+
+// MetricWriteStats are the writes of a metric to a sink in a period. A batch holds several metrics usually,
+// so the latency of a batch is attributed to its metrics by their share of the rows
+type MetricWriteStats struct {
+	Metric         string  `json:"metric"`
+	Batches        int64   `json:"batches"` // writes the metric was part of
+	Rows           int64   `json:"rows"`
+	MaxBatchRows   int64   `json:"max_batch_rows"`
+	Bytes          int64   `json:"bytes"` // estimated size of the written values
+	Errors         int64   `json:"errors"`
+	Retries        int64   `json:"retries"` // batches written again after a failure, e.g. replayed from a spool
+	LatencySeconds float64 `json:"latency_seconds"`
+}
+
+// WriteStatsBucket holds the writes of the metrics in a period, by metric name
+type WriteStatsBucket struct {
+	Time    time.Time          `json:"time"` // start of the period
+	Metrics []MetricWriteStats `json:"metrics"`
+}
+
+// SinkWriteStats is the write statistics history of a sink
+type SinkWriteStats struct {
+	Sink              string             `json:"sink"`
+	ResolutionSeconds int                `json:"resolution_seconds"`
+	Buckets           []WriteStatsBucket `json:"buckets"` // oldest first
+}
+
+// writeStats keeps the per-metric write statistics of a sink in buckets of a limited period,
+// so that the metrics dominating the storage cost can be identified
+type writeStats struct {
+	sync.Mutex
+	history time.Duration
+	buckets []writeStatsBucket
+}
+
+type writeStatsBucket struct {
+	time    time.Time
+	metrics map[string]*MetricWriteStats
+}
+
+func newWriteStats(history time.Duration) *writeStats {
+	return &writeStats{history: max(history, writeStatsResolution)}
+}
+
+// bucket returns the stats of the metric in the current period, dropping the periods beyond the history
+func (ws *writeStats) bucket(now time.Time, metric string) *MetricWriteStats {
+	t := now.Truncate(writeStatsResolution)
+	if n := len(ws.buckets); n == 0 || ws.buckets[n-1].time.Before(t) {
+		ws.buckets = append(ws.buckets, writeStatsBucket{time: t, metrics: make(map[string]*MetricWriteStats)})
+	}
+	ws.buckets = slices.DeleteFunc(ws.buckets, func(b writeStatsBucket) bool {
+		return !b.time.After(t.Add(-ws.history))
+	})
+	b := ws.buckets[len(ws.buckets)-1]
+	s, ok := b.metrics[metric]
+	if !ok {
+		s = &MetricWriteStats{Metric: metric}
+		b.metrics[metric] = s
+	}
+	return s
+}
+
+// record accounts a write of the measurements taking latency
+func (ws *writeStats) record(now time.Time, msgs []metrics.MeasurementEnvelope, latency time.Duration, err error) {
+	rows := make(map[string]int64)
+	bytes := make(map[string]int64)
+	var total int64
+	for _, msg := range msgs {
+		rows[msg.MetricName] += int64(len(msg.Data))
+		for _, m := range msg.Data {
+			bytes[msg.MetricName] += measurementSize(m)
+		}
+		total += int64(len(msg.Data))
+	}
+	ws.Lock()
+	defer ws.Unlock()
+	for metric, n := range rows {
+		s := ws.bucket(now, metric)
+		s.Batches++
+		s.Rows += n
+		s.MaxBatchRows = max(s.MaxBatchRows, n)
+		s.Bytes += bytes[metric]
+		if err != nil {
+			s.Errors++
+		}
+		if total > 0 {
+			s.LatencySeconds += latency.Seconds() * float64(n) / float64(total)
+		} else {
+			s.LatencySeconds += latency.Seconds() / float64(len(rows))
+		}
+	}
+}
+
+// retried accounts a batch of the metrics written again after a failure
+func (ws *writeStats) retried(now time.Time, metricNames ...string) {
+	ws.Lock()
+	defer ws.Unlock()
+	for _, metric := range metricNames {
+		ws.bucket(now, metric).Retries++
+	}
+}
+
+// get returns the buckets oldest first, the metrics of a bucket sorted by name
+func (ws *writeStats) get(sink string) SinkWriteStats {
+	ws.Lock()
+	defer ws.Unlock()
+	s := SinkWriteStats{Sink: sink, ResolutionSeconds: int(writeStatsResolution.Seconds()), Buckets: make([]WriteStatsBucket, 0, len(ws.buckets))}
+	for _, b := range ws.buckets {
+		bucket := WriteStatsBucket{Time: b.time}
+		for _, m := range slices.SortedFunc(maps.Values(b.metrics), func(a, b *MetricWriteStats) int { return cmp.Compare(a.Metric, b.Metric) }) {
+			bucket.Metrics = append(bucket.Metrics, *m)
+		}
+		s.Buckets = append(s.Buckets, bucket)
+	}
+	return s
+}
+
+// measurementSize estimates the size of the values of a row as stored, without encoding it
+func measurementSize(m metrics.Measurement) (size int64) {
+	for k, v := range m {
+		size += int64(len(k))
+		switch val := v.(type) {
+		case nil:
+		case bool:
+			size++
+		case string:
+			size += int64(len(val))
+		case []byte:
+			size += int64(len(val))
+		default: // numbers, timestamps
+			size += 8
+		}
+	}
+	return
+}
+
+// writeStatsRecorder is implemented by the sinks writing batches again after a failure, e.g. from a spool
+type writeStatsRecorder interface {
+	setWriteStats(ws *writeStats)
+}
+
+// WriteStats returns the per-metric write statistics history of every sink
+func (mw *MultiWriter) WriteStats() []SinkWriteStats {
+	mw.Lock()
+	defer mw.Unlock()
+	stats := make([]SinkWriteStats, 0, len(mw.states))
+	for _, s := range mw.states {
+		stats = append(stats, s.stats.get(s.health.Sink))
+	}
+	return stats
+}
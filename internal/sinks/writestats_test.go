@@ -0,0 +1,50 @@
+package sinks
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteStats(t *testing.T) {
+	ws := newWriteStats(2 * time.Minute)
+	start := time.Date(2024, 1, 31, 12, 0, 10, 0, time.UTC)
+	batch := []metrics.MeasurementEnvelope{
+		{MetricName: "db_stats", Data: metrics.Measurements{{"epoch_ns": int64(1), "tag_datname": "shop"}}},
+		{MetricName: "table_stats", Data: metrics.Measurements{{"relname": "t1"}, {"relname": "t2"}, {"relname": "t3"}}},
+	}
+	ws.record(start, batch, 4*time.Second, nil)
+	ws.record(start.Add(time.Second), batch[1:], time.Second, errors.New("disk full"))
+	ws.retried(start.Add(2*time.Second), "table_stats")
+
+	s := ws.get("postgres")
+	assert.Equal(t, 60, s.ResolutionSeconds)
+	require.Len(t, s.Buckets, 1)
+	assert.Equal(t, start.Truncate(time.Minute), s.Buckets[0].Time)
+	assert.Equal(t, []MetricWriteStats{
+		{Metric: "db_stats", Batches: 1, Rows: 1, MaxBatchRows: 1, Bytes: 8 + 8 + 11 + 4, LatencySeconds: 1},
+		{Metric: "table_stats", Batches: 2, Rows: 6, MaxBatchRows: 3, Bytes: 2 * 3 * (7 + 2), Errors: 1, Retries: 1, LatencySeconds: 4},
+	}, s.Buckets[0].Metrics, "latency is attributed by the share of rows")
+
+	ws.record(start.Add(time.Minute), batch[:1], 0, nil)
+	ws.record(start.Add(2*time.Minute), batch[:1], 0, nil)
+	s = ws.get("postgres")
+	require.Len(t, s.Buckets, 2, "buckets beyond the history are dropped")
+	assert.Equal(t, start.Add(time.Minute).Truncate(time.Minute), s.Buckets[0].Time)
+}
+
+func TestMultiWriterWriteStats(t *testing.T) {
+	mw := &MultiWriter{statsHistory: time.Hour}
+	mw.AddWriter(&MockWriter{})
+	require.NoError(t, mw.states[0].write(mw.writers[0], []metrics.MeasurementEnvelope{{MetricName: "wal", Data: metrics.Measurements{{"xlog_location_b": int64(1)}}}}, 0))
+
+	stats := mw.WriteStats()
+	require.Len(t, stats, 1)
+	require.Len(t, stats[0].Buckets, 1)
+	assert.Equal(t, "wal", stats[0].Buckets[0].Metrics[0].Metric)
+	assert.EqualValues(t, 1, stats[0].Buckets[0].Metrics[0].Rows)
+}
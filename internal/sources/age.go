@@ -0,0 +1,60 @@
+package sources
+
+// This file contains the decryption of the YAML files encrypted with age, see https://age-encryption.org/v1.
+// Both the X25519 identities, e.g. of age-keygen or sops, and the passphrases are supported.
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+const (
+	ageHeader         = "age-encryption.org/v1"
+	ageIdentityPrefix = "AGE-SECRET-KEY-1"
+)
+
+// isAgeEncrypted returns true if the data is an age encrypted file, binary or armored
+func isAgeEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(ageHeader+"\n")) ||
+		bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte(armor.Header))
+}
+
+// parseAgeIdentities returns the X25519 identities of the key, e.g. the content of a key file with comments,
+// or the key as the passphrase if it contains no identities
+func parseAgeIdentities(key string) ([]age.Identity, error) {
+	if !strings.Contains(strings.ToUpper(key), ageIdentityPrefix) {
+		passphrase := strings.TrimRight(key, "\r\n")
+		if passphrase == "" {
+			return nil, errors.New("empty decryption key")
+		}
+		id, err := age.NewScryptIdentity(passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return []age.Identity{id}, nil
+	}
+	ids, err := age.ParseIdentities(strings.NewReader(key))
+	if err != nil {
+		return nil, fmt.Errorf("malformed age identity: %w", err)
+	}
+	return ids, nil
+}
+
+// decryptAge returns the plaintext of the binary or armored age encrypted file
+func decryptAge(data []byte, ids []age.Identity) ([]byte, error) {
+	var src io.Reader = bytes.NewReader(data)
+	if !bytes.HasPrefix(data, []byte(ageHeader)) {
+		src = armor.NewReader(src)
+	}
+	r, err := age.Decrypt(src, ids...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
@@ -1,11 +1,53 @@
 package sources
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
 // SourceOpts specifies the sources related command-line options
 type CmdOpts struct {
-	Sources                      string   `short:"s" long:"sources" mapstructure:"config" description:"Postgres URI, file or folder of YAML files containing info on which DBs to monitor" env:"PW_SOURCES"`
-	Refresh                      int      `long:"refresh" mapstructure:"refresh" description:"How frequently to resync sources and metrics" env:"PW_REFRESH" default:"120"`
-	Groups                       []string `short:"g" long:"group" mapstructure:"group" description:"Groups for filtering which databases to monitor. By default all are monitored" env:"PW_GROUP"`
-	MinDbSizeMB                  int64    `long:"min-db-size-mb" mapstructure:"min-db-size-mb" description:"Smaller size DBs will be ignored and not monitored until they reach the threshold." env:"PW_MIN_DB_SIZE_MB" default:"0"`
-	MaxParallelConnectionsPerDb  int      `long:"max-parallel-connections-per-db" mapstructure:"max-parallel-connections-per-db" description:"Max parallel metric fetches per DB. Note the multiplication effect on multi-DB instances" env:"PW_MAX_PARALLEL_CONNECTIONS_PER_DB" default:"4"`
-	TryCreateListedExtsIfMissing string   `long:"try-create-listed-exts-if-missing" mapstructure:"try-create-listed-exts-if-missing" description:"Try creating the listed extensions (comma sep.) on first connect for all monitored DBs when missing. Main usage - pg_stat_statements" env:"PW_TRY_CREATE_LISTED_EXTS_IF_MISSING" default:""`
+	Sources                      string        `short:"s" long:"sources" mapstructure:"config" description:"Postgres URI, file or folder of YAML files containing info on which DBs to monitor" env:"PW_SOURCES"`
+	SourcesKey                   string        `long:"sources-key" mapstructure:"sources-key" description:"Age identity (AGE-SECRET-KEY-1...) or passphrase to decrypt the encrypted YAML sources files" env:"PW_SOURCES_KEY"`
+	SourcesKeyFile               string        `long:"sources-key-file" mapstructure:"sources-key-file" description:"File with the age identities to decrypt the encrypted YAML sources files, e.g. the key file of age-keygen or sops" env:"PW_SOURCES_KEY_FILE"`
+	SourcesKeyCommand            string        `long:"sources-key-command" mapstructure:"sources-key-command" description:"Shell command printing the age identity or passphrase to decrypt the encrypted YAML sources files, e.g. to read it from a keyring or KMS" env:"PW_SOURCES_KEY_COMMAND"`
+	Refresh                      int           `long:"refresh" mapstructure:"refresh" description:"How frequently to resync sources and metrics" env:"PW_REFRESH" default:"120"`
+	Groups                       []string      `short:"g" long:"group" mapstructure:"group" description:"Groups for filtering which databases to monitor. By default all are monitored" env:"PW_GROUP"`
+	MinDbSizeMB                  int64         `long:"min-db-size-mb" mapstructure:"min-db-size-mb" description:"Smaller size DBs will be ignored and not monitored until they reach the threshold." env:"PW_MIN_DB_SIZE_MB" default:"0"`
+	MinDbSizeHysteresis          int           `long:"min-db-size-hysteresis" mapstructure:"min-db-size-hysteresis" description:"Percentage above --min-db-size-mb a dormant DB must grow to be monitored again" env:"PW_MIN_DB_SIZE_HYSTERESIS" default:"10"`
+	DormancyStateFile            string        `long:"dormancy-state-file" mapstructure:"dormancy-state-file" description:"File to keep the dormant DBs state across restarts. Disabled if empty" env:"PW_DORMANCY_STATE_FILE"`
+	DowntimeFile                 string        `long:"downtime-file" mapstructure:"downtime-file" description:"File to keep the planned maintenance downtimes across restarts. Disabled if empty" env:"PW_DOWNTIME_FILE"`
+	MaxParallelConnectionsPerDb  int           `long:"max-parallel-connections-per-db" mapstructure:"max-parallel-connections-per-db" description:"Max parallel metric fetches per DB. Note the multiplication effect on multi-DB instances" env:"PW_MAX_PARALLEL_CONNECTIONS_PER_DB" default:"4"`
+	MaxParallelFetchesPerHost    int           `long:"max-parallel-fetches-per-host" mapstructure:"max-parallel-fetches-per-host" description:"Max in-flight metric fetches of all DBs of a physical host, surplus fetches wait at most the metric interval. Set to 0 to disable" env:"PW_MAX_PARALLEL_FETCHES_PER_HOST" default:"3"`
+	StatementTimeout             time.Duration `long:"statement-timeout" mapstructure:"statement-timeout" description:"Max execution time of a metric query. Enforced both server-side and client-side. Set to 0 to disable" env:"PW_STATEMENT_TIMEOUT" default:"5m"`
+	AuditLog                     string        `long:"audit-log" mapstructure:"audit-log" description:"File to record every statement executed on monitored DBs. Disabled if empty" env:"PW_AUDIT_LOG"`
+	AuditLogSize                 int           `long:"audit-log-size" mapstructure:"audit-log-size" description:"Maximum size in MB of the audit log file before it gets rotated" env:"PW_AUDIT_LOG_SIZE" default:"100"`
+	AuditLogNumber               int           `long:"audit-log-number" mapstructure:"audit-log-number" description:"Maximum number of old audit log files to retain" env:"PW_AUDIT_LOG_NUMBER" default:"10"`
+	InventoryExport              string        `long:"inventory-export" mapstructure:"inventory-export" description:"File or webhook URL to export the inventory of monitored DBs to. Disabled if empty" env:"PW_INVENTORY_EXPORT"`
+	InventoryExportFormat        string        `long:"inventory-export-format" mapstructure:"inventory-export-format" description:"Format of the inventory export" choice:"json" choice:"csv" env:"PW_INVENTORY_EXPORT_FORMAT" default:"json"`
+	InventoryExportInterval      time.Duration `long:"inventory-export-interval" mapstructure:"inventory-export-interval" description:"How often to export the inventory of monitored DBs" env:"PW_INVENTORY_EXPORT_INTERVAL" default:"1h"`
+	TryCreateListedExtsIfMissing string        `long:"try-create-listed-exts-if-missing" mapstructure:"try-create-listed-exts-if-missing" description:"Try creating the listed extensions (comma sep.) on first connect for all monitored DBs when missing. Main usage - pg_stat_statements" env:"PW_TRY_CREATE_LISTED_EXTS_IF_MISSING" default:""`
+}
+
+// DecryptionKey returns the age identities or the passphrase of the encrypted YAML sources files, from the first of
+// --sources-key, --sources-key-file and --sources-key-command specified
+func (c CmdOpts) DecryptionKey(ctx context.Context) (string, error) {
+	switch {
+	case c.SourcesKey != "":
+		return c.SourcesKey, nil
+	case c.SourcesKeyFile != "":
+		key, err := os.ReadFile(c.SourcesKeyFile)
+		return string(key), err
+	case c.SourcesKeyCommand != "":
+		key, err := exec.CommandContext(ctx, "sh", "-c", c.SourcesKeyCommand).Output()
+		if err != nil {
+			return "", fmt.Errorf("--sources-key-command failed: %w", err)
+		}
+		return string(key), nil
+	}
+	return "", errors.New("no decryption key specified, use --sources-key, --sources-key-file or --sources-key-command")
 }
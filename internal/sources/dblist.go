@@ -0,0 +1,74 @@
+package sources
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+)
+
+// databaseListSQL returns a cheap fingerprint of the databases of the instance changing on every
+// create, drop, rename and (dis)allowing connections
+const databaseListSQL = `select /* pgwatch_generated */
+	md5(string_agg(oid::text || ':' || datname || ':' || datallowconn::text, ',' order by oid))
+	from pg_database`
+
+const databaseListTimeout = 5 * time.Second
+
+// resolvedDatabaseList contains the database list fingerprint of the postgres-continuous-discovery source
+// seen on the last resolve
+type resolvedDatabaseList struct {
+	Source      Source
+	Fingerprint string
+}
+
+var resolvedDatabaseLists = make(map[string]resolvedDatabaseList) // per source name
+var resolvedDatabaseListsLock sync.Mutex
+
+func rememberDatabaseList(s Source, fingerprint string) {
+	resolvedDatabaseListsLock.Lock()
+	resolvedDatabaseLists[s.Name] = resolvedDatabaseList{Source: s, Fingerprint: fingerprint}
+	resolvedDatabaseListsLock.Unlock()
+}
+
+// forgetDatabaseLists removes the sources not in the configuration anymore
+func forgetDatabaseLists(enabled map[string]bool) {
+	resolvedDatabaseListsLock.Lock()
+	maps.DeleteFunc(resolvedDatabaseLists, func(name string, _ resolvedDatabaseList) bool { return !enabled[name] })
+	resolvedDatabaseListsLock.Unlock()
+}
+
+var getDatabaseListFingerprint = func(ctx context.Context, s Source) (fingerprint string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, databaseListTimeout)
+	defer cancel()
+	conn, err := pgx.Connect(ctx, s.ConnStr)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = conn.Close(ctx) }()
+	err = conn.QueryRow(ctx, databaseListSQL).Scan(&fingerprint)
+	return
+}
+
+// DatabaseListsChanged checks the instances of the resolved postgres-continuous-discovery sources
+// and returns the names of the sources with databases created, dropped or renamed since the last resolve.
+// Unreachable instances are skipped
+func DatabaseListsChanged(ctx context.Context) (changed []string) {
+	resolvedDatabaseListsLock.Lock()
+	lists := slices.Collect(maps.Values(resolvedDatabaseLists))
+	resolvedDatabaseListsLock.Unlock()
+	for _, l := range lists {
+		fingerprint, err := getDatabaseListFingerprint(ctx, l.Source)
+		if err != nil {
+			continue
+		}
+		if fingerprint != l.Fingerprint {
+			changed = append(changed, l.Source.Name)
+		}
+	}
+	slices.Sort(changed)
+	return
+}
@@ -0,0 +1,31 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDatabaseListsChanged(t *testing.T) {
+	ctx := context.Background()
+	fingerprint := "a"
+	var connErr error
+	defer func(f func(context.Context, Source) (string, error)) { getDatabaseListFingerprint = f }(getDatabaseListFingerprint)
+	getDatabaseListFingerprint = func(context.Context, Source) (string, error) { return fingerprint, connErr }
+
+	rememberDatabaseList(Source{Name: "tenants"}, "a")
+	defer forgetDatabaseLists(nil)
+	assert.Empty(t, DatabaseListsChanged(ctx))
+
+	fingerprint = "b"
+	assert.Equal(t, []string{"tenants"}, DatabaseListsChanged(ctx), "created database should be detected")
+
+	connErr = errors.New("connection refused")
+	assert.Empty(t, DatabaseListsChanged(ctx), "unreachable instances should be skipped")
+
+	forgetDatabaseLists(map[string]bool{"other": true})
+	connErr = nil
+	assert.Empty(t, DatabaseListsChanged(ctx), "removed sources should not be checked")
+}
@@ -0,0 +1,132 @@
+package sources
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// custom tags added to the databases of Patroni cluster members
+const (
+	PatroniRoleTag      = "patroni_role"
+	PatroniClusterTag   = "patroni_cluster"   // patroni-namespace-discovery and standby metrics routing only
+	PatroniNamespaceTag = "patroni_namespace" // patroni-namespace-discovery only
+)
+
+// isPatroniPrimary returns true for the roles reported for the cluster leader by different Patroni versions
+func isPatroniPrimary(role string) bool {
+	return role == "master" || role == "primary"
+}
+
+// setPatroniMember tags the member database with its Patroni role and cluster metadata and, if the source
+// has a standby configuration, assigns it to the replicas right away
+func (s *Source) setPatroniMember(m PatroniClusterMember) {
+	if s.CustomTags == nil {
+		s.CustomTags = make(map[string]string, 3)
+	}
+	s.CustomTags[PatroniRoleTag] = m.Role
+	if s.Kind == SourcePatroniNamespace || len(s.HostConfig.StandbyMetrics) > 0 {
+		s.CustomTags[PatroniClusterTag] = m.Scope
+	}
+	if s.Kind == SourcePatroniNamespace {
+		s.CustomTags[PatroniNamespaceTag] = s.HostConfig.Namespace
+	}
+	if s.ClusterName == "" {
+		s.ClusterName = m.Scope
+	}
+	if isPatroniPrimary(m.Role) || (s.PresetMetricsStandby == "" && len(s.MetricsStandby) == 0) {
+		return
+	}
+	s.PresetMetrics = s.PresetMetricsStandby
+	s.Metrics = maps.Clone(s.MetricsStandby)
+}
+
+// routeStandbyMetrics pairs the primary of every cluster database with a replica, the first one by name,
+// which fetches the standby metrics of the primary to reduce its load
+func routeStandbyMetrics(mds []*MonitoredDatabase) {
+	members := make(map[string][]*MonitoredDatabase) // [cluster/dbname]
+	for _, md := range mds {
+		if len(md.HostConfig.StandbyMetrics) == 0 {
+			continue
+		}
+		key := md.CustomTags[PatroniClusterTag] + "/" + md.Source.GetDatabaseName()
+		members[key] = append(members[key], md)
+	}
+	for _, group := range members {
+		var primary, replica *MonitoredDatabase
+		slices.SortFunc(group, func(a, b *MonitoredDatabase) int { return strings.Compare(a.Name, b.Name) })
+		for _, md := range group {
+			if isPatroniPrimary(md.CustomTags[PatroniRoleTag]) {
+				primary = md
+			} else if replica == nil {
+				replica = md
+			}
+		}
+		if primary != nil && replica != nil {
+			primary.StandbyMetricsTo = replica.Name
+			replica.StandbyMetricsFrom = primary.Name
+		}
+	}
+}
+
+// PatroniMembership contains the Patroni cluster members of the source seen on the last resolve
+type PatroniMembership struct {
+	Source     Source
+	Members    []PatroniClusterMember
+	ResolvedAt time.Time
+}
+
+func (pm PatroniMembership) roles() map[string]string {
+	return memberRoles(pm.Members)
+}
+
+func memberRoles(members []PatroniClusterMember) map[string]string {
+	roles := make(map[string]string, len(members))
+	for _, m := range members {
+		roles[m.Name] = m.Role
+	}
+	return roles
+}
+
+var resolvedPatroniMembership = make(map[string]PatroniMembership) // per source name
+var resolvedPatroniMembershipLock sync.Mutex
+
+func rememberPatroniMembers(s Source, members []PatroniClusterMember) {
+	resolvedPatroniMembershipLock.Lock()
+	resolvedPatroniMembership[s.Name] = PatroniMembership{Source: s, Members: slices.Clone(members), ResolvedAt: time.Now()}
+	resolvedPatroniMembershipLock.Unlock()
+}
+
+// forgetPatroniMembers removes the sources not in the configuration anymore
+func forgetPatroniMembers(enabled map[string]bool) {
+	resolvedPatroniMembershipLock.Lock()
+	maps.DeleteFunc(resolvedPatroniMembership, func(name string, _ PatroniMembership) bool { return !enabled[name] })
+	resolvedPatroniMembershipLock.Unlock()
+}
+
+// ResolvedPatroniMembership returns the Patroni cluster members of all sources seen on the last resolve
+func ResolvedPatroniMembership() []PatroniMembership {
+	resolvedPatroniMembershipLock.Lock()
+	defer resolvedPatroniMembershipLock.Unlock()
+	return slices.SortedFunc(maps.Values(resolvedPatroniMembership), func(a, b PatroniMembership) int {
+		return strings.Compare(a.Source.Name, b.Source.Name)
+	})
+}
+
+// PatroniRolesChanged checks the DCS of the resolved Patroni sources and returns the names of the sources
+// having members with a different role than seen on the last resolve, e.g. after a switchover.
+// Sources with unavailable DCS are skipped
+func PatroniRolesChanged() (changed []string) {
+	for _, pm := range ResolvedPatroniMembership() {
+		members, err := getClusterMembers(pm.Source)
+		if err != nil {
+			continue
+		}
+		if !maps.Equal(memberRoles(members), pm.roles()) {
+			changed = append(changed, pm.Source.Name)
+		}
+	}
+	return
+}
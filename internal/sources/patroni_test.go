@@ -0,0 +1,96 @@
+package sources
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetPatroniMember(t *testing.T) {
+	s := Source{PresetMetrics: "exhaustive", PresetMetricsStandby: "standby"}
+	s.setPatroniMember(PatroniClusterMember{Scope: "batman", Role: "replica"})
+	assert.Equal(t, map[string]string{PatroniRoleTag: "replica"}, s.CustomTags)
+	assert.Equal(t, "standby", s.PresetMetrics, "replicas should get the standby preset")
+
+	s = Source{PresetMetrics: "exhaustive", PresetMetricsStandby: "standby"}
+	s.setPatroniMember(PatroniClusterMember{Scope: "batman", Role: "primary"})
+	assert.Equal(t, "exhaustive", s.PresetMetrics)
+
+	s = Source{Metrics: map[string]float64{"wal": 60}}
+	s.setPatroniMember(PatroniClusterMember{Scope: "batman", Role: "replica"})
+	assert.Equal(t, map[string]float64{"wal": 60}, s.Metrics, "no standby config to swap to")
+
+	s = Source{Kind: SourcePatroniNamespace, HostConfig: HostConfigAttrs{Namespace: "/service/"}}
+	s.setPatroniMember(PatroniClusterMember{Scope: "batman", Role: "master"})
+	assert.Equal(t, map[string]string{PatroniRoleTag: "master", PatroniClusterTag: "batman", PatroniNamespaceTag: "/service/"}, s.CustomTags)
+	assert.Equal(t, "batman", s.ClusterName, "the cluster name defaults to the Patroni scope")
+
+	s = Source{ClusterName: "gotham"}
+	s.setPatroniMember(PatroniClusterMember{Scope: "batman", Role: "replica"})
+	assert.Equal(t, "gotham", s.ClusterName, "the configured cluster name is kept")
+}
+
+func TestIsScopeIncluded(t *testing.T) {
+	c := HostConfigAttrs{}
+	included, err := c.IsScopeIncluded("batman")
+	assert.NoError(t, err)
+	assert.True(t, included)
+
+	c = HostConfigAttrs{ScopeIncludePattern: "^prod_", ScopeExcludePattern: "_test$"}
+	for scope, expected := range map[string]bool{"prod_batman": true, "dev_batman": false, "prod_test": false} {
+		included, err = c.IsScopeIncluded(scope)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, included, scope)
+	}
+
+	c.ScopeExcludePattern = "("
+	_, err = c.IsScopeIncluded("prod_batman")
+	assert.Error(t, err)
+}
+
+func TestPatroniRolesChanged(t *testing.T) {
+	members := []PatroniClusterMember{{Name: "node1", Role: "primary"}, {Name: "node2", Role: "replica"}}
+	var dcsErr error
+	defer func(f func(Source) ([]PatroniClusterMember, error)) { getClusterMembers = f }(getClusterMembers)
+	getClusterMembers = func(Source) ([]PatroniClusterMember, error) { return members, dcsErr }
+
+	rememberPatroniMembers(Source{Name: "batman"}, members)
+	defer forgetPatroniMembers(nil)
+	assert.Empty(t, PatroniRolesChanged())
+	assert.Len(t, ResolvedPatroniMembership(), 1)
+
+	members = []PatroniClusterMember{{Name: "node1", Role: "replica"}, {Name: "node2", Role: "primary"}}
+	assert.Equal(t, []string{"batman"}, PatroniRolesChanged(), "switchover should be detected")
+
+	dcsErr = errors.New("DCS unavailable")
+	assert.Empty(t, PatroniRolesChanged(), "unavailable DCS should be skipped")
+
+	forgetPatroniMembers(map[string]bool{"robin": true})
+	dcsErr = nil
+	assert.Empty(t, PatroniRolesChanged(), "removed sources should not be checked")
+}
+
+func TestRouteStandbyMetrics(t *testing.T) {
+	member := func(name, scope, role string, standbyMetrics ...string) *MonitoredDatabase {
+		md := &MonitoredDatabase{Source: Source{Name: name, Kind: SourcePatroni, ConnStr: "postgresql://" + name + "/shop"}}
+		md.HostConfig.StandbyMetrics = standbyMetrics
+		md.setPatroniMember(PatroniClusterMember{Scope: scope, Name: name, Role: role})
+		return md
+	}
+	primary := member("node1", "batman", "primary", "table_stats")
+	replica2 := member("node3", "batman", "replica", "table_stats")
+	replica1 := member("node2", "batman", "replica", "table_stats")
+	lonely := member("node4", "robin", "primary", "table_stats")
+	plain := member("node5", "joker", "primary")
+	plainReplica := member("node6", "joker", "replica")
+	routeStandbyMetrics([]*MonitoredDatabase{primary, replica2, replica1, lonely, plain, plainReplica})
+
+	assert.Equal(t, "batman", primary.CustomTags[PatroniClusterTag], "members are tagged with the cluster")
+	assert.Equal(t, "node2", primary.StandbyMetricsTo, "the first replica by name is designated")
+	assert.Equal(t, "node1", replica1.StandbyMetricsFrom)
+	assert.Empty(t, replica2.StandbyMetricsFrom)
+	assert.Empty(t, lonely.StandbyMetricsTo, "no replica to route to")
+	assert.Empty(t, plain.StandbyMetricsTo, "routing is not configured")
+	assert.NotContains(t, plain.CustomTags, PatroniClusterTag)
+}
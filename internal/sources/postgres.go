@@ -64,9 +64,15 @@ func (r *dbSourcesReaderWriter) updateDatabase(conn db.PgxIface, md Source) (err
 	exclude_pattern, 
 	custom_tags, 
 	host_config, 
-	only_if_master) 
+	only_if_master,
+	cluster_name,
+	owner,
+	team,
+	contact,
+	notes) 
 values 
-	($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''), $9, $10, $11, $12, $13, $14) 
+	($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''), $9, $10, $11, $12, $13, $14, NULLIF($15, ''),
+	NULLIF($16, ''), NULLIF($17, ''), NULLIF($18, ''), NULLIF($19, '')) 
 on conflict (name) do update set
 	"group" = $2, 
 	dbtype = $3, 
@@ -80,12 +86,18 @@ on conflict (name) do update set
 	exclude_pattern = $11, 
 	custom_tags = $12,
 	host_config = $13, 
-	only_if_master = $14`
+	only_if_master = $14,
+	cluster_name = NULLIF($15, ''),
+	owner = NULLIF($16, ''),
+	team = NULLIF($17, ''),
+	contact = NULLIF($18, ''),
+	notes = NULLIF($19, '')`
 	_, err = conn.Exec(context.Background(), sql,
 		md.Name, md.Group, md.Kind,
 		md.ConnStr, m(md.Metrics), m(md.MetricsStandby), md.PresetMetrics, md.PresetMetricsStandby,
 		md.IsSuperuser, md.IncludePattern, md.ExcludePattern, m(md.CustomTags),
-		m(md.HostConfig), md.OnlyIfMaster)
+		m(md.HostConfig), md.OnlyIfMaster, md.ClusterName,
+		md.Owner, md.Team, md.Contact, md.Notes)
 	return err
 }
 
@@ -114,6 +126,11 @@ func (r *dbSourcesReaderWriter) GetSources() (dbs Sources, err error) {
 	coalesce(custom_tags, '{}'::jsonb) as custom_tags, 
 	coalesce(host_config, '{}') as host_config, 
 	only_if_master,
+	coalesce(cluster_name, '') as cluster_name,
+	coalesce(owner, '') as owner,
+	coalesce(team, '') as team,
+	coalesce(contact, '') as contact,
+	coalesce(notes, '') as notes,
 	is_enabled
 from
 	pgwatch.source`
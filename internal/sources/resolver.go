@@ -16,6 +16,7 @@ import (
 	"os"
 	"path"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -29,14 +30,26 @@ import (
 // ResolveDatabases() updates list of monitored objects from continuous monitoring sources, e.g. patroni
 func (srcs Sources) ResolveDatabases() (_ MonitoredDatabases, err error) {
 	resolvedDbs := make(MonitoredDatabases, 0, len(srcs))
+	enabled := make(map[string]bool, len(srcs))
 	for _, s := range srcs {
 		if !s.IsEnabled {
 			continue
 		}
+		enabled[s.Name] = true
 		dbs, e := s.ResolveDatabases()
 		err = errors.Join(err, e)
+		if s.HostConfig.ServerTags > "" {
+			for _, md := range dbs {
+				md.ApplyServerTags(context.TODO())
+			}
+		}
+		for _, md := range dbs {
+			md.SetClusterNameTag()
+		}
 		resolvedDbs = append(resolvedDbs, dbs...)
 	}
+	forgetPatroniMembers(enabled)
+	forgetDatabaseLists(enabled)
 	return resolvedDbs, nil
 }
 
@@ -191,6 +204,11 @@ func getEtcdClusterMembers(s Source) ([]PatroniClusterMember, error) {
 		}
 
 		for scope := range scopes {
+			if included, err := s.HostConfig.IsScopeIncluded(scope); err != nil {
+				return ret, err
+			} else if !included {
+				continue
+			}
 			scopeMembers, err := extractEtcdScopeMembers(ctx, s, scope, kapi, true)
 			if err != nil {
 				continue
@@ -203,7 +221,6 @@ func getEtcdClusterMembers(s Source) ([]PatroniClusterMember, error) {
 			return ret, cmp.Or(context.Cause(ctx), err)
 		}
 	}
-	lastFoundClusterMembers[s.Name] = ret
 	return ret, nil
 }
 
@@ -244,6 +261,21 @@ const (
 	dcsTypeConsul    = "consul"
 )
 
+var errUnknownDCS = errors.New("unknown DCS")
+
+// getClusterMembers returns the Patroni cluster members registered in the DCS of the source
+var getClusterMembers = func(ce Source) ([]PatroniClusterMember, error) {
+	switch ce.HostConfig.DcsType {
+	case dcsTypeEtcd:
+		return getEtcdClusterMembers(ce)
+	case dcsTypeZookeeper:
+		return getZookeeperClusterMembers(ce)
+	case dcsTypeConsul:
+		return getConsulClusterMembers(ce)
+	}
+	return nil, errUnknownDCS
+}
+
 func ResolveDatabasesFromPatroni(ce Source) ([]*MonitoredDatabase, error) {
 	var mds []*MonitoredDatabase
 	var clusterMembers []PatroniClusterMember
@@ -251,15 +283,8 @@ func ResolveDatabasesFromPatroni(ce Source) ([]*MonitoredDatabase, error) {
 	var ok bool
 	var dbUnique string
 
-	switch ce.HostConfig.DcsType {
-	case dcsTypeEtcd:
-		clusterMembers, err = getEtcdClusterMembers(ce)
-	case dcsTypeZookeeper:
-		clusterMembers, err = getZookeeperClusterMembers(ce)
-	case dcsTypeConsul:
-		clusterMembers, err = getConsulClusterMembers(ce)
-	default:
-		return nil, errors.New("unknown DCS")
+	if clusterMembers, err = getClusterMembers(ce); errors.Is(err, errUnknownDCS) {
+		return nil, err
 	}
 	if err != nil {
 		logger.WithField("source", ce.Name).Debug("Failed to get info from DCS, using previous member info if any")
@@ -269,13 +294,14 @@ func ResolveDatabasesFromPatroni(ce Source) ([]*MonitoredDatabase, error) {
 	} else {
 		lastFoundClusterMembers[ce.Name] = clusterMembers
 	}
+	rememberPatroniMembers(ce, clusterMembers)
 	if len(clusterMembers) == 0 {
 		return mds, err
 	}
 
 	for _, m := range clusterMembers {
 		logger.Infof("Processing Patroni cluster member [%s:%s]", ce.Name, m.Name)
-		if ce.OnlyIfMaster && m.Role != "master" {
+		if ce.OnlyIfMaster && !isPatroniPrimary(m.Role) {
 			logger.Infof("Skipping over Patroni cluster member [%s:%s] as not a master", ce.Name, m.Name)
 			continue
 		}
@@ -292,9 +318,13 @@ func ResolveDatabasesFromPatroni(ce Source) ([]*MonitoredDatabase, error) {
 		} else {
 			dbUnique = ce.Name + "_" + m.Name
 		}
-		if ce.GetDatabaseName() != "" {
+		vars := map[string]string{"name": ce.Name, "cluster": m.Scope, "scope": m.Scope,
+			"member": m.Name, "role": m.Role, "host": host, "port": port}
+		if dbname := ce.GetDatabaseName(); dbname != "" {
 			c := &MonitoredDatabase{Source: *ce.Clone()}
-			c.Name = dbUnique
+			vars["dbname"] = dbname
+			c.Name = ce.DiscoveredName(dbUnique, vars)
+			c.setPatroniMember(m)
 			mds = append(mds, c)
 			continue
 		}
@@ -334,48 +364,82 @@ func ResolveDatabasesFromPatroni(ce Source) ([]*MonitoredDatabase, error) {
 		for _, d := range data {
 			connURL.Path = d["datname"].(string)
 			c := ce.Clone()
-			c.Name = dbUnique + "_" + d["datname_escaped"].(string)
+			vars["dbname"] = d["datname_escaped"].(string)
+			c.Name = ce.DiscoveredName(dbUnique+"_"+vars["dbname"], vars)
 			c.ConnStr = connURL.String()
+			c.setPatroniMember(m)
 			mds = append(mds, &MonitoredDatabase{Source: *c})
 		}
 
 	}
+	routeStandbyMetrics(mds)
 
 	return mds, err
 }
 
 // "resolving" reads all the DB names from the given host/port, additionally matching/not matching specified regex patterns
+// and the discovery filter of the host config
 func ResolveDatabasesFromPostgres(s Source) (resolvedDbs MonitoredDatabases, err error) {
 	var (
-		c      db.PgxPoolIface
-		dbname string
-		rows   pgx.Rows
+		c                     db.PgxPoolIface
+		dbname, dbnameEscaped string
+		rows                  pgx.Rows
+		connConfig            *pgx.ConnConfig
+		f                     = s.HostConfig.Discovery
 	)
+	if connConfig, err = pgx.ParseConfig(s.ConnStr); err != nil {
+		return
+	}
 	c, err = db.New(context.TODO(), s.ConnStr)
 	if err != nil {
 		return
 	}
 	defer c.Close()
 
+	var fingerprint string
+	if err = c.QueryRow(context.TODO(), databaseListSQL).Scan(&fingerprint); err != nil {
+		return nil, err
+	}
+	rememberDatabaseList(s, fingerprint)
+
 	sql := `select /* pgwatch_generated */
+		datname::text as datname,
 		quote_ident(datname)::text as datname_escaped
-		from pg_database
+		from pg_database d
 		where not datistemplate
 		and datallowconn
 		and has_database_privilege (datname, 'CONNECT')
 		and case when length(trim($1)) > 0 then datname ~ $1 else true end
-		and case when length(trim($2)) > 0 then not datname ~ $2 else true end`
+		and case when length(trim($2)) > 0 then not datname ~ $2 else true end
+		and case when cardinality($3::text[]) > 0 then pg_get_userbyid(datdba) = any($3) else true end
+		and case when $4 > 0 then pg_database_size(d.oid) >= $4 * 1024 * 1024 else true end
+		and case when length(trim($5)) > 0 then coalesce(shobj_description(d.oid, 'pg_database'), '') ~ $5 else true end`
 
-	if rows, err = c.Query(context.TODO(), sql, s.IncludePattern, s.ExcludePattern); err != nil {
+	if rows, err = c.Query(context.TODO(), sql, s.IncludePattern, s.ExcludePattern,
+		f.Owners, f.MinSizeMB, f.MarkerComment); err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 	for rows.Next() {
-		if err = rows.Scan(&dbname); err != nil {
+		if err = rows.Scan(&dbname, &dbnameEscaped); err != nil {
 			return nil, err
 		}
+		if f.MarkerExtension > "" {
+			found, err := hasExtension(context.TODO(), s, dbname, f.MarkerExtension)
+			if err != nil {
+				logger.WithField("source", s.Name).WithField("database", dbname).WithError(err).Warning("could not check the marker extension")
+			}
+			if !found {
+				continue
+			}
+		}
 		rdb := &MonitoredDatabase{Source: *s.Clone()}
-		rdb.Name += "_" + dbname
-		rdb.SetDatabaseName(dbname)
+		rdb.Name = s.DiscoveredName(s.Name+"_"+dbnameEscaped, map[string]string{"name": s.Name,
+			"host": connConfig.Host, "port": strconv.Itoa(int(connConfig.Port)), "dbname": dbnameEscaped})
+		rdb.SetDatabaseName(dbnameEscaped)
+		if err = f.ApplyOverrides(&rdb.Source, dbname); err != nil {
+			return nil, err
+		}
 		resolvedDbs = append(resolvedDbs, rdb)
 	}
 
@@ -384,3 +448,107 @@ func ResolveDatabasesFromPostgres(s Source) (resolvedDbs MonitoredDatabases, err
 	}
 	return
 }
+
+// DiscoveredName returns the unique name of a discovered database built from the name template
+// of the host config, e.g. "{cluster}_{role}_{dbname}", or the default name if no template is set.
+// Variables not known for the source kind are replaced with empty strings
+func (s Source) DiscoveredName(defaultName string, vars map[string]string) string {
+	if s.HostConfig.NameTemplate == "" {
+		return defaultName
+	}
+	return nameTemplateVarRegex.ReplaceAllStringFunc(s.HostConfig.NameTemplate, func(v string) string {
+		return vars[strings.Trim(v, "{}")]
+	})
+}
+
+var nameTemplateVarRegex = regexp.MustCompile(`\{(name|cluster|scope|member|role|host|port|dbname)\}`)
+
+// hasExtension checks if the extension is installed in the given database of the source instance
+func hasExtension(ctx context.Context, s Source, dbname, extension string) (found bool, err error) {
+	connConfig, err := pgx.ParseConfig(s.ConnStr)
+	if err != nil {
+		return false, err
+	}
+	connConfig.Database = dbname
+	conn, err := pgx.ConnectConfig(ctx, connConfig)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = conn.Close(ctx) }()
+	err = conn.QueryRow(ctx, `select /* pgwatch_generated */ exists(select 1 from pg_extension where extname = $1)`, extension).Scan(&found)
+	return
+}
+
+// server tags sources
+const (
+	ServerTagsComment = "comment" // JSON object in the database COMMENT
+	ServerTagsTable   = "table"   // key and value columns of the pgwatch.tags table
+)
+
+const serverTagsTimeout = 5 * time.Second
+
+// getServerTags reads the tags the database owners put on the monitored database itself
+func getServerTags(ctx context.Context, connConfig *pgx.ConnConfig, from string) (tags map[string]string, err error) {
+	var sql string
+	switch from {
+	case ServerTagsComment:
+		sql = `select /* pgwatch_generated */ coalesce(shobj_description(oid, 'pg_database'), '') from pg_database where datname = current_database()`
+	case ServerTagsTable:
+		sql = `select /* pgwatch_generated */ case when to_regclass('pgwatch.tags') is null then '' else
+			(select coalesce(jsonb_object_agg(key, value), '{}')::text from pgwatch.tags) end`
+	default:
+		return nil, fmt.Errorf("unknown server tags source %q, expected %q or %q", from, ServerTagsComment, ServerTagsTable)
+	}
+	ctx, cancel := context.WithTimeout(ctx, serverTagsTimeout)
+	defer cancel()
+	conn, err := pgx.ConnectConfig(ctx, connConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close(ctx) }()
+	var jsonText string
+	if err = conn.QueryRow(ctx, sql).Scan(&jsonText); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(strings.TrimSpace(jsonText), "{") {
+		return nil, nil // a plain text comment, not meant for pgwatch
+	}
+	return jsonTextToStringMap(jsonText)
+}
+
+// MergeServerTags adds the tags read from the server to the custom tags.
+// Tags configured in pgwatch take precedence over the ones set on the server
+func (md *MonitoredDatabase) MergeServerTags(tags map[string]string) {
+	if len(tags) > 0 && md.CustomTags == nil {
+		md.CustomTags = make(map[string]string, len(tags))
+	}
+	for k, v := range tags {
+		if _, ok := md.CustomTags[k]; !ok {
+			md.CustomTags[k] = v
+		}
+	}
+}
+
+// ApplyServerTags merges the tags set on the monitored database into the custom tags
+// according to the server_tags host config setting. Failures are logged, the configured tags are kept
+func (md *MonitoredDatabase) ApplyServerTags(ctx context.Context) {
+	if md.HostConfig.ServerTags == "" || !md.IsPostgresSource() {
+		return
+	}
+	var connConfig *pgx.ConnConfig
+	if md.ConnConfig != nil {
+		connConfig = md.ConnConfig.ConnConfig.Copy()
+	} else {
+		var err error
+		if connConfig, err = pgx.ParseConfig(md.ConnStr); err != nil {
+			logger.WithField("source", md.Name).WithError(err).Warning("could not read server tags")
+			return
+		}
+	}
+	tags, err := getServerTags(ctx, connConfig, md.HostConfig.ServerTags)
+	if err != nil {
+		logger.WithField("source", md.Name).WithError(err).Warning("could not read server tags")
+		return
+	}
+	md.MergeServerTags(tags)
+}
@@ -46,3 +46,115 @@ func TestMonitoredDatabase_ResolveDatabasesFromPostgres(t *testing.T) {
 	db = dbs.GetMonitoredDatabase(md.Name + "_unexpected")
 	assert.Nil(t, db)
 }
+
+func TestResolveDatabasesFromPostgres_DiscoveryFilter(t *testing.T) {
+	pgContainer, err := postgres.Run(ctx,
+		"docker.io/postgres:16-alpine",
+		postgres.WithDatabase("mydatabase"),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(5*time.Second)),
+	)
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, pgContainer.Terminate(ctx)) }()
+	_, _, err = pgContainer.Exec(ctx, []string{"psql", "-U", "postgres", "-c", "CREATE ROLE tenant; CREATE DATABASE tenant_db OWNER tenant; COMMENT ON DATABASE tenant_db IS 'pgwatch: monitored'"})
+	require.NoError(t, err)
+
+	s := sources.Source{Name: "continuous", Kind: sources.SourcePostgresContinuous}
+	s.ConnStr, err = pgContainer.ConnectionString(ctx)
+	require.NoError(t, err)
+
+	s.HostConfig.Discovery = sources.DiscoveryFilter{Owners: []string{"tenant"}}
+	dbs, err := sources.ResolveDatabasesFromPostgres(s)
+	assert.NoError(t, err)
+	assert.Len(t, dbs, 1)
+	assert.NotNil(t, dbs.GetMonitoredDatabase("continuous_tenant_db"))
+
+	s.HostConfig.Discovery = sources.DiscoveryFilter{MarkerComment: "^pgwatch:"}
+	dbs, err = sources.ResolveDatabasesFromPostgres(s)
+	assert.NoError(t, err)
+	assert.Len(t, dbs, 1)
+
+	s.HostConfig.Discovery = sources.DiscoveryFilter{MinSizeMB: 1024}
+	dbs, err = sources.ResolveDatabasesFromPostgres(s)
+	assert.NoError(t, err)
+	assert.Empty(t, dbs)
+
+	s.HostConfig.Discovery = sources.DiscoveryFilter{
+		MarkerExtension: "plpgsql",
+		Overrides:       []sources.DiscoveryOverride{{DBNamePattern: "^tenant_", PresetMetrics: "basic"}},
+	}
+	dbs, err = sources.ResolveDatabasesFromPostgres(s)
+	assert.NoError(t, err)
+	assert.Len(t, dbs, 3) // postgres, mydatabase and tenant_db
+	assert.Equal(t, "basic", dbs.GetMonitoredDatabase("continuous_tenant_db").PresetMetrics)
+
+	s.HostConfig.Discovery = sources.DiscoveryFilter{MarkerExtension: "pg_stat_statements"}
+	dbs, err = sources.ResolveDatabasesFromPostgres(s)
+	assert.NoError(t, err)
+	assert.Empty(t, dbs)
+}
+
+func TestSource_DiscoveredName(t *testing.T) {
+	s := sources.Source{Name: "prod"}
+	vars := map[string]string{"name": "prod", "cluster": "batman", "role": "replica", "dbname": "shop"}
+	assert.Equal(t, "prod_shop", s.DiscoveredName("prod_shop", vars), "default name without a template")
+
+	s.HostConfig.NameTemplate = "{cluster}_{role}_{dbname}"
+	assert.Equal(t, "batman_replica_shop", s.DiscoveredName("prod_shop", vars))
+
+	s.HostConfig.NameTemplate = "{name}-{host}-{dbname}-{unknown}"
+	assert.Equal(t, "prod--shop-{unknown}", s.DiscoveredName("prod_shop", vars))
+}
+
+func TestSources_ResolveDatabases_ServerTags(t *testing.T) {
+	pgContainer, err := postgres.Run(ctx,
+		"docker.io/postgres:16-alpine",
+		postgres.WithDatabase("mydatabase"),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(5*time.Second)),
+	)
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, pgContainer.Terminate(ctx)) }()
+	_, _, err = pgContainer.Exec(ctx, []string{"psql", "-U", "postgres", "-d", "mydatabase", "-c",
+		`COMMENT ON DATABASE mydatabase IS '{"team": "billing", "env": "dev"}';
+		CREATE SCHEMA pgwatch; CREATE TABLE pgwatch.tags(key text primary key, value text); INSERT INTO pgwatch.tags VALUES ('team', 'shop')`})
+	require.NoError(t, err)
+
+	s := sources.Source{Name: "db", Kind: sources.SourcePostgres, IsEnabled: true, CustomTags: map[string]string{"env": "prod"}}
+	s.ConnStr, err = pgContainer.ConnectionString(ctx)
+	require.NoError(t, err)
+
+	s.HostConfig.ServerTags = sources.ServerTagsComment
+	dbs, err := sources.Sources{s}.ResolveDatabases()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "billing", "env": "prod"}, dbs[0].CustomTags, "configured tags take precedence")
+
+	s.HostConfig.ServerTags = sources.ServerTagsTable
+	dbs, err = sources.Sources{s}.ResolveDatabases()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "shop", "env": "prod"}, dbs[0].CustomTags)
+}
+
+func TestMonitoredDatabase_ApplyServerTags(t *testing.T) {
+	md := &sources.MonitoredDatabase{Source: sources.Source{Name: "db", CustomTags: map[string]string{"env": "prod"}}}
+	md.MergeServerTags(map[string]string{"env": "dev", "team": "billing"})
+	assert.Equal(t, map[string]string{"env": "prod", "team": "billing"}, md.CustomTags)
+
+	md = &sources.MonitoredDatabase{}
+	md.MergeServerTags(map[string]string{"team": "billing"})
+	assert.Equal(t, map[string]string{"team": "billing"}, md.CustomTags)
+
+	// failures keep the configured tags
+	md = &sources.MonitoredDatabase{Source: sources.Source{Name: "db", ConnStr: "postgres://localhost:1/db?connect_timeout=1",
+		CustomTags: map[string]string{"env": "prod"}}}
+	md.HostConfig.ServerTags = "unknown"
+	md.ApplyServerTags(ctx)
+	assert.Equal(t, map[string]string{"env": "prod"}, md.CustomTags)
+	md.HostConfig.ServerTags = sources.ServerTagsComment
+	md.ApplyServerTags(ctx)
+	assert.Equal(t, map[string]string{"env": "prod"}, md.CustomTags)
+}
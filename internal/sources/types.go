@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"maps"
 	"reflect"
+	"regexp"
 	"slices"
 
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/db"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/faults"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -61,6 +63,11 @@ type (
 		CustomTags           map[string]string  `yaml:"custom_tags" db:"custom_tags"`
 		HostConfig           HostConfigAttrs    `yaml:"host_config" db:"host_config"`
 		OnlyIfMaster         bool               `yaml:"only_if_master" db:"only_if_master"`
+		ClusterName          string             `yaml:"cluster_name" db:"cluster_name"`
+		Owner                string             `yaml:"owner" db:"owner"`
+		Team                 string             `yaml:"team" db:"team"`
+		Contact              string             `yaml:"contact" db:"contact"`
+		Notes                string             `yaml:"notes" db:"notes"`
 	}
 
 	Sources []Source
@@ -102,13 +109,42 @@ func (s *Source) Clone() *Source {
 type (
 	MonitoredDatabase struct {
 		Source
-		Conn       db.PgxPoolIface
-		ConnConfig *pgxpool.Config
+		Conn               db.PgxPoolIface
+		ConnConfig         *pgxpool.Config
+		StandbyMetricsTo   string // designated replica fetching the standby metrics of this Patroni primary
+		StandbyMetricsFrom string // Patroni primary whose standby metrics this replica fetches
 	}
 
 	MonitoredDatabases []*MonitoredDatabase
 )
 
+// ClusterNameTag is the tag added to all measurements of the members of a logical HA cluster
+const ClusterNameTag = "cluster_name"
+
+// SetClusterNameTag tags the monitored database with its cluster name, so that the primary
+// and the standbys of the same HA cluster can be aggregated in dashboards
+func (md *MonitoredDatabase) SetClusterNameTag() {
+	if md.ClusterName == "" {
+		return
+	}
+	if md.CustomTags == nil {
+		md.CustomTags = make(map[string]string, 1)
+	}
+	md.CustomTags[ClusterNameTag] = md.ClusterName
+}
+
+// OwnershipTags returns the owner, team and contact tags of the source set, so that the alert receivers know
+// whom to contact about the database
+func (s *Source) OwnershipTags() map[string]string {
+	tags := make(map[string]string, 3)
+	for tag, value := range map[string]string{"owner": s.Owner, "team": s.Team, "contact": s.Contact} {
+		if value > "" {
+			tags[tag] = value
+		}
+	}
+	return tags
+}
+
 // Ping will try to establish a brand new connection to the server and return any error
 func (md *MonitoredDatabase) Ping(ctx context.Context) error {
 	c, err := pgx.Connect(ctx, md.ConnStr)
@@ -121,13 +157,14 @@ func (md *MonitoredDatabase) Ping(ctx context.Context) error {
 
 // Connect will establish a connection to the database if it's not already connected.
 // If the connection is already established, it pings the server to ensure it's still alive.
-func (md *MonitoredDatabase) Connect(ctx context.Context, opts CmdOpts) (err error) {
+// Callbacks are applied to the pool config of a new connection only.
+func (md *MonitoredDatabase) Connect(ctx context.Context, opts CmdOpts, callbacks ...db.ConnConfigCallback) (err error) {
 	if md.Conn == nil {
 		if md.ConnConfig != nil {
 			md.ConnConfig.MaxConns = int32(opts.MaxParallelConnectionsPerDb)
-			md.Conn, err = db.NewWithConfig(ctx, md.ConnConfig)
+			md.Conn, err = db.NewWithConfig(ctx, md.ConnConfig, callbacks...)
 		} else {
-			md.Conn, err = db.New(ctx, md.ConnStr)
+			md.Conn, err = db.New(ctx, md.ConnStr, callbacks...)
 		}
 		if err != nil {
 			return err
@@ -176,6 +213,9 @@ func (mds MonitoredDatabases) GetMonitoredDatabase(DBUniqueName string) *Monitor
 // Any resolution errors will be returned, e.g. etcd unavailability.
 // It's up to the caller to proceed with the databases available or stop the execution due to errors.
 func (mds MonitoredDatabases) SyncFromReader(r Reader) (newmds MonitoredDatabases, err error) {
+	if err = faults.ConfigError(); err != nil {
+		return nil, err
+	}
 	srcs, err := r.GetSources()
 	if err != nil {
 		return nil, err
@@ -212,6 +252,90 @@ type HostConfigAttrs struct {
 	LogsGlobPath           string                             `yaml:"logs_glob_path"`   // default $data_directory / $log_directory / *.csvlog
 	LogsMatchRegex         string                             `yaml:"logs_match_regex"` // default is for CSVLOG format. needs to capture following named groups: log_time, user_name, database_name and error_severity
 	PerMetricDisabledTimes []HostConfigPerMetricDisabledTimes `yaml:"per_metric_disabled_intervals"`
+	ExecEnv                string                             `yaml:"exec_env"` // overrides automatic execution environment detection, e.g. AWS_AURORA
+	Discovery              DiscoveryFilter                    `yaml:"discovery"`
+	NameTemplate           string                             `yaml:"name_template"`         // unique name of discovered databases, e.g. "{cluster}_{role}_{dbname}"
+	ScopeIncludePattern    string                             `yaml:"scope_include_pattern"` // regex to filter Patroni clusters for patroni-namespace-discovery
+	ScopeExcludePattern    string                             `yaml:"scope_exclude_pattern"`
+	ServerTags             string                             `yaml:"server_tags"`     // read additional custom tags from the monitored DB: "comment" or "table"
+	StandbyMetrics         []string                           `yaml:"standby_metrics"` // read-heavy metrics of Patroni primaries fetched from a designated replica
+	Canary                 bool                               `yaml:"canary"`          // opt-in write/read round trip on the pgwatch_canary.canary table
+	OverloadGuard          OverloadGuard                      `yaml:"overload_guard"`
+	TLSPolicy              string                             `yaml:"tls_policy"`       // how to treat connections weaker than the sslmode asks for, see TLSPolicy*
+	DiskCapacityGB         float64                            `yaml:"disk_capacity_gb"` // space available to the database, for the days until full of the growth_forecast metric
+	ViaPgBouncer           bool                               `yaml:"via_pgbouncer"`    // conn_str points to a PgBouncer pooled database in transaction pooling mode
+}
+
+// TLS policies of the monitoring connections, the default is to connect however the sslmode allows
+const (
+	TLSPolicyRequireVerify     = "require-verify"      // refuse to connect unless the server certificate is verified
+	TLSPolicyPreferWithWarning = "prefer-with-warning" // allow the plaintext fallback of sslmode=prefer but warn about it
+	TLSPolicyDisallowDowngrade = "disallow-downgrade"  // never fall back to plaintext if TLS was asked for
+)
+
+// OverloadGuard skips the bulk metrics while the monitored server is above any of the thresholds
+type OverloadGuard struct {
+	MaxActiveBackends int     `yaml:"max_active_backends"` // active backends in pg_stat_activity
+	MaxLoadAvg        float64 `yaml:"max_load_avg"`        // 1 minute load average, needs the get_load_average() helper
+}
+
+// Enabled returns true if any threshold is set
+func (g OverloadGuard) Enabled() bool {
+	return g.MaxActiveBackends > 0 || g.MaxLoadAvg > 0
+}
+
+// IsScopeIncluded checks the Patroni cluster name against the scope include and exclude patterns
+func (c HostConfigAttrs) IsScopeIncluded(scope string) (bool, error) {
+	if c.ScopeIncludePattern > "" {
+		if matched, err := regexp.MatchString(c.ScopeIncludePattern, scope); err != nil || !matched {
+			return false, err
+		}
+	}
+	if c.ScopeExcludePattern > "" {
+		matched, err := regexp.MatchString(c.ScopeExcludePattern, scope)
+		return !matched && err == nil, err
+	}
+	return true, nil
+}
+
+// DiscoveryFilter narrows down the databases found by postgres-continuous-discovery sources
+// in addition to the include and exclude name patterns
+type DiscoveryFilter struct {
+	Owners          []string            `yaml:"owners"`           // only databases owned by one of the listed roles
+	MinSizeMB       int64               `yaml:"min_size_mb"`      // smaller databases are ignored until they reach the threshold
+	MarkerComment   string              `yaml:"marker_comment"`   // regex the database comment must match
+	MarkerExtension string              `yaml:"marker_extension"` // extension that must be installed in the database
+	Overrides       []DiscoveryOverride `yaml:"overrides"`
+}
+
+// DiscoveryOverride replaces the metrics configuration of the discovered databases matching the name pattern
+type DiscoveryOverride struct {
+	DBNamePattern string             `yaml:"dbname_pattern"`
+	PresetMetrics string             `yaml:"preset_metrics"`
+	Metrics       map[string]float64 `yaml:"custom_metrics"`
+	CustomTags    map[string]string  `yaml:"custom_tags"` // merged with the source custom tags
+}
+
+// ApplyOverrides updates the metrics configuration of the discovered database with all matching overrides
+func (f DiscoveryFilter) ApplyOverrides(s *Source, dbname string) error {
+	for _, o := range f.Overrides {
+		matched, err := regexp.MatchString(o.DBNamePattern, dbname)
+		if err != nil {
+			return fmt.Errorf("invalid discovery override pattern %q: %w", o.DBNamePattern, err)
+		}
+		if !matched {
+			continue
+		}
+		if o.PresetMetrics > "" || len(o.Metrics) > 0 {
+			s.PresetMetrics = o.PresetMetrics
+			s.Metrics = maps.Clone(o.Metrics)
+		}
+		if len(o.CustomTags) > 0 && s.CustomTags == nil {
+			s.CustomTags = make(map[string]string, len(o.CustomTags))
+		}
+		maps.Copy(s.CustomTags, o.CustomTags)
+	}
+	return nil
 }
 
 type HostConfigPerMetricDisabledTimes struct { // metric gathering override per host / metric / time
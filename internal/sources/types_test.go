@@ -116,6 +116,23 @@ func TestMonitoredDatabase_IsPostgresSource(t *testing.T) {
 	assert.True(t, md.IsPostgresSource(), "IsPostgresSource() = false, want true")
 }
 
+func TestMonitoredDatabase_SetClusterNameTag(t *testing.T) {
+	md := &sources.MonitoredDatabase{}
+	md.SetClusterNameTag()
+	assert.Nil(t, md.CustomTags, "no cluster name, no tag")
+
+	md.ClusterName = "sales"
+	md.CustomTags = map[string]string{"env": "prod"}
+	md.SetClusterNameTag()
+	assert.Equal(t, map[string]string{"env": "prod", sources.ClusterNameTag: "sales"}, md.CustomTags)
+}
+
+func TestSource_OwnershipTags(t *testing.T) {
+	s := sources.Source{Owner: "alice", Contact: "#billing-oncall", Notes: "not a tag"}
+	assert.Equal(t, map[string]string{"owner": "alice", "contact": "#billing-oncall"}, s.OwnershipTags())
+	assert.Empty(t, (&sources.Source{}).OwnershipTags())
+}
+
 type testSourceReader struct {
 	sources.Sources
 	error
@@ -169,3 +186,28 @@ func TestMonitoredDatabases_SyncFromReader(t *testing.T) {
 	assert.NotEqual(t, mdbs[0].Name, newmdbs[0].Name)
 	assert.Nil(t, newmdbs[0].Conn)
 }
+
+func TestDiscoveryFilter_ApplyOverrides(t *testing.T) {
+	f := sources.DiscoveryFilter{Overrides: []sources.DiscoveryOverride{
+		{DBNamePattern: "^tenant_", PresetMetrics: "basic", CustomTags: map[string]string{"tier": "tenant"}},
+		{DBNamePattern: "^tenant_vip", Metrics: map[string]float64{"db_stats": 30}},
+	}}
+
+	s := sources.Source{PresetMetrics: "exhaustive", CustomTags: map[string]string{"env": "prod"}}
+	assert.NoError(t, f.ApplyOverrides(&s, "tenant_vip_1"))
+	assert.Equal(t, "", s.PresetMetrics, "later overrides win")
+	assert.Equal(t, map[string]float64{"db_stats": 30}, s.Metrics)
+	assert.Equal(t, map[string]string{"env": "prod", "tier": "tenant"}, s.CustomTags)
+
+	s = sources.Source{PresetMetrics: "exhaustive"}
+	assert.NoError(t, f.ApplyOverrides(&s, "tenant_2"))
+	assert.Equal(t, "basic", s.PresetMetrics)
+	assert.Equal(t, map[string]string{"tier": "tenant"}, s.CustomTags)
+
+	s = sources.Source{PresetMetrics: "exhaustive"}
+	assert.NoError(t, f.ApplyOverrides(&s, "postgres"))
+	assert.Equal(t, "exhaustive", s.PresetMetrics, "non matching databases are left untouched")
+
+	f.Overrides[0].DBNamePattern = "("
+	assert.Error(t, f.ApplyOverrides(&s, "postgres"))
+}
@@ -3,10 +3,15 @@ package sources
 // This file contains the implementation of the ReaderWriter interface for the YAML file.
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
 	"slices"
 	"strings"
 
@@ -14,18 +19,32 @@ import (
 )
 
 func NewYAMLSourcesReaderWriter(ctx context.Context, path string) (ReaderWriter, error) {
+	return NewEncryptedYAMLSourcesReaderWriter(ctx, path, nil)
+}
+
+// KeyFunc returns the age identities or the passphrase to decrypt the encrypted files
+type KeyFunc func(ctx context.Context) (string, error)
+
+// NewEncryptedYAMLSourcesReaderWriter returns the reader writer of the YAML files, the age encrypted ones
+// are decrypted with the key returned by key on every read. Encrypted files are read-only
+func NewEncryptedYAMLSourcesReaderWriter(ctx context.Context, path string, key KeyFunc) (ReaderWriter, error) {
 	return &fileSourcesReaderWriter{
 		ctx:  ctx,
 		path: path,
+		key:  key,
 	}, nil
 }
 
 type fileSourcesReaderWriter struct {
 	ctx  context.Context
 	path string
+	key  KeyFunc
 }
 
 func (fcr *fileSourcesReaderWriter) WriteSources(mds Sources) error {
+	if data, err := os.ReadFile(fcr.path); err == nil && isAgeEncrypted(data) {
+		return errors.New("the sources file is encrypted, edit it with age instead")
+	}
 	yamlData, _ := yaml.Marshal(mds)
 	return os.WriteFile(fcr.path, yamlData, 0644)
 }
@@ -59,6 +78,7 @@ func (fcr *fileSourcesReaderWriter) GetSources() (dbs Sources, err error) {
 	if fi, err = os.Stat(fcr.path); err != nil {
 		return
 	}
+	var positions []string // file:line of the sources, same order
 	switch mode := fi.Mode(); {
 	case mode.IsDir():
 		err = filepath.WalkDir(fcr.path, func(path string, d fs.DirEntry, err error) error {
@@ -69,34 +89,105 @@ func (fcr *fileSourcesReaderWriter) GetSources() (dbs Sources, err error) {
 			if d.IsDir() || !strings.HasSuffix(name, ".yaml") && !strings.HasSuffix(name, ".yml") {
 				return nil
 			}
-			var mdbs Sources
-			if mdbs, err = fcr.getSources(path); err == nil {
-				dbs = append(dbs, mdbs...)
-			}
+			mdbs, pos, err := fcr.getSources(path)
+			dbs = append(dbs, mdbs...)
+			positions = append(positions, pos...)
 			return err
 		})
 	case mode.IsRegular():
-		dbs, err = fcr.getSources(fcr.path)
+		dbs, positions, err = fcr.getSources(fcr.path)
 	}
 	if err != nil {
 		return nil, err
 	}
+	defined := make(map[string]string, len(dbs))
+	for i, md := range dbs {
+		if pos, ok := defined[md.Name]; ok {
+			return nil, fmt.Errorf("%s: duplicate source with name '%s' found, already defined at %s", positions[i], md.Name, pos)
+		}
+		defined[md.Name] = positions[i]
+	}
 	return dbs.Validate()
 }
 
-func (fcr *fileSourcesReaderWriter) getSources(configFilePath string) (dbs Sources, err error) {
+// yamlLineError matches the position of the YAML syntax and type errors, e.g. "yaml: line 3: did not find expected key"
+var yamlLineError = regexp.MustCompile(`^(?:yaml: )?line (\d+): `)
+
+// positionedError returns the YAML decoding error with the file:line prefix of every failure
+func positionedError(configFilePath string, err error) error {
+	var msgs []string
+	if te := (*yaml.TypeError)(nil); errors.As(err, &te) {
+		msgs = te.Errors
+	} else {
+		msgs = []string{err.Error()}
+	}
+	for i, msg := range msgs {
+		if m := yamlLineError.FindStringSubmatch(msg); m != nil {
+			msgs[i] = configFilePath + ":" + m[1] + ": " + msg[len(m[0]):]
+		} else {
+			msgs[i] = configFilePath + ": " + strings.TrimPrefix(msg, "yaml: ")
+		}
+	}
+	return errors.New(strings.Join(msgs, "\n"))
+}
+
+// getSources reads all the YAML documents of the file, every one a list of sources. Anchors and aliases,
+// incl. the merge key, e.g. "<<: *defaults", are resolved within a document
+func (fcr *fileSourcesReaderWriter) getSources(configFilePath string) (dbs Sources, positions []string, err error) {
 	var yamlFile []byte
 	if yamlFile, err = os.ReadFile(configFilePath); err != nil {
 		return
 	}
-	c := make(Sources, 0) // there can be multiple configs in a single file
-	if err = yaml.Unmarshal(yamlFile, &c); err != nil {
+	if yamlFile, err = fcr.decrypt(configFilePath, yamlFile); err != nil {
 		return
 	}
-	for _, v := range c {
-		dbs = append(dbs, fcr.expandEnvVars(v))
+	dec := yaml.NewDecoder(bytes.NewReader(yamlFile))
+	for {
+		var doc yaml.Node
+		if err = dec.Decode(&doc); errors.Is(err, io.EOF) {
+			return dbs, positions, nil
+		} else if err != nil {
+			return nil, nil, positionedError(configFilePath, err)
+		}
+		c := make(Sources, 0) // there can be multiple configs in a single document
+		if err = doc.Decode(&c); err != nil {
+			return nil, nil, positionedError(configFilePath, err)
+		}
+		var items []*yaml.Node
+		if len(doc.Content) > 0 {
+			items = doc.Content[0].Content
+		}
+		for i, v := range c {
+			dbs = append(dbs, fcr.expandEnvVars(v))
+			pos := configFilePath
+			if i < len(items) {
+				pos = fmt.Sprintf("%s:%d", configFilePath, items[i].Line)
+			}
+			positions = append(positions, pos)
+		}
+	}
+}
+
+// decrypt returns the content of the age encrypted file decrypted, other files as is
+func (fcr *fileSourcesReaderWriter) decrypt(configFilePath string, data []byte) ([]byte, error) {
+	if !isAgeEncrypted(data) {
+		return data, nil
+	}
+	if fcr.key == nil {
+		return nil, fmt.Errorf("%s: encrypted, but no decryption key available", configFilePath)
+	}
+	key, err := fcr.key(fcr.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", configFilePath, err)
+	}
+	ids, err := parseAgeIdentities(key)
+	if err == nil {
+		data, err = decryptAge(data, ids)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: cannot decrypt: %w", configFilePath, err)
 	}
-	return
+	return data, nil
 }
 
 func (fcr *fileSourcesReaderWriter) expandEnvVars(md Source) Source {
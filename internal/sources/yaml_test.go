@@ -119,6 +119,61 @@ func TestYAMLGetMonitoredDatabases(t *testing.T) {
 	})
 }
 
+func TestYAMLMultiDocumentAnchors(t *testing.T) {
+	a := assert.New(t)
+	tmpFile := filepath.Join(t.TempDir(), "sources.yaml")
+	a.NoError(os.WriteFile(tmpFile, []byte(`
+- &defaults
+  name: test1
+  conn_str: postgresql://localhost/test1
+  preset_metrics: basic
+  custom_tags: &tags
+    env: prod
+- <<: *defaults
+  name: test2
+  conn_str: postgresql://localhost/test2
+---
+- name: test3
+  conn_str: postgresql://localhost/test3
+  custom_tags:
+    env: test
+---
+`), 0644))
+	yamlrw, err := sources.NewYAMLSourcesReaderWriter(ctx, tmpFile)
+	a.NoError(err)
+
+	dbs, err := yamlrw.GetSources()
+	a.NoError(err)
+	a.Len(dbs, 3, "all documents are read")
+	a.Equal("basic", dbs[1].PresetMetrics, "merge key is resolved")
+	a.Equal("postgresql://localhost/test2", dbs[1].ConnStr, "merged keys are overridden")
+	a.Equal(map[string]string{"env": "prod"}, dbs[1].CustomTags)
+	a.Equal("test3", dbs[2].Name)
+}
+
+func TestYAMLErrorPositions(t *testing.T) {
+	a := assert.New(t)
+	tmpDir := t.TempDir()
+	read := func(content string) error {
+		tmpFile := filepath.Join(tmpDir, "sources.yaml")
+		a.NoError(os.WriteFile(tmpFile, []byte(content), 0644))
+		yamlrw, err := sources.NewYAMLSourcesReaderWriter(ctx, tmpFile)
+		a.NoError(err)
+		_, err = yamlrw.GetSources()
+		return err
+	}
+	file := filepath.Join(tmpDir, "sources.yaml")
+
+	err := read("- name: test1\n  custom_metrics:\n    db_stats: often\n")
+	a.EqualError(err, file+":3: cannot unmarshal !!str `often` into float64")
+
+	err = read("- name: test1\n---\n- name: test2\n  conn_str: [\n")
+	a.EqualError(err, file+":4: did not find expected node content", "syntax errors are positioned in the later documents too")
+
+	err = read("- name: test1\n  conn_str: postgresql://localhost/test1\n---\n- name: test1\n  conn_str: postgresql://localhost/test1\n")
+	a.EqualError(err, file+":4: duplicate source with name 'test1' found, already defined at "+file+":1")
+}
+
 func TestYAMLDeleteDatabase(t *testing.T) {
 	a := assert.New(t)
 
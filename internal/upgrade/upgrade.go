@@ -0,0 +1,243 @@
+// Package upgrade checks a Postgres database for the blockers and pitfalls of a major version upgrade
+// with pg_upgrade, e.g. removed extensions, data types and settings
+package upgrade
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/db"
+	"github.com/jackc/pgx/v5"
+)
+
+// Severities of the findings
+const (
+	SeverityBlocker = "blocker" // the upgrade fails or the upgraded server does not start
+	SeverityWarning = "warning" // needs attention before or after the upgrade
+)
+
+// LongTransactionAge is the age of the open transactions reported, they delay the shutdown of the old server
+const LongTransactionAge = 5 * time.Minute
+
+// removedExtensions are the contrib extensions removed in a major version, [name]=version
+var removedExtensions = map[string]int{
+	"tsearch2":     100000,
+	"chkpass":      110000,
+	"adminpack":    170000,
+	"old_snapshot": 170000,
+}
+
+// removedSettings are the settings removed or renamed in a major version, [name]=version. A server does not
+// start with an unknown setting in postgresql.conf
+var removedSettings = map[string]int{
+	"checkpoint_segments":               90500,
+	"wal_keep_segments":                 130000,
+	"operator_precedence_warning":       140000,
+	"vacuum_cleanup_index_scale_factor": 140000,
+	"stats_temp_directory":              150000,
+	"force_parallel_mode":               160000,
+	"promote_trigger_file":              160000,
+	"vacuum_defer_cleanup_age":          160000,
+	"db_user_namespace":                 170000,
+	"old_snapshot_threshold":            170000,
+	"trace_recovery_messages":           170000,
+}
+
+// changedTypes are the data types pg_upgrade refuses in user columns when upgrading from a version before the
+// given one, as they were removed or their on-disk format changed, [name]=version
+var changedTypes = map[string]int{
+	"unknown":        100000,
+	"abstime":        120000,
+	"reltime":        120000,
+	"tinterval":      120000,
+	"sql_identifier": 120000,
+	"aclitem":        160000,
+}
+
+// regTypes are the reg* data types referencing system OIDs not preserved by pg_upgrade. regclass, regrole and
+// regtype are missing, as their OIDs are preserved
+var regTypes = []string{"regcollation", "regconfig", "regdictionary", "regnamespace", "regoper", "regoperator",
+	"regproc", "regprocedure"}
+
+// Finding is an upgrade issue of the database
+type Finding struct {
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	Object   string `json:"object"`
+	Detail   string `json:"detail"`
+}
+
+// String returns the finding as "check: object, detail"
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s, %s", f.Check, f.Object, f.Detail)
+}
+
+// check returns the findings of an upgrade from version to target, both as server_version_num
+type check func(ctx context.Context, conn db.PgxIface, version, target int) ([]Finding, error)
+
+// checks are run in order
+var checks = []struct {
+	name string
+	fn   check
+}{
+	{"extensions", checkExtensions},
+	{"data_types", checkDataTypes},
+	{"settings", checkSettings},
+	{"replication_slots", checkReplicationSlots},
+	{"transactions", checkTransactions},
+}
+
+// Run checks the database for an upgrade to the target major version, 10 or later
+func Run(ctx context.Context, conn db.PgxIface, targetMajor int) (findings []Finding, err error) {
+	var version int
+	if err = conn.QueryRow(ctx, "select current_setting('server_version_num')::int").Scan(&version); err != nil {
+		return nil, err
+	}
+	target := targetMajor * 10000
+	if target <= version-version%10000 {
+		return nil, fmt.Errorf("target version %d is not newer than the server version %d", targetMajor, version/10000)
+	}
+	for _, c := range checks {
+		f, err := c.fn(ctx, conn, version, target)
+		if err != nil {
+			return findings, fmt.Errorf("%s check failed: %w", c.name, err)
+		}
+		findings = append(findings, f...)
+	}
+	return findings, nil
+}
+
+// removedIn returns the names removed after version up to target
+func removedIn(names map[string]int, version, target int) []string {
+	removed := make([]string, 0)
+	for _, name := range slices.Sorted(maps.Keys(names)) {
+		if v := names[name]; v > version && v <= target {
+			removed = append(removed, name)
+		}
+	}
+	return removed
+}
+
+func checkExtensions(ctx context.Context, conn db.PgxIface, version, target int) (findings []Finding, err error) {
+	rows, err := conn.Query(ctx, `select e.extname::text, e.extversion, coalesce(a.default_version, '')
+		from pg_extension e left join pg_available_extensions a on a.name = e.extname
+		order by 1`)
+	if err != nil {
+		return nil, err
+	}
+	removed := removedIn(removedExtensions, version, target)
+	var name, installed, available string
+	_, err = pgx.ForEachRow(rows, []any{&name, &installed, &available}, func() error {
+		switch {
+		case slices.Contains(removed, name):
+			findings = append(findings, Finding{"extensions", SeverityBlocker, name,
+				fmt.Sprintf("removed in version %d, drop the extension", removedExtensions[name]/10000)})
+		case available != "" && installed != available:
+			findings = append(findings, Finding{"extensions", SeverityWarning, name,
+				fmt.Sprintf("version %s installed, but %s available, run ALTER EXTENSION %s UPDATE", installed, available, name)})
+		}
+		return nil
+	})
+	return
+}
+
+func checkDataTypes(ctx context.Context, conn db.PgxIface, version, target int) (findings []Finding, err error) {
+	types := append(removedIn(changedTypes, version, target), regTypes...)
+	rows, err := conn.Query(ctx, `select quote_ident(n.nspname) || '.' || quote_ident(c.relname) || '.' || quote_ident(a.attname),
+		  t.typname::text
+		from pg_attribute a
+		  join pg_class c on c.oid = a.attrelid
+		  join pg_namespace n on n.oid = c.relnamespace
+		  join pg_type t on t.oid = a.atttypid or t.typarray = a.atttypid
+		where c.relkind in ('r', 'm', 'p') and a.attnum > 0 and not a.attisdropped
+		  and n.nspname not in ('pg_catalog', 'information_schema') and n.nspname not like 'pg\_toast%'
+		  and t.typname = any($1)
+		order by 1`, types)
+	if err != nil {
+		return nil, err
+	}
+	var column, typ string
+	_, err = pgx.ForEachRow(rows, []any{&column, &typ}, func() error {
+		if slices.Contains(regTypes, typ) {
+			findings = append(findings, Finding{"data_types", SeverityBlocker, column,
+				fmt.Sprintf("%s references OIDs not preserved by pg_upgrade, change the column type, e.g. to text", typ)})
+		} else {
+			findings = append(findings, Finding{"data_types", SeverityBlocker, column,
+				fmt.Sprintf("%s is not supported by pg_upgrade to version %d, change the column type", typ, target/10000)})
+		}
+		return nil
+	})
+	return
+}
+
+func checkSettings(ctx context.Context, conn db.PgxIface, version, target int) (findings []Finding, err error) {
+	rows, err := conn.Query(ctx, `select name::text, setting from pg_settings
+		where source not in ('default', 'override') and name = any($1)
+		order by 1`, removedIn(removedSettings, version, target))
+	if err != nil {
+		return nil, err
+	}
+	var name, setting string
+	_, err = pgx.ForEachRow(rows, []any{&name, &setting}, func() error {
+		findings = append(findings, Finding{"settings", SeverityBlocker, name,
+			fmt.Sprintf("set to %s, but removed in version %d, remove it from the configuration", setting, removedSettings[name]/10000)})
+		return nil
+	})
+	return
+}
+
+func checkReplicationSlots(ctx context.Context, conn db.PgxIface, version, _ int) (findings []Finding, err error) {
+	rows, err := conn.Query(ctx, `select slot_name::text, slot_type, active from pg_replication_slots order by 1`)
+	if err != nil {
+		return nil, err
+	}
+	var name, kind string
+	var active bool
+	_, err = pgx.ForEachRow(rows, []any{&name, &kind, &active}, func() error {
+		detail := "physical slots are not migrated by pg_upgrade, recreate it on the upgraded server"
+		if kind == "logical" {
+			detail = "logical slots are not migrated by pg_upgrade from versions before 17, recreate it and resync the subscribers"
+			if version >= 170000 {
+				detail = "logical slots are only migrated if the subscribers are caught up, stop the writes before the upgrade"
+			}
+		}
+		if !active {
+			detail = "inactive, retains WAL; " + detail
+		}
+		findings = append(findings, Finding{"replication_slots", SeverityWarning, name, detail})
+		return nil
+	})
+	return
+}
+
+func checkTransactions(ctx context.Context, conn db.PgxIface, _, _ int) (findings []Finding, err error) {
+	rows, err := conn.Query(ctx, `select gid from pg_prepared_xacts order by prepared`)
+	if err != nil {
+		return nil, err
+	}
+	var name string
+	if _, err = pgx.ForEachRow(rows, []any{&name}, func() error {
+		findings = append(findings, Finding{"transactions", SeverityBlocker, name,
+			"prepared transaction, COMMIT PREPARED or ROLLBACK PREPARED it"})
+		return nil
+	}); err != nil {
+		return
+	}
+	rows, err = conn.Query(ctx, `select pid::text, extract(epoch from now() - xact_start)::int8
+		from pg_stat_activity
+		where xact_start < now() - make_interval(secs => $1) and pid <> pg_backend_pid()
+		order by xact_start`, LongTransactionAge.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	var seconds int64
+	_, err = pgx.ForEachRow(rows, []any{&name, &seconds}, func() error {
+		findings = append(findings, Finding{"transactions", SeverityWarning, "pid " + name,
+			fmt.Sprintf("transaction open for %s, delays the shutdown before the upgrade", time.Duration(seconds)*time.Second)})
+		return nil
+	})
+	return
+}
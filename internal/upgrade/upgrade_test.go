@@ -0,0 +1,69 @@
+package upgrade
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemovedIn(t *testing.T) {
+	assert.Equal(t, []string{"adminpack", "old_snapshot"}, removedIn(removedExtensions, 160004, 170000))
+	assert.Equal(t, []string{"chkpass"}, removedIn(removedExtensions, 100000, 160000))
+	assert.Empty(t, removedIn(removedExtensions, 170000, 180000))
+	assert.NotNil(t, removedIn(removedExtensions, 170000, 180000), "query argument is never null")
+}
+
+func TestRun(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	conn.ExpectQuery("server_version_num").WillReturnRows(pgxmock.NewRows([]string{"v"}).AddRow(150008))
+	conn.ExpectQuery("from pg_extension").
+		WillReturnRows(pgxmock.NewRows([]string{"name", "installed", "available"}).
+			AddRow("pg_stat_statements", "1.9", "1.10").AddRow("plpgsql", "1.0", "1.0").AddRow("adminpack", "2.1", "2.1"))
+	conn.ExpectQuery("from pg_attribute").WithArgs(append([]string{"aclitem"}, regTypes...)).
+		WillReturnRows(pgxmock.NewRows([]string{"column", "type"}).
+			AddRow("public.t.acl", "aclitem").AddRow("public.t.fn", "regproc"))
+	conn.ExpectQuery("from pg_settings").WithArgs([]string{"force_parallel_mode", "promote_trigger_file", "vacuum_defer_cleanup_age"}).
+		WillReturnRows(pgxmock.NewRows([]string{"name", "setting"}).AddRow("vacuum_defer_cleanup_age", "1000"))
+	conn.ExpectQuery("from pg_replication_slots").
+		WillReturnRows(pgxmock.NewRows([]string{"name", "type", "active"}).AddRow("sub1", "logical", false))
+	conn.ExpectQuery("from pg_prepared_xacts").
+		WillReturnRows(pgxmock.NewRows([]string{"gid"}).AddRow("tx1"))
+	conn.ExpectQuery("from pg_stat_activity").WithArgs(LongTransactionAge.Seconds()).
+		WillReturnRows(pgxmock.NewRows([]string{"pid", "seconds"}).AddRow("42", int64(3600)))
+
+	findings, err := Run(context.Background(), conn, 16)
+	require.NoError(t, err)
+	assert.NoError(t, conn.ExpectationsWereMet())
+
+	checks := make([]string, 0, len(findings))
+	for _, f := range findings {
+		checks = append(checks, f.Severity+" "+f.Check+" "+f.Object)
+	}
+	assert.Equal(t, []string{
+		"warning extensions pg_stat_statements",
+		"blocker data_types public.t.acl",
+		"blocker data_types public.t.fn",
+		"blocker settings vacuum_defer_cleanup_age",
+		"warning replication_slots sub1",
+		"blocker transactions tx1",
+		"warning transactions pid 42",
+	}, checks, "adminpack is removed in 17 only")
+	assert.Contains(t, findings[4].Detail, "inactive")
+	assert.Contains(t, findings[6].Detail, "1h0m0s")
+}
+
+func TestRunTargetNotNewer(t *testing.T) {
+	conn, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.ExpectQuery("server_version_num").WillReturnRows(pgxmock.NewRows([]string{"v"}).AddRow(160004))
+
+	_, err = Run(context.Background(), conn, 16)
+	assert.ErrorContains(t, err, "not newer than the server version 16")
+}
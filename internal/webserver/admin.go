@@ -0,0 +1,59 @@
+package webserver
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// adminAuth lets the requests with the admin bearer token through, the token is independent from the web UI login
+// so that orchestration tooling doesn't need the admin credentials
+func (Server *WebUIServer) adminAuth(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(token), []byte(Server.WebAdminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "invalid admin token", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// SetShutdownHandler sets the function stopping the collector on the /admin/shutdown request
+func (Server *WebUIServer) SetShutdownHandler(fn func()) {
+	if Server != nil {
+		Server.shutdown.Store(&fn)
+	}
+}
+
+func (Server *WebUIServer) handleAdminReload(w http.ResponseWriter, r *http.Request) {
+	if err := Server.Refresh(); err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			http.Error(w, "no gatherer running in this process", http.StatusNotImplemented)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	Server.l.WithField("remote", r.RemoteAddr).Info("configuration reload requested via REST API")
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (Server *WebUIServer) handleAdminShutdown(w http.ResponseWriter, r *http.Request) {
+	fn := Server.shutdown.Load()
+	if fn == nil {
+		http.Error(w, "shutdown not supported", http.StatusNotImplemented)
+		return
+	}
+	Server.l.WithField("remote", r.RemoteAddr).Warning("shutdown requested via REST API")
+	w.WriteHeader(http.StatusAccepted)
+	_ = http.NewResponseController(w).Flush() // answer before the listeners are gone
+	(*fn)()
+}
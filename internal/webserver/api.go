@@ -3,8 +3,13 @@ package webserver
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
 
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/alerting"
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/db"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
 )
@@ -50,6 +55,95 @@ func (server *WebUIServer) GetMetrics() (res string, err error) {
 	return
 }
 
+// GetCompatibility returns the metric compatibility matrix of the monitored sources
+func (server *WebUIServer) GetCompatibility() (res string, err error) {
+	cr, ok := server.readyChecker.(CompatibilityReporter)
+	if !ok {
+		return "", errors.ErrUnsupported
+	}
+	b, _ := json.Marshal(cr.CompatibilityReport())
+	res = string(b)
+	return
+}
+
+// GetEffectiveConfig returns the fully resolved configuration of the collector with passwords masked
+func (server *WebUIServer) GetEffectiveConfig() (res string, err error) {
+	ecr, ok := server.readyChecker.(EffectiveConfigReporter)
+	if !ok {
+		return "", errors.ErrUnsupported
+	}
+	b, err := json.Marshal(ecr.EffectiveConfig())
+	res = log.Redact(string(b))
+	return
+}
+
+// GetStats returns the state of the collector internals and the sinks health in the given version of the schema
+func (server *WebUIServer) GetStats(version int) (res string, err error) {
+	var stats any
+	switch version {
+	case 1:
+		sr, ok := server.readyChecker.(StatsReporter)
+		if !ok {
+			return "", errors.ErrUnsupported
+		}
+		stats = sr.Stats()
+	case 2:
+		sr, ok := server.readyChecker.(StatsV2Reporter)
+		if !ok {
+			return "", errors.ErrUnsupported
+		}
+		stats = sr.StatsV2()
+	default:
+		return "", fmt.Errorf("unknown stats version %d", version)
+	}
+	b, err := json.Marshal(stats)
+	res = log.Redact(string(b))
+	return
+}
+
+// GetThroughput returns the per-minute collector throughput history
+func (server *WebUIServer) GetThroughput() (res string, err error) {
+	tr, ok := server.readyChecker.(ThroughputReporter)
+	if !ok {
+		return "", errors.ErrUnsupported
+	}
+	b, err := json.Marshal(tr.Throughput())
+	res = string(b)
+	return
+}
+
+// GetWriteStats returns the per-minute write statistics of the sinks by metric
+func (server *WebUIServer) GetWriteStats() (res string, err error) {
+	wr, ok := server.readyChecker.(WriteStatsReporter)
+	if !ok {
+		return "", errors.ErrUnsupported
+	}
+	b, err := json.Marshal(wr.WriteStats())
+	res = log.Redact(string(b))
+	return
+}
+
+// Refresh asks the main loop to re-read the sources and metric definitions immediately
+func (server *WebUIServer) Refresh() error {
+	r, ok := server.readyChecker.(Refresher)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	r.Refresh()
+	return nil
+}
+
+// GetConfigDrift returns the configuration drift of the monitored hosts
+func (server *WebUIServer) GetConfigDrift() (res string, err error) {
+	dr, ok := server.readyChecker.(ConfigDriftReporter)
+	if !ok {
+		return "", errors.ErrUnsupported
+	}
+	b, _ := json.Marshal(dr.ConfigDriftReport())
+	res = string(b)
+	return
+}
+
 // UpdateMetric updates the stored metric information
 func (server *WebUIServer) UpdateMetric(name string, params []byte) error {
 	var m metrics.Metric
@@ -90,3 +184,76 @@ func (server *WebUIServer) UpdateSource(params []byte) error {
 	}
 	return server.sourcesReaderWriter.UpdateSource(md)
 }
+
+// ForgetSource removes the stored measurements of the source now or after the embargo period
+func (server *WebUIServer) ForgetSource(name string, embargo time.Duration) error {
+	sf, ok := server.readyChecker.(SourceForgetter)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	return sf.ForgetSource(name, embargo)
+}
+
+// GetScheduledForgets returns the sources to be forgotten after the embargo period
+func (server *WebUIServer) GetScheduledForgets() (res string, err error) {
+	sf, ok := server.readyChecker.(SourceForgetter)
+	if !ok {
+		return "", errors.ErrUnsupported
+	}
+	b, err := json.Marshal(sf.ScheduledForgets())
+	res = string(b)
+	return
+}
+
+// CancelForget cancels the scheduled removal of the source data
+func (server *WebUIServer) CancelForget(name string) error {
+	sf, ok := server.readyChecker.(SourceForgetter)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	if !sf.CancelForget(name) {
+		return fmt.Errorf("no data removal scheduled for source %q", name)
+	}
+	return nil
+}
+
+// GetDowntimes returns the current and future planned maintenance downtimes
+func (server *WebUIServer) GetDowntimes() (res string, err error) {
+	dm, ok := server.readyChecker.(DowntimeManager)
+	if !ok {
+		return "", errors.ErrUnsupported
+	}
+	b, err := json.Marshal(dm.Downtimes())
+	res = string(b)
+	return
+}
+
+// AddDowntime schedules the downtime, created by the given user if known, and returns it with its ID
+func (server *WebUIServer) AddDowntime(params []byte, creator string) (res string, err error) {
+	dm, ok := server.readyChecker.(DowntimeManager)
+	if !ok {
+		return "", errors.ErrUnsupported
+	}
+	var d alerting.Downtime
+	if err = json.Unmarshal(params, &d); err != nil {
+		return
+	}
+	if creator > "" {
+		d.Creator = creator
+	}
+	if d, err = dm.AddDowntime(d); err != nil {
+		return
+	}
+	b, err := json.Marshal(d)
+	res = string(b)
+	return
+}
+
+// DeleteDowntime cancels the downtime
+func (server *WebUIServer) DeleteDowntime(id string) error {
+	dm, ok := server.readyChecker.(DowntimeManager)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	return dm.DeleteDowntime(id)
+}
@@ -11,4 +11,7 @@ type CmdOpts struct {
 	WebAddr     string `long:"web-addr" mapstructure:"web-addr" description:"TCP address in the form 'host:port' to listen on" default:":8080" env:"PW_WEBADDR"`
 	WebUser     string `long:"web-user" mapstructure:"web-user" description:"Admin login" env:"PW_WEBUSER"`
 	WebPassword string `long:"web-password" mapstructure:"web-password" description:"Admin password" env:"PW_WEBPASSWORD"`
+	WebReadOnly bool   `long:"web-readonly" mapstructure:"web-readonly" description:"Reject all requests changing the configuration or the stored measurements" env:"PW_WEBREADONLY"`
+	// WebAdminToken enables the /admin endpoints for orchestration tooling, independently of the admin login
+	WebAdminToken string `long:"web-admin-token" mapstructure:"web-admin-token" description:"Bearer token of the /admin/reload and /admin/shutdown endpoints, disabled if empty" env:"PW_WEBADMINTOKEN"`
 }
@@ -0,0 +1,35 @@
+package webserver
+
+import (
+	"net/http"
+)
+
+func (Server *WebUIServer) handleCompatibility(w http.ResponseWriter, r *http.Request) {
+	var (
+		err error
+		res string
+	)
+
+	defer func() {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}()
+
+	switch r.Method {
+	case http.MethodGet:
+		// return metric compatibility matrix of monitored sources
+		if res, err = Server.GetCompatibility(); err != nil {
+			return
+		}
+		_, err = w.Write([]byte(res))
+
+	case http.MethodOptions:
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, OPTIONS")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,67 @@
+package webserver
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/alerting"
+)
+
+func (Server *WebUIServer) handleDowntimes(w http.ResponseWriter, r *http.Request) {
+	var (
+		err    error
+		params []byte
+		res    string
+	)
+
+	defer func() {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}()
+
+	switch r.Method {
+	case http.MethodGet:
+		// return the current and future downtimes
+		if res, err = Server.GetDowntimes(); err != nil {
+			return
+		}
+		_, err = w.Write([]byte(res))
+
+	case http.MethodPost:
+		// schedule a new downtime, the logged in user is its creator
+		if params, err = io.ReadAll(r.Body); err != nil {
+			return
+		}
+		if res, err = Server.AddDowntime(params, tokenUsername(r)); errors.Is(err, errors.ErrUnsupported) {
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			err = nil
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, err = w.Write([]byte(res))
+
+	case http.MethodDelete:
+		// cancel the downtime
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "downtime id is required", http.StatusBadRequest)
+			return
+		}
+		if err = Server.DeleteDowntime(id); errors.Is(err, alerting.ErrDowntimeNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			err = nil
+		}
+
+	case http.MethodOptions:
+		w.Header().Set("Allow", "GET, POST, DELETE, OPTIONS")
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE, OPTIONS")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,57 @@
+package webserver
+
+import (
+	"net/http"
+	"time"
+)
+
+func (Server *WebUIServer) handleForgetSource(w http.ResponseWriter, r *http.Request) {
+	var (
+		err error
+		res string
+	)
+
+	defer func() {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}()
+
+	switch r.Method {
+	case http.MethodGet:
+		// return the sources waiting for the embargo period to pass
+		if res, err = Server.GetScheduledForgets(); err != nil {
+			return
+		}
+		_, err = w.Write([]byte(res))
+
+	case http.MethodPost:
+		// remove the data of the decommissioned source now or after the embargo period
+		var embargo time.Duration
+		if e := r.URL.Query().Get("embargo"); e > "" {
+			if embargo, err = time.ParseDuration(e); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				err = nil
+				return
+			}
+		}
+		if err = Server.ForgetSource(r.URL.Query().Get("name"), embargo); err != nil {
+			return
+		}
+		if embargo > 0 {
+			w.WriteHeader(http.StatusAccepted)
+		}
+
+	case http.MethodDelete:
+		// cancel the scheduled removal
+		err = Server.CancelForget(r.URL.Query().Get("name"))
+
+	case http.MethodOptions:
+		w.Header().Set("Allow", "GET, POST, DELETE, OPTIONS")
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE, OPTIONS")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -82,6 +82,23 @@ func generateJWT(username string) (string, error) {
 	return token.SignedString(sampleSecretKey)
 }
 
+// tokenUsername returns the user name of the request token, already checked by validateToken
+func tokenUsername(r *http.Request) string {
+	t := r.Header.Get("Token")
+	if t == "" {
+		t = r.URL.Query().Get("Token")
+	}
+	token, err := jwt.Parse(t, func(*jwt.Token) (interface{}, error) {
+		return sampleSecretKey, nil
+	})
+	if err != nil {
+		return ""
+	}
+	claims, _ := token.Claims.(jwt.MapClaims)
+	username, _ := claims["username"].(string)
+	return username
+}
+
 func validateToken(r *http.Request) (err error) {
 	var t string
 	if r.Header["Token"] == nil {
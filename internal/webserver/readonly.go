@@ -0,0 +1,20 @@
+package webserver
+
+import (
+	"net/http"
+	"strings"
+)
+
+// readOnlyMiddleware rejects all requests that could change the configuration or the stored measurements,
+// only the login and the /admin endpoints protected by their own token are allowed besides the reading methods
+func readOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet, r.Method == http.MethodHead, r.Method == http.MethodOptions, r.URL.Path == "/login",
+			strings.HasPrefix(r.URL.Path, "/admin/"):
+			next.ServeHTTP(w, r)
+		default:
+			http.Error(w, "web UI is in read-only mode", http.StatusForbidden)
+		}
+	})
+}
@@ -0,0 +1,32 @@
+package webserver
+
+import (
+	"net/http"
+)
+
+func (Server *WebUIServer) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	var err error
+
+	defer func() {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}()
+
+	switch r.Method {
+	case http.MethodPost:
+		// wake up the main loop to re-read sources and metric definitions
+		if err = Server.Refresh(); err != nil {
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+
+	case http.MethodOptions:
+		w.Header().Set("Allow", "POST, OPTIONS")
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "POST, OPTIONS")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
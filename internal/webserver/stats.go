@@ -0,0 +1,134 @@
+package webserver
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Media types of the stats REST API, the plain JSON one returns the default version of the endpoint
+const (
+	statsMediaTypeJSON = "application/json"
+	statsMediaTypeV1   = "application/vnd.pgwatch.stats.v1+json"
+	statsMediaTypeV2   = "application/vnd.pgwatch.stats.v2+json"
+)
+
+// negotiateStatsVersion picks the first stats version of the Accept header the endpoint supports,
+// the first of the supported versions is the default one
+func negotiateStatsVersion(accept string, supported ...int) (version int, contentType string, ok bool) {
+	if strings.TrimSpace(accept) == "" {
+		return supported[0], statsMediaTypeJSON, true
+	}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+		switch mediaType {
+		case "*/*", "application/*", statsMediaTypeJSON:
+			return supported[0], statsMediaTypeJSON, true
+		case statsMediaTypeV1:
+			version = 1
+		case statsMediaTypeV2:
+			version = 2
+		default:
+			continue
+		}
+		if slices.Contains(supported, version) {
+			return version, mediaType, true
+		}
+	}
+	return 0, "", false
+}
+
+// handleStatsVersions serves the stats in the negotiated version
+func (Server *WebUIServer) handleStatsVersions(w http.ResponseWriter, r *http.Request, supported ...int) {
+	var (
+		err error
+		res string
+	)
+
+	defer func() {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}()
+
+	switch r.Method {
+	case http.MethodGet:
+		// return the state of the collector internals and the sinks health
+		w.Header().Set("Vary", "Accept")
+		version, contentType, ok := negotiateStatsVersion(r.Header.Get("Accept"), supported...)
+		if !ok {
+			http.Error(w, fmt.Sprintf("supported media types: %s, %s or %s", statsMediaTypeJSON, statsMediaTypeV1, statsMediaTypeV2),
+				http.StatusNotAcceptable)
+			return
+		}
+		if res, err = Server.GetStats(version); err != nil {
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		_, err = w.Write([]byte(res))
+
+	case http.MethodOptions:
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, OPTIONS")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleStats serves the frozen v1 stats unless v2 is asked for explicitly
+func (Server *WebUIServer) handleStats(w http.ResponseWriter, r *http.Request) {
+	Server.handleStatsVersions(w, r, 1, 2)
+}
+
+// handleStatsV2 serves the stats in the stable v2 schema
+func (Server *WebUIServer) handleStatsV2(w http.ResponseWriter, r *http.Request) {
+	Server.handleStatsVersions(w, r, 2)
+}
+
+// handleThroughput serves the per-minute collector throughput history for charting
+func (Server *WebUIServer) handleThroughput(w http.ResponseWriter, r *http.Request) {
+	Server.handleStatsHistory(w, r, Server.GetThroughput)
+}
+
+// handleWriteStats serves the per-minute write statistics of the sinks by metric
+func (Server *WebUIServer) handleWriteStats(w http.ResponseWriter, r *http.Request) {
+	Server.handleStatsHistory(w, r, Server.GetWriteStats)
+}
+
+// handleStatsHistory serves a read only stats history returned by get
+func (Server *WebUIServer) handleStatsHistory(w http.ResponseWriter, r *http.Request, get func() (string, error)) {
+	var (
+		err error
+		res string
+	)
+
+	defer func() {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}()
+
+	switch r.Method {
+	case http.MethodGet:
+		if res, err = get(); err != nil {
+			return
+		}
+		w.Header().Set("Content-Type", statsMediaTypeJSON)
+		_, err = w.Write([]byte(res))
+
+	case http.MethodOptions:
+		w.Header().Set("Allow", "GET, OPTIONS")
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, OPTIONS")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -6,14 +6,17 @@ import (
 	"io"
 	"io/fs"
 	"mime"
-	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/alerting"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/drift"
+	"github.com/cybertec-postgresql/pgwatch/v3/internal/handover"
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/log"
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/metrics"
 	"github.com/cybertec-postgresql/pgwatch/v3/internal/sources"
@@ -23,6 +26,60 @@ type ReadyChecker interface {
 	Ready() bool
 }
 
+// CompatibilityReporter returns the metric compatibility matrix of the monitored sources
+type CompatibilityReporter interface {
+	CompatibilityReport() []metrics.CompatibilityReport
+}
+
+// ConfigDriftReporter returns the configuration drift of the monitored hosts
+type ConfigDriftReporter interface {
+	ConfigDriftReport() []drift.Report
+}
+
+// EffectiveConfigReporter returns the fully resolved configuration the collector is working with
+type EffectiveConfigReporter interface {
+	EffectiveConfig() any
+}
+
+// StatsReporter returns the state of the collector internals including the sinks health
+type StatsReporter interface {
+	Stats() any
+}
+
+// StatsV2Reporter returns the state of the collector internals in the stable, versioned schema
+type StatsV2Reporter interface {
+	StatsV2() any
+}
+
+// ThroughputReporter returns the per-minute collector throughput history
+type ThroughputReporter interface {
+	Throughput() any
+}
+
+// WriteStatsReporter returns the per-minute write statistics of the sinks by metric
+type WriteStatsReporter interface {
+	WriteStats() any
+}
+
+// Refresher triggers an immediate re-read of the sources and metric definitions
+type Refresher interface {
+	Refresh()
+}
+
+// SourceForgetter removes the stored measurements of decommissioned sources, optionally after an embargo period
+type SourceForgetter interface {
+	ForgetSource(name string, embargo time.Duration) error
+	ScheduledForgets() map[string]time.Time
+	CancelForget(name string) bool
+}
+
+// DowntimeManager schedules the planned maintenance windows silencing the alerts and the availability accounting
+type DowntimeManager interface {
+	AddDowntime(d alerting.Downtime) (alerting.Downtime, error)
+	Downtimes() []alerting.Downtime
+	DeleteDowntime(id string) error
+}
+
 type WebUIServer struct {
 	http.Server
 	CmdOpts
@@ -32,6 +89,7 @@ type WebUIServer struct {
 	metricsReaderWriter metrics.ReaderWriter
 	sourcesReaderWriter sources.ReaderWriter
 	readyChecker        ReadyChecker
+	shutdown            atomic.Pointer[func()] // stops the collector, see SetShutdownHandler
 }
 
 func Init(ctx context.Context, opts CmdOpts, webuifs fs.FS, mrw metrics.ReaderWriter, srw sources.ReaderWriter, rc ReadyChecker) (*WebUIServer, error) {
@@ -39,13 +97,17 @@ func Init(ctx context.Context, opts CmdOpts, webuifs fs.FS, mrw metrics.ReaderWr
 		return nil, nil
 	}
 	mux := http.NewServeMux()
+	var handler http.Handler = mux
+	if opts.WebReadOnly {
+		handler = readOnlyMiddleware(mux)
+	}
 	s := &WebUIServer{
 		Server: http.Server{
 			Addr:           opts.WebAddr,
 			ReadTimeout:    10 * time.Second,
 			WriteTimeout:   10 * time.Second,
 			MaxHeaderBytes: 1 << 20,
-			Handler:        corsMiddleware(mux),
+			Handler:        corsMiddleware(handler),
 		},
 		ctx:                 ctx,
 		l:                   log.GetLogger(ctx),
@@ -60,15 +122,29 @@ func Init(ctx context.Context, opts CmdOpts, webuifs fs.FS, mrw metrics.ReaderWr
 	mux.Handle("/test-connect", NewEnsureAuth(s.handleTestConnect))
 	mux.Handle("/metric", NewEnsureAuth(s.handleMetrics))
 	mux.Handle("/preset", NewEnsureAuth(s.handlePresets))
+	mux.Handle("/compatibility", NewEnsureAuth(s.handleCompatibility))
+	mux.Handle("/drift", NewEnsureAuth(s.handleDrift))
+	mux.Handle("/refresh", NewEnsureAuth(s.handleRefresh))
+	mux.Handle("/source/forget", NewEnsureAuth(s.handleForgetSource))
+	mux.Handle("/downtime", NewEnsureAuth(s.handleDowntimes))
+	mux.Handle("/effective-config", NewEnsureAuth(s.handleEffectiveConfig))
+	mux.Handle("/stats", NewEnsureAuth(s.handleStats))
+	mux.Handle("/v2/stats", NewEnsureAuth(s.handleStatsV2))
+	mux.Handle("/v2/stats/throughput", NewEnsureAuth(s.handleThroughput))
+	mux.Handle("/v2/stats/writes", NewEnsureAuth(s.handleWriteStats))
 	mux.Handle("/log", NewEnsureAuth(s.serveWsLog))
 	mux.HandleFunc("/login", s.handleLogin)
 	mux.HandleFunc("/liveness", s.handleLiveness)
 	mux.HandleFunc("/readiness", s.handleReadiness)
+	if opts.WebAdminToken > "" {
+		mux.HandleFunc("/admin/reload", s.adminAuth(s.handleAdminReload))
+		mux.HandleFunc("/admin/shutdown", s.adminAuth(s.handleAdminShutdown))
+	}
 	if opts.WebDisable != WebDisableUI {
 		mux.HandleFunc("/", s.handleStatic)
 	}
 
-	ln, err := net.Listen("tcp", s.Addr)
+	ln, err := handover.Listen(s.Addr)
 	if err != nil {
 		return nil, err
 	}
@@ -83,7 +159,7 @@ func (Server *WebUIServer) handleStatic(w http.ResponseWriter, r *http.Request)
 		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
 		return
 	}
-	routes := []string{"/", "/sources", "/metrics", "/presets", "/logs"}
+	routes := []string{"/", "/sources", "/metrics", "/presets", "/compatibility", "/drift", "/downtimes", "/logs"}
 	path := r.URL.Path
 	if slices.Contains(routes, path) {
 		path = "index.html"
@@ -1,4 +1,7 @@
 export enum QueryKeys {
+  Compatibility = "Compatibility",
+  Downtime = "Downtime",
+  Drift = "Drift",
   Metric = "Metric",
   Preset = "Preset",
   Source = "Source",
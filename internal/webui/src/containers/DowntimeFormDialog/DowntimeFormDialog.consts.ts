@@ -0,0 +1,47 @@
+import * as Yup from "yup";
+import { DowntimeRequestBody } from "types/Downtime/Downtime";
+import { DowntimeFormValues } from "./DowntimeFormDialog.types";
+
+// datetime-local inputs take the local time without the time zone
+const toLocalInput = (date: Date) => {
+  const local = new Date(date.getTime() - date.getTimezoneOffset() * 60000);
+  return local.toISOString().slice(0, 16);
+};
+
+export const getDowntimeInitialValues = (): DowntimeFormValues => {
+  const now = new Date();
+  return {
+    DBName: "",
+    Group: "",
+    Metric: "",
+    Start: toLocalInput(now),
+    End: toLocalInput(new Date(now.getTime() + 60 * 60000)),
+    Reason: "",
+  };
+};
+
+export const downtimeFormValuesValidationSchema = Yup.object({
+  DBName: Yup.string().test(
+    "scope",
+    "Database or group is required",
+    (value, context) => !!value || !!context.parent.Group,
+  ),
+  Group: Yup.string().optional(),
+  Metric: Yup.string().optional(),
+  Start: Yup.string().required("Start is required"),
+  End: Yup.string().required("End is required").test(
+    "after-start",
+    "End must be after the start",
+    (value, context) => !value || new Date(value) > new Date(context.parent.Start),
+  ),
+  Reason: Yup.string().optional(),
+});
+
+export const createDowntimeRequest = (values: DowntimeFormValues): DowntimeRequestBody => ({
+  dbname: values.DBName || undefined,
+  group: values.Group || undefined,
+  metric: values.Metric || undefined,
+  start: new Date(values.Start).toISOString(),
+  end: new Date(values.End).toISOString(),
+  reason: values.Reason,
+});
@@ -0,0 +1,97 @@
+import { useEffect } from "react";
+import { yupResolver } from "@hookform/resolvers/yup";
+import { Button, Dialog, DialogActions, DialogContent, FormControl, FormHelperText, InputLabel, OutlinedInput } from "@mui/material";
+import { SubmitHandler, useForm } from "react-hook-form";
+import { useFormStyles } from "styles/form";
+import { useAddDowntime } from "queries/Downtime";
+import { createDowntimeRequest, downtimeFormValuesValidationSchema, getDowntimeInitialValues } from "./DowntimeFormDialog.consts";
+import { DowntimeFormValues } from "./DowntimeFormDialog.types";
+
+type Props = {
+  open: boolean;
+  handleClose: () => void;
+};
+
+const fields: { name: keyof DowntimeFormValues, label: string, type?: string, helperText?: string }[] = [
+  { name: "DBName", label: "Database", helperText: "Monitored database, all databases of the group if empty" },
+  { name: "Group", label: "Group", helperText: "Source group, any group if empty" },
+  { name: "Metric", label: "Metric", helperText: "Silenced metric, all metrics if empty" },
+  { name: "Start", label: "Start", type: "datetime-local" },
+  { name: "End", label: "End", type: "datetime-local" },
+  { name: "Reason", label: "Reason" },
+];
+
+export const DowntimeFormDialog = ({ open, handleClose }: Props) => {
+  const { register, handleSubmit, reset, formState: { errors } } = useForm<DowntimeFormValues>({
+    resolver: yupResolver(downtimeFormValuesValidationSchema)
+  });
+  const { classes, cx } = useFormStyles();
+
+  useEffect(() => {
+    reset(getDowntimeInitialValues());
+  }, [open, reset]);
+
+  const addDowntime = useAddDowntime();
+
+  useEffect(() => {
+    if (addDowntime.isSuccess) {
+      handleClose();
+    }
+  }, [addDowntime.isSuccess]); // eslint-disable-line
+
+  const onSubmit: SubmitHandler<DowntimeFormValues> = (values) => {
+    addDowntime.mutate(createDowntimeRequest(values));
+  };
+
+  return (
+    <Dialog
+      open={open}
+      onClose={handleClose}
+      className={classes.formDialog}
+    >
+      <form onSubmit={handleSubmit(onSubmit)}>
+        <DialogContent>
+          <div className={cx(classes.form, classes.formContent)}>
+            {fields.map(({ name, label, type, helperText }) => (
+              <FormControl
+                key={name}
+                className={cx(classes.formControlInput, classes.widthFull)}
+                error={!!errors[name]}
+                variant="outlined"
+              >
+                <InputLabel htmlFor={name} shrink={type ? true : undefined}>{label}</InputLabel>
+                <OutlinedInput
+                  {...register(name)}
+                  id={name}
+                  label={label}
+                  type={type}
+                  notched={type ? true : undefined}
+                  aria-describedby={`${name}-error`}
+                />
+                <FormHelperText id={`${name}-error`}>{errors[name]?.message ?? helperText}</FormHelperText>
+              </FormControl>
+            ))}
+          </div>
+        </DialogContent>
+        <DialogActions className={classes.formButtons}>
+          <Button
+            onClick={handleClose}
+            size="medium"
+            variant="outlined"
+            disabled={addDowntime.isLoading}
+          >
+            Cancel
+          </Button>
+          <Button
+            type="submit"
+            size="medium"
+            variant="contained"
+            disabled={addDowntime.isLoading}
+          >
+            Add downtime
+          </Button>
+        </DialogActions>
+      </form>
+    </Dialog>
+  );
+};
@@ -0,0 +1,8 @@
+export type DowntimeFormValues = {
+  DBName: string;
+  Group: string;
+  Metric: string;
+  Start: string;
+  End: string;
+  Reason: string;
+};
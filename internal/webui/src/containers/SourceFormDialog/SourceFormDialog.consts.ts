@@ -18,6 +18,10 @@ export const getSourceInitialValues = (data?: Source): SourceFormValues => ({
   CustomTags: toArrayFromRecord(data?.CustomTags),
   IncludePattern: data?.IncludePattern ?? "",
   ExcludePattern: data?.ExcludePattern ?? "",
+  Owner: data?.Owner ?? "",
+  Team: data?.Team ?? "",
+  Contact: data?.Contact ?? "",
+  Notes: data?.Notes ?? "",
 });
 
 export const createSourceRequest = (values: SourceFormValues): Source => ({
@@ -36,4 +40,8 @@ export const createSourceRequest = (values: SourceFormValues): Source => ({
   IncludePattern: values.IncludePattern ?? "",
   ExcludePattern: values.ExcludePattern ?? "",
   HostConfig: {},
+  Owner: values.Owner ?? "",
+  Team: values.Team ?? "",
+  Contact: values.Contact ?? "",
+  Notes: values.Notes ?? "",
 });
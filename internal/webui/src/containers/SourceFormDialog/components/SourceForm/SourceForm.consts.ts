@@ -74,4 +74,8 @@ export const sourceFormValuesValidationSchema = Yup.object({
     const idx = list.findIndex((v, i) => v !== set[i]);
     return context.createError({ path: `${context.path}[${idx}].Name`, message: "Name should be unique", type: "unique" });
   }).optional().nullable(),
+  Owner: Yup.string().optional().nullable(),
+  Team: Yup.string().optional().nullable(),
+  Contact: Yup.string().optional().nullable(),
+  Notes: Yup.string().optional().nullable(),
 });
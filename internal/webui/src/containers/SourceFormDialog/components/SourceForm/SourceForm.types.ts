@@ -32,6 +32,10 @@ type SourceFormTags = {
     Name: string;
     Value: string;
   }[] | null,
+  Owner?: string | null;
+  Team?: string | null;
+  Contact?: string | null;
+  Notes?: string | null;
 };
 
 export type SourceFormValues =
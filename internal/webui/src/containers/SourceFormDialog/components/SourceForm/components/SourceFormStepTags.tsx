@@ -21,6 +21,56 @@ export const SourceFormStepTags = () => {
 
   return (
     <div className={classes.form}>
+      <div className={classes.row}>
+        <FormControl
+          className={cx(classes.formControlInput, classes.widthDefault)}
+          variant="outlined"
+        >
+          <InputLabel htmlFor="Owner">Owner</InputLabel>
+          <OutlinedInput
+            {...register("Owner")}
+            id="Owner"
+            label="Owner"
+          />
+        </FormControl>
+        <FormControl
+          className={cx(classes.formControlInput, classes.widthDefault)}
+          variant="outlined"
+        >
+          <InputLabel htmlFor="Team">Team</InputLabel>
+          <OutlinedInput
+            {...register("Team")}
+            id="Team"
+            label="Team"
+          />
+        </FormControl>
+      </div>
+      <FormControl
+        className={cx(classes.formControlInput, classes.widthDefault)}
+        variant="outlined"
+      >
+        <InputLabel htmlFor="Contact">Contact</InputLabel>
+        <OutlinedInput
+          {...register("Contact")}
+          id="Contact"
+          label="Contact"
+          aria-describedby="Contact-helper"
+        />
+        <FormHelperText id="Contact-helper">E-mail, chat channel or on-call rotation</FormHelperText>
+      </FormControl>
+      <FormControl
+        className={cx(classes.formControlInput, classes.widthFull)}
+        variant="outlined"
+      >
+        <InputLabel htmlFor="Notes">Notes</InputLabel>
+        <OutlinedInput
+          {...register("Notes")}
+          id="Notes"
+          label="Notes"
+          multiline
+          maxRows={5}
+        />
+      </FormControl>
       {fields.map(({ id }, index) => (
         <div className={classes.row} key={id}>
           <FormControl
@@ -1,3 +1,6 @@
+import { CompatibilityPage } from "pages/CompatibilityPage/CompatibilityPage";
+import { DowntimesPage } from "pages/DowntimesPage/DowntimesPage";
+import { DriftPage } from "pages/DriftPage/DriftPage";
 import { LoginPage } from "pages/LoginPage/LoginPage";
 import { LogsPage } from "pages/LogsPage/LogsPage";
 import { MetricsPage } from "pages/MetricsPage/MetricsPage";
@@ -28,6 +31,21 @@ export const privateRoutes = [
     link: "/presets",
     element: PresetsPage,
   },
+  {
+    title: "Compatibility",
+    link: "/compatibility",
+    element: CompatibilityPage,
+  },
+  {
+    title: "Drift",
+    link: "/drift",
+    element: DriftPage,
+  },
+  {
+    title: "Downtimes",
+    link: "/downtimes",
+    element: DowntimesPage,
+  },
   {
     title: "Logs",
     link: "/logs",
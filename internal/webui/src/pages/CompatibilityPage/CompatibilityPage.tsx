@@ -0,0 +1,12 @@
+import { usePageStyles } from "styles/page";
+import { CompatibilityGrid } from "./components/CompatibilityGrid/CompatibilityGrid";
+
+export const CompatibilityPage = () => {
+  const { classes } = usePageStyles();
+
+  return (
+    <div className={classes.root}>
+      <CompatibilityGrid />
+    </div>
+  );
+};
@@ -0,0 +1,36 @@
+import { GridColDef } from "@mui/x-data-grid";
+import { Compatibility } from "types/Compatibility/Compatibility";
+
+export const useCompatibilityGridColumns = (): GridColDef<Compatibility>[] => ([
+  {
+    field: "version",
+    headerName: "Version",
+    width: 100,
+    align: "center",
+    headerAlign: "center",
+  },
+  {
+    field: "sources",
+    headerName: "Sources",
+    flex: 1,
+    align: "left",
+    headerAlign: "center",
+    valueGetter: ({ row }) => row.sources?.join(", "),
+  },
+  {
+    field: "missing_metrics",
+    headerName: "Metrics without definition",
+    flex: 1,
+    align: "left",
+    headerAlign: "center",
+    valueGetter: ({ row }) => row.missing_metrics.join(", "),
+  },
+  {
+    field: "uncovered_views",
+    headerName: "Views not covered",
+    flex: 1,
+    align: "left",
+    headerAlign: "center",
+    valueGetter: ({ row }) => row.uncovered_views.join(", "),
+  },
+]);
@@ -0,0 +1,40 @@
+import { DataGrid } from "@mui/x-data-grid";
+import { Error } from "components/Error/Error";
+import { Loading } from "components/Loading/Loading";
+import { usePageStyles } from "styles/page";
+import { useCompatibility } from "queries/Compatibility";
+import { useCompatibilityGridColumns } from "./CompatibilityGrid.consts";
+
+export const CompatibilityGrid = () => {
+  const { classes } = usePageStyles();
+
+  const { data, isLoading, isError, error } = useCompatibility();
+
+  const columns = useCompatibilityGridColumns();
+
+  if (isLoading) {
+    return (
+      <Loading />
+    );
+  };
+
+  if (isError) {
+    const err = error as Error;
+    return (
+      <Error message={err.message} />
+    );
+  };
+
+  return (
+    <div className={classes.page}>
+      <DataGrid
+        getRowId={(row) => row.version}
+        columns={columns}
+        rows={data ?? []}
+        rowsPerPageOptions={[]}
+        getRowHeight={() => "auto"}
+        disableColumnMenu
+      />
+    </div>
+  );
+};
@@ -0,0 +1,12 @@
+import { usePageStyles } from "styles/page";
+import { DowntimesGrid } from "./components/DowntimesGrid/DowntimesGrid";
+
+export const DowntimesPage = () => {
+  const { classes } = usePageStyles();
+
+  return (
+    <div className={classes.root}>
+      <DowntimesGrid />
+    </div>
+  );
+};
@@ -0,0 +1,68 @@
+import { GridColDef } from "@mui/x-data-grid";
+import { Downtime } from "types/Downtime/Downtime";
+import { DowntimesGridActions } from "./components/DowntimesGridActions/DowntimesGridActions";
+
+const formatTime = (value: string) => new Date(value).toLocaleString();
+
+export const useDowntimesGridColumns = (): GridColDef<Downtime>[] => ([
+  {
+    field: "dbname",
+    headerName: "Database",
+    width: 180,
+    align: "left",
+    headerAlign: "center",
+    valueGetter: ({ row }) => row.dbname || "all",
+  },
+  {
+    field: "group",
+    headerName: "Group",
+    width: 130,
+    align: "left",
+    headerAlign: "center",
+    valueGetter: ({ row }) => row.group || "all",
+  },
+  {
+    field: "metric",
+    headerName: "Metric",
+    width: 150,
+    align: "left",
+    headerAlign: "center",
+    valueGetter: ({ row }) => row.metric || "all",
+  },
+  {
+    field: "start",
+    headerName: "Start",
+    width: 170,
+    align: "center",
+    headerAlign: "center",
+    valueGetter: ({ row }) => formatTime(row.start),
+  },
+  {
+    field: "end",
+    headerName: "End",
+    width: 170,
+    align: "center",
+    headerAlign: "center",
+    valueGetter: ({ row }) => formatTime(row.end),
+  },
+  {
+    field: "reason",
+    headerName: "Reason",
+    flex: 1,
+    align: "left",
+    headerAlign: "center",
+  },
+  {
+    field: "creator",
+    headerName: "Creator",
+    width: 120,
+    align: "left",
+    headerAlign: "center",
+  },
+  {
+    field: "Actions",
+    headerName: "Actions",
+    headerAlign: "center",
+    renderCell: ({ row }) => <DowntimesGridActions downtime={row} />
+  },
+]);
@@ -0,0 +1,45 @@
+import { useState } from "react";
+import { DataGrid } from "@mui/x-data-grid";
+import { Error } from "components/Error/Error";
+import { GridToolbar } from "components/GridToolbar/GridToolbar";
+import { Loading } from "components/Loading/Loading";
+import { DowntimeFormDialog } from "containers/DowntimeFormDialog/DowntimeFormDialog";
+import { usePageStyles } from "styles/page";
+import { useDowntimes } from "queries/Downtime";
+import { useDowntimesGridColumns } from "./DowntimesGrid.consts";
+
+export const DowntimesGrid = () => {
+  const { classes } = usePageStyles();
+  const [dialogOpen, setDialogOpen] = useState(false);
+
+  const { data, isLoading, isError, error } = useDowntimes();
+
+  const columns = useDowntimesGridColumns();
+
+  if (isLoading) {
+    return (
+      <Loading />
+    );
+  };
+
+  if (isError) {
+    const err = error as Error;
+    return (
+      <Error message={err.message} />
+    );
+  };
+
+  return (
+    <div className={classes.page}>
+      <DataGrid
+        getRowId={(row) => row.id}
+        columns={columns}
+        rows={data ?? []}
+        rowsPerPageOptions={[]}
+        components={{ Toolbar: () => <GridToolbar onNewClick={() => setDialogOpen(true)} /> }}
+        disableColumnMenu
+      />
+      <DowntimeFormDialog open={dialogOpen} handleClose={() => setDialogOpen(false)} />
+    </div>
+  );
+};
@@ -0,0 +1,37 @@
+import { useEffect, useState } from "react";
+import DeleteIcon from "@mui/icons-material/Delete";
+import { IconButton } from "@mui/material";
+import { WarningDialog } from "components/WarningDialog/WarningDialog";
+import { useDeleteDowntime } from "queries/Downtime";
+import { Downtime } from "types/Downtime/Downtime";
+
+type Props = {
+  downtime: Downtime;
+};
+
+export const DowntimesGridActions = ({ downtime }: Props) => {
+  const [dialogOpen, setDialogOpen] = useState(false);
+  const { mutate, isSuccess } = useDeleteDowntime();
+
+  const handleDialogClose = () => setDialogOpen(false);
+
+  const handleSubmit = () => mutate(downtime.id);
+
+  useEffect(() => {
+    isSuccess && handleDialogClose();
+  }, [isSuccess]);
+
+  return (
+    <>
+      <IconButton title="Cancel downtime" onClick={() => setDialogOpen(true)}>
+        <DeleteIcon />
+      </IconButton>
+      <WarningDialog
+        open={dialogOpen}
+        message={`Are you sure want to cancel the downtime "${downtime.reason}"`}
+        onClose={handleDialogClose}
+        onSubmit={handleSubmit}
+      />
+    </>
+  );
+};
@@ -0,0 +1,12 @@
+import { usePageStyles } from "styles/page";
+import { DriftGrid } from "./components/DriftGrid/DriftGrid";
+
+export const DriftPage = () => {
+  const { classes } = usePageStyles();
+
+  return (
+    <div className={classes.root}>
+      <DriftGrid />
+    </div>
+  );
+};
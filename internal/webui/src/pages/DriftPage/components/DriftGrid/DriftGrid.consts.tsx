@@ -0,0 +1,37 @@
+import { GridColDef } from "@mui/x-data-grid";
+import { Drift } from "types/Drift/Drift";
+
+export const useDriftGridColumns = (): GridColDef<Drift>[] => ([
+  {
+    field: "source",
+    headerName: "Source",
+    width: 200,
+    align: "left",
+    headerAlign: "center",
+  },
+  {
+    field: "group",
+    headerName: "Group",
+    width: 150,
+    align: "left",
+    headerAlign: "center",
+  },
+  {
+    field: "group_size",
+    headerName: "Hosts in group",
+    width: 120,
+    type: "number",
+    align: "center",
+    headerAlign: "center",
+  },
+  {
+    field: "deviations",
+    headerName: "Drifted settings",
+    flex: 1,
+    align: "left",
+    headerAlign: "center",
+    valueGetter: ({ row }) => row.deviations
+      .map((d) => `${d.setting}=${d.value} (expected: ${d.expected}, ${d.baseline})`)
+      .join(", "),
+  },
+]);
@@ -0,0 +1,40 @@
+import { DataGrid } from "@mui/x-data-grid";
+import { Error } from "components/Error/Error";
+import { Loading } from "components/Loading/Loading";
+import { usePageStyles } from "styles/page";
+import { useDrift } from "queries/Drift";
+import { useDriftGridColumns } from "./DriftGrid.consts";
+
+export const DriftGrid = () => {
+  const { classes } = usePageStyles();
+
+  const { data, isLoading, isError, error } = useDrift();
+
+  const columns = useDriftGridColumns();
+
+  if (isLoading) {
+    return (
+      <Loading />
+    );
+  };
+
+  if (isError) {
+    const err = error as Error;
+    return (
+      <Error message={err.message} />
+    );
+  };
+
+  return (
+    <div className={classes.page}>
+      <DataGrid
+        getRowId={(row) => row.source}
+        columns={columns}
+        rows={data ?? []}
+        rowsPerPageOptions={[]}
+        getRowHeight={() => "auto"}
+        disableColumnMenu
+      />
+    </div>
+  );
+};
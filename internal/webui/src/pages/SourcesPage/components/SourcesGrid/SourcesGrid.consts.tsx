@@ -123,6 +123,36 @@ export const useSourcesGridColumns = (): GridColDef<Source>[] => ([
     renderCell: ({row}) => <HostConfigPopUp source={row} />,
     hide: true,
   },
+  {
+    field: "Owner",
+    headerName: "Owner",
+    width: 150,
+    align: "left",
+    headerAlign: "center",
+    hide: true,
+  },
+  {
+    field: "Team",
+    headerName: "Team",
+    width: 150,
+    align: "left",
+    headerAlign: "center",
+  },
+  {
+    field: "Contact",
+    headerName: "Contact",
+    width: 150,
+    align: "left",
+    headerAlign: "center",
+  },
+  {
+    field: "Notes",
+    headerName: "Notes",
+    width: 200,
+    align: "left",
+    headerAlign: "center",
+    hide: true,
+  },
   {
     field: "OnlyIfMaster",
     headerName: "Primary mode only",
@@ -0,0 +1,11 @@
+import { useQuery } from "@tanstack/react-query";
+import { QueryKeys } from "consts/queryKeys";
+import { Compatibility } from "types/Compatibility/Compatibility";
+import CompatibilityService from "services/Compatibility";
+
+const services = CompatibilityService.getInstance();
+
+export const useCompatibility = () => useQuery<Compatibility[]>({
+  queryKey: [QueryKeys.Compatibility],
+  queryFn: async () => await services.getCompatibility()
+});
@@ -0,0 +1,21 @@
+import { useMutation, useQuery } from "@tanstack/react-query";
+import { QueryKeys } from "consts/queryKeys";
+import { Downtime, DowntimeRequestBody } from "types/Downtime/Downtime";
+import DowntimeService from "services/Downtime";
+
+const services = DowntimeService.getInstance();
+
+export const useDowntimes = () => useQuery<Downtime[]>({
+  queryKey: [QueryKeys.Downtime],
+  queryFn: async () => await services.getDowntimes()
+});
+
+export const useAddDowntime = () => useMutation({
+  mutationKey: [QueryKeys.Downtime],
+  mutationFn: async (data: DowntimeRequestBody) => await services.addDowntime(data),
+});
+
+export const useDeleteDowntime = () => useMutation({
+  mutationKey: [QueryKeys.Downtime],
+  mutationFn: async (id: string) => await services.deleteDowntime(id)
+});
@@ -0,0 +1,11 @@
+import { useQuery } from "@tanstack/react-query";
+import { QueryKeys } from "consts/queryKeys";
+import { Drift } from "types/Drift/Drift";
+import DriftService from "services/Drift";
+
+const services = DriftService.getInstance();
+
+export const useDrift = () => useQuery<Drift[]>({
+  queryKey: [QueryKeys.Drift],
+  queryFn: async () => await services.getDrift()
+});
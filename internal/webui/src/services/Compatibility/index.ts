@@ -0,0 +1,25 @@
+import { apiClient } from "api";
+import { AxiosInstance } from "axios";
+
+
+export default class CompatibilityService {
+  private api: AxiosInstance;
+  private static _instance: CompatibilityService;
+
+  constructor() {
+    this.api = apiClient();
+  }
+
+  public static getInstance(): CompatibilityService {
+    if (!CompatibilityService._instance) {
+      CompatibilityService._instance = new CompatibilityService();
+    }
+
+    return CompatibilityService._instance;
+  };
+
+  public async getCompatibility() {
+    return await this.api.get("/compatibility").
+      then(response => response.data);
+  };
+};
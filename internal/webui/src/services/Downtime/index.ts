@@ -0,0 +1,36 @@
+import { apiClient } from "api";
+import { AxiosInstance } from "axios";
+import { DowntimeRequestBody } from "types/Downtime/Downtime";
+
+
+export default class DowntimeService {
+  private api: AxiosInstance;
+  private static _instance: DowntimeService;
+
+  constructor() {
+    this.api = apiClient();
+  }
+
+  public static getInstance(): DowntimeService {
+    if (!DowntimeService._instance) {
+      DowntimeService._instance = new DowntimeService();
+    }
+
+    return DowntimeService._instance;
+  };
+
+  public async getDowntimes() {
+    return await this.api.get("/downtime").
+      then(response => response.data);
+  };
+
+  public async addDowntime(data: DowntimeRequestBody) {
+    return await this.api.post("/downtime", data).
+      then(response => response.data);
+  };
+
+  public async deleteDowntime(id: string) {
+    return await this.api.delete("/downtime", { params: { id } }).
+      then(response => response.data);
+  };
+};
@@ -0,0 +1,25 @@
+import { apiClient } from "api";
+import { AxiosInstance } from "axios";
+
+
+export default class DriftService {
+  private api: AxiosInstance;
+  private static _instance: DriftService;
+
+  constructor() {
+    this.api = apiClient();
+  }
+
+  public static getInstance(): DriftService {
+    if (!DriftService._instance) {
+      DriftService._instance = new DriftService();
+    }
+
+    return DriftService._instance;
+  };
+
+  public async getDrift() {
+    return await this.api.get("/drift").
+      then(response => response.data);
+  };
+};
@@ -0,0 +1,6 @@
+export type Compatibility = {
+  version: number;
+  sources?: string[];
+  missing_metrics: string[];
+  uncovered_views: string[];
+};
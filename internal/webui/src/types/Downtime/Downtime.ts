@@ -0,0 +1,12 @@
+export type Downtime = {
+  id: string;
+  dbname?: string;
+  group?: string;
+  metric?: string;
+  start: string;
+  end: string;
+  reason: string;
+  creator: string;
+};
+
+export type DowntimeRequestBody = Omit<Downtime, "id" | "creator">;
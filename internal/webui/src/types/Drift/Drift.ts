@@ -0,0 +1,13 @@
+export type Deviation = {
+  setting: string;
+  value: string;
+  expected: string;
+  baseline: "consensus" | "golden";
+};
+
+export type Drift = {
+  source: string;
+  group: string;
+  group_size: number;
+  deviations: Deviation[];
+};
@@ -14,4 +14,8 @@ export type Source = {
   CustomTags: Record<string, string> | null;
   HostConfig: object;
   OnlyIfMaster: boolean;
+  Owner: string;
+  Team: string;
+  Contact: string;
+  Notes: string;
 };